@@ -0,0 +1,121 @@
+// Package allocs counts heap allocations frame by frame, for checking that the
+// emulator's hot path -- the CPU loop, executing instructions, and the renderer,
+// drawing the screen -- still doesn't allocate after you've changed it. Nothing in
+// either needs to: the Chip8's memory and screen are all there from the start, so
+// every allocation is garbage the collector has to stop and pick up, sixty times a
+// second, and a stutter waiting to happen.
+//
+// The counts come from runtime.ReadMemStats, which counts every allocation in the
+// program, whichever goroutine made it, so they're upper bounds: a frame with none
+// is a frame with none, but a frame with some might have picked up somebody else's.
+// A Measure of the renderer counts its own, near enough (it runs on the main
+// thread, and nothing much else does while it runs), and whatever a Frame counts
+// that the renderer didn't is the CPU loop's, or the rest of the program's.
+//
+// ReadMemStats stops the world for a moment each time, which is fine for finding
+// allocations, and not something to leave on for playing.
+package allocs
+
+import (
+	"runtime"
+	"sync"
+)
+
+// A Counter is the allocations counted in one place, frame after frame.
+type Counter struct {
+	Name string
+	// Frames is how many frames (or Measures) were counted, and Allocating how
+	// many of those allocated at all.
+	Frames, Allocating int64
+	// Allocs and Bytes are how many allocations there were altogether, and how
+	// big, and Max the most allocations in any one frame.
+	Allocs, Bytes, Max uint64
+}
+
+// PerFrame returns how many allocations there were a frame, on average.
+func (c Counter) PerFrame() float64 {
+	if c.Frames == 0 {
+		return 0
+	}
+	return float64(c.Allocs) / float64(c.Frames)
+}
+
+func (c *Counter) add(allocs, bytes uint64) {
+	c.Frames++
+	if allocs > 0 {
+		c.Allocating++
+	}
+	c.Allocs += allocs
+	c.Bytes += bytes
+	if allocs > c.Max {
+		c.Max = allocs
+	}
+}
+
+// An Audit counts the allocations in each frame, and in the parts of it it's
+// asked to Measure. It's safe to use from any goroutine.
+type Audit struct {
+	mu      sync.Mutex
+	started bool
+	last    runtime.MemStats
+	frames  Counter
+	// sections are the Measured parts, in the order they were first measured.
+	sections []*Counter
+}
+
+// New returns an Audit that hasn't counted anything yet.
+func New() *Audit {
+	return &Audit{frames: Counter{Name: "every frame"}}
+}
+
+// Frame counts the allocations since the last Frame as a frame's. Call it at the
+// end of every frame, from the Chip8's OnFrame hook; the first call just starts
+// the count.
+func (a *Audit) Frame() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started {
+		a.frames.add(m.Mallocs-a.last.Mallocs, m.TotalAlloc-a.last.TotalAlloc)
+	}
+	a.started = true
+	a.last = m
+}
+
+// Measure calls f, and counts the allocations while it ran as name's, once per
+// frame: wrap the renderer's drawing of a frame in it, say.
+func (a *Audit) Measure(name string, f func()) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.section(name).add(after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+}
+
+// section returns the counter for name, starting one if there isn't one. a.mu
+// must be held.
+func (a *Audit) section(name string) *Counter {
+	for _, c := range a.sections {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Counter{Name: name}
+	a.sections = append(a.sections, c)
+	return c
+}
+
+// Counters returns what's been counted so far: every frame first, and then each
+// of the Measured parts.
+func (a *Audit) Counters() []Counter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counters := []Counter{a.frames}
+	for _, c := range a.sections {
+		counters = append(counters, *c)
+	}
+	return counters
+}
@@ -0,0 +1,70 @@
+package allocs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/allocs"
+	"github.com/mpingram/chip8/cpu"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+var sink []byte
+
+// Audit
+// should count the allocations in a Measure
+// should say so when nothing allocated
+func TestAudit(t *testing.T) {
+	a := allocs.New()
+	for i := 0; i < 3; i++ {
+		a.Frame()
+		a.Measure("renderer", func() { sink = make([]byte, 1<<16) })
+	}
+	counters := a.Counters()
+	if len(counters) != 2 || counters[0].Frames != 2 || counters[1].Name != "renderer" || counters[1].Frames != 3 {
+		t.Fatalf("got %+v", counters)
+	}
+	if r := counters[1]; r.Allocating != 3 || r.Allocs < 3 || r.Bytes < 3<<16 || r.PerFrame() < 1 {
+		t.Errorf("got %+v for the renderer", r)
+	}
+
+	var b bytes.Buffer
+	if err := allocs.New().WriteReport(&b); err != nil || !strings.Contains(b.String(), "No frames went by") {
+		t.Errorf("got %v,\n%s", err, b.String())
+	}
+}
+
+// The CPU loop
+// should run frame after frame without allocating
+func TestCPULoop(t *testing.T) {
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	// clear the screen, draw a random digit, and again: CLS, DRW and friends
+	// are the instructions most likely to have something to allocate.
+	if err := c8.Load([]byte{0x00, 0xe0, 0xc0, 0x0f, 0xf0, 0x29, 0xd1, 0x15, 0x71, 0x01, 0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	a := allocs.New()
+	c8.OnFrame(func(frame uint64) {
+		a.Frame()
+		if frame == 300 {
+			c8.Halt()
+		}
+	})
+	c8.SetTurbo(true)
+	c8.Resume()
+	// the test's the only other thing running, and it's waiting, so anything
+	// counted is the CPU loop's -- bar the odd stray from the runtime itself.
+	if frames := a.Counters()[0]; frames.Allocating > 5 {
+		t.Errorf("the CPU loop allocated in %d of %d frames, %d times in all", frames.Allocating, frames.Frames, frames.Allocs)
+	}
+}
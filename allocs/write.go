@@ -0,0 +1,40 @@
+package allocs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteReport writes what's been counted, for reading, and says whether the hot
+// path allocated.
+func (a *Audit) WriteReport(w io.Writer) error {
+	counters := a.Counters()
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tframes\tallocating\tallocs\tper frame\tmost in a frame\tbytes\t\n")
+	for _, c := range counters {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t%d\t%d\t\n", c.Name, c.Frames, c.Allocating, c.Allocs, c.PerFrame(), c.Max, c.Bytes)
+	}
+	tw.Flush()
+
+	frames := counters[0]
+	var measured uint64
+	for _, c := range counters[1:] {
+		measured += c.Allocs
+	}
+	switch {
+	case frames.Frames == 0:
+		fmt.Fprintf(&b, "\nNo frames went by, so there's nothing to say.\n")
+	case frames.Allocs == 0:
+		fmt.Fprintf(&b, "\nNothing allocated at all: the hot path's allocation-free.\n")
+	case measured >= frames.Allocs:
+		fmt.Fprintf(&b, "\nThe measured parts account for every allocation, so the CPU loop didn't allocate.\n")
+	default:
+		fmt.Fprintf(&b, "\n%d allocations (%.2f a frame) came from outside the measured parts: the CPU loop, or something else running alongside it.\n",
+			frames.Allocs-measured, float64(frames.Allocs-measured)/float64(frames.Frames))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,181 @@
+// Package archive gets games from the CHIP-8 Archive (github.com/JohnEarnest/chip8Archive),
+// the public collection of games from the Octo game jams, with a description of each
+// and the settings it was written for. It downloads the list of games, and the games
+// themselves, once, and keeps them, so a game that's been played once can be played
+// again on a train.
+//
+// The Archive's settings are Octo's (see the octo package), which go by different
+// names and the other way round half the time; Program.Metadata turns them into a metadata file (see the
+// metadata package), which is kept next to the game, so it's played the way it
+// should be wherever it's loaded from.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mpingram/chip8/metadata"
+	"github.com/mpingram/chip8/octo"
+	"github.com/mpingram/chip8/storage"
+)
+
+// DefaultBase is where the Archive is.
+const DefaultBase = "https://raw.githubusercontent.com/JohnEarnest/chip8Archive/master/"
+
+// maxDownload is as much as we'll download of anything: the list of games is a
+// few hundred kilobytes, and the biggest games 64k.
+const maxDownload = 8 << 20
+
+// indexKey is where the list of games is kept.
+const indexKey = "archive/programs.json"
+
+// ROMFolder is where the games are kept, once they're downloaded.
+const ROMFolder = "archive/roms"
+
+// ROMKey returns where the game with this ID is kept, once it's downloaded.
+// Its metadata is kept next to it, the way the metadata package expects.
+func ROMKey(id string) string {
+	return ROMFolder + "/" + id + ".ch8"
+}
+
+// An Archive is the CHIP-8 Archive, at Base, and where to keep what's downloaded from it.
+type Archive struct {
+	Base   string
+	Client *http.Client
+	Store  storage.Storage
+}
+
+// New returns the Archive at DefaultBase, keeping what it downloads in store.
+func New(store storage.Storage) *Archive {
+	return &Archive{Base: DefaultBase, Client: http.DefaultClient, Store: store}
+}
+
+// A Program is a game in the Archive.
+type Program struct {
+	// ID is what the Archive calls it, and its ROM's file name.
+	ID          string
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	Description string   `json:"desc"`
+	// Event is the game jam it was made for, and Release when it came out.
+	Event   string `json:"event"`
+	Release string `json:"release"`
+	// Platform is what it was written for: chip8, schip or xochip.
+	Platform string       `json:"platform"`
+	Options  octo.Options `json:"options"`
+}
+
+// Metadata returns the program's metadata, for keeping next to it.
+func (p Program) Metadata() *metadata.Metadata {
+	quirks := p.Options.Quirks()
+	return &metadata.Metadata{
+		Title:       p.Title,
+		Author:      strings.Join(p.Authors, ", "),
+		Description: p.Description,
+		Speed:       p.Options.Speed(),
+		Quirks:      &quirks,
+		Font:        p.Options.Font(),
+	}
+}
+
+// Matches returns true if every one of words is somewhere in the program's ID,
+// title, authors or description, in any case.
+func (p Program) Matches(words []string) bool {
+	text := strings.ToLower(strings.Join(append([]string{p.ID, p.Title, p.Description}, p.Authors...), " "))
+	for _, word := range words {
+		if !strings.Contains(text, strings.ToLower(word)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads the Archive's list of games, programs.json, and returns them in order of title.
+func Parse(r io.Reader) ([]Program, error) {
+	var index map[string]Program
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return nil, fmt.Errorf("the list of games doesn't make sense: %v", err)
+	}
+	programs := make([]Program, 0, len(index))
+	for id, p := range index {
+		p.ID = id
+		if p.Title == "" {
+			p.Title = id
+		}
+		programs = append(programs, p)
+	}
+	sort.Slice(programs, func(i, j int) bool {
+		return strings.ToLower(programs[i].Title) < strings.ToLower(programs[j].Title)
+	})
+	return programs, nil
+}
+
+// Programs returns the games in the Archive. It downloads the list afresh if it
+// can, and if it can't, makes do with the one it downloaded last time.
+func (a *Archive) Programs(ctx context.Context) ([]Program, error) {
+	index, err := a.download(ctx, "programs.json")
+	if err == nil {
+		// if it can't be kept, it's downloaded again next time; that's all.
+		a.Store.Put(indexKey, index)
+	} else {
+		kept, keptErr := a.Store.Get(indexKey)
+		if keptErr != nil {
+			return nil, err
+		}
+		index = kept
+	}
+	return Parse(bytes.NewReader(index))
+}
+
+// Download downloads the program, and its metadata, unless they've been downloaded
+// already. It's kept under ROMKey(p.ID).
+func (a *Archive) Download(ctx context.Context, p Program) error {
+	key := ROMKey(p.ID)
+	if _, err := a.Store.Get(key); err == nil {
+		return nil
+	}
+	rom, err := a.download(ctx, "roms/"+p.ID+".ch8")
+	if err != nil {
+		return err
+	}
+	m, err := p.Metadata().Marshal()
+	if err != nil {
+		return err
+	}
+	// the metadata goes first, so there's never a ROM without it.
+	if err := a.Store.Put(metadata.Path(key), m); err != nil {
+		return err
+	}
+	return a.Store.Put(key, rom)
+}
+
+// download gets the file at path in the Archive.
+func (a *Archive) download(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", a.Base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s from the archive: %s", path, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, fmt.Errorf("downloading %s from the archive: it's too big", path)
+	}
+	return data, nil
+}
@@ -0,0 +1,90 @@
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpingram/chip8/archive"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/metadata"
+	"github.com/mpingram/chip8/storage"
+)
+
+const programs = `{
+	"octojam1title": {"title": "Octojam 1 Title", "authors": ["John Earnest"], "desc": "A title screen.", "platform": "chip8", "options": {"tickrate": 7, "fontStyle": "vip"}},
+	"slipperyslope": {"title": "Slippery Slope", "authors": ["John Earnest"], "desc": "Push the penguin.", "platform": "chip8", "options": {"tickrate": 15, "shiftQuirks": true, "loadStoreQuirks": true, "fillColor": "#FFFFFF"}}
+}`
+
+// Archive.Programs / Download / Program.Metadata
+// should list the games in order of title, with the settings they want, in our quirks
+// should download a game and its metadata once, and keep them
+// should make do with the list it kept when the archive can't be reached
+func TestArchive(t *testing.T) {
+	var downloads int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		switch r.URL.Path {
+		case "/programs.json":
+			w.Write([]byte(programs))
+		case "/roms/slipperyslope.ch8":
+			w.Write([]byte{0x12, 0x00})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	store := storage.NewMemory()
+	a := &archive.Archive{Base: ts.URL + "/", Client: ts.Client(), Store: store}
+	ctx := context.Background()
+
+	list, err := a.Programs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "octojam1title" || list[1].Title != "Slippery Slope" {
+		t.Fatalf("got %+v", list)
+	}
+	title, slope := list[0], list[1]
+	if q := title.Options.Quirks(); !q.ShiftVy || !q.IncrementI || q.JumpVx {
+		t.Errorf("a game with no Octo quirks got %v, want shift-vy and increment-i", q)
+	}
+	if q := slope.Options.Quirks(); q != (cpu.Quirks{}) || slope.Options.Speed() != 900 {
+		t.Errorf("a game with Octo's SCHIP quirks got %v at %d, want none at 900", q, slope.Options.Speed())
+	}
+	if m := title.Metadata(); m.Font == nil || m.Font.Name != "vip" || m.Name() != "Octojam 1 Title by John Earnest" {
+		t.Errorf("got metadata %+v", m)
+	}
+	if !slope.Matches([]string{"PENGUIN", "earnest"}) || slope.Matches([]string{"penguin", "pong"}) {
+		t.Errorf("searching for games doesn't work")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := a.Download(ctx, slope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if downloads != 2 {
+		t.Errorf("downloaded %d times, want twice: the list, and the game once", downloads)
+	}
+	rom, err := store.Get(archive.ROMKey("slipperyslope"))
+	if err != nil || len(rom) != 2 {
+		t.Errorf("got ROM % x, %v", rom, err)
+	}
+	data, err := store.Get(metadata.Path(archive.ROMKey("slipperyslope")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := metadata.Parse(bytes.NewReader(data)); err != nil || m.Title != "Slippery Slope" || m.Speed != 900 {
+		t.Errorf("got metadata %+v, %v", m, err)
+	}
+	if err := a.Download(ctx, archive.Program{ID: "missing"}); err == nil {
+		t.Errorf("downloaded a game that isn't there")
+	}
+
+	ts.Close()
+	if list, err := a.Programs(ctx); err != nil || len(list) != 2 {
+		t.Errorf("offline, got %d games and %v, want the 2 from last time", len(list), err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package asm assembles Chip8 assembly language, written the way cpu.Disassemble
+// writes it, into programs the Chip8 can run:
+//
+//	; comments start with a semicolon
+//	start:              ; a label is a name for the address of whatever comes after it
+//	    LD V0,5         ; numbers can be decimal, 0x hex, or 0b binary
+//	    LD I,sprite     ; labels can go anywhere an address can
+//	    DRW V0,V0,3
+//	    JP start
+//	sprite:
+//	    DB 0b11100000,0b10100000,0b11100000   ; DB puts bytes straight into the program
+//
+// Mnemonics and register names can be in either case, and spaces after the commas are fine.
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProgramStart is where programs are loaded, and so the address of their first byte.
+const ProgramStart = 0x200
+
+// An Error is a mistake in the source, and where it was.
+type Error struct {
+	Line int
+	Err  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// statement is one line of source, taken apart.
+type statement struct {
+	line     int
+	mnemonic string
+	operands []string
+}
+
+// Assemble assembles a program, to be loaded at ProgramStart.
+func Assemble(source string) ([]byte, error) {
+	program, _, err := AssembleWithLabels(source)
+	return program, err
+}
+
+// Labels are the addresses a program's labels ended up at, by name, in lower case.
+type Labels map[string]uint16
+
+// AssembleWithLabels is Assemble, and it says where the labels went too -- for
+// debuggers, which would rather you said "break at loop" than "break at 0x2a4".
+func AssembleWithLabels(source string) ([]byte, Labels, error) {
+	// first, find out where everything goes, so labels can be used before they're defined...
+	labels := make(map[string]int)
+	var statements []statement
+	address := ProgramStart
+	for n, line := range strings.Split(source, "\n") {
+		if semicolon := strings.Index(line, ";"); semicolon >= 0 {
+			line = line[:semicolon]
+		}
+		line = strings.TrimSpace(line)
+		for {
+			colon := strings.Index(line, ":")
+			if colon < 0 {
+				break
+			}
+			label := strings.ToLower(strings.TrimSpace(line[:colon]))
+			if !isName(label) {
+				return nil, nil, &Error{n + 1, fmt.Sprintf("%q isn't a good name for a label", label)}
+			}
+			if _, ok := labels[label]; ok {
+				return nil, nil, &Error{n + 1, fmt.Sprintf("label %s is already defined", label)}
+			}
+			labels[label] = address
+			line = strings.TrimSpace(line[colon+1:])
+		}
+		if line == "" {
+			continue
+		}
+		st := parseStatement(line)
+		st.line = n + 1
+		statements = append(statements, st)
+		if st.mnemonic == "DB" {
+			address += len(st.operands)
+		} else {
+			address += 2
+		}
+	}
+	if address > 0x1000 {
+		return nil, nil, fmt.Errorf("the program is %d bytes long; only %d fit in memory", address-ProgramStart, 0x1000-ProgramStart)
+	}
+
+	// ...then put it all together.
+	program := make([]byte, 0, address-ProgramStart)
+	for _, st := range statements {
+		if st.mnemonic == "DB" {
+			for _, operand := range st.operands {
+				b, err := number(operand, 0xff)
+				if err != nil {
+					return nil, nil, &Error{st.line, err.Error()}
+				}
+				program = append(program, byte(b))
+			}
+			continue
+		}
+		opcode, err := encode(st, labels)
+		if err != nil {
+			return nil, nil, &Error{st.line, err.Error()}
+		}
+		program = append(program, byte(opcode>>8), byte(opcode))
+	}
+	found := make(Labels, len(labels))
+	for name, address := range labels {
+		found[name] = uint16(address)
+	}
+	return program, found, nil
+}
+
+// Address works out which address s is: a label, a label and how far past it, like
+// loop+4, or just a number, like 0x2a4.
+func (l Labels) Address(s string) (uint16, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if n, err := number(s, 0xfff); err == nil {
+		return n, nil
+	}
+	name, offset := s, uint16(0)
+	if plus := strings.Index(s, "+"); plus >= 0 {
+		n, err := number(strings.TrimSpace(s[plus+1:]), 0xfff)
+		if err != nil {
+			return 0, err
+		}
+		name, offset = strings.TrimSpace(s[:plus]), n
+	}
+	address, ok := l[name]
+	if !ok {
+		return 0, fmt.Errorf("there's no label called %s", name)
+	}
+	if int(address)+int(offset) > 0xfff {
+		return 0, fmt.Errorf("%s is past the end of memory", s)
+	}
+	return address + offset, nil
+}
+
+// Instruction assembles one instruction, like "ADD V1,2", into its opcode.
+// It can't refer to labels, since there aren't any.
+func Instruction(source string) (uint16, error) {
+	if semicolon := strings.Index(source, ";"); semicolon >= 0 {
+		source = source[:semicolon]
+	}
+	st := parseStatement(strings.TrimSpace(source))
+	if st.mnemonic == "DB" {
+		return 0, fmt.Errorf("DB isn't an instruction")
+	}
+	return encode(st, nil)
+}
+
+func parseStatement(line string) statement {
+	fields := strings.SplitN(line, " ", 2)
+	st := statement{mnemonic: strings.ToUpper(fields[0])}
+	if len(fields) == 2 && strings.TrimSpace(fields[1]) != "" {
+		for _, operand := range strings.Split(fields[1], ",") {
+			st.operands = append(st.operands, strings.ToUpper(strings.TrimSpace(operand)))
+		}
+	}
+	return st
+}
+
+func isName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '-' || r == '.' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// register returns the number of the V register named by operand, like 10 for VA.
+func register(operand string) (uint16, bool) {
+	if len(operand) != 2 || operand[0] != 'V' {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(operand[1:], 16, 4)
+	return uint16(n), err == nil
+}
+
+func number(operand string, max uint64) (uint16, error) {
+	n, err := strconv.ParseUint(strings.ToLower(operand), 0, 16)
+	if err != nil || n > max {
+		return 0, fmt.Errorf("%s isn't a number from 0 to %#x", operand, max)
+	}
+	return uint16(n), nil
+}
+
+// encode works out the opcode for an instruction.
+func encode(st statement, labels map[string]int) (uint16, error) {
+	ops := st.operands
+	want := func(n int) error {
+		if len(ops) != n {
+			return fmt.Errorf("%s takes %d operands, not %d", st.mnemonic, n, len(ops))
+		}
+		return nil
+	}
+	address := func(operand string) (uint16, error) {
+		if addr, ok := labels[strings.ToLower(operand)]; ok {
+			return uint16(addr), nil
+		}
+		if isName(strings.ToLower(operand)) && !(operand[0] >= '0' && operand[0] <= '9') {
+			return 0, fmt.Errorf("there's no label %s", strings.ToLower(operand))
+		}
+		return number(operand, 0xfff)
+	}
+	// reg, or reg and something else, are the shapes most instructions come in.
+	reg := func(i int) (uint16, error) {
+		x, ok := register(ops[i])
+		if !ok {
+			return 0, fmt.Errorf("%s needs a register like V0 there, not %s", st.mnemonic, ops[i])
+		}
+		return x, nil
+	}
+	regReg := func(base uint16) (uint16, error) {
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		x, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		y, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		return base | x<<8 | y<<4, nil
+	}
+	regByteOrReg := func(byteBase, regBase uint16) (uint16, error) {
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		x, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		if y, ok := register(ops[1]); ok {
+			if regBase == 0 {
+				return 0, fmt.Errorf("%s can't take two registers", st.mnemonic)
+			}
+			return regBase | x<<8 | y<<4, nil
+		}
+		kk, err := number(ops[1], 0xff)
+		if err != nil {
+			return 0, err
+		}
+		return byteBase | x<<8 | kk, nil
+	}
+	oneReg := func(base uint16) (uint16, error) {
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		x, err := reg(0)
+		return base | x<<8, err
+	}
+
+	switch st.mnemonic {
+	case "CLS":
+		return 0x00e0, want(0)
+	case "RET":
+		return 0x00ee, want(0)
+	case "SCD":
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		n, err := number(ops[0], 0xf)
+		return 0x00c0 | n, err
+	case "SCR":
+		return 0x00fb, want(0)
+	case "SCL":
+		return 0x00fc, want(0)
+	case "JP":
+		if len(ops) == 2 && ops[0] == "V0" {
+			addr, err := address(ops[1])
+			return 0xb000 | addr, err
+		}
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		addr, err := address(ops[0])
+		return 0x1000 | addr, err
+	case "CALL":
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		addr, err := address(ops[0])
+		return 0x2000 | addr, err
+	case "SE":
+		return regByteOrReg(0x3000, 0x5000)
+	case "SNE":
+		return regByteOrReg(0x4000, 0x9000)
+	case "RND":
+		return regByteOrReg(0xc000, 0)
+	case "OR":
+		return regReg(0x8001)
+	case "AND":
+		return regReg(0x8002)
+	case "XOR":
+		return regReg(0x8003)
+	case "SUB":
+		return regReg(0x8005)
+	case "SUBN":
+		return regReg(0x8007)
+	case "SHR", "SHL":
+		base := uint16(0x8006)
+		if st.mnemonic == "SHL" {
+			base = 0x800e
+		}
+		// the second register is optional; the original interpreter ignored it anyway.
+		if len(ops) == 1 {
+			x, err := reg(0)
+			return base | x<<8 | x<<4, err
+		}
+		return regReg(base)
+	case "SKP":
+		return oneReg(0xe09e)
+	case "SKNP":
+		return oneReg(0xe0a1)
+	case "SKP2":
+		return oneReg(0xe0f2)
+	case "SKNP2":
+		return oneReg(0xe0f5)
+	case "PLANE":
+		// which planes to draw on: 1, 2, or 3 for both (see cpu/planes.go).
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		n, err := number(ops[0], 0x3)
+		return 0xf001 | n<<8, err
+	case "DRW":
+		if err := want(3); err != nil {
+			return 0, err
+		}
+		x, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		y, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		n, err := number(ops[2], 0xf)
+		return 0xd000 | x<<8 | y<<4 | n, err
+	case "ADD":
+		if len(ops) == 2 && ops[0] == "I" {
+			x, err := reg(1)
+			return 0xf01e | x<<8, err
+		}
+		return regByteOrReg(0x7000, 0x8004)
+	case "LD":
+		return encodeLD(ops, address, reg, regByteOrReg)
+	}
+	return 0, fmt.Errorf("%s isn't an instruction", st.mnemonic)
+}
+
+// encodeLD works out the opcode for LD, which has more forms than everything else put together.
+func encodeLD(ops []string, address func(string) (uint16, error), reg func(int) (uint16, error), regByteOrReg func(uint16, uint16) (uint16, error)) (uint16, error) {
+	if len(ops) != 2 {
+		return 0, fmt.Errorf("LD takes 2 operands, not %d", len(ops))
+	}
+	// LD somewhere,Vx
+	into := map[string]uint16{"DT": 0xf015, "ST": 0xf018, "F": 0xf029, "HF": 0xf030, "B": 0xf033, "[I]": 0xf055, "R": 0xf075}
+	if base, ok := into[ops[0]]; ok {
+		x, err := reg(1)
+		return base | x<<8, err
+	}
+	// LD Vx,somewhere
+	from := map[string]uint16{"DT": 0xf007, "K": 0xf00a, "[I]": 0xf065, "R": 0xf085}
+	if base, ok := from[ops[1]]; ok {
+		x, err := reg(0)
+		return base | x<<8, err
+	}
+	if ops[0] == "I" {
+		addr, err := address(ops[1])
+		return 0xa000 | addr, err
+	}
+	return regByteOrReg(0x6000, 0x8000)
+}
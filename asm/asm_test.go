@@ -0,0 +1,84 @@
+package asm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// Instruction
+// should assemble everything Disassemble writes back into the same opcode
+func TestInstructionRoundTrip(t *testing.T) {
+	for _, opcode := range []uint16{
+		0x00e0, 0x00ee, 0x00c4, 0x00fb, 0x00fc, 0x1234, 0x2abc, 0x3a0c, 0x4b10, 0x5120, 0x6a0c, 0x7301,
+		0x8120, 0x8121, 0x8122, 0x8123, 0x8124, 0x8125, 0x8126, 0x8127, 0x812e,
+		0x9450, 0xa20a, 0xb300, 0xc70f, 0xd235, 0xe19e, 0xe2a1, 0xe3f2, 0xe4f5,
+		0xf307, 0xf40a, 0xf515, 0xf618, 0xf71e, 0xf829, 0xf933, 0xfa55, 0xfb65, 0xf375, 0xf285, 0xf630, 0xf301,
+	} {
+		source := cpu.Disassemble(opcode)
+		got, err := asm.Instruction(source)
+		if err != nil {
+			t.Errorf("Instruction(%q): %v", source, err)
+			continue
+		}
+		if got != opcode {
+			t.Errorf("Instruction(%q) = %04x, want %04x", source, got, opcode)
+		}
+	}
+
+	for _, bad := range []string{"", "NOP", "LD V0", "LD VG,1", "ADD V0,256", "JP 0x1000", "DRW V0,V1,16", "RND V0,V1", "PLANE 4"} {
+		if _, err := asm.Instruction(bad); err == nil {
+			t.Errorf("Instruction(%q) should have failed", bad)
+		}
+	}
+}
+
+// Assemble
+// should put labels and data where they go, and say which line anything's wrong with
+func TestAssemble(t *testing.T) {
+	program, err := asm.Assemble(`
+		; a comment on its own
+		start:  ld i, sprite   ; lower case is fine too
+		        jp start
+		sprite: DB 0xff,0b1010,3
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xa2, 0x04, 0x12, 0x00, 0xff, 0x0a, 0x03}
+	if !bytes.Equal(program, want) {
+		t.Errorf("got % x, want % x", program, want)
+	}
+
+	_, err = asm.Assemble("CLS\nJP nowhere\n")
+	if err == nil || err.Error() != "line 2: there's no label nowhere" {
+		t.Errorf("got error %v, want one about line 2", err)
+	}
+}
+
+// AssembleWithLabels / Labels.Address
+// should say where each label went
+// should find addresses by label, by label and offset, and by number
+func TestLabels(t *testing.T) {
+	_, labels, err := asm.AssembleWithLabels(`
+		start:  CLS
+		Loop:   JP loop
+		sprite: DB 1,2,3
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s, want := range map[string]uint16{"start": 0x200, "loop": 0x202, "LOOP": 0x202, "sprite+2": 0x206, "sprite + 1": 0x205, "0x2a4": 0x2a4} {
+		got, err := labels.Address(s)
+		if err != nil || got != want {
+			t.Errorf("Address(%q) = %03x, %v; want %03x", s, got, err, want)
+		}
+	}
+	for _, bad := range []string{"nowhere", "loop+", "loop+x", "sprite+0xfff"} {
+		if _, err := labels.Address(bad); err == nil {
+			t.Errorf("Address(%q) should have failed", bad)
+		}
+	}
+}
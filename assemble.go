@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/watch"
+)
+
+func init() {
+	commands["asm"] = command{
+		usage: "assemble a program (see the asm package) into a ROM -- and with -watch, again every time it's saved, and with -run, play it as you go",
+		run:   asmCommand,
+	}
+}
+
+// runAssembled plays the program in a window, for asm -run: with watching, it
+// swaps each new version in as it's saved. It's nil if this chip8 was built without
+// a window to play it in (see assemblerun.go).
+var runAssembled func(a *assembly, watching bool, breakpoints []string) error
+
+// An assembly is a source file, and the ROM it assembles into.
+type assembly struct {
+	source, output string
+	// program and labels are what it assembled into last time it assembled.
+	program []byte
+	labels  asm.Labels
+}
+
+// assemble assembles the source, and writes the ROM if it assembled. If it didn't,
+// the program and labels stay the way they were.
+func (a *assembly) assemble() error {
+	source, err := ioutil.ReadFile(a.source)
+	if err != nil {
+		return err
+	}
+	program, labels, err := asm.AssembleWithLabels(string(source))
+	if err != nil {
+		return fmt.Errorf("%s: %v", a.source, err)
+	}
+	if err := ioutil.WriteFile(a.output, program, 0644); err != nil {
+		return err
+	}
+	a.program, a.labels = program, labels
+	return nil
+}
+
+func asmCommand(args []string) error {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	output := flags.String("o", "", "write the ROM to `file` (default: the source's name, ending in .ch8)")
+	watching := flags.Bool("watch", false, "assemble it again every time it's saved")
+	run := flags.Bool("run", false, "play it; with -watch, each new version goes into memory in place of the old one, while it runs")
+	breakList := flags.String("break", "", "with -run, stop before the instructions at these addresses, as a comma-separated `list` of labels, label+offset or numbers, like loop,draw+4,0x2a4")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 asm [flags] program.8o\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	a := &assembly{source: flags.Arg(0), output: *output}
+	if a.output == "" {
+		a.output = strings.TrimSuffix(a.source, filepath.Ext(a.source)) + ".ch8"
+	}
+	if a.output == a.source {
+		return fmt.Errorf("writing the ROM to %s would write over the source", a.output)
+	}
+	var breakpoints []string
+	if *breakList != "" {
+		if !*run {
+			return fmt.Errorf("-break only works with -run")
+		}
+		breakpoints = strings.Split(*breakList, ",")
+	}
+	if *run && runAssembled == nil {
+		return fmt.Errorf("-run needs a window to play it in, and this chip8 was built without one")
+	}
+
+	if err := a.assemble(); err != nil {
+		// there's no playing a program that hasn't assembled yet, but with
+		// -watch alone, the next save might fix it.
+		if *run || !*watching {
+			return err
+		}
+		log.Print(err)
+	} else {
+		log.Printf("assembled %s: %d bytes", a.output, len(a.program))
+	}
+	if *run {
+		return runAssembled(a, *watching, breakpoints)
+	}
+	if !*watching {
+		return nil
+	}
+
+	w, err := watch.New(a.source)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	log.Printf("watching %s for changes; Ctrl-C to stop", a.source)
+	for range w.Changes() {
+		if err := a.assemble(); err != nil {
+			log.Print(err)
+			continue
+		}
+		log.Printf("assembled %s: %d bytes", a.output, len(a.program))
+	}
+	return nil
+}
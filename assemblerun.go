@@ -0,0 +1,171 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/watch"
+)
+
+func init() {
+	runAssembled = runAssembly
+}
+
+// breakpoints are where asm -run stops. They're kept by what they were called on
+// the command line -- a label, a label and an offset, or a number -- so they can be
+// found again in each new version of the program, wherever the labels have moved to.
+type breakpoints struct {
+	names []string
+	// at is where they are in the version of the program that's running. The
+	// Chip8's goroutine looks at it every instruction, so only touch it with mu held.
+	mu sync.Mutex
+	at map[uint16]bool
+}
+
+// place works out where the breakpoints are in a program with these labels, and
+// returns the ones that aren't anywhere any more.
+func (b *breakpoints) place(labels asm.Labels) (lost []string) {
+	at := make(map[uint16]bool)
+	for _, name := range b.names {
+		address, err := labels.Address(name)
+		if err != nil {
+			lost = append(lost, name)
+			continue
+		}
+		at[address] = true
+	}
+	b.mu.Lock()
+	b.at = at
+	b.mu.Unlock()
+	return lost
+}
+
+// has returns true if there's a breakpoint at address.
+func (b *breakpoints) has(address uint16) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.at[address]
+}
+
+// runAssembly is asm -run. Each time the source is saved and assembles, the new
+// program goes into memory over the old one, right where it is, and the game
+// carries on with everything else -- registers, stack, timers and screen -- the way
+// it was: change a sprite, or what a key does, and see it straight away, without
+// having to play back to where you were. (Change too much, and the game's likely to
+// come off the rails; hold the reset key to start it over.)
+func runAssembly(a *assembly, watching bool, names []string) error {
+	stops := &breakpoints{names: names}
+	if lost := stops.place(a.labels); len(lost) > 0 {
+		return fmt.Errorf("there's nowhere to break at %s", strings.Join(lost, ", "))
+	}
+	var w *watch.Watcher
+	if watching {
+		var err error
+		if w, err = watch.New(a.source); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	window := openWindow(false, 0)
+	defer glfw.Terminate()
+	renderer := NewOpenGLRenderer(window)
+	title := newWindowTitle(window, a.output)
+	input := NewGLFWKeyboardInput(window)
+	osd := new(onScreenDisplay)
+
+	c8 := cpu.NewChip8(control.NewKeypad(input), silentSpeaker{})
+	// nobody's going to read the log, and it would grow forever.
+	c8.SetLogLevel(cpu.LogNone)
+	if err := c8.Load(a.program); err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		c8.OnInstruction(func(pc, opcode uint16) {
+			// stop before the instruction at the breakpoint, not after it.
+			if stops.has(c8.Snapshot().PC) {
+				c8.Break()
+			}
+		})
+	}
+	bindPauseKey(input, c8)
+	hold := bindResetKey(input, osd, func() {
+		c8.Halt()
+		c8.Wait()
+		if err := c8.Load(a.program); err != nil {
+			log.Printf("resetting: %v", err)
+		}
+		go resume(c8)
+	})
+	bindHelpKey(input, osd)
+	go resume(c8)
+
+	// swapIn assembles the source again, and puts what it assembles into in place of
+	// what's running.
+	swapIn := func() {
+		old := len(a.program)
+		if err := a.assemble(); err != nil {
+			log.Print(err)
+			osd.showToast(strings.TrimPrefix(err.Error(), a.source+": "))
+			return
+		}
+		code := a.program
+		if len(code) < old {
+			// clear out the end of the old version, so nothing jumps into what's left of it.
+			code = append(code[:len(code):len(code)], make([]byte, old-len(code))...)
+		}
+		if err := c8.WriteMemory(asm.ProgramStart, code); err != nil {
+			log.Printf("swapping in %s: %v", a.output, err)
+			osd.showToast("couldn't swap the new version in")
+			return
+		}
+		log.Printf("assembled %s: %d bytes", a.output, len(a.program))
+		if lost := stops.place(a.labels); len(lost) > 0 {
+			log.Printf("there's nowhere to break at %s any more", strings.Join(lost, ", "))
+			osd.showToast("lost the breakpoints at " + strings.Join(lost, ", "))
+			return
+		}
+		osd.showToast(fmt.Sprintf("swapped in: %d bytes", len(a.program)))
+	}
+
+	refresh := time.NewTicker(time.Second / 60)
+	defer refresh.Stop()
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+		title.update(c8)
+		hold.update(time.Now())
+		showPaused(osd, c8)
+		if w != nil {
+			select {
+			case <-w.Changes():
+				swapIn()
+			default:
+			}
+		}
+
+		<-refresh.C
+		frameReady := false
+		select {
+		case <-c8.FrameReady():
+			frameReady = true
+		default:
+		}
+		overlayChanged := renderer.SetOverlay(osd.lines(time.Now()))
+		if frameReady || overlayChanged {
+			renderer.Render(c8.ColorFrame())
+		}
+	}
+	c8.Halt()
+	c8.Wait()
+	return nil
+}
@@ -0,0 +1,139 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/demo"
+)
+
+// attractMode shows off the ROM library with nobody at the keys, like an arcade
+// cabinet between customers: each ROM the menu would list gets its turn on screen
+// for a while, with its name up in the corner, playing itself if it has a demo
+// script (see the demo package). As soon as somebody presses a key, it's their
+// game, and attract mode is over.
+type attractMode struct {
+	menu   *menu
+	osd    *onScreenDisplay
+	c8     *cpu.Chip8
+	keypad *control.Keypad
+	input  *GLFWKeyboardInput
+	// each is how long each ROM gets.
+	each time.Duration
+
+	roms []string
+	// current is the index in roms of the ROM that's on, and until is when it's
+	// the next one's turn.
+	current int
+	until   time.Time
+	// script is the current ROM's demo script, if it has one, and held the key
+	// it's holding down.
+	script *demo.Script
+	held   cpu.KeyCode
+	// over is true once somebody's taken over.
+	over bool
+}
+
+// startAttractMode starts attract mode, at the first ROM in the library. The Chip8
+// should be stopped; attract mode starts and stops it from here on.
+func startAttractMode(m *menu, osd *onScreenDisplay, c8 *cpu.Chip8, keypad *control.Keypad, input *GLFWKeyboardInput, each time.Duration) *attractMode {
+	a := &attractMode{menu: m, osd: osd, c8: c8, keypad: keypad, input: input, each: each, roms: m.roms(), current: -1, held: cpu.KeyNone}
+	a.next(time.Now())
+	return a
+}
+
+// update moves on to the next ROM when it's time, plays the demo script, and
+// notices anybody pressing a key. Call it once a frame, on the main thread.
+func (a *attractMode) update(now time.Time) {
+	if a.over {
+		return
+	}
+	if a.input.Held() != 0 {
+		a.stop()
+		return
+	}
+	if !now.Before(a.until) {
+		a.next(now)
+	}
+	if a.script == nil {
+		return
+	}
+	key := a.script.Held(a.c8.FrameCount())
+	if key == a.held {
+		return
+	}
+	if a.held != cpu.KeyNone {
+		a.keypad.Release(a.held)
+	}
+	if key != cpu.KeyNone {
+		a.keypad.Press(key)
+	}
+	a.held = key
+}
+
+// next loads the next ROM that will load, and starts it.
+func (a *attractMode) next(now time.Time) {
+	a.releaseKey()
+	a.until = now.Add(a.each)
+	for tries := 0; tries < len(a.roms); tries++ {
+		a.current = (a.current + 1) % len(a.roms)
+		path := a.roms[a.current]
+		if err := a.menu.load(path); err != nil {
+			log.Printf("attract mode: skipping %s: %v", path, err)
+			continue
+		}
+		a.menu.romPath = path
+		a.script = readDemoScript(path)
+		a.osd.setIndicator("attract", romLabel(path)+" - press any key to play")
+		go resume(a.c8)
+		return
+	}
+	log.Printf("attract mode: there's nothing to show")
+	a.stop()
+}
+
+// stop ends attract mode, leaving whatever's on to whoever pressed the key.
+func (a *attractMode) stop() {
+	a.releaseKey()
+	a.over = true
+	a.script = nil
+	a.osd.setIndicator("attract", "")
+}
+
+// releaseKey lets go of the key the demo script has down, if it has one.
+func (a *attractMode) releaseKey() {
+	if a.held != cpu.KeyNone {
+		a.keypad.Release(a.held)
+		a.held = cpu.KeyNone
+	}
+}
+
+// readDemoScript reads the demo script for the ROM in path, if there is one.
+func readDemoScript(path string) *demo.Script {
+	if strings.HasPrefix(path, tutorialPrefix) {
+		return nil
+	}
+	scriptPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".demo"
+	f, err := os.Open(scriptPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("attract mode: %v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+	script, err := demo.Parse(f)
+	if err != nil {
+		log.Printf("attract mode: %s: %v", scriptPath, err)
+		return nil
+	}
+	return script
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/archive"
+)
+
+func init() {
+	commands["browse"] = command{
+		usage: "find a game in the CHIP-8 Archive, download it, and play it the way it was meant to be played",
+		run:   browseCommand,
+	}
+}
+
+// browseCommand lists the games in the CHIP-8 Archive (see the archive package)
+// that match the words on the command line, asks which one to play, and plays it:
+// downloaded once and kept, with the speed, quirks and font it was written for
+// (in its metadata, so they're there when it's loaded from the menu later, too).
+func browseCommand(args []string) error {
+	flags := flag.NewFlagSet("browse", flag.ExitOnError)
+	all := flags.Bool("all", false, "list the SCHIP and XO-CHIP games too, which need more than this Chip8 has, and may not work")
+	dataDir := flags.String("dir", "", "keep the games under `folder` (default: the usual place on this system)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 browse [flags] [words to look for...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	store := openStore(*dataDir)
+	a := archive.New(store)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	programs, err := a.Programs(ctx)
+	if err != nil {
+		return err
+	}
+	var found []archive.Program
+	for _, p := range programs {
+		if (*all || p.Platform == "chip8") && p.Matches(flags.Args()) {
+			found = append(found, p)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("there's nothing like that in the archive")
+	}
+
+	for i, p := range found {
+		fmt.Printf("%3d. %s", i+1, p.Title)
+		if len(p.Authors) > 0 {
+			fmt.Printf(", by %s", strings.Join(p.Authors, ", "))
+		}
+		if p.Platform != "chip8" {
+			fmt.Printf(" (%s)", p.Platform)
+		}
+		fmt.Println()
+		if p.Description != "" {
+			fmt.Printf("     %s\n", summary(p.Description, 100))
+		}
+	}
+	chosen := found[0]
+	if len(found) > 1 {
+		fmt.Printf("play which one? (1-%d, or enter for none) ", len(found))
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 || n > len(found) {
+			return fmt.Errorf("there's no number %s", line)
+		}
+		chosen = found[n-1]
+	}
+
+	if err := a.Download(ctx, chosen); err != nil {
+		return err
+	}
+	romPath, err := store.Path(archive.ROMKey(chosen.ID))
+	if err != nil {
+		return err
+	}
+	// the emulator proper is main, with the ROM on the command line; this is
+	// simpler than pretending to be it.
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self, romPath)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// summary cuts text down to its first line, and at most max characters of it.
+func summary(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if newline := strings.IndexAny(text, "\r\n"); newline >= 0 {
+		text = text[:newline]
+	}
+	if runes := []rune(text); len(runes) > max {
+		text = strings.TrimSpace(string(runes[:max-3])) + "..."
+	}
+	return text
+}
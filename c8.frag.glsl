@@ -6,8 +6,32 @@ out vec4 FragColor;
 in vec2 TexCoord;
 
 uniform sampler2D texture1;
+// paletted is 1 while drawing the Chip8's screen, whose texture only says which
+// color each pixel is -- 0, 1/3, 2/3 or 1 for background, foreground, plane2 and
+// both -- and 0 while drawing the overlay, whose texture has colors of its own.
+uniform int paletted;
+uniform vec3 foreground;
+uniform vec3 background;
+uniform vec3 plane2;
+uniform vec3 both;
 
 void main()
 {
-	FragColor = texture(texture1, TexCoord);
+	vec4 texel = texture(texture1, TexCoord);
+	if (paletted == 1) {
+		// in between the colors is where the texture's been shrunk and pixels
+		// blended together; blend the colors the same way.
+		float color = texel.r * 3.0;
+		vec3 rgb;
+		if (color < 1.0) {
+			rgb = mix(background, foreground, color);
+		} else if (color < 2.0) {
+			rgb = mix(foreground, plane2, color - 1.0);
+		} else {
+			rgb = mix(plane2, both, color - 2.0);
+		}
+		FragColor = vec4(rgb, 1.0);
+	} else {
+		FragColor = texel;
+	}
 }
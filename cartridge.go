@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpingram/chip8/metadata"
+	"github.com/mpingram/chip8/octo"
+)
+
+// isCartridge returns true if the ROM at path is really an Octo cartridge (see the
+// octo package): a GIF, with the game's source in it.
+func isCartridge(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gif")
+}
+
+// readCartridge reads the Octo cartridge at path.
+func readCartridge(path string) (*octo.Cartridge, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cart, err := octo.ReadCartridge(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cart, nil
+}
+
+// cartridgeROM reads the Octo cartridge at path, and compiles the game in it.
+func cartridgeROM(path string) ([]byte, error) {
+	cart, err := readCartridge(path)
+	if err != nil {
+		return nil, err
+	}
+	rom, err := cart.ROM()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rom, nil
+}
+
+// cartridgeMetadata returns what a cartridge's options say about how to play it,
+// as if it were in a metadata file.
+func cartridgeMetadata(cart *octo.Cartridge) *metadata.Metadata {
+	quirks := cart.Options.Quirks()
+	return &metadata.Metadata{Speed: cart.Options.Speed(), Quirks: &quirks, Font: cart.Options.Font()}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// A command is one of the things you can ask chip8 to do other than play a game,
+// like `chip8 dump`. Its name is the first command line argument.
+type command struct {
+	// usage is a one-line summary, shown in the list of commands.
+	usage string
+	run   func(args []string) error
+	// play, for commands that are shortcuts for playing a game a particular way
+	// (instead of run), returns the flags and ROM to play it with, as if they'd
+	// been typed on the command line. If it returns none, there's nothing to play.
+	play func(args []string) ([]string, error)
+}
+
+var commands = map[string]command{}
+
+// runCommand runs the command named by the first command line argument,
+// and returns false if there isn't one, or if the command wants a game played
+// (in which case it's rewritten os.Args to say which).
+func runCommand() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		return false
+	}
+	if cmd.play != nil {
+		args, err := cmd.play(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "chip8 %s: %v\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		if len(args) == 0 {
+			return true
+		}
+		os.Args = append(os.Args[:1], args...)
+		return false
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "chip8 %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// printCommands lists the commands, for the usage message.
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  chip8 %s\n    \t%s\n", name, commands[name].usage)
+	}
+}
+
+// parseAddress parses a memory address, in hex with a 0x prefix or in decimal.
+// Whether the Chip8 has that much memory is for whatever uses the address to say.
+func parseAddress(s string) (uint16, error) {
+	addr, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(addr), nil
+}
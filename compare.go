@@ -0,0 +1,123 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/compare"
+	"github.com/mpingram/chip8/cpu"
+)
+
+func init() {
+	commands["compare"] = command{
+		usage: "play a ROM with two sets of quirks at once, side by side, and see where they part ways",
+		run:   compareCommand,
+	}
+}
+
+// compareCommand plays the same ROM twice over, side by side, with -a's quirks on
+// the left and -b's on the right, in lockstep and with the same keys (see the
+// compare package). The moment the two screens stop agreeing, it says which frame
+// that was and (unless -stop=false) stops them both there, so you can see which
+// one's gone wrong while it's still the only thing that has.
+func compareCommand(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	quirksA := flags.String("a", "", "the quirks for the left-hand screen, as a comma-separated `list`: "+strings.Join(cpu.QuirkNames(), ", "))
+	quirksB := flags.String("b", "", "the quirks for the right-hand screen, as a comma-separated `list`")
+	speed := flags.Int("speed", cpu.DefaultSpeed, "number of instructions to execute per second")
+	fontName := flags.String("font", cpu.DefaultFont.Name, "draw numbers with this interpreter's `font`: "+strings.Join(cpu.FontNames(), ", "))
+	paletteName := flags.String("palette", palettes[0].name, "draw the screens in this `palette`: "+strings.Join(paletteNames(), ", "))
+	stop := flags.Bool("stop", true, "pause when the screens first differ")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 compare [flags] -a quirks -b quirks rom.ch8\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	a, err := cpu.ParseQuirks(*quirksA)
+	if err != nil {
+		return err
+	}
+	b, err := cpu.ParseQuirks(*quirksB)
+	if err != nil {
+		return err
+	}
+	if a == b {
+		return fmt.Errorf("-a and -b are the same quirks (%s); there'd be nothing to compare", a)
+	}
+	font, err := cpu.FontByName(*fontName)
+	if err != nil {
+		return err
+	}
+	p, ok := paletteByName(*paletteName)
+	if !ok {
+		return fmt.Errorf("there's no palette called %q", *paletteName)
+	}
+	romPath := flags.Arg(0)
+	rom, err := readROM(romPath)
+	if err != nil {
+		return err
+	}
+	pair, err := compare.New(rom, a, b, *speed, cpu.WithFont(font))
+	if err != nil {
+		return err
+	}
+
+	window := openWindow(false, 0)
+	defer glfw.Terminate()
+	window.SetSize(1280, 320)
+	window.SetTitle(fmt.Sprintf("%s: %s | %s", romLabel(romPath), a, b))
+	renderer := NewOpenGLRenderer(window)
+	renderer.SetPalette(p)
+	input := NewGLFWKeyboardInput(window)
+	osd := new(onScreenDisplay)
+
+	// the Chip8s only run when the loop below advances them, so pausing is just not doing that.
+	paused := false
+	input.Describe(keyName(pauseKey), "pause and resume")
+	input.OnHotkey(pauseKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			paused = !paused
+		}
+	})
+	bindHelpKey(input, osd)
+	osd.showToast(fmt.Sprintf("left: %s, right: %s", a, b))
+
+	screens := make([]cpu.ColorFrame, 2)
+	refresh := time.NewTicker(time.Second / cpu.FramesPerSecond)
+	defer refresh.Stop()
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+
+		<-refresh.C
+		if !paused {
+			_, diverged := pair.Diverged()
+			screens[0], screens[1] = pair.Advance(input.Held())
+			if frame, ok := pair.Diverged(); ok && !diverged {
+				log.Printf("the screens differ from frame %d on", frame)
+				osd.setIndicator("diverged", fmt.Sprintf("different from frame %d", frame))
+				paused = *stop
+			}
+		}
+		// not showPaused: dimming the screens would hide the very thing we stopped to look at.
+		if paused {
+			osd.setIndicator("paused", "paused - "+keyName(pauseKey)+" to carry on")
+		} else {
+			osd.setIndicator("paused", "")
+		}
+		renderer.SetOverlay(osd.lines(time.Now()))
+		renderer.RenderTiles(screens, -1)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package compare runs one ROM on two Chip8s at once, in lockstep, with different
+// quirks, and finds the first frame where their screens stop agreeing -- which is
+// usually the quickest way there is to answer "which quirks does this ROM want?":
+// run it both ways, and see which way it goes wrong, and when.
+//
+// The two Chip8s get the same keys at the same frames and the same random numbers,
+// so anything that's different between them is down to the quirks.
+package compare
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Pair is the two Chip8s, A and B. Don't Resume them: Advance runs them, a frame
+// at a time, so they can't get out of step.
+type Pair struct {
+	A, B   *cpu.Chip8
+	keys   *keyboard
+	frames uint64
+	// diverged is the frame the screens first differed on, if diverging is true.
+	diverged  uint64
+	diverging bool
+}
+
+// New loads rom into two Chip8s, one with quirks a and the other with quirks b,
+// both running at speed (which Advance needs to be the same for both) and set up
+// with options, which shouldn't include quirks.
+func New(rom []byte, a, b cpu.Quirks, speed int, options ...cpu.Option) (*Pair, error) {
+	p := &Pair{keys: new(keyboard)}
+	seed := time.Now().UnixNano()
+	for _, c := range []struct {
+		c8     **cpu.Chip8
+		quirks cpu.Quirks
+	}{{&p.A, a}, {&p.B, b}} {
+		c8 := cpu.NewChip8(p.keys, silentSpeaker{}, append(options, cpu.WithQuirks(c.quirks))...)
+		// nobody's going to read the log, and it would grow forever.
+		c8.SetLogLevel(cpu.LogNone)
+		c8.SetSpeed(speed)
+		c8.SetSeed(seed)
+		if err := c8.Load(rom); err != nil {
+			return nil, err
+		}
+		*c.c8 = c8
+	}
+	return p, nil
+}
+
+// Advance runs both Chip8s for a frame, holding down keys (a bitmask, bit n for key
+// n), and returns what's on their screens at the end of it.
+func (p *Pair) Advance(keys uint16) (a, b cpu.ColorFrame) {
+	atomic.StoreUint32(&p.keys.held, uint32(keys))
+	for _, c8 := range []*cpu.Chip8{p.A, p.B} {
+		for start := c8.FrameCount(); c8.FrameCount() == start; {
+			c8.FrameStep()
+		}
+	}
+	p.frames++
+	a, b = p.A.ColorFrame(), p.B.ColorFrame()
+	if a != b && !p.diverging {
+		p.diverged, p.diverging = p.frames, true
+	}
+	return a, b
+}
+
+// Frames returns how many frames Advance has run.
+func (p *Pair) Frames() uint64 {
+	return p.frames
+}
+
+// Diverged returns the first frame, counting from 1, at the end of which the two
+// screens were different, and false if they've been the same all along.
+func (p *Pair) Diverged() (frame uint64, ok bool) {
+	return p.diverged, p.diverging
+}
+
+// keyboard is the one keyboard both Chip8s share, so they always see the same keys.
+type keyboard struct {
+	// held is a bitmask of the keys down, bit n for key n. Only touch it through sync/atomic.
+	held uint32
+}
+
+// Poll returns the lowest key held down.
+func (k *keyboard) Poll() cpu.KeyCode {
+	held := atomic.LoadUint32(&k.held)
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if held&(1<<key) != 0 {
+			return key
+		}
+	}
+	return cpu.KeyNone
+}
+
+// silentSpeaker is a Speaker that doesn't make any noise: nobody's listening to a comparison.
+type silentSpeaker struct{}
+
+func (silentSpeaker) StartSound() {}
+func (silentSpeaker) StopSound()  {}
@@ -0,0 +1,61 @@
+package compare_test
+
+import (
+	"testing"
+
+	"github.com/mpingram/chip8/compare"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// shiftROM draws V0 >> 1 as a digit, where V0 is 6 and V1 is 3, and waits for
+// key 5 to draw it again. With the shift-vy quirk, it's V1 that gets shifted.
+var shiftROM = []byte{
+	0x60, 0x06, // LD V0 06
+	0x61, 0x03, // LD V1 03
+	0x62, 0x05, // LD V2 05
+	0x80, 0x16, // SHR V0 V1
+	0xf0, 0x29, // LD F V0
+	0xd3, 0x35, // DRW V3 V3 5
+	0xe2, 0xa1, // SKNP V2
+	0x12, 0x0a, // JP 20a
+	0x12, 0x0c, // JP 20c
+}
+
+// Pair.Advance / Diverged
+// should run the two Chip8s in step, frame for frame, with the same keys
+// should say which frame the screens first differed on, if they ever did
+func TestPair(t *testing.T) {
+	same, err := compare.New(shiftROM, cpu.Quirks{}, cpu.Quirks{}, cpu.DefaultSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		same.Advance(1 << 5)
+	}
+	if frame, ok := same.Diverged(); ok {
+		t.Errorf("the same quirks diverged at frame %d", frame)
+	}
+	if same.Frames() != 10 || same.A.FrameCount() != 10 || same.B.FrameCount() != 10 {
+		t.Errorf("ran %d frames, and the Chip8s are at %d and %d; want 10 all round", same.Frames(), same.A.FrameCount(), same.B.FrameCount())
+	}
+
+	different, err := compare.New(shiftROM, cpu.Quirks{}, cpu.Quirks{ShiftVy: true}, cpu.DefaultSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first uint64
+	for frame := uint64(1); frame <= 10; frame++ {
+		if a, b := different.Advance(0); a != b && first == 0 {
+			first = frame
+		}
+	}
+	if first == 0 {
+		t.Fatalf("3 and 1 look the same")
+	}
+	if frame, ok := different.Diverged(); !ok || frame != first {
+		t.Errorf("diverged at frame %d (%v), want %d", frame, ok, first)
+	}
+	if a, b := different.A.Snapshot(), different.B.Snapshot(); a.V[0] != 3 || b.V[0] != 1 {
+		t.Errorf("V0 is %d and %d, want 3 and 1", a.V[0], b.V[0])
+	}
+}
@@ -0,0 +1,229 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// defaultTap is how long a key tapped over the API stays down if the caller doesn't say.
+// Games poll the keyboard whenever they feel like it, so a tap has to last long enough
+// for them to notice -- a few frames is plenty.
+const defaultTap = 100 * time.Millisecond
+
+// status is what GET /status returns.
+type status struct {
+	Running bool   `json:"running"`
+	Speed   int    `json:"speed"`
+	Turbo   bool   `json:"turbo"`
+	Frame   uint64 `json:"frame"`
+	// Spectators is how many people are watching the broadcast, if there is one.
+	Spectators int `json:"spectators"`
+	// Keypads is how many keypads POST /key can press keys on: 0, 1 or 2.
+	Keypads int `json:"keypads"`
+}
+
+// keyEvent is what POST /key takes.
+type keyEvent struct {
+	// Key is the hex digit on the Chip8 keypad, "0" through "f".
+	Key string `json:"key"`
+	// Action is "press", "release" or "tap".
+	Action string `json:"action"`
+	// Hold is how long a tap lasts, like "250ms". It's optional.
+	Hold string `json:"hold,omitempty"`
+	// Keypad is which keypad to press it on, 1 or 2 (see HandleSecondKeypad).
+	// It's optional, and 1 if it's left out.
+	Keypad int `json:"keypad,omitempty"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	st := status{
+		Running: s.c8.IsRunning(),
+		Speed:   s.c8.Speed(),
+		Turbo:   s.c8.IsTurbo(),
+		Frame:   s.c8.FrameCount(),
+	}
+	if s.spectators != nil {
+		st.Spectators = s.spectators.stream.watching()
+	}
+	if s.keypad != nil {
+		st.Keypads++
+	}
+	if s.keypad2 != nil {
+		st.Keypads++
+	}
+	writeJSON(w, st)
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, s.c8.Snapshot())
+}
+
+// handleROM loads the ROM in the request body. If the Chip8 was running,
+// it starts running the new ROM right away.
+func (s *Server) handleROM(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPut, http.MethodPost) {
+		return
+	}
+	// the largest ROM is the one that fills memory after the interpreter's 512 bytes.
+	maxROMSize := int64(s.c8.MemorySize() - 0x200)
+	rom, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxROMSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ROMs can be at most %d bytes", maxROMSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	wasRunning := s.c8.IsRunning()
+	s.c8.Halt()
+	s.c8.Wait()
+	if err := s.c8.Load(rom); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wasRunning {
+		go s.c8.Resume()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	s.c8.Halt()
+	s.c8.Wait()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	go s.c8.Resume()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStep pauses the Chip8, executes one instruction, and returns the snapshot afterwards.
+func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	s.c8.Halt()
+	s.c8.Wait()
+	s.c8.StepN(1)
+	writeJSON(w, s.c8.Snapshot())
+}
+
+// handleFrameStep pauses the Chip8, runs it to the end of the frame, and returns
+// the snapshot afterwards.
+func (s *Server) handleFrameStep(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	s.c8.FrameStep()
+	writeJSON(w, s.c8.Snapshot())
+}
+
+// handleSpeed reads or sets the speed, in instructions per second.
+// PUT takes the speed as JSON, like {"speed": 700}.
+func (s *Server) handleSpeed(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if r.Method == http.MethodPut {
+		var body struct {
+			Speed int `json:"speed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Speed < 1 {
+			http.Error(w, `expected a body like {"speed": 700}`, http.StatusBadRequest)
+			return
+		}
+		s.c8.SetSpeed(body.Speed)
+	}
+	writeJSON(w, map[string]int{"speed": s.c8.Speed()})
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	var event keyEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	keypad := s.keypad
+	switch event.Keypad {
+	case 0, 1:
+	case 2:
+		keypad = s.keypad2
+	default:
+		http.Error(w, fmt.Sprintf("there's no keypad %d; it's 1 or 2", event.Keypad), http.StatusBadRequest)
+		return
+	}
+	if keypad == nil {
+		http.Error(w, "this Chip8 doesn't take remote key presses on that keypad", http.StatusNotImplemented)
+		return
+	}
+	n, err := strconv.ParseUint(event.Key, 16, 4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("key %q is not a hex digit", event.Key), http.StatusBadRequest)
+		return
+	}
+	key := cpu.KeyCode(n)
+
+	switch event.Action {
+	case "press":
+		keypad.Press(key)
+	case "release":
+		keypad.Release(key)
+	case "tap":
+		hold := defaultTap
+		if event.Hold != "" {
+			if hold, err = time.ParseDuration(event.Hold); err != nil || hold <= 0 {
+				http.Error(w, fmt.Sprintf("hold %q is not a duration", event.Hold), http.StatusBadRequest)
+				return
+			}
+		}
+		keypad.Tap(key, hold)
+	default:
+		http.Error(w, fmt.Sprintf("action %q should be press, release or tap", event.Action), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowMethods checks that the request uses one of methods, and answers it
+// with 405 Method Not Allowed if it doesn't.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	allow := ""
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+		if allow != "" {
+			allow += ", "
+		}
+		allow += m
+	}
+	w.Header().Set("Allow", allow)
+	http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
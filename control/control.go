@@ -0,0 +1,130 @@
+// Package control lets you drive a running Chip8 over HTTP: watch its screen, load
+// ROMs, pause it, step it, press its keys, download and upload its state, and so on.
+// It's how a session can be moved between a server instance and a local instance,
+// or how tools written in other languages can poke at the emulator.
+package control
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// maxStateSize is the largest savestate we'll accept. Real ones are a couple of kilobytes.
+const maxStateSize = 1 << 20
+
+// Server is an http.Handler that serves the control API for one Chip8.
+//
+//	GET  /           a page that shows the screen, live
+//	GET  /screen     a websocket that streams the screen (see PublishFrame)
+//	GET  /status     whether it's running, its speed, and the frame count, as JSON
+//	GET  /snapshot   the Chip8State, as JSON
+//	PUT  /rom        load the ROM in the body (and keep running, if it was)
+//	POST /pause      halt the Chip8
+//	POST /resume     set it running again
+//	POST /step       halt, execute one instruction, and return the snapshot
+//	POST /framestep  halt, execute the rest of the frame, and return the snapshot
+//	GET  /speed      the speed, as {"speed": 700}
+//	PUT  /speed      set the speed, given as {"speed": 700}
+//	POST /key        press, release or tap a key: {"key": "5", "action": "tap", "hold": "100ms"},
+//	                 with "keypad": 2 for the second keypad, if there is one
+//	GET  /state      download the current savestate
+//	PUT  /state      restore the uploaded savestate
+//
+// HandleCrowd, HandleSerial, HandleSecondKeypad and Broadcast add a few more.
+//
+// Everything but the viewer page needs the token, either in an
+// "Authorization: Bearer <token>" header or, for browsers opening a websocket
+// (which can't set headers), in a ?token= query parameter.
+type Server struct {
+	c8     *cpu.Chip8
+	keypad *Keypad
+	// keypad2 is the second keypad, if the Chip8 has one (see HandleSecondKeypad).
+	keypad2 *Keypad
+	token   string
+	mux     *http.ServeMux
+	screen  *screenStream
+	// spectators is the read-only broadcast, if there is one (see Broadcast).
+	spectators *spectators
+}
+
+// NewServer returns a Server controlling c8, which only does as it's told by
+// clients that know token. An empty token lets anyone in, which is only a good idea
+// in tests. Key presses go to keypad, which should be c8's keyboard; if it's nil,
+// the Chip8 can't be typed on remotely.
+func NewServer(c8 *cpu.Chip8, keypad *Keypad, token string) *Server {
+	s := &Server{c8: c8, keypad: keypad, token: token, mux: http.NewServeMux(), screen: newScreenStream()}
+	s.mux.HandleFunc("/", s.handleViewer)
+	s.mux.HandleFunc("/screen", s.handleScreen)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/snapshot", s.handleSnapshot)
+	s.mux.HandleFunc("/rom", s.handleROM)
+	s.mux.HandleFunc("/pause", s.handlePause)
+	s.mux.HandleFunc("/resume", s.handleResume)
+	s.mux.HandleFunc("/step", s.handleStep)
+	s.mux.HandleFunc("/framestep", s.handleFrameStep)
+	s.mux.HandleFunc("/speed", s.handleSpeed)
+	s.mux.HandleFunc("/key", s.handleKey)
+	s.mux.HandleFunc("/state", s.handleState)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the viewer page is just HTML; it's what it connects to that needs the token.
+	// Spectators don't need it either: all they can do is watch.
+	if r.URL.Path != "/" && !s.spectators.public(r.URL.Path) && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chip8"`)
+		http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return HasToken(r, s.token)
+}
+
+// HasToken reports whether r comes with token, the way Server wants it: in an
+// "Authorization: Bearer" header or a ?token= query parameter. Every request has the empty token.
+func HasToken(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	given := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		given = strings.TrimPrefix(auth, "Bearer ")
+	}
+	// compare in constant time, so the token can't be guessed a byte at a time by timing us.
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var state bytes.Buffer
+		if err := s.c8.SaveState(&state); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="chip8.state"`)
+		w.Write(state.Bytes())
+
+	case http.MethodPut, http.MethodPost:
+		err := s.c8.LoadState(http.MaxBytesReader(w, r.Body, maxStateSize))
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case cpu.ErrWrongROM:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+
+	default:
+		allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodPost)
+	}
+}
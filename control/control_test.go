@@ -0,0 +1,321 @@
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+func newTestChip8(t *testing.T, program []byte) *cpu.Chip8 {
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// GET /state, PUT /state
+// should move a session from one Chip8 to another running the same ROM
+// should refuse a state from a different ROM
+func TestStateExportImport(t *testing.T) {
+	program := []byte{0x60, 0x2a} // LD V0 2a
+	from := newTestChip8(t, program)
+	from.Step()
+	fromServer := httptest.NewServer(control.NewServer(from, nil, ""))
+	defer fromServer.Close()
+
+	resp, err := http.Get(fromServer.URL + "/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /state: %s", resp.Status)
+	}
+
+	to := newTestChip8(t, program)
+	toServer := httptest.NewServer(control.NewServer(to, nil, ""))
+	defer toServer.Close()
+	if status := put(t, toServer.URL+"/state", state); status != http.StatusNoContent {
+		t.Fatalf("PUT /state: got status %d, want %d", status, http.StatusNoContent)
+	}
+	if got, want := to.Snapshot().V[0], from.Snapshot().V[0]; got != want {
+		t.Errorf("after PUT /state, V0 = %02x, want %02x", got, want)
+	}
+
+	other := newTestChip8(t, []byte{0x00, 0xe0})
+	otherServer := httptest.NewServer(control.NewServer(other, nil, ""))
+	defer otherServer.Close()
+	if status := put(t, otherServer.URL+"/state", state); status != http.StatusConflict {
+		t.Errorf("PUT /state from another ROM: got status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func put(t *testing.T, url string, body []byte) int {
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// GET /screen
+// should complete a websocket handshake and send the current frame as a binary message
+func TestScreenStream(t *testing.T) {
+	server := control.NewServer(newTestChip8(t, []byte{0x00, 0xe0}), nil, "")
+	var frame [256]byte
+	frame[0] = 0x80 // top-left pixel
+	server.PublishFrame(frame)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	conn, r, resp := dialWebsocket(t, ts, "/screen")
+	defer conn.Close()
+	// the example from RFC 6455
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("Sec-WebSocket-Accept: got %q, want %q", got, want)
+	}
+
+	if got := readFrame(t, r); got != frame {
+		t.Errorf("streamed frame doesn't match the published frame")
+	}
+}
+
+// dialWebsocket opens a websocket to path on ts, by hand.
+func dialWebsocket(t *testing.T, ts *httptest.Server, path string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: chip8\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("%s handshake: got status %s", path, resp.Status)
+	}
+	return conn, r, resp
+}
+
+// readFrame reads one frame off a screen websocket.
+func readFrame(t *testing.T, r *bufio.Reader) [256]byte {
+	// a 256-byte binary message: FIN|binary, 16-bit length, payload
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x82, 126, 0x01, 0x00}; !bytes.Equal(header, want) {
+		t.Fatalf("message header: got % x, want % x", header, want)
+	}
+	var frame [256]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// Broadcast
+// should let spectators watch without the token
+// should hold frames back by the delay
+// should count the spectators
+func TestBroadcast(t *testing.T) {
+	server := control.NewServer(newTestChip8(t, []byte{0x00, 0xe0}), nil, "sesame")
+	server.Broadcast(100 * time.Millisecond)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	conn, r, _ := dialWebsocket(t, ts, "/spectate")
+	defer conn.Close()
+	if frame := readFrame(t, r); frame != ([256]byte{}) {
+		t.Errorf("spectators should start off with a blank screen")
+	}
+
+	var frame [256]byte
+	frame[0] = 0x80
+	published := time.Now()
+	server.PublishFrame(frame)
+	if got := readFrame(t, r); got != frame {
+		t.Errorf("the broadcast frame doesn't match the published frame")
+	}
+	if waited := time.Since(published); waited < 100*time.Millisecond {
+		t.Errorf("the broadcast frame arrived after %v, before the 100ms delay was up", waited)
+	}
+
+	resp, err := http.Get(ts.URL + "/spectators")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count struct{ Spectators int }
+	json.NewDecoder(resp.Body).Decode(&count)
+	resp.Body.Close()
+	if count.Spectators != 1 {
+		t.Errorf("GET /spectators = %d, want 1", count.Spectators)
+	}
+
+	resp, err = http.Get(ts.URL + "/snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("a spectator could GET /snapshot without the token: got status %s", resp.Status)
+	}
+}
+
+// the REST API
+// should turn away requests without the token
+// should step the Chip8, an instruction or a frame, and report its state
+// should pass key presses on to the keypad, key 0 included
+func TestAPI(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x2a, // LD V0 2a
+	})
+	keypad := control.NewKeypad(nil)
+	ts := httptest.NewServer(control.NewServer(c, keypad, "sesame"))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/step", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /step without a token: got status %s, want 401", resp.Status)
+	}
+
+	resp = request(t, http.MethodPost, ts.URL+"/step", "sesame", "")
+	var state cpu.Chip8State
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if state.V[0] != 0x2a || state.PC != 0x202 {
+		t.Errorf("after POST /step: PC=%03x V0=%02x, want PC=202 V0=2a", state.PC, state.V[0])
+	}
+
+	// the program's over, so the rest of the frame is just the timers.
+	resp = request(t, http.MethodPost, ts.URL+"/framestep", "sesame", "")
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || state.PC != 0x202 {
+		t.Errorf("after POST /framestep: got status %s and PC=%03x, want 200 and PC=202", resp.Status, state.PC)
+	}
+
+	resp = request(t, http.MethodPost, ts.URL+"/key", "sesame", `{"key": "a", "action": "press"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("POST /key: got status %s", resp.Status)
+	}
+	if got := keypad.Poll(); got != cpu.KeyA {
+		t.Errorf("after pressing A over the API, keypad.Poll() = %x, want a", got)
+	}
+	resp = request(t, http.MethodPost, ts.URL+"/key", "sesame", `{"key": "0", "action": "press"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("POST /key for key 0: got status %s", resp.Status)
+	}
+	if got := keypad.Poll(); got != cpu.Key0 {
+		t.Errorf("after pressing 0 over the API, keypad.Poll() = %x, want 0", got)
+	}
+}
+
+// Server.HandleSecondKeypad
+// should press keys on the second keypad when the request says "keypad": 2
+func TestSecondKeypad(t *testing.T) {
+	c := newTestChip8(t, nil)
+	keypad, keypad2 := control.NewKeypad(nil), control.NewKeypad(nil)
+	server := control.NewServer(c, keypad, "sesame")
+	server.HandleSecondKeypad(keypad2)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	resp := request(t, http.MethodPost, ts.URL+"/key", "sesame", `{"key": "7", "action": "press", "keypad": 2}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("POST /key on keypad 2: got status %s", resp.Status)
+	}
+	if got, got2 := keypad.Poll(), keypad2.Poll(); got != cpu.KeyNone || got2 != cpu.Key7 {
+		t.Errorf("after pressing 7 on keypad 2, the keypads have %x and %x, want nothing and 7", got, got2)
+	}
+	resp = request(t, http.MethodPost, ts.URL+"/key", "sesame", `{"key": "7", "action": "press", "keypad": 3}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /key on keypad 3: got status %s, want 400", resp.Status)
+	}
+}
+
+func request(t *testing.T, method, url, token, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// Crowd
+// should press the key with the most votes, one vote per voter
+// should take votes for key 0
+func TestCrowd(t *testing.T) {
+	keypad := control.NewKeypad(nil)
+	crowd := control.NewCrowd(keypad, 20*time.Millisecond)
+	crowd.Alias("up", cpu.Key1)
+	crowd.Vote("alice", "up")
+	crowd.Vote("bob", "4")
+	crowd.Vote("carol", "1")
+	crowd.Vote("dave", "4")
+	if err := crowd.Vote("dave", "4"); err == nil {
+		t.Errorf("dave voted twice in one window")
+	}
+	crowd.Vote("erin", "UP")
+	if err := crowd.Vote("frank", "0"); err != nil {
+		t.Errorf("voting for key 0: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go crowd.Run(stop)
+	deadline := time.Now().Add(time.Second)
+	for keypad.Poll() == cpu.KeyNone && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := keypad.Poll(); got != cpu.Key1 {
+		t.Errorf("the crowd voted 1 (by alias) over 4, but the keypad pressed %x", got)
+	}
+}
@@ -0,0 +1,190 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// Crowd lets a crowd of people play a game together, "Twitch plays" style: everyone
+// votes for a key, and at the end of each decision window, the key with the most
+// votes gets pressed. Votes come in over HTTP, either directly (POST /vote) or as
+// chat messages passed along by a chat bot (POST /chat); see Server.HandleCrowd.
+type Crowd struct {
+	keypad *Keypad
+	window time.Duration
+
+	mu sync.Mutex
+	// votes counts this window's votes for each key; voters is who has voted
+	// this window, so nobody gets to vote twice.
+	votes   [16]int
+	voters  map[string]bool
+	order   []cpu.KeyCode
+	aliases map[string]cpu.KeyCode
+	// last is the key that won the last window, for anyone curious.
+	last cpu.KeyCode
+}
+
+// NewCrowd returns a Crowd that presses keys on keypad, deciding which one every window.
+// Call Run to start counting.
+func NewCrowd(keypad *Keypad, window time.Duration) *Crowd {
+	return &Crowd{
+		keypad:  keypad,
+		window:  window,
+		voters:  make(map[string]bool),
+		aliases: make(map[string]cpu.KeyCode),
+		last:    cpu.KeyNone,
+	}
+}
+
+// Alias lets people vote for key by name, like "up" for the key that moves a paddle up.
+func (c *Crowd) Alias(name string, key cpu.KeyCode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aliases[strings.ToLower(name)] = key
+}
+
+// Run counts the votes at the end of every window and presses the winning key
+// for most of the next window, until stop is closed.
+func (c *Crowd) Run(stop <-chan struct{}) {
+	tick := time.NewTicker(c.window)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		if key, ok := c.decide(); ok {
+			// let go a little before the next decision, so the same key winning
+			// twice in a row reads as two presses instead of one long one.
+			c.keypad.Tap(key, c.window*3/4)
+		}
+	}
+}
+
+// decide returns the winning key of the window that just ended, and starts a new window.
+// Ties go to whichever key got its first vote first. If nobody voted, there's no winner.
+func (c *Crowd) decide() (cpu.KeyCode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.order) == 0 {
+		return cpu.KeyNone, false
+	}
+	winner := c.order[0]
+	for _, key := range c.order[1:] {
+		if c.votes[key] > c.votes[winner] {
+			winner = key
+		}
+	}
+	c.votes = [16]int{}
+	c.voters = make(map[string]bool)
+	c.order = c.order[:0]
+	c.last = winner
+	return winner, true
+}
+
+// Vote records voter's vote for a key, given as a hex digit ("5") or an alias ("up").
+// It returns an error if the choice isn't a key or voter has already voted this window.
+// An empty voter is anonymous, and can vote as often as they like.
+func (c *Crowd) Vote(voter, choice string) error {
+	choice = strings.ToLower(strings.TrimSpace(choice))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.aliases[choice]
+	if !ok {
+		n, err := strconv.ParseUint(choice, 16, 4)
+		if err != nil {
+			return fmt.Errorf("%q isn't a key", choice)
+		}
+		key = cpu.KeyCode(n)
+	}
+	if voter != "" {
+		if c.voters[voter] {
+			return fmt.Errorf("%s has already voted", voter)
+		}
+		c.voters[voter] = true
+	}
+	if c.votes[key] == 0 {
+		c.order = append(c.order, key)
+	}
+	c.votes[key]++
+	return nil
+}
+
+// tally is what GET /votes returns.
+type tally struct {
+	Votes map[string]int `json:"votes"`
+	Last  string         `json:"last,omitempty"`
+}
+
+func (c *Crowd) tally() tally {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := tally{Votes: make(map[string]int)}
+	for _, key := range c.order {
+		t.Votes[fmt.Sprintf("%x", key)] = c.votes[key]
+	}
+	if c.last != cpu.KeyNone {
+		t.Last = fmt.Sprintf("%x", c.last)
+	}
+	return t
+}
+
+// HandleCrowd adds the crowd-control endpoints to the server:
+//
+//	POST /vote    vote for a key: {"voter": "alice", "key": "5"}
+//	POST /chat    a chat message from a chat bot: {"user": "alice", "message": "!up"}
+//	GET  /votes   the votes so far this window, and last window's winner
+//
+// Chat messages are votes if they're a key or an alias, optionally starting with
+// a "!"; anything else is just chat, and is ignored.
+func (s *Server) HandleCrowd(crowd *Crowd) {
+	s.mux.HandleFunc("/vote", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		var vote struct {
+			Voter string `json:"voter"`
+			Key   string `json:"key"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&vote); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := crowd.Vote(vote.Voter, vote.Key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.mux.HandleFunc("/chat", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		var chat struct {
+			User    string `json:"user"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&chat); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the chat bot doesn't care whether it was a vote; neither should it
+		// have to. Anything that isn't a vote is just someone talking.
+		crowd.Vote(chat.User, strings.TrimPrefix(strings.TrimSpace(chat.Message), "!"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.mux.HandleFunc("/votes", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, crowd.tally())
+	})
+}
@@ -0,0 +1,114 @@
+package control
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// Keypad is a cpu.Keyboard that can be typed on over HTTP. It sits in front of
+// the local keyboard (if there is one), so keys pressed remotely and keys pressed
+// locally both reach the Chip8. Give it to cpu.NewChip8 in place of the local keyboard.
+type Keypad struct {
+	local cpu.Keyboard
+	// held is a bitmask of the keys pressed remotely, bit n for key n.
+	// The CPU polls it from its own goroutine, so only touch it through sync/atomic.
+	held uint32
+	// events gets every key pressed and released, remotely and locally. It's nil
+	// if the local keyboard doesn't have events (see cpu.KeyEventSource), since
+	// then the Chip8 has to poll it anyway.
+	events chan cpu.KeyEvent
+}
+
+// keyEventBuffer is how many key events can wait for the Chip8 to get to them;
+// it only reads them while it's running.
+const keyEventBuffer = 64
+
+// NewKeypad returns a Keypad in front of local, which may be nil.
+func NewKeypad(local cpu.Keyboard) *Keypad {
+	k := &Keypad{local: local}
+	var localEvents <-chan cpu.KeyEvent
+	if source, ok := local.(cpu.KeyEventSource); ok {
+		localEvents = source.KeyEvents()
+	}
+	if local == nil || localEvents != nil {
+		k.events = make(chan cpu.KeyEvent, keyEventBuffer)
+	}
+	if localEvents != nil {
+		go func() {
+			for event := range localEvents {
+				sendKeyEvent(k.events, event)
+			}
+		}()
+	}
+	return k
+}
+
+// KeyEvents returns the keys pressed and released, remotely and locally, or nil
+// if the local keyboard can only be polled.
+func (k *Keypad) KeyEvents() <-chan cpu.KeyEvent {
+	return k.events
+}
+
+// sendKeyEvent sends an event without waiting: if nobody has read the last
+// keyEventBuffer events, the oldest makes way.
+func sendKeyEvent(events chan cpu.KeyEvent, event cpu.KeyEvent) {
+	for {
+		select {
+		case events <- event:
+			return
+		default:
+		}
+		select {
+		case <-events:
+		default:
+		}
+	}
+}
+
+// Poll returns the lowest key pressed remotely, or if none are, whatever the local keyboard says.
+func (k *Keypad) Poll() cpu.KeyCode {
+	held := atomic.LoadUint32(&k.held)
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if held&(1<<key) != 0 {
+			return key
+		}
+	}
+	if k.local != nil {
+		return k.local.Poll()
+	}
+	return cpu.KeyNone
+}
+
+// Press holds a key down until Release is called.
+func (k *Keypad) Press(key cpu.KeyCode) {
+	k.set(key, true)
+}
+
+// Release lets go of a key.
+func (k *Keypad) Release(key cpu.KeyCode) {
+	k.set(key, false)
+}
+
+// Tap presses a key and lets go of it after d. It doesn't wait for that to happen.
+func (k *Keypad) Tap(key cpu.KeyCode, d time.Duration) {
+	k.Press(key)
+	time.AfterFunc(d, func() { k.Release(key) })
+}
+
+func (k *Keypad) set(key cpu.KeyCode, pressed bool) {
+	for {
+		old := atomic.LoadUint32(&k.held)
+		new := old &^ (1 << key)
+		if pressed {
+			new = old | 1<<key
+		}
+		if atomic.CompareAndSwapUint32(&k.held, old, new) {
+			break
+		}
+	}
+	if k.events != nil {
+		sendKeyEvent(k.events, cpu.KeyEvent{Code: key, Pressed: pressed, Timestamp: time.Now()})
+	}
+}
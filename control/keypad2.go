@@ -0,0 +1,9 @@
+package control
+
+// HandleSecondKeypad lets POST /key press keys on a second keypad, for two-player
+// games, with "keypad": 2 in the request. keypad should be the Chip8's second
+// keyboard (see cpu.Chip8.ConnectSecondKeyboard). Call it before the server
+// starts serving.
+func (s *Server) HandleSecondKeypad(keypad *Keypad) {
+	s.keypad2 = keypad
+}
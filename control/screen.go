@@ -0,0 +1,144 @@
+package control
+
+import (
+	_ "embed" // for the viewer
+	"net/http"
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// viewerHTML is a page that connects to /screen (or /spectate, when it's
+// served as /watch) and draws what it gets.
+//
+//go:embed viewer.html
+var viewerHTML []byte
+
+// screenStream fans frames out to everyone watching over a websocket.
+// Nobody gets to hold anyone else up: each viewer only ever has the latest frame
+// waiting for it, so a viewer on a slow connection skips frames instead of
+// falling further and further behind.
+type screenStream struct {
+	mu      sync.Mutex
+	frame   cpu.Frame
+	viewers map[*screenViewer]struct{}
+}
+
+type screenViewer struct {
+	mu    sync.Mutex
+	frame cpu.Frame
+	fresh chan struct{}
+}
+
+func newScreenStream() *screenStream {
+	return &screenStream{viewers: make(map[*screenViewer]struct{})}
+}
+
+func (s *screenStream) publish(frame cpu.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frame = frame
+	for v := range s.viewers {
+		v.offer(frame)
+	}
+}
+
+func (s *screenStream) join() *screenViewer {
+	v := &screenViewer{fresh: make(chan struct{}, 1)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewers[v] = struct{}{}
+	// start the newcomer off with whatever is on screen now.
+	v.offer(s.frame)
+	return v
+}
+
+func (s *screenStream) leave(v *screenViewer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.viewers, v)
+}
+
+// watching returns how many viewers there are.
+func (s *screenStream) watching() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.viewers)
+}
+
+// offer replaces the viewer's waiting frame with a newer one.
+func (v *screenViewer) offer(frame cpu.Frame) {
+	v.mu.Lock()
+	v.frame = frame
+	v.mu.Unlock()
+	select {
+	case v.fresh <- struct{}{}:
+	default:
+	}
+}
+
+func (v *screenViewer) take() cpu.Frame {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.frame
+}
+
+// PublishFrame sends a frame to everyone watching the screen. Whoever is displaying
+// the Chip8 calls it with each frame they get from Chip8.Frame, since only one
+// display can read frames from a Chip8.
+func (s *Server) PublishFrame(frame cpu.Frame) {
+	s.screen.publish(frame)
+	if s.spectators != nil {
+		s.spectators.publish(frame)
+	}
+}
+
+// handleScreen streams the screen over a websocket, one binary message per frame.
+// Each message is the 256 bytes of video memory, laid out like Chip8.Frame returns them.
+func (s *Server) handleScreen(w http.ResponseWriter, r *http.Request) {
+	streamScreen(w, r, s.screen)
+}
+
+// streamScreen streams stream to a new viewer over a websocket, until the viewer goes away.
+func streamScreen(w http.ResponseWriter, r *http.Request, stream *screenStream) {
+	ws, err := upgradeWebsocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	viewer := stream.join()
+	defer stream.leave(viewer)
+
+	// the viewer doesn't have anything to say, but we have to keep reading to
+	// answer pings and to find out when it goes away.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := ws.readMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-viewer.fresh:
+			frame := viewer.take()
+			if err := ws.writeMessage(opBinary, frame[:]); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+func (s *Server) handleViewer(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/watch" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerHTML)
+}
@@ -0,0 +1,23 @@
+package control
+
+import (
+	"net/http"
+
+	"github.com/mpingram/chip8/devices"
+)
+
+// HandleSerial adds an endpoint for reading what the ROM has written to its
+// serial port (see devices.Serial):
+//
+//	GET /serial   the last 4K or so of it, as plain text
+//
+// Call it before the server starts serving.
+func (s *Server) HandleSerial(serial *devices.Serial) {
+	s.mux.HandleFunc("/serial", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(serial.Output())
+	})
+}
@@ -0,0 +1,81 @@
+package control
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// spectators is a read-only broadcast of the screen, for people who want to watch
+// somebody play without being able to touch anything. Everyone watching shares the
+// same stream of frames -- nothing gets emulated twice -- and the stream can run a
+// little behind the game, so the player can't be helped (or heckled) in real time.
+type spectators struct {
+	delay  time.Duration
+	stream *screenStream
+	// pending is the delay line: frames waiting for their turn to be shown.
+	pending chan delayedFrame
+}
+
+type delayedFrame struct {
+	due   time.Time
+	frame cpu.Frame
+}
+
+// Broadcast lets anyone watch the Chip8 over HTTP, read-only and without the token,
+// running delay behind the player:
+//
+//	GET /watch        a page that shows the broadcast, live (or nearly)
+//	GET /spectate     a websocket that streams the broadcast, like /screen does
+//	GET /spectators   how many people are watching the broadcast, as {"spectators": 12}
+//
+// Call it before the server starts serving.
+func (s *Server) Broadcast(delay time.Duration) {
+	sp := &spectators{delay: delay, stream: newScreenStream()}
+	if delay > 0 {
+		// a frame a sixtieth of a second, for as long as the delay, and some room to spare.
+		sp.pending = make(chan delayedFrame, int(delay/(time.Second/cpu.FramesPerSecond))+cpu.FramesPerSecond)
+		go sp.run()
+	}
+	s.spectators = sp
+	s.mux.HandleFunc("/watch", s.handleViewer)
+	s.mux.HandleFunc("/spectate", func(w http.ResponseWriter, r *http.Request) {
+		streamScreen(w, r, sp.stream)
+	})
+	s.mux.HandleFunc("/spectators", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, struct {
+			Spectators int `json:"spectators"`
+		}{sp.stream.watching()})
+	})
+}
+
+// publish sends a frame to the spectators, once the delay is up.
+func (sp *spectators) publish(frame cpu.Frame) {
+	if sp.pending == nil {
+		sp.stream.publish(frame)
+		return
+	}
+	select {
+	case sp.pending <- delayedFrame{time.Now().Add(sp.delay), frame}:
+	default:
+		// frames are coming in faster than sixty a second; the spectators can
+		// do without this one.
+	}
+}
+
+// run shows each frame in the delay line when its time comes.
+func (sp *spectators) run() {
+	for f := range sp.pending {
+		time.Sleep(time.Until(f.due))
+		sp.stream.publish(f.frame)
+	}
+}
+
+// public reports whether path is part of the broadcast, which anyone can watch.
+func (sp *spectators) public(path string) bool {
+	return sp != nil && (path == "/watch" || path == "/spectate" || path == "/spectators")
+}
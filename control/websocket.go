@@ -0,0 +1,172 @@
+package control
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// This is just enough of the WebSocket protocol (RFC 6455) to push frames to a
+// browser: the handshake, unfragmented binary messages from us, and reading
+// (and mostly ignoring) whatever the browser sends back so we notice when it hangs up.
+
+// websocketGUID is the magic string every WebSocket server hashes into its handshake
+// reply, to prove it really speaks WebSocket and isn't some confused HTTP server.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// maxClientMessage is the largest message we'll read from a client. The viewer
+// never sends anything but control frames, which are at most 125 bytes.
+const maxClientMessage = 1 << 12
+
+var errNotWebsocket = errors.New("not a websocket handshake")
+
+// websocketConn is a server-side WebSocket connection.
+type websocketConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	// writeMu is held while writing a message, since pongs are written
+	// by whoever is reading while frames are written by whoever is sending them.
+	writeMu sync.Mutex
+}
+
+// upgradeWebsocket answers a WebSocket handshake and takes over the connection.
+// If the request isn't a handshake, it writes an error response and returns errNotWebsocket.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a websocket handshake", http.StatusBadRequest)
+		return nil, errNotWebsocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websockets aren't supported here", http.StatusInternalServerError)
+		return nil, errNotWebsocket
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &websocketConn{conn: conn, rw: rw}, nil
+}
+
+// headerContains reports whether any of the comma-separated values of header name is value,
+// ignoring case. (Firefox sends "Connection: keep-alive, Upgrade".)
+func headerContains(h http.Header, name, value string) bool {
+	for _, field := range h[http.CanonicalHeaderKey(name)] {
+		for _, v := range strings.Split(field, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeMessage sends one unfragmented message. Messages from the server aren't masked.
+func (ws *websocketConn) writeMessage(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	header := []byte{0x80 | opcode} // FIN, no extensions
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	ws.rw.Write(header)
+	ws.rw.Write(payload)
+	return ws.rw.Flush()
+}
+
+// readMessage reads the next message from the client, answering pings as it goes.
+// It returns io.EOF once the client closes the connection.
+func (ws *websocketConn) readMessage() (opcode byte, payload []byte, err error) {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+			return 0, nil, err
+		}
+		opcode = head[0] & 0x0F
+		masked := head[1]&0x80 != 0
+		length := uint64(head[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if length > maxClientMessage {
+			return 0, nil, errors.New("websocket message too large")
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		payload = make([]byte, length)
+		if _, err := io.ReadFull(ws.rw, payload); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case opClose:
+			ws.writeMessage(opClose, nil)
+			return 0, nil, io.EOF
+		case opPing:
+			if err := ws.writeMessage(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+		case opPong:
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+func (ws *websocketConn) Close() error {
+	return ws.conn.Close()
+}
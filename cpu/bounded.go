@@ -0,0 +1,143 @@
+package cpu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RunStats is what a StepN or RunFor got done, and how it ended.
+type RunStats struct {
+	// RunResult's Reason is StopHalted if it did everything it was asked to, and
+	// why it stopped short otherwise: the program finished, say, or a hook Broke.
+	RunResult
+	// Instructions is how many instructions were executed, and Frames how many
+	// frames ended while they were.
+	Instructions int
+	Frames       uint64
+}
+
+// StepN executes the next n instructions, one after another without waiting for
+// the clock, and leaves the Chip8 stopped. It's for running the Chip8 a bit at a
+// time from somewhere else's loop -- a game loop, a test, a browser's
+// requestAnimationFrame -- rather than letting it run off on its own with Resume.
+// Unlike calling Step n times, it doesn't work out what each instruction changed,
+// which is most of what a Step costs.
+//
+// Like Step, it stops the Chip8 first if it's running. It stops short if the
+// program finishes or something goes wrong, or if somebody Halts or Breaks it
+// (from a hook, say) -- the Chip8 counts as running until it's done, so they can.
+// The speaker's left as the program left it: a game loop calling StepN every
+// frame hasn't really stopped in between.
+func (c *Chip8) StepN(n int) RunStats {
+	if c.IsRunning() {
+		c.Halt()
+	}
+	// wait for a Resume that's going to get out of the way, and keep the next
+	// one out until we're done.
+	c.running.Lock()
+	defer c.running.Unlock()
+	return c.runN(n, nil, StopHalted)
+}
+
+// RunFor executes d's worth of instructions at the Chip8's speed -- a sixtieth of
+// a second's worth for each frame of a game loop, say -- without waiting for the
+// clock, like StepN. d is emulated time, so however long RunFor really takes, the
+// program sees d go by. The part of an instruction d doesn't cover is carried
+// over into the next RunFor, so sixty calls of a sixtieth of a second come out at
+// a second's worth of instructions between them.
+//
+// For running the Chip8 for a while in real time, pass ResumeContext a context
+// with a timeout instead.
+func (c *Chip8) RunFor(d time.Duration) RunStats {
+	if c.IsRunning() {
+		c.Halt()
+	}
+	c.running.Lock()
+	defer c.running.Unlock()
+	if d <= 0 {
+		return RunStats{RunResult: RunResult{Reason: StopHalted, PC: c.currentPC()}}
+	}
+	owed := c.runForOwed + int64(d)*int64(c.Speed())
+	c.runForOwed = owed % int64(time.Second)
+	stats := c.runN(int(owed/int64(time.Second)), nil, StopHalted)
+	if stats.Reason != StopHalted {
+		// it stopped short, and there's no making up the rest later.
+		c.runForOwed = 0
+	}
+	return stats
+}
+
+// RunUntilLimit is the most instructions RunUntil executes looking for its address,
+// in case the program never gets there: twenty-five minutes' worth at the 700 a
+// second most other Chip8s run at, and still only a moment's work for this one.
+const RunUntilLimit = 1 << 20
+
+// RunUntil executes instructions until the program counter gets to addr, and
+// stops there, before the instruction at addr: a debugger's "run to cursor",
+// without a breakpoint to remember to take out again. If PC's at addr already,
+// it runs until it comes back round. Like StepN it doesn't wait for the clock.
+//
+// When it gets there, the Reason is StopBreakpoint. If it's executed
+// RunUntilLimit instructions and still hasn't, it gives up where it is, and the
+// Reason is StopHalted -- or it stops short like StepN does, and says why.
+func (c *Chip8) RunUntil(addr uint16) RunStats {
+	if c.IsRunning() {
+		c.Halt()
+	}
+	c.running.Lock()
+	defer c.running.Unlock()
+	return c.runN(RunUntilLimit, func(step StepResult) bool { return step.NextPC == addr }, StopBreakpoint)
+}
+
+// FrameStep executes instructions until the end of the frame (see FrameCount):
+// the next sixtieth of a second of emulated time, when the timers count down and
+// the screen's shown. It's the natural step for watching the screen change and
+// for playing a frame at a time, tool-assisted-speedrun style -- keys held down
+// are read as they would be running at full speed. Like StepN it doesn't wait
+// for the clock, and it stops short if the program finishes or something goes
+// wrong. The Reason is StopHalted if it got to the end of the frame.
+func (c *Chip8) FrameStep() RunStats {
+	if c.IsRunning() {
+		c.Halt()
+	}
+	c.running.Lock()
+	defer c.running.Unlock()
+	start := c.FrameCount()
+	// every instruction moves the clock on, so a frame's over in speed/60 of
+	// them, give or take the one left over from the last frame.
+	return c.runN(c.Speed()/FramesPerSecond+1, func(StepResult) bool { return c.FrameCount() != start }, StopHalted)
+}
+
+// runN executes up to n instructions, for StepN and friends, or until done says
+// to stop after one, if there's a done, in which case it stops for doneReason.
+// c.running must be held.
+func (c *Chip8) runN(n int, done func(StepResult) bool, doneReason StopReason) RunStats {
+	atomic.StoreInt32(&c.stopReason, int32(StopHalted))
+	atomic.StoreInt32(&c.isStoppedFlag, 0)
+	startFrame := c.FrameCount()
+	var stats RunStats
+	for ; stats.Instructions < n && c.IsRunning(); stats.Instructions++ {
+		result := c.cycle()
+		if result.Err != nil {
+			stats.Err = result.Err
+		}
+		if done != nil && result.Err == nil && c.IsRunning() && done(result) {
+			c.stop(doneReason)
+		}
+	}
+	// stopping doesn't change the reason if something else got there first.
+	if c.IsRunning() {
+		c.stop(StopHalted)
+	}
+	stats.Reason = StopReason(atomic.LoadInt32(&c.stopReason))
+	if stats.Reason == StopFinished {
+		// the 0x0000 at the end isn't an instruction anybody executed.
+		stats.Instructions--
+	}
+	if stats.Reason != StopError {
+		stats.Err = nil
+	}
+	stats.PC = c.currentPC()
+	stats.Frames = c.FrameCount() - startFrame
+	return stats
+}
@@ -0,0 +1,134 @@
+package cpu
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// FramesPerSecond is how many frames the Chip8 draws in a second of emulated time.
+// The Chip8 has no real notion of frames, but its timers tick at 60Hz and most
+// games are written around that, so a frame is a sixtieth of a second's worth of instructions.
+const FramesPerSecond = 60
+
+// ErrNoCheckpoint is returned by RollbackTo when there's no checkpoint old enough
+// to roll back to: either checkpointing is off, or the frame has fallen out of the history.
+var ErrNoCheckpoint = errors.New("no checkpoint at or before that frame")
+
+// A checkpoint is the machine state as it was at the start of a frame -- all of
+// it, random number generator and keys included, so that the frames after it play
+// out the same way again given the same input.
+type checkpoint struct {
+	frame uint64
+	state machineState
+}
+
+// checkpointHistory is a ring of the most recent checkpoints, oldest first.
+// The checkpoints are allocated once, up front, and reused, so checkpointing
+// every frame doesn't churn the garbage collector.
+type checkpointHistory struct {
+	// every is the number of frames between checkpoints; 0 means checkpointing is off.
+	every  int
+	ring   []checkpoint
+	oldest int
+	count  int
+}
+
+// SetCheckpointing makes the Chip8 save a checkpoint of its state every `every` frames,
+// keeping the last `keep` of them, so it can later be rolled back with RollbackTo.
+// This is the machinery behind rewinding a game, and behind netplay's rollback,
+// where a late input means going back a few frames and playing them again.
+//
+// Checkpointing every frame with a few seconds of history costs about a megabyte
+// and a 4K copy per frame, which is nothing. SetCheckpointing(0, 0) turns it off.
+// Changing the settings throws away the existing checkpoints.
+func (c *Chip8) SetCheckpointing(every, keep int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if every < 1 || keep < 1 {
+		c.checkpoints = checkpointHistory{}
+		return
+	}
+	c.checkpoints = checkpointHistory{every: every, ring: make([]checkpoint, keep)}
+}
+
+// FrameCount returns the number of frames the Chip8 has run since the program was loaded.
+// It's safe to call from any goroutine.
+func (c *Chip8) FrameCount() uint64 {
+	return atomic.LoadUint64(&c.frame)
+}
+
+// Checkpoints returns the frames there are checkpoints for, oldest first.
+func (c *Chip8) Checkpoints() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &c.checkpoints
+	frames := make([]uint64, h.count)
+	for n := range frames {
+		frames[n] = h.ring[(h.oldest+n)%len(h.ring)].frame
+	}
+	return frames
+}
+
+// RollbackTo puts the Chip8 back the way it was at the start of the given frame, or
+// if there's no checkpoint for exactly that frame, the closest one before it. It returns
+// the frame it actually rolled back to. Checkpoints after that frame are thrown away,
+// since they belong to a future that's no longer going to happen.
+//
+// Like LoadState, RollbackTo is safe to call while the Chip8 is running, and the
+// peripherals, speed and other settings stay as they are.
+func (c *Chip8) RollbackTo(frame uint64) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &c.checkpoints
+	// find the newest checkpoint at or before frame.
+	n := h.count - 1
+	for ; n >= 0; n-- {
+		if h.ring[(h.oldest+n)%len(h.ring)].frame <= frame {
+			break
+		}
+	}
+	if n < 0 {
+		return 0, ErrNoCheckpoint
+	}
+	cp := &h.ring[(h.oldest+n)%len(h.ring)]
+	c.restoreMachineState(cp.state)
+	atomic.StoreUint64(&c.frame, cp.frame)
+	h.count = n + 1
+	return cp.frame, nil
+}
+
+// endFrame is called after every instruction, with how many sixtieths of a second
+// went by while it ran (see tick). It moves the frame count along a frame for each,
+// and takes a checkpoint at the start of every frame that's due one. It returns true
+// if that instruction was the last of its frame. c.mu must be held.
+func (c *Chip8) endFrame(ticks int) bool {
+	for i := 0; i < ticks; i++ {
+		frame := atomic.AddUint64(&c.frame, 1)
+		if c.checkpoints.every != 0 && frame%uint64(c.checkpoints.every) == 0 {
+			c.takeCheckpoint(frame)
+		}
+	}
+	return ticks > 0
+}
+
+// takeCheckpoint adds a checkpoint of the current state to the history. c.mu must be held.
+func (c *Chip8) takeCheckpoint(frame uint64) {
+	h := &c.checkpoints
+	var cp *checkpoint
+	if h.count < len(h.ring) {
+		cp = &h.ring[(h.oldest+h.count)%len(h.ring)]
+		h.count++
+	} else {
+		// the history is full; write over the oldest checkpoint.
+		cp = &h.ring[h.oldest]
+		h.oldest = (h.oldest + 1) % len(h.ring)
+	}
+	cp.frame = frame
+	c.captureMachineState(&cp.state)
+}
+
+// clear forgets every checkpoint, but keeps the settings.
+func (h *checkpointHistory) clear() {
+	h.oldest = 0
+	h.count = 0
+}
@@ -0,0 +1,219 @@
+package cpu
+
+import "time"
+
+// ConnectKeyboard swaps in a different keyboard, even while the Chip8 is running:
+// the next instruction that looks at the keys looks at the new one. Connect nil
+// to unplug the keyboard, and the Chip8 sees no keys pressed at all.
+func (c *Chip8) ConnectKeyboard(keyboard Keyboard) {
+	if keyboard == nil {
+		keyboard = unpluggedKeyboard{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setKeyboard(keyboard)
+}
+
+// ConnectSecondKeyboard plugs in a second keypad, for two-player games: the
+// CHIP-8X had a socket for one, and a few homebrew games use it. Programs read it
+// with SKP2 and SKNP2 (ExF2 and ExF5); everything else only ever looks at the first.
+// There's no second keypad until you connect one, and connecting nil unplugs it again.
+func (c *Chip8) ConnectSecondKeyboard(keyboard Keyboard) {
+	if keyboard == nil {
+		keyboard = unpluggedKeyboard{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setSecondKeyboard(keyboard)
+}
+
+// ConnectSpeaker swaps in a different speaker, even while the Chip8 is running.
+// If the Chip8 is beeping at the time, the old speaker stops and the new one
+// takes over for the rest of the beep. Connect nil to unplug the speaker.
+func (c *Chip8) ConnectSpeaker(speaker Speaker) {
+	if speaker == nil {
+		speaker = unpluggedSpeaker{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.beeping() {
+		c.speaker.StopSound()
+		speaker.StartSound()
+	}
+	c.speaker = speaker
+}
+
+// A DisplayFeed is a display's own supply of frames, for when there's more than one
+// display: a terminal as well as the window, say, or a recorder plugged in halfway
+// through a game. The Chip8's own FrameReady and Frame are for one display only,
+// since reading a frame takes it off the next reader; each DisplayFeed gets every
+// frame for itself. Like FrameReady and Frame, it never holds the Chip8 up -- a
+// display that reads slowly just skips frames.
+type DisplayFeed struct {
+	c     *Chip8
+	video *frameBuffer
+}
+
+// ConnectDisplay connects another display, even while the Chip8 is running, and
+// returns the frames for it, starting with the screen as it is now. Disconnect it
+// when the display's done with it.
+func (c *Chip8) ConnectDisplay() *DisplayFeed {
+	d := &DisplayFeed{c: c, video: newFrameBuffer()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d.video.scrolled = c.video.scrolled
+	d.video.publish(c.colorFrame())
+	c.displays = append(c.displays, d)
+	return d
+}
+
+// Disconnect stops sending the display frames. FrameReady never fires again, and
+// Frame keeps returning the last frame it got.
+func (d *DisplayFeed) Disconnect() {
+	d.c.mu.Lock()
+	defer d.c.mu.Unlock()
+	for i, connected := range d.c.displays {
+		if connected == d {
+			d.c.displays = append(d.c.displays[:i:i], d.c.displays[i+1:]...)
+			return
+		}
+	}
+}
+
+// FrameReady returns a channel that receives a value whenever there's a new frame
+// for the display, like the Chip8's FrameReady.
+func (d *DisplayFeed) FrameReady() <-chan struct{} {
+	return d.video.ready
+}
+
+// Frame returns the most recent frame, like the Chip8's Frame. Call it (or
+// ColorFrame) from one goroutine only.
+func (d *DisplayFeed) Frame() Frame {
+	return d.video.latest()[0]
+}
+
+// ColorFrame returns the most recent frame with all its bit planes, like the
+// Chip8's ColorFrame.
+func (d *DisplayFeed) ColorFrame() ColorFrame {
+	return d.video.latest()
+}
+
+// unpluggedKeyboard is what's connected when no keyboard is: nobody's pressing anything.
+type unpluggedKeyboard struct{}
+
+func (unpluggedKeyboard) Poll() KeyCode { return KeyNone }
+
+// unpluggedSpeaker is what's connected when no speaker is. It beeps in silence.
+type unpluggedSpeaker struct{}
+
+func (unpluggedSpeaker) StartSound() {}
+func (unpluggedSpeaker) StopSound()  {}
+
+// A KeyEvent is a key on the keypad going down or coming back up.
+type KeyEvent struct {
+	Code    KeyCode
+	Pressed bool
+	// Timestamp is when it happened, for anyone recording input.
+	Timestamp time.Time
+}
+
+// KeyEventSource is something a Keyboard can be as well, if it knows when keys
+// go down and come up rather than just which key is down right now. Polling only
+// sees one key at a time, and only if it's still down when the Chip8 gets round
+// to looking; events catch every press, however quick, and every key at once.
+//
+// When the Chip8's keyboard is a KeyEventSource, the Chip8 keeps track of the keys
+// from its events instead of polling for them, and LD Vx,K waits for a key to be
+// pressed, like on the real thing, instead of taking whatever key is already down.
+type KeyEventSource interface {
+	// KeyEvents returns the channel the events come down. The Chip8 reads it
+	// between instructions, and never waits on it. A keyboard that turns out not
+	// to have events after all (see control.Keypad) returns nil, and gets polled.
+	KeyEvents() <-chan KeyEvent
+}
+
+// setKeyboard connects keyboard, and its events if it has any. c.mu must be held.
+func (c *Chip8) setKeyboard(keyboard Keyboard) {
+	c.input = keyboard
+	c.keyEvents = nil
+	if source, ok := keyboard.(KeyEventSource); ok {
+		c.keyEvents = source.KeyEvents()
+	}
+	c.keysDown = 0
+	c.waitingForKey, c.keyArrived = false, false
+}
+
+// setSecondKeyboard connects keyboard as the second keypad, and its events if
+// it has any. c.mu must be held.
+func (c *Chip8) setSecondKeyboard(keyboard Keyboard) {
+	c.input2 = keyboard
+	c.keyEvents2 = nil
+	if source, ok := keyboard.(KeyEventSource); ok {
+		c.keyEvents2 = source.KeyEvents()
+	}
+	c.keysDown2 = 0
+}
+
+// takeKeyEvents catches up on both keypads' events, if they have any. c.mu must be held.
+func (c *Chip8) takeKeyEvents() {
+	c.takeSecondKeyEvents()
+	for c.keyEvents != nil {
+		select {
+		case event, ok := <-c.keyEvents:
+			if !ok {
+				c.keyEvents = nil
+				return
+			}
+			bit := uint16(1) << (event.Code & 0xf)
+			if !event.Pressed {
+				c.keysDown &^= bit
+				continue
+			}
+			c.keysDown |= bit
+			if c.waitingForKey && !c.keyArrived {
+				c.keyArrived, c.arrivedKey = true, event.Code
+			}
+		default:
+			return
+		}
+	}
+}
+
+// takeSecondKeyEvents catches up on the second keypad's events. Nothing waits
+// for a key on it, so all there is to do is keep keysDown2 up to date. c.mu must be held.
+func (c *Chip8) takeSecondKeyEvents() {
+	for c.keyEvents2 != nil {
+		select {
+		case event, ok := <-c.keyEvents2:
+			if !ok {
+				c.keyEvents2 = nil
+				return
+			}
+			bit := uint16(1) << (event.Code & 0xf)
+			if event.Pressed {
+				c.keysDown2 |= bit
+			} else {
+				c.keysDown2 &^= bit
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyDown2 reports whether key is held down on the second keypad. With no second
+// keypad plugged in, nothing is. c.mu must be held.
+func (c *Chip8) keyDown2(key KeyCode) bool {
+	if c.keyEvents2 != nil {
+		return c.keysDown2&(1<<(key&0xf)) != 0
+	}
+	return c.input2.Poll() == key&0xf
+}
+
+// keyDown reports whether key is held down. c.mu must be held.
+func (c *Chip8) keyDown(key KeyCode) bool {
+	if c.keyEvents != nil {
+		return c.keysDown&(1<<(key&0xf)) != 0
+	}
+	return c.input.Poll() == key&0xf
+}
@@ -2,20 +2,27 @@ package cpu
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
 	"fmt"
+	"io"
 	"log"
-	"math/rand"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // A KeyCode is a number that represents a key on the Chip-8 hexadecimal keyboard.
-// Only the numbers 0 through 16 (0x0 through 0xF) are valid KeyCodes.
-// KeyCode 0 indicates 'No Keypress', ie that no key is currently pressed.
+// Only the numbers 0 through 15 (0x0 through 0xF) are valid KeyCodes, and
+// KeyNone, which is none of them, indicates 'No Keypress'.
 type KeyCode byte
 
-// KeyNone indicates 'No Keypresss', ie that no key is currently pressed.
+// KeyNone indicates 'No Keypresss', ie that no key is currently pressed. It used
+// to be 0, which left no way to press key 0; now it's off the end of the keypad.
 const (
-	KeyNone KeyCode = 0x00
+	KeyNone KeyCode = 0xff
+	Key0    KeyCode = 0x00
 	Key1    KeyCode = 0x01
 	Key2    KeyCode = 0x02
 	Key3    KeyCode = 0x03
@@ -29,6 +36,7 @@ const (
 	KeyB    KeyCode = 0x0b
 	KeyC    KeyCode = 0x0c
 	KeyD    KeyCode = 0x0d
+	KeyE    KeyCode = 0x0e
 	KeyF    KeyCode = 0x0f
 )
 
@@ -49,6 +57,18 @@ const (
 // operation of the chip, letting you start and stop the CPU, inspect its state, and execute a single
 // instruction at a time.
 type Chip8 struct {
+	// frame is the number of frames run since the program was loaded.
+	// It's read from other goroutines, so use sync/atomic -- and it's first in the
+	// struct so it's 64-bit aligned even on 32-bit platforms, which sync/atomic needs.
+	frame uint64
+
+	// mu is held while the Chip8 executes an instruction, so other goroutines
+	// can safely look at (or replace) the machine state between instructions.
+	mu sync.Mutex
+	// running is held by Resume for as long as the run loop is going, so Wait
+	// can tell when it's stopped and two run loops can never go at once.
+	running sync.Mutex
+
 	// program counter
 	pc uint16
 	// address register
@@ -56,24 +76,116 @@ type Chip8 struct {
 	// data registers
 	v [16]byte
 	// delay and sound timers.
-	// Both delay and sound timers are registers that are decremented at 60hz once set.
+	// Both delay and sound timers are registers that are decremented at 60hz once set
+	// (see timers.go).
 	dt byte
 	st byte
+	// beepHold is how many more sixtieths of a second the speaker stays on after
+	// the sound timer runs out, to make a short beep last minBeepTicks (see MinimumBeep).
+	beepHold     int
+	minBeepTicks int
 
 	// stack pointer
-	sp     uint16
-	memory [4096]byte
-
-	Log    bytes.Buffer
-	logger *log.Logger
-
-	speed         int
-	clock         *time.Ticker
-	isStoppedFlag bool
-
-	speaker  Speaker
-	input    Keyboard
-	videoOut chan<- [256]byte
+	sp uint16
+	// memory is 4K, unless the Chip8 was made with more (see WithMemorySize).
+	memory []byte
+	// plane2 is the screen's second bit plane, and planes is which planes DRW and
+	// CLS draw on, bit 0 for plane 1 and bit 1 for plane 2 (see planes.go).
+	plane2 [256]byte
+	planes byte
+
+	// romHash is the SHA-1 hash of the loaded program, which savestates are checked against.
+	romHash [sha1.Size]byte
+	// romSize is how many bytes long the loaded program is.
+	romSize int
+
+	Log      bytes.Buffer
+	logger   *log.Logger
+	logLevel LogLevel
+	// logOutput is where the log goes instead of Log, if anywhere (see SetLogOutput).
+	logOutput io.Writer
+
+	// speed is the number of instructions to execute per second.
+	// It can be changed from another goroutine, so use sync/atomic.
+	speed int32
+	// slowMotion is the slow motion rate, in thousandths (see SetSlowMotion).
+	// It's set from other goroutines too, so only touch it through sync/atomic.
+	slowMotion int32
+	clock      *pacer
+	// isStoppedFlag is 1 while the Chip8 is halted. Halt and IsRunning get
+	// called from other goroutines, so only touch it through sync/atomic.
+	isStoppedFlag int32
+	// stopReason is why the Chip8 last stopped, a StopReason (see stop.go).
+	// It's set from other goroutines too, so only touch it through sync/atomic.
+	stopReason int32
+	// turbo is 1 while the Chip8 is running flat out (see SetTurbo). It's
+	// set from other goroutines, so only touch it through sync/atomic.
+	turbo int32
+
+	speaker Speaker
+	input   Keyboard
+	video   *frameBuffer
+	// displays are the extra displays connected with ConnectDisplay, each with
+	// a frameBuffer of its own.
+	displays []*DisplayFeed
+	// input2 is the second keypad, for two-player games (see ConnectSecondKeyboard),
+	// and keyEvents2 and keysDown2 are its events and the keys they say are down,
+	// like keyEvents and keysDown.
+	input2     Keyboard
+	keyEvents2 <-chan KeyEvent
+	keysDown2  uint16
+
+	// keyEvents is where the keyboard's events come from, if it has any (see
+	// KeyEventSource), and keysDown is which keys they say are down, bit n for key n.
+	keyEvents <-chan KeyEvent
+	keysDown  uint16
+	// waitingForKey is true while LD Vx,K waits for a key event, and keyArrived
+	// is true once one has, arrivedKey being the key.
+	waitingForKey, keyArrived bool
+	arrivedKey                KeyCode
+
+	// rng is where RND gets its random numbers. It's the Chip8's own, rather than
+	// math/rand's, so that two Chip8s given the same seed roll the same numbers (see SetSeed),
+	// and a plain value, so that checkpoints can copy it and roll them again.
+	rng rand.PCG
+
+	// the SCHIP's RPL user flags, and where they're kept between runs (see ConnectRPLFlags).
+	// Unlike everything else, they survive a reset.
+	rpl        [numRPLFlags]byte
+	rplStorage RPLFlags
+	// rplChanged is set when a program changes the flags, until they're handed
+	// to rplSaver at the end of the frame.
+	rplChanged bool
+	rplSaver   rplSaver
+
+	// protection is which parts of memory programs can't write to (see protect.go),
+	// and protectedWrites are the writes the current instruction tried to make there anyway.
+	protection       WriteProtection
+	protectedWrites  []ProtectedWrite
+	onProtectedWrite func(w ProtectedWrite)
+
+	// quirks are which interpreter's idea of the instructions to follow (see quirks.go).
+	quirks Quirks
+	// font is the digits in memory for LD F,Vx and LD HF,Vx (see fonts.go).
+	font Font
+	// peripherals are the devices wired into memory (see peripheral.go).
+	peripherals []attachedPeripheral
+	// hostCalls are the Go functions programs can call with 0nnn, if they're allowed to (see hostcall.go).
+	hostCalls map[uint16]HostCall
+
+	// timerPhase is how far through the current sixtieth of a second the Chip8
+	// is, in sixtieths of an instruction. (See timers.go.)
+	timerPhase  int
+	checkpoints checkpointHistory
+	// runForOwed is the part of an instruction RunFor didn't have time for last
+	// time, in nanoseconds times instructions a second. (See bounded.go.)
+	runForOwed int64
+
+	// onInstruction and onFrame are called as the Chip8 runs, and timer is told
+	// how long each instruction took, if anybody's asked (see hooks.go).
+	onInstruction func(pc, opcode uint16)
+	onFrame       func(frame uint64)
+	timer         InstructionTimer
 }
 
 // NewChip8 returns an initialized Chip8, ready to run
@@ -87,28 +199,188 @@ type Chip8 struct {
 // of the Chip8 interpreter takes a very lazy approach to displaying
 // the screen: it provides direct read-only access to its video memory.
 // "If these kids want to see the screen, they can read the hex or get
-// off my lawn", this implementation says.
-func NewChip8(keyboard Keyboard, speaker Speaker, videoOut chan<- [256]byte) *Chip8 {
+// off my lawn", this implementation says. (It has mellowed a little with age:
+// see LatestFrames, BufferedFrames and PolledFrames for the ways it'll hand
+// frames over, if you pass one as an option.)
+func NewChip8(keyboard Keyboard, speaker Speaker, opts ...Option) *Chip8 {
 	c := new(Chip8)
+	c.font = DefaultFont
+	c.memory = make([]byte, MemorySize4K)
 	c.reset()
-	c.input = keyboard
+	if keyboard == nil {
+		keyboard = unpluggedKeyboard{}
+	}
+	c.setKeyboard(keyboard)
+	c.setSecondKeyboard(unpluggedKeyboard{})
 	c.speaker = speaker
-	c.videoOut = videoOut
+	if speaker == nil {
+		c.speaker = unpluggedSpeaker{}
+	}
+	c.video = newFrameBuffer()
+	c.logLevel = LogInstructions
+	c.clock = newPacer(0)
+	c.slowMotion = 1000
+	c.SetSpeed(DefaultSpeed)
+	c.rng.Seed(uint64(time.Now().UnixNano()), 0)
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
+// SetSeed seeds the random number generator behind RND. Two Chip8s running the same
+// program with the same seed and the same input do exactly the same thing, which is
+// what keeps netplay peers in step (and makes bugs reproducible).
+// NewChip8 seeds it from the clock.
+func (c *Chip8) SetSeed(seed int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rng.Seed(uint64(seed), 0)
+}
+
+// DefaultSpeed is the number of instructions per second a new Chip8 executes.
+const DefaultSpeed = 60
+
+// SetSpeed sets the number of instructions the Chip8 executes per second.
+// Speeds in the hundreds (500-1000 or so) are about what the original
+// interpreters managed, and what most games expect.
+//
+// SetSpeed is safe to call from any goroutine, even while the Chip8 is running.
+// Speeds less than 1 are treated as 1.
+func (c *Chip8) SetSpeed(instructionsPerSecond int) {
+	if instructionsPerSecond < 1 {
+		instructionsPerSecond = 1
+	}
+	atomic.StoreInt32(&c.speed, int32(instructionsPerSecond))
+	c.setClock()
+}
+
+// Speed returns the number of instructions the Chip8 executes per second.
+func (c *Chip8) Speed() int {
+	return int(atomic.LoadInt32(&c.speed))
+}
+
+// SetSlowMotion runs the Chip8 in slow motion, at rate times its speed: at 0.5 it runs
+// half as fast, at 0.1 a tenth as fast, and at 1 it's back to normal. Everything slows
+// down together, timers and all, so the program still does exactly what it would have
+// done -- just slowly enough to watch it do it. Unlike turning the speed down, the
+// speed the program asked for is left alone, so there's nothing to put back afterwards.
+//
+// Rates are kept between 0.01 and 1. SetSlowMotion is safe to call from any goroutine.
+func (c *Chip8) SetSlowMotion(rate float64) {
+	permille := int32(rate*1000 + 0.5)
+	if permille < 10 {
+		permille = 10
+	}
+	if permille > 1000 {
+		permille = 1000
+	}
+	atomic.StoreInt32(&c.slowMotion, permille)
+	c.setClock()
+}
+
+// SlowMotion returns the slow motion rate; 1 means the Chip8 is running at full speed.
+func (c *Chip8) SlowMotion() float64 {
+	return float64(atomic.LoadInt32(&c.slowMotion)) / 1000
+}
+
+// setClock sets the time between instructions from the speed and slow motion rate.
+func (c *Chip8) setClock() {
+	perSecond := time.Duration(atomic.LoadInt32(&c.speed))
+	permille := time.Duration(atomic.LoadInt32(&c.slowMotion))
+	c.clock.setInterval(time.Second * 1000 / (perSecond * permille))
+}
+
+// A LogLevel controls how much the Chip8 writes to its Log.
+type LogLevel int
+
+const (
+	// LogNone turns off logging entirely.
+	LogNone LogLevel = iota
+	// LogInstructions logs a line for every instruction the Chip8 executes.
+	// This is handy for debugging, but formatting all those lines takes
+	// longer than executing the instructions does, so turn it off if you need speed.
+	LogInstructions
+	// LogExplanations logs a line for every instruction too, but explains what the
+	// instruction did in plain English, with the values it did it to:
+	//
+	//	chip8:12:00:00.000000 20c: DRW V2,V3,5 -- draw the 5-row sprite at I=0x20a at x=V2(12), y=V3(5); no collision
+	//
+	// It's for learning how the Chip8 (and emulators) work. It's slower still than
+	// LogInstructions, so run the Chip8 slowly to keep up with it anyway.
+	LogExplanations
+)
+
+// SetLogLevel sets how much the Chip8 writes to its Log.
+// NewChip8 starts out logging every instruction (LogInstructions).
+func (c *Chip8) SetLogLevel(level LogLevel) {
+	c.logLevel = level
+}
+
+// SetLogOutput sends the log to w as it's written, instead of keeping it in Log --
+// to watch it go by in a terminal, say. Set it to nil to go back to Log.
+func (c *Chip8) SetLogOutput(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logOutput = w
+	if w == nil {
+		w = &c.Log
+	}
+	c.logger.SetOutput(w)
+}
+
+// tracing reports whether every executed instruction should be logged.
+// Check it before formatting a log line, so we don't pay for formatting
+// lines nobody is going to read.
+func (c *Chip8) tracing() bool {
+	return c.logLevel == LogInstructions
+}
+
+// explaining reports whether every executed instruction should be explained.
+func (c *Chip8) explaining() bool {
+	return c.logLevel == LogExplanations
+}
+
 // Run loads a program into memory and executes it.
 //
 // This is the simplest way to run a program on the Chip8 CPU. Make sure that you
 // have set up a display set up to read the Chip8's video memory, or else you'll
 // see a black screen, just like if you forgot to plug in your TV in Real Life.
-func (c *Chip8) Run(program []byte) error {
+//
+// Run returns once the Chip8 stops, saying why (see Resume). If the program
+// couldn't be loaded, that's a StopError too.
+func (c *Chip8) Run(program []byte) RunResult {
+	if err := c.Load(program); err != nil {
+		return RunResult{Reason: StopError, PC: c.currentPC(), Err: err}
+	}
+	return c.Resume()
+}
+
+// Load resets the Chip8 and loads a program into memory, ready to be started
+// with Resume(). If the Chip8 is running, Load has no effect and returns an error.
+//
+// Run does all this for you; Load is for when you want to do something in
+// between loading the program and running it, like restoring a savestate.
+func (c *Chip8) Load(program []byte) error {
+	if c.IsRunning() {
+		return fmt.Errorf("can't load a program while the Chip8 is running")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.reset()
 	err := c.load(program)
 	if err != nil {
 		return err
 	}
-	c.Resume()
+	c.romHash = sha1.Sum(program)
+	c.romSize = len(program)
+	// the freshly loaded program is the oldest thing there is to roll back to.
+	if c.checkpoints.every != 0 {
+		c.takeCheckpoint(0)
+	}
+	// show the freshly cleared screen, so the display doesn't keep
+	// showing whatever the last program left behind.
+	c.refreshScreen()
 	return nil
 }
 
@@ -146,17 +418,23 @@ type Speaker interface {
 // under the hood both the stack and the video memory live in the same 'Memory' byte array
 // with everything else.
 // This is for convenience, so you don't have to know what the offsets are to figure out
-// where the screen and stack start. (If you were curious, the offsets are 0xEA0 for the stack
-// and 0xF00 for the video memory.)
+// where the screen and stack start. (If you were curious, they're right at the top of
+// memory: in 4K, the offsets are 0xEA0 for the stack and 0xF00 for the video memory.)
 type Chip8State struct {
-	PC            uint16
-	I             uint16
-	V             [16]byte
-	DT            byte
-	ST            byte
-	Memory        [4096]byte
-	Stack         []byte
-	VideoMemory   []byte
+	PC uint16
+	I  uint16
+	V  [16]byte
+	DT byte
+	ST byte
+	// Memory is all of memory, MemorySize bytes of it (see WithMemorySize).
+	Memory      []byte
+	MemorySize  int
+	Stack       []byte
+	VideoMemory []byte
+	// Plane2 is the screen's second bit plane, which isn't in Memory, and Planes
+	// which planes DRW and CLS draw on (see planes.go).
+	Plane2        [256]byte
+	Planes        byte
 	MemoryDiagram string
 	Speed         int
 }
@@ -183,18 +461,63 @@ type Chip8State struct {
 // the video card read the video memory and converted it to electrical signals that the
 // CRT TV it was connected to could display. I think! I've never even looked at any of
 // these computers except online.
+//
+// ReadVideoMemory reads the screen as it is right now, between instructions, so a
+// display adapter can poll it whenever it likes. (If you'd rather be told when
+// there's something new to draw, see FrameReady and Frame.)
+func (c *Chip8) ReadVideoMemory() [256]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var videoMemory [256]byte
+	copy(videoMemory[:], c.memory[c.videoMemoryAddress():])
+	return videoMemory
+}
+
+// ReadScreen is ReadVideoMemory for people with better things to do than shift
+// bits around: it returns the screen as 32 rows of 64 pixels, true for on.
+func (c *Chip8) ReadScreen() [32][64]bool {
+	frame := Frame(c.ReadVideoMemory())
+	return frame.Matrix()
+}
+
+// refreshScreen sends the screen as it is now to whoever's displaying it (see Frame
+// and ColorFrame).
 func (c *Chip8) refreshScreen() {
-	var screen [256]byte
-	copy(screen, c.memory[videoMemoryAddress:highestMemoryAddress]
-	c.videoOut <- screen
+	frame := c.colorFrame()
+	c.video.publish(frame)
+	for _, d := range c.displays {
+		d.video.scrolled = c.video.scrolled
+		d.video.publish(frame)
+	}
+}
+
+// FrameReady returns a channel that receives a value whenever the Chip8 has drawn
+// a new frame. Once it fires, call Frame() to get the frame.
+//
+// The Chip8 never waits for anyone to read from this channel: if frames come
+// faster than you read them, you'll get one notification for the whole bunch.
+// If the Chip8 was made with PolledFrames, the channel never fires.
+func (c *Chip8) FrameReady() <-chan struct{} {
+	return c.video.ready
+}
+
+// Frame returns the most recent frame the Chip8 has drawn, as a copy of its
+// 256 bytes of video memory. (See the Frame type for how to get at the pixels.)
+//
+// Frame is meant to be called by one display at a time. For another display
+// alongside it, or a recorder, connect a DisplayFeed (see ConnectDisplay).
+func (c *Chip8) Frame() Frame {
+	return c.video.latest()[0]
 }
 
 // load takes a Chip8 program as input and loads the program into the Chip8 memory.
 func (c *Chip8) load(program []byte) error {
+	if int(programStartAddress)+len(program) > len(c.memory) {
+		return fmt.Errorf("the program is %d bytes long; only %d fit in memory", len(program), len(c.memory)-int(programStartAddress))
+	}
 	// load program into memory
-	var programStartAddr = 0x200
 	for i, b := range program {
-		c.memory[programStartAddr+i] = b
+		c.memory[int(programStartAddress)+i] = b
 	}
 	return nil
 }
@@ -211,63 +534,160 @@ func (c *Chip8) reset() {
 	c.v = [16]byte{}
 	c.dt = 0x00
 	c.st = 0x00
-	c.sp = stackAddress
-	c.memory = [4096]byte{}
+	c.beepHold = 0
+	c.sp = c.stackAddress()
+	for i := range c.memory {
+		c.memory[i] = 0
+	}
+	c.plane2 = [256]byte{}
+	c.planes = 1
+	atomic.StoreUint64(&c.frame, 0)
+	c.timerPhase = 0
+	c.runForOwed = 0
+	c.checkpoints.clear()
+	c.waitingForKey, c.keyArrived = false, false
 
-	c.speed = 60                               // number of instructions to execute per second
-	c.clock = time.NewTicker(time.Second / 60) // Tick 60 times per second
 	c.Log = bytes.Buffer{}
+	logOutput := c.logOutput
+	if logOutput == nil {
+		logOutput = &c.Log
+	}
 
 	// Chip8 begins life in stopped state.
-	c.isStoppedFlag = true
+	atomic.StoreInt32(&c.isStoppedFlag, 1)
 
 	// instantiate Chip8 logger.
-	c.logger = log.New(&c.Log, "chip8:", log.Ltime|log.Lmicroseconds)
+	c.logger = log.New(logOutput, "chip8:", log.Ltime|log.Lmicroseconds)
 
 	// set program counter to start of program memory
 	c.pc = 0x200
 
-	// set decimal digits in memory location
-	loadFontSprites(&c.memory, 0x0)
+	// set decimal digits in memory location (see fonts.go)
+	c.loadFont()
 }
 
 // Resume puts the Chip8 back into a running state after the Chip8 has
 // been halted (by calling -- you guessed it -- Halt()).
 // If the Chip8 is in a running state, calls to Resume have no effect.
-func (c *Chip8) Resume() {
+//
+// Resume returns once the Chip8 stops again, saying why: Halt, the end of the
+// program, a Break, or an instruction it couldn't execute.
+func (c *Chip8) Resume() RunResult {
+	return c.ResumeContext(context.Background())
+}
+
+// ResumeContext is Resume, except that it also stops when ctx is cancelled.
+func (c *Chip8) ResumeContext(ctx context.Context) RunResult {
+	if c.IsRunning() {
+		return RunResult{Reason: StopAlreadyRunning, PC: c.currentPC()}
+	}
+	// if we were only just halted, the old run loop may still be finishing
+	// its last instruction; wait for it to get out of the way.
+	c.running.Lock()
+	defer c.running.Unlock()
 	// Only begin the CPU loop if Chip8 CPU is currently stopped.
-	if !c.IsRunning() {
-		c.isStoppedFlag = false
-		// While the Chip8 is in 'running' state,
-		// Run the CPU loop. Exit the loop once
-		// the Chip8 exits running state.
-		for c.IsRunning() {
-			// wait for the clock to tick
-			<-c.clock.C
-			// decode and execute the next instruction
-			c.cycle()
-			// TODO CONSIDER add 'err' and/or 'finished' here,
-			// to make sequence of control simpler.
+	if c.IsRunning() {
+		return RunResult{Reason: StopAlreadyRunning, PC: c.currentPC()}
+	}
+	if err := ctx.Err(); err != nil {
+		return RunResult{Reason: StopCancelled, PC: c.currentPC(), Err: err}
+	}
+	atomic.StoreInt32(&c.stopReason, int32(StopHalted))
+	atomic.StoreInt32(&c.isStoppedFlag, 0)
+	stopWatching := c.cancelOnDone(ctx)
+	defer stopWatching()
+	// we could have been stopped for ages; don't try to make up for lost time.
+	c.clock.restart()
+	// and pick up any beep that was going when we stopped (see timers.go).
+	c.resumeSound()
+	defer c.pauseSound()
+	// and when we stop, get the RPL flags saved before saying so.
+	defer c.finishSavingRPLFlags()
+	// While the Chip8 is in 'running' state,
+	// Run the CPU loop. Exit the loop once
+	// the Chip8 exits running state.
+	var err error
+	for c.IsRunning() {
+		// wait for the clock to tick, unless we're in turbo mode
+		// and don't wait for anything.
+		if !c.IsTurbo() {
+			c.clock.wait()
+		}
+		// decode and execute the next instruction
+		if result := c.cycle(); result.Err != nil {
+			err = result.Err
 		}
 	}
+	result := RunResult{Reason: StopReason(atomic.LoadInt32(&c.stopReason)), PC: c.currentPC()}
+	switch result.Reason {
+	case StopError:
+		result.Err = err
+	case StopCancelled:
+		result.Err = ctx.Err()
+	}
+	return result
 }
 
 // Halt pauses a running Chip8 CPU after the currently executing instruction finishes.
 // To resume a stopped Chip8, call its Resume() method.
 // While the CPU is in a stopped state, further calls to Stop have no effect.
 func (c *Chip8) Halt() {
-	if c.IsRunning() {
-		c.isStoppedFlag = true
+	c.stop(StopHalted)
+}
+
+// Wait blocks until the Chip8 has stopped running: Halt only asks the Chip8 to stop,
+// and it finishes the instruction it's on first. Halt and then Wait before doing
+// anything that needs the Chip8 to be good and stopped, like loading a new program.
+// If the Chip8 isn't running, Wait returns right away.
+func (c *Chip8) Wait() {
+	c.running.Lock()
+	c.running.Unlock()
+}
+
+// SetTurbo turns turbo mode on or off. In turbo mode the Chip8 stops waiting for
+// its clock and executes instructions as fast as it possibly can -- handy for
+// fast-forwarding through slow title screens and long waits.
+// Timers count down sixty times a second of emulated time just like always, so to
+// the program it looks like time itself has sped up.
+//
+// SetTurbo is safe to call from any goroutine, even while the Chip8 is running.
+func (c *Chip8) SetTurbo(on bool) {
+	var flag int32
+	if on {
+		flag = 1
 	}
+	atomic.StoreInt32(&c.turbo, flag)
+}
+
+// IsTurbo returns true if the Chip8 is in turbo mode.
+func (c *Chip8) IsTurbo() bool {
+	return atomic.LoadInt32(&c.turbo) == 1
 }
 
 // IsRunning returns true if the Chip8 CPU is in a running state
 // and false if the Chip8 CPU is in a halted state.
 func (c *Chip8) IsRunning() bool {
-	return !c.isStoppedFlag
+	return atomic.LoadInt32(&c.isStoppedFlag) == 0
+}
+
+// StepResult is what a single instruction did: which instruction it was, where
+// it was, where the program goes next, and everything it changed.
+type StepResult struct {
+	Instruction Instruction
+	// PC is the address the instruction was at, and NextPC is where the program
+	// counter ended up after it.
+	PC, NextPC uint16
+	// Changes is everything the instruction changed, timers included: Diff of
+	// the Chip8 from just before it to just after.
+	Changes StateDiff
+	// Err is why the instruction couldn't be executed, if it couldn't. The
+	// program counter stays put on it.
+	Err error
 }
 
 // Step executes the next instruction in its entirety and then pauses the Chip8 CPU.
+// It returns what the instruction did, so you don't have to take Snapshots on
+// either side of it and Diff them yourself.
 //
 // The behavior of Step changes slightly depending on the state of the Chip8 CPU.
 // If the Chip8 CPU is currently running, Step lets the CPU finish executing the instruction
@@ -275,99 +695,136 @@ func (c *Chip8) IsRunning() bool {
 // instruction and pauses the Chip8 CPU.
 // If the Chip8 CPU is currently paused, Step executes the next program instruction and pauses
 // the Chip8 CPU.
-func (c *Chip8) Step() {
+//
+// If the program has already ended, there's no instruction to execute: the
+// result's Instruction is the 0x0000 that marks the end, and the timers are all
+// that changes.
+func (c *Chip8) Step() StepResult {
 	// stop CPU if currently running
 	if c.IsRunning() {
 		c.Halt()
 	}
+	// wait for the run loop to finish its last instruction, and keep a Resume
+	// out until we're done, so the Changes are this instruction's alone.
+	c.running.Lock()
+	defer c.running.Unlock()
+	before := c.Snapshot()
 	// do one cycle
-	c.cycle()
+	result := c.cycle()
+	result.Changes = Diff(before, c.Snapshot())
+	return result
 }
 
-func (c *Chip8) cycle() {
+// cycle executes one instruction and counts the timers down, if they're due. It fills in all of
+// the StepResult but the Changes, which cost too much to work out every cycle
+// when the Chip8's running flat out; Step works those out itself.
+func (c *Chip8) cycle() StepResult {
+	c.mu.Lock()
 
-	// decrement delay timer
-	if c.dt > 0 {
-		c.dt--
-	}
-	// decrement sound timer
-	if c.st > 0 {
-		c.st--
-		// tell the speaker to stop playing if we reached
-		// the end of the sound timer on this cycle.
-		if c.st == 0 {
-			c.speaker.StopSound()
-		}
-	}
+	// count the timers down, if a sixtieth of a second has gone by (see timers.go).
+	ticks := c.tick()
+	c.countDownTimers(ticks)
 	// if haven't reached end of program,
 	// execute next instruction in program.
-	opcode := c.readOpcode(c.pc)
+	c.takeKeyEvents()
+	pc := c.pc
+	opcode := c.readOpcode(pc)
+	ins := Decode(opcode)
+	var err error
+	var started time.Time
+	if c.timer != nil {
+		started = time.Now()
+	}
 	if opcode == eofInstruction {
-		c.Halt()
+		c.stop(StopFinished)
+	} else if c.explaining() {
+		before := c.registers()
+		if err = c.exec(ins); err == nil {
+			c.logger.Printf("%03x: %s -- %s", pc, ins, explain(opcode, before, c.registers(), c.quirks))
+		}
 	} else {
 		// exec will handle incrementing and/or moving the program counter.
-		c.exec(opcode)
+		err = c.exec(ins)
+	}
+	if c.timer != nil && opcode != eofInstruction {
+		c.timer.TimeInstruction(pc, opcode, time.Since(started))
+	}
+	if err == nil {
+		// stop at the instruction that did it, like for any other error.
+		if err = c.protectedWriteError(); err != nil {
+			c.pc = pc
+		}
+	}
+	if err != nil {
+		// there's no carrying on from an instruction we don't understand.
+		c.stop(StopError)
+	}
+	result := StepResult{Instruction: ins, PC: pc, NextPC: c.pc, Err: err}
+	frameEnded := c.endFrame(ticks)
+	if frameEnded {
+		c.saveRPLFlags()
+	}
+	frame := c.FrameCount()
+	onInstruction, onFrame := c.onInstruction, c.onFrame
+	onProtectedWrite, protectedWrites := c.onProtectedWrite, c.protectedWrites
+	c.protectedWrites = nil
+	c.mu.Unlock()
+
+	// the hooks are called with the Chip8 unlocked, so they can look around.
+	if onProtectedWrite != nil {
+		for _, w := range protectedWrites {
+			onProtectedWrite(w)
+		}
 	}
+	if onInstruction != nil && opcode != eofInstruction && err == nil {
+		onInstruction(pc, opcode)
+	}
+	if onFrame != nil && frameEnded {
+		onFrame(frame)
+	}
+	return result
 }
 
 // Snapshot returns a static copy of the Chip8 CPU at the moment the method is called.
 func (c *Chip8) Snapshot() Chip8State {
-	return Chip8State{
-		PC:            c.pc,
-		I:             c.i,
-		V:             c.v,
-		DT:            c.dt,
-		ST:            c.st,
-		Stack:         c.memory[stackAddress:c.sp],
-		Memory:        c.memory,
-		MemoryDiagram: "FIXME:NotImplemented",
-		VideoMemory:   c.memory[videoMemoryAddress:highestMemoryAddress],
-		Speed:         c.speed}
-}
-
-const stackAddress uint16 = 0xEA0
-const videoMemoryAddress uint16 = 0xF00
-const highestMemoryAddress uint16 = 0xFFF
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := Chip8State{
+		PC:         c.pc,
+		I:          c.i,
+		V:          c.v,
+		DT:         c.dt,
+		ST:         c.st,
+		Memory:     append([]byte(nil), c.memory...),
+		MemorySize: len(c.memory),
+		Plane2:     c.plane2,
+		Planes:     c.planes,
+		Speed:      c.Speed()}
+	// slice the snapshot's own copy of memory, not the live memory,
+	// or the stack and screen would keep changing under the caller's feet.
+	state.Stack = state.Memory[c.stackAddress():c.sp]
+	state.VideoMemory = state.Memory[c.videoMemoryAddress():]
+	return state
+}
+
+const programStartAddress uint16 = 0x200
 const eofInstruction = 0x0000
 
-func loadFontSprites(memory *[4096]byte, startAddress int) {
-	fontSpriteData := [16 * 5]byte{
-		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-		0x20, 0x60, 0x20, 0x20, 0x70, // 1
-		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-		0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-		0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-		0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-		0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
-	}
-	totalBytes := 16 * 5 // 16 glyphs, 5 bytes wide
-	for i := startAddress; i < startAddress+totalBytes; i++ {
-		offset := startAddress + i
-		memory[offset] = fontSpriteData[i]
-	}
-}
-
-// drawSprite draws the sprite to the specified coordinates on the screen.
+// drawSprite draws the sprite to the specified coordinates on screen, which is the
+// video memory of one of the screen's bit planes (see plane).
 //
 // The x and y arguments are the sprite's target top-left screen coordinates.
 // If x or y are outside the visible area of the screen, drawSprite wraps the
 // coordinates around so they are safely in screen space.
 //
 // If pixels of the sprite are drawn outside the visible area of the screen,
-// those pixels are wrapped around to the opposite edge.
+// those pixels are wrapped around to the opposite edge -- or with the Clip quirk,
+// thrown away.
 //
-// drawSprite returns true if the sprite was drawn on top of any other pixels
-// already on the screen; otherwise it returns false.
-func (c *Chip8) drawSprite(sprite []byte, x, y byte) bool {
+// drawSprite returns how many rows of the sprite were drawn on top of other pixels
+// already on the screen -- plus, with the Clip quirk, how many rows were cut off
+// the bottom, since that's what the SCHIP counted.
+func (c *Chip8) drawSprite(screen []byte, sprite []byte, x, y byte) (collisions int) {
 	/*
 	* I can't count how many times I've misunderstood this algorithm, so I've guzzled some coffee
 	* and written out exactly how and why it works.
@@ -427,6 +884,10 @@ func (c *Chip8) drawSprite(sprite []byte, x, y byte) bool {
 	*
 	* To write a sprite that is more than one pixel tall (what will they think up next??), we just repeat
 	* this procedure once for each row, just increasing the value of y for each row.
+	*
+	* WITH THE CLIP QUIRK
+	* Rows that would fall off the bottom of the screen aren't drawn, and neither is screenRightByte if
+	* it would have wrapped around to byte 0. (Which is much less work than wrapping, as it turns out.)
 	 */
 
 	// Wrap the coordinates around so that they land inside screen space.
@@ -438,37 +899,51 @@ func (c *Chip8) drawSprite(sprite []byte, x, y byte) bool {
 
 	// Write the sprite to video memory. If a sprite pixel is
 	// written over an active screen pixel, turn that pixel off
-	// (invert it) and set the 'occluded' flag to true.
-	var occluded = false
+	// (invert it) and count the row as occluded.
 	for i, spriteByte := range sprite {
+		if c.quirks.Clip && int(y)+i >= int(screenH) {
+			// only the SCHIP counted the rows it cut off; the COSMAC VIP just
+			// didn't draw them.
+			if c.quirks.CountCollisions {
+				collisions += len(sprite) - i
+			}
+			break
+		}
+		occluded := false
 		xOffset := uint16(x / 8)
 		yOffset := uint16((y + byte(i)) * 8)
 		if isByteAligned := x%8 == 0; isByteAligned {
-			offset := videoMemoryAddress + yOffset + xOffset
-			screenByte := c.memory[offset]
+			offset := yOffset + xOffset
+			screenByte := screen[offset]
 			// if spriteByte and screenByte have an active pixel in the same place,
 			// spriteByte occluded an active pixel.
 			occluded = spriteByte&screenByte != 0
-			c.memory[offset] = spriteByte ^ screenByte
+			screen[offset] = spriteByte ^ screenByte
 
 		} else {
-			spriteLeftByte := spriteByte >> x % 8
+			spriteLeftByte := spriteByte >> (x % 8)
 			spriteRightByte := spriteByte << (8 - (x % 8))
 
-			leftOffset := videoMemoryAddress + yOffset + xOffset
-			rightOffset := videoMemoryAddress + yOffset + ((xOffset + 1) % 8)
-			screenLeftByte := c.memory[leftOffset]
-			screenRightByte := c.memory[rightOffset]
+			leftOffset := yOffset + xOffset
+			rightOffset := yOffset + ((xOffset + 1) % 8)
+			if c.quirks.Clip && xOffset+1 >= 8 {
+				spriteRightByte = 0
+			}
+			screenLeftByte := screen[leftOffset]
+			screenRightByte := screen[rightOffset]
 			// if spriteByte and screenByte have an active pixel in the same place,
 			// spriteByte occluded an active pixel.
 			occluded = spriteLeftByte&screenLeftByte != 0 ||
 				spriteRightByte&screenRightByte != 0
-			c.memory[leftOffset] = spriteLeftByte ^ screenLeftByte
-			c.memory[rightOffset] = spriteRightByte ^ screenRightByte
+			screen[leftOffset] = spriteLeftByte ^ screenLeftByte
+			screen[rightOffset] = spriteRightByte ^ screenRightByte
+		}
+		if occluded {
+			collisions++
 		}
 	}
 
-	return occluded
+	return collisions
 }
 
 func (c *Chip8) stackPush(addr uint16) {
@@ -479,374 +954,396 @@ func (c *Chip8) stackPush(addr uint16) {
 	c.sp += 2
 }
 
+// stackPop takes the address on top of the stack off it. sp points at the free
+// slot above the top, so it comes down first, and then the address is under it.
+// There has to be something on the stack; RET checks.
 func (c *Chip8) stackPop() uint16 {
+	c.sp -= 2
 	high := c.memory[c.sp]
 	low := c.memory[c.sp+1]
-	if c.sp > stackAddress {
-		c.sp -= 2
-	}
 	return uint16(high)<<8 | uint16(low)
 }
 
-func (c *Chip8) exec(opcode uint16) {
+func (c *Chip8) exec(ins Instruction) error {
+	opcode := ins.Opcode
+	if c.tracing() {
+		c.logger.Printf("%04x: %s\n", opcode, ins)
+	}
 
-	// key:
-	// ------
-	// nnn - low 12 bits of opcode
-	// n - low 4 bits of opcode
-	// x - low 4 bits of opcode's high byte
-	// y - low 4 bits of opcode's low byte
-	// kk - opcode's low byte
+	// see Instruction for what x, y, n, nn and nnn are.
+	x, y := ins.X, ins.Y
 
-	switch first := (opcode & 0xf000) >> 12; first {
+	switch ins.Op {
 
-	case 0x0:
-		switch opcode {
-		// 00E0: CLS (clear)
-		case 0x00e0:
-			c.logger.Printf("%04x: CLS", opcode)
-			// zero out all bytes in video memory
-			for i := videoMemoryAddress; i <= highestMemoryAddress; i++ {
-				c.memory[i] = 0x0
+	// 00E0: CLS (clear)
+	case OpCLS:
+		// zero out all bytes in video memory, of every plane we're drawing on
+		for _, plane := range c.drawingPlanes() {
+			screen := c.plane(plane)
+			for i := range screen {
+				screen[i] = 0x0
 			}
-			c.pc += 2
+		}
+		c.refreshScreen()
+		c.pc += 2
 
-		// 00EE: RET (return)
-		case 0x00ee:
-			c.logger.Printf("%04x: RET", opcode)
-			c.pc = c.stackPop()
-			// we've gone back to the location of the original CALL instruction;
-			// proceed past it to the next instruction.
-			c.pc += 2
+	// 00Cn: SCD n (scroll the screen down n rows) -- and see Quirks.HalfScroll
+	case OpSCD:
+		c.scroll(0, int(ins.N))
+		c.pc += 2
 
-		default:
-			panic(fmt.Sprintf("Unrecognized opcode: %04x", opcode))
+	// 00FB: SCR (scroll the screen right 4 pixels)
+	case OpSCR:
+		c.scroll(scrollStep, 0)
+		c.pc += 2
+
+	// 00FC: SCL (scroll the screen left 4 pixels)
+	case OpSCL:
+		c.scroll(-scrollStep, 0)
+		c.pc += 2
+
+	// 00EE: RET (return)
+	case OpRET:
+		// returning with nothing to return to would pick an address out of
+		// whatever's below the stack, and carry on running the font.
+		if c.sp <= c.stackAddress() {
+			return fmt.Errorf("stack underflow: RET at %03x, with nothing on the stack", c.pc)
 		}
+		c.pc = c.stackPop()
+		// we've gone back to the location of the original CALL instruction;
+		// proceed past it to the next instruction.
+		c.pc += 2
 
 	// 1nnn: JP (jump) addr
-	case 0x1:
-		addr := opcode & 0x0fff
-		c.logger.Printf("%04x: JP %03x\n", opcode, addr)
-		c.pc = addr
+	case OpJP:
+		c.pc = ins.NNN
 
 	// 2nnn: CALL addr
-	case 0x2:
-		addr := opcode & 0x0fff
-		c.logger.Printf("%04x: CALL %03x\n", opcode, addr)
+	case OpCALL:
+		// a program that calls and never returns runs out of stack sooner or later;
+		// carrying on would scribble over the screen, and then off the end of memory.
+		if int(c.sp)+2 > int(c.videoMemoryAddress()) {
+			return fmt.Errorf("stack overflow: CALL %03x at %03x, with %d calls on the stack already", ins.NNN, c.pc, stackSize/2)
+		}
 		c.stackPush(c.pc)
-		c.pc = addr
+		c.pc = ins.NNN
 
 	// 3xkk: SE Vx byte (skip if equal)
-	case 0x3:
-		x := opcode & 0x0f00 >> 8
-		kk := opcode & 0x00ff
-		c.logger.Printf("%04x: SE V%x %02x\n", opcode, x, kk)
-		if c.v[x] == byte(kk) {
+	case OpSEImm:
+		if c.v[x] == ins.NN {
 			c.pc += 2
 		}
 		c.pc += 2
 
 	// 4xkk: SNE Vx byte (skip if not equal)
-	case 0x4:
-		x := opcode & 0x0f00 >> 8
-		kk := opcode & 0x00ff
-		c.logger.Printf("%04x: SNE V%x %02x\n", opcode, x, kk)
-		if c.v[x] != byte(kk) {
+	case OpSNEImm:
+		if c.v[x] != ins.NN {
 			c.pc += 2
 		}
 		c.pc += 2
 
 	// 5xy0: SE Vx Vy (skip if equal)
-	case 0x5:
-		x := opcode & 0x0f00 >> 8
-		y := opcode & 0x00f0 >> 4
-		c.logger.Printf("%04x: SE V%x V%x\n", opcode, x, y)
+	case OpSE:
 		if c.v[x] == c.v[y] {
 			c.pc += 2
 		}
 		c.pc += 2
 
 	// 6xkk: LD Vx byte (load value to register)
-	case 0x6:
-		x := opcode & 0x0f00 >> 8
-		kk := opcode & 0x00ff
-		c.logger.Printf("%04x: LD V%x %02x\n", opcode, x, kk)
-		c.v[x] = byte(kk)
+	case OpLDImm:
+		c.v[x] = ins.NN
 		c.pc += 2
 
 	// 7xkk: ADD Vx byte (add value to register)
-	case 0x7:
-		x := opcode & 0x0f00 >> 8
-		kk := opcode & 0x00ff
-		c.logger.Printf("%04x: ADD V%x %02x\n", opcode, x, kk)
-		c.v[x] = c.v[x] + byte(kk)
-		c.pc += 2
-
-	case 0x8:
-		switch last := opcode & 0x000f; last {
-
-		// 8xy0: LD Vx Vy (clone register)
-		case 0x0:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: LD V%x V%x\n", opcode, x, y)
-			c.v[x] = c.v[y]
-			c.pc += 2
+	case OpADDImm:
+		c.v[x] = c.v[x] + ins.NN
+		c.pc += 2
 
-		// 8xy1: OR Vx Vy (or Vx Vy, assign result to Vx)
-		case 0x1:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: OR V%x V%x\n", opcode, x, y)
-			c.v[x] = c.v[x] | c.v[y]
-			c.pc += 2
+	// 8xy0: LD Vx Vy (clone register)
+	case OpLD:
+		c.v[x] = c.v[y]
+		c.pc += 2
 
-		// 8xy2: AND Vx Vy (and Vx Vy, assign result to Vx)
-		case 0x2:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: OR V%x V%x\n", opcode, x, y)
-			c.v[x] = c.v[x] & c.v[y]
-			c.pc += 2
+	// 8xy1: OR Vx Vy (or Vx Vy, assign result to Vx) -- and for 8xy1-8xy3, see Quirks.ResetVF
+	case OpOR:
+		c.v[x] = c.v[x] | c.v[y]
+		if c.quirks.ResetVF {
+			c.v[0xf] = 0
+		}
+		c.pc += 2
 
-		// 8xy3: XOR Vx Vy (or Vx Vy, assign result to Vx)
-		case 0x3:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: XOR V%x V%x\n", opcode, x, y)
-			c.v[x] = c.v[x] ^ c.v[y]
-			c.pc += 2
+	// 8xy2: AND Vx Vy (and Vx Vy, assign result to Vx)
+	case OpAND:
+		c.v[x] = c.v[x] & c.v[y]
+		if c.quirks.ResetVF {
+			c.v[0xf] = 0
+		}
+		c.pc += 2
 
-		// 8xy4: ADD Vx Vy (add Vx Vy, assign result to Vx, set Vf if carry)
-		case 0x4:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: ADD V%x V%x\n", opcode, x, y)
-			if (x + y) > 255 {
-				c.v[0xf] = 1
-			}
-			c.v[x] = c.v[x] + c.v[y]
-			c.pc += 2
+	// 8xy3: XOR Vx Vy (xor Vx Vy, assign result to Vx)
+	case OpXOR:
+		c.v[x] = c.v[x] ^ c.v[y]
+		if c.quirks.ResetVF {
+			c.v[0xf] = 0
+		}
+		c.pc += 2
 
-		// 8xy5: SUB Vx Vy (sub Vx Vy, assign result to Vx)
-		case 0x5:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: SUB V%x V%x\n", opcode, x, y)
-			c.v[x] = c.v[x] - c.v[y]
-			c.pc += 2
+	// 8xy4: ADD Vx Vy (add Vx Vy, assign result to Vx, set VF=1 if it carried past 255 otherwise VF=0)
+	case OpADD:
+		sum := uint16(c.v[x]) + uint16(c.v[y])
+		c.setResultAndFlag(x, byte(sum), sum > 0xff)
+		c.pc += 2
 
-		// 8xy6: SHR Vx Vy (set VF=1 if the lowest bit of Vx is 1 otherwise set VF=0, then right shift Vx by 1)
-		case 0x6:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: SHR V%x V%x\n", opcode, x, y)
-			c.v[0xf] = c.v[x] & 0x01
-			c.v[x] = c.v[x] >> 1
-			c.pc += 2
+	// 8xy5: SUB Vx Vy (set VF=0 if it has to borrow [Vy > Vx] otherwise VF=1, sub Vx Vy, assign result to Vx)
+	case OpSUB:
+		c.setResultAndFlag(x, c.v[x]-c.v[y], c.v[x] >= c.v[y])
+		c.pc += 2
 
-		// 8xy7: SUBN Vx Vy (set VF=1 if Vy > Vx otherwise set VF=0, sub Vx Vy, assign result to Vx)
-		case 0x7:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: SUBN V%x V%x\n", opcode, x, y)
-			if c.v[y] > c.v[x] {
-				c.v[0xf] = 1
-			} else {
-				c.v[0xf] = 0
-			}
-			c.v[x] = c.v[x] - c.v[y]
-			c.pc += 2
+	// 8xy6: SHR Vx Vy (set VF=1 if the lowest bit of Vx is 1 otherwise set VF=0, then right shift Vx by 1) -- but see Quirks.ShiftVy
+	// (with the ShiftVy quirk, it's Vy that gets shifted, into Vx.)
+	case OpSHR:
+		shifted := c.v[x]
+		if c.quirks.ShiftVy {
+			shifted = c.v[y]
+		}
+		c.setResultAndFlag(x, shifted>>1, shifted&0x01 != 0)
+		c.pc += 2
 
-		// 8xyE: SHL Vx Vy (set VF=1 if the highest bit of Vx is 1 otherwise set VF=0, then left shift Vx by 1)
-		case 0xE:
-			x := opcode & 0x0f00 >> 8
-			y := opcode & 0x00f0 >> 4
-			c.logger.Printf("%04x: SHL V%x V%x\n", opcode, x, y)
-			c.v[0xf] = c.v[x] & 0x80 // 128 in decimal, 1000 0000 in binary
-			c.v[x] = c.v[x] << 1
-			c.pc += 2
+	// 8xy7: SUBN Vx Vy (set VF=0 if it has to borrow [Vx > Vy] otherwise VF=1, sub Vy Vx, assign result to Vx)
+	case OpSUBN:
+		c.setResultAndFlag(x, c.v[y]-c.v[x], c.v[y] >= c.v[x])
+		c.pc += 2
 
-		default:
-			panic(fmt.Sprintf("Unrecognized opcode: %04x", opcode))
+	// 8xyE: SHL Vx Vy (set VF=1 if the highest bit of Vx is 1 otherwise set VF=0, then left shift Vx by 1) -- but see Quirks.ShiftVy
+	// (with the ShiftVy quirk, it's Vy that gets shifted, into Vx.)
+	case OpSHL:
+		shifted := c.v[x]
+		if c.quirks.ShiftVy {
+			shifted = c.v[y]
 		}
+		c.setResultAndFlag(x, shifted<<1, shifted&0x80 != 0) // 0x80 is 128 in decimal, 1000 0000 in binary
+		c.pc += 2
 
 	// 9xy0: SNE Vx Vy (skip next opcode if Vx != Vy)
-	case 0x9:
-		x := opcode & 0x0f00 >> 8
-		y := opcode & 0x00f0 >> 4
-		c.logger.Printf("%04x: SNE V%x V%x\n", opcode, x, y)
+	case OpSNE:
 		if c.v[x] != c.v[y] {
 			c.pc += 2
 		}
 		c.pc += 2
 
 	// Annn: LD I addr (set I=nnn)
-	case 0xA:
-		addr := opcode & 0x0fff
-		c.logger.Printf("%04x: LD I %03x\n", opcode, addr)
-		c.i = addr
+	case OpLDI:
+		c.i = ins.NNN
 		c.pc += 2
 
-	// Bnnn: JP V0 addr (jump to address nnn + v0, set PC=nnn + v0)
-	case 0xB:
-		addr := opcode & 0x0fff
-		c.logger.Printf("%04x: JP V0 %03x\n", opcode, addr)
-		c.pc = addr + uint16(c.v[0])
+	// Bnnn: JP V0 addr (jump to address nnn + v0, set PC=nnn + v0) -- but see Quirks.JumpVx
+	case OpJPV0:
+		offset := c.v[0]
+		if c.quirks.JumpVx {
+			offset = c.v[x]
+		}
+		c.pc = ins.NNN + uint16(offset)
 
 	// Cxkk: RND Vx byte (Vx = random byte and kk)
-	case 0xC:
-		x := opcode & 0x0f00 >> 8
-		kk := opcode & 0x00ff
-		c.logger.Printf("%04x: RND V%x %02x\n", opcode, x, kk)
-		// Read is exported function from math/rand -- loads random bytes into passed array.
-		rnd := byte(rand.Intn(256))
-		c.v[x] = rnd & byte(kk)
-		c.pc += 2
-
-	// Dxyn: DRW Vx Vy n (display n-byte sprite located at I at coordinates Vx,Vy, set VF=collision [if sprite is drawn on top of any active pixels])
-	case 0xD:
-		x := opcode & 0x0f00 >> 8
-		y := opcode & 0x00f0 >> 4
-		n := opcode & 0x000f
-		c.logger.Printf("%04x: DRW V%x V%x %x\n", opcode, x, y, n)
-		sprite := make([]byte, 0, 16)
-		for i := c.i; i < c.i+n; i++ {
-			sprite = append(sprite, c.memory[i])
-		}
-		occluded := c.drawSprite(sprite, c.v[x], c.v[y])
-		if occluded {
-			fmt.Printf("\n\n\n\nOccluded my duded\n\n\n\n")
+	case OpRND:
+		rnd := byte(c.rng.Uint64())
+		c.v[x] = rnd & ins.NN
+		c.pc += 2
+
+	// Dxyn: DRW Vx Vy n (display n-byte sprite located at I at coordinates Vx,Vy, set VF=collision [if any row of the sprite is drawn on top of any active pixels]) -- and see Quirks.CountCollisions
+	case OpDRW:
+		// with more than one plane to draw on, each plane gets its own sprite, one
+		// after the other in memory: plane 1's first, then plane 2's.
+		collisions := 0
+		addr := c.i
+		for _, plane := range c.drawingPlanes() {
+			sprite := make([]byte, 0, 16)
+			for i := uint16(0); i < uint16(ins.N); i++ {
+				sprite = append(sprite, c.readByte(addr))
+				addr++
+			}
+			collisions += c.drawSprite(c.plane(plane), sprite, c.v[x], c.v[y])
+		}
+		switch {
+		case c.quirks.CountCollisions:
+			c.v[0xf] = byte(collisions)
+		case collisions > 0:
 			c.v[0xf] = 1
-		} else {
+		default:
 			c.v[0xf] = 0
 		}
+		c.refreshScreen()
 		c.pc += 2
 
-	case 0xE:
-		switch lastTwo := opcode & 0x0ff; lastTwo {
-		// Ex9E: SKP Vx (skip next instruction if key with the value of Vx is currently pressed)
-		case 0x9E:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: SKP V%x\n", opcode, x)
-			key := KeyCode(c.v[x])
-			if c.input.Poll() == key {
-				c.pc += 2
-			}
+	// Ex9E: SKP Vx (skip next instruction if key with the value of Vx is currently pressed)
+	case OpSKP:
+		if c.keyDown(KeyCode(c.v[x])) {
 			c.pc += 2
+		}
+		c.pc += 2
 
-		// ExA1: SKNP Vx (skip next instruction if key with the value of Vx is currently not pressed)
-		case 0xA1:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: SKNP V%x\n", opcode, x)
-			key := KeyCode(c.v[x])
-			if c.input.Poll() != key {
-				c.pc += 2
-			}
+	// ExA1: SKNP Vx (skip next instruction if key with the value of Vx is currently not pressed)
+	case OpSKNP:
+		if !c.keyDown(KeyCode(c.v[x])) {
 			c.pc += 2
+		}
+		c.pc += 2
 
-		default:
-			panic(fmt.Sprintf("Unrecognized opcode: %04x", opcode))
+	// ExF2: SKP2 Vx (skip next instruction if key Vx is down on the second keypad)
+	case OpSKP2:
+		if c.keyDown2(KeyCode(c.v[x])) {
+			c.pc += 2
 		}
-	case 0xF:
-		switch lastTwo := opcode & 0x0ff; lastTwo {
+		c.pc += 2
 
-		// Fx07: LD Vx DT (set Vx=DT)
-		case 0x07:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD V%x DT\n", opcode, x)
-			c.v[x] = c.dt
+	// ExF5: SKNP2 Vx (skip next instruction if key Vx isn't down on the second keypad)
+	case OpSKNP2:
+		if !c.keyDown2(KeyCode(c.v[x])) {
 			c.pc += 2
+		}
+		c.pc += 2
 
-		// Fx0A: LD Vx K (wait for key press, store value of key press in Vx)
-		case 0x0a:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD V%x K\n", opcode, x)
-			if key := c.input.Poll(); key != KeyNone {
-				c.v[x] = byte(key)
+	// Fx01: PLANE x (draw on plane 1 if bit 0 of x is set, and on plane 2 if bit 1 is) -- see planes.go
+	case OpPLANE:
+		c.planes = x & 0x3
+		c.pc += 2
+
+	// Fx07: LD Vx DT (set Vx=DT)
+	case OpLDVxDT:
+		c.v[x] = c.dt
+		c.pc += 2
+
+	// Fx0A: LD Vx K (wait for key press, store value of key press in Vx)
+	case OpLDVxK:
+		if c.keyEvents != nil {
+			// wait for a key to go down from here on; one that's already down doesn't count.
+			if !c.waitingForKey {
+				c.waitingForKey, c.keyArrived = true, false
+			} else if c.keyArrived {
+				c.waitingForKey = false
+				c.v[x] = byte(c.arrivedKey)
 				c.pc += 2
 			}
-			// if no key is pressed, do NOT advance the
-			// program counter -- execute this same instruction next cycle.
-			// This effectively halts the interpreter until a key is pressed.
-
-		// Fx15: LD DT Vx (set DT=Vx)
-		case 0x15:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD DT V%x\n", opcode, x)
-			c.dt = c.v[x]
+		} else if key := c.input.Poll(); key != KeyNone {
+			c.v[x] = byte(key)
 			c.pc += 2
+		}
+		// if no key is pressed, do NOT advance the
+		// program counter -- execute this same instruction next cycle.
+		// This effectively halts the interpreter until a key is pressed.
 
-		// Fx18: LD ST Vx (set ST=Vx)
-		case 0x18:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD ST V%x\n", opcode, x)
-			c.st = c.v[x]
-			// tell the speaker to start making noise
-			c.speaker.StartSound()
-			c.pc += 2
+	// Fx15: LD DT Vx (set DT=Vx)
+	case OpLDDTVx:
+		c.dt = c.v[x]
+		c.pc += 2
 
-		// Fx1E: ADD I Vx (set I=I+Vx)
-		case 0x1E:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: ADD I V%x\n", opcode, x)
-			c.i = c.i + uint16(c.v[x])
-			c.pc += 2
+	// Fx18: LD ST Vx (set ST=Vx)
+	case OpLDSTVx:
+		// tell the speaker to start making noise (see timers.go)
+		c.startBeep(c.v[x])
+		c.pc += 2
 
-		// Fx29: LD F Vx (set I=memory address of sprite corresponding to digit in Vx)
-		case 0x29:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD F V%x\n", opcode, x)
-			digit := c.v[x]
-			// each sprite corresponds to one digit and is five bytes wide,
-			// and digits are stored in increasing order. So the sprite for '5'
-			// will start at five sets of bytes away from the starting address.
-			fontSpritesStartAddress := 0x00
-			spriteWidth := 5
-			offset := digit * byte(spriteWidth)
-			c.i = uint16(fontSpritesStartAddress) + uint16(offset)
-			c.pc += 2
+	// Fx1E: ADD I Vx (set I=I+Vx)
+	case OpADDI:
+		c.i = c.i + uint16(c.v[x])
+		c.pc += 2
 
-		// Fx33: LD B Vx (store binary converted decimal [BCD] representation of number in Vx in memory locations I(hundreds place), I+1(tens place), I+2(ones place)
-		case 0x33:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD B V%x\n", opcode, x)
-			// TODO implement
-			c.pc += 2
+	// Fx29: LD F Vx (set I=memory address of sprite corresponding to digit in Vx)
+	case OpLDF:
+		digit := c.v[x]
+		// each sprite corresponds to one digit and is five bytes wide,
+		// and digits are stored in increasing order. So the sprite for '5'
+		// will start at five sets of bytes away from the starting address.
+		spriteWidth := 5
+		offset := (digit & 0xf) * byte(spriteWidth)
+		c.i = smallFontAddress + uint16(offset)
+		c.pc += 2
 
-		// Fx55: LD I Vx (store registers V0 through Vx in memory starting at I)
-		case 0x55:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD I V%x\n", opcode, x)
-			for i := uint16(0); i < x; i++ {
-				c.memory[c.i+i] = c.v[i]
-			}
-			c.pc += 2
+	// Fx30: LD HF Vx (SCHIP: set I=memory address of the big ten-row sprite for the decimal digit in Vx)
+	case OpLDHF:
+		digit := c.v[x] % 10
+		c.i = largeFontAddress + uint16(digit)*10
+		c.pc += 2
 
-		// Fx65: LD Vx I (read values in memory starting at I into registers V0 through Vx)
-		case 0x65:
-			x := opcode & 0x0f00 >> 8
-			c.logger.Printf("%04x: LD V%x I\n", opcode, x)
-			for i := uint16(0); i < x; i++ {
-				c.v[i] = c.memory[c.i+i]
-			}
-			c.pc += 2
+	// Fx33: LD B Vx (store binary converted decimal [BCD] representation of number in Vx in memory locations I(hundreds place), I+1(tens place), I+2(ones place)
+	case OpLDB:
+		c.writeByte(c.i, c.v[x]/100)
+		c.writeByte(c.i+1, c.v[x]/10%10)
+		c.writeByte(c.i+2, c.v[x]%10)
+		c.pc += 2
 
-		default:
-			panic(fmt.Sprintf("Unrecognized opcode: %04x", opcode))
+	// Fx55: LD I Vx (store registers V0 through Vx in memory starting at I) -- and see Quirks.IncrementI
+	case OpStore:
+		for i := uint16(0); i <= uint16(x); i++ {
+			c.writeByte(c.i+i, c.v[i])
 		}
+		if c.quirks.IncrementI {
+			c.i += uint16(x) + 1
+		}
+		c.pc += 2
+
+	// Fx65: LD Vx I (read values in memory starting at I into registers V0 through Vx) -- and see Quirks.IncrementI
+	case OpLoad:
+		for i := uint16(0); i <= uint16(x); i++ {
+			c.v[i] = c.readByte(c.i + i)
+		}
+		if c.quirks.IncrementI {
+			c.i += uint16(x) + 1
+		}
+		c.pc += 2
+
+	// Fx75: LD R Vx (SCHIP: store registers V0 through Vx in the RPL user flags, x <= 7)
+	case OpStoreRPL:
+		c.storeRPLFlags(uint16(x))
+		c.pc += 2
+
+	// Fx85: LD Vx R (SCHIP: read the RPL user flags into registers V0 through Vx, x <= 7)
+	case OpLoadRPL:
+		c.loadRPLFlags(uint16(x))
+		c.pc += 2
 
 	default:
-		panic(fmt.Sprintf("Unrecognized opcode: %04x", opcode))
+		// maybe it's a host call (see hostcall.go), or one of a peripheral's (see peripheral.go).
+		if called, err := c.execHostCall(opcode); called {
+			if err != nil {
+				return err
+			}
+			c.pc += 2
+			return nil
+		}
+		if c.execPeripheral(opcode) {
+			c.pc += 2
+			return nil
+		}
+		return fmt.Errorf("unrecognized opcode %04x at %03x", opcode, c.pc)
 	}
+	return nil
+}
 
+// setResultAndFlag finishes off the arithmetic instructions (8xy4 through 8xyE),
+// which put their result in Vx and their carry, borrow or fallen-off bit in VF.
+// When x is F, one of them has to win: normally it's the flag, which is written
+// last, but with the FlagFirst quirk the result is. Either way the operands were
+// read before anything was written, so VF as Vy means what VF was beforehand.
+func (c *Chip8) setResultAndFlag(x, result byte, flag bool) {
+	var vf byte
+	if flag {
+		vf = 1
+	}
+	if c.quirks.FlagFirst {
+		c.v[0xf] = vf
+		c.v[x] = result
+		return
+	}
+	c.v[x] = result
+	c.v[0xf] = vf
 }
 
 func (c *Chip8) readOpcode(addr uint16) uint16 {
 	// the opcode we want to read is the next two bytes,
 	// stored big-endian.
-	high := c.memory[addr]
-	low := c.memory[addr+1]
+	high := c.memory[addr&c.highestMemoryAddress()]
+	low := c.memory[(addr+1)&c.highestMemoryAddress()]
 	// combine bytes as one uint16,
 	// keeping the big-endian representation
 	opcode := (uint16(high) << 8) | uint16(low)
@@ -11,3 +11,1808 @@ package cpu_test
 // Chip8.Load(program)
 // if the Chip8 is running, should have no effect
 // if the Chip8 is stopped, should prepare a Chip8 program such that
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// nullKeyboard is a keyboard nobody ever touches.
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+// nullSpeaker is a speaker that's unplugged.
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// newTestChip8 returns a Chip8 with program loaded, ready to Step through.
+func newTestChip8(t *testing.T, program []byte) *cpu.Chip8 {
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Chip8.SaveState / Chip8.LoadState
+// should restore registers and memory exactly
+// should refuse a state saved with a different ROM loaded
+// should say in the header which quirks it was saved with
+// should refuse a state that decompresses to more than any Chip8 has
+func TestSaveStateRoundTrip(t *testing.T) {
+	program := []byte{
+		0x60, 0x2a, // LD V0 2a
+		0xa1, 0x23, // LD I 123
+	}
+	c := newTestChip8(t, program)
+	quirks := cpu.Quirks{ShiftVy: true, Clip: true, HalfScroll: true}
+	c.SetQuirks(quirks)
+	c.Step()
+	c.Step()
+	var saved bytes.Buffer
+	if err := c.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestChip8(t, program)
+	if err := restored.LoadState(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	want, got := c.Snapshot(), restored.Snapshot()
+	if got.PC != want.PC || got.I != want.I || got.V != want.V || !bytes.Equal(got.Memory, want.Memory) {
+		t.Errorf("restored state differs from saved state:\nwant PC=%03x I=%03x V=%x\n got PC=%03x I=%03x V=%x",
+			want.PC, want.I, want.V, got.PC, got.I, got.V)
+	}
+
+	other := newTestChip8(t, []byte{0x00, 0xe0})
+	if err := other.LoadState(bytes.NewReader(saved.Bytes())); err != cpu.ErrWrongROM {
+		t.Errorf("loading a state from another ROM: got error %v, want ErrWrongROM", err)
+	}
+
+	header, err := cpu.ReadSavestateHeader(bytes.NewReader(saved.Bytes()))
+	if err != nil || header.Quirks != quirks {
+		t.Errorf("the header says the quirks were %v (err %v), want %v", header.Quirks, err, quirks)
+	}
+
+	// a few kilobytes that inflate to ten megabytes: the same header, and a bomb.
+	var bomb bytes.Buffer
+	zw := zlib.NewWriter(&bomb)
+	zw.Write(make([]byte, 10<<20))
+	zw.Close()
+	headerLength := 8 + int(binary.BigEndian.Uint16(saved.Bytes()[6:]))
+	var bombed bytes.Buffer
+	bombed.Write(saved.Bytes()[:headerLength])
+	binary.Write(&bombed, binary.BigEndian, uint32(bomb.Len()))
+	bombed.Write(bomb.Bytes())
+	if err := restored.LoadState(&bombed); err == nil || !strings.Contains(err.Error(), "bigger") {
+		t.Errorf("loading a zlib bomb: got error %v", err)
+	}
+}
+
+// Chip8.SaveState / Chip8.LoadState / NewChip8FromState
+// should save and load a state with the stack full
+func TestSaveStateFullStack(t *testing.T) {
+	program := []byte{
+		0x22, 0x00, // CALL 200
+	}
+	c := newTestChip8(t, program)
+	// 48 calls fill the stack right up to the screen.
+	for i := 0; i < 48; i++ {
+		if result := c.Step(); result.Err != nil {
+			t.Fatalf("call %d: %v", i+1, result.Err)
+		}
+	}
+	var saved bytes.Buffer
+	if err := c.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestChip8(t, program)
+	if err := restored.LoadState(&saved); err != nil {
+		t.Fatalf("loading a state with 48 calls on the stack: %v", err)
+	}
+	if got, want := restored.Snapshot().Stack, c.Snapshot().Stack; len(got) != 96 || !bytes.Equal(got, want) {
+		t.Errorf("restored stack is %x, want %x", got, want)
+	}
+	if result := restored.Step(); result.Err == nil {
+		t.Errorf("a 49th call, after loading the state, should overflow the stack")
+	}
+	rebuilt, err := cpu.NewChip8FromState(nullKeyboard{}, nullSpeaker{}, c.Snapshot())
+	if err != nil {
+		t.Fatalf("rebuilding a Chip8 with 48 calls on the stack: %v", err)
+	}
+	if got, want := rebuilt.Snapshot().Stack, c.Snapshot().Stack; !bytes.Equal(got, want) {
+		t.Errorf("rebuilt stack is %x, want %x", got, want)
+	}
+	data, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded cpu.Chip8State
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding a state with 48 calls on the stack: %v", err)
+	}
+	if got, want := decoded.Stack, c.Snapshot().Stack; !bytes.Equal(got, want) {
+		t.Errorf("decoded stack is %x, want %x", got, want)
+	}
+}
+
+// Chip8State JSON encoding / NewChip8FromState
+// should rebuild a Chip8 that matches the original
+// should leave out the memory diagram
+// should reject stack depths there's no room for
+func TestChip8StateJSONRoundTrip(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x2a, // LD V0 2a
+		0x22, 0x06, // CALL 206
+		0x00, 0x00,
+		0xa1, 0x23, // LD I 123
+	})
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	want := c.Snapshot()
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("memoryDiagram")) {
+		t.Errorf("the JSON has a memory diagram, and there's no such thing yet:\n%s", data)
+	}
+	var decoded cpu.Chip8State
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := cpu.NewChip8FromState(nullKeyboard{}, nullSpeaker{}, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := restored.Snapshot()
+	if got.PC != want.PC || got.I != want.I || got.V != want.V ||
+		!bytes.Equal(got.Memory, want.Memory) || !bytes.Equal(got.Stack, want.Stack) {
+		t.Errorf("restored state differs:\nwant PC=%03x I=%03x V=%x stack=%x\n got PC=%03x I=%03x V=%x stack=%x",
+			want.PC, want.I, want.V, want.Stack, got.PC, got.I, got.V, got.Stack)
+	}
+
+	// a stack deeper than there's room for is an error, even one so deep that
+	// where it would end wraps round past the end of memory.
+	for _, depth := range []int{49, 32767, 65535} {
+		bad := bytes.Replace(data, []byte(`"stackDepth":1`), []byte(fmt.Sprintf(`"stackDepth":%d`, depth)), 1)
+		if err := json.Unmarshal(bad, &decoded); err == nil {
+			t.Errorf("decoding a state with a stack %d deep didn't fail", depth)
+		}
+	}
+}
+
+// Diff
+// should report changed registers, memory and screen regions
+func TestDiff(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0xa0, 0x50, // LD I 050
+		0x22, 0x06, // CALL 206
+		0x00, 0x00,
+		0xd0, 0x05, // DRW V0 V0 5 (draws the '0' font sprite at 0,0)
+	})
+	c.Step()
+	before := c.Snapshot()
+	c.Step()
+	c.Step()
+	d := cpu.Diff(before, c.Snapshot())
+
+	if len(d.Screen) != 1 || d.Screen[0] != (cpu.ScreenRegion{X: 0, Y: 0, Width: 4, Height: 5}) {
+		t.Errorf("screen regions: got %+v, want one 4x5 region at 0,0", d.Screen)
+	}
+	if len(d.Memory) != 1 || d.Memory[0].Address != 0xea0 {
+		t.Errorf("memory changes: got %+v, want the return address pushed at 0xea0", d.Memory)
+	}
+	names := ""
+	for _, r := range d.Registers {
+		names += r.Name + " "
+	}
+	if names != "PC SP " {
+		t.Errorf("changed registers: got %q, want PC and SP\n%v", names, d)
+	}
+}
+
+// Diff
+// should compare states with different amounts of memory as far as they both go, and say so
+// should cope with empty states
+// should count pixels changed on the second plane as changed
+func TestDiffOddStates(t *testing.T) {
+	small := newTestChip8(t, []byte{0x60, 0x2a}).Snapshot()
+	big := cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.WithMemorySize(cpu.MemorySize64K))
+	if err := big.Load([]byte{0x60, 0x2b}); err != nil {
+		t.Fatal(err)
+	}
+	d := cpu.Diff(small, big.Snapshot())
+	if d.OldMemorySize != cpu.MemorySize4K || d.NewMemorySize != cpu.MemorySize64K {
+		t.Errorf("memory size: got %d -> %d, want 4K -> 64K", d.OldMemorySize, d.NewMemorySize)
+	}
+	if len(d.Memory) != 1 || d.Memory[0].Address != 0x201 {
+		t.Errorf("memory changes: got %+v, want just the byte at 201", d.Memory)
+	}
+	if d.Empty() || !strings.Contains(d.String(), "memory size: 4096 -> 65536") {
+		t.Errorf("the diff should say the memory size changed:\n%v", d)
+	}
+
+	if d := cpu.Diff(cpu.Chip8State{}, cpu.Chip8State{}); !d.Empty() {
+		t.Errorf("two empty states differ:\n%v", d)
+	}
+	if d := cpu.Diff(cpu.Chip8State{}, small); d.NewMemorySize != cpu.MemorySize4K {
+		t.Errorf("an empty state and a 4K one: got memory size %d -> %d", d.OldMemorySize, d.NewMemorySize)
+	}
+
+	drawn := small
+	drawn.Plane2[8] = 0x40
+	d = cpu.Diff(small, drawn)
+	if len(d.Screen) != 1 || d.Screen[0] != (cpu.ScreenRegion{X: 1, Y: 1, Width: 1, Height: 1}) {
+		t.Errorf("screen regions: got %+v, want the pixel at 1,1 on plane 2", d.Screen)
+	}
+}
+
+// Chip8.SetCheckpointing / Chip8.RollbackTo
+// should put the Chip8 back the way it was at the start of a checkpointed frame
+// should refuse to roll back past the oldest checkpoint
+func TestRollbackTo(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x70, 0x01, // ADD V0 01
+		0x12, 0x00, // JP 200
+	})
+	c.SetSpeed(cpu.FramesPerSecond) // one instruction per frame
+	c.SetCheckpointing(2, 3)
+	for c.FrameCount() < 8 {
+		c.Step()
+	}
+	state8 := c.Snapshot()
+	for c.FrameCount() < 10 {
+		c.Step()
+	}
+	if got, want := c.Checkpoints(), []uint64{6, 8, 10}; !equalFrames(got, want) {
+		t.Fatalf("checkpoints: got %v, want %v", got, want)
+	}
+
+	frame, err := c.RollbackTo(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame != 8 || c.FrameCount() != 8 {
+		t.Errorf("RollbackTo(9): rolled back to frame %d (frame count %d), want 8", frame, c.FrameCount())
+	}
+	if got := c.Snapshot(); got.PC != state8.PC || got.V != state8.V {
+		t.Errorf("after rollback, PC=%03x V0=%02x, want PC=%03x V0=%02x", got.PC, got.V[0], state8.PC, state8.V[0])
+	}
+	if got, want := c.Checkpoints(), []uint64{6, 8}; !equalFrames(got, want) {
+		t.Errorf("checkpoints after rollback: got %v, want %v", got, want)
+	}
+
+	if _, err := c.RollbackTo(5); err != cpu.ErrNoCheckpoint {
+		t.Errorf("RollbackTo(5): got error %v, want ErrNoCheckpoint", err)
+	}
+}
+
+// Chip8.RollbackTo
+// should play the frames after a checkpoint out the same again, given the same keys
+func TestRollbackReplay(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x62, 0x05, // LD V2 05
+		0xc0, 0xff, // RND V0 ff
+		0x81, 0x04, // ADD V1 V0
+		0xe2, 0x9e, // SKP V2
+		0x12, 0x02, // JP 202
+		0x73, 0x01, // ADD V3 01
+		0xf0, 0x15, // LD DT V0
+		0x12, 0x02, // JP 202
+	})
+	keys := make(eventKeyboard, 8)
+	c.ConnectKeyboard(keys)
+	c.SetSeed(1)
+	// a speed that isn't a multiple of 60, so frames don't all have the same number of instructions.
+	c.SetSpeed(700)
+	c.SetCheckpointing(1, 30)
+	// key 5 goes down on frame 8, before the checkpoint we go back to, and comes up on frame 13.
+	input := func(frame uint64) {
+		switch frame {
+		case 8:
+			keys <- cpu.KeyEvent{Code: 5, Pressed: true}
+		case 13:
+			keys <- cpu.KeyEvent{Code: 5, Pressed: false}
+		}
+	}
+	type frameState struct {
+		PC, I uint16
+		V     [16]byte
+		DT    byte
+	}
+	play := func(to uint64) map[uint64]frameState {
+		states := make(map[uint64]frameState)
+		for c.FrameCount() < to {
+			input(c.FrameCount())
+			if stats := c.FrameStep(); stats.Err != nil {
+				t.Fatal(stats.Err)
+			}
+			s := c.Snapshot()
+			states[c.FrameCount()] = frameState{s.PC, s.I, s.V, s.DT}
+		}
+		return states
+	}
+
+	first := play(20)
+	if frame, err := c.RollbackTo(10); err != nil || frame != 10 {
+		t.Fatalf("RollbackTo(10) = %d, %v", frame, err)
+	}
+	again := play(20)
+	for frame := uint64(11); frame <= 20; frame++ {
+		if again[frame] != first[frame] {
+			t.Errorf("frame %d played differently the second time:\nfirst  %+v\nsecond %+v", frame, first[frame], again[frame])
+		}
+	}
+	if first[20].V[3] == 0 {
+		t.Errorf("the key presses never reached the game")
+	}
+}
+
+func equalFrames(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LogExplanations
+// should log each instruction with a plain-English explanation of what it did
+func TestExplanations(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x62, 0x0c, // LD V2 0c
+		0x63, 0x05, // LD V3 05
+		0xd2, 0x35, // DRW V2 V3 5
+	})
+	var log bytes.Buffer
+	c.SetLogOutput(&log)
+	c.SetLogLevel(cpu.LogExplanations)
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	want := []string{
+		"200: LD V2,0x0c -- set V2 to 12",
+		"202: LD V3,0x05 -- set V3 to 5",
+		"204: DRW V2,V3,5 -- draw the 5-row sprite at I=0x000 at x=V2(12), y=V3(5); no collision",
+	}
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines of explanation, want %d:\n%s", len(lines), len(want), log.String())
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d: got %q, want it to end %q", i, line, want[i])
+		}
+	}
+}
+
+// Chip8.SetSlowMotion
+// should slow the Chip8 down without changing its speed
+// should keep the rate between 0.01 and 1
+func TestSlowMotion(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x12, 0x00, // JP 200
+	})
+	c.SetSpeed(600)
+	c.SetSlowMotion(0.1)
+	var executed int32
+	c.OnInstruction(func(pc, opcode uint16) {
+		atomic.AddInt32(&executed, 1)
+	})
+	go c.Resume()
+	time.Sleep(200 * time.Millisecond)
+	c.Halt()
+	c.Wait()
+	// 600 a second for 200ms is 120 instructions; a tenth of that is 12.
+	if n := atomic.LoadInt32(&executed); n > 40 {
+		t.Errorf("executed %d instructions in 200ms at 600/s in 0.1x slow motion, want about 12", n)
+	}
+	if c.Speed() != 600 {
+		t.Errorf("slow motion changed the speed to %d", c.Speed())
+	}
+
+	for rate, want := range map[float64]float64{0.25: 0.25, 0: 0.01, 3: 1} {
+		c.SetSlowMotion(rate)
+		if got := c.SlowMotion(); got != want {
+			t.Errorf("after SetSlowMotion(%v), SlowMotion() = %v, want %v", rate, got, want)
+		}
+	}
+}
+
+// TraceDraw
+// should work out exactly what DRW draws, wherever the sprite lands, and what it leaves in VF
+func TestTraceDraw(t *testing.T) {
+	program := []byte{
+		0xa2, 0x16, // LD I,sprite
+		0x60, 0x03, // LD V0,3
+		0x61, 0x1e, // LD V1,30
+		0xd0, 0x13, // DRW V0,V1,3 -- off the bottom, wrapping to the top
+		0x60, 0x3c, // LD V0,60
+		0xd0, 0x13, // DRW V0,V1,3 -- off the right, wrapping to the left
+		0x60, 0x08, // LD V0,8
+		0x61, 0x00, // LD V1,0
+		0xd0, 0x13, // DRW V0,V1,3 -- lined up with a byte
+		0x60, 0x09, // LD V0,9
+		0xd0, 0x13, // DRW V0,V1,3 -- right on top of the last one
+		0xff, 0x81, 0xff, // sprite
+	}
+	c := newTestChip8(t, program)
+	for draws := 0; draws < 4; {
+		before := c.Snapshot()
+		opcode := uint16(before.Memory[before.PC])<<8 | uint16(before.Memory[before.PC+1])
+		if opcode&0xf000 != 0xd000 {
+			c.Step()
+			continue
+		}
+		draws++
+		rows := cpu.TraceDraw(before, opcode)
+		c.Step()
+		after := c.Snapshot()
+
+		var want [256]byte
+		copy(want[:], before.VideoMemory)
+		for _, row := range rows {
+			copy(want[row.Y*8:], row.After[:])
+		}
+		if !bytes.Equal(want[:], after.VideoMemory) {
+			t.Errorf("draw %d: traced screen\n% x\ndoesn't match the drawn one\n% x", draws, want, after.VideoMemory)
+		}
+		collided := false
+		for _, row := range rows {
+			collided = collided || row.Collided
+		}
+		if collided != (after.V[0xf] == 1) {
+			t.Errorf("draw %d: traced collision %v, but VF is %d", draws, collided, after.V[0xf])
+		}
+	}
+}
+
+// Decode / Instruction.String
+// should take an opcode apart into the instruction and its pieces
+// should write it out the way the assembler reads it, or "???" if it isn't one
+func TestDecode(t *testing.T) {
+	ins := cpu.Decode(0xd235)
+	if ins.Op != cpu.OpDRW || ins.X != 2 || ins.Y != 3 || ins.N != 5 || ins.NN != 0x35 || ins.NNN != 0x235 {
+		t.Errorf("Decode(0xd235) = %+v", ins)
+	}
+	for opcode, want := range map[uint16]string{
+		0x00e0: "CLS",
+		0x1200: "JP 0x200",
+		0x3a05: "SE VA,0x05",
+		0x8ab4: "ADD VA,VB",
+		0xd235: "DRW V2,V3,5",
+		0xf355: "LD [I],V3",
+		0x5121: "???",
+		0xf0ff: "???",
+	} {
+		if got := cpu.Decode(opcode).String(); got != want {
+			t.Errorf("Decode(%04x).String() = %q, want %q", opcode, got, want)
+		}
+	}
+}
+
+// Chip8.Step
+// should return the instruction it ran, where it ran it, where the program goes next,
+// and what it changed
+// should go back after the CALL on a RET
+func TestStepResult(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x62, 0x0c, // LD V2 0c
+		0x22, 0x06, // CALL 206
+		0x00, 0x00,
+		0x00, 0xee, // RET
+	})
+	result := c.Step()
+	if result.Instruction.Op != cpu.OpLDImm || result.PC != 0x200 || result.NextPC != 0x202 {
+		t.Errorf("LD: got %s at %03x, next %03x", result.Instruction, result.PC, result.NextPC)
+	}
+	if got, want := result.Changes.String(), "PC: 0x200 -> 0x202\nV2: 0x00 -> 0x0c"; got != want {
+		t.Errorf("LD changed:\n%s\nwant:\n%s", got, want)
+	}
+	result = c.Step()
+	if result.Instruction.Op != cpu.OpCALL || result.NextPC != 0x206 {
+		t.Errorf("CALL: got %s, next %03x", result.Instruction, result.NextPC)
+	}
+	if len(result.Changes.Memory) != 1 || result.Changes.Memory[0].Address != 0xea0 {
+		t.Errorf("CALL should have pushed the return address onto the stack, but changed:\n%s", result.Changes)
+	}
+	result = c.Step()
+	if result.Instruction.Op != cpu.OpRET || result.PC != 0x206 || result.NextPC != 0x204 {
+		t.Errorf("RET: got %s at %03x, next %03x", result.Instruction, result.PC, result.NextPC)
+	}
+	if stack := c.Snapshot().Stack; len(stack) != 0 {
+		t.Errorf("RET should have emptied the stack, but it's % x", stack)
+	}
+}
+
+// Chip8.Run / Chip8.ResumeContext
+// should say the program finished when it runs into 0x0000
+// should say there was an error, and where, when it runs into something that isn't an instruction
+// should say it stopped at a breakpoint when a hook calls Break
+// should say it was cancelled when its context is
+func TestRunResult(t *testing.T) {
+	run := func(program []byte) cpu.RunResult {
+		c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+		c.SetLogLevel(cpu.LogNone)
+		c.SetTurbo(true)
+		return c.Run(program)
+	}
+	if result := run([]byte{0x60, 0x01}); result.Reason != cpu.StopFinished || result.PC != 0x202 {
+		t.Errorf("finishing: got %v at %03x", result.Reason, result.PC)
+	}
+	if result := run([]byte{0x60, 0x01, 0xf0, 0xff}); result.Reason != cpu.StopError || result.PC != 0x202 || result.Err == nil {
+		t.Errorf("crashing: got %v at %03x, err %v", result.Reason, result.PC, result.Err)
+	}
+
+	// a program that goes round in circles forever.
+	loop := []byte{0x70, 0x01, 0x12, 0x00}
+	c := newTestChip8(t, loop)
+	c.SetTurbo(true)
+	c.OnInstruction(func(pc, opcode uint16) {
+		if opcode == 0x1200 {
+			c.Break()
+		}
+	})
+	if result := c.Resume(); result.Reason != cpu.StopBreakpoint || result.PC != 0x200 {
+		t.Errorf("breaking: got %v at %03x", result.Reason, result.PC)
+	}
+
+	c = newTestChip8(t, loop)
+	c.SetTurbo(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if result := c.ResumeContext(ctx); result.Reason != cpu.StopCancelled || result.Err != context.DeadlineExceeded {
+		t.Errorf("cancelling: got %v, err %v", result.Reason, result.Err)
+	}
+}
+
+// Chip8.ReadVideoMemory / Chip8.ReadScreen
+// should show what's on the screen right now, packed and unpacked
+func TestReadScreen(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x09, // LD V0 09
+		0x61, 0x02, // LD V1 02
+		0xa0, 0x50, // LD I 050: the font's 0 glyph
+		0xd0, 0x11, // DRW V0 V1 1: the top row of the 0 glyph, 11110000
+	})
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+	videoMemory := c.ReadVideoMemory()
+	// x=9 is one pixel into the second byte of the row, so the 1111 lands on pixels 9-12.
+	if got := videoMemory[2*8+1]; got != 0x78 {
+		t.Errorf("video memory byte for x=8..15, y=2: got %08b, want %08b", got, 0x78)
+	}
+	screen := c.ReadScreen()
+	for x := 0; x < 64; x++ {
+		if want := x >= 9 && x <= 12; screen[2][x] != want {
+			t.Errorf("pixel %d,2: got %v, want %v", x, screen[2][x], want)
+		}
+	}
+}
+
+// Chip8.ReadMemory / Chip8.WriteMemory / Chip8.SetWriteProtection
+// should read back what was written
+// should refuse to go past the end of memory
+// should refuse to write over the font while write protection is on
+func TestReadWriteMemory(t *testing.T) {
+	c := newTestChip8(t, []byte{0x00, 0xe0})
+	if err := c.WriteMemory(0x300, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.ReadMemory(0x300, 3); err != nil || !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("read back % x, %v", got, err)
+	}
+	if _, err := c.ReadMemory(0xffe, 3); err == nil {
+		t.Error("reading past the end of memory should fail")
+	}
+	if err := c.WriteMemory(0xfff, []byte{1, 2}); err == nil {
+		t.Error("writing past the end of memory should fail")
+	}
+	c.SetWriteProtection(true)
+	if err := c.WriteMemory(0x1ff, []byte{0xff, 0xff}); err == nil {
+		t.Error("writing to 0x1ff should fail with write protection on")
+	}
+	if err := c.WriteMemory(0x200, []byte{0xff}); err != nil {
+		t.Errorf("writing to 0x200 should be fine with write protection on: %v", err)
+	}
+}
+
+// Chip8.GetRegister / Chip8.SetRegister
+// should read back what was set
+// should refuse values that don't fit the register
+func TestSetRegister(t *testing.T) {
+	c := newTestChip8(t, []byte{0x00, 0xe0})
+	for _, set := range []struct {
+		r     cpu.Register
+		value uint16
+	}{{cpu.V3, 0x2a}, {cpu.VF, 0xff}, {cpu.I, 0x345}, {cpu.PC, 0x202}, {cpu.SP, 0xea2}, {cpu.DT, 60}} {
+		if err := c.SetRegister(set.r, set.value); err != nil {
+			t.Errorf("setting %v to %#x: %v", set.r, set.value, err)
+		}
+		if got, err := c.GetRegister(set.r); got != set.value || err != nil {
+			t.Errorf("%v: got %#x, %v, want %#x", set.r, got, err, set.value)
+		}
+	}
+	if state := c.Snapshot(); state.V[3] != 0x2a || state.I != 0x345 || len(state.Stack) != 2 {
+		t.Errorf("the snapshot doesn't agree: V3=%#x I=%#x stack=% x", state.V[3], state.I, state.Stack)
+	}
+	for _, bad := range []struct {
+		r     cpu.Register
+		value uint16
+	}{{cpu.V0, 0x100}, {cpu.PC, 0x1000}, {cpu.SP, 0xea1}, {cpu.SP, 0x200}} {
+		if err := c.SetRegister(bad.r, bad.value); err == nil {
+			t.Errorf("setting %v to %#x should fail", bad.r, bad.value)
+		}
+	}
+}
+
+// Frame
+// should convert to a matrix of pixels and back without losing anything
+// should unpack to a byte a pixel, top row first
+func TestFrame(t *testing.T) {
+	var frame cpu.Frame
+	frame.SetPixel(0, 0, true)
+	frame.SetPixel(9, 2, true)
+	frame.SetPixel(63, 31, true)
+	if frame[0] != 0x80 || frame[2*8+1] != 0x40 || frame[255] != 0x01 {
+		t.Errorf("SetPixel put the pixels in the wrong bits: % x", frame)
+	}
+	matrix := frame.Matrix()
+	if !matrix[2][9] || matrix[2][8] {
+		t.Errorf("Matrix: pixel 9,2 should be the only one on in its row")
+	}
+	if back := cpu.FrameFromMatrix(matrix); back != frame {
+		t.Errorf("FrameFromMatrix(Matrix()) = % x, want % x", back, frame)
+	}
+	pixels := frame.Pixels()
+	if pixels[0] != 0xff || pixels[2*64+9] != 0xff || pixels[2*64+8] != 0 || pixels[len(pixels)-1] != 0xff {
+		t.Errorf("Pixels put the pixels in the wrong places")
+	}
+}
+
+// BufferedFrames / PolledFrames
+// should send every frame down Frames, in order, dropping the oldest once it's full
+// should never say a frame is ready when frames are polled for
+func TestFrameDelivery(t *testing.T) {
+	// three CLSes and a DRW of the font's 0: four frames, on top of the one Load sends.
+	program := []byte{0x00, 0xe0, 0x00, 0xe0, 0x00, 0xe0, 0xa0, 0x50, 0xd0, 0x05}
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.BufferedFrames(3))
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c.Step()
+	}
+	if got := len(c.Frames()); got != 3 {
+		t.Fatalf("got %d frames waiting, want 3", got)
+	}
+	var last cpu.Frame
+	for i := 0; i < 3; i++ {
+		last = <-c.Frames()
+	}
+	if last != c.Frame() || last == (cpu.Frame{}) {
+		t.Error("the last frame down the channel should be the 0 that DRW drew")
+	}
+
+	c = cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.PolledFrames())
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	c.Step()
+	select {
+	case <-c.FrameReady():
+		t.Error("FrameReady fired with PolledFrames")
+	default:
+	}
+	if c.Frames() != nil {
+		t.Error("Frames should be nil without BufferedFrames")
+	}
+}
+
+// countingSpeaker counts how many times it's been told to start and stop.
+type countingSpeaker struct{ starts, stops int }
+
+func (s *countingSpeaker) StartSound() { s.starts++ }
+func (s *countingSpeaker) StopSound()  { s.stops++ }
+
+// heldKeyboard always has the same key held down.
+type heldKeyboard cpu.KeyCode
+
+func (k heldKeyboard) Poll() cpu.KeyCode { return cpu.KeyCode(k) }
+
+// Chip8.ConnectKeyboard / Chip8.ConnectSpeaker
+// should hand a beep that's going over to the new speaker
+// should have the next instruction read the new keyboard
+func TestConnectPeripherals(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x10, // LD V0 10
+		0xf0, 0x18, // LD ST V0
+		0xf1, 0x0a, // LD V1 K
+	})
+	old, replacement := new(countingSpeaker), new(countingSpeaker)
+	c.ConnectSpeaker(old)
+	c.Step()
+	c.Step()
+	c.ConnectSpeaker(replacement)
+	if old.starts != 1 || old.stops != 1 || replacement.starts != 1 {
+		t.Errorf("old speaker started %d and stopped %d times, new one started %d times; want 1, 1, 1", old.starts, old.stops, replacement.starts)
+	}
+	c.ConnectKeyboard(heldKeyboard(cpu.KeyB))
+	c.Step()
+	if v1 := c.Snapshot().V[1]; v1 != byte(cpu.KeyB) {
+		t.Errorf("V1 = %x, want the b held on the new keyboard", v1)
+	}
+	// unplugging them shouldn't crash anything.
+	c.ConnectKeyboard(nil)
+	c.ConnectSpeaker(nil)
+}
+
+// Chip8.ConnectDisplay
+// should start a display off with the screen as it is
+// should give every connected display every frame, without taking them from the others
+// should stop sending frames to a display once it's disconnected
+func TestConnectDisplay(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0xa0, 0x50, // LD I 050
+		0xd0, 0x05, // DRW V0 V0 5
+		0x00, 0xe0, // CLS
+	})
+	c.Step()
+	first := c.ConnectDisplay()
+	c.Step()
+	second := c.ConnectDisplay()
+	for _, d := range []*cpu.DisplayFeed{first, second} {
+		select {
+		case <-d.FrameReady():
+		default:
+			t.Fatal("a display has no frame waiting")
+		}
+		if frame := d.Frame(); !frame.Pixel(0, 0) {
+			t.Error("a display didn't get the frame with the 0 on it")
+		}
+	}
+	if frame := c.Frame(); !frame.Pixel(0, 0) {
+		t.Error("the displays took the frame from the Chip8's own display")
+	}
+
+	first.Disconnect()
+	c.Step()
+	select {
+	case <-first.FrameReady():
+		t.Error("a display got a frame after it disconnected")
+	default:
+	}
+	if frame := second.Frame(); frame.Pixel(0, 0) {
+		t.Error("the display that's still connected didn't get the cleared screen")
+	}
+}
+
+// eventKeyboard is a keyboard that sends key events.
+type eventKeyboard chan cpu.KeyEvent
+
+func (k eventKeyboard) Poll() cpu.KeyCode              { return cpu.KeyNone }
+func (k eventKeyboard) KeyEvents() <-chan cpu.KeyEvent { return k }
+
+// KeyEventSource
+// should let SKP see every key that's down, not just one
+// should have LD Vx,K wait for a key to go down, ignoring one that was down already
+func TestKeyEvents(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x03, // LD V0 3
+		0xe0, 0x9e, // SKP V0
+		0x00, 0xe0, // CLS (skipped)
+		0xf1, 0x0a, // LD V1 K
+	})
+	keys := make(eventKeyboard, 8)
+	c.ConnectKeyboard(keys)
+	keys <- cpu.KeyEvent{Code: 0x2, Pressed: true}
+	keys <- cpu.KeyEvent{Code: 0x3, Pressed: true}
+	c.Step()
+	if result := c.Step(); result.NextPC != 0x206 {
+		t.Fatalf("SKP V0 should have skipped with keys 2 and 3 down, but went to %03x", result.NextPC)
+	}
+	for i := 0; i < 3; i++ {
+		if result := c.Step(); result.NextPC != 0x206 {
+			t.Fatalf("LD V1,K shouldn't take the keys that were already down")
+		}
+	}
+	keys <- cpu.KeyEvent{Code: 0xa, Pressed: true}
+	if result := c.Step(); result.NextPC != 0x208 {
+		t.Fatalf("LD V1,K should have taken key a as soon as it went down")
+	}
+	if v1 := c.Snapshot().V[1]; v1 != 0xa {
+		t.Errorf("V1 = %x, want key a", v1)
+	}
+}
+
+// ShiftVy
+// should shift Vy into Vx with the ShiftVy quirk, and Vx in place without it
+func TestShiftQuirk(t *testing.T) {
+	program := []byte{
+		0x60, 0x01, // LD V0 1
+		0x61, 0x81, // LD V1 81
+		0x80, 0x16, // SHR V0 V1
+	}
+	c := newTestChip8(t, program)
+	c.Step()
+	c.Step()
+	c.Step()
+	if s := c.Snapshot(); s.V[0] != 0x00 || s.V[0xf] != 1 {
+		t.Errorf("without the quirk, SHR V0 should leave V0=0 and VF=1, got V0=%x VF=%x", s.V[0], s.V[0xf])
+	}
+
+	c = newTestChip8(t, program)
+	c.SetQuirks(cpu.Quirks{ShiftVy: true})
+	c.Step()
+	c.Step()
+	c.Step()
+	if s := c.Snapshot(); s.V[0] != 0x40 || s.V[0xf] != 1 {
+		t.Errorf("with ShiftVy, SHR V0 V1 should leave V0=40 and VF=1, got V0=%x VF=%x", s.V[0], s.V[0xf])
+	}
+
+	q, err := cpu.ParseQuirks("shift-vy")
+	if err != nil || !q.ShiftVy || q.String() != "shift-vy" {
+		t.Errorf("ParseQuirks(shift-vy) = %v, %v", q, err)
+	}
+	if _, err := cpu.ParseQuirks("shift-vx"); err == nil {
+		t.Errorf("ParseQuirks should refuse quirks that don't exist")
+	}
+}
+
+// IncrementI
+// should move I past the registers after Fx55 and Fx65 with the quirk, and leave it alone without
+func TestIncrementIQuirk(t *testing.T) {
+	program := []byte{
+		0xa3, 0x00, // LD I 300
+		0xf2, 0x55, // LD [I] V2
+		0xf1, 0x65, // LD V1 [I]
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{IncrementI: quirk})
+		c.Step()
+		c.Step()
+		want := uint16(0x300)
+		if quirk {
+			want = 0x303
+		}
+		if i := c.Snapshot().I; i != want {
+			t.Errorf("with IncrementI %v, I = %03x after LD [I] V2, want %03x", quirk, i, want)
+		}
+		c.Step()
+		if quirk {
+			want = 0x305
+		}
+		if i := c.Snapshot().I; i != want {
+			t.Errorf("with IncrementI %v, I = %03x after LD V1 [I], want %03x", quirk, i, want)
+		}
+	}
+}
+
+// JumpVx
+// should have Bxnn add Vx with the quirk, and V0 without it
+func TestJumpVxQuirk(t *testing.T) {
+	program := []byte{
+		0x60, 0x02, // LD V0 2
+		0x62, 0x04, // LD V2 4
+		0xb2, 0x10, // JP V0 210
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{JumpVx: quirk})
+		c.Step()
+		c.Step()
+		want := uint16(0x212)
+		if quirk {
+			want = 0x214
+		}
+		if result := c.Step(); result.NextPC != want {
+			t.Errorf("with JumpVx %v, B210 jumped to %03x, want %03x", quirk, result.NextPC, want)
+		}
+	}
+}
+
+// Clip
+// should cut off sprites at the right and bottom edges with the quirk, and wrap them without it
+// should only count the rows it cuts off as collisions with CountCollisions
+func TestClipQuirk(t *testing.T) {
+	program := []byte{
+		0x60, 0x3c, // LD V0 60
+		0x61, 0x1f, // LD V1 31
+		0xa2, 0x0a, // LD I 20a
+		0xd0, 0x12, // DRW V0 V1 2
+		0x12, 0x08, // JP 208
+		0xff, 0xff, // the sprite: two rows of 8 pixels
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{Clip: quirk})
+		for i := 0; i < 4; i++ {
+			c.Step()
+		}
+		screen := c.ReadScreen()
+		if !screen[31][63] {
+			t.Errorf("with Clip %v, the corner of the sprite that's on screen wasn't drawn", quirk)
+		}
+		if wrapped := screen[31][0] || screen[0][63] || screen[0][0]; wrapped == quirk {
+			t.Errorf("with Clip %v, the sprite wrapped: %v", quirk, wrapped)
+		}
+		if vf := c.Snapshot().V[0xf]; vf != 0 {
+			t.Errorf("with Clip %v, drawing on an empty screen set VF to %d", quirk, vf)
+		}
+	}
+
+	c := newTestChip8(t, program)
+	c.SetQuirks(cpu.Quirks{Clip: true, CountCollisions: true})
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+	if vf := c.Snapshot().V[0xf]; vf != 1 {
+		t.Errorf("with Clip and CountCollisions, VF = %d after cutting a row off, want 1", vf)
+	}
+}
+
+// ResetVF
+// should have OR, AND and XOR clear VF with the quirk, and leave it alone without
+func TestResetVFQuirk(t *testing.T) {
+	for _, op := range []byte{0x1, 0x2, 0x3} {
+		program := []byte{
+			0x6f, 0x07, // LD VF 7
+			0x80, 0x10 | op, // OR, AND or XOR V0 V1
+		}
+		for _, quirk := range []bool{false, true} {
+			c := newTestChip8(t, program)
+			c.SetQuirks(cpu.Quirks{ResetVF: quirk})
+			c.Step()
+			c.Step()
+			want := byte(7)
+			if quirk {
+				want = 0
+			}
+			if vf := c.Snapshot().V[0xf]; vf != want {
+				t.Errorf("with ResetVF %v, VF = %d after 801%x, want %d", quirk, vf, op, want)
+			}
+		}
+	}
+}
+
+// CountCollisions
+// should set VF to 1 if any row collided, even if the last one didn't
+// should set VF to how many rows collided with the quirk
+func TestCollisions(t *testing.T) {
+	program := []byte{
+		0xa2, 0x08, // LD I 208
+		0xd0, 0x03, // DRW V0 V0 3
+		0xd0, 0x03, // DRW V0 V0 3
+		0x12, 0x06, // JP 206
+		0xff, 0xff, 0x00, // the sprite: two full rows, then an empty one
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{CountCollisions: quirk})
+		c.Step()
+		c.Step()
+		if vf := c.Snapshot().V[0xf]; vf != 0 {
+			t.Errorf("with CountCollisions %v, drawing on an empty screen set VF to %d", quirk, vf)
+		}
+		c.Step()
+		want := byte(1)
+		if quirk {
+			want = 2
+		}
+		if vf := c.Snapshot().V[0xf]; vf != want {
+			t.Errorf("with CountCollisions %v, VF = %d after drawing over the sprite, want %d", quirk, vf, want)
+		}
+	}
+}
+
+// 8xy4-8xyE
+// should set VF from the values in the registers: carry, no borrow, or the bit shifted out
+// should leave the flag in VF when VF is Vx, or the result with the FlagFirst quirk
+func TestArithmeticFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        byte
+		vx, vy    byte
+		result, f byte
+	}{
+		{"ADD carries", 0x4, 0xf0, 0x20, 0x10, 1},
+		{"ADD doesn't carry", 0x4, 0x02, 0x03, 0x05, 0},
+		{"SUB doesn't borrow", 0x5, 0x05, 0x03, 0x02, 1},
+		{"SUB borrows", 0x5, 0x03, 0x05, 0xfe, 0},
+		{"SUBN doesn't borrow", 0x7, 0x03, 0x05, 0x02, 1},
+		{"SUBN borrows", 0x7, 0x05, 0x03, 0xfe, 0},
+		{"SHR", 0x6, 0x03, 0x00, 0x01, 1},
+		{"SHL", 0xe, 0x81, 0x00, 0x02, 1},
+	}
+	for _, test := range tests {
+		c := newTestChip8(t, []byte{
+			0x60, test.vx, // LD V0 vx
+			0x61, test.vy, // LD V1 vy
+			0x80, 0x10 | test.op, // V0 op V1
+		})
+		c.Step()
+		c.Step()
+		c.Step()
+		if s := c.Snapshot(); s.V[0] != test.result || s.V[0xf] != test.f {
+			t.Errorf("%s: V0=%02x VF=%d, want V0=%02x VF=%d", test.name, s.V[0], s.V[0xf], test.result, test.f)
+		}
+	}
+
+	program := []byte{
+		0x6f, 0xf0, // LD VF f0
+		0x61, 0x20, // LD V1 20
+		0x8f, 0x14, // ADD VF V1
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{FlagFirst: quirk})
+		c.Step()
+		c.Step()
+		c.Step()
+		want := byte(1)
+		if quirk {
+			want = 0x10
+		}
+		if vf := c.Snapshot().V[0xf]; vf != want {
+			t.Errorf("with FlagFirst %v, ADD VF V1 left VF=%02x, want %02x", quirk, vf, want)
+		}
+	}
+}
+
+// Timers
+// should count down sixty times a second of emulated time, whatever the speed
+func TestTimersAt60Hz(t *testing.T) {
+	for _, speed := range []int{30, 60, 500, 700} {
+		c := newTestChip8(t, []byte{
+			0x60, 0xff, // LD V0 ff
+			0xf0, 0x15, // LD DT V0
+			0x12, 0x04, // JP 204
+		})
+		c.SetSpeed(speed)
+		c.Step()
+		c.Step()
+		start, frames := c.Snapshot().DT, c.FrameCount()
+		// three seconds' worth of instructions
+		for i := 0; i < 3*speed; i++ {
+			c.Step()
+		}
+		if counted := start - c.Snapshot().DT; counted != 180 {
+			t.Errorf("at speed %d, DT counted down %d times in three seconds, want 180", speed, counted)
+		}
+		if counted := c.FrameCount() - frames; counted != 180 {
+			t.Errorf("at speed %d, %d frames went by in three seconds, want 180", speed, counted)
+		}
+	}
+}
+
+// MinimumBeep
+// should keep the speaker on for the minimum, but count the sound timer down on time
+func TestMinimumBeep(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x01, // LD V0 1
+		0xf0, 0x18, // LD ST V0
+		0x12, 0x04, // JP 204
+	})
+	speaker := new(countingSpeaker)
+	c.ConnectSpeaker(speaker)
+	c.SetMinimumBeep(100 * time.Millisecond)
+	c.Step()
+	c.Step()
+	c.Step()
+	if st := c.Snapshot().ST; st != 0 {
+		t.Errorf("ST = %d a sixtieth of a second after LD ST V0, want 0", st)
+	}
+	// 100ms is six sixtieths of a second, and one is gone already.
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+	if speaker.stops != 0 {
+		t.Errorf("the speaker stopped %d times before the minimum beep was up", speaker.stops)
+	}
+	c.Step()
+	if speaker.starts != 1 || speaker.stops != 1 {
+		t.Errorf("after the minimum beep, the speaker started %d times and stopped %d times, want once each", speaker.starts, speaker.stops)
+	}
+}
+
+// Chip8.Halt / Chip8.Resume
+// should freeze the timers and the frame count while the Chip8's stopped
+// should silence a beep while it's stopped, and pick it back up after
+func TestPauseFreezesTimers(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0xff, // LD V0 ff
+		0xf0, 0x15, // LD DT V0
+		0xf0, 0x18, // LD ST V0
+		0x12, 0x06, // JP 206
+	})
+	speaker := new(countingSpeaker)
+	c.ConnectSpeaker(speaker)
+	c.SetSpeed(600)
+	c.SetTurbo(true)
+	stopAt := uint64(10)
+	c.OnFrame(func(frame uint64) {
+		if frame == stopAt {
+			c.Halt()
+		}
+	})
+	c.Resume()
+	paused := c.Snapshot()
+	if speaker.starts != 1 || speaker.stops != 1 {
+		t.Errorf("pausing mid-beep, the speaker started %d times and stopped %d times, want once each", speaker.starts, speaker.stops)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if now := c.Snapshot(); now.DT != paused.DT || now.ST != paused.ST || c.FrameCount() != stopAt {
+		t.Errorf("while paused, DT went %d to %d and ST %d to %d, at frame %d", paused.DT, now.DT, paused.ST, now.ST, c.FrameCount())
+	}
+
+	stopAt = 20
+	c.Resume()
+	if speaker.starts != 2 || speaker.stops != 2 {
+		t.Errorf("after resuming and pausing again, the speaker started %d times and stopped %d times, want twice each", speaker.starts, speaker.stops)
+	}
+	if dt := c.Snapshot().DT; dt != paused.DT-10 {
+		t.Errorf("DT = %d ten frames after resuming, want %d", dt, paused.DT-10)
+	}
+}
+
+// Chip8.StepN / Chip8.RunFor
+// should execute just as many instructions as they're asked to, and stop
+// should stop short when the program finishes
+// should carry the part of an instruction RunFor hasn't time for over to the next
+func TestBoundedRuns(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x70, 0x01, // ADD V0 1
+		0x12, 0x00, // JP 200
+	})
+	c.SetSpeed(120)
+	stats := c.StepN(10)
+	if stats.Instructions != 10 || stats.Frames != 5 || stats.Reason != cpu.StopHalted || stats.Err != nil || c.IsRunning() {
+		t.Errorf("StepN(10) got %+v", stats)
+	}
+	if v0 := c.Snapshot().V[0]; v0 != 5 {
+		t.Errorf("V0 = %d after five times round the loop, want 5", v0)
+	}
+
+	c.SetSpeed(150)
+	var counts []int
+	for i := 0; i < 4; i++ {
+		counts = append(counts, c.RunFor(10*time.Millisecond).Instructions)
+	}
+	// a hundredth of a second is an instruction and a half at 150 a second.
+	if fmt.Sprint(counts) != "[1 2 1 2]" {
+		t.Errorf("RunFor(10ms) executed %v instructions, want [1 2 1 2]", counts)
+	}
+
+	c = newTestChip8(t, []byte{0x60, 0x01, 0x61, 0x02})
+	if stats := c.StepN(10); stats.Instructions != 2 || stats.Reason != cpu.StopFinished || stats.PC != 0x204 {
+		t.Errorf("StepN(10) past the end of the program got %+v", stats)
+	}
+	c = newTestChip8(t, []byte{0xff, 0xff})
+	if stats := c.RunFor(time.Second); stats.Instructions != 1 || stats.Reason != cpu.StopError || stats.Err == nil {
+		t.Errorf("RunFor into a bad instruction got %+v", stats)
+	}
+}
+
+// Chip8.RunUntil
+// should stop before the instruction at the address
+// should go round again if it's at the address already
+// should give up if the program never gets there
+func TestRunUntil(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x70, 0x01, // 200: ADD V0 1
+		0x30, 0x03, // 202: SE V0 3
+		0x12, 0x00, // 204: JP 200
+		0x12, 0x06, // 206: JP 206
+	})
+	stats := c.RunUntil(0x206)
+	if stats.Reason != cpu.StopBreakpoint || stats.PC != 0x206 || stats.Instructions != 8 {
+		t.Errorf("RunUntil(0x206) got %+v", stats)
+	}
+	if v0 := c.Snapshot().V[0]; v0 != 3 {
+		t.Errorf("V0 = %d when it got to 0x206, want 3", v0)
+	}
+	if stats := c.RunUntil(0x206); stats.Reason != cpu.StopBreakpoint || stats.Instructions != 1 {
+		t.Errorf("RunUntil(0x206) from 0x206 got %+v", stats)
+	}
+	if stats := c.RunUntil(0x200); stats.Reason != cpu.StopHalted || stats.Instructions != cpu.RunUntilLimit || stats.PC != 0x206 {
+		t.Errorf("RunUntil somewhere the program never goes got %+v", stats)
+	}
+}
+
+// Chip8.FrameStep
+// should run to the end of the frame, and no further
+// should read the keys held down, like running at full speed
+func TestFrameStep(t *testing.T) {
+	c := cpu.NewChip8(heldKeyboard(cpu.Key5), nullSpeaker{})
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load([]byte{
+		0xe0, 0x9e, // 200: SKP V0 (V0 is 0, and 5's held, so no skip)
+		0x71, 0x01, // 202: ADD V1 1
+		0x12, 0x00, // 204: JP 200
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetSpeed(700)
+	for frame := uint64(1); frame <= 3; frame++ {
+		stats := c.FrameStep()
+		if stats.Reason != cpu.StopHalted || stats.Frames != 1 || c.FrameCount() != frame || c.IsRunning() {
+			t.Errorf("FrameStep %d got %+v, at frame %d", frame, stats, c.FrameCount())
+		}
+		// 700 a second is eleven or twelve instructions a frame.
+		if stats.Instructions < 11 || stats.Instructions > 12 {
+			t.Errorf("FrameStep %d executed %d instructions, want 11 or 12", frame, stats.Instructions)
+		}
+	}
+	// three frames is 35 instructions: eleven times round, and SKP and ADD again.
+	if v1 := c.Snapshot().V[1]; v1 != 12 {
+		t.Errorf("V1 = %d after three frames, want 12", v1)
+	}
+
+	c.SetSpeed(30)
+	if stats := c.FrameStep(); stats.Instructions != 1 || stats.Frames != 2 {
+		t.Errorf("FrameStep at 30 instructions a second got %+v, want one instruction and two frames", stats)
+	}
+}
+
+// Chip8.SetFont
+// should point LD F,Vx and LD HF,Vx at the font's digits, wherever they are
+func TestFonts(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x0b, // LD V0 0b
+		0xf0, 0x29, // LD F V0
+		0x61, 0x07, // LD V1 07
+		0xf1, 0x30, // LD HF V1
+	})
+	vip, err := cpu.FontByName("vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetFont(vip)
+	c.Step()
+	c.Step()
+	glyph, err := c.ReadMemory(uint16(c.Snapshot().I), 5)
+	if err != nil || !bytes.Equal(glyph, vip.Glyph(0xb)) {
+		t.Errorf("LD F,V0 pointed I at %x, want the VIP's B, %x", glyph, vip.Glyph(0xb))
+	}
+	c.Step()
+	c.Step()
+	big, err := c.ReadMemory(uint16(c.Snapshot().I), 10)
+	if err != nil || !bytes.Equal(big, vip.Large[70:80]) {
+		t.Errorf("LD HF,V1 pointed I at %x, want the big 7, %x", big, vip.Large[70:80])
+	}
+	if _, err := cpu.FontByName("comic-sans"); err == nil {
+		t.Error("FontByName should refuse fonts that don't exist")
+	}
+}
+
+// Chip8.ConnectSecondKeyboard
+// should let SKP2 and SKNP2 see the second keypad, and only them
+func TestSecondKeypad(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x05, // LD V0 05
+		0xe0, 0xf2, // SKP2 V0
+		0x61, 0x01, // LD V1 01 (skipped, if 5's down on the second keypad)
+		0xe0, 0x9e, // SKP V0
+		0x62, 0x01, // LD V2 01 (not skipped: the first keypad has nothing down)
+		0xe0, 0xf5, // SKNP2 V0
+		0x63, 0x01, // LD V3 01 (not skipped, since 5's down)
+	})
+	c.ConnectSecondKeyboard(heldKeyboard(cpu.Key5))
+	for i := 0; i < 6; i++ {
+		c.Step()
+	}
+	if v := c.Snapshot().V; v[1] != 0 || v[2] != 1 || v[3] != 1 {
+		t.Errorf("with 5 down on the second keypad, V1-V3 = %d %d %d, want 0 1 1", v[1], v[2], v[3])
+	}
+}
+
+// Keyboard
+// should let SKP see key 0 held down on a keyboard that's polled, and not when nothing is
+func TestPolledKeyZero(t *testing.T) {
+	program := []byte{
+		0xe0, 0x9e, // SKP V0 (V0 is 0)
+		0x61, 0x01, // LD V1 01 (skipped, if 0's down)
+		0xf2, 0x0a, // LD V2 K
+	}
+	c := newTestChip8(t, program)
+	c.Step()
+	c.Step()
+	if v := c.Snapshot().V; v[1] != 1 {
+		t.Errorf("SKP V0 skipped with nothing held down")
+	}
+
+	held := newTestChip8(t, program)
+	held.ConnectKeyboard(heldKeyboard(cpu.Key0))
+	held.Step()
+	if pc := held.Snapshot().PC; pc != 0x204 {
+		t.Errorf("SKP V0 went to %03x with key 0 held down, want 204", pc)
+	}
+	held.Step()
+	if state := held.Snapshot(); state.PC != 0x206 || state.V[2] != 0 {
+		t.Errorf("LD V2,K with key 0 held down went to %03x, V2=%x; want 206 and 0", state.PC, state.V[2])
+	}
+}
+
+// Chip8.ConnectSecondKeyboard
+// should have SKP2 see nothing down with no second keypad plugged in, key 0 included
+// should have SKP2 see key 0, and every other key that's down, on a second keypad with events
+func TestSecondKeypadKeyZero(t *testing.T) {
+	program := []byte{
+		0xe0, 0xf2, // SKP2 V0 (V0 is 0)
+		0x61, 0x01, // LD V1 01 (skipped, if 0's down on the second keypad)
+		0xe2, 0xf2, // SKP2 V2 (V2 is 0 too)
+		0x63, 0x01, // LD V3 01
+	}
+	unplugged := newTestChip8(t, program)
+	unplugged.Step()
+	unplugged.Step()
+	if v := unplugged.Snapshot().V; v[1] != 1 {
+		t.Errorf("SKP2 V0 skipped with no second keypad plugged in")
+	}
+
+	c := newTestChip8(t, program)
+	keys := make(eventKeyboard, 8)
+	c.ConnectSecondKeyboard(keys)
+	keys <- cpu.KeyEvent{Code: 0x0, Pressed: true}
+	keys <- cpu.KeyEvent{Code: 0x7, Pressed: true}
+	c.Step()
+	if v := c.Snapshot().V; v[1] != 0 {
+		t.Errorf("SKP2 V0 didn't skip with 0 and 7 down on the second keypad")
+	}
+	if c.Snapshot().PC != 0x204 {
+		t.Fatalf("PC = %03x after the skip, want 204", c.Snapshot().PC)
+	}
+	keys <- cpu.KeyEvent{Code: 0x0, Pressed: false}
+	c.Step()
+	c.Step()
+	if v := c.Snapshot().V; v[3] != 1 {
+		t.Errorf("SKP2 V2 skipped after 0 came back up")
+	}
+}
+
+// cpu.WithMemorySize
+// should put the stack and the screen at the top of 64K, and load programs that don't fit in 4K
+// should record the size in snapshots, and in their JSON
+// should refuse savestates from a Chip8 with a different amount of memory
+func TestMemorySize(t *testing.T) {
+	program := make([]byte, 0x2000)
+	copy(program, []byte{
+		0x22, 0x04, // CALL 204
+		0x00, 0x00,
+		0x60, 0x2a, // LD V0 2a
+	})
+	small := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	if err := small.Load(program); err == nil {
+		t.Error("an 8K program loaded into 4K of memory")
+	}
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.WithMemorySize(cpu.MemorySize64K))
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	c.Step()
+	c.Step()
+	state := c.Snapshot()
+	if state.MemorySize != cpu.MemorySize64K || len(state.Memory) != cpu.MemorySize64K {
+		t.Errorf("the snapshot has %d bytes of memory and says %d, want 64K", len(state.Memory), state.MemorySize)
+	}
+	if !bytes.Equal(state.Stack, []byte{0x02, 0x00}) || !bytes.Equal(state.Memory[0xfea0:0xfea2], state.Stack) {
+		t.Errorf("after CALL, the stack is %x and memory at fea0 is %x, want the call's address at fea0", state.Stack, state.Memory[0xfea0:0xfea2])
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded cpu.Chip8State
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.MemorySize != cpu.MemorySize64K || !bytes.Equal(decoded.Memory, state.Memory) || !bytes.Equal(decoded.Stack, state.Stack) {
+		t.Error("the 64K snapshot didn't survive a trip through JSON")
+	}
+
+	// the same ROM, so it's only the memory that's different.
+	c.Load(program[:6])
+	small.Load(program[:6])
+	var saved bytes.Buffer
+	if err := c.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := small.LoadState(&saved); err == nil {
+		t.Error("a 4K Chip8 loaded a savestate with 64K of memory")
+	}
+}
+
+// Chip8.SetMemoryProtection
+// should leave protected memory alone, and report the write to the hook
+// should stop the Chip8 on a protected write, with Stop
+func TestMemoryProtection(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0xa0, 0x50, // LD I 050
+		0x60, 0xff, // LD V0 ff
+		0xf0, 0x55, // LD [I] V0
+		0xa2, 0x00, // LD I 200
+		0xf0, 0x55, // LD [I] V0
+	})
+	c.SetMemoryProtection(cpu.WriteProtection{Interpreter: true, Program: true})
+	var writes []cpu.ProtectedWrite
+	c.OnProtectedWrite(func(w cpu.ProtectedWrite) { writes = append(writes, w) })
+	font, _ := c.ReadMemory(0x050, 1)
+	for i := 0; i < 5; i++ {
+		if result := c.Step(); result.Err != nil {
+			t.Fatalf("a protected write stopped the Chip8 without Stop: %v", result.Err)
+		}
+	}
+	if got, _ := c.ReadMemory(0x050, 1); !bytes.Equal(got, font) {
+		t.Errorf("the font at 050 is %x after a protected write, want %x", got, font)
+	}
+	if got, _ := c.ReadMemory(0x200, 1); got[0] != 0xa0 {
+		t.Errorf("the program at 200 is %x after a protected write, want a0", got)
+	}
+	want := []cpu.ProtectedWrite{{PC: 0x204, Address: 0x050, Value: 0xff}, {PC: 0x208, Address: 0x200, Value: 0xff}}
+	if len(writes) != 2 || writes[0] != want[0] || writes[1] != want[1] {
+		t.Errorf("the hook heard about %+v, want %+v", writes, want)
+	}
+
+	c.SetMemoryProtection(cpu.WriteProtection{Program: true, Stop: true})
+	c.SetTurbo(true)
+	if result := c.Run([]byte{0x60, 0x01, 0xa2, 0x00, 0xf0, 0x55}); result.Reason != cpu.StopError || result.PC != 0x204 || result.Err == nil {
+		t.Errorf("writing over the program with Stop on: got %v at %03x, err %v", result.Reason, result.PC, result.Err)
+	}
+	if p, err := cpu.ParseWriteProtection("all,stop"); err != nil || p != (cpu.WriteProtection{Interpreter: true, Program: true, Stop: true}) {
+		t.Errorf("ParseWriteProtection(all,stop) = %v, %v", p, err)
+	}
+}
+
+// PLANE
+// should draw on the planes it picks, plane 2's sprite after plane 1's, and CLS only them
+// should keep plane 2 in savestates
+func TestPlanes(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0xa2, 0x10, // LD I 210
+		0xf3, 0x01, // PLANE 3
+		0xd0, 0x01, // DRW V0 V0 1
+		0xf2, 0x01, // PLANE 2
+		0x00, 0xe0, // CLS
+		0x12, 0x0a, // JP 20a
+		0x00, 0x00, 0x00, 0x00,
+		0xf0, 0x3c, // plane 1's sprite, then plane 2's
+	})
+	if got := cpu.Disassemble(0xf301); got != "PLANE 3" {
+		t.Errorf("Disassemble(f301) = %q, want PLANE 3", got)
+	}
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	frame := c.ColorFrame()
+	for x, want := range []int{1, 1, 3, 3, 2, 2, 0} {
+		if got := frame.Color(x, 0); got != want {
+			t.Errorf("after drawing on both planes, the pixel at %d,0 is color %d, want %d", x, got, want)
+		}
+	}
+	var saved bytes.Buffer
+	if err := c.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Step()
+	c.Step()
+	frame = c.ColorFrame()
+	if !frame.Monochrome() || frame[0][0] != 0xf0 {
+		t.Errorf("CLS on plane 2 left planes %x and %x, want f0 and nothing", frame[0][0], frame[1][0])
+	}
+	if err := c.LoadState(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if frame = c.ColorFrame(); frame[1][0] != 0x3c {
+		t.Errorf("plane 2 is %x after loading the savestate, want 3c", frame[1][0])
+	}
+}
+
+// SCD, SCR and SCL
+// should scroll the screen, losing what goes off the edge
+// should tell displays how far it scrolled, with FrameScroll
+// should scroll half as far with the HalfScroll quirk
+func TestScroll(t *testing.T) {
+	program := []byte{
+		0xa2, 0x0c, // LD I 20c
+		0xd0, 0x01, // DRW V0 V0 1
+		0x00, 0xfb, // SCR
+		0x00, 0xc2, // SCD 2
+		0x00, 0xfc, // SCL
+		0x12, 0x0a, // JP 20a
+		0xff,
+	}
+	for _, quirk := range []bool{false, true} {
+		c := newTestChip8(t, program)
+		c.SetQuirks(cpu.Quirks{HalfScroll: quirk})
+		c.Step()
+		c.Step()
+		c.Frame()
+		before := c.FrameScroll()
+		c.Step()
+		c.Step()
+		c.Step()
+		frame := c.Frame()
+		row, want := 2, []byte{0xff, 0x00}
+		if quirk {
+			row = 1
+		}
+		if got := frame[row*8 : row*8+2]; !bytes.Equal(got, want) {
+			t.Errorf("with HalfScroll %v, row %d is %x after scrolling, want %x", quirk, row, got, want)
+		}
+		if frame[0] != 0 {
+			t.Errorf("with HalfScroll %v, the top row is %x after scrolling down, want 0", quirk, frame[0])
+		}
+		if got := c.FrameScroll(); got.X != before.X || got.Y != before.Y+row {
+			t.Errorf("with HalfScroll %v, FrameScroll went from %v to %v, want %d rows down", quirk, before, got, row)
+		}
+	}
+
+	var f cpu.Frame
+	f[0] = 0xff
+	if f.Scroll(-64, 0); f != (cpu.Frame{}) {
+		t.Errorf("scrolling a whole screen's width left left %x behind", f[:8])
+	}
+}
+
+// registerFile is a peripheral that remembers what's written to it, and has an
+// instruction, 5xy1, that swaps Vx and Vy.
+type registerFile struct {
+	cells  map[uint16]byte
+	writes int
+}
+
+func (r *registerFile) Read(addr uint16) byte { return r.cells[addr] + 1 }
+func (r *registerFile) Write(addr uint16, value byte) {
+	r.cells[addr] = value
+	r.writes++
+}
+func (r *registerFile) Exec(opcode uint16, v *[16]byte) bool {
+	if opcode&0xf00f != 0x5001 {
+		return false
+	}
+	x, y := opcode>>8&0xf, opcode>>4&0xf
+	v[x], v[y] = v[y], v[x]
+	return true
+}
+
+// Chip8.AttachPeripheral
+// should send the program's reads and writes in its range to the peripheral
+// should offer it the opcodes the Chip8 doesn't know
+// should refuse overlapping ranges
+func TestPeripherals(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x05, // LD V0 05
+		0x61, 0x09, // LD V1 09
+		0xa8, 0x00, // LD I 800
+		0xf1, 0x55, // LD [I] V1
+		0xf1, 0x65, // LD V1 [I]
+		0x50, 0x11, // 5011: the peripheral's swap V0 V1
+	})
+	device := &registerFile{cells: map[uint16]byte{}}
+	if err := c.AttachPeripheral(0x800, 0x810, device); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AttachPeripheral(0x80f, 0x820, &registerFile{}); err == nil {
+		t.Error("AttachPeripheral should refuse a range that overlaps another peripheral")
+	}
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+	if device.writes != 2 || device.cells[0x800] != 5 || device.cells[0x801] != 9 {
+		t.Errorf("the peripheral got %d writes, %v, want V0 and V1 at 800 and 801", device.writes, device.cells)
+	}
+	if memory, _ := c.ReadMemory(0x800, 2); memory[0] != 0 || memory[1] != 0 {
+		t.Errorf("the writes went to memory too: %x", memory)
+	}
+	c.Step()
+	if v := c.Snapshot().V; v[0] != 6 || v[1] != 10 {
+		t.Errorf("reading the peripheral gave V0=%d V1=%d, want 6 and 10", v[0], v[1])
+	}
+	if result := c.Step(); result.Err != nil {
+		t.Fatalf("the peripheral's opcode: %v", result.Err)
+	}
+	if v := c.Snapshot().V; v[0] != 10 || v[1] != 6 {
+		t.Errorf("5011 should have swapped V0 and V1, got V0=%d V1=%d", v[0], v[1])
+	}
+
+	c.DetachPeripheral(device)
+	c.SetRegister(cpu.PC, 0x20a)
+	if result := c.Step(); result.Err == nil {
+		t.Error("5011 should be unrecognized again once the peripheral is detached")
+	}
+}
+
+// HostCalls
+// should let programs call Go functions with 0nnn, and only if they're allowed to
+func TestHostCalls(t *testing.T) {
+	program := []byte{
+		0x60, 0x02, // LD V0 02
+		0x61, 0x03, // LD V1 03
+		0x01, 0x23, // SYS 123: V0 = V0 * V1
+		0x04, 0x56, // SYS 456: fails
+	}
+	multiply := func(state *cpu.HostCallState) error {
+		state.V[0] *= state.V[1]
+		return nil
+	}
+	fail := func(state *cpu.HostCallState) error { return errors.New("out of cheese") }
+
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.HostCalls(map[uint16]cpu.HostCall{0x123: multiply, 0x456: fail}))
+	c.SetLogLevel(cpu.LogNone)
+	if err := c.Load(program); err != nil {
+		t.Fatal(err)
+	}
+	c.Step()
+	c.Step()
+	if result := c.Step(); result.Err != nil || c.Snapshot().V[0] != 6 {
+		t.Errorf("SYS 123 gave V0=%d, err %v; want 6", c.Snapshot().V[0], result.Err)
+	}
+	if result := c.Step(); result.Err == nil || !strings.Contains(result.Err.Error(), "out of cheese") {
+		t.Errorf("SYS 456 should have stopped with the call's error, got %v", result.Err)
+	}
+
+	c = newTestChip8(t, program)
+	c.Step()
+	c.Step()
+	if result := c.Step(); result.Err == nil {
+		t.Error("SYS 123 shouldn't do anything without HostCalls")
+	}
+}
+
+// memoryRPLFlags keeps RPL flags in memory, and remembers what was saved.
+type memoryRPLFlags struct {
+	mu    sync.Mutex
+	flags [8]byte
+	saves int
+}
+
+func (f *memoryRPLFlags) Load() [8]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flags
+}
+
+func (f *memoryRPLFlags) Save(flags [8]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flags = flags
+	f.saves++
+}
+
+// Fx75 / Fx85
+// should save V0 through Vx in the RPL flags, and bring them back
+// should stop at V7, however big x is
+// should load the flags from a connected RPLFlags, and save them there once the frame's over
+func TestRPLFlags(t *testing.T) {
+	c := newTestChip8(t, []byte{
+		0x60, 0x01, // LD V0 01
+		0x63, 0x04, // LD V3 04
+		0x67, 0x08, // LD V7 08
+		0x68, 0x09, // LD V8 09
+		0xfa, 0x75, // LD R VA
+		0x60, 0x00, // LD V0 00
+		0x63, 0x00, // LD V3 00
+		0x67, 0x00, // LD V7 00
+		0x68, 0x00, // LD V8 00
+		0xfa, 0x85, // LD VA R
+	})
+	for i := 0; i < 10; i++ {
+		c.Step()
+	}
+	if v := c.Snapshot().V; v[0] != 1 || v[3] != 4 || v[7] != 8 || v[8] != 0 {
+		t.Errorf("after saving and restoring the flags, V0=%d V3=%d V7=%d V8=%d; want 1, 4, 8 and 0", v[0], v[3], v[7], v[8])
+	}
+
+	storage := &memoryRPLFlags{flags: [8]byte{0x2a}}
+	c = newTestChip8(t, []byte{
+		0xf0, 0x85, // LD V0 R
+		0x70, 0x01, // ADD V0 01
+		0xf0, 0x75, // LD R V0
+		0x12, 0x06, // JP 206
+	})
+	c.ConnectRPLFlags(storage)
+	c.SetTurbo(true)
+	c.OnFrame(func(frame uint64) {
+		if frame == 10 {
+			c.Halt()
+		}
+	})
+	c.Resume()
+	if got := storage.Load(); got[0] != 0x2b || storage.saves != 1 {
+		t.Errorf("storage has %x, saved %d times; want 2b in the first flag, saved once", got, storage.saves)
+	}
+}
+
+// FuzzChip8 runs a program for a while, under some quirks, and checks that whatever
+// the program does, the Chip8 copes: no panics, an instruction that can't be
+// executed is an error that leaves the program counter on it, a RET goes back
+// to just after the CALL it's returning from, and a RET with nothing to return
+// to is a stack underflow.
+//
+// Its corpus, in testdata/fuzz/FuzzChip8, is every program that's ever caught the
+// Chip8 out, named for what it did, and it's run with the rest of the tests. When
+// `go test -fuzz FuzzChip8 ./cpu` finds a new one, it goes in there too; give it a
+// name that says what it was, and fix it.
+func FuzzChip8(f *testing.F) {
+	f.Add([]byte{0x12, 0x00}, uint8(0))
+	f.Fuzz(func(t *testing.T, program []byte, quirks uint8) {
+		c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{}, cpu.WithQuirks(cpu.Quirks{
+			ShiftVy:         quirks&0x01 != 0,
+			IncrementI:      quirks&0x02 != 0,
+			JumpVx:          quirks&0x04 != 0,
+			Clip:            quirks&0x08 != 0,
+			ResetVF:         quirks&0x10 != 0,
+			CountCollisions: quirks&0x20 != 0,
+			FlagFirst:       quirks&0x40 != 0,
+			HalfScroll:      quirks&0x80 != 0,
+		}))
+		c.SetLogLevel(cpu.LogNone)
+		c.SetSeed(1)
+		if err := c.Load(program); err != nil {
+			return
+		}
+		// calls keeps the return addresses the stack ought to have on it, for as
+		// long as nothing else has written to memory: a program that's written over
+		// its own stack can return wherever it likes.
+		var calls []uint16
+		scribbled := false
+		for i := 0; i < 500; i++ {
+			result := c.Step()
+			if result.Err != nil {
+				if result.NextPC != result.PC {
+					t.Errorf("%v, and the program counter moved from %03x to %03x", result.Err, result.PC, result.NextPC)
+				}
+				if underflow := strings.Contains(result.Err.Error(), "stack underflow"); result.Instruction.Op == cpu.OpRET && underflow != (len(calls) == 0) {
+					t.Errorf("RET at %03x with %d calls on the stack: %v", result.PC, len(calls), result.Err)
+				}
+				return
+			}
+			switch result.Instruction.Op {
+			case cpu.OpCALL:
+				calls = append(calls, result.PC+2)
+			case cpu.OpRET:
+				if len(calls) == 0 {
+					t.Fatalf("RET at %03x with nothing on the stack went to %03x, rather than failing with a stack underflow", result.PC, result.NextPC)
+				}
+				if want := calls[len(calls)-1]; !scribbled && result.NextPC != want {
+					t.Fatalf("RET at %03x went to %03x, not back after its CALL to %03x", result.PC, result.NextPC, want)
+				}
+				calls = calls[:len(calls)-1]
+			default:
+				if len(result.Changes.Memory) > 0 {
+					scribbled = true
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StateDiff describes everything that changed between two Chip8States:
+// which registers changed, which ranges of memory changed, and which parts of
+// the screen changed. It's the answer to "what did that last instruction just do?"
+type StateDiff struct {
+	Registers []RegisterChange
+	// Memory lists the changed ranges of memory outside of video memory,
+	// in increasing address order. Changes to the screen are in Screen instead.
+	Memory []MemoryChange
+	// OldMemorySize and NewMemorySize are how much memory the two states have, if
+	// it's different, and 0 if it's not. Memory then only covers the addresses
+	// below where either state's screen starts.
+	OldMemorySize, NewMemorySize int
+	// Screen lists the parts of the screen that changed, on either plane, from top to bottom.
+	Screen []ScreenRegion
+}
+
+// A RegisterChange is a register that has a different value in the second state.
+// Name is "PC", "I", "SP", "DT", "ST", or "V0" through "VF".
+type RegisterChange struct {
+	Name     string
+	Old, New uint16
+}
+
+// A MemoryChange is a run of consecutive bytes of memory that changed, starting at Address.
+type MemoryChange struct {
+	Address  uint16
+	Old, New []byte
+}
+
+// A ScreenRegion is a rectangle of the screen, in pixels, with 0,0 at the top-left.
+// Every row in the rectangle has at least one changed pixel.
+type ScreenRegion struct {
+	X, Y, Width, Height int
+}
+
+// Diff compares two snapshots of a Chip8 (usually the same Chip8, a moment apart)
+// and returns what changed from a to b.
+func Diff(a, b Chip8State) StateDiff {
+	var d StateDiff
+
+	addRegister := func(name string, old, new uint16) {
+		if old != new {
+			d.Registers = append(d.Registers, RegisterChange{name, old, new})
+		}
+	}
+	addRegister("PC", a.PC, b.PC)
+	addRegister("I", a.I, b.I)
+	addRegister("SP", a.stackPointer(), b.stackPointer())
+	addRegister("DT", uint16(a.DT), uint16(b.DT))
+	addRegister("ST", uint16(a.ST), uint16(b.ST))
+	for i := range a.V {
+		addRegister(fmt.Sprintf("V%X", i), uint16(a.V[i]), uint16(b.V[i]))
+	}
+
+	if len(a.Memory) != len(b.Memory) {
+		d.OldMemorySize, d.NewMemorySize = len(a.Memory), len(b.Memory)
+	}
+	// collect runs of changed bytes, stopping at the start of video memory -- or
+	// of memory the other state doesn't have.
+	video := a.videoAddress()
+	if v := b.videoAddress(); v < video {
+		video = v
+	}
+	for addr := 0; addr < video; addr++ {
+		if a.Memory[addr] == b.Memory[addr] {
+			continue
+		}
+		start := addr
+		for addr < video && a.Memory[addr] != b.Memory[addr] {
+			addr++
+		}
+		d.Memory = append(d.Memory, MemoryChange{
+			Address: uint16(start),
+			Old:     append([]byte(nil), a.Memory[start:addr]...),
+			New:     append([]byte(nil), b.Memory[start:addr]...),
+		})
+	}
+
+	// find bands of consecutive rows with changed pixels, and the columns they span.
+	screenA := a.screen()
+	screenB := b.screen()
+	var region *ScreenRegion
+	for y := 0; y < 32; y++ {
+		left, right := -1, -1
+		for x := 0; x < 64; x++ {
+			bit := byte(0x80) >> uint(x%8)
+			i := y*8 + x/8
+			if screenA[i]&bit != screenB[i]&bit || a.Plane2[i]&bit != b.Plane2[i]&bit {
+				if left == -1 {
+					left = x
+				}
+				right = x
+			}
+		}
+		if left == -1 {
+			region = nil
+			continue
+		}
+		if region == nil {
+			d.Screen = append(d.Screen, ScreenRegion{X: left, Y: y, Width: right - left + 1, Height: 1})
+			region = &d.Screen[len(d.Screen)-1]
+			continue
+		}
+		// grow the current region to take in this row.
+		regionRight := region.X + region.Width - 1
+		if left < region.X {
+			region.X = left
+		}
+		if right > regionRight {
+			regionRight = right
+		}
+		region.Width = regionRight - region.X + 1
+		region.Height++
+	}
+	return d
+}
+
+// stackPointer returns where s's stack pointer would be, or 0 if s has no memory
+// (the zero Chip8State, say).
+func (s Chip8State) stackPointer() uint16 {
+	if len(s.Memory) == 0 {
+		return 0
+	}
+	return stackAddressFor(len(s.Memory)) + uint16(len(s.Stack))
+}
+
+// videoAddress returns where s's screen starts in its memory, or 0 if s has no memory.
+func (s Chip8State) videoAddress() int {
+	if len(s.Memory) < videoMemorySize {
+		return 0
+	}
+	return int(videoAddressFor(len(s.Memory)))
+}
+
+// screen returns s's video memory, or a blank screen if it hasn't got any.
+func (s Chip8State) screen() []byte {
+	if len(s.VideoMemory) < videoMemorySize {
+		return make([]byte, videoMemorySize)
+	}
+	return s.VideoMemory
+}
+
+// Empty returns true if nothing changed.
+func (d StateDiff) Empty() bool {
+	return len(d.Registers) == 0 && len(d.Memory) == 0 && len(d.Screen) == 0 && d.OldMemorySize == d.NewMemorySize
+}
+
+// String formats the diff for humans, one change per line, like:
+//
+//	PC: 0x200 -> 0x202
+//	V3: 0x00 -> 0x2a
+//	memory 0xea0-0xea1: 00 00 -> 02 04
+//	screen: 8x5 at 12,5
+func (d StateDiff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	var b strings.Builder
+	for _, r := range d.Registers {
+		switch r.Name {
+		case "PC", "I", "SP":
+			fmt.Fprintf(&b, "%s: 0x%03x -> 0x%03x\n", r.Name, r.Old, r.New)
+		default:
+			fmt.Fprintf(&b, "%s: 0x%02x -> 0x%02x\n", r.Name, r.Old, r.New)
+		}
+	}
+	if d.OldMemorySize != d.NewMemorySize {
+		fmt.Fprintf(&b, "memory size: %d -> %d\n", d.OldMemorySize, d.NewMemorySize)
+	}
+	for _, m := range d.Memory {
+		if len(m.New) == 1 {
+			fmt.Fprintf(&b, "memory 0x%03x: % x -> % x\n", m.Address, m.Old, m.New)
+		} else {
+			fmt.Fprintf(&b, "memory 0x%03x-0x%03x: % x -> % x\n", m.Address, int(m.Address)+len(m.New)-1, m.Old, m.New)
+		}
+	}
+	for _, s := range d.Screen {
+		fmt.Fprintf(&b, "screen: %dx%d at %d,%d\n", s.Width, s.Height, s.X, s.Y)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
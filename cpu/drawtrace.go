@@ -0,0 +1,60 @@
+package cpu
+
+// A DrawRow is what drawing a sprite does to one row of the screen: the sprite's byte
+// for that row gets split in two to line up with the bytes of video memory, and the
+// halves are XORed into the screen. It's the big comment in drawSprite, worked through
+// with real numbers.
+type DrawRow struct {
+	// X and Y are where on the screen the row is drawn, in pixels, after wrapping.
+	X, Y int
+	// Sprite is the sprite's byte for this row.
+	Sprite byte
+	// Left is which byte of the screen row (0-7) the sprite's left half lands in, and
+	// Right which byte its right half lands in -- or -1 if X is a multiple of 8 and the
+	// sprite byte lines up with Left all by itself.
+	Left, Right int
+	// SpriteLeft and SpriteRight are the sprite byte shifted into the two halves.
+	SpriteLeft, SpriteRight byte
+	// Before and After are the screen row, a bit to a pixel, before and after the XOR.
+	Before, After [8]byte
+	// Collided is true if the sprite turned off a pixel that was on.
+	Collided bool
+}
+
+// TraceDraw works out, without drawing anything, what the DRW instruction opcode
+// would do to state's screen, a row at a time. It's for showing how drawing works;
+// if any row Collided, DRW sets VF to 1.
+func TraceDraw(state Chip8State, opcode uint16) []DrawRow {
+	n := int(opcode & 0x000f)
+	x := state.V[opcode&0x0f00>>8] % 64
+	y := state.V[opcode&0x00f0>>4] % 32
+	var screen [256]byte
+	copy(screen[:], state.VideoMemory)
+
+	rows := make([]DrawRow, n)
+	for i := range rows {
+		sprite := state.Memory[(int(state.I)+i)%len(state.Memory)]
+		// (y+i)*8 wraps around at 256 just like drawSprite's does, so tall
+		// sprites wrap from the bottom of the screen to the top.
+		yOffset := int((y + byte(i)) * 8)
+		row := DrawRow{X: int(x), Y: yOffset / 8, Sprite: sprite, Left: int(x / 8), Right: -1}
+		copy(row.Before[:], screen[yOffset:yOffset+8])
+		if x%8 == 0 {
+			row.SpriteLeft = sprite
+		} else {
+			row.Right = (row.Left + 1) % 8
+			row.SpriteLeft = sprite >> (x % 8)
+			row.SpriteRight = sprite << (8 - x%8)
+		}
+		row.After = row.Before
+		row.After[row.Left] ^= row.SpriteLeft
+		row.Collided = row.SpriteLeft&row.Before[row.Left] != 0
+		if row.Right >= 0 {
+			row.After[row.Right] ^= row.SpriteRight
+			row.Collided = row.Collided || row.SpriteRight&row.Before[row.Right] != 0
+		}
+		copy(screen[yOffset:], row.After[:])
+		rows[i] = row
+	}
+	return rows
+}
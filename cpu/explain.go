@@ -0,0 +1,186 @@
+package cpu
+
+import "fmt"
+
+// Disassemble returns the assembly language for an opcode, like "DRW V2,V3,5".
+// Opcodes that aren't instructions come out as "???".
+func Disassemble(opcode uint16) string {
+	return Decode(opcode).String()
+}
+
+// registers is the part of the machine an instruction can change, apart from memory.
+// It's what explain compares before and after.
+type registers struct {
+	pc, i  uint16
+	v      [16]byte
+	dt, st byte
+}
+
+func (c *Chip8) registers() registers {
+	return registers{pc: c.pc, i: c.i, v: c.v, dt: c.dt, st: c.st}
+}
+
+// explain says in plain English what an instruction just did, with the values it
+// did it to, like "draw the 5-row sprite at I=0x20a at x=V2(12), y=V3(5); collision flag set".
+// before and after are the registers from either side of it, and quirks are the
+// quirks it ran with.
+func explain(opcode uint16, before, after registers, quirks Quirks) string {
+	ins := Decode(opcode)
+	x, y, n, kk, nnn := ins.X, ins.Y, ins.N, ins.NN, ins.NNN
+	vx, vy := before.v[x], before.v[y]
+	// every skip instruction lands either two or four bytes on.
+	skipped := "it isn't, so carry on"
+	if after.pc == before.pc+4 {
+		skipped = "it is, so skip it"
+	}
+
+	switch ins.Op {
+	case OpCLS:
+		return "clear the screen"
+	case OpSCD:
+		if quirks.HalfScroll {
+			return fmt.Sprintf("scroll the screen down %d rows, the SCHIP's way: half of %d", n/2, n)
+		}
+		return fmt.Sprintf("scroll the screen down %d rows", n)
+	case OpSCR, OpSCL:
+		way := "right"
+		if ins.Op == OpSCL {
+			way = "left"
+		}
+		if quirks.HalfScroll {
+			return fmt.Sprintf("scroll the screen %s %d pixels, the SCHIP's way: half of %d", way, scrollStep/2, scrollStep)
+		}
+		return fmt.Sprintf("scroll the screen %s %d pixels", way, scrollStep)
+	case OpRET:
+		return fmt.Sprintf("return from the subroutine, back to %#03x", after.pc)
+	case OpJP:
+		if nnn == before.pc {
+			return fmt.Sprintf("jump to %#03x -- which is right here, so this is the end of the line", nnn)
+		}
+		return fmt.Sprintf("jump to %#03x", nnn)
+	case OpCALL:
+		return fmt.Sprintf("call the subroutine at %#03x, which will return to just after here", nnn)
+	case OpSEImm:
+		return fmt.Sprintf("skip the next instruction if V%X(%d) is %d: %s", x, vx, kk, skipped)
+	case OpSNEImm:
+		return fmt.Sprintf("skip the next instruction if V%X(%d) isn't %d: %s", x, vx, kk, skipped)
+	case OpSE:
+		return fmt.Sprintf("skip the next instruction if V%X(%d) is the same as V%X(%d): %s", x, vx, y, vy, skipped)
+	case OpLDImm:
+		return fmt.Sprintf("set V%X to %d", x, kk)
+	case OpADDImm:
+		wrapped := ""
+		if int(vx)+int(kk) > 0xff {
+			wrapped = ", wrapping around past 255"
+		}
+		return fmt.Sprintf("add %d to V%X(%d), making %d%s", kk, x, vx, after.v[x], wrapped)
+	case OpLD:
+		return fmt.Sprintf("copy V%X(%d) into V%X", y, vy, x)
+	case OpOR:
+		return fmt.Sprintf("set V%X to V%X(%08b) OR V%X(%08b), which is %08b%s", x, x, vx, y, vy, after.v[x], resetVF(quirks))
+	case OpAND:
+		return fmt.Sprintf("set V%X to V%X(%08b) AND V%X(%08b), which is %08b%s", x, x, vx, y, vy, after.v[x], resetVF(quirks))
+	case OpXOR:
+		return fmt.Sprintf("set V%X to V%X(%08b) XOR V%X(%08b), which is %08b%s", x, x, vx, y, vy, after.v[x], resetVF(quirks))
+	case OpADD:
+		return fmt.Sprintf("add V%X(%d) to V%X(%d), making %d; the carry flag VF is %d", y, vy, x, vx, after.v[x], after.v[0xf])
+	case OpSUB:
+		return fmt.Sprintf("subtract V%X(%d) from V%X(%d), making %d; the borrow flag VF is %d (0 means it borrowed)", y, vy, x, vx, after.v[x], after.v[0xf])
+	case OpSHR:
+		if quirks.ShiftVy {
+			return fmt.Sprintf("shift V%X(%08b) right a bit and put it in V%X, making %08b; the bit that fell off goes in VF(%d)", y, vy, x, after.v[x], after.v[0xf])
+		}
+		return fmt.Sprintf("shift V%X(%08b) right a bit, making %08b; the bit that fell off goes in VF(%d)", x, vx, after.v[x], after.v[0xf])
+	case OpSUBN:
+		return fmt.Sprintf("set V%X to V%X(%d) minus V%X(%d), making %d; the borrow flag VF is %d (0 means it borrowed)", x, y, vy, x, vx, after.v[x], after.v[0xf])
+	case OpSHL:
+		if quirks.ShiftVy {
+			return fmt.Sprintf("shift V%X(%08b) left a bit and put it in V%X, making %08b; the bit that fell off goes in VF(%d)", y, vy, x, after.v[x], after.v[0xf])
+		}
+		return fmt.Sprintf("shift V%X(%08b) left a bit, making %08b; the bit that fell off goes in VF(%d)", x, vx, after.v[x], after.v[0xf])
+	case OpSNE:
+		return fmt.Sprintf("skip the next instruction if V%X(%d) is different from V%X(%d): %s", x, vx, y, vy, skipped)
+	case OpLDI:
+		return fmt.Sprintf("point I at %#03x", nnn)
+	case OpJPV0:
+		if quirks.JumpVx {
+			return fmt.Sprintf("jump to %#03x plus V%X(%d), which is %#03x", nnn, x, vx, after.pc)
+		}
+		return fmt.Sprintf("jump to %#03x plus V0(%d), which is %#03x", nnn, before.v[0], after.pc)
+	case OpRND:
+		return fmt.Sprintf("roll a random number, keep the bits in %08b, and put it in V%X: it came out %d", kk, x, after.v[x])
+	case OpDRW:
+		collision := "no collision"
+		if after.v[0xf] != 0 {
+			collision = "collision flag set"
+		}
+		if quirks.CountCollisions && after.v[0xf] != 0 {
+			collision = fmt.Sprintf("%d rows collided, so VF is %d", after.v[0xf], after.v[0xf])
+		}
+		return fmt.Sprintf("draw the %d-row sprite at I=%#03x at x=V%X(%d), y=V%X(%d); %s", n, before.i, x, vx, y, vy, collision)
+	case OpSKP:
+		return fmt.Sprintf("skip the next instruction if key V%X(%X) is down: %s", x, vx, skipped)
+	case OpSKNP:
+		return fmt.Sprintf("skip the next instruction if key V%X(%X) isn't down: %s", x, vx, skipped)
+	case OpSKP2:
+		return fmt.Sprintf("skip the next instruction if key V%X(%X) is down on the second keypad: %s", x, vx, skipped)
+	case OpSKNP2:
+		return fmt.Sprintf("skip the next instruction if key V%X(%X) isn't down on the second keypad: %s", x, vx, skipped)
+	case OpLDVxDT:
+		return fmt.Sprintf("copy the delay timer(%d) into V%X", before.dt, x)
+	case OpLDVxK:
+		if after.pc == before.pc {
+			return fmt.Sprintf("wait for a key to put in V%X: none yet, so come back and wait some more", x)
+		}
+		return fmt.Sprintf("wait for a key to put in V%X: got key %X", x, after.v[x])
+	case OpLDDTVx:
+		return fmt.Sprintf("set the delay timer to V%X(%d), which counts down to 0 sixty times a second", x, vx)
+	case OpLDSTVx:
+		return fmt.Sprintf("set the sound timer to V%X(%d): beep until it counts down to 0", x, vx)
+	case OpADDI:
+		return fmt.Sprintf("add V%X(%d) to I(%#03x), making %#03x", x, vx, before.i, after.i)
+	case OpLDF:
+		return fmt.Sprintf("point I at the font's sprite for the digit %X in V%X, at %#03x", vx, x, after.i)
+	case OpLDB:
+		return fmt.Sprintf("write V%X(%d) in decimal, one digit per byte, at I(%#03x), I+1 and I+2", x, vx, before.i)
+	case OpPLANE:
+		switch x & 0x3 {
+		case 0:
+			return "draw on no planes at all, so DRW and CLS do nothing"
+		case 1:
+			return "draw on plane 1, the usual one"
+		case 2:
+			return "draw on plane 2"
+		}
+		return "draw on both planes at once"
+	case OpLDHF:
+		return fmt.Sprintf("point I at the font's big sprite for the digit %d in V%X, at %#03x", vx%10, x, after.i)
+	case OpStore:
+		return fmt.Sprintf("copy V0 through V%X into memory, starting at I(%#03x)%s", x, before.i, movedI(before, after))
+	case OpLoad:
+		return fmt.Sprintf("copy memory into V0 through V%X, starting at I(%#03x)%s", x, before.i, movedI(before, after))
+	case OpStoreRPL:
+		return fmt.Sprintf("save V0 through V%X in the RPL flags, which outlast the game", x)
+	case OpLoadRPL:
+		return fmt.Sprintf("load V0 through V%X from the RPL flags", x)
+	}
+	return "not an instruction"
+}
+
+// movedI is the end of the explanation of Fx55 and Fx65, which move I along with
+// the IncrementI quirk and leave it alone without.
+func movedI(before, after registers) string {
+	if after.i == before.i {
+		return ""
+	}
+	return fmt.Sprintf(", then move I on to %#03x", after.i)
+}
+
+// resetVF is the end of the explanation of OR, AND and XOR, which clear VF with
+// the ResetVF quirk.
+func resetVF(quirks Quirks) string {
+	if !quirks.ResetVF {
+		return ""
+	}
+	return ", and VF goes back to 0"
+}
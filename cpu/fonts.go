@@ -0,0 +1,195 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Font is the sprites the Chip8 keeps in its own memory for drawing numbers:
+// the hex digits 0 to F, four pixels wide and five tall, which LD F,Vx points I
+// at, and the SCHIP's big decimal digits 0 to 9, eight pixels wide and ten tall,
+// which LD HF,Vx points I at.
+//
+// Every interpreter drew its own digits, and while they all agree a 0 is a box,
+// they disagree about nearly everything else -- which sounds harmless until a
+// game draws its score with one font and checks it for collisions with another.
+type Font struct {
+	// Name is what the font is called in FontByName.
+	Name string
+	// Small is the sprites for 0 to F, five bytes each.
+	Small [16 * 5]byte
+	// Large is the sprites for 0 to 9, ten bytes each.
+	Large [10 * 10]byte
+}
+
+// The fonts go where most emulators put them, so games that go looking for them
+// themselves (some do) find them: the small one at 0x050 and the large one
+// straight after it.
+const (
+	smallFontAddress uint16 = 0x050
+	largeFontAddress uint16 = smallFontAddress + 16*5
+	fontEnd          uint16 = largeFontAddress + 10*10
+)
+
+// Glyph returns the sprite for a hex digit in the small font.
+func (f *Font) Glyph(digit byte) []byte {
+	digit &= 0xf
+	return f.Small[digit*5 : digit*5+5]
+}
+
+// schipLargeDigits are the SCHIP's big digits. It was the only interpreter with
+// big digits, so every font gets them.
+var schipLargeDigits = [10 * 10]byte{
+	0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+	0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+	0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+	0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+	0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+	0x3E, 0x7C, 0xC0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+	0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+}
+
+// DefaultFont is the font a Chip8 has unless you give it another: the SCHIP's,
+// which is the one everybody copied, and the one this Chip8 has always had.
+var DefaultFont = Font{
+	Name: "schip",
+	Small: [16 * 5]byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x20, 0x60, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+	Large: schipLargeDigits,
+}
+
+// fonts are all the fonts FontByName knows, DefaultFont first.
+var fonts = []Font{
+	DefaultFont,
+	{
+		// the COSMAC VIP's, from the original interpreter. Its 7 has no
+		// bend in it, and its B and D are drawn from the middle.
+		Name: "vip",
+		Small: [16 * 5]byte{
+			0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+			0x60, 0x20, 0x20, 0x20, 0x70, // 1
+			0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+			0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+			0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+			0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+			0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+			0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+			0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+			0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+			0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+			0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+			0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+			0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+			0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+			0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+		},
+		Large: schipLargeDigits,
+	},
+	{
+		// the DREAM 6800's, which is only three pixels wide.
+		Name: "dream6800",
+		Small: [16 * 5]byte{
+			0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+			0x40, 0x40, 0x40, 0x40, 0x40, // 1
+			0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+			0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+			0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+			0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+			0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+			0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+			0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+			0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+			0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+			0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+			0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+			0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+			0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+			0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+		},
+		Large: schipLargeDigits,
+	},
+	{
+		// the ETI-660's, three pixels wide too, with a lowercase b and d.
+		Name: "eti660",
+		Small: [16 * 5]byte{
+			0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+			0x20, 0x20, 0x20, 0x20, 0x20, // 1
+			0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+			0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+			0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+			0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+			0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+			0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+			0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+			0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+			0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+			0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+			0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+			0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+			0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+			0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+		},
+		Large: schipLargeDigits,
+	},
+}
+
+// FontByName returns the font called name: "schip", "vip", "dream6800" or "eti660".
+func FontByName(name string) (Font, error) {
+	for _, font := range fonts {
+		if font.Name == name {
+			return font, nil
+		}
+	}
+	return Font{}, fmt.Errorf("there's no font called %q; the fonts are %s", name, strings.Join(FontNames(), ", "))
+}
+
+// FontNames returns the names of all the fonts, for FontByName.
+func FontNames() []string {
+	var names []string
+	for _, font := range fonts {
+		names = append(names, font.Name)
+	}
+	return names
+}
+
+// WithFont is an Option that gives the Chip8 a different font from DefaultFont.
+func WithFont(font Font) Option {
+	return func(c *Chip8) {
+		c.SetFont(font)
+	}
+}
+
+// SetFont changes the Chip8's font. It goes into memory straight away, over
+// whatever was there before, and stays there through every program loaded
+// after it.
+func (c *Chip8) SetFont(font Font) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.font = font
+	c.loadFont()
+}
+
+// loadFont puts the font in memory where LD F,Vx and LD HF,Vx expect it. c.mu must be held.
+func (c *Chip8) loadFont() {
+	copy(c.memory[smallFontAddress:], c.font.Small[:])
+	copy(c.memory[largeFontAddress:], c.font.Large[:])
+}
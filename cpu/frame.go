@@ -0,0 +1,64 @@
+package cpu
+
+// Frame is one picture of the Chip8's screen, in the same shape as its video
+// memory: 256 bytes, 8 to a row of 64 pixels, a bit to a pixel, the highest bit
+// of the first byte being the top-left pixel (see ReadVideoMemory for the whole
+// story). It's the one screen format everything in this repository passes around;
+// the methods convert it to whatever shape your display would rather have.
+type Frame [256]byte
+
+// FrameWidth and FrameHeight are the size of the screen, in pixels.
+const (
+	FrameWidth  = 64
+	FrameHeight = 32
+)
+
+// Pixel returns true if the pixel at x, y is on, with 0, 0 at the top-left.
+func (f *Frame) Pixel(x, y int) bool {
+	return f[y*8+x/8]&(0x80>>uint(x%8)) != 0
+}
+
+// SetPixel turns the pixel at x, y on or off.
+func (f *Frame) SetPixel(x, y int, on bool) {
+	bit := byte(0x80) >> uint(x%8)
+	if on {
+		f[y*8+x/8] |= bit
+	} else {
+		f[y*8+x/8] &^= bit
+	}
+}
+
+// Matrix unpacks the frame into 32 rows of 64 pixels, true for on.
+func (f *Frame) Matrix() [FrameHeight][FrameWidth]bool {
+	var matrix [FrameHeight][FrameWidth]bool
+	for y := range matrix {
+		for x := range matrix[y] {
+			matrix[y][x] = f.Pixel(x, y)
+		}
+	}
+	return matrix
+}
+
+// FrameFromMatrix packs 32 rows of 64 pixels back into a Frame.
+func FrameFromMatrix(matrix [FrameHeight][FrameWidth]bool) Frame {
+	var f Frame
+	for y := range matrix {
+		for x, on := range matrix[y] {
+			f.SetPixel(x, y, on)
+		}
+	}
+	return f
+}
+
+// Pixels unpacks the frame into a byte a pixel, 0xff for on and 0x00 for off,
+// a row at a time from the top -- ready to be uploaded as a one-channel texture,
+// or scaled up into whatever colors you like.
+func (f *Frame) Pixels() []byte {
+	pixels := make([]byte, FrameWidth*FrameHeight)
+	for i := range pixels {
+		if f.Pixel(i%FrameWidth, i/FrameWidth) {
+			pixels[i] = 0xff
+		}
+	}
+	return pixels
+}
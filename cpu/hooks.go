@@ -0,0 +1,45 @@
+package cpu
+
+import "time"
+
+// OnInstruction sets a function to be called after each instruction the Chip8 executes,
+// with the address it was at and its opcode. It's for tools that need to know exactly
+// what the Chip8 is up to, like debuggers and autosplitters. Set it to nil to stop.
+//
+// The hook is called on whichever goroutine is running the Chip8, once per instruction,
+// so keep it quick: a slow hook is a slow Chip8. The Chip8 isn't locked while the hook
+// runs, so it can look at the Chip8's state with Snapshot and the like, or Halt it --
+// but it mustn't Wait for it to stop, since the Chip8 is waiting for the hook.
+func (c *Chip8) OnInstruction(hook func(pc, opcode uint16)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onInstruction = hook
+}
+
+// OnFrame sets a function to be called at the end of each frame (see FrameCount),
+// with the number of the frame that just ended. Everything OnInstruction says about
+// hooks goes for this one too, except that it's only called sixty times a second.
+func (c *Chip8) OnFrame(hook func(frame uint64)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFrame = hook
+}
+
+// An InstructionTimer is told how long each instruction took (see TimeInstructions).
+type InstructionTimer interface {
+	TimeInstruction(pc, opcode uint16, took time.Duration)
+}
+
+// TimeInstructions has the Chip8 time each instruction it executes, in real time,
+// and tell timer how long it took -- for finding out whether a game that runs slow
+// is slow because of the emulator or because of the game (the timing package adds
+// it all up). Looking at the clock twice an instruction costs a little itself, so
+// it's off until this turns it on. Set it to nil to turn it off again.
+//
+// Unlike the other hooks, timer is called with the Chip8 locked, right after the
+// instruction, so it mustn't call the Chip8 at all: it's for writing things down.
+func (c *Chip8) TimeInstructions(timer InstructionTimer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = timer
+}
@@ -0,0 +1,71 @@
+package cpu
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A HostCall is a Go function a Chip-8 program can call, for hybrid programs that
+// want something only the host has -- the time of day, a random seed worth the
+// name, a file -- and for tests that want to check on things from inside a ROM.
+//
+// The program calls it with 0nnn, where nnn is the number it was given in
+// HostCalls. On the COSMAC VIP, 0nnn ran the machine code at nnn, which is about
+// as close as the Chip-8 ever got to a system call; nothing here uses it otherwise.
+// Except: 00E0 and 00EE are CLS and RET, and 00Cn, 00FB and 00FC are the SCHIP's
+// scrolls, and those are instructions first -- so don't number a call 0e0, 0ee,
+// 0c0 to 0cf, 0fb or 0fc, or the program will never get to call it.
+//
+// The call gets the registers and memory in state, and anything it changes in
+// them sticks. If it returns an error, the Chip8 stops with StopError, as if the
+// program had run into an instruction it didn't know. Like a Peripheral, it's
+// called with the Chip8 locked, so it mustn't call the Chip8's methods.
+type HostCall func(state *HostCallState) error
+
+// HostCallState is what a HostCall gets to work with.
+type HostCallState struct {
+	// V and I are the registers, to read the call's arguments from and put its
+	// results in.
+	V [16]byte
+	I uint16
+	// Memory is the Chip8's memory itself, screen and all: 4K of it, or however
+	// much the Chip8 has (see WithMemorySize).
+	Memory []byte
+}
+
+// HostCalls is an Option that lets programs call Go functions (see HostCall), by
+// the numbers in calls. Without it, 0nnn is an instruction the Chip8 doesn't know,
+// like it always was: a ROM only gets to call out of the emulator if you said so.
+func HostCalls(calls map[uint16]HostCall) Option {
+	return func(c *Chip8) {
+		c.hostCalls = make(map[uint16]HostCall, len(calls))
+		for number, call := range calls {
+			c.hostCalls[number&0x0fff] = call
+		}
+	}
+}
+
+// execHostCall makes the host call opcode asks for, if it's a host call and host
+// calls are allowed, and returns true if it did. c.mu must be held.
+func (c *Chip8) execHostCall(opcode uint16) (bool, error) {
+	if opcode&0xf000 != 0 {
+		return false, nil
+	}
+	call, ok := c.hostCalls[opcode]
+	if !ok {
+		return false, nil
+	}
+	var screen [256]byte
+	copy(screen[:], c.memory[c.videoMemoryAddress():])
+	state := HostCallState{V: c.v, I: c.i, Memory: c.memory}
+	err := call(&state)
+	c.v, c.i = state.V, state.I
+	// the call may well have drawn something.
+	if !bytes.Equal(screen[:], c.memory[c.videoMemoryAddress():]) {
+		c.refreshScreen()
+	}
+	if err != nil {
+		return true, fmt.Errorf("host call %03x at %03x: %v", opcode, c.pc, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,135 @@
+package cpu
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// A DumpFormat is a file format for memory dumps.
+type DumpFormat int
+
+const (
+	// DumpBinary is raw bytes, exactly as they are in memory.
+	DumpBinary DumpFormat = iota
+	// DumpHex is a hex dump with 16 bytes per line, each line starting with
+	// the address of its first byte:
+	//
+	//	0200: 6a 02 6b 0c 6c 3f 6d 0c a2 ea da b6 dc d6 6e 00
+	DumpHex
+)
+
+// DumpMemory writes length bytes of memory, starting at address start, to w.
+// Use it to pull sprites and data out of a game, or to see what a game did to its memory.
+func (c *Chip8) DumpMemory(w io.Writer, start uint16, length int, format DumpFormat) error {
+	if length < 0 || int(start)+length > len(c.memory) {
+		return fmt.Errorf("memory range %03x+%d is out of bounds", start, length)
+	}
+	c.mu.Lock()
+	dump := make([]byte, length)
+	copy(dump, c.memory[start:])
+	c.mu.Unlock()
+
+	if format == DumpBinary {
+		_, err := w.Write(dump)
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for offset := 0; offset < len(dump); offset += 16 {
+		end := offset + 16
+		if end > len(dump) {
+			end = len(dump)
+		}
+		fmt.Fprintf(bw, "%04x: % x\n", int(start)+offset, dump[offset:end])
+	}
+	return bw.Flush()
+}
+
+// LoadMemory reads a memory dump from r and writes it into memory starting at address start,
+// returning the number of bytes written. Hex dumps are read in order; the addresses at
+// the start of each line are ignored, so a dump can be loaded somewhere other than where it came from.
+//
+// This is how you patch a game: dump some memory, edit it, and load it back.
+func (c *Chip8) LoadMemory(r io.Reader, start uint16, format DumpFormat) (int, error) {
+	var data []byte
+	var err error
+	if format == DumpBinary {
+		data, err = ioutil.ReadAll(r)
+	} else {
+		data, err = readHexDump(r)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := c.WriteMemory(start, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// ReadMemory returns a copy of n bytes of memory, starting at addr.
+func (c *Chip8) ReadMemory(addr uint16, n int) ([]byte, error) {
+	if n < 0 || int(addr)+n > len(c.memory) {
+		return nil, fmt.Errorf("memory range %03x+%d is out of bounds", addr, n)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.memory[addr:int(addr)+n]...), nil
+}
+
+// WriteMemory writes data into memory, starting at addr, in between instructions.
+// It's for debuggers, cheats and tests -- anything that wants to poke at the
+// Chip8's memory while it runs. If the screen changes, the display hears about it.
+//
+// If the interpreter's part of memory is write protected (see SetWriteProtection),
+// WriteMemory won't write anywhere below 0x200, where the font lives.
+func (c *Chip8) WriteMemory(addr uint16, data []byte) error {
+	end := int(addr) + len(data)
+	if end > len(c.memory) {
+		return fmt.Errorf("%d bytes at %03x don't fit in memory", len(data), addr)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.protection.Interpreter && addr < programStartAddress && len(data) > 0 {
+		return fmt.Errorf("%03x is write protected: everything below %03x is the interpreter's", addr, programStartAddress)
+	}
+	copy(c.memory[addr:], data)
+	if end > int(c.videoMemoryAddress()) {
+		c.refreshScreen()
+	}
+	return nil
+}
+
+// SetWriteProtection turns write protection of the interpreter's part of memory,
+// 0x000 to 0x1ff, on or off. Overwrite the font by accident and every number the
+// game draws comes out as garbage, which is a fun afternoon of debugging I'd rather
+// save you from. It's off to start with. It's the same as the Interpreter field of
+// SetMemoryProtection, which can protect the program too.
+func (c *Chip8) SetWriteProtection(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.protection.Interpreter = on
+}
+
+func readHexDump(r io.Reader) ([]byte, error) {
+	var data []byte
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		// drop the address at the start of the line, if there is one
+		if i := strings.Index(text, ":"); i >= 0 {
+			text = text[i+1:]
+		}
+		for _, field := range strings.Fields(text) {
+			b, err := hex.DecodeString(field)
+			if err != nil || len(b) != 1 {
+				return nil, fmt.Errorf("hex dump line %d: %q is not a hex byte", line, field)
+			}
+			data = append(data, b[0])
+		}
+	}
+	return data, scanner.Err()
+}
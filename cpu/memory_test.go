@@ -0,0 +1,82 @@
+package cpu_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// Chip8.DumpMemory / Chip8.LoadMemory
+// should load a binary dump back exactly, wherever it's loaded
+// should load a hex dump back exactly, however many bytes are on its last line
+func TestDumpMemoryRoundTrip(t *testing.T) {
+	// 21 bytes: a full line of hex and a bit of one.
+	program := []byte("an odd number of byte")
+	for _, format := range []cpu.DumpFormat{cpu.DumpBinary, cpu.DumpHex} {
+		c := newTestChip8(t, program)
+		var dump bytes.Buffer
+		if err := c.DumpMemory(&dump, 0x200, len(program), format); err != nil {
+			t.Fatal(err)
+		}
+		if format == cpu.DumpHex && !strings.HasPrefix(dump.String(), "0200: 61 6e 20 6f") {
+			t.Errorf("the hex dump doesn't start with the address and the first bytes:\n%s", dump.String())
+		}
+
+		other := newTestChip8(t, nil)
+		n, err := other.LoadMemory(&dump, 0x300, format)
+		if err != nil {
+			t.Fatalf("loading the dump: %v", err)
+		}
+		got, _ := other.ReadMemory(0x300, len(program))
+		if n != len(program) || !bytes.Equal(got, program) {
+			t.Errorf("format %d: loaded %d bytes, %q; want %d, %q", format, n, got, len(program), program)
+		}
+	}
+}
+
+// Chip8.LoadMemory
+// should reject hex dumps with anything but hex bytes in them, and say which line
+// should leave memory alone when it rejects a dump
+func TestLoadMemoryMalformedHex(t *testing.T) {
+	for _, dump := range []string{
+		"0200: 6a 02\n0210: 6a zz\n",
+		"0200: 6a 02\n0210: 6a 123\n",
+		"0200: 6a 02\n0210: 6a a\n",
+	} {
+		c := newTestChip8(t, nil)
+		_, err := c.LoadMemory(strings.NewReader(dump), 0x200, cpu.DumpHex)
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("loading %q: got %v, want an error about line 2", dump, err)
+		}
+		if got, _ := c.ReadMemory(0x200, 2); !bytes.Equal(got, []byte{0, 0}) {
+			t.Errorf("loading %q: memory is %x after the error, want it untouched", dump, got)
+		}
+	}
+}
+
+// Chip8.DumpMemory / Chip8.LoadMemory
+// should refuse to read or write past the end of memory
+func TestMemoryDumpBounds(t *testing.T) {
+	c := newTestChip8(t, nil)
+	if _, err := c.LoadMemory(bytes.NewReader([]byte{1, 2, 3, 4}), 0xffe, cpu.DumpBinary); err == nil {
+		t.Error("loading 4 bytes at ffe didn't fail")
+	}
+	if _, err := c.LoadMemory(strings.NewReader("0ffe: 01 02 03\n"), 0xffe, cpu.DumpHex); err == nil {
+		t.Error("loading 3 bytes of hex at ffe didn't fail")
+	}
+	if got, _ := c.ReadMemory(0xffe, 2); !bytes.Equal(got, []byte{0, 0}) {
+		t.Errorf("memory at ffe is %x after loads that didn't fit, want it untouched", got)
+	}
+	var dump bytes.Buffer
+	if err := c.DumpMemory(&dump, 0xff0, 32, cpu.DumpBinary); err == nil {
+		t.Error("dumping 32 bytes at ff0 didn't fail")
+	}
+	if err := c.DumpMemory(&dump, 0x200, -1, cpu.DumpHex); err == nil {
+		t.Error("dumping -1 bytes didn't fail")
+	}
+	if err := c.DumpMemory(&dump, 0xff0, 16, cpu.DumpBinary); err != nil || dump.Len() != 16 {
+		t.Errorf("dumping the last 16 bytes: got %d bytes, %v", dump.Len(), err)
+	}
+}
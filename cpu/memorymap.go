@@ -0,0 +1,39 @@
+package cpu
+
+// A MemoryRegion is a stretch of the Chip8's memory that's set aside for something.
+type MemoryRegion struct {
+	// Name is what it's for: "font", "interpreter", "program", "data", "stack" or "video".
+	Name string
+	// Start is the region's first address, and End the address just after its last.
+	// They're ints, not addresses, since in 64K of memory the video region ends at 0x10000.
+	Start, End int
+}
+
+// MemoryMap returns how the Chip8's memory is laid out, from 0x000 to the top
+// (0xfff, unless it has more than 4K -- see WithMemorySize):
+//
+//	interpreter  where the original interpreter itself lived; unused here,
+//	             apart from the font in the middle of it
+//	font         the sprites for the digits, which LD F,Vx and LD HF,Vx point I at
+//	program      the loaded program, starting at 0x200
+//	data         the rest of memory, free for the program to use
+//	stack        where CALL keeps return addresses
+//	video        the screen, a bit to a pixel
+func (c *Chip8) MemoryMap() []MemoryRegion {
+	c.mu.Lock()
+	programEnd := 0x200 + c.romSize
+	c.mu.Unlock()
+	stack, video := int(c.stackAddress()), int(c.videoMemoryAddress())
+	if programEnd > stack {
+		programEnd = stack
+	}
+	return []MemoryRegion{
+		{"interpreter", 0x000, int(smallFontAddress)},
+		{"font", int(smallFontAddress), int(fontEnd)},
+		{"interpreter", int(fontEnd), 0x200},
+		{"program", 0x200, programEnd},
+		{"data", programEnd, stack},
+		{"stack", stack, video},
+		{"video", video, len(c.memory)},
+	}
+}
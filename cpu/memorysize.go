@@ -0,0 +1,94 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The sizes of memory a Chip8 can have (see WithMemorySize).
+const (
+	// MemorySize4K is what the COSMAC VIP had, and what nearly every Chip-8 program
+	// expects. It's the default.
+	MemorySize4K = 0x1000
+	// MemorySize64K is what XO-CHIP gives programs: room for much bigger games, and
+	// for experimenting.
+	MemorySize64K = 0x10000
+)
+
+// The stack and the screen live at the very top of memory, whatever size it is:
+// the screen in the last 256 bytes, and the stack in the 96 bytes below that. In
+// 4K that puts them at 0xea0 and 0xf00, right where the COSMAC VIP kept them; in
+// 64K everything from 0x200 up to 0xfea0 is the program's to play with.
+const (
+	stackSize       = 0x60
+	videoMemorySize = 0x100
+)
+
+// stackAddressFor returns where the stack starts, in memory of the given size.
+func stackAddressFor(size int) uint16 {
+	return uint16(size - videoMemorySize - stackSize)
+}
+
+// videoAddressFor returns where the screen starts, in memory of the given size.
+func videoAddressFor(size int) uint16 {
+	return uint16(size - videoMemorySize)
+}
+
+// stackAddress returns where the Chip8's stack starts.
+func (c *Chip8) stackAddress() uint16 {
+	return stackAddressFor(len(c.memory))
+}
+
+// videoMemoryAddress returns where the Chip8's screen starts.
+func (c *Chip8) videoMemoryAddress() uint16 {
+	return videoAddressFor(len(c.memory))
+}
+
+// highestMemoryAddress returns the Chip8's last address. Since both memory sizes
+// are powers of two, it doubles as the mask that wraps addresses around.
+func (c *Chip8) highestMemoryAddress() uint16 {
+	return uint16(len(c.memory) - 1)
+}
+
+// validMemorySize returns true if size is one of the sizes a Chip8 can have.
+func validMemorySize(size int) bool {
+	return size == MemorySize4K || size == MemorySize64K
+}
+
+// WithMemorySize is an Option that gives the Chip8 size bytes of memory:
+// MemorySize4K or MemorySize64K. Anything else gets you the usual 4K.
+//
+// The registers are still what they were -- I and PC can reach past 0xfff, but only
+// by counting up to it, with ADD I,Vx or by running off the end of 4K -- so in 64K
+// most of memory is for data that programs copy in with LD [I],Vx and LD Vx,[I].
+// Peripherals and host calls can reach all of it.
+func WithMemorySize(size int) Option {
+	return func(c *Chip8) {
+		if !validMemorySize(size) {
+			size = MemorySize4K
+		}
+		if size != len(c.memory) {
+			c.memory = make([]byte, size)
+			c.sp = c.stackAddress()
+			c.loadFont()
+			c.refreshScreen()
+		}
+	}
+}
+
+// ParseMemorySize parses a memory size the way people write them: "4k" or "64k"
+// (or the number of bytes, 4096 or 65536).
+func ParseMemorySize(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "4k", "4096":
+		return MemorySize4K, nil
+	case "64k", "65536":
+		return MemorySize64K, nil
+	}
+	return 0, fmt.Errorf("memory size %q should be 4k or 64k", s)
+}
+
+// MemorySize returns how many bytes of memory the Chip8 has.
+func (c *Chip8) MemorySize() int {
+	return len(c.memory)
+}
@@ -0,0 +1,231 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An Op is which instruction an opcode is, ignoring what it's done to: "load a byte into
+// a register", say, whichever register and whichever byte.
+type Op uint8
+
+// The instructions, named after their assembly language. Where two share a name, the
+// one that takes a byte (3xkk and friends) is the Imm one, and the LDs that aren't
+// just LD are named after where they load to and from.
+const (
+	// OpInvalid is any opcode that isn't an instruction.
+	OpInvalid  Op = iota
+	OpCLS         // 00E0
+	OpRET         // 00EE
+	OpJP          // 1nnn
+	OpCALL        // 2nnn
+	OpSEImm       // 3xkk
+	OpSNEImm      // 4xkk
+	OpSE          // 5xy0
+	OpLDImm       // 6xkk
+	OpADDImm      // 7xkk
+	OpLD          // 8xy0
+	OpOR          // 8xy1
+	OpAND         // 8xy2
+	OpXOR         // 8xy3
+	OpADD         // 8xy4
+	OpSUB         // 8xy5
+	OpSHR         // 8xy6
+	OpSUBN        // 8xy7
+	OpSHL         // 8xyE
+	OpSNE         // 9xy0
+	OpLDI         // Annn
+	OpJPV0        // Bnnn
+	OpRND         // Cxkk
+	OpDRW         // Dxyn
+	OpSKP         // Ex9E
+	OpSKNP        // ExA1
+	OpLDVxDT      // Fx07
+	OpLDVxK       // Fx0A
+	OpLDDTVx      // Fx15
+	OpLDSTVx      // Fx18
+	OpADDI        // Fx1E
+	OpLDF         // Fx29
+	OpLDB         // Fx33
+	OpStore       // Fx55
+	OpLoad        // Fx65
+	OpStoreRPL    // Fx75
+	OpLoadRPL     // Fx85
+	OpLDHF        // Fx30
+	OpSKP2        // ExF2
+	OpSKNP2       // ExF5
+	OpPLANE       // Fx01
+	OpSCD         // 00Cn
+	OpSCR         // 00FB
+	OpSCL         // 00FC
+)
+
+// opSyntax is how each instruction is written in assembly language: its name, and
+// its operands, with x, y, n, nn and nnn standing for the pieces of the opcode
+// (Vx and Vy for registers, and a bare x for the digit itself, like PLANE's).
+var opSyntax = [...]struct{ name, operands string }{
+	OpInvalid: {"???", ""},
+	OpCLS:     {"CLS", ""}, OpRET: {"RET", ""},
+	OpJP: {"JP", "nnn"}, OpCALL: {"CALL", "nnn"},
+	OpSEImm: {"SE", "Vx,nn"}, OpSNEImm: {"SNE", "Vx,nn"}, OpSE: {"SE", "Vx,Vy"},
+	OpLDImm: {"LD", "Vx,nn"}, OpADDImm: {"ADD", "Vx,nn"},
+	OpLD: {"LD", "Vx,Vy"}, OpOR: {"OR", "Vx,Vy"}, OpAND: {"AND", "Vx,Vy"}, OpXOR: {"XOR", "Vx,Vy"},
+	OpADD: {"ADD", "Vx,Vy"}, OpSUB: {"SUB", "Vx,Vy"}, OpSHR: {"SHR", "Vx,Vy"},
+	OpSUBN: {"SUBN", "Vx,Vy"}, OpSHL: {"SHL", "Vx,Vy"}, OpSNE: {"SNE", "Vx,Vy"},
+	OpLDI: {"LD", "I,nnn"}, OpJPV0: {"JP", "V0,nnn"}, OpRND: {"RND", "Vx,nn"},
+	OpDRW: {"DRW", "Vx,Vy,n"}, OpSKP: {"SKP", "Vx"}, OpSKNP: {"SKNP", "Vx"},
+	OpLDVxDT: {"LD", "Vx,DT"}, OpLDVxK: {"LD", "Vx,K"}, OpLDDTVx: {"LD", "DT,Vx"}, OpLDSTVx: {"LD", "ST,Vx"},
+	OpADDI: {"ADD", "I,Vx"}, OpLDF: {"LD", "F,Vx"}, OpLDB: {"LD", "B,Vx"},
+	OpStore: {"LD", "[I],Vx"}, OpLoad: {"LD", "Vx,[I]"}, OpStoreRPL: {"LD", "R,Vx"}, OpLoadRPL: {"LD", "Vx,R"},
+	OpLDHF: {"LD", "HF,Vx"}, OpSKP2: {"SKP2", "Vx"}, OpSKNP2: {"SKNP2", "Vx"},
+	OpPLANE: {"PLANE", "x"}, OpSCD: {"SCD", "n"}, OpSCR: {"SCR", ""}, OpSCL: {"SCL", ""},
+}
+
+// String returns the instruction's name in assembly language, like "LD" or "DRW".
+func (op Op) String() string {
+	if int(op) >= len(opSyntax) {
+		return "???"
+	}
+	return opSyntax[op].name
+}
+
+// Syntax returns the instruction's name and operands, with the pieces of the opcode
+// standing in for what's done to, like "DRW Vx,Vy,n". Unlike String, that tells the
+// LDs apart.
+func (op Op) Syntax() string {
+	if int(op) >= len(opSyntax) || opSyntax[op].operands == "" {
+		return op.String()
+	}
+	return opSyntax[op].name + " " + opSyntax[op].operands
+}
+
+// An Instruction is an opcode taken apart: which instruction it is, and the pieces
+// of the opcode it does it with. Which pieces mean anything depends on the instruction
+// -- JP only uses NNN, DRW uses X, Y and N -- but they're all filled in regardless.
+type Instruction struct {
+	Opcode uint16
+	Op     Op
+	// X and Y are the second and third hex digits, usually registers.
+	X, Y byte
+	// N is the last hex digit, NN the last byte, and NNN the last three digits,
+	// usually an address.
+	N   byte
+	NN  byte
+	NNN uint16
+}
+
+// Decode takes an opcode apart. Opcodes that aren't instructions decode as OpInvalid.
+func Decode(opcode uint16) Instruction {
+	ins := Instruction{
+		Opcode: opcode,
+		X:      byte(opcode & 0x0f00 >> 8),
+		Y:      byte(opcode & 0x00f0 >> 4),
+		N:      byte(opcode & 0x000f),
+		NN:     byte(opcode & 0x00ff),
+		NNN:    opcode & 0x0fff,
+	}
+	ins.Op = decodeOp(opcode, ins.N, ins.NN)
+	return ins
+}
+
+// eightOps are the 8xyN instructions, by N.
+var eightOps = map[byte]Op{
+	0x0: OpLD, 0x1: OpOR, 0x2: OpAND, 0x3: OpXOR, 0x4: OpADD,
+	0x5: OpSUB, 0x6: OpSHR, 0x7: OpSUBN, 0xe: OpSHL,
+}
+
+// fOps are the FxNN instructions, by NN.
+var fOps = map[byte]Op{
+	0x01: OpPLANE, 0x07: OpLDVxDT, 0x0a: OpLDVxK, 0x15: OpLDDTVx, 0x18: OpLDSTVx,
+	0x1e: OpADDI, 0x29: OpLDF, 0x30: OpLDHF, 0x33: OpLDB, 0x55: OpStore,
+	0x65: OpLoad, 0x75: OpStoreRPL, 0x85: OpLoadRPL,
+}
+
+func decodeOp(opcode uint16, n, nn byte) Op {
+	switch opcode & 0xf000 >> 12 {
+	case 0x0:
+		switch opcode {
+		case 0x00e0:
+			return OpCLS
+		case 0x00ee:
+			return OpRET
+		case 0x00fb:
+			return OpSCR
+		case 0x00fc:
+			return OpSCL
+		}
+		if opcode&0xfff0 == 0x00c0 {
+			return OpSCD
+		}
+	case 0x1:
+		return OpJP
+	case 0x2:
+		return OpCALL
+	case 0x3:
+		return OpSEImm
+	case 0x4:
+		return OpSNEImm
+	case 0x5:
+		if n == 0 {
+			return OpSE
+		}
+	case 0x6:
+		return OpLDImm
+	case 0x7:
+		return OpADDImm
+	case 0x8:
+		if op, ok := eightOps[n]; ok {
+			return op
+		}
+	case 0x9:
+		if n == 0 {
+			return OpSNE
+		}
+	case 0xa:
+		return OpLDI
+	case 0xb:
+		return OpJPV0
+	case 0xc:
+		return OpRND
+	case 0xd:
+		return OpDRW
+	case 0xe:
+		switch nn {
+		case 0x9e:
+			return OpSKP
+		case 0xa1:
+			return OpSKNP
+		case 0xf2:
+			return OpSKP2
+		case 0xf5:
+			return OpSKNP2
+		}
+	case 0xf:
+		if op, ok := fOps[nn]; ok {
+			return op
+		}
+	}
+	return OpInvalid
+}
+
+// String returns the instruction in assembly language, like "DRW V2,V3,5", or "???"
+// if it isn't one.
+func (ins Instruction) String() string {
+	if int(ins.Op) >= len(opSyntax) || ins.Op == OpInvalid {
+		return "???"
+	}
+	syntax := opSyntax[ins.Op]
+	if syntax.operands == "" {
+		return syntax.name
+	}
+	// the longest placeholders go first, so nnn isn't taken for n three times.
+	operands := strings.NewReplacer(
+		"Vx", fmt.Sprintf("V%X", ins.X),
+		"Vy", fmt.Sprintf("V%X", ins.Y),
+		"nnn", fmt.Sprintf("%#03x", ins.NNN),
+		"nn", fmt.Sprintf("%#02x", ins.NN),
+		"n", fmt.Sprintf("%d", ins.N),
+		"x", fmt.Sprintf("%d", ins.X),
+	).Replace(syntax.operands)
+	return syntax.name + " " + operands
+}
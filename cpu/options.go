@@ -0,0 +1,46 @@
+package cpu
+
+// An Option changes how NewChip8 sets up a Chip8.
+type Option func(*Chip8)
+
+// LatestFrames is how frames are delivered unless you say otherwise: FrameReady
+// fires when there's a new frame, and Frame returns the newest one. If frames
+// come faster than you read them, the ones you missed are gone -- which is what
+// a display wants, since nobody needs to see a frame that's already out of date.
+func LatestFrames() Option {
+	return func(c *Chip8) {
+		c.video.ready = make(chan struct{}, 1)
+		c.video.frames = nil
+	}
+}
+
+// BufferedFrames sends a copy of every frame down the channel Frames returns,
+// which holds up to n of them, for readers that want to see every frame in order,
+// like a recorder. The Chip8 never waits for the reader: if it falls n frames
+// behind, the oldest frame is dropped to make room. FrameReady and Frame work
+// just like with LatestFrames too.
+func BufferedFrames(n int) Option {
+	if n < 1 {
+		n = 1
+	}
+	return func(c *Chip8) {
+		c.video.ready = make(chan struct{}, 1)
+		c.video.frames = make(chan Frame, n)
+	}
+}
+
+// PolledFrames is for displays that look at the screen when they're good and
+// ready, with Frame or ReadVideoMemory, and don't want to be told about new
+// frames at all. FrameReady returns a channel that never fires.
+func PolledFrames() Option {
+	return func(c *Chip8) {
+		c.video.ready = nil
+		c.video.frames = nil
+	}
+}
+
+// Frames returns the channel every frame goes down, if the Chip8 was made with
+// BufferedFrames, or nil if it wasn't.
+func (c *Chip8) Frames() <-chan Frame {
+	return c.video.frames
+}
@@ -0,0 +1,73 @@
+package cpu
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// spinWindow is how close to a deadline the pacer stops sleeping and starts spinning.
+// OS sleep timers are only accurate to a millisecond or so (and on some systems
+// much worse), which is fine at 60 instructions per second but hopeless at 1000,
+// where an instruction is due every millisecond. So we sleep until we're almost
+// there and then spin the rest of the way.
+const spinWindow = 2 * time.Millisecond
+
+// maxLag is how far behind schedule the pacer will catch up. A hiccup -- the
+// garbage collector, a window being dragged about, the OS having other plans --
+// makes a few instructions late, and the pacer makes up for them by not waiting
+// for the next few, so over a long session no time goes missing. But without a
+// limit, a long pause (say, a debugger breakpoint) would be followed by a burst of
+// instructions executed as fast as possible, so anything more than maxLag behind
+// is written off.
+const maxLag = 250 * time.Millisecond
+
+// A pacer hands out evenly spaced deadlines and waits for them.
+//
+// It replaces a time.Ticker, which can't tick faster than the OS timer resolution
+// and drops ticks when the reader falls behind. The pacer instead keeps an absolute
+// schedule, so an instruction that runs late is made up for by the next wait being shorter.
+// (time.Now reads the monotonic clock too, so the schedule doesn't care if someone
+// changes the time of day under it.)
+type pacer struct {
+	// interval is the time between deadlines, in nanoseconds.
+	// It can be changed from another goroutine, so use sync/atomic.
+	interval int64
+	next     time.Time
+}
+
+func newPacer(interval time.Duration) *pacer {
+	return &pacer{interval: int64(interval)}
+}
+
+// setInterval changes the time between deadlines. It's safe to call from any goroutine.
+func (p *pacer) setInterval(interval time.Duration) {
+	atomic.StoreInt64(&p.interval, int64(interval))
+}
+
+// wait blocks until the next deadline.
+func (p *pacer) wait() {
+	interval := time.Duration(atomic.LoadInt64(&p.interval))
+	now := time.Now()
+	if p.next.IsZero() {
+		// first wait: start a fresh schedule from now.
+		p.next = now
+	} else if now.Sub(p.next) > maxLag {
+		// we fell too far behind: catch up on the last maxLag, and forget the rest.
+		p.next = now.Add(-maxLag)
+	}
+	p.next = p.next.Add(interval)
+
+	if remaining := p.next.Sub(now); remaining > spinWindow {
+		time.Sleep(remaining - spinWindow)
+	}
+	for time.Now().Before(p.next) {
+		runtime.Gosched()
+	}
+}
+
+// restart forgets the current schedule, so the next wait starts counting from scratch.
+// Call it after the Chip8 has been stopped for a while.
+func (p *pacer) restart() {
+	p.next = time.Time{}
+}
@@ -0,0 +1,113 @@
+package cpu
+
+import "fmt"
+
+// A Peripheral is a device you've wired into the Chip8's memory, for building your
+// own Chip-8 variant on top of this one: a clock that tells the time, a few
+// kilobytes of extra storage, a thermometer on a Raspberry Pi -- whatever you can
+// dream up. Attach it to a range of addresses with AttachPeripheral, and from then
+// on, whenever a program reads or writes one of those addresses, the Peripheral
+// gets asked instead of memory.
+//
+// Read and Write are called with the Chip8 locked, on whichever goroutine is
+// running it, so they mustn't call the Chip8's methods, and they should be quick.
+type Peripheral interface {
+	// Read returns the byte at addr, which is somewhere in the peripheral's range.
+	Read(addr uint16) byte
+	// Write stores value at addr, which is somewhere in the peripheral's range.
+	Write(addr uint16, value byte)
+}
+
+// An OpcodePeripheral is a Peripheral with instructions of its own. Any opcode
+// the Chip8 doesn't recognise -- 5xy1, 8xy8, E000 and friends -- is offered to
+// each attached OpcodePeripheral in turn, before the Chip8 gives up on it.
+type OpcodePeripheral interface {
+	Peripheral
+	// Exec carries out opcode, with the data registers to read and write, and
+	// returns true. If it isn't one of its opcodes, it leaves v alone and returns false.
+	Exec(opcode uint16, v *[16]byte) bool
+}
+
+// attachedPeripheral is a Peripheral and where it's attached.
+type attachedPeripheral struct {
+	start, end uint16
+	device     Peripheral
+}
+
+// AttachPeripheral wires device into the addresses from start up to (but not
+// including) end. They have to be somewhere below the stack (at 0xea0, in 4K of
+// memory), and clear of any other peripheral. Attach a device with an empty range (start == end) to
+// give it instructions and no memory at all (see OpcodePeripheral).
+//
+// Programs reach the device when they read and write memory: with LD [I],Vx,
+// LD Vx,[I], LD B,Vx and DRW. Instructions are still fetched from plain memory,
+// and the tools that look at memory from outside -- ReadMemory, WriteMemory,
+// Snapshot and the rest -- see the memory underneath, so peeking at a device
+// never sets it off.
+func (c *Chip8) AttachPeripheral(start, end uint16, device Peripheral) error {
+	if end < start || end > c.stackAddress() {
+		return fmt.Errorf("can't attach a peripheral at %03x-%03x: peripherals go below the stack, at %03x", start, end, c.stackAddress())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.peripherals {
+		if start < p.end && p.start < end {
+			return fmt.Errorf("can't attach a peripheral at %03x-%03x: there's one at %03x-%03x already", start, end, p.start, p.end)
+		}
+	}
+	c.peripherals = append(c.peripherals, attachedPeripheral{start, end, device})
+	return nil
+}
+
+// DetachPeripheral unplugs device, and its addresses go back to being plain memory.
+func (c *Chip8) DetachPeripheral(device Peripheral) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.peripherals {
+		if p.device == device {
+			c.peripherals = append(c.peripherals[:i:i], c.peripherals[i+1:]...)
+			return
+		}
+	}
+}
+
+// readByte reads the byte at addr for the program, from a peripheral if there's one
+// there. c.mu must be held.
+func (c *Chip8) readByte(addr uint16) byte {
+	addr &= c.highestMemoryAddress()
+	for _, p := range c.peripherals {
+		if addr >= p.start && addr < p.end {
+			return p.device.Read(addr)
+		}
+	}
+	return c.memory[addr]
+}
+
+// writeByte writes the byte at addr for the program, to a peripheral if there's one
+// there -- unless it's write protected, in which case it just makes a note of it.
+// c.mu must be held.
+func (c *Chip8) writeByte(addr uint16, value byte) {
+	addr &= c.highestMemoryAddress()
+	if c.writeProtected(addr) {
+		c.protectedWrites = append(c.protectedWrites, ProtectedWrite{PC: c.pc, Address: addr, Value: value})
+		return
+	}
+	for _, p := range c.peripherals {
+		if addr >= p.start && addr < p.end {
+			p.device.Write(addr, value)
+			return
+		}
+	}
+	c.memory[addr] = value
+}
+
+// execPeripheral offers an opcode the Chip8 doesn't know to its peripherals, and
+// returns true if one of them took it. c.mu must be held.
+func (c *Chip8) execPeripheral(opcode uint16) bool {
+	for _, p := range c.peripherals {
+		if device, ok := p.device.(OpcodePeripheral); ok && device.Exec(opcode, &c.v) {
+			return true
+		}
+	}
+	return false
+}
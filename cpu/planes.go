@@ -0,0 +1,88 @@
+package cpu
+
+// The screen can have a second bit plane, like XO-CHIP's: another 64x32 pixels laid
+// over the first, which programs draw on after PLANE 2 (or both at once, after
+// PLANE 3). A pixel that's on in plane 1 only is color 1, on in plane 2 only is
+// color 2, and on in both is color 3; off in both, it's the background, color 0.
+// What the colors are is up to the display. Programs that never say PLANE never
+// touch plane 2, and come out in colors 0 and 1, same as always.
+//
+// Plane 1 is the video memory at the top of memory. Plane 2 doesn't fit in there,
+// so it lives on its own, though it's still in savestates and snapshots.
+
+// numPlanes is how many bit planes the screen has.
+const numPlanes = 2
+
+// A ColorFrame is one picture of the Chip8's screen with all of its bit planes:
+// [0] is plane 1, the same as Frame, and [1] is plane 2.
+type ColorFrame [numPlanes]Frame
+
+// Color returns the color of the pixel at x, y, with 0, 0 at the top-left: bit 0
+// is set if it's on in plane 1, and bit 1 if it's on in plane 2.
+func (f *ColorFrame) Color(x, y int) int {
+	color := 0
+	for plane := range f {
+		if f[plane].Pixel(x, y) {
+			color |= 1 << uint(plane)
+		}
+	}
+	return color
+}
+
+// Colors unpacks the frame into a byte a pixel, its color from 0 to 3, a row at a
+// time from the top -- Pixels, but with colors.
+func (f *ColorFrame) Colors() []byte {
+	colors := make([]byte, FrameWidth*FrameHeight)
+	for i := range colors {
+		colors[i] = byte(f.Color(i%FrameWidth, i/FrameWidth))
+	}
+	return colors
+}
+
+// Monochrome returns true if nothing is on in plane 2, so the frame is only ever
+// colors 0 and 1.
+func (f *ColorFrame) Monochrome() bool {
+	return f[1] == Frame{}
+}
+
+// ColorFrame returns the most recent frame the Chip8 has drawn, with all its bit
+// planes. It's Frame for displays that can show colors: a display should call one or
+// the other, since either one takes the frame the other one was waiting for.
+func (c *Chip8) ColorFrame() ColorFrame {
+	return c.video.latest()
+}
+
+// plane returns the video memory of bit plane n, counting from 0. c.mu must be held.
+func (c *Chip8) plane(n int) []byte {
+	if n == 0 {
+		return c.memory[c.videoMemoryAddress():]
+	}
+	return c.plane2[:]
+}
+
+// planeLists are the planes each value of c.planes selects, worked out ahead of
+// time, so drawing doesn't allocate a list of them on every DRW. Don't change them.
+var planeLists = func() (lists [1 << numPlanes][]int) {
+	for mask := range lists {
+		for n := 0; n < numPlanes; n++ {
+			if mask&(1<<uint(n)) != 0 {
+				lists[mask] = append(lists[mask], n)
+			}
+		}
+	}
+	return lists
+}()
+
+// drawingPlanes returns which planes DRW and CLS work on, counting from 0. c.mu must be held.
+func (c *Chip8) drawingPlanes() []int {
+	return planeLists[int(c.planes)%len(planeLists)]
+}
+
+// colorFrame makes a ColorFrame of the screen as it is now. c.mu must be held.
+func (c *Chip8) colorFrame() ColorFrame {
+	var frame ColorFrame
+	for n := range frame {
+		copy(frame[n][:], c.plane(n))
+	}
+	return frame
+}
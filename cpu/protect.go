@@ -0,0 +1,147 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteProtection says which parts of memory programs aren't allowed to write to,
+// and what happens when they try. It's for development: a game that scribbles
+// over the font, or over its own code, usually didn't mean to, and finding out
+// where it did is a lot easier at the moment it happens than three levels later
+// when the score comes out as garbage.
+//
+// Protected memory stays the way it was; the write just doesn't happen. The zero
+// WriteProtection protects nothing, which is what the Chip8 starts with.
+type WriteProtection struct {
+	// Interpreter protects 0x000-0x1ff, where the interpreter lived on the COSMAC
+	// VIP, and where the font lives now.
+	Interpreter bool
+	// Program protects the program that was loaded, from 0x200 to the end of the
+	// ROM -- for catching self-modifying code, or a stray LD [I],Vx.
+	Program bool
+	// Stop makes a protected write stop the Chip8 with StopError, like an
+	// instruction it didn't know. Without it, the Chip8 carries on, and the write
+	// is only reported to the OnProtectedWrite hook.
+	Stop bool
+}
+
+// protectionNames are what ParseWriteProtection calls WriteProtection's fields.
+var protectionNames = []struct {
+	name  string
+	field func(p *WriteProtection) *bool
+}{
+	{"interpreter", func(p *WriteProtection) *bool { return &p.Interpreter }},
+	{"program", func(p *WriteProtection) *bool { return &p.Program }},
+	{"stop", func(p *WriteProtection) *bool { return &p.Stop }},
+}
+
+// ParseWriteProtection turns a comma-separated list, like "interpreter,program",
+// into a WriteProtection: "interpreter", "program" and "stop" turn on the fields
+// they're named after, and "all" protects both parts of memory.
+func ParseWriteProtection(list string) (WriteProtection, error) {
+	var p WriteProtection
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "all":
+			p.Interpreter, p.Program = true, true
+			continue
+		}
+		found := false
+		for _, protection := range protectionNames {
+			if protection.name == name {
+				*protection.field(&p) = true
+				found = true
+			}
+		}
+		if !found {
+			return WriteProtection{}, fmt.Errorf("%q isn't something to write protect; try interpreter, program, all or stop", name)
+		}
+	}
+	return p, nil
+}
+
+// String returns the protection the way ParseWriteProtection reads it, or "none".
+func (p WriteProtection) String() string {
+	var on []string
+	for _, protection := range protectionNames {
+		if *protection.field(&p) {
+			on = append(on, protection.name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, ",")
+}
+
+// A ProtectedWrite is a program trying to write to write-protected memory.
+type ProtectedWrite struct {
+	// PC is the address of the instruction that tried it.
+	PC uint16
+	// Address is where it tried to write, and Value what it tried to write there.
+	Address uint16
+	Value   byte
+}
+
+func (w ProtectedWrite) String() string {
+	return fmt.Sprintf("the instruction at %03x tried to write %02x to %03x, which is write protected", w.PC, w.Value, w.Address)
+}
+
+// ProtectMemory is an Option that sets the Chip8's write protection (see
+// SetMemoryProtection).
+func ProtectMemory(p WriteProtection) Option {
+	return func(c *Chip8) {
+		c.protection = p
+	}
+}
+
+// SetMemoryProtection sets which parts of memory programs can't write to, and
+// what happens when they try, from the next instruction on.
+//
+// It only holds programs back. WriteMemory, for debuggers and cheats, only minds
+// the interpreter's part of memory, since patching the program is the whole point
+// of most of what it's used for.
+func (c *Chip8) SetMemoryProtection(p WriteProtection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.protection = p
+}
+
+// MemoryProtection returns the Chip8's write protection.
+func (c *Chip8) MemoryProtection() WriteProtection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protection
+}
+
+// OnProtectedWrite sets a function to be called whenever a program tries to write
+// to write-protected memory, whether or not that stops the Chip8. It's a hook like
+// OnInstruction, and everything that says goes for this one too; it's called
+// before OnInstruction is, for the instruction that did it.
+func (c *Chip8) OnProtectedWrite(hook func(w ProtectedWrite)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onProtectedWrite = hook
+}
+
+// writeProtected returns true if programs can't write to addr. c.mu must be held.
+func (c *Chip8) writeProtected(addr uint16) bool {
+	if addr < programStartAddress {
+		return c.protection.Interpreter
+	}
+	return c.protection.Program && int(addr) < int(programStartAddress)+c.romSize
+}
+
+// protectedWriteError returns the error that stops the Chip8, if the instruction
+// that just ran tried to write to protected memory and that's meant to stop it.
+// c.mu must be held.
+func (c *Chip8) protectedWriteError() error {
+	if !c.protection.Stop || len(c.protectedWrites) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v", c.protectedWrites[0])
+}
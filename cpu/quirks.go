@@ -0,0 +1,156 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quirks are the places where Chip-8 interpreters don't agree on what an
+// instruction does. Nobody wrote the Chip-8 down properly until long after
+// everyone had written their own interpreter, and every generation of games was
+// written for whichever interpreter its author had -- so a game that works
+// perfectly on one can be a mess on another. Turn on the quirks a game expects.
+//
+// The zero Quirks is what this Chip8 has always done.
+type Quirks struct {
+	// ShiftVy makes 8xy6 and 8xyE shift Vy and put the result in Vx, like the
+	// COSMAC VIP's original interpreter did, instead of shifting Vx where it is,
+	// like the CHIP-48 and SCHIP did.
+	ShiftVy bool
+	// IncrementI makes Fx55 and Fx65 leave I pointing just past the last register
+	// they saved or loaded, at I+x+1, like the COSMAC VIP did, instead of leaving
+	// I where it was, like the CHIP-48 and SCHIP did.
+	IncrementI bool
+	// JumpVx makes Bxnn jump to xnn plus Vx, like the CHIP-48 and SCHIP did (by
+	// mistake, most likely), instead of to nnn plus V0, like the COSMAC VIP did.
+	// It's the same instruction either way -- the x is just the top digit of nnn.
+	JumpVx bool
+	// Clip makes sprites that run off the edge of the screen get cut off there,
+	// like the COSMAC VIP and SCHIP did, instead of wrapping around to the other
+	// side. Either way a sprite that *starts* off the edge wraps round onto it.
+	Clip bool
+	// ResetVF makes 8xy1, 8xy2 and 8xy3 (OR, AND and XOR) set VF to 0 afterwards,
+	// like the COSMAC VIP did -- not on purpose, but because its interpreter
+	// borrowed the flags register to do the sums. Everyone since leaves VF alone.
+	ResetVF bool
+	// CountCollisions makes Dxyn set VF to how many rows of the sprite collided,
+	// rather than just 1 if any did, like the SCHIP did in its high-resolution
+	// mode. This Chip8 doesn't have a high-resolution mode, so it's all the time
+	// or not at all. Together with Clip, rows cut off the bottom count too.
+	CountCollisions bool
+	// FlagFirst decides what's left in VF after an arithmetic instruction
+	// (8xy4-8xy7 and 8xyE) with VF as its Vx, which has to hold the result and the
+	// flag at once. Normally the flag is written last and wins, like on the COSMAC
+	// VIP; with FlagFirst, the flag is written first and the result wins, like in
+	// some later interpreters that games were tested on.
+	FlagFirst bool
+	// HalfScroll makes SCD, SCR and SCL scroll half as far as they say, like the
+	// SCHIP 1.1 did in low resolution, where it counted in its high-resolution
+	// pixels (see scroll.go). Everyone since counts in the pixels on the screen.
+	HalfScroll bool
+}
+
+// quirkNames are what the quirks are called in ParseQuirks and String.
+var quirkNames = []struct {
+	name  string
+	field func(q *Quirks) *bool
+}{
+	{"shift-vy", func(q *Quirks) *bool { return &q.ShiftVy }},
+	{"increment-i", func(q *Quirks) *bool { return &q.IncrementI }},
+	{"jump-vx", func(q *Quirks) *bool { return &q.JumpVx }},
+	{"clip", func(q *Quirks) *bool { return &q.Clip }},
+	{"vf-reset", func(q *Quirks) *bool { return &q.ResetVF }},
+	{"count-collisions", func(q *Quirks) *bool { return &q.CountCollisions }},
+	{"flag-first", func(q *Quirks) *bool { return &q.FlagFirst }},
+	{"half-scroll", func(q *Quirks) *bool { return &q.HalfScroll }},
+}
+
+// ParseQuirks turns a comma-separated list of quirk names, like "shift-vy", into
+// Quirks. See QuirkNames for the names.
+func ParseQuirks(list string) (Quirks, error) {
+	var q Quirks
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, quirk := range quirkNames {
+			if quirk.name == name {
+				*quirk.field(&q) = true
+				found = true
+			}
+		}
+		if !found {
+			return Quirks{}, fmt.Errorf("%q isn't a quirk; the quirks are %s", name, strings.Join(QuirkNames(), ", "))
+		}
+	}
+	return q, nil
+}
+
+// QuirkNames returns the names of all the quirks, for ParseQuirks.
+func QuirkNames() []string {
+	var names []string
+	for _, quirk := range quirkNames {
+		names = append(names, quirk.name)
+	}
+	return names
+}
+
+// String returns the quirks that are turned on, the way ParseQuirks reads them,
+// or "none".
+func (q Quirks) String() string {
+	var on []string
+	for _, quirk := range quirkNames {
+		if *quirk.field(&q) {
+			on = append(on, quirk.name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, ",")
+}
+
+// Bits returns the quirks that are turned on as a bitmask, bit n for the nth quirk
+// in QuirkNames, the way savestates keep them.
+func (q Quirks) Bits() uint32 {
+	var bits uint32
+	for n, quirk := range quirkNames {
+		if *quirk.field(&q) {
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits
+}
+
+// QuirksFromBits turns a bitmask from Bits back into Quirks. Bits for quirks this
+// version doesn't know about are ignored.
+func QuirksFromBits(bits uint32) Quirks {
+	var q Quirks
+	for n, quirk := range quirkNames {
+		*quirk.field(&q) = bits&(1<<uint(n)) != 0
+	}
+	return q
+}
+
+// SetQuirks sets which quirks the Chip8 has, from the next instruction on.
+func (c *Chip8) SetQuirks(q Quirks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quirks = q
+}
+
+// Quirks returns which quirks the Chip8 has.
+func (c *Chip8) Quirks() Quirks {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quirks
+}
+
+// WithQuirks is an Option that gives the Chip8 quirks from the start.
+func WithQuirks(q Quirks) Option {
+	return func(c *Chip8) {
+		c.quirks = q
+	}
+}
@@ -0,0 +1,129 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Register is one of the Chip8's registers, for GetRegister and SetRegister.
+type Register int
+
+// The registers. V0 through VF are the data registers, and happen to be numbered
+// 0 through 15, so Register(x) is Vx.
+const (
+	V0 Register = iota
+	V1
+	V2
+	V3
+	V4
+	V5
+	V6
+	V7
+	V8
+	V9
+	VA
+	VB
+	VC
+	VD
+	VE
+	VF
+	// I is the address register.
+	I
+	// PC is the program counter.
+	PC
+	// SP is the stack pointer, which points just past the top of the stack.
+	SP
+	// DT and ST are the delay and sound timers.
+	DT
+	ST
+)
+
+// String returns the register's name, the same one Diff uses: "V0" through "VF",
+// "I", "PC", "SP", "DT" or "ST".
+func (r Register) String() string {
+	switch {
+	case r >= V0 && r <= VF:
+		return fmt.Sprintf("V%X", int(r))
+	case r == I:
+		return "I"
+	case r == PC:
+		return "PC"
+	case r == SP:
+		return "SP"
+	case r == DT:
+		return "DT"
+	case r == ST:
+		return "ST"
+	}
+	return fmt.Sprintf("Register(%d)", int(r))
+}
+
+// ParseRegister turns a register's name, in any case, into the Register.
+func ParseRegister(name string) (Register, error) {
+	for r := V0; r <= ST; r++ {
+		if strings.EqualFold(name, r.String()) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("%q isn't a register", name)
+}
+
+// GetRegister returns what's in a register. Registers that only hold a byte
+// come back as a uint16 all the same.
+func (c *Chip8) GetRegister(r Register) (uint16, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r >= V0 && r <= VF:
+		return uint16(c.v[r]), nil
+	case r == I:
+		return c.i, nil
+	case r == PC:
+		return c.pc, nil
+	case r == SP:
+		return c.sp, nil
+	case r == DT:
+		return uint16(c.dt), nil
+	case r == ST:
+		return uint16(c.st), nil
+	}
+	return 0, fmt.Errorf("%v isn't a register", r)
+}
+
+// SetRegister puts a value in a register, in between instructions, for patching
+// things up while you debug. The value has to fit: a byte for V0-VF and the
+// timers, and an address for I and PC. SP has to point somewhere in the stack,
+// at the start of an entry.
+//
+// Setting ST doesn't start or stop the speaker; the Chip8 only does that when the
+// program sets it, or when it runs out.
+func (c *Chip8) SetRegister(r Register, value uint16) error {
+	isByte := (r >= V0 && r <= VF) || r == DT || r == ST
+	switch {
+	case isByte && value > 0xff:
+		return fmt.Errorf("%v only holds a byte, and %#x doesn't fit", r, value)
+	case (r == I || r == PC) && int(value) >= len(c.memory):
+		return fmt.Errorf("%v holds an address, and %#x is past the end of memory", r, value)
+	case r == SP && (value < c.stackAddress() || value > c.videoMemoryAddress() || (value-c.stackAddress())%2 != 0):
+		return fmt.Errorf("SP has to point into the stack, at an even offset from %#03x", c.stackAddress())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r >= V0 && r <= VF:
+		c.v[r] = byte(value)
+	case r == I:
+		c.i = value
+	case r == PC:
+		c.pc = value
+	case r == SP:
+		c.sp = value
+	case r == DT:
+		c.dt = byte(value)
+	case r == ST:
+		c.st = byte(value)
+	default:
+		return fmt.Errorf("%v isn't a register", r)
+	}
+	return nil
+}
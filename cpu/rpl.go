@@ -0,0 +1,126 @@
+package cpu
+
+import "sync"
+
+// numRPLFlags is the number of RPL user flags on the HP-48, which is
+// how many registers (V0 through V7) the SCHIP's Fx75 and Fx85 can save and restore.
+const numRPLFlags = 8
+
+// RPLFlags stores the SCHIP's RPL user flags somewhere they'll survive
+// the Chip8 being switched off.
+//
+// On the HP-48 calculators the SCHIP ran on, the RPL user flags were a little
+// bit of memory that belonged to the calculator rather than the game, and some
+// games used Fx75 to stash things like high scores there -- primitive save data.
+// Connect an RPLFlags (Chip8.ConnectRPLFlags) to keep that save data between runs.
+type RPLFlags interface {
+	// Load returns the flags as they were last saved.
+	Load() [8]byte
+	// Save is called with the new flags after a program changes them: at the end
+	// of the frame it changed them in, however many times it did, and on a
+	// goroutine of its own, so that saving can take its time without holding the
+	// game up. Saves don't overlap, and when the Chip8 stops running, it waits
+	// for the last one to finish.
+	Save(flags [8]byte)
+}
+
+// ConnectRPLFlags connects storage for the RPL user flags, and reads the flags
+// from it. Without any storage connected, the flags are forgotten when the
+// Chip8 goes away. Flags a program's changed that haven't been saved yet are
+// saved in the storage that was connected before, which they belong to.
+func (c *Chip8) ConnectRPLFlags(flags RPLFlags) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.saveRPLFlags()
+	// the new storage might be the old one again, so let it finish saving first.
+	c.rplSaver.wait()
+	c.rplStorage = flags
+	if flags != nil {
+		c.rpl = flags.Load()
+	}
+}
+
+// storeRPLFlags copies V0 through Vx into the RPL flags (Fx75).
+func (c *Chip8) storeRPLFlags(x uint16) {
+	if x >= numRPLFlags {
+		x = numRPLFlags - 1
+	}
+	copy(c.rpl[:x+1], c.v[:x+1])
+	c.rplChanged = true
+}
+
+// loadRPLFlags copies the RPL flags into V0 through Vx (Fx85).
+func (c *Chip8) loadRPLFlags(x uint16) {
+	if x >= numRPLFlags {
+		x = numRPLFlags - 1
+	}
+	copy(c.v[:x+1], c.rpl[:x+1])
+}
+
+// saveRPLFlags hands the flags to rplSaver to save, if a program's changed them
+// since the last time. c.mu must be held.
+func (c *Chip8) saveRPLFlags() {
+	if !c.rplChanged {
+		return
+	}
+	c.rplChanged = false
+	if c.rplStorage != nil {
+		c.rplSaver.save(c.rplStorage, c.rpl)
+	}
+}
+
+// finishSavingRPLFlags saves the flags if they've changed, and waits until
+// they're saved.
+func (c *Chip8) finishSavingRPLFlags() {
+	c.mu.Lock()
+	c.saveRPLFlags()
+	c.mu.Unlock()
+	c.rplSaver.wait()
+}
+
+// rplSaver saves RPL flags off the CPU loop, on a goroutine that runs while there's
+// saving to do. If the flags change again while it's still saving, it saves the
+// newest next, and forgets about any in between.
+type rplSaver struct {
+	mu      sync.Mutex
+	saving  sync.WaitGroup
+	running bool
+	// pending is whether there are flags waiting to be saved in storage.
+	pending bool
+	storage RPLFlags
+	flags   [numRPLFlags]byte
+}
+
+// save saves flags in storage, soon.
+func (s *rplSaver) save(storage RPLFlags, flags [numRPLFlags]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.storage, s.flags, s.pending = storage, flags, true
+	if s.running {
+		return
+	}
+	s.running = true
+	s.saving.Add(1)
+	go s.run()
+}
+
+func (s *rplSaver) run() {
+	defer s.saving.Done()
+	for {
+		s.mu.Lock()
+		if !s.pending {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		storage, flags := s.storage, s.flags
+		s.pending = false
+		s.mu.Unlock()
+		storage.Save(flags)
+	}
+}
+
+// wait waits for whatever's being saved to be saved.
+func (s *rplSaver) wait() {
+	s.saving.Wait()
+}
@@ -0,0 +1,85 @@
+package cpu
+
+import "encoding/binary"
+
+// Scrolling is the SCHIP's trick for games that move the whole screen at once, like
+// side-scrollers: instead of redrawing everything a pixel over, the program says
+// SCR (00FB) or SCL (00FC) to move the screen 4 pixels right or left, or SCD n
+// (00Cn) to move it n rows down. Whatever scrolls off the edge is gone, and what
+// scrolls on is blank. With a second plane (see planes.go), it's only the planes
+// PLANE picked that scroll, like on the XO-CHIP.
+//
+// The SCHIP had two resolutions, and counted scrolls in its high-resolution pixels
+// even in low resolution, where they were half the size -- so a low-resolution game
+// scrolled half as far as it said. XO-CHIP (and most games written since) count in
+// whatever pixels are on the screen. This Chip8 only has the one, low, resolution, so
+// it counts its own pixels, unless the HalfScroll quirk says to do it the SCHIP's way.
+
+// scrollStep is how many pixels SCR and SCL scroll, sideways.
+const scrollStep = 4
+
+// A Scroll is how far the screen has been scrolled, in pixels: X to the right, and
+// Y down. Left and up are negative.
+type Scroll struct {
+	X, Y int
+}
+
+// Scroll moves the picture dx pixels right and dy pixels down (or left and up, for
+// negative numbers). Pixels that go off the edge are lost, and the ones that come
+// in are off.
+func (f *Frame) Scroll(dx, dy int) {
+	var scrolled Frame
+	for y := 0; y < FrameHeight; y++ {
+		from := y - dy
+		if from < 0 || from >= FrameHeight {
+			continue
+		}
+		// a row is 64 pixels, so it shifts as one uint64, the leftmost pixel on top.
+		// (Shifting it 64 or more clears it, which is just what scrolling that far should do.)
+		row := binary.BigEndian.Uint64(f[from*8:])
+		switch {
+		case dx > 0:
+			row >>= uint(dx)
+		case dx < 0:
+			row <<= uint(-dx)
+		}
+		binary.BigEndian.PutUint64(scrolled[y*8:], row)
+	}
+	*f = scrolled
+}
+
+// Scroll scrolls every plane of the frame, like Frame's Scroll.
+func (f *ColorFrame) Scroll(dx, dy int) {
+	for plane := range f {
+		f[plane].Scroll(dx, dy)
+	}
+}
+
+// FrameScroll returns how far the screen had scrolled, all told, by the time the
+// frame last returned by Frame or ColorFrame was drawn. The total means nothing by
+// itself, but take the previous frame's away from it and you know how far the screen
+// scrolled in between -- a hint for displays that would rather slide the picture over
+// smoothly than have it jump. Call it from the same goroutine as Frame.
+func (c *Chip8) FrameScroll() Scroll {
+	return c.video.frontScroll()
+}
+
+// scroll scrolls the planes that are being drawn on, like SCD, SCR and SCL do, by dx
+// and dy of the program's pixels -- halved, with the HalfScroll quirk. c.mu must be held.
+func (c *Chip8) scroll(dx, dy int) {
+	if c.quirks.HalfScroll {
+		// an odd number of the SCHIP's little pixels is half of one of ours, which
+		// can't be done; round towards not scrolling.
+		dx, dy = dx/2, dy/2
+	}
+	for _, plane := range c.drawingPlanes() {
+		var frame Frame
+		screen := c.plane(plane)
+		copy(frame[:], screen)
+		frame.Scroll(dx, dy)
+		copy(screen, frame[:])
+	}
+	c.video.scrolled.X += dx
+	c.video.scrolled.Y += dy
+	c.refreshScreen()
+}
@@ -0,0 +1,145 @@
+package cpu
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// chip8StateJSON is how a Chip8State looks in JSON. Byte arrays are hex strings,
+// which are a lot easier on the eyes (and the network) than JSON's arrays of numbers.
+// The stack and the video memory aren't stored separately since they're part of memory;
+// only the stack's depth is.
+type chip8StateJSON struct {
+	PC         uint16 `json:"pc"`
+	I          uint16 `json:"i"`
+	V          string `json:"v"`
+	DT         byte   `json:"dt"`
+	ST         byte   `json:"st"`
+	StackDepth int    `json:"stackDepth"`
+	Memory     string `json:"memory"`
+	MemorySize int    `json:"memorySize,omitempty"`
+	// Plane2 is left out while it's blank, and Planes while it's plane 1 (its
+	// usual), so states that never went near plane 2 look the way they always did.
+	Plane2        string `json:"plane2,omitempty"`
+	Planes        *byte  `json:"planes,omitempty"`
+	MemoryDiagram string `json:"memoryDiagram,omitempty"`
+	Speed         int    `json:"speed"`
+}
+
+// MarshalJSON encodes the state as JSON, with the registers and memory as hex strings.
+func (s Chip8State) MarshalJSON() ([]byte, error) {
+	j := chip8StateJSON{
+		PC:            s.PC,
+		I:             s.I,
+		V:             hex.EncodeToString(s.V[:]),
+		DT:            s.DT,
+		ST:            s.ST,
+		StackDepth:    len(s.Stack) / 2,
+		Memory:        hex.EncodeToString(s.Memory),
+		MemorySize:    len(s.Memory),
+		MemoryDiagram: s.MemoryDiagram,
+		Speed:         s.Speed,
+	}
+	if s.Plane2 != [256]byte{} {
+		j.Plane2 = hex.EncodeToString(s.Plane2[:])
+	}
+	if s.Planes != 1 {
+		planes := s.Planes
+		j.Planes = &planes
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a state encoded by MarshalJSON.
+func (s *Chip8State) UnmarshalJSON(data []byte) error {
+	var j chip8StateJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := decodeHexInto(s.V[:], j.V, "v"); err != nil {
+		return err
+	}
+	// states from before memory came in sizes don't say, and are all 4K.
+	if j.MemorySize == 0 {
+		j.MemorySize = MemorySize4K
+	}
+	if !validMemorySize(j.MemorySize) {
+		return fmt.Errorf("chip8 state: unsupported memory size %d", j.MemorySize)
+	}
+	s.Memory = make([]byte, j.MemorySize)
+	if err := decodeHexInto(s.Memory, j.Memory, "memory"); err != nil {
+		return err
+	}
+	if j.Plane2 != "" {
+		if err := decodeHexInto(s.Plane2[:], j.Plane2, "plane2"); err != nil {
+			return err
+		}
+	}
+	s.Planes = 1
+	if j.Planes != nil {
+		s.Planes = *j.Planes & 0x3
+	}
+	stack, video := stackAddressFor(j.MemorySize), videoAddressFor(j.MemorySize)
+	// compare as ints: a depth big enough to wrap round in a uint16 would look fine.
+	if j.StackDepth < 0 || j.StackDepth > (int(video)-int(stack))/2 {
+		return fmt.Errorf("chip8 state: invalid stack depth %d", j.StackDepth)
+	}
+	s.PC = j.PC
+	s.I = j.I
+	s.DT = j.DT
+	s.ST = j.ST
+	s.MemorySize = j.MemorySize
+	s.Stack = s.Memory[stack : stack+uint16(j.StackDepth)*2]
+	s.VideoMemory = s.Memory[video:]
+	s.MemoryDiagram = j.MemoryDiagram
+	s.Speed = j.Speed
+	return nil
+}
+
+func decodeHexInto(dst []byte, src string, field string) error {
+	decoded, err := hex.DecodeString(src)
+	if err != nil {
+		return fmt.Errorf("chip8 state: %s: %v", field, err)
+	}
+	if len(decoded) != len(dst) {
+		return fmt.Errorf("chip8 state: %s is %d bytes long, want %d", field, len(decoded), len(dst))
+	}
+	copy(dst, decoded)
+	return nil
+}
+
+// NewChip8FromState returns a Chip8 that picks up exactly where the Chip8 that
+// the state was taken from (with Snapshot) was at the time. Like NewChip8, the Chip8
+// it returns is stopped; call Resume() to carry on running it.
+//
+// This is how you move a running machine between processes: Snapshot it,
+// send the state over as JSON (or gob), and rebuild it on the other side.
+// The Chip8 gets as much memory as the state has, whatever opts say.
+func NewChip8FromState(keyboard Keyboard, speaker Speaker, state Chip8State, opts ...Option) (*Chip8, error) {
+	size := len(state.Memory)
+	if !validMemorySize(size) {
+		return nil, fmt.Errorf("chip8 state: unsupported memory size %d", size)
+	}
+	if len(state.Stack)%2 != 0 || len(state.Stack) > int(videoAddressFor(size))-int(stackAddressFor(size)) {
+		return nil, fmt.Errorf("chip8 state: invalid stack size %d", len(state.Stack))
+	}
+	c := NewChip8(keyboard, speaker, append(opts, WithMemorySize(size))...)
+	c.pc = state.PC
+	c.i = state.I
+	c.v = state.V
+	c.dt = state.DT
+	c.st = state.ST
+	copy(c.memory, state.Memory)
+	// the stack in Stack is the same as the one in Memory, unless someone has been
+	// editing the state by hand -- in which case, they probably meant their edits.
+	copy(c.memory[c.stackAddress():], state.Stack)
+	c.sp = c.stackAddress() + uint16(len(state.Stack))
+	c.plane2 = state.Plane2
+	c.planes = state.Planes & 0x3
+	if state.Speed > 0 {
+		c.SetSpeed(state.Speed)
+	}
+	c.refreshScreen()
+	return c, nil
+}
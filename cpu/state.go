@@ -0,0 +1,327 @@
+package cpu
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand/v2"
+)
+
+// Savestate file format
+//
+// A savestate is a small header followed by the compressed machine state.
+// All numbers are big-endian, like the Chip8 itself.
+//
+//	offset  size  field
+//	0       4     magic: the bytes "C8SS"
+//	4       2     format version (currently 1)
+//	6       2     header length: the number of header bytes that follow this field
+//	8       20    SHA-1 hash of the ROM that was loaded when the state was saved
+//	28      4     quirks: the quirks in effect when the state was saved, bit n
+//	              for the nth of QuirkNames (see Quirks.Bits)
+//	...           (header fields added by later versions)
+//	8+hlen  4     state length: the number of bytes of compressed state that follow
+//	12+hlen ...   state: the machine state, zlib-compressed
+//
+// The machine state, once decompressed, is:
+//
+//	offset  size  field
+//	0       2     PC
+//	2       2     I
+//	4       16    V0 through VF
+//	20      1     DT
+//	21      1     ST
+//	22      2     stack pointer
+//	24      4     memory size, n
+//	28      n     memory, including the stack and the screen
+//	28+n    256   the screen's second bit plane
+//	284+n   1     which planes are drawn on (see planes.go)
+//	...           (state fields added by later versions)
+//
+// The format is meant to outlive the emulator version that wrote it. New fields
+// are only ever added at the end of the header or the end of the state, and readers
+// skip whatever is past the fields they know about -- so an old emulator can
+// still read a savestate from a newer one. The version is only bumped for
+// changes that old readers can't cope with, and readers refuse versions newer
+// than their own.
+const (
+	savestateMagic   = "C8SS"
+	savestateVersion = 1
+	// the header fields this version knows about: ROM hash and quirks.
+	savestateHeaderLength = sha1.Size + 4
+)
+
+// maxMachineStateLength is the most machine state a savestate can decompress to:
+// the biggest this version writes, with 64K of memory, and room to spare for the
+// fields later versions add. Anything bigger isn't a savestate, or is one made to
+// eat all the memory it can.
+const maxMachineStateLength = 28 + MemorySize64K + 257 + 4096
+
+var (
+	// ErrNotSavestate is returned when loading something that isn't a savestate.
+	ErrNotSavestate = errors.New("not a Chip8 savestate")
+	// ErrSavestateVersion is returned when loading a savestate written in a newer,
+	// incompatible version of the format.
+	ErrSavestateVersion = errors.New("savestate was written by a newer version of the emulator")
+	// ErrWrongROM is returned when loading a savestate that was saved while running
+	// a different ROM than the one that's loaded now.
+	ErrWrongROM = errors.New("savestate belongs to a different ROM")
+)
+
+// SavestateHeader holds the information about a savestate that can be read
+// without loading it.
+type SavestateHeader struct {
+	Version uint16
+	ROMHash [sha1.Size]byte
+	// Quirks are the quirks the Chip8 had when the state was saved. They aren't
+	// restored with it -- they're a setting, like the speed -- but a game saved
+	// with one set of quirks may not carry on the same with another.
+	Quirks Quirks
+}
+
+// machineState is everything it takes to put a Chip8 back exactly the way it was:
+// the registers, the timers, the stack pointer, and all of memory (which includes
+// the stack and the screen).
+type machineState struct {
+	PC     uint16
+	I      uint16
+	V      [16]byte
+	DT     byte
+	ST     byte
+	SP     uint16
+	Memory []byte
+	Plane2 [256]byte
+	Planes byte
+
+	// The rest isn't in savestates, only in checkpoints, where it's what makes
+	// playing the frames after one again come out the same: the dice, where the
+	// frame boundaries fall, and the keys. LoadState leaves them as they are.
+	RNG                       rand.PCG
+	TimerPhase                int
+	KeysDown, KeysDown2       uint16
+	WaitingForKey, KeyArrived bool
+	ArrivedKey                KeyCode
+}
+
+// SaveState writes the complete state of the Chip8 to w, so that it can be
+// restored later with LoadState -- a savestate, in emulator-speak.
+// See the top of this file for the format.
+//
+// SaveState is safe to call while the Chip8 is running; the state is captured
+// between two instructions.
+func (c *Chip8) SaveState(w io.Writer) error {
+	c.mu.Lock()
+	var state machineState
+	c.captureMachineState(&state)
+	header := SavestateHeader{Version: savestateVersion, ROMHash: c.romHash, Quirks: c.quirks}
+	c.mu.Unlock()
+
+	var blob bytes.Buffer
+	zw := zlib.NewWriter(&blob)
+	if err := writeMachineState(zw, state); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(savestateMagic)
+	binary.Write(bw, binary.BigEndian, header.Version)
+	binary.Write(bw, binary.BigEndian, uint16(savestateHeaderLength))
+	bw.Write(header.ROMHash[:])
+	binary.Write(bw, binary.BigEndian, header.Quirks.Bits())
+	binary.Write(bw, binary.BigEndian, uint32(blob.Len()))
+	bw.Write(blob.Bytes())
+	return bw.Flush()
+}
+
+// LoadState restores a state saved by SaveState. Execution continues from
+// the restored state: if the Chip8 is running, it keeps running from there.
+//
+// LoadState refuses to load a state that was saved while a different ROM was
+// loaded, returning ErrWrongROM.
+// The Chip8's peripherals, speed and other settings are not part of the saved state
+// and are left as they are.
+func (c *Chip8) LoadState(r io.Reader) error {
+	header, state, err := decodeSavestate(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if header.ROMHash != c.romHash {
+		return ErrWrongROM
+	}
+	if len(state.Memory) != len(c.memory) {
+		return fmt.Errorf("savestate has %d bytes of memory, and this Chip8 has %d", len(state.Memory), len(c.memory))
+	}
+	// a savestate doesn't have what only checkpoints keep, so keep ours.
+	state.RNG, state.TimerPhase = c.rng, c.timerPhase
+	state.KeysDown, state.KeysDown2 = c.keysDown, c.keysDown2
+	state.WaitingForKey, state.KeyArrived, state.ArrivedKey = c.waitingForKey, c.keyArrived, c.arrivedKey
+	c.restoreMachineState(state)
+	return nil
+}
+
+// captureMachineState copies the machine state into state. c.mu must be held.
+// It fills in a state rather than returning one so that checkpoints can reuse theirs.
+func (c *Chip8) captureMachineState(state *machineState) {
+	state.PC = c.pc
+	state.I = c.i
+	state.V = c.v
+	state.DT = c.dt
+	state.ST = c.st
+	state.SP = c.sp
+	// reuse the state's memory, if it has some the right size.
+	state.Memory = append(state.Memory[:0], c.memory...)
+	state.Plane2 = c.plane2
+	state.Planes = c.planes
+	state.RNG = c.rng
+	state.TimerPhase = c.timerPhase
+	state.KeysDown, state.KeysDown2 = c.keysDown, c.keysDown2
+	state.WaitingForKey, state.KeyArrived, state.ArrivedKey = c.waitingForKey, c.keyArrived, c.arrivedKey
+}
+
+// restoreMachineState puts the machine back the way it was in state, which has to
+// have as much memory as the Chip8 does. c.mu must be held.
+func (c *Chip8) restoreMachineState(state machineState) {
+	c.pc = state.PC
+	c.i = state.I
+	c.v = state.V
+	c.dt = state.DT
+	c.st = state.ST
+	c.beepHold = 0
+	c.sp = state.SP
+	copy(c.memory, state.Memory)
+	c.plane2 = state.Plane2
+	c.planes = state.Planes
+	c.rng = state.RNG
+	c.timerPhase = state.TimerPhase
+	c.keysDown, c.keysDown2 = state.KeysDown, state.KeysDown2
+	c.waitingForKey, c.keyArrived, c.arrivedKey = state.WaitingForKey, state.KeyArrived, state.ArrivedKey
+
+	// bring the speaker and the screen in line with the restored state.
+	if c.st > 0 {
+		c.speaker.StartSound()
+	} else {
+		c.speaker.StopSound()
+	}
+	c.refreshScreen()
+}
+
+// ReadSavestateHeader reads just the header of a savestate, so you can check
+// which ROM it belongs to without loading it.
+func ReadSavestateHeader(r io.Reader) (SavestateHeader, error) {
+	var header SavestateHeader
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || string(magic[:]) != savestateMagic {
+		return header, ErrNotSavestate
+	}
+	if err := binary.Read(r, binary.BigEndian, &header.Version); err != nil {
+		return header, ErrNotSavestate
+	}
+	if header.Version > savestateVersion {
+		return header, ErrSavestateVersion
+	}
+	var headerLength uint16
+	if err := binary.Read(r, binary.BigEndian, &headerLength); err != nil {
+		return header, ErrNotSavestate
+	}
+	if headerLength < savestateHeaderLength {
+		return header, ErrNotSavestate
+	}
+	fields := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return header, ErrNotSavestate
+	}
+	copy(header.ROMHash[:], fields)
+	header.Quirks = QuirksFromBits(binary.BigEndian.Uint32(fields[sha1.Size:]))
+	// anything past the fields we know about was added by a later version; skip it.
+	return header, nil
+}
+
+func decodeSavestate(r io.Reader) (SavestateHeader, machineState, error) {
+	var state machineState
+	header, err := ReadSavestateHeader(r)
+	if err != nil {
+		return header, state, err
+	}
+	var blobLength uint32
+	if err := binary.Read(r, binary.BigEndian, &blobLength); err != nil {
+		return header, state, ErrNotSavestate
+	}
+	zr, err := zlib.NewReader(io.LimitReader(r, int64(blobLength)))
+	if err != nil {
+		return header, state, fmt.Errorf("reading savestate: %v", err)
+	}
+	defer zr.Close()
+	raw, err := ioutil.ReadAll(io.LimitReader(zr, maxMachineStateLength+1))
+	if err != nil {
+		return header, state, fmt.Errorf("reading savestate: %v", err)
+	}
+	if len(raw) > maxMachineStateLength {
+		return header, state, fmt.Errorf("reading savestate: the state's bigger than any Chip8's")
+	}
+	state, err = readMachineState(raw)
+	return header, state, err
+}
+
+func writeMachineState(w io.Writer, state machineState) error {
+	fixed := struct {
+		PC, I  uint16
+		V      [16]byte
+		DT, ST byte
+		SP     uint16
+		Size   uint32
+	}{state.PC, state.I, state.V, state.DT, state.ST, state.SP, uint32(len(state.Memory))}
+	if err := binary.Write(w, binary.BigEndian, fixed); err != nil {
+		return err
+	}
+	if _, err := w.Write(state.Memory); err != nil {
+		return err
+	}
+	if _, err := w.Write(state.Plane2[:]); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{state.Planes})
+	return err
+}
+
+func readMachineState(raw []byte) (machineState, error) {
+	var state machineState
+	const fixedLength = 28
+	if len(raw) < fixedLength {
+		return state, fmt.Errorf("reading savestate: state is too short")
+	}
+	state.PC = binary.BigEndian.Uint16(raw[0:])
+	state.I = binary.BigEndian.Uint16(raw[2:])
+	copy(state.V[:], raw[4:20])
+	state.DT = raw[20]
+	state.ST = raw[21]
+	state.SP = binary.BigEndian.Uint16(raw[22:])
+	size := int(binary.BigEndian.Uint32(raw[24:]))
+	if !validMemorySize(size) || len(raw) < fixedLength+size {
+		return state, fmt.Errorf("reading savestate: unsupported memory size %d", size)
+	}
+	state.Memory = append([]byte(nil), raw[fixedLength:fixedLength+size]...)
+	// savestates from before the second plane stop here, and only ever drew on plane 1.
+	state.Planes = 1
+	if planes := raw[fixedLength+size:]; len(planes) >= len(state.Plane2)+1 {
+		copy(state.Plane2[:], planes)
+		state.Planes = planes[len(state.Plane2)] & 0x3
+	}
+	// anything past that was added by a later version; skip it.
+
+	if state.SP < stackAddressFor(size) || state.SP > videoAddressFor(size) || state.SP%2 != 0 {
+		return state, fmt.Errorf("reading savestate: invalid stack pointer %03x", state.SP)
+	}
+	return state, nil
+}
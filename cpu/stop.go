@@ -0,0 +1,103 @@
+package cpu
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// A StopReason is why the Chip8 stopped running. Before I had these, a program
+// that crashed and a program that finished looked exactly the same from the
+// outside: the Chip8 just wasn't running any more, and you had to guess.
+type StopReason int32
+
+const (
+	// StopHalted means somebody called Halt.
+	StopHalted StopReason = iota
+	// StopFinished means the program ran into a 0x0000, which is where programs end.
+	StopFinished
+	// StopBreakpoint means somebody called Break, usually a hook that was
+	// watching for the program to get somewhere.
+	StopBreakpoint
+	// StopError means the Chip8 ran into something it couldn't execute. The
+	// RunResult's Err says what.
+	StopError
+	// StopCancelled means the context passed to ResumeContext was cancelled.
+	StopCancelled
+	// StopAlreadyRunning means the Chip8 was already running when Resume was
+	// called, so Resume didn't do anything at all.
+	StopAlreadyRunning
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopHalted:
+		return "halted"
+	case StopFinished:
+		return "finished"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopError:
+		return "error"
+	case StopCancelled:
+		return "cancelled"
+	case StopAlreadyRunning:
+		return "already running"
+	}
+	return "unknown"
+}
+
+// RunResult is how a run of the Chip8 ended: why it stopped, and where.
+type RunResult struct {
+	Reason StopReason
+	// PC is where the program counter was when the Chip8 stopped: the next
+	// instruction it would execute, or the one it couldn't.
+	PC uint16
+	// Err is what went wrong, for StopError and StopCancelled.
+	Err error
+}
+
+// Break stops a running Chip8 after the current instruction, like Halt, except
+// that Resume says it stopped at a breakpoint. It's for hooks (see OnInstruction)
+// that watch for the program to get somewhere and then stop it there.
+func (c *Chip8) Break() {
+	c.stop(StopBreakpoint)
+}
+
+// stop stops the Chip8 after the current instruction, for the reason given.
+// The reason has to be in place before the flag goes up, since the run loop
+// reads the reason as soon as it sees the flag.
+func (c *Chip8) stop(reason StopReason) {
+	atomic.StoreInt32(&c.stopReason, int32(reason))
+	atomic.StoreInt32(&c.isStoppedFlag, 1)
+}
+
+// cancelOnDone stops the Chip8 when ctx is done. Call the function it returns
+// once the run loop's over, so it stops watching.
+func (c *Chip8) cancelOnDone(ctx context.Context) (stopWatching func()) {
+	if ctx.Done() == nil {
+		// this context can never be cancelled; don't bother watching it.
+		return func() {}
+	}
+	finished, watching := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watching)
+		select {
+		case <-ctx.Done():
+			c.stop(StopCancelled)
+		case <-finished:
+		}
+	}()
+	return func() {
+		close(finished)
+		// wait for the watcher to go, so it can't stop the next run by mistake.
+		<-watching
+	}
+}
+
+// currentPC returns the program counter, waiting for the instruction that's
+// executing, if there is one, to finish.
+func (c *Chip8) currentPC() uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pc
+}
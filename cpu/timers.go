@@ -0,0 +1,131 @@
+package cpu
+
+import "time"
+
+// The delay and sound timers count down sixty times a second -- a second of
+// emulated time, that is, which is Speed() instructions long. Counting down once
+// every Speed()/60 instructions only works out at speeds that divide by 60 (at
+// 500 it would count down 62 and a half times a second, and a beep that should
+// last a second would be over 4% early), so instead the Chip8 keeps a running
+// total of how far through the current sixtieth of a second it is, and carries
+// whatever's left over into the next one. Nothing is ever rounded off, so however
+// long a game runs, its timers and frames never drift.
+//
+// It's emulated time rather than the wall clock because emulated time is what the
+// program sees: in turbo, slow motion, netplay and rollbacks the timers stay in
+// step with the instructions, and the program can't tell anything's different.
+// Keeping the instructions in step with the wall clock is the pacer's job (see pacing.go).
+//
+// It also means pausing freezes everything for free. While the Chip8's stopped --
+// the pause key, a debugger, a window that's lost focus, anything that Halts it --
+// no instructions run, so no time passes: DT, ST and the frame count stay where
+// they were, and carry on from there when it Resumes, as if it had never stopped.
+// Stepping moves them on one instruction's worth at a time, so a delay you're
+// stepping through can't run out underneath you. The one thing that does go on in
+// real time is the speaker, so pauseSound and resumeSound see to that.
+
+// tick moves the Chip8's clock on by one instruction, and returns how many
+// sixtieths of a second went by while it ran: usually none, now and then one, or
+// at speeds under 60, more than one. c.mu must be held.
+func (c *Chip8) tick() int {
+	speed := c.Speed()
+	c.timerPhase += FramesPerSecond
+	ticks := 0
+	for c.timerPhase >= speed {
+		c.timerPhase -= speed
+		ticks++
+	}
+	return ticks
+}
+
+// countDownTimers counts the delay and sound timers down ticks times, and stops
+// the speaker if the sound timer runs out. c.mu must be held.
+func (c *Chip8) countDownTimers(ticks int) {
+	for ; ticks > 0; ticks-- {
+		if c.dt > 0 {
+			c.dt--
+		}
+		if c.st > 0 {
+			c.st--
+			// tell the speaker to stop playing if we reached
+			// the end of the sound timer on this tick -- unless
+			// the beep's being held on to make it long enough to hear.
+			if c.st == 0 && c.beepHold == 0 {
+				c.speaker.StopSound()
+			}
+		} else if c.beepHold > 0 {
+			c.beepHold--
+			if c.beepHold == 0 {
+				c.speaker.StopSound()
+			}
+		}
+	}
+}
+
+// MinimumBeep is an Option that makes every beep last at least d, however short
+// the program asked for it to be. A beep of ST=1 is a sixtieth of a second, which
+// most speakers barely get going in before it's over, and plenty of games use
+// beeps that short as their only way of saying something happened. The sound
+// timer itself still counts down on time, so the program can't tell.
+func MinimumBeep(d time.Duration) Option {
+	return func(c *Chip8) {
+		c.minBeepTicks = beepTicks(d)
+	}
+}
+
+// SetMinimumBeep changes how long the shortest beep lasts (see MinimumBeep). 0
+// lets beeps be as short as the program likes.
+func (c *Chip8) SetMinimumBeep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.minBeepTicks = beepTicks(d)
+}
+
+// beepTicks is d in sixtieths of a second, rounded up.
+func beepTicks(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d*FramesPerSecond + time.Second - 1) / time.Second)
+}
+
+// startBeep sets the sound timer, and starts the speaker if there's anything to
+// hear. If the beep would be shorter than the minimum, the speaker is held on
+// for the difference once the sound timer runs out. c.mu must be held.
+func (c *Chip8) startBeep(st byte) {
+	c.st = st
+	c.beepHold = 0
+	if st == 0 {
+		return
+	}
+	if hold := c.minBeepTicks - int(st); hold > 0 {
+		c.beepHold = hold
+	}
+	c.speaker.StartSound()
+}
+
+// pauseSound stops the speaker when the Chip8 stops, if it's beeping, so a beep
+// that was going doesn't go on for as long as the Chip8's stopped. The sound timer
+// is frozen where it was, and resumeSound picks the beep back up from there.
+func (c *Chip8) pauseSound() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.beeping() {
+		c.speaker.StopSound()
+	}
+}
+
+// resumeSound starts the speaker again when the Chip8 starts, if it was beeping
+// when it stopped.
+func (c *Chip8) resumeSound() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.beeping() {
+		c.speaker.StartSound()
+	}
+}
+
+// beeping reports whether the speaker should be on. c.mu must be held.
+func (c *Chip8) beeping() bool {
+	return c.st > 0 || c.beepHold > 0
+}
@@ -0,0 +1,102 @@
+package cpu
+
+import "sync/atomic"
+
+// frameBuffer is a lock-free triple buffer that carries frames of video memory
+// from the Chip8 CPU to whoever is displaying them. The frames are ColorFrames, every
+// bit plane of them; displays that only do black and white just look at plane 1.
+//
+// The old way of doing this was to push every frame down an unbuffered channel,
+// which meant that a slow display (or no display at all) would stop the CPU dead
+// in its tracks until somebody got around to reading the frame. With a triple buffer
+// the CPU always has a spare buffer to write into, so it never has to wait: it writes
+// the new frame into its back buffer and swaps it with the 'pending' buffer in the middle.
+// The display swaps the pending buffer with its front buffer whenever it gets around to it.
+// If the display is slow, frames it didn't get to are simply overwritten -- latest frame wins.
+//
+// The index of the pending buffer and a 'fresh' flag are packed into a single uint32
+// so both can be swapped in one atomic operation.
+type frameBuffer struct {
+	buffers [3]ColorFrame
+	// scrolls are how far the screen had scrolled when each buffer's frame was
+	// drawn, and scrolled is how far it's scrolled now; only the writer touches
+	// that (see FrameScroll).
+	scrolls  [3]Scroll
+	scrolled Scroll
+	// back is only touched by the writer, front is only touched by the reader.
+	back  uint32
+	front uint32
+	// pending holds the index of the middle buffer in its low two bits,
+	// and the fresh flag in the bit above them.
+	pending uint32
+
+	// ready receives a value whenever a new frame is published. It has room
+	// for one notification, so the writer never blocks on it either. It's nil
+	// when frames are only polled for (see PolledFrames).
+	ready chan struct{}
+	// frames gets a copy of every frame, when frames are buffered (see BufferedFrames).
+	frames chan Frame
+}
+
+const frameFreshFlag uint32 = 1 << 2
+
+func newFrameBuffer() *frameBuffer {
+	return &frameBuffer{
+		back:    0,
+		pending: 1,
+		front:   2,
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// publish copies the frame into the back buffer and makes it the pending frame.
+// publish never blocks.
+func (f *frameBuffer) publish(frame ColorFrame) {
+	f.buffers[f.back] = frame
+	f.scrolls[f.back] = f.scrolled
+	old := atomic.SwapUint32(&f.pending, f.back|frameFreshFlag)
+	f.back = old &^ frameFreshFlag
+	// notify the reader, unless there is already a notification it hasn't picked up.
+	if f.ready != nil {
+		select {
+		case f.ready <- struct{}{}:
+		default:
+		}
+	}
+	if f.frames != nil {
+		f.queue(frame[0])
+	}
+}
+
+// queue puts the frame on the frames channel. If the channel's full,
+// the oldest frame makes way for it, so the writer still never blocks.
+func (f *frameBuffer) queue(frame Frame) {
+	for {
+		select {
+		case f.frames <- frame:
+			return
+		default:
+		}
+		// full up: drop the oldest, unless the reader just beat us to it.
+		select {
+		case <-f.frames:
+		default:
+		}
+	}
+}
+
+// latest returns the most recently published frame. If no new frame has been
+// published since the last call, it returns the same frame as last time.
+func (f *frameBuffer) latest() ColorFrame {
+	if atomic.LoadUint32(&f.pending)&frameFreshFlag != 0 {
+		old := atomic.SwapUint32(&f.pending, f.front)
+		f.front = old &^ frameFreshFlag
+	}
+	return f.buffers[f.front]
+}
+
+// frontScroll returns how far the screen had scrolled when the frame latest last
+// returned was drawn. Like latest, it's for the reader only.
+func (f *frameBuffer) frontScroll() Scroll {
+	return f.scrolls[f.front]
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// startCrowd lets the crowd vote on keys through server, deciding every window.
+// aliases names keys for voting, like "up=1,down=4".
+func startCrowd(server *control.Server, keypad *control.Keypad, window time.Duration, aliases string) error {
+	crowd := control.NewCrowd(keypad, window)
+	for _, alias := range strings.Split(aliases, ",") {
+		if alias == "" {
+			continue
+		}
+		parts := strings.SplitN(alias, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("crowd alias %q should look like name=key", alias)
+		}
+		key, err := strconv.ParseUint(parts[1], 16, 4)
+		if err != nil {
+			return fmt.Errorf("crowd alias %q: %q isn't a key", alias, parts[1])
+		}
+		crowd.Alias(parts[0], cpu.KeyCode(key))
+	}
+	server.HandleCrowd(crowd)
+	// the crowd plays for as long as we do.
+	go crowd.Run(nil)
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/plugins"
+)
+
+const (
+	// codePanelWidth and memoryPanelWidth are how wide the debugger's panels are, in
+	// the register view's pixels: a character is 4 pixels wide.
+	codePanelWidth   = 22 * 4
+	memoryPanelWidth = 30 * 4
+	debugPanelsWidth = codePanelWidth + memoryPanelWidth
+	// debugPanelLines is how many lines of code or memory fit under a panel's heading.
+	debugPanelLines = (registerViewHeight - 16) / 8
+	// memoryPanelColumns is how many bytes go on a line of the memory panel.
+	memoryPanelColumns = 8
+	// pluginPanelWidth is how wide each plugin's panel is (see the plugins package).
+	pluginPanelWidth = 30 * 4
+)
+
+// drawCode draws the code panel at x: the instructions around PC, disassembled,
+// with the one at PC in green.
+//
+// The Chip8 can't tell code from data, and neither can we, so this just takes the
+// two bytes at each even step from PC and hopes. Data comes out as nonsense
+// instructions, which is how you know it's data.
+func (v *registerView) drawCode(state cpu.Chip8State, x int) {
+	c := v.canvas
+	c.text(x, 4, "CODE", viewText)
+	start := int(state.PC) - debugPanelLines/2*2
+	for line := 0; line < debugPanelLines; line++ {
+		addr := start + line*2
+		if addr < 0 || addr+1 >= len(state.Memory) {
+			continue
+		}
+		opcode := uint16(state.Memory[addr])<<8 | uint16(state.Memory[addr+1])
+		col := viewText
+		if addr == int(state.PC) {
+			col = viewPC
+		}
+		text := fmt.Sprintf("%03X %04X %s", addr, opcode, strings.ToUpper(cpu.Disassemble(opcode)))
+		c.text(x, 16+line*8, text, col)
+	}
+}
+
+// drawMemory draws the memory panel at x: a hex dump of the memory around I, which
+// is where most of the action is, with the byte at I in blue and anything just
+// written flashing.
+func (v *registerView) drawMemory(state cpu.Chip8State, x int) {
+	c := v.canvas
+	c.text(x, 4, "MEMORY AT I", viewText)
+	start := int(state.I)&^(memoryPanelColumns-1) - memoryPanelColumns*4
+	if last := len(state.Memory) - debugPanelLines*memoryPanelColumns; start > last {
+		start = last
+	}
+	if start < 0 {
+		start = 0
+	}
+	for line := 0; line < debugPanelLines; line++ {
+		y := 16 + line*8
+		addr := start + line*memoryPanelColumns
+		c.text(x, y, fmt.Sprintf("%03X", addr), viewText)
+		for i := 0; i < memoryPanelColumns; i++ {
+			col := fade(viewText, v.flashMemory[addr+i])
+			if addr+i == int(state.I) {
+				col = viewI
+			}
+			c.text(x+16+i*12, y, fmt.Sprintf("%02X", state.Memory[addr+i]), col)
+		}
+	}
+}
+
+// drawPluginPanel draws a plugin's panel at x: its name, and the lines it has to say,
+// as many as fit. The font only has capitals, so that's what they come out in.
+func (v *registerView) drawPluginPanel(panel plugins.DebugPanelPlugin, state cpu.Chip8State, x int) {
+	c := v.canvas
+	c.text(x, 4, strings.ToUpper(panel.Name()), viewText)
+	for line, text := range panel.Panel(state) {
+		if line == debugPanelLines {
+			break
+		}
+		if len(text) > pluginPanelWidth/4-1 {
+			text = text[:pluginPanelWidth/4-1]
+		}
+		c.text(x, 16+line*8, strings.ToUpper(text), viewText)
+	}
+}
@@ -0,0 +1,194 @@
+// Package demo plays demo scripts: which keys to press, and when, to make a game look
+// like someone's playing it -- for attract mode, where games take turns on screen
+// with nobody at the keys.
+//
+// Scripts are plain text files, named after the ROM they're for (pong.ch8's is
+// pong.demo) and kept next to it:
+//
+//	# lines starting with # are comments
+//	# at frame 60, hold key 5 for 10 frames (to start the game)
+//	60 5 10
+//	# then up for a while, and down for a while
+//	90 1 30
+//	130 4 30
+//	# and from frame 90 on, do it all again every 80 frames
+//	loop 90 80
+//
+// Times are in frames since the ROM was loaded, 60 to a second of the game's time,
+// so a script plays the same whatever speed the emulator runs at.
+//
+// Scripts don't have to be written by hand: a Recorder writes one down while you
+// play, which is how keyboard macros are made (see the metadata package).
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Press is a key held down for a while.
+type Press struct {
+	// Frame is the frame it's pressed on, and Frames how many frames it's held for.
+	Frame, Frames uint64
+	Key           cpu.KeyCode
+}
+
+// String writes the press the way a script does: "60 5 10".
+func (p Press) String() string {
+	return fmt.Sprintf("%d %x %d", p.Frame, p.Key, p.Frames)
+}
+
+// A Script is a demo script, parsed.
+type Script struct {
+	Presses []Press
+	// LoopFrom and LoopEvery say to repeat the presses from frame LoopFrom on every
+	// LoopEvery frames, forever. If LoopEvery is 0, the script plays once.
+	LoopFrom, LoopEvery uint64
+}
+
+// Parse reads a demo script.
+func Parse(r io.Reader) (*Script, error) {
+	script := new(Script)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "loop" {
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: a loop is \"loop <from frame> <every frames>\"", n)
+			}
+			from, err1 := strconv.ParseUint(fields[1], 10, 64)
+			every, err2 := strconv.ParseUint(fields[2], 10, 64)
+			if err1 != nil || err2 != nil || every == 0 {
+				return nil, fmt.Errorf("line %d: a loop's frames are whole numbers, and it has to be at least a frame long", n)
+			}
+			script.LoopFrom, script.LoopEvery = from, every
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: a press is \"<frame> <key> <frames held>\"", n)
+		}
+		frame, err1 := strconv.ParseUint(fields[0], 10, 64)
+		key, err2 := strconv.ParseUint(fields[1], 16, 4)
+		frames, err3 := strconv.ParseUint(fields[2], 10, 64)
+		if err1 != nil || err3 != nil {
+			return nil, fmt.Errorf("line %d: frames are whole numbers", n)
+		}
+		if err2 != nil {
+			return nil, fmt.Errorf("line %d: %q isn't a key; keys are 0 to f", n, fields[1])
+		}
+		script.Presses = append(script.Presses, Press{Frame: frame, Frames: frames, Key: cpu.KeyCode(key)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Held returns the key the script holds down on frame, or cpu.KeyNone. If two
+// presses overlap, the one that started last wins.
+func (s *Script) Held(frame uint64) cpu.KeyCode {
+	if s.LoopEvery > 0 && frame >= s.LoopFrom+s.LoopEvery {
+		frame = s.LoopFrom + (frame-s.LoopFrom)%s.LoopEvery
+	}
+	held := cpu.KeyNone
+	for _, press := range s.Presses {
+		if frame >= press.Frame && frame < press.Frame+press.Frames {
+			held = press.Key
+		}
+	}
+	return held
+}
+
+// End returns the frame the last press is over by: how long the script is, if it
+// doesn't loop.
+func (s *Script) End() uint64 {
+	var end uint64
+	for _, press := range s.Presses {
+		if press.Frame+press.Frames > end {
+			end = press.Frame + press.Frames
+		}
+	}
+	return end
+}
+
+// Lines returns the script the way Parse reads it, a line at a time.
+func (s *Script) Lines() []string {
+	var lines []string
+	for _, press := range s.Presses {
+		lines = append(lines, press.String())
+	}
+	if s.LoopEvery > 0 {
+		lines = append(lines, fmt.Sprintf("loop %d %d", s.LoopFrom, s.LoopEvery))
+	}
+	return lines
+}
+
+// A Recorder writes down the keys held down, frame by frame, as a script, counting
+// the frames from the first one it's told about.
+type Recorder struct {
+	started bool
+	// start is the frame the recording started on, and last the last one recorded,
+	// counting from start.
+	start, last uint64
+	// held is the keys held on the last frame recorded, and down the index in
+	// presses of the press each one's in the middle of.
+	held    uint16
+	down    [16]int
+	presses []Press
+}
+
+// Record writes down that the keys in held (bit n for key n) were held down on
+// frame. Call it every frame, or as near as you can: a frame that's recorded
+// twice counts once, and one that's missed counts as whatever was held before it.
+func (r *Recorder) Record(frame uint64, held uint16) {
+	if !r.started {
+		r.started, r.start = true, frame
+	}
+	if frame < r.start {
+		return
+	}
+	at := frame - r.start
+	r.last = at
+	for key := 0; key < 16; key++ {
+		bit := uint16(1) << uint(key)
+		switch {
+		case held&bit != 0 && r.held&bit == 0:
+			r.down[key] = len(r.presses)
+			r.presses = append(r.presses, Press{Frame: at, Key: cpu.KeyCode(key)})
+		case held&bit == 0 && r.held&bit != 0:
+			r.release(r.presses, key, at)
+		}
+	}
+	r.held = held
+}
+
+// Script returns what's been recorded so far, with any keys that are still held
+// let go of after the last frame recorded.
+func (r *Recorder) Script() *Script {
+	presses := append([]Press(nil), r.presses...)
+	for key := 0; key < 16; key++ {
+		if r.held&(1<<uint(key)) != 0 {
+			r.release(presses, key, r.last+1)
+		}
+	}
+	return &Script{Presses: presses}
+}
+
+// release ends key's press in presses on frame. A key that was down and up again
+// on the same frame -- the Chip8 was paused, say -- still gets a frame, or the
+// program would never see it.
+func (r *Recorder) release(presses []Press, key int, frame uint64) {
+	p := &presses[r.down[key]]
+	p.Frames = frame - p.Frame
+	if p.Frames == 0 {
+		p.Frames = 1
+	}
+}
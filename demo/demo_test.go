@@ -0,0 +1,84 @@
+package demo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/demo"
+)
+
+// Parse / Held
+// should hold each key down for as many frames as it says, from the frame it says
+// should go round again from the loop's start, every loop's length
+// should say which line's wrong in a script that is
+func TestScript(t *testing.T) {
+	script, err := demo.Parse(strings.NewReader(`
+		# start the game, then up and down
+		60 5 10
+		90 1 30
+		130 4 30
+		loop 90 80
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		frame uint64
+		key   cpu.KeyCode
+	}{
+		{0, cpu.KeyNone},
+		{60, 5},
+		{69, 5},
+		{70, cpu.KeyNone},
+		{90, 1},
+		{130, 4},
+		{165, cpu.KeyNone},
+		{170, 1},      // 90 again
+		{170 + 40, 4}, // 130 again
+		{90 + 80*100 + 5, 1},
+	} {
+		if got := script.Held(want.frame); got != want.key {
+			t.Errorf("frame %d: key %X is held, want %X", want.frame, got, want.key)
+		}
+	}
+
+	for _, bad := range []string{"60 g 10", "sixty 5 10", "60 5", "loop 90 0"} {
+		_, err := demo.Parse(strings.NewReader("# fine\n" + bad))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: got error %v, want one about line 2", bad, err)
+		}
+	}
+}
+
+// Recorder
+// should write down each key's presses, from the frame it started recording on
+// should let go of keys still held when the recording stops
+// should write the script out so it parses back the same
+func TestRecorder(t *testing.T) {
+	var r demo.Recorder
+	for frame := uint64(100); frame < 130; frame++ {
+		var held uint16
+		switch {
+		case frame >= 105 && frame < 110:
+			held = 1 << 5
+		case frame >= 120:
+			held = 1<<1 | 1<<4
+		}
+		r.Record(frame, held)
+		// paused frames get recorded twice.
+		r.Record(frame, held)
+	}
+	script := r.Script()
+	want := []string{"5 5 5", "20 1 10", "20 4 10"}
+	if got := script.Lines(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("recorded %q, want %q", got, want)
+	}
+	if script.End() != 30 || script.Held(7) != 5 || script.Held(12) != cpu.KeyNone {
+		t.Errorf("the recording ends at %d, and holds %X on frame 7", script.End(), script.Held(7))
+	}
+	again, err := demo.Parse(strings.NewReader(strings.Join(script.Lines(), "\n")))
+	if err != nil || strings.Join(again.Lines(), ",") != strings.Join(want, ",") {
+		t.Errorf("parsed back %v, %v", again, err)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build fyne
+// +build fyne
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mpingram/chip8/fyneui"
+)
+
+func init() {
+	commands["desktop"] = command{
+		usage: "play in a desktop app, with menus to open ROMs and a settings dialog (see the fyneui package)",
+		run:   desktopCommand,
+	}
+}
+
+func desktopCommand(args []string) error {
+	flags := flag.NewFlagSet("desktop", flag.ExitOnError)
+	dataDir := flags.String("dir", "", "keep the settings under `folder` (default: the usual place on this system)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 desktop [flags] [rom.ch8]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return fyneui.Run(openStore(*dataDir), flags.Arg(0))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/devices"
+)
+
+// deviceFlags are the devices (see the devices package) asked for on the command line.
+type deviceFlags struct {
+	// clock puts a real-time clock at devices.ClockAddress.
+	clock bool
+	// serial puts a serial port at devices.SerialAddress, writing to this file, or
+	// stdout if it's "-".
+	serial string
+}
+
+// attachDevices wires the devices in flags into c8. It returns the serial port,
+// if there is one, for the HTTP API.
+func attachDevices(c8 *cpu.Chip8, flags deviceFlags) *devices.Serial {
+	if flags.clock {
+		clock := devices.NewClock(devices.ClockAddress, nil)
+		if err := c8.AttachPeripheral(devices.ClockAddress, devices.ClockAddress+devices.ClockSize, clock); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flags.serial == "" {
+		return nil
+	}
+	var out io.Writer = os.Stdout
+	if flags.serial != "-" {
+		file, err := os.OpenFile(flags.serial, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// it's open for as long as the emulator is.
+		out = file
+	}
+	serial := devices.NewSerial(out)
+	if err := c8.AttachPeripheral(devices.SerialAddress, devices.SerialAddress+1, serial); err != nil {
+		log.Fatal(err)
+	}
+	return serial
+}
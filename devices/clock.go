@@ -0,0 +1,72 @@
+// Package devices has peripherals to wire into a Chip8 (see cpu.Peripheral), for
+// ROMs written for a Chip-8 with a few more bits and pieces than the original had.
+// None of them are attached unless you ask: a ROM that doesn't know they're there
+// is none the wiser.
+package devices
+
+import "time"
+
+// ClockAddress is where a Clock is attached unless you have somewhere else in
+// mind: just under the stack, well out of the way of any program that doesn't
+// know it's there.
+const ClockAddress = 0xe90
+
+// ClockSize is how many bytes of memory a Clock takes up.
+const ClockSize = 7
+
+// A Clock is a real-time clock, for clock and calendar ROMs. It's 7 bytes of memory:
+//
+//	+0  the year, counting from 2000
+//	+1  the month, 1 to 12
+//	+2  the day of the month, 1 to 31
+//	+3  the hour, 0 to 23
+//	+4  the minute, 0 to 59
+//	+5  the second, 0 to 59
+//	+6  the day of the week, 0 (Sunday) to 6 (Saturday)
+//
+// Reading the year reads the time, and the other bytes stay at that time until the
+// year is read again, so the usual way of reading it -- LD I at the clock and then
+// LD V6,[I] -- gets all seven bytes at the same moment, and never 10:59 when it's
+// 11:00. Writing to it does nothing; the clock knows what time it is.
+type Clock struct {
+	// start is where the clock is attached, and now tells it the time.
+	start uint16
+	now   func() time.Time
+	time  [ClockSize]byte
+}
+
+// NewClock returns a clock to attach at start, which tells the time with now
+// (time.Now, unless you're testing it or fancy living in another decade).
+func NewClock(start uint16, now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+	c := &Clock{start: start, now: now}
+	c.latch()
+	return c
+}
+
+// latch reads the time into the clock's bytes.
+func (c *Clock) latch() {
+	t := c.now()
+	c.time = [ClockSize]byte{
+		byte(t.Year() - 2000), byte(t.Month()), byte(t.Day()),
+		byte(t.Hour()), byte(t.Minute()), byte(t.Second()),
+		byte(t.Weekday()),
+	}
+}
+
+// Read returns one of the clock's bytes.
+func (c *Clock) Read(addr uint16) byte {
+	offset := int(addr) - int(c.start)
+	if offset < 0 || offset >= ClockSize {
+		return 0
+	}
+	if offset == 0 {
+		c.latch()
+	}
+	return c.time[offset]
+}
+
+// Write does nothing.
+func (c *Clock) Write(addr uint16, value byte) {}
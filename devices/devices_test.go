@@ -0,0 +1,73 @@
+package devices_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/devices"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// Clock
+// should give a program reading it with LD Vx,[I] the date and time, all from the same moment
+func TestClock(t *testing.T) {
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	if err := c8.Load([]byte{
+		0xae, 0x90, // LD I e90
+		0xf6, 0x65, // LD V6 [I]
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ticking := time.Date(2026, time.March, 14, 23, 59, 59, 0, time.UTC)
+	clock := devices.NewClock(devices.ClockAddress, func() time.Time {
+		ticking = ticking.Add(time.Second)
+		return ticking
+	})
+	if err := c8.AttachPeripheral(devices.ClockAddress, devices.ClockAddress+devices.ClockSize, clock); err != nil {
+		t.Fatal(err)
+	}
+	c8.Step()
+	c8.Step()
+	want := [16]byte{26, 3, 15, 0, 0, 1, 0}
+	if v := c8.Snapshot().V; v != want {
+		t.Errorf("read the clock as %v, want %v", v[:7], want[:7])
+	}
+}
+
+// Serial
+// should send every byte the program writes to it out of the port, in order
+func TestSerial(t *testing.T) {
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	if err := c8.Load([]byte{
+		0xae, 0x98, // LD I e98
+		0x60, 'h', // LD V0 'h'
+		0xf0, 0x55, // LD [I] V0
+		0x60, 'i', // LD V0 'i'
+		0xf0, 0x55, // LD [I] V0
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	serial := devices.NewSerial(&out)
+	if err := c8.AttachPeripheral(devices.SerialAddress, devices.SerialAddress+1, serial); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c8.Step()
+	}
+	if out.String() != "hi" || string(serial.Output()) != "hi" {
+		t.Errorf("the port sent %q and remembers %q, want \"hi\"", out.String(), serial.Output())
+	}
+}
@@ -0,0 +1,58 @@
+package devices
+
+import (
+	"io"
+	"sync"
+)
+
+// SerialAddress is where a Serial port is attached unless you have somewhere else
+// in mind: just after the Clock, if there is one.
+const SerialAddress = 0xe98
+
+// serialBacklog is how much of what's been written to a Serial port it remembers,
+// for Output.
+const serialBacklog = 4096
+
+// A Serial port is a byte of memory that goes somewhere else: every byte a
+// program writes to it comes out of the port, in order, like println for ROMs.
+// It's a way of saying "got here" or "score=" and a number without drawing over
+// the game. Reading it gives 0.
+//
+// Writing a byte with LD [I],V0 is all it takes; LD [I],Vx writes V0 to Vx one
+// after another, but only the first lands on the port -- the rest go to whatever's
+// after it in memory -- so send one byte at a time.
+type Serial struct {
+	mu  sync.Mutex
+	out io.Writer
+	// backlog is the last serialBacklog bytes written, oldest first.
+	backlog []byte
+}
+
+// NewSerial returns a serial port that writes to out, which may be nil if
+// Output is all you want.
+func NewSerial(out io.Writer) *Serial {
+	return &Serial{out: out}
+}
+
+// Read gives 0; there's nothing to read.
+func (s *Serial) Read(addr uint16) byte { return 0 }
+
+// Write sends a byte out of the port.
+func (s *Serial) Write(addr uint16, value byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.out != nil {
+		s.out.Write([]byte{value})
+	}
+	s.backlog = append(s.backlog, value)
+	if len(s.backlog) > serialBacklog {
+		s.backlog = append(s.backlog[:0], s.backlog[len(s.backlog)-serialBacklog:]...)
+	}
+}
+
+// Output returns the last 4K or so written to the port.
+func (s *Serial) Output() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.backlog...)
+}
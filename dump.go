@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+func init() {
+	commands["dump"] = command{
+		usage: "run a ROM for a while without a window, then dump (part of) its memory to a file",
+		run:   dumpCommand,
+	}
+}
+
+func dumpCommand(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	cycles := flags.Int("cycles", 0, "number of instructions to run before dumping")
+	start := flags.String("start", "0x000", "address of the first byte to dump")
+	length := flags.Int("length", 0, "number of bytes to dump (default everything from -start to the end of memory)")
+	memory := flags.String("memory", "4k", "how much `memory` the Chip8 has: 4k, or 64k")
+	asHex := flags.Bool("hex", false, "write a hex dump instead of raw bytes")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 dump [flags] rom.ch8 output-file\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	startAddr, err := parseAddress(*start)
+	if err != nil {
+		return err
+	}
+	memorySize, err := cpu.ParseMemorySize(*memory)
+	if err != nil {
+		return err
+	}
+	if *length == 0 {
+		*length = memorySize - int(startAddr)
+	}
+
+	rom, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	c8 := cpu.NewChip8(noKeyboard{}, silentSpeaker{}, cpu.WithMemorySize(memorySize))
+	c8.SetLogLevel(cpu.LogNone)
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+	c8.StepN(*cycles)
+
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := c8.DumpMemory(out, startAddr, *length, dumpFormat(*asHex)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func dumpFormat(asHex bool) cpu.DumpFormat {
+	if asHex {
+		return cpu.DumpHex
+	}
+	return cpu.DumpBinary
+}
+
+// A patch is a file to load into memory at some address, given on the command
+// line as file@address, like sprites.hex@0x300. Files ending in .hex are read
+// as hex dumps; anything else as raw bytes.
+type patch struct {
+	path    string
+	address uint16
+}
+
+// patchList collects the -patch flags.
+type patchList []patch
+
+func (p *patchList) String() string {
+	parts := make([]string, len(*p))
+	for i, pt := range *p {
+		parts[i] = fmt.Sprintf("%s@%#03x", pt.path, pt.address)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p *patchList) Set(value string) error {
+	at := strings.LastIndex(value, "@")
+	if at < 0 {
+		return fmt.Errorf("want file@address, got %q", value)
+	}
+	addr, err := parseAddress(value[at+1:])
+	if err != nil {
+		return err
+	}
+	*p = append(*p, patch{path: value[:at], address: addr})
+	return nil
+}
+
+// apply loads each patch into c8's memory.
+func (p patchList) apply(c8 *cpu.Chip8) error {
+	for _, pt := range p {
+		f, err := os.Open(pt.path)
+		if err != nil {
+			return err
+		}
+		_, err = c8.LoadMemory(f, pt.address, dumpFormat(filepath.Ext(pt.path) == ".hex"))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("patching %s: %v", pt.path, err)
+		}
+	}
+	return nil
+}
+
+// dumpAll stores a hex dump of all of c8's memory as dumps/<ROM name>/<time>.hex,
+// returning the name of the file it's in.
+func dumpAll(c8 *cpu.Chip8, store *storage.Dir, romPath string) (string, error) {
+	key := fmt.Sprintf("dumps/%s/%s.hex", romFolder(romPath), time.Now().Format("20060102-150405"))
+	var dump bytes.Buffer
+	if err := c8.DumpMemory(&dump, 0, c8.MemorySize(), cpu.DumpHex); err != nil {
+		return "", err
+	}
+	if err := store.Put(key, dump.Bytes()); err != nil {
+		return "", err
+	}
+	return store.Path(key)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpingram/chip8/storage"
+)
+
+// openStore returns the storage everything the emulator writes goes in: savestates,
+// autosaves, memory dumps and the rest, each in a folder of its own with a folder for
+// each ROM in that, like saves/Pong/slot1.state. It's kept under dir if that's given,
+// or otherwise wherever this system usually keeps such things.
+//
+// Before there was anywhere else to put them, everything went in the current directory;
+// if there's a saves folder there, we carry on using it rather than lose anybody's saves.
+func openStore(dir string) *storage.Dir {
+	if dir != "" {
+		return storage.NewDir(dir)
+	}
+	if info, err := os.Stat("saves"); err == nil && info.IsDir() {
+		return storage.NewDir(".")
+	}
+	root, err := storage.DefaultRoot("chip8")
+	if err != nil {
+		log.Printf("can't find anywhere to keep files (%v), so they're going in the current directory", err)
+		return storage.NewDir(".")
+	}
+	return storage.NewDir(root)
+}
+
+// romFolder returns the name of the folder a ROM's files go in: its name, without
+// the folders or the .ch8, or anything else that doesn't belong in a file name.
+func romFolder(romPath string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '-'
+		}
+		return r
+	}, romLabel(romPath))
+	if name == "" || name == "." || name == ".." {
+		return "rom"
+	}
+	return name
+}
+
+// romLabel is what a ROM's called in the menu.
+func romLabel(path string) string {
+	if strings.HasPrefix(path, tutorialPrefix) {
+		names, _ := tutorials()
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(path, tutorialPrefix), "%d", &n)
+		if n >= 1 && n <= len(names) {
+			return "tutorial: " + tutorialTitle(names[n-1])
+		}
+		return path
+	}
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
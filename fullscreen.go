@@ -0,0 +1,23 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"log"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// fullscreenMonitor returns the monitor to cover with -fullscreen -monitor n: monitors
+// count from 1, and 0 is the primary one. It has to be called after glfw.Init.
+func fullscreenMonitor(n int) *glfw.Monitor {
+	if n == 0 {
+		return glfw.GetPrimaryMonitor()
+	}
+	monitors := glfw.GetMonitors()
+	if n < 1 || n > len(monitors) {
+		log.Fatalf("there's no monitor %d: there are only %d", n, len(monitors))
+	}
+	return monitors[n-1]
+}
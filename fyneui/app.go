@@ -0,0 +1,352 @@
+//go:build fyne
+// +build fyne
+
+package fyneui
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	fynestorage "fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/softrender"
+	"github.com/mpingram/chip8/storage"
+)
+
+// screenScale is how many of the window's pixels each of the Chip8's takes, to
+// begin with. The screen grows and shrinks with the window after that.
+const screenScale = 10
+
+// ui is the desktop app: one window with the screen in it, and the Chip8 playing
+// on it, if a ROM's been opened.
+type ui struct {
+	app      fyne.App
+	window   fyne.Window
+	store    storage.Storage
+	settings Settings
+	screen   *canvas.Image
+	renderer *softrender.Renderer
+	// registers is the Debug > Registers window, while it's open.
+	registers      fyne.Window
+	registersLabel *widget.Label
+
+	mu sync.Mutex
+	// game is the Chip8 that's playing, or nil if nothing is.
+	game *game
+}
+
+// game is a Chip8 playing a ROM.
+type game struct {
+	c8      *cpu.Chip8
+	keypad  *control.Keypad
+	romPath string
+	// done is closed when the game is over, because another one's started.
+	done chan struct{}
+}
+
+// Run opens the desktop app, with its settings kept in store, and plays romPath if
+// it isn't "". It returns when the window is closed.
+func Run(store storage.Storage, romPath string) error {
+	settings, err := LoadSettings(store)
+	if err != nil {
+		// they'll have to set them again, but that's no reason not to play.
+		log.Printf("%v; using the default settings", err)
+	}
+	u := &ui{
+		app:      app.NewWithID("io.github.mpingram.chip8"),
+		store:    store,
+		settings: settings,
+		renderer: softrender.NewRenderer(softrender.DefaultPalette, 1),
+	}
+	u.window = u.app.NewWindow("Chip8")
+	u.screen = canvas.NewImageFromImage(image.NewRGBA(image.Rect(0, 0, cpu.FrameWidth, cpu.FrameHeight)))
+	u.screen.FillMode = canvas.ImageFillContain
+	u.screen.ScaleMode = canvas.ImageScalePixels
+	u.screen.SetMinSize(fyne.NewSize(cpu.FrameWidth*screenScale/2, cpu.FrameHeight*screenScale/2))
+	u.window.SetContent(u.screen)
+	u.window.Resize(fyne.NewSize(cpu.FrameWidth*screenScale, cpu.FrameHeight*screenScale))
+	u.window.SetMainMenu(u.menu())
+	u.bindKeys()
+	u.window.SetOnClosed(func() { u.stop() })
+
+	if romPath != "" {
+		if err := u.play(romPath); err != nil {
+			dialog.ShowError(err, u.window)
+		}
+	}
+	u.window.ShowAndRun()
+	return nil
+}
+
+// menu makes the window's menus. It's made again whenever the recent ROMs change.
+func (u *ui) menu() *fyne.MainMenu {
+	recent := fyne.NewMenuItem("Recent", nil)
+	var recentItems []*fyne.MenuItem
+	for _, path := range u.settings.Recent {
+		path := path
+		recentItems = append(recentItems, fyne.NewMenuItem(filepath.Base(path), func() { u.open(path) }))
+	}
+	if len(recentItems) == 0 {
+		none := fyne.NewMenuItem("(nothing yet)", nil)
+		none.Disabled = true
+		recentItems = append(recentItems, none)
+	}
+	recent.ChildMenu = fyne.NewMenu("", recentItems...)
+
+	file := fyne.NewMenu("File",
+		fyne.NewMenuItem("Open ROM...", u.showOpen),
+		recent,
+	)
+	emulation := fyne.NewMenu("Emulation",
+		fyne.NewMenuItem("Pause / Resume", u.togglePause),
+		fyne.NewMenuItem("Reset", u.reset),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Settings...", u.showSettings),
+	)
+	debug := fyne.NewMenu("Debug",
+		fyne.NewMenuItem("Registers", u.showRegisters),
+	)
+	return fyne.NewMainMenu(file, emulation, debug)
+}
+
+// showOpen asks which ROM to open, and opens it.
+func (u *ui) showOpen() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if reader == nil {
+			// they thought better of it.
+			return
+		}
+		defer reader.Close()
+		u.open(reader.URI().Path())
+	}, u.window)
+	open.SetFilter(fynestorage.NewExtensionFileFilter([]string{".ch8", ".c8", ".sc8", ".xo8"}))
+	open.Show()
+}
+
+// open plays the ROM in path, and adds it to the recent ROMs.
+func (u *ui) open(path string) {
+	if err := u.play(path); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+	u.settings.AddRecent(path)
+	u.saveSettings()
+	u.window.SetMainMenu(u.menu())
+}
+
+// play stops whatever's playing and starts playing the ROM in romPath, with the
+// settings as they are now.
+func (u *ui) play(romPath string) error {
+	rom, err := ioutil.ReadFile(romPath)
+	if err != nil {
+		return err
+	}
+	options, err := u.settings.Options()
+	if err != nil {
+		return err
+	}
+	keypad := control.NewKeypad(nil)
+	c8 := cpu.NewChip8(keypad, quietSpeaker{}, options...)
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(u.settings.Speed)
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+
+	u.stop()
+	g := &game{c8: c8, keypad: keypad, romPath: romPath, done: make(chan struct{})}
+	u.mu.Lock()
+	u.game = g
+	u.mu.Unlock()
+	u.window.SetTitle("Chip8 - " + filepath.Base(romPath))
+	go c8.Resume()
+	go u.draw(g)
+	return nil
+}
+
+// stop stops the game that's playing, if there is one.
+func (u *ui) stop() {
+	u.mu.Lock()
+	g := u.game
+	u.game = nil
+	u.mu.Unlock()
+	if g != nil {
+		close(g.done)
+		g.c8.Halt()
+	}
+}
+
+// playing returns the game that's playing, or nil.
+func (u *ui) playing() *game {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.game
+}
+
+// draw draws g's frames until it's over.
+func (u *ui) draw(g *game) {
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-g.c8.FrameReady():
+		}
+		frame := g.c8.ColorFrame()
+		state := g.c8.Snapshot()
+		// Fyne's objects belong to its main goroutine, and so does the renderer's
+		// image, which the screen draws from.
+		fyne.Do(func() {
+			u.screen.Image = u.renderer.Render(frame)
+			u.screen.Refresh()
+			if u.registers != nil {
+				u.registersLabel.SetText(registerText(state))
+			}
+		})
+	}
+}
+
+func (u *ui) togglePause() {
+	g := u.playing()
+	if g == nil {
+		return
+	}
+	if g.c8.IsRunning() {
+		g.c8.Halt()
+	} else {
+		go g.c8.Resume()
+	}
+}
+
+// reset starts the ROM that's playing over, which also puts any settings that have
+// changed since it started into effect.
+func (u *ui) reset() {
+	if g := u.playing(); g != nil {
+		if err := u.play(g.romPath); err != nil {
+			dialog.ShowError(err, u.window)
+		}
+	}
+}
+
+// bindKeys presses and releases keys on the Chip8's keypad as they're pressed and
+// released on the keyboard, going by the keymap.
+func (u *ui) bindKeys() {
+	keys, ok := u.window.Canvas().(desktop.Canvas)
+	if !ok {
+		// not a desktop, so no keyboard to speak of.
+		return
+	}
+	keys.SetOnKeyDown(func(event *fyne.KeyEvent) {
+		if key, ok := u.settings.Keymap.Key(string(event.Name)); ok {
+			if g := u.playing(); g != nil {
+				g.keypad.Press(key)
+			}
+		}
+	})
+	keys.SetOnKeyUp(func(event *fyne.KeyEvent) {
+		if key, ok := u.settings.Keymap.Key(string(event.Name)); ok {
+			if g := u.playing(); g != nil {
+				g.keypad.Release(key)
+			}
+		}
+	})
+}
+
+// showSettings shows the settings dialog. The keymap takes effect as soon as it's
+// saved; everything else takes effect when the next ROM starts (or this one's reset),
+// since a Chip8's quirks and font are set when it's made.
+func (u *ui) showSettings() {
+	speed := widget.NewEntry()
+	speed.SetText(strconv.Itoa(u.settings.Speed))
+	speed.Validator = func(text string) error {
+		if n, err := strconv.Atoi(text); err != nil || n <= 0 {
+			return fmt.Errorf("the speed is a number of instructions a second")
+		}
+		return nil
+	}
+	font := widget.NewSelect(cpu.FontNames(), nil)
+	font.SetSelected(u.settings.Font)
+	quirks := widget.NewCheckGroup(cpu.QuirkNames(), nil)
+	quirks.SetSelected(u.settings.Quirks)
+
+	var keys [16]*widget.Entry
+	keyGrid := container.NewGridWithColumns(8)
+	for key := range keys {
+		keys[key] = widget.NewEntry()
+		keys[key].SetText(u.settings.Keymap[key])
+		keyGrid.Add(widget.NewLabel(fmt.Sprintf("%X", key)))
+		keyGrid.Add(keys[key])
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Speed", speed),
+		widget.NewFormItem("Font", font),
+		widget.NewFormItem("Quirks", quirks),
+		widget.NewFormItem("Keys", keyGrid),
+	}
+	dialog.ShowForm("Settings", "Save", "Cancel", items, func(save bool) {
+		if !save {
+			return
+		}
+		settings := u.settings
+		settings.Speed, _ = strconv.Atoi(speed.Text)
+		settings.Font = font.Selected
+		settings.Quirks = quirks.Selected
+		for key := range keys {
+			settings.Keymap[key] = strings.TrimSpace(keys[key].Text)
+		}
+		if err := settings.Keymap.Check(); err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		u.settings = settings
+		u.saveSettings()
+	}, u.window)
+}
+
+func (u *ui) saveSettings() {
+	if err := u.settings.Save(u.store); err != nil {
+		dialog.ShowError(fmt.Errorf("saving settings: %v", err), u.window)
+	}
+}
+
+// showRegisters opens the registers window, which follows along as the game plays.
+func (u *ui) showRegisters() {
+	if u.registers != nil {
+		u.registers.RequestFocus()
+		return
+	}
+	u.registersLabel = widget.NewLabel("")
+	u.registersLabel.TextStyle.Monospace = true
+	if g := u.playing(); g != nil {
+		u.registersLabel.SetText(registerText(g.c8.Snapshot()))
+	}
+	u.registers = u.app.NewWindow("Registers")
+	u.registers.SetContent(u.registersLabel)
+	u.registers.SetOnClosed(func() { u.registers = nil })
+	u.registers.Show()
+}
+
+// quietSpeaker is a speaker that doesn't make a sound. Fyne doesn't do sound.
+type quietSpeaker struct{}
+
+func (quietSpeaker) StartSound() {}
+func (quietSpeaker) StopSound()  {}
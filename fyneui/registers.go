@@ -0,0 +1,25 @@
+package fyneui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// registerText lays out the registers for the registers window: V0 to VF in two
+// rows of eight, then PC, I, the timers and the stack.
+func registerText(state cpu.Chip8State) string {
+	var b strings.Builder
+	for i, v := range state.V {
+		fmt.Fprintf(&b, "V%X %02x  ", i, v)
+		if i%8 == 7 {
+			b.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&b, "\nPC %03x  I %03x  DT %02x  ST %02x\n\nstack:", state.PC, state.I, state.DT, state.ST)
+	for i := 0; 2*i+1 < len(state.Stack); i++ {
+		fmt.Fprintf(&b, " %03x", int(state.Stack[2*i])<<8|int(state.Stack[2*i+1]))
+	}
+	return b.String()
+}
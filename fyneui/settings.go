@@ -0,0 +1,150 @@
+// Package fyneui is a desktop frontend for the emulator made with Fyne
+// (https://fyne.io), for people who'd rather open ROMs from a menu than from a
+// command line: it has native menus to open a ROM or one of the recent ones, pause
+// and reset, and look at the registers, and a settings dialog for the quirks, the
+// font, the speed and which keys are which -- settings it remembers from one run to
+// the next.
+//
+// Fyne is a big dependency for something the windowed emulator mostly does already,
+// so the frontend is only built with the fyne build tag:
+//
+//	go get fyne.io/fyne/v2@latest
+//	go build -tags fyne
+//	chip8 desktop
+//
+// Fyne needs cgo and a C compiler, same as GLFW. It needs version 2.6 or later.
+// The settings themselves (this file) are plain Go, and always built.
+package fyneui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+// settingsKey is where the settings are kept.
+const settingsKey = "desktop/settings.json"
+
+// maxRecent is how many ROMs the Recent menu remembers.
+const maxRecent = 10
+
+// A Keymap says which key on the keyboard is which key on the Chip8's keypad: the
+// name of the keyboard key for each of keys 0 to F, as Fyne names them ("1", "Q",
+// "Space" and so on).
+type Keymap [16]string
+
+// DefaultKeymap is the left-hand side of a QWERTY keyboard, the same as the
+// windowed emulator's:
+//
+//	1 2 3 4        A 0 B F
+//	Q W E R   ->   1 2 3 C
+//	A S D F        4 5 6 D
+//	Z X C V        7 8 9 E
+var DefaultKeymap = Keymap{
+	0x0: "2", 0x1: "Q", 0x2: "W", 0x3: "E",
+	0x4: "A", 0x5: "S", 0x6: "D", 0x7: "Z",
+	0x8: "X", 0x9: "C", 0xA: "1", 0xB: "3",
+	0xC: "R", 0xD: "F", 0xE: "V", 0xF: "4",
+}
+
+// Key returns the Chip8 key the keyboard key called name is mapped to.
+func (k Keymap) Key(name string) (cpu.KeyCode, bool) {
+	for key, mapped := range k {
+		if mapped != "" && strings.EqualFold(mapped, name) {
+			return cpu.KeyCode(key), true
+		}
+	}
+	return cpu.KeyNone, false
+}
+
+// Check returns an error if a Chip8 key has no keyboard key, or two share one.
+func (k Keymap) Check() error {
+	for key, name := range k {
+		if name == "" {
+			return fmt.Errorf("key %X isn't mapped to anything", key)
+		}
+		for other := key + 1; other < len(k); other++ {
+			if strings.EqualFold(k[other], name) {
+				return fmt.Errorf("keys %X and %X are both mapped to %s", key, other, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Settings are what the settings dialog sets, and the Recent menu's ROMs.
+type Settings struct {
+	// Speed is how many instructions the Chip8 runs a second.
+	Speed int `json:"speed"`
+	// Quirks are the names of the quirks that are on (see cpu.QuirkNames).
+	Quirks []string `json:"quirks,omitempty"`
+	// Font is the name of the font (see cpu.FontNames).
+	Font   string `json:"font"`
+	Keymap Keymap `json:"keymap"`
+	// Recent are the files of the ROMs opened most recently, most recent first.
+	Recent []string `json:"recent,omitempty"`
+}
+
+// DefaultSettings are the settings before anyone's changed them.
+func DefaultSettings() Settings {
+	return Settings{
+		Speed:  cpu.DefaultSpeed,
+		Font:   cpu.DefaultFont.Name,
+		Keymap: DefaultKeymap,
+	}
+}
+
+// LoadSettings reads the settings from store. If nothing's been saved yet, it
+// returns the defaults. Anything missing from what was saved (because it was saved
+// before there was such a setting) is the default too.
+func LoadSettings(store storage.Storage) (Settings, error) {
+	s := DefaultSettings()
+	data, err := store.Get(settingsKey)
+	if err == storage.ErrNotFound {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return DefaultSettings(), fmt.Errorf("reading settings: %v", err)
+	}
+	return s, nil
+}
+
+// Save writes the settings to store, for LoadSettings to read next time.
+func (s Settings) Save(store storage.Storage) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return store.Put(settingsKey, data)
+}
+
+// Options returns the options to make a Chip8 with these settings.
+func (s Settings) Options() ([]cpu.Option, error) {
+	quirks, err := cpu.ParseQuirks(strings.Join(s.Quirks, ","))
+	if err != nil {
+		return nil, err
+	}
+	font, err := cpu.FontByName(s.Font)
+	if err != nil {
+		return nil, err
+	}
+	return []cpu.Option{cpu.WithQuirks(quirks), cpu.WithFont(font)}, nil
+}
+
+// AddRecent puts path at the top of the recent ROMs, taking it out of wherever it
+// was before, and forgets the oldest if there are too many.
+func (s *Settings) AddRecent(path string) {
+	recent := []string{path}
+	for _, other := range s.Recent {
+		if other != path && len(recent) < maxRecent {
+			recent = append(recent, other)
+		}
+	}
+	s.Recent = recent
+}
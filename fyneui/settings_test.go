@@ -0,0 +1,68 @@
+package fyneui_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/fyneui"
+	"github.com/mpingram/chip8/storage"
+)
+
+// Settings
+// should be the defaults until they've been saved, and what was saved after
+// should keep the recent ROMs most recent first, without repeats, and not too many
+// should find keys in the keymap whatever their case, and catch keys mapped twice
+func TestSettings(t *testing.T) {
+	store := storage.NewMemory()
+	s, err := fyneui.LoadSettings(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Speed != cpu.DefaultSpeed || s.Keymap != fyneui.DefaultKeymap {
+		t.Errorf("got %+v before anything was saved, want the defaults", s)
+	}
+	if err := s.Keymap.Check(); err != nil {
+		t.Errorf("the default keymap: %v", err)
+	}
+
+	s.Quirks = []string{"shift-vy", "clip"}
+	s.Speed = 1000
+	for i := 0; i < 12; i++ {
+		s.AddRecent(fmt.Sprintf("rom%d.ch8", i))
+	}
+	s.AddRecent("rom5.ch8")
+	if err := s.Save(store); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := fyneui.LoadSettings(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Speed != 1000 || len(loaded.Quirks) != 2 {
+		t.Errorf("got %+v back, want what was saved", loaded)
+	}
+	want := []string{"rom5.ch8", "rom11.ch8", "rom10.ch8", "rom9.ch8", "rom8.ch8", "rom7.ch8", "rom6.ch8", "rom4.ch8", "rom3.ch8", "rom2.ch8"}
+	if fmt.Sprint(loaded.Recent) != fmt.Sprint(want) {
+		t.Errorf("the recent ROMs are %v, want %v", loaded.Recent, want)
+	}
+	if _, err := loaded.Options(); err != nil {
+		t.Errorf("making options: %v", err)
+	}
+	loaded.Quirks = []string{"teleport"}
+	if _, err := loaded.Options(); err == nil {
+		t.Errorf("a quirk that doesn't exist made options")
+	}
+
+	if key, ok := fyneui.DefaultKeymap.Key("q"); !ok || key != 0x1 {
+		t.Errorf("q is key %X (%v), want 1", key, ok)
+	}
+	if _, ok := fyneui.DefaultKeymap.Key("P"); ok {
+		t.Errorf("P is mapped to a key")
+	}
+	keymap := fyneui.DefaultKeymap
+	keymap[0xF] = "w"
+	if err := keymap.Check(); err == nil {
+		t.Errorf("a keymap with W on two keys checked out")
+	}
+}
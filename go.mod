@@ -1,5 +1,7 @@
 module github.com/mpingram/chip8
 
+go 1.22
+
 require (
 	github.com/go-gl/gl v0.0.0-20180407155706-68e253793080
 	github.com/go-gl/glfw v0.0.0-20180813204114-2484f3e51bc4
@@ -0,0 +1,34 @@
+//go:build grpc
+// +build grpc
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/rpc"
+	"github.com/mpingram/chip8/rpc/chip8pb"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	grpcAddr = flag.String("grpc", "", "serve the gRPC API (see package rpc) on this `address`, like localhost:9090")
+	serveGRPC = func(addr string, c8 *cpu.Chip8, keypad *control.Keypad) func(cpu.Frame) {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		emulator := rpc.NewServer(c8, keypad)
+		server := grpc.NewServer()
+		chip8pb.RegisterEmulatorServer(server, emulator)
+		go func() {
+			log.Fatal(server.Serve(listener))
+		}()
+		log.Printf("serving the gRPC API on %s", listener.Addr())
+		return emulator.PublishFrame
+	}
+}
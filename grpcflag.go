@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// grpcAddr is where -grpc serves the gRPC API (see package rpc). The flag's only
+// there in builds with the grpc build tag, which set it up in grpc.go; in any
+// other build it stays "", and serveGRPC stays nil.
+var grpcAddr = new(string)
+
+// serveGRPC starts serving the gRPC API for c8 on addr, with input going to keypad
+// (which can be nil, like the HTTP server's), and returns what to pass the frames
+// the Chip8 draws to, for StreamFrames.
+var serveGRPC func(addr string, c8 *cpu.Chip8, keypad *control.Keypad) func(cpu.Frame)
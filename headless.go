@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/remote"
+	"github.com/mpingram/chip8/storage"
+)
+
+// headlessShutdownTimeout is how long HTTP requests get to finish when a headless
+// emulator is stopped, before they're cut off.
+const headlessShutdownTimeout = 5 * time.Second
+
+// headlessConfig is everything runHeadless needs to know, straight from the command line.
+type headlessConfig struct {
+	romPath      string
+	rom          []byte
+	speed        int
+	turbo        bool
+	quirks       cpu.Quirks
+	minBeep      time.Duration
+	font         cpu.Font
+	memorySize   int
+	protection   cpu.WriteProtection
+	devices      deviceFlags
+	keypad2      bool
+	patches      patchList
+	store        storage.Storage
+	httpAddr     string
+	token        string
+	grpcAddr     string
+	crowdWindow  time.Duration
+	crowdAliases string
+	// displayAddr is where remote displays (chip8 view) connect.
+	displayAddr string
+	// broadcast lets spectators watch, broadcastDelay behind the game.
+	broadcast      bool
+	broadcastDelay time.Duration
+	// autosave picks the game up from the last autosave when it starts, and
+	// autosaves it again when it's stopped.
+	autosave bool
+}
+
+// runHeadless runs a ROM without a window, for a server somewhere with no screen
+// attached. The ways in are the HTTP control API, which serves a page for watching
+// the screen from a browser, and the remote display protocol, for chip8 view -- and
+// the gRPC API, in builds with it. It runs until ctx is done, which for
+// context.Background() is never: stop it with Ctrl-C.
+func runHeadless(ctx context.Context, config headlessConfig) {
+	// keys come from remote displays, if there are any, and over HTTP.
+	var displays *remote.Server
+	var local cpu.Keyboard = noKeyboard{}
+	var speaker cpu.Speaker = silentSpeaker{}
+	if config.displayAddr != "" {
+		displays = remote.NewServer()
+		local, speaker = displays, displays
+	}
+	keypad := control.NewKeypad(local)
+	c8 := cpu.NewChip8(keypad, speaker, cpu.WithQuirks(config.quirks), cpu.MinimumBeep(config.minBeep), cpu.WithFont(config.font), cpu.WithMemorySize(config.memorySize), cpu.ProtectMemory(config.protection))
+	watchProtectedWrites(c8, config.protection)
+	// nobody's going to read the log, and it would grow forever.
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(config.speed)
+	c8.SetTurbo(config.turbo)
+	serial := attachDevices(c8, config.devices)
+	// remote displays only have the one keypad, so the second is only on the HTTP API.
+	var secondKeypad *control.Keypad
+	if config.keypad2 {
+		secondKeypad = control.NewKeypad(nil)
+		c8.ConnectSecondKeyboard(secondKeypad)
+	}
+	c8.ConnectRPLFlags(newSaveSlots(config.store, config.romPath).rplFlags())
+	if err := c8.Load(config.rom); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.patches.apply(c8); err != nil {
+		log.Fatal(err)
+	}
+	if config.autosave {
+		saved, err := loadAutosave(config.store, config.rom)
+		switch {
+		case err == nil:
+			warnQuirks(c8, saved)
+			if err := c8.LoadState(bytes.NewReader(saved)); err != nil {
+				log.Printf("resuming autosave: %v", err)
+			} else {
+				log.Printf("resumed from the autosave")
+			}
+		case err != storage.ErrNotFound:
+			log.Printf("reading autosave: %v", err)
+		}
+	}
+
+	var server *control.Server
+	var httpServer *http.Server
+	if config.httpAddr != "" {
+		server = control.NewServer(c8, keypad, config.token)
+		if serial != nil {
+			server.HandleSerial(serial)
+		}
+		if secondKeypad != nil {
+			server.HandleSecondKeypad(secondKeypad)
+		}
+		if config.crowdWindow > 0 {
+			if err := startCrowd(server, keypad, config.crowdWindow, config.crowdAliases); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if config.broadcast {
+			server.Broadcast(config.broadcastDelay)
+			log.Printf("spectators can watch at http://%s/watch", config.httpAddr)
+		}
+		httpServer = &http.Server{Addr: config.httpAddr, Handler: server}
+		go func() {
+			if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		log.Printf("watch at http://%s/?token=%s", config.httpAddr, config.token)
+	}
+	var publishGRPC func(cpu.Frame)
+	if config.grpcAddr != "" {
+		publishGRPC = serveGRPC(config.grpcAddr, c8, keypad)
+	}
+	var listener net.Listener
+	if displays != nil {
+		var err error
+		listener, err = net.Listen("tcp", config.displayAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			err := displays.Serve(listener)
+			if ctx.Err() == nil {
+				log.Fatal(err)
+			}
+		}()
+		log.Printf("remote displays can connect with: chip8 view %s", listener.Addr())
+	}
+	// we're the Chip8's only display, so we pass every frame on to everyone watching.
+	go func() {
+		for range c8.FrameReady() {
+			frame := c8.Frame()
+			if server != nil {
+				server.PublishFrame(frame)
+			}
+			if displays != nil {
+				displays.PublishFrame(frame)
+			}
+			if publishGRPC != nil {
+				publishGRPC(frame)
+			}
+		}
+	}()
+	// if the program finishes or crashes, or is paused over HTTP, it's up to
+	// whoever is on the other end; keep serving.
+	go func() {
+		logStop(c8.Resume())
+	}()
+	<-ctx.Done()
+
+	// time to go: stop the game, save it, and let everyone connected down gently.
+	c8.Halt()
+	c8.Wait()
+	if config.autosave {
+		if err := autosave(config.store, c8, config.rom); err != nil {
+			log.Printf("autosaving: %v", err)
+		} else {
+			log.Printf("autosaved")
+		}
+	}
+	if httpServer != nil {
+		shutdown, cancel := context.WithTimeout(context.Background(), headlessShutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdown); err != nil {
+			log.Printf("shutting down the HTTP server: %v", err)
+		}
+	}
+	if listener != nil {
+		listener.Close()
+	}
+}
+
+// apiToken returns the token for the HTTP control API: the one given with -token,
+// or if there wasn't one, a random one, which it prints so you can copy it.
+// Anyone who can reach the API can do anything to the emulator, so there's always a token.
+func apiToken(given string) string {
+	if given != "" {
+		return given
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		log.Fatal(err)
+	}
+	token := hex.EncodeToString(random)
+	log.Printf("HTTP control API token: %s", token)
+	return token
+}
+
+// noKeyboard is a Keyboard nobody is typing on, for running without a window.
+type noKeyboard struct{}
+
+func (noKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+// silentSpeaker is a Speaker that doesn't make any noise. We don't have a real one yet.
+type silentSpeaker struct{}
+
+func (silentSpeaker) StartSound() {}
+func (silentSpeaker) StopSound()  {}
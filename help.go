@@ -0,0 +1,28 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// helpKey shows and hides the help: it's the ? key, with or without the shift.
+// (F1 would be the usual place for help, but that's savestate slot 1.)
+const helpKey = glfw.KeySlash
+
+// bindHelpKey binds the key that shows what all the other keys do: whichever keys
+// have been described by the time anyone asks.
+func bindHelpKey(input *GLFWKeyboardInput, osd *onScreenDisplay) {
+	input.Describe("?", "this help")
+	input.OnHotkey(helpKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		if len(osd.help) > 0 {
+			osd.showHelp()
+		} else {
+			osd.showHelp(append([]string{"keys", ""}, input.Help()...)...)
+		}
+	})
+}
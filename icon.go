@@ -0,0 +1,76 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"image"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// iconGlyphs are the C and the 8 from the Chip8's own font, which between them
+// spell out the emulator's name in the docks, taskbars and alt-tabs of the world.
+var iconGlyphs = [][]byte{
+	cpu.DefaultFont.Glyph(0xC),
+	cpu.DefaultFont.Glyph(0x8),
+}
+
+// iconSizes are the sizes of icon we draw; the window system picks whichever suits it best.
+var iconSizes = []int{16, 32, 48, 64, 128}
+
+var (
+	iconForeground = color{0xff, 0x00, 0x00}
+	iconBackground = color{0x20, 0x00, 0x00}
+)
+
+// setWindowIcon gives window the emulator's icon. (Some systems, like macOS, don't let
+// windows have their own icons, and GLFW quietly does nothing there.)
+func setWindowIcon(window *glfw.Window) {
+	icons := make([]image.Image, len(iconSizes))
+	for i, size := range iconSizes {
+		icons[i] = drawIcon(size)
+	}
+	window.SetIcon(icons)
+}
+
+// drawIcon draws the icon size pixels square: "C8" in big chunky pixels, in the
+// default palette's colors, with a pixel's worth of border all round.
+func drawIcon(size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	fill := func(x, y int, c color) {
+		i := img.PixOffset(x, y)
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c[0], c[1], c[2], 0xff
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			fill(x, y, iconBackground)
+		}
+	}
+	// the two glyphs are 4 pixels wide with a gap between them, which makes 9,
+	// and 5 tall; with the border, that's 11 pixels across.
+	scale := size / 11
+	if scale < 1 {
+		scale = 1
+	}
+	left := (size - 9*scale) / 2
+	top := (size - 5*scale) / 2
+	for g, glyph := range iconGlyphs {
+		for row, bits := range glyph {
+			for col := 0; col < 4; col++ {
+				if bits&(0x80>>uint(col)) == 0 {
+					continue
+				}
+				x := left + (g*5+col)*scale
+				y := top + row*scale
+				for py := y; py < y+scale; py++ {
+					for px := x; px < x+scale; px++ {
+						fill(px, py, iconForeground)
+					}
+				}
+			}
+		}
+	}
+	return img
+}
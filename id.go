@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/archive"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/metadata"
+	"github.com/mpingram/chip8/romid"
+	"github.com/mpingram/chip8/storage"
+)
+
+// databaseKey is where the CHIP-8 database is kept, once it's downloaded.
+const databaseKey = "romid/programs.json"
+
+// maxDatabase is as much of the database as we'll download; it's a megabyte or two.
+const maxDatabase = 32 << 20
+
+func init() {
+	commands["id"] = command{
+		usage: "say what a ROM is: its checksums, which Chip8 it needs, and which game it is",
+		run:   idCommand,
+	}
+}
+
+// idCommand says what each of the ROMs on the command line is (see the romid
+// package), and looks them up in the CHIP-8 database, and among the games
+// downloaded from the CHIP-8 Archive (see chip8 browse).
+func idCommand(args []string) error {
+	flags := flag.NewFlagSet("id", flag.ExitOnError)
+	update := flags.Bool("update", false, "download the latest CHIP-8 database first")
+	dataDir := flags.String("dir", "", "keep the database under `folder` (default: the usual place on this system)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 id [flags] rom.ch8...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	store := openStore(*dataDir)
+	if *update {
+		if err := downloadDatabase(store); err != nil {
+			return err
+		}
+	}
+	var db *romid.Database
+	if data, err := store.Get(databaseKey); err == nil {
+		if db, err = romid.ParseDatabase(bytes.NewReader(data)); err != nil {
+			return err
+		}
+	} else if err != storage.ErrNotFound {
+		return err
+	}
+	downloads := archiveDownloads(store)
+
+	for i, romPath := range flags.Args() {
+		rom, err := readROM(romPath)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		id := romid.Identify(rom)
+		fmt.Println(romPath)
+		fmt.Printf("  size:      %d bytes\n", id.Size)
+		fmt.Printf("  sha-1:     %x\n", id.SHA1)
+		fmt.Printf("  crc-32:    %08x\n", id.CRC32)
+		fmt.Printf("  platform:  %s\n", id.Platform)
+		for _, e := range id.Evidence {
+			fmt.Printf("             %04x at %#03x is %s\n", e.Opcode, e.Address, e.Platform)
+		}
+		if db != nil {
+			if game, version, ok := db.Lookup(id.SHA1); ok {
+				fmt.Printf("  game:      %s\n", gameName(game))
+				if len(version.Platforms) > 0 {
+					fmt.Printf("  runs on:   %s\n", strings.Join(version.Platforms, ", "))
+				}
+				if version.TickRate > 0 {
+					fmt.Printf("  speed:     %d\n", version.TickRate*cpu.FramesPerSecond)
+				}
+			}
+		}
+		if name, ok := downloads[id.SHA1]; ok {
+			fmt.Printf("  archive:   %s\n", name)
+		}
+	}
+	if db == nil {
+		fmt.Fprintf(os.Stderr, "\n(there's no CHIP-8 database to look the games up in yet: chip8 id -update downloads it)\n")
+	}
+	return nil
+}
+
+// gameName returns what to call a game in the database: its title, who wrote it,
+// and when.
+func gameName(game romid.Game) string {
+	name := game.Title
+	if len(game.Authors) > 0 {
+		name += ", by " + strings.Join(game.Authors, ", ")
+	}
+	if game.Release != "" {
+		name += " (" + game.Release + ")"
+	}
+	return name
+}
+
+// archiveDownloads returns the names of the games that have been downloaded from
+// the CHIP-8 Archive, by SHA-1.
+func archiveDownloads(store *storage.Dir) map[[sha1.Size]byte]string {
+	found := make(map[[sha1.Size]byte]string)
+	dir, err := store.Path(archive.ROMFolder)
+	if err != nil {
+		return found
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.ch8"))
+	for _, path := range paths {
+		rom, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		name := romLabel(path)
+		if m, err := metadata.Read(path); err == nil && m != nil && m.Name() != "" {
+			name = m.Name()
+		}
+		found[sha1.Sum(rom)] = name
+	}
+	return found
+}
+
+// downloadDatabase downloads the CHIP-8 database, and keeps it.
+func downloadDatabase(store *storage.Dir) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequest("GET", romid.DatabaseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading the CHIP-8 database: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDatabase+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxDatabase {
+		return fmt.Errorf("downloading the CHIP-8 database: it's too big")
+	}
+	// no sense keeping it if it's no good.
+	if _, err := romid.ParseDatabase(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return store.Put(databaseKey, data)
+}
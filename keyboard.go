@@ -1,121 +1,387 @@
+//go:build cgo
+// +build cgo
+
 package main
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
 )
 
+// GLFWKeyboardInput turns GLFW key events into Chip8 keypresses.
+//
+// GLFW only delivers key events on the main thread (inside glfw.PollEvents),
+// but the Chip8 polls its keyboard from its own goroutine. So the state of the
+// 16 keypad keys lives in a single uint32 bitmask -- bit n is set while key n
+// is held down -- which the key callback updates and Poll reads atomically.
+// No locks, and the CPU never waits on the UI thread.
 type GLFWKeyboardInput struct {
-	window *glfw.Window
+	window  *glfw.Window
+	keys    uint32
+	hotkeys map[glfw.Key]hotkeyHandler
+	// events gets every keypad key pressed and released (see cpu.KeyEventSource).
+	events chan cpu.KeyEvent
+	// help says what the hotkeys do, in the order they were described.
+	help []hotkeyHelp
+	// second is the second keypad, on the numpad, or nil if nobody's asked for it
+	// (see SecondKeypad).
+	second *numpadKeypad
+	// gameKeys are more keys for the keypad, gameKeys2 more keys for the second
+	// keypad, and controls says how to play, for the ROM that's being played (see SetGame).
+	gameKeys, gameKeys2 map[glfw.Key]cpu.KeyCode
+	controls            []string
+}
+
+// hotkeyHelp says what a hotkey (or a few hotkeys that go together) does, for the help overlay.
+type hotkeyHelp struct {
+	keys, what string
+}
+
+// A hotkeyHandler is called when its hotkey is pressed or released,
+// along with whichever modifier keys (Shift, Ctrl...) were held at the time.
+type hotkeyHandler func(pressed bool, mods glfw.ModifierKey)
+
+// keypadMapping maps keys on a QWERTY keyboard to the Chip8 hexadecimal keypad.
+var keypadMapping = map[glfw.Key]cpu.KeyCode{
+	glfw.Key1: 0xA, glfw.Key2: 0x0, glfw.Key3: 0xB, glfw.Key4: 0xF,
+	glfw.KeyQ: 0x1, glfw.KeyW: 0x2, glfw.KeyE: 0x3, glfw.KeyR: 0xC,
+	glfw.KeyA: 0x4, glfw.KeyS: 0x5, glfw.KeyD: 0x6, glfw.KeyF: 0xD,
+	glfw.KeyZ: 0x7, glfw.KeyX: 0x8, glfw.KeyC: 0x9, glfw.KeyV: 0xE,
+}
+
+// keypadRows are the keys of the keypad, as they're laid out on the keyboard.
+var keypadRows = [4][4]glfw.Key{
+	{glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4},
+	{glfw.KeyQ, glfw.KeyW, glfw.KeyE, glfw.KeyR},
+	{glfw.KeyA, glfw.KeyS, glfw.KeyD, glfw.KeyF},
+	{glfw.KeyZ, glfw.KeyX, glfw.KeyC, glfw.KeyV},
 }
 
+// secondKeypadMapping maps the numpad to the second keypad, for two-player games,
+// laid out the same way as the first.
+var secondKeypadMapping = map[glfw.Key]cpu.KeyCode{
+	glfw.KeyKP7: 0x1, glfw.KeyKP8: 0x2, glfw.KeyKP9: 0x3, glfw.KeyKPDivide: 0xC,
+	glfw.KeyKP4: 0x4, glfw.KeyKP5: 0x5, glfw.KeyKP6: 0x6, glfw.KeyKPMultiply: 0xD,
+	glfw.KeyKP1: 0x7, glfw.KeyKP2: 0x8, glfw.KeyKP3: 0x9, glfw.KeyKPSubtract: 0xE,
+	glfw.KeyKP0: 0xA, glfw.KeyKPDecimal: 0x0, glfw.KeyKPEnter: 0xB, glfw.KeyKPAdd: 0xF,
+}
+
+// secondKeypadRows are the keys of the second keypad, as they're laid out on the numpad.
+var secondKeypadRows = [4][4]glfw.Key{
+	{glfw.KeyKP7, glfw.KeyKP8, glfw.KeyKP9, glfw.KeyKPDivide},
+	{glfw.KeyKP4, glfw.KeyKP5, glfw.KeyKP6, glfw.KeyKPMultiply},
+	{glfw.KeyKP1, glfw.KeyKP2, glfw.KeyKP3, glfw.KeyKPSubtract},
+	{glfw.KeyKP0, glfw.KeyKPDecimal, glfw.KeyKPEnter, glfw.KeyKPAdd},
+}
+
+// NewGLFWKeyboardInput creates a keyboard input that listens to key events on window.
+// It must be called from the main thread.
 func NewGLFWKeyboardInput(window *glfw.Window) *GLFWKeyboardInput {
-	return &GLFWKeyboardInput{window}
-}
-
-func (input *GLFWKeyboardInput) Poll() KeyState {
-	if input.window == nil {
-		panic("Poll() called before AttachWindow")
-	}
-
-	glfw.PollEvents()
-
-	// copied from cpu; this is where it should go tho
-	// META CONTROL LOOP
-	// ===
-	// read and execute control key inputs
-	/*
-		switch c.input.Poll() {
-		case PAUSE_KEY:
-			c.Pause()
-		case UNPAUSE_KEY:
-			c.Start()
-		case POWEROFF_KEY:
-			c.shouldCloseFlag = true
-		case STEP_KEY
-			// only step forward a paused CPU to avoid a double-step
-			if c.IsPaused() {
-				c.Step()
-			}
-		case DUMPSTATE_KEY
-			c.logger.Print(c)
+	input := &GLFWKeyboardInput{
+		window:  window,
+		hotkeys: make(map[glfw.Key]hotkeyHandler),
+		// enough for a few seconds of frantic button mashing while the Chip8 isn't looking.
+		events: make(chan cpu.KeyEvent, 64),
+	}
+	window.SetKeyCallback(input.onKey)
+	return input
+}
+
+// onKey is the GLFW key callback. It runs on the main thread.
+func (input *GLFWKeyboardInput) onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	// the second keypad gets the numpad, even the keys that are usually hotkeys;
+	// player two needs them more.
+	if code, ok := secondKeypadMapping[key]; ok && input.second != nil {
+		switch action {
+		case glfw.Press:
+			atomicSetBit(&input.second.keys, code, true)
+		case glfw.Release:
+			atomicSetBit(&input.second.keys, code, false)
+		}
+		return
+	}
+	if handler, ok := input.hotkeys[key]; ok {
+		switch action {
+		case glfw.Press:
+			handler(true, mods)
+		case glfw.Release:
+			handler(false, mods)
 		}
-		// ===
-	*/
+		return
+	}
 
-	k := KeyState{}
-	// Power Off key
-	if input.window.GetKey(glfw.KeyEscape) == glfw.Press {
-		k[0x10] = true
-		input.window.SetShouldClose(true)
+	// Power Off key, unless something (like the menu) has taken it over
+	if key == glfw.KeyEscape && action == glfw.Press {
+		w.SetShouldClose(true)
+		return
 	}
-	// Pause key
-	if input.window.GetKey(glfw.KeyP) == glfw.Press {
-		k[0x11] = true
+
+	if code, ok := input.gameKeys2[key]; ok && input.second != nil {
+		switch action {
+		case glfw.Press:
+			atomicSetBit(&input.second.keys, code, true)
+		case glfw.Release:
+			atomicSetBit(&input.second.keys, code, false)
+		}
+		return
 	}
-	// Unpause key
-	if input.window.GetKey(glfw.KeyLeftBracket) == glfw.Press {
-		k[0x12] = true
+	code, ok := input.gameKeys[key]
+	if !ok {
+		code, ok = keypadMapping[key]
 	}
-	// Step key
-	if input.window.GetKey(glfw.KeyRightBracket) == glfw.Press {
-		k[0x13] = true
+	if !ok {
+		return
 	}
-	// Dump state key
-	if input.window.GetKey(glfw.KeyO) == glfw.Press {
-		k[0x14] = true
+	switch action {
+	case glfw.Press:
+		input.setKey(code, true)
+	case glfw.Release:
+		input.setKey(code, false)
 	}
+}
+
+// OnHotkey binds an emulator hotkey: handler is called with pressed=true when key
+// is pressed and pressed=false when it's released. Hotkeys take priority over the keypad.
+// Handlers run on the main thread, from inside glfw.PollEvents.
+func (input *GLFWKeyboardInput) OnHotkey(key glfw.Key, handler hotkeyHandler) {
+	input.hotkeys[key] = handler
+}
+
+// Describe says what keys do, for the help overlay. Describe each hotkey next to
+// where it's bound, so the help only lists the hotkeys that are really there; keys
+// is how to write them, like "tab" or "shift+f1-f10".
+func (input *GLFWKeyboardInput) Describe(keys, what string) {
+	input.help = append(input.help, hotkeyHelp{keys, what})
+}
 
-	if input.window.GetKey(glfw.KeyQ) == glfw.Press {
-		k[0x1] = true
+// SetGame sets up the keyboard for the ROM being played: keys are more keys to
+// play it with, on top of the keypad, keys2 the same for the second keypad (if
+// there is one), and controls are lines of help on how to. Keys that are hotkeys
+// already stay hotkeys. It has to be called on the main thread.
+func (input *GLFWKeyboardInput) SetGame(keys, keys2 map[glfw.Key]cpu.KeyCode, controls []string) {
+	// let go of any game keys that are down, or they'd be down forever.
+	for key, code := range input.gameKeys {
+		if input.window.GetKey(key) == glfw.Press {
+			input.setKey(code, false)
+		}
 	}
-	if input.window.GetKey(glfw.KeyW) == glfw.Press {
-		k[0x2] = true
+	for key, code := range input.gameKeys2 {
+		if input.window.GetKey(key) == glfw.Press && input.second != nil {
+			atomicSetBit(&input.second.keys, code, false)
+		}
 	}
-	if input.window.GetKey(glfw.KeyE) == glfw.Press {
-		k[0x3] = true
+	input.gameKeys, input.gameKeys2, input.controls = keys, keys2, controls
+}
+
+// Help returns lines of text that say what every hotkey does, and which keys are
+// which on the Chip8's keypad.
+func (input *GLFWKeyboardInput) Help() []string {
+	var lines []string
+	for _, h := range input.help {
+		lines = append(lines, h.keys+": "+h.what)
 	}
-	if input.window.GetKey(glfw.KeyR) == glfw.Press {
-		k[0xc] = true
+	if _, ok := input.hotkeys[glfw.KeyEscape]; !ok {
+		lines = append(lines, keyName(glfw.KeyEscape)+": quit")
 	}
-
-	if input.window.GetKey(glfw.KeyA) == glfw.Press {
-		k[0x4] = true
+	keys, codes := describeKeypad(keypadRows, keypadMapping, "")
+	lines = append(lines, "", "keypad: "+strings.Join(keys, " "), "is chip8: "+strings.Join(codes, " "))
+	if len(input.gameKeys) > 0 {
+		lines = append(lines, "and: "+describeGameKeys(input.gameKeys))
 	}
-	if input.window.GetKey(glfw.KeyS) == glfw.Press {
-		k[0x5] = true
+	if input.second != nil {
+		keys, codes := describeKeypad(secondKeypadRows, secondKeypadMapping, "")
+		lines = append(lines, "keypad 2: "+strings.Join(keys, " "), "is chip8: "+strings.Join(codes, " "))
+		if len(input.gameKeys2) > 0 {
+			lines = append(lines, "and: "+describeGameKeys(input.gameKeys2))
+		}
 	}
-	if input.window.GetKey(glfw.KeyD) == glfw.Press {
-		k[0x6] = true
+	if len(input.controls) > 0 {
+		lines = append(append(lines, ""), input.controls...)
 	}
-	if input.window.GetKey(glfw.KeyF) == glfw.Press {
-		k[0xD] = true
+	return lines
+}
+
+// describeGameKeys writes out a game's keys and the Chip8 keys they are, like "i=1 k=c".
+func describeGameKeys(keys map[glfw.Key]cpu.KeyCode) string {
+	var described []string
+	for key, code := range keys {
+		described = append(described, fmt.Sprintf("%s=%x", keyName(key), code))
 	}
+	sort.Strings(described)
+	return strings.Join(described, " ")
+}
+
+// describeKeypad writes out a keypad's keys and the Chip8 keys they are, a row at
+// a time, with sep between the keys in a row.
+func describeKeypad(rows [4][4]glfw.Key, mapping map[glfw.Key]cpu.KeyCode, sep string) (keys, codes []string) {
+	for _, row := range rows {
+		var k, c []string
+		for _, key := range row {
+			k = append(k, keyName(key))
+			c = append(c, fmt.Sprintf("%x", mapping[key]))
+		}
+		keys, codes = append(keys, strings.Join(k, sep)), append(codes, strings.Join(c, sep))
+	}
+	return keys, codes
+}
 
-	if input.window.GetKey(glfw.KeyZ) == glfw.Press {
-		k[0x7] = true
+// keyName is what to call a key in help text.
+func keyName(key glfw.Key) string {
+	switch key {
+	case glfw.KeyEscape:
+		return "esc"
+	case glfw.KeyTab:
+		return "tab"
+	case glfw.KeyEnter:
+		return "enter"
+	case glfw.KeySpace:
+		return "space"
+	case glfw.KeyBackspace:
+		return "backspace"
+	case glfw.KeyUp:
+		return "up"
+	case glfw.KeyDown:
+		return "down"
+	case glfw.KeyLeft:
+		return "left"
+	case glfw.KeyRight:
+		return "right"
+	case glfw.KeyPageUp:
+		return "pgup"
+	case glfw.KeyPageDown:
+		return "pgdn"
+	case glfw.KeyKPDecimal:
+		return "."
+	case glfw.KeyKPDivide:
+		return "/"
+	case glfw.KeyKPMultiply:
+		return "*"
+	case glfw.KeyKPSubtract:
+		return "-"
+	case glfw.KeyKPAdd:
+		return "+"
+	case glfw.KeyKPEnter:
+		return "ent"
 	}
-	if input.window.GetKey(glfw.KeyX) == glfw.Press {
-		k[0x8] = true
+	if key >= glfw.KeyKP0 && key <= glfw.KeyKP9 {
+		return fmt.Sprintf("%d", key-glfw.KeyKP0)
 	}
-	if input.window.GetKey(glfw.KeyC) == glfw.Press {
-		k[0x9] = true
+	if key >= glfw.KeyF1 && key <= glfw.KeyF12 {
+		return fmt.Sprintf("f%d", key-glfw.KeyF1+1)
 	}
-	if input.window.GetKey(glfw.KeyV) == glfw.Press {
-		k[0xE] = true
+	// the rest of the keys worth naming are numbered by their ASCII codes.
+	if key >= glfw.KeySpace && key <= glfw.KeyGraveAccent {
+		return strings.ToLower(string(rune(key)))
 	}
+	return "?"
+}
+
+// namedKeys are the keys keyByName knows by name, rather than by what they type.
+var namedKeys = []glfw.Key{glfw.KeyUp, glfw.KeyDown, glfw.KeyLeft, glfw.KeyRight, glfw.KeySpace, glfw.KeyEnter, glfw.KeyBackspace, glfw.KeyTab}
 
-	if input.window.GetKey(glfw.Key1) == glfw.Press {
-		k[0xA] = true
+// keyByName finds a key by what keyName calls it: a letter, a digit or a bit of
+// punctuation on the main part of the keyboard, or one of namedKeys.
+func keyByName(name string) (glfw.Key, bool) {
+	name = strings.ToLower(name)
+	for _, key := range namedKeys {
+		if keyName(key) == name {
+			return key, true
+		}
 	}
-	if input.window.GetKey(glfw.Key2) == glfw.Press {
-		k[0x0] = true
+	if len(name) == 1 {
+		key := glfw.Key(strings.ToUpper(name)[0])
+		if key > glfw.KeySpace && key <= glfw.KeyGraveAccent && keyName(key) == name {
+			return key, true
+		}
 	}
-	if input.window.GetKey(glfw.Key3) == glfw.Press {
-		k[0xB] = true
+	return 0, false
+}
+
+// setKey atomically sets or clears the bit for one keypad key, and sends the event.
+func (input *GLFWKeyboardInput) setKey(code cpu.KeyCode, pressed bool) {
+	atomicSetBit(&input.keys, code, pressed)
+	event := cpu.KeyEvent{Code: code, Pressed: pressed, Timestamp: time.Now()}
+	for {
+		select {
+		case input.events <- event:
+			return
+		default:
+		}
+		// nobody's reading; the oldest event makes way for this one.
+		select {
+		case <-input.events:
+		default:
+		}
 	}
-	if input.window.GetKey(glfw.Key4) == glfw.Press {
-		k[0xF] = true
+}
+
+// atomicSetBit sets or clears the bit for one keypad key in keys, atomically.
+func atomicSetBit(keys *uint32, code cpu.KeyCode, pressed bool) {
+	bit := uint32(1) << uint(code)
+	for {
+		old := atomic.LoadUint32(keys)
+		updated := old &^ bit
+		if pressed {
+			updated = old | bit
+		}
+		if atomic.CompareAndSwapUint32(keys, old, updated) {
+			return
+		}
 	}
+}
+
+// KeyEvents returns the keypad keys as they're pressed and released.
+func (input *GLFWKeyboardInput) KeyEvents() <-chan cpu.KeyEvent {
+	return input.events
+}
+
+// Poll returns the lowest-numbered keypad key that is currently held down,
+// or cpu.KeyNone if no key is held down. It's safe to call from any goroutine.
+func (input *GLFWKeyboardInput) Poll() cpu.KeyCode {
+	return lowestKey(atomic.LoadUint32(&input.keys))
+}
+
+// lowestKey returns the lowest-numbered key set in keys, or cpu.KeyNone.
+func lowestKey(keys uint32) cpu.KeyCode {
+	for code := uint(0); code < 16; code++ {
+		if keys&(1<<code) != 0 {
+			return cpu.KeyCode(code)
+		}
+	}
+	return cpu.KeyNone
+}
+
+// SecondKeypad turns the numpad into a second keypad, for two-player games, and
+// returns it to connect to the Chip8 (see cpu.Chip8.ConnectSecondKeyboard). The
+// numpad's hotkeys stop working, since the keypad needs every key. Call it on the
+// main thread, before the window's had any key events.
+func (input *GLFWKeyboardInput) SecondKeypad() cpu.Keyboard {
+	if input.second == nil {
+		input.second = &numpadKeypad{}
+	}
+	return input.second
+}
+
+// numpadKeypad is the second keypad. onKey keeps its bitmask up to date, the same
+// way it does the first's.
+type numpadKeypad struct {
+	keys uint32
+}
+
+// Poll returns the lowest-numbered key held down on the second keypad, or
+// cpu.KeyNone. It's safe to call from any goroutine.
+func (k *numpadKeypad) Poll() cpu.KeyCode {
+	return lowestKey(atomic.LoadUint32(&k.keys))
+}
 
-	return k
+// Held returns every keypad key that's held down, as a bitmask with bit n set
+// while key n is down. It's safe to call from any goroutine.
+func (input *GLFWKeyboardInput) Held() uint16 {
+	return uint16(atomic.LoadUint32(&input.keys))
 }
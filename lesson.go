@@ -0,0 +1,52 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/lesson"
+)
+
+// lessonKey goes on to the lesson's next step.
+const lessonKey = glfw.KeyEnter
+
+// readLesson reads the lesson file at path.
+func readLesson(path string) (*lesson.Lesson, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lesson.Parse(f)
+}
+
+// lessonROM returns the path of the lesson's ROM. A relative path is relative to
+// the lesson file, so lessons can be shipped in a folder with their ROMs.
+func lessonROM(lessonPath string, l *lesson.Lesson) string {
+	if l.ROM == "" || strings.HasPrefix(l.ROM, tutorialPrefix) || filepath.IsAbs(l.ROM) {
+		return l.ROM
+	}
+	return filepath.Join(filepath.Dir(lessonPath), l.ROM)
+}
+
+// startLesson starts playing a lesson on c8, which should have the lesson's ROM
+// loaded but not be running: the lesson runs it, a step at a time. The lesson
+// is written to the terminal, and Enter goes on to the next step.
+func startLesson(l *lesson.Lesson, c8 *cpu.Chip8, input *GLFWKeyboardInput) *lesson.Player {
+	player := lesson.NewPlayer(l, os.Stdout)
+	player.Attach(c8)
+	input.Describe(keyName(lessonKey), "next step of the lesson")
+	input.OnHotkey(lessonKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			player.Next()
+		}
+	})
+	player.Next()
+	return player
+}
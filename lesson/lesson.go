@@ -0,0 +1,248 @@
+// Package lesson plays lessons: guided walks through a Chip8 program, a step at a time.
+// Each step says something, runs the program up to a breakpoint, and checks that the
+// machine got where the lesson says it should have -- "V0 should be 28 now, because..."
+// -- before pausing until the student's ready to go on.
+//
+// Lessons are plain text files, so anybody can write one:
+//
+//	# lines starting with # are comments
+//	title: Drawing a sprite
+//	rom: tutorial:1
+//	speed: 20
+//
+//	step: Getting ready
+//	Before drawing anything, the program puts the x and y coordinates
+//	in V0 and V1, and points I at the sprite.
+//	until: pc==0x208
+//	expect: V0==28   x is 28 pixels from the left
+//	expect: V1==12   and y is 12 from the top
+//
+//	step: Drawing
+//	Now DRW draws it.
+//	until: pc==0x20a
+//
+// A step runs until its until: condition comes true, and then checks each of its
+// expect: conditions; a step without an until: doesn't run at all. Everything else
+// in a step is what it says. Conditions compare a register (V0-VF, I, PC, DT or ST)
+// or a byte of memory (mem[0x300]) with a number, using ==, !=, >= or <=.
+package lesson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Lesson is a lesson file, parsed.
+type Lesson struct {
+	Title string
+	// ROM is the ROM to play the lesson with, as it would be given on the command line.
+	ROM string
+	// Speed is how fast to run it, in instructions per second, or 0 to leave it up to the player.
+	Speed int
+	Steps []Step
+}
+
+// A Step is one step of a lesson.
+type Step struct {
+	Title string
+	Text  string
+	// Until is the breakpoint to run to, or nil not to run at all.
+	Until  *Condition
+	Expect []Expectation
+}
+
+// An Expectation is something that should be true once a step has run, and why.
+type Expectation struct {
+	Condition Condition
+	Why       string
+}
+
+// A Condition compares part of the Chip8 with a number, like V0==28.
+type Condition struct {
+	// Register is "V0" through "VF", "I", "PC", "DT", "ST" or "mem".
+	Register string
+	// Address is the address of the byte of memory, for "mem".
+	Address uint16
+	Op      string
+	Value   uint16
+}
+
+// Parse reads a lesson file.
+func Parse(r io.Reader) (*Lesson, error) {
+	lesson := new(Lesson)
+	var step *Step
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key, value := splitKey(line)
+		fail := func(format string, args ...interface{}) (*Lesson, error) {
+			return nil, fmt.Errorf("lesson: line %d: %s", n, fmt.Sprintf(format, args...))
+		}
+
+		switch key {
+		case "title", "rom", "speed":
+			if step != nil {
+				return fail("%s: goes at the top, before the first step", key)
+			}
+			switch key {
+			case "title":
+				lesson.Title = value
+			case "rom":
+				lesson.ROM = value
+			case "speed":
+				speed, err := strconv.Atoi(value)
+				if err != nil || speed < 1 {
+					return fail("speed %q isn't a positive number", value)
+				}
+				lesson.Speed = speed
+			}
+
+		case "step":
+			lesson.Steps = append(lesson.Steps, Step{Title: value})
+			step = &lesson.Steps[len(lesson.Steps)-1]
+
+		case "until", "expect":
+			if step == nil {
+				return fail("%s: has to be part of a step", key)
+			}
+			fields := strings.Fields(value)
+			if len(fields) == 0 {
+				return fail("%s: needs a condition", key)
+			}
+			cond, err := ParseCondition(fields[0])
+			if err != nil {
+				return fail("%v", err)
+			}
+			if key == "until" {
+				if step.Until != nil {
+					return fail("a step can only run until one thing")
+				}
+				step.Until = &cond
+			} else {
+				why := strings.TrimSpace(strings.TrimPrefix(value, fields[0]))
+				step.Expect = append(step.Expect, Expectation{cond, why})
+			}
+
+		default:
+			if step == nil {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				return fail("everything but the title, rom and speed has to be part of a step")
+			}
+			step.Text += line + "\n"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lesson.Steps) == 0 {
+		return nil, fmt.Errorf("lesson: there aren't any steps")
+	}
+	for i := range lesson.Steps {
+		lesson.Steps[i].Text = strings.TrimSpace(lesson.Steps[i].Text)
+	}
+	return lesson, nil
+}
+
+// splitKey splits a line like "until: pc==0x208" into its key and value, or
+// returns no key if it isn't one of those.
+func splitKey(line string) (key, value string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", line
+	}
+	switch key := strings.ToLower(strings.TrimSpace(line[:colon])); key {
+	case "title", "rom", "speed", "step", "until", "expect":
+		return key, strings.TrimSpace(line[colon+1:])
+	}
+	return "", line
+}
+
+// ParseCondition parses a condition, like V0==28, I>=0x300 or mem[0x3f0]!=0.
+func ParseCondition(s string) (Condition, error) {
+	var cond Condition
+	for _, op := range []string{"==", "!=", ">=", "<="} {
+		if i := strings.Index(s, op); i >= 0 {
+			cond.Register, cond.Op = strings.TrimSpace(s[:i]), op
+			value, err := strconv.ParseUint(strings.TrimSpace(s[i+2:]), 0, 16)
+			if err != nil {
+				return Condition{}, fmt.Errorf("%q isn't a number", s[i+2:])
+			}
+			cond.Value = uint16(value)
+			break
+		}
+	}
+	if cond.Op == "" {
+		return Condition{}, fmt.Errorf("condition %q needs ==, !=, >= or <=", s)
+	}
+
+	name := strings.ToUpper(cond.Register)
+	switch {
+	case strings.HasPrefix(strings.ToLower(name), "mem[") && strings.HasSuffix(name, "]"):
+		addr, err := strconv.ParseUint(strings.ToLower(name[4:len(name)-1]), 0, 16)
+		if err != nil || addr > 0xfff {
+			return Condition{}, fmt.Errorf("%q isn't an address in memory", name[4:len(name)-1])
+		}
+		cond.Register, cond.Address = "mem", uint16(addr)
+	case name == "I" || name == "PC" || name == "DT" || name == "ST":
+		cond.Register = name
+	case len(name) == 2 && name[0] == 'V' && strings.ContainsRune("0123456789ABCDEF", rune(name[1])):
+		cond.Register = name
+	default:
+		return Condition{}, fmt.Errorf("%q isn't a register or mem[address]", cond.Register)
+	}
+	return cond, nil
+}
+
+// value returns the current value of whatever the condition looks at.
+func (cond Condition) value(state cpu.Chip8State) uint16 {
+	switch cond.Register {
+	case "mem":
+		return uint16(state.Memory[cond.Address])
+	case "I":
+		return state.I
+	case "PC":
+		return state.PC
+	case "DT":
+		return uint16(state.DT)
+	case "ST":
+		return uint16(state.ST)
+	}
+	x, _ := strconv.ParseUint(cond.Register[1:], 16, 4)
+	return uint16(state.V[x])
+}
+
+// Met reports whether the condition is true of state.
+func (cond Condition) Met(state cpu.Chip8State) bool {
+	v := cond.value(state)
+	switch cond.Op {
+	case "==":
+		return v == cond.Value
+	case "!=":
+		return v != cond.Value
+	case ">=":
+		return v >= cond.Value
+	default:
+		return v <= cond.Value
+	}
+}
+
+func (cond Condition) String() string {
+	name := cond.Register
+	if name == "mem" {
+		name = fmt.Sprintf("mem[%#03x]", cond.Address)
+	}
+	if cond.Register == "PC" || cond.Register == "I" {
+		return fmt.Sprintf("%s%s%#03x", name, cond.Op, cond.Value)
+	}
+	return fmt.Sprintf("%s%s%d", name, cond.Op, cond.Value)
+}
@@ -0,0 +1,110 @@
+package lesson_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/lesson"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// Parse
+// should read the lesson that comes with the emulator
+// should say which line a mistake is on
+func TestParse(t *testing.T) {
+	f, err := os.Open("../lessons/drawing-a-sprite.lesson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := lesson.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Title != "Drawing a sprite" || l.ROM != "tutorial:1" || l.Speed != 5 || len(l.Steps) != 4 {
+		t.Fatalf("got %q, %q, speed %d, %d steps", l.Title, l.ROM, l.Speed, len(l.Steps))
+	}
+	first := l.Steps[0]
+	if first.Until == nil || first.Until.String() != "PC==0x208" || len(first.Expect) != 3 || first.Expect[0].Why != "x is 28 pixels from the left" {
+		t.Errorf("first step came out wrong: %+v", first)
+	}
+	if l.Steps[3].Until != nil {
+		t.Errorf("the last step shouldn't run anywhere")
+	}
+
+	_, err = lesson.Parse(strings.NewReader("title: oops\nstep: one\nuntil: V0=1\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("got error %v, want one about line 3", err)
+	}
+}
+
+// Player
+// should run each step to its breakpoint, stop there, and check what it expected
+func TestPlayer(t *testing.T) {
+	rom, err := asm.Assemble("LD V0,1\nLD V0,2\nLD V0,3\nend: JP end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := lesson.Parse(strings.NewReader(`
+step: one
+until: PC==0x204
+expect: V0==2   right
+expect: V0==3   wrong
+step: two
+until: V0==3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(1000)
+	if err := c8.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	p := lesson.NewPlayer(l, &out)
+	p.Attach(c8)
+
+	waitFor := func(status string) {
+		deadline := time.Now().Add(2 * time.Second)
+		for p.Status() != status {
+			if time.Now().After(deadline) {
+				t.Fatalf("status is %q, want %q", p.Status(), status)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	p.Next()
+	waitFor("lesson step 1/2: press enter")
+	c8.Wait()
+	if pc := c8.Snapshot().PC; pc != 0x204 {
+		t.Errorf("stopped at %03x, want 204", pc)
+	}
+	if !strings.Contains(out.String(), "✓ V0==2 -- right") || !strings.Contains(out.String(), "✗ V0==3 -- wrong (but V0==2)") {
+		t.Errorf("didn't check the expectations right:\n%s", out.String())
+	}
+
+	p.Next()
+	waitFor("lesson step 2/2: press enter")
+	c8.Wait()
+	if p.Next() {
+		t.Errorf("Next should say the lesson's over")
+	}
+	if !strings.Contains(out.String(), "1 of 2 things were as expected") {
+		t.Errorf("didn't sum up:\n%s", out.String())
+	}
+}
@@ -0,0 +1,142 @@
+package lesson
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Player plays a lesson on a Chip8, writing what each step says (and whether
+// the Chip8 did what was expected) to a terminal, or wherever.
+type Player struct {
+	lesson *Lesson
+	out    io.Writer
+
+	mu sync.Mutex
+	c8 *cpu.Chip8
+	// step is the step we're on, or -1 before the lesson starts.
+	step int
+	// running is true while the Chip8 runs to the step's breakpoint.
+	running bool
+	// passed and failed count the expectations that have been checked.
+	passed, failed int
+}
+
+// NewPlayer returns a Player for a lesson, that writes the lesson out to out.
+func NewPlayer(lesson *Lesson, out io.Writer) *Player {
+	return &Player{lesson: lesson, out: out, step: -1}
+}
+
+// Attach gets ready to play the lesson on c8, replacing its OnInstruction hook.
+// Load the ROM, then call Next to start the first step; don't Resume c8 yourself.
+func (p *Player) Attach(c8 *cpu.Chip8) {
+	p.mu.Lock()
+	p.c8 = c8
+	p.mu.Unlock()
+	c8.OnInstruction(func(pc, opcode uint16) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.running {
+			return
+		}
+		state := c8.Snapshot()
+		if p.lesson.Steps[p.step].Until.Met(state) {
+			// this is the breakpoint: stop right here, before the next instruction.
+			c8.Break()
+			p.running = false
+			p.check(state)
+		}
+	})
+}
+
+// Next goes on to the next step, if the last one's finished: it says what the step
+// says, and runs the Chip8 to the step's breakpoint. It returns false once there
+// are no steps left.
+func (p *Player) Next() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return true
+	}
+	if p.step+1 >= len(p.lesson.Steps) {
+		if p.step < len(p.lesson.Steps) {
+			p.step++
+			p.finish()
+		}
+		return false
+	}
+	p.step++
+	step := p.lesson.Steps[p.step]
+	if p.step == 0 && p.lesson.Title != "" {
+		fmt.Fprintf(p.out, "\n%s\n%s\n", p.lesson.Title, strings.Repeat("=", len(p.lesson.Title)))
+	}
+	fmt.Fprintf(p.out, "\nStep %d of %d", p.step+1, len(p.lesson.Steps))
+	if step.Title != "" {
+		fmt.Fprintf(p.out, ": %s", step.Title)
+	}
+	fmt.Fprintf(p.out, "\n\n%s\n", step.Text)
+	if step.Until == nil {
+		p.check(p.c8.Snapshot())
+		return true
+	}
+	fmt.Fprintf(p.out, "\n(running until %s...)\n", step.Until)
+	p.running = true
+	go p.c8.Resume()
+	return true
+}
+
+// check checks the step's expectations against state, and says how they went.
+// The Player must be locked.
+func (p *Player) check(state cpu.Chip8State) {
+	step := p.lesson.Steps[p.step]
+	for _, expect := range step.Expect {
+		got := Condition{Register: expect.Condition.Register, Address: expect.Condition.Address, Op: "==", Value: expect.Condition.value(state)}
+		mark := "✓"
+		if expect.Condition.Met(state) {
+			p.passed++
+		} else {
+			mark = "✗"
+			p.failed++
+		}
+		fmt.Fprintf(p.out, "  %s %s", mark, expect.Condition)
+		if expect.Why != "" {
+			fmt.Fprintf(p.out, " -- %s", expect.Why)
+		}
+		if !expect.Condition.Met(state) {
+			fmt.Fprintf(p.out, " (but %s)", got)
+		}
+		fmt.Fprintln(p.out)
+	}
+	if p.step+1 < len(p.lesson.Steps) {
+		fmt.Fprintf(p.out, "\nPress Enter for the next step.\n")
+	} else {
+		fmt.Fprintf(p.out, "\nPress Enter to finish.\n")
+	}
+}
+
+// finish says how the lesson went.
+func (p *Player) finish() {
+	fmt.Fprintf(p.out, "\nThat's the end of the lesson.")
+	if total := p.passed + p.failed; total > 0 {
+		fmt.Fprintf(p.out, " %d of %d things were as expected.", p.passed, total)
+	}
+	fmt.Fprintln(p.out)
+}
+
+// Status says where the lesson's got to, in a few words, for showing on screen.
+func (p *Player) Status() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.step < 0:
+		return "lesson: press enter to start"
+	case p.step >= len(p.lesson.Steps):
+		return "lesson over"
+	case p.running:
+		return fmt.Sprintf("lesson step %d/%d: running", p.step+1, len(p.lesson.Steps))
+	}
+	return fmt.Sprintf("lesson step %d/%d: press enter", p.step+1, len(p.lesson.Steps))
+}
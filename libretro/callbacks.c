@@ -0,0 +1,38 @@
+#include "libretro.h"
+
+static retro_environment_t environ_cb;
+static retro_video_refresh_t video_cb;
+static retro_audio_sample_batch_t audio_batch_cb;
+static retro_input_poll_t input_poll_cb;
+static retro_input_state_t input_state_cb;
+
+uint32_t framebuffer[64 * 32];
+int16_t audiobuffer[2 * 735];
+
+void set_environment(retro_environment_t cb) { environ_cb = cb; }
+void set_video_refresh(retro_video_refresh_t cb) { video_cb = cb; }
+void set_audio_sample_batch(retro_audio_sample_batch_t cb) { audio_batch_cb = cb; }
+void set_input_poll(retro_input_poll_t cb) { input_poll_cb = cb; }
+void set_input_state(retro_input_state_t cb) { input_state_cb = cb; }
+
+bool call_environment(unsigned cmd, void *data) {
+	return environ_cb && environ_cb(cmd, data);
+}
+
+void call_video_refresh(const void *data, unsigned width, unsigned height, size_t pitch) {
+	if (video_cb)
+		video_cb(data, width, height, pitch);
+}
+
+size_t call_audio_sample_batch(const int16_t *data, size_t frames) {
+	return audio_batch_cb ? audio_batch_cb(data, frames) : 0;
+}
+
+void call_input_poll(void) {
+	if (input_poll_cb)
+		input_poll_cb();
+}
+
+int16_t call_input_state(unsigned port, unsigned device, unsigned index, unsigned id) {
+	return input_state_cb ? input_state_cb(port, device, index, id) : 0;
+}
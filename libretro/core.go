@@ -0,0 +1,296 @@
+// Command libretro is the Chip8 as a libretro core, so it can be played inside
+// RetroArch (or any other libretro frontend) with its shaders, savestates, rewind,
+// and controller handling. Build it as a shared library:
+//
+//	go build -buildmode=c-shared -o chip8_libretro.so ./libretro
+//
+// and point RetroArch at it with retroarch -L chip8_libretro.so game.ch8.
+//
+// The controls are the same QWERTY layout as the standalone emulator (1234/QWER/ASDF/ZXCV),
+// and on a gamepad the d-pad is 2/4/6/8 -- the keys most games use for directions --
+// with the face buttons as 5, A, B and 0.
+package main
+
+// #include "libretro.h"
+import "C"
+
+import (
+	"bytes"
+	"strconv"
+	"unsafe"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+const (
+	screenWidth  = 64
+	screenHeight = 32
+	sampleRate   = 44100
+	// samplesPerFrame is how many audio samples go with each frame at 60fps.
+	samplesPerFrame = sampleRate / cpu.FramesPerSecond
+	// beepHz is the pitch of the beep. The Chip8 doesn't say, so: A5, which is annoying enough.
+	beepHz = 880
+	// stateSize is how much room a savestate gets. Frontends want savestates to be
+	// the same size every time, but ours are compressed, so they get padded out to this.
+	// A compressed 4K of memory can't get much bigger than 4K.
+	stateSize = 8192
+	// defaultSpeed is how many instructions per second the core runs unless
+	// it's told otherwise in the core options -- about what most games expect.
+	defaultSpeed = 600
+)
+
+// libretro keeps C strings around forever, so they're allocated once and never freed.
+var (
+	libraryName     = C.CString("Chip8")
+	libraryVersion  = C.CString("1.0")
+	validExtensions = C.CString("ch8|c8|rom")
+	speedKey        = C.CString("chip8_speed")
+	speedOption     = C.CString("Speed (instructions per second); 600|300|500|700|1000|1500|2000|60")
+)
+
+// core is everything the core keeps between calls. libretro calls us from one thread,
+// one function at a time, so nothing here needs a lock.
+var core struct {
+	c8      *cpu.Chip8
+	rom     []byte
+	keys    retroKeyboard
+	beeping bool
+	phase   int
+}
+
+// retroKeyboard is the keypad, as read from the frontend once a frame.
+type retroKeyboard struct {
+	held uint16
+}
+
+func (k *retroKeyboard) Poll() cpu.KeyCode {
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if k.held&(1<<key) != 0 {
+			return key
+		}
+	}
+	return cpu.KeyNone
+}
+
+// beeper is the Speaker; the beep itself is made in retro_run.
+type beeper struct{}
+
+func (beeper) StartSound() { core.beeping = true }
+func (beeper) StopSound()  { core.beeping = false }
+
+// joypadMapping maps RETRO_DEVICE_ID_JOYPAD_* buttons to keypad keys.
+var joypadMapping = map[C.uint]cpu.KeyCode{
+	4: 0x2, // up
+	5: 0x8, // down
+	6: 0x4, // left
+	7: 0x6, // right
+	8: 0x5, // A
+	0: 0xA, // B
+	9: 0xB, // X
+	1: 0x0, // Y
+}
+
+// keyboardMapping maps RETROK_* keys (which are ASCII, for letters and digits)
+// to the keypad, in the same layout as the standalone emulator.
+var keyboardMapping = map[C.uint]cpu.KeyCode{
+	'1': 0xA, '2': 0x0, '3': 0xB, '4': 0xF,
+	'q': 0x1, 'w': 0x2, 'e': 0x3, 'r': 0xC,
+	'a': 0x4, 's': 0x5, 'd': 0x6, 'f': 0xD,
+	'z': 0x7, 'x': 0x8, 'c': 0x9, 'v': 0xE,
+}
+
+func main() {}
+
+//export retro_api_version
+func retro_api_version() C.uint { return C.RETRO_API_VERSION }
+
+//export retro_set_environment
+func retro_set_environment(cb C.retro_environment_t) {
+	C.set_environment(cb)
+	vars := []C.struct_retro_variable{{key: speedKey, value: speedOption}, {}}
+	C.call_environment(C.RETRO_ENVIRONMENT_SET_VARIABLES, unsafe.Pointer(&vars[0]))
+}
+
+//export retro_set_video_refresh
+func retro_set_video_refresh(cb C.retro_video_refresh_t) { C.set_video_refresh(cb) }
+
+//export retro_set_audio_sample
+func retro_set_audio_sample(cb unsafe.Pointer) {}
+
+//export retro_set_audio_sample_batch
+func retro_set_audio_sample_batch(cb C.retro_audio_sample_batch_t) { C.set_audio_sample_batch(cb) }
+
+//export retro_set_input_poll
+func retro_set_input_poll(cb C.retro_input_poll_t) { C.set_input_poll(cb) }
+
+//export retro_set_input_state
+func retro_set_input_state(cb C.retro_input_state_t) { C.set_input_state(cb) }
+
+//export retro_init
+func retro_init() {}
+
+//export retro_deinit
+func retro_deinit() {}
+
+//export retro_get_system_info
+func retro_get_system_info(info *C.struct_retro_system_info) {
+	info.library_name = libraryName
+	info.library_version = libraryVersion
+	info.valid_extensions = validExtensions
+	info.need_fullpath = false
+	info.block_extract = false
+}
+
+//export retro_get_system_av_info
+func retro_get_system_av_info(info *C.struct_retro_system_av_info) {
+	info.geometry.base_width = screenWidth
+	info.geometry.base_height = screenHeight
+	info.geometry.max_width = screenWidth
+	info.geometry.max_height = screenHeight
+	info.geometry.aspect_ratio = screenWidth / screenHeight
+	info.timing.fps = cpu.FramesPerSecond
+	info.timing.sample_rate = sampleRate
+}
+
+//export retro_set_controller_port_device
+func retro_set_controller_port_device(port, device C.uint) {}
+
+//export retro_load_game
+func retro_load_game(game *C.struct_retro_game_info) C.bool {
+	if game == nil || game.data == nil {
+		return false
+	}
+	format := C.uint(C.RETRO_PIXEL_FORMAT_XRGB8888)
+	if !bool(C.call_environment(C.RETRO_ENVIRONMENT_SET_PIXEL_FORMAT, unsafe.Pointer(&format))) {
+		return false
+	}
+	core.rom = C.GoBytes(game.data, C.int(game.size))
+	core.c8 = cpu.NewChip8(&core.keys, beeper{})
+	core.c8.SetLogLevel(cpu.LogNone)
+	applySpeed()
+	return C.bool(core.c8.Load(core.rom) == nil)
+}
+
+//export retro_load_game_special
+func retro_load_game_special(gameType C.uint, info *C.struct_retro_game_info, numInfo C.size_t) C.bool {
+	return false
+}
+
+//export retro_unload_game
+func retro_unload_game() {
+	core.c8 = nil
+	core.rom = nil
+}
+
+//export retro_get_region
+func retro_get_region() C.uint { return C.RETRO_REGION_NTSC }
+
+//export retro_reset
+func retro_reset() {
+	if core.c8 != nil {
+		core.c8.Load(core.rom)
+	}
+}
+
+//export retro_run
+func retro_run() {
+	var updated C.bool
+	if bool(C.call_environment(C.RETRO_ENVIRONMENT_GET_VARIABLE_UPDATE, unsafe.Pointer(&updated))) && bool(updated) {
+		applySpeed()
+	}
+
+	C.call_input_poll()
+	core.keys.held = 0
+	for id, key := range joypadMapping {
+		if C.call_input_state(0, C.RETRO_DEVICE_JOYPAD, 0, id) != 0 {
+			core.keys.held |= 1 << key
+		}
+	}
+	for id, key := range keyboardMapping {
+		if C.call_input_state(0, C.RETRO_DEVICE_KEYBOARD, 0, id) != 0 {
+			core.keys.held |= 1 << key
+		}
+	}
+
+	// run the Chip8 for exactly one frame.
+	for start := core.c8.FrameCount(); core.c8.FrameCount() == start; {
+		core.c8.FrameStep()
+	}
+
+	frame := cpu.Frame(core.c8.ReadVideoMemory())
+	pixels := (*[screenWidth * screenHeight]C.uint32_t)(unsafe.Pointer(&C.framebuffer[0]))
+	for i := range pixels {
+		pixels[i] = 0
+		if frame.Pixel(i%screenWidth, i/screenWidth) {
+			pixels[i] = 0xFFFFFF
+		}
+	}
+	C.call_video_refresh(unsafe.Pointer(&C.framebuffer[0]), screenWidth, screenHeight, screenWidth*4)
+
+	// a square wave while the sound timer runs, silence otherwise.
+	samples := (*[2 * samplesPerFrame]C.int16_t)(unsafe.Pointer(&C.audiobuffer[0]))
+	halfPeriod := sampleRate / beepHz / 2
+	for i := 0; i < samplesPerFrame; i++ {
+		var level C.int16_t
+		if core.beeping {
+			level = 4000
+			if (core.phase/halfPeriod)%2 == 1 {
+				level = -4000
+			}
+			core.phase++
+		}
+		samples[2*i], samples[2*i+1] = level, level
+	}
+	C.call_audio_sample_batch(&C.audiobuffer[0], samplesPerFrame)
+}
+
+// applySpeed reads the speed from the core options.
+func applySpeed() {
+	speed := defaultSpeed
+	variable := C.struct_retro_variable{key: speedKey}
+	if bool(C.call_environment(C.RETRO_ENVIRONMENT_GET_VARIABLE, unsafe.Pointer(&variable))) && variable.value != nil {
+		if n, err := strconv.Atoi(C.GoString(variable.value)); err == nil {
+			speed = n
+		}
+	}
+	core.c8.SetSpeed(speed)
+}
+
+//export retro_serialize_size
+func retro_serialize_size() C.size_t { return stateSize }
+
+//export retro_serialize
+func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if core.c8 == nil || size < stateSize {
+		return false
+	}
+	var state bytes.Buffer
+	if err := core.c8.SaveState(&state); err != nil || state.Len() > stateSize {
+		return false
+	}
+	// savestates know their own length, so the padding after one is ignored when it's loaded.
+	dst := (*[stateSize]byte)(data)
+	*dst = [stateSize]byte{}
+	copy(dst[:], state.Bytes())
+	return true
+}
+
+//export retro_unserialize
+func retro_unserialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if core.c8 == nil {
+		return false
+	}
+	return C.bool(core.c8.LoadState(bytes.NewReader(C.GoBytes(data, C.int(size)))) == nil)
+}
+
+//export retro_cheat_reset
+func retro_cheat_reset() {}
+
+//export retro_cheat_set
+func retro_cheat_set(index C.uint, enabled C.bool, code *C.char) {}
+
+//export retro_get_memory_data
+func retro_get_memory_data(id C.uint) unsafe.Pointer { return nil }
+
+//export retro_get_memory_size
+func retro_get_memory_size(id C.uint) C.size_t { return 0 }
@@ -0,0 +1,89 @@
+#ifndef CHIP8_LIBRETRO_H
+#define CHIP8_LIBRETRO_H
+
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+// Just the parts of the real libretro.h that we use. The values are part of the libretro ABI,
+// which is stable: they'll never change.
+
+#define RETRO_API_VERSION 1
+
+#define RETRO_DEVICE_JOYPAD   1
+#define RETRO_DEVICE_KEYBOARD 3
+
+#define RETRO_ENVIRONMENT_GET_VARIABLE         15
+#define RETRO_ENVIRONMENT_SET_VARIABLES        16
+#define RETRO_ENVIRONMENT_GET_VARIABLE_UPDATE  17
+#define RETRO_ENVIRONMENT_SET_PIXEL_FORMAT     10
+
+#define RETRO_PIXEL_FORMAT_XRGB8888 1
+
+#define RETRO_REGION_NTSC 0
+
+struct retro_system_info {
+	const char *library_name;
+	const char *library_version;
+	const char *valid_extensions;
+	bool need_fullpath;
+	bool block_extract;
+};
+
+struct retro_game_geometry {
+	unsigned base_width;
+	unsigned base_height;
+	unsigned max_width;
+	unsigned max_height;
+	float aspect_ratio;
+};
+
+struct retro_system_timing {
+	double fps;
+	double sample_rate;
+};
+
+struct retro_system_av_info {
+	struct retro_game_geometry geometry;
+	struct retro_system_timing timing;
+};
+
+struct retro_game_info {
+	const char *path;
+	const void *data;
+	size_t size;
+	const char *meta;
+};
+
+struct retro_variable {
+	const char *key;
+	const char *value;
+};
+
+typedef bool (*retro_environment_t)(unsigned cmd, void *data);
+typedef void (*retro_video_refresh_t)(const void *data, unsigned width, unsigned height, size_t pitch);
+typedef void (*retro_audio_sample_t)(int16_t left, int16_t right);
+typedef size_t (*retro_audio_sample_batch_t)(const int16_t *data, size_t frames);
+typedef void (*retro_input_poll_t)(void);
+typedef int16_t (*retro_input_state_t)(unsigned port, unsigned device, unsigned index, unsigned id);
+
+// Go can't call C function pointers, so the callbacks are kept in callbacks.c,
+// and we call them through these.
+void set_environment(retro_environment_t cb);
+void set_video_refresh(retro_video_refresh_t cb);
+void set_audio_sample_batch(retro_audio_sample_batch_t cb);
+void set_input_poll(retro_input_poll_t cb);
+void set_input_state(retro_input_state_t cb);
+
+bool call_environment(unsigned cmd, void *data);
+void call_video_refresh(const void *data, unsigned width, unsigned height, size_t pitch);
+size_t call_audio_sample_batch(const int16_t *data, size_t frames);
+void call_input_poll(void);
+int16_t call_input_state(unsigned port, unsigned device, unsigned index, unsigned id);
+
+// the frame and the audio are handed to the frontend from C memory, since
+// cgo doesn't let C hang on to Go pointers.
+extern uint32_t framebuffer[64 * 32];
+extern int16_t audiobuffer[2 * 735];
+
+#endif
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/livesplit"
+)
+
+// splitList collects the -split flags.
+type splitList []livesplit.Condition
+
+func (s *splitList) String() string {
+	parts := make([]string, len(*s))
+	for i, cond := range *s {
+		parts[i] = cond.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *splitList) Set(value string) error {
+	cond, err := livesplit.ParseCondition(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, cond)
+	return nil
+}
+
+// startSplitter connects to LiveSplit Server at addr, and splits c8's runs there.
+// Call it before loading the ROM, so the timer starts with the game.
+func startSplitter(c8 *cpu.Chip8, addr string, splits splitList) (*livesplit.Client, error) {
+	client, err := livesplit.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	splitter := livesplit.NewSplitter(client, splits)
+	// if LiveSplit goes away it'll fail every time; once is enough to hear about it.
+	var once sync.Once
+	splitter.Errors = func(err error) {
+		once.Do(func() { log.Printf("livesplit: %v", err) })
+	}
+	splitter.Attach(c8)
+	return client, nil
+}
@@ -0,0 +1,280 @@
+// Package livesplit auto-splits Chip8 speedruns in LiveSplit. It watches the Chip8
+// run, and when the next split's condition comes true -- a score reaching some value,
+// the screen showing the level-complete picture, the game getting to the code that
+// starts the next level -- it tells LiveSplit to split, over the LiveSplit Server protocol.
+//
+// To use it, start the server in LiveSplit (Control > Start TCP Server; it listens on
+// port 16834 unless you tell it otherwise) before starting the emulator.
+package livesplit
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// DefaultAddr is where LiveSplit Server listens unless it's told otherwise.
+const DefaultAddr = "localhost:16834"
+
+// A Timer is something that can be told to start, split and reset, like LiveSplit.
+type Timer interface {
+	// Send sends a LiveSplit Server command, like "starttimer" or "split".
+	Send(command string) error
+}
+
+// Client is a connection to LiveSplit Server. Commands are sent in the background,
+// so a slow LiveSplit never slows down the game.
+type Client struct {
+	conn     net.Conn
+	commands chan string
+
+	mu  sync.Mutex
+	err error
+}
+
+// Dial connects to LiveSplit Server at addr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, commands: make(chan string, 64)}
+	go c.send()
+	return c, nil
+}
+
+func (c *Client) send() {
+	w := bufio.NewWriter(c.conn)
+	for command := range c.commands {
+		w.WriteString(command + "\r\n")
+		if err := w.Flush(); err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			// keep draining, so nobody blocks on a dead connection.
+		}
+	}
+}
+
+// Send queues a command for LiveSplit. It returns the error from the last command
+// that failed to send, if any did: once the connection's broken, it stays broken.
+func (c *Client) Send(command string) error {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case c.commands <- command:
+		return nil
+	default:
+		return fmt.Errorf("livesplit: too many commands waiting to be sent; dropped %q", command)
+	}
+}
+
+// Close hangs up on LiveSplit, once the commands already queued have been sent.
+func (c *Client) Close() error {
+	close(c.commands)
+	return c.conn.Close()
+}
+
+// A Condition is something that happens during a run, which a split waits for.
+type Condition struct {
+	kind    conditionKind
+	address uint16
+	value   byte
+	atLeast bool
+	hash    [sha1.Size]byte
+}
+
+type conditionKind int
+
+const (
+	// the Chip8 executes the instruction at address.
+	reachedPC conditionKind = iota
+	// the byte at address is value (or at least value).
+	memoryValue
+	// the screen's ScreenHash is hash.
+	screenHash
+)
+
+// ParseCondition parses a condition, written one of these ways:
+//
+//	pc=0x2a4           the Chip8 executes the instruction at 0x2a4, like a breakpoint
+//	mem[0x3f0]==5      the byte at 0x3f0 becomes 5
+//	mem[0x3f0]>=5      the byte at 0x3f0 becomes 5 or more
+//	screen=<sha1>      the screen looks exactly like it did when it had that ScreenHash
+//
+// Addresses and values can be in hex (with 0x) or decimal.
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "pc="):
+		addr, err := parseNumber(s[len("pc="):], 0xfff)
+		if err != nil {
+			return Condition{}, err
+		}
+		return Condition{kind: reachedPC, address: uint16(addr)}, nil
+
+	case strings.HasPrefix(s, "mem["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return Condition{}, fmt.Errorf("livesplit: condition %q is missing a ]", s)
+		}
+		addr, err := parseNumber(s[len("mem["):end], 0xfff)
+		if err != nil {
+			return Condition{}, err
+		}
+		cond := Condition{kind: memoryValue, address: uint16(addr)}
+		rest := s[end+1:]
+		switch {
+		case strings.HasPrefix(rest, "=="):
+			rest = rest[2:]
+		case strings.HasPrefix(rest, ">="):
+			rest = rest[2:]
+			cond.atLeast = true
+		default:
+			return Condition{}, fmt.Errorf("livesplit: condition %q needs == or >= after the address", s)
+		}
+		value, err := parseNumber(rest, 0xff)
+		if err != nil {
+			return Condition{}, err
+		}
+		cond.value = byte(value)
+		return cond, nil
+
+	case strings.HasPrefix(s, "screen="):
+		hash, err := hex.DecodeString(s[len("screen="):])
+		if err != nil || len(hash) != sha1.Size {
+			return Condition{}, fmt.Errorf("livesplit: %q isn't a screen hash", s[len("screen="):])
+		}
+		cond := Condition{kind: screenHash}
+		copy(cond.hash[:], hash)
+		return cond, nil
+	}
+	return Condition{}, fmt.Errorf("livesplit: don't know what condition %q means", s)
+}
+
+func parseNumber(s string, max uint64) (uint64, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 16)
+	if err != nil || n > max {
+		return 0, fmt.Errorf("livesplit: %q isn't a number from 0 to %#x", s, max)
+	}
+	return n, nil
+}
+
+func (cond Condition) String() string {
+	switch cond.kind {
+	case reachedPC:
+		return fmt.Sprintf("pc=%#03x", cond.address)
+	case memoryValue:
+		op := "=="
+		if cond.atLeast {
+			op = ">="
+		}
+		return fmt.Sprintf("mem[%#03x]%s%d", cond.address, op, cond.value)
+	default:
+		return "screen=" + hex.EncodeToString(cond.hash[:])
+	}
+}
+
+// ScreenHash is the hash of a screen, for screen= conditions: the SHA-1 of its
+// 256 bytes of video memory, in hex. The emulator logs the screen's hash whenever
+// memory is dumped, which is the easy way to get one.
+func ScreenHash(videoMemory []byte) string {
+	hash := sha1.Sum(videoMemory)
+	return hex.EncodeToString(hash[:])
+}
+
+// Splitter splits a run: it starts the timer when the game starts, splits each time
+// the next split's condition comes true, and resets the timer when the game does.
+type Splitter struct {
+	timer  Timer
+	splits []Condition
+	// Errors gets told about anything that goes wrong talking to the timer.
+	// It's called from the Chip8's goroutine.
+	Errors func(error)
+
+	// next is the split we're waiting for. It's only touched from the Chip8's goroutine.
+	next int
+	// lastFrame is the last frame we saw, so we can tell when the Chip8 is reset.
+	lastFrame uint64
+	started   bool
+}
+
+// NewSplitter returns a Splitter that tells timer to split when each of the
+// splits' conditions comes true, in order.
+func NewSplitter(timer Timer, splits []Condition) *Splitter {
+	return &Splitter{timer: timer, splits: splits}
+}
+
+// Attach starts splitting c8's runs, replacing its OnInstruction and OnFrame hooks.
+// The run starts with the next frame -- attach the splitter before loading the ROM.
+func (s *Splitter) Attach(c8 *cpu.Chip8) {
+	c8.OnInstruction(func(pc, opcode uint16) {
+		if cond, ok := s.waiting(); ok && cond.kind == reachedPC && pc == cond.address {
+			s.split()
+		}
+	})
+	c8.OnFrame(func(frame uint64) {
+		// the frame count starting over means the game's been reset (or a
+		// savestate loaded), and so has the run.
+		if frame < s.lastFrame && s.started {
+			s.command("reset")
+			s.started = false
+			s.next = 0
+		}
+		s.lastFrame = frame
+		if !s.started {
+			s.command("starttimer")
+			s.started = true
+		}
+		cond, ok := s.waiting()
+		if !ok || cond.kind == reachedPC {
+			return
+		}
+		state := c8.Snapshot()
+		if cond.met(state) {
+			s.split()
+		}
+	})
+}
+
+// waiting returns the condition the next split is waiting for, if there are splits left.
+func (s *Splitter) waiting() (Condition, bool) {
+	if !s.started || s.next >= len(s.splits) {
+		return Condition{}, false
+	}
+	return s.splits[s.next], true
+}
+
+func (s *Splitter) split() {
+	s.command("split")
+	s.next++
+}
+
+func (s *Splitter) command(command string) {
+	if err := s.timer.Send(command); err != nil && s.Errors != nil {
+		s.Errors(err)
+	}
+}
+
+// met reports whether a memory or screen condition is true of state.
+func (cond Condition) met(state cpu.Chip8State) bool {
+	switch cond.kind {
+	case memoryValue:
+		value := state.Memory[cond.address]
+		return value == cond.value || (cond.atLeast && value > cond.value)
+	case screenHash:
+		return sha1.Sum(state.VideoMemory) == cond.hash
+	}
+	return false
+}
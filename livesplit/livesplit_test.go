@@ -0,0 +1,72 @@
+package livesplit_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/livesplit"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// commandLog is a Timer that writes down what it's told.
+type commandLog []string
+
+func (l *commandLog) Send(command string) error {
+	*l = append(*l, command)
+	return nil
+}
+
+// Splitter
+// should start the timer, split on each condition in order, and reset with the game
+func TestSplitter(t *testing.T) {
+	var splits []livesplit.Condition
+	for _, s := range []string{"pc=0x202", "mem[0x200]>=0x60"} {
+		cond, err := livesplit.ParseCondition(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		splits = append(splits, cond)
+	}
+	var timer commandLog
+	c := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c.SetLogLevel(cpu.LogNone)
+	livesplit.NewSplitter(&timer, splits).Attach(c)
+	rom := []byte{
+		0x60, 0x05, // LD V0 05
+		0x12, 0x02, // JP 202
+	}
+	if err := c.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+	if err := c.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	c.Step()
+
+	want := commandLog{"starttimer", "split", "split", "reset", "starttimer"}
+	if !reflect.DeepEqual(timer, want) {
+		t.Errorf("the timer was told %q, want %q", timer, want)
+	}
+}
+
+// ParseCondition
+// should turn away conditions that don't make sense
+func TestParseCondition(t *testing.T) {
+	for _, s := range []string{"pc=0x1000", "mem[0x300]=5", "mem[0x300]>=256", "screen=abc", "score=5"} {
+		if _, err := livesplit.ParseCondition(s); err == nil {
+			t.Errorf("ParseCondition(%q) should have failed", s)
+		}
+	}
+}
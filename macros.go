@@ -0,0 +1,193 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/demo"
+	"github.com/mpingram/chip8/metadata"
+)
+
+// recordMacroKey starts recording a macro, or throws away the one being recorded.
+const recordMacroKey = glfw.KeyF11
+
+// macros records keys pressed on the keypad and plays them back: F11 starts
+// recording, Ctrl and a function key (the save slot keys, see savestate.go) keeps
+// what's been pressed since as that key's macro, and after that Ctrl and the key
+// plays it. They're for the boring bits you do every time, like getting through a
+// game's menus. Each ROM's macros are kept in its metadata file (see the metadata
+// package), so they're still there next time.
+type macros struct {
+	c8     *cpu.Chip8
+	keypad *control.Keypad
+	input  *GLFWKeyboardInput
+	osd    *onScreenDisplay
+	// romPath is the ROM the macros are for, and bound its macros, by the name of
+	// the function key they're on.
+	romPath string
+	bound   map[string]*demo.Script
+	// recorder is the macro being recorded, if there is one.
+	recorder *demo.Recorder
+	// playing is the macro being played, if there is one, started the frame it
+	// started on, and held the key it's holding down.
+	playing *demo.Script
+	started uint64
+	held    cpu.KeyCode
+}
+
+func newMacros(c8 *cpu.Chip8, keypad *control.Keypad, input *GLFWKeyboardInput, osd *onScreenDisplay) *macros {
+	return &macros{c8: c8, keypad: keypad, input: input, osd: osd, held: cpu.KeyNone}
+}
+
+// bindMacroKeys binds the key that records macros. The keys that keep and play them
+// are the save slot keys with Ctrl, which bindSaveSlotKeys hands over to use.
+func bindMacroKeys(input *GLFWKeyboardInput, mac *macros) {
+	input.Describe(keyName(recordMacroKey), "record a macro of keypad presses (f11 again throws it away)")
+	input.Describe("ctrl+"+keyName(slotKeys[0])+"-"+keyName(slotKeys[len(slotKeys)-1]), "keep the macro being recorded on that key, or play the one that's there")
+	input.OnHotkey(recordMacroKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		if mac.recorder != nil {
+			mac.recorder = nil
+			mac.osd.setIndicator("macro", "")
+			mac.osd.showToast("threw the macro away")
+			return
+		}
+		mac.stop()
+		mac.recorder = new(demo.Recorder)
+		mac.osd.setIndicator("macro", "recording a macro - ctrl+f1-f10 keeps it")
+	})
+}
+
+// load takes up the macros for the ROM at path, which has just been loaded,
+// forgetting the last ROM's and anything being recorded for it.
+func (mac *macros) load(path string, bound map[string]*demo.Script) {
+	mac.stop()
+	mac.recorder = nil
+	mac.osd.setIndicator("macro", "")
+	mac.romPath = path
+	mac.bound = make(map[string]*demo.Script)
+	for name, script := range bound {
+		if !isSlotKeyName(name) {
+			log.Printf("%s: there's no %q key to put a macro on; they go on f1 to f10", metadata.Path(path), name)
+			continue
+		}
+		mac.bound[name] = script
+	}
+}
+
+// isSlotKeyName reports whether name is what keyName calls one of slotKeys.
+func isSlotKeyName(name string) bool {
+	for _, key := range slotKeys {
+		if keyName(key) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// use is Ctrl and a function key: it keeps the macro being recorded on key, if
+// there is one, and plays the one on key if there isn't.
+func (mac *macros) use(key glfw.Key) {
+	name := keyName(key)
+	if mac.recorder != nil {
+		script := mac.recorder.Script()
+		mac.recorder = nil
+		mac.osd.setIndicator("macro", "")
+		if len(script.Presses) == 0 {
+			mac.osd.showToast("nothing was pressed, so there's no macro to keep")
+			return
+		}
+		mac.bound[name] = script
+		if err := mac.save(name, script); err != nil {
+			log.Printf("saving the macro: %v", err)
+			mac.osd.showToast("macro on ctrl+" + name + ", until the next rom (couldn't save it)")
+			return
+		}
+		mac.osd.showToast("macro saved on ctrl+" + name)
+		return
+	}
+	script, ok := mac.bound[name]
+	if !ok {
+		mac.osd.showToast(fmt.Sprintf("there's no macro on ctrl+%s (%s records one)", name, keyName(recordMacroKey)))
+		return
+	}
+	mac.stop()
+	mac.playing, mac.started = script, mac.c8.FrameCount()
+	mac.osd.setIndicator("macro", "playing the macro on ctrl+"+name)
+}
+
+// save writes the macro on name into the ROM's metadata file, along with whatever
+// else it says.
+func (mac *macros) save(name string, script *demo.Script) error {
+	if strings.HasPrefix(mac.romPath, tutorialPrefix) {
+		return fmt.Errorf("the tutorials haven't got metadata files to keep macros in")
+	}
+	m, err := metadata.Read(mac.romPath)
+	if err != nil {
+		return err
+	}
+	if m == nil && isCartridge(mac.romPath) {
+		// a metadata file beats the cartridge's own options, so it had better
+		// say the same things.
+		if cart, err := readCartridge(mac.romPath); err == nil {
+			m = cartridgeMetadata(cart)
+		}
+	}
+	if m == nil {
+		m = new(metadata.Metadata)
+	}
+	if m.Macros == nil {
+		m.Macros = make(map[string]*demo.Script)
+	}
+	m.Macros[name] = script
+	return metadata.Write(mac.romPath, m)
+}
+
+// update records the keys held down, or presses the macro's, for this frame. Call
+// it once a frame, on the main thread.
+func (mac *macros) update() {
+	frame := mac.c8.FrameCount()
+	if mac.recorder != nil {
+		mac.recorder.Record(frame, mac.input.Held())
+	}
+	if mac.playing == nil {
+		return
+	}
+	at := frame - mac.started
+	if at >= mac.playing.End() {
+		mac.stop()
+		return
+	}
+	key := mac.playing.Held(at)
+	if key == mac.held {
+		return
+	}
+	if mac.held != cpu.KeyNone {
+		mac.keypad.Release(mac.held)
+	}
+	if key != cpu.KeyNone {
+		mac.keypad.Press(key)
+	}
+	mac.held = key
+}
+
+// stop stops the macro that's playing, if one is, and lets go of its key.
+func (mac *macros) stop() {
+	if mac.held != cpu.KeyNone {
+		mac.keypad.Release(mac.held)
+		mac.held = cpu.KeyNone
+	}
+	if mac.playing != nil {
+		mac.playing = nil
+		mac.osd.setIndicator("macro", "")
+	}
+}
@@ -1,13 +1,29 @@
+//go:build cgo
+// +build cgo
+
 package main
 
 import (
-	_ "fmt"
-	"io/ioutil"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/allocs"
+	"github.com/mpingram/chip8/archive"
+	"github.com/mpingram/chip8/control"
 	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/lesson"
+	"github.com/mpingram/chip8/livesplit"
+	"github.com/mpingram/chip8/netplay"
+	"github.com/mpingram/chip8/playlist"
+	"github.com/mpingram/chip8/timing"
 )
 
 func init() {
@@ -15,38 +31,606 @@ func init() {
 	runtime.LockOSThread()
 }
 
+// turboFrameSkip is how many frames go by for each frame we render in turbo mode.
+const turboFrameSkip = 8
+
+// turboKey is held down to fast-forward.
+const turboKey = glfw.KeyTab
+
+// dumpKey dumps all of memory to a file.
+const dumpKey = glfw.KeyF12
+
 func main() {
+	if runCommand() {
+		return
+	}
 
-	err := glfw.Init()
+	turbo := flag.Bool("turbo", false, "run as fast as possible, without a speed limit")
+	speed := flag.Int("speed", cpu.DefaultSpeed, "number of instructions to execute per second")
+	slowMotion := flag.Float64("slowmo", 1, "start in slow motion, at this `rate` (0.1 is a tenth of the speed); [ and ] change it as you go")
+	registers := flag.Bool("registers", false, "open a second window that shows the registers, stack and memory changing as the game runs")
+	debug := flag.Bool("debug", false, "like -registers, but with the code around PC and the memory around I in the window too")
+	explain := flag.Bool("explain", false, "explain each instruction in plain English as it runs, to learn how the Chip8 works (try a low -speed)")
+	httpAddr := flag.String("http", "", "serve the HTTP control API on this `address`, like localhost:8080")
+	token := flag.String("token", "", "the `token` HTTP clients need to control the emulator (default: a random one, printed at startup)")
+	headless := flag.Bool("headless", false, "run without a window; watch the screen in a browser with -http, or with chip8 view and -display")
+	displayAddr := flag.String("display", "", "with -headless, let remote displays (chip8 view) connect on this `address`, like :7800")
+	crowdWindow := flag.Duration("crowd", 0, "let a crowd vote on keys over HTTP, pressing the winner every `window`, like 2s")
+	crowdAliases := flag.String("crowd-aliases", "", "names the crowd can vote for keys by, like up=1,down=4")
+	broadcast := flag.Bool("broadcast", false, "let anyone watch, read-only and without the token, at /watch on the HTTP server")
+	broadcastDelay := flag.Duration("broadcast-delay", 0, "with -broadcast, hold the broadcast this far behind the game, like 5s")
+	hostAddr := flag.String("host", "", "host a two-player netplay game, listening on `address`, like :7700")
+	joinAddr := flag.String("join", "", "join the netplay game hosted at `address`, like example.com:7700")
+	fullscreen := flag.Bool("fullscreen", false, "cover the whole screen with a borderless window, rather than switching video modes")
+	monitor := flag.Int("monitor", 0, "with -fullscreen, which `monitor` to cover, counting from 1 (default: the primary one)")
+	quirkList := flag.String("quirks", "", "make instructions behave like older interpreters did, for games that expect them to, as a comma-separated `list`: "+strings.Join(cpu.QuirkNames(), ", "))
+	fontName := flag.String("font", cpu.DefaultFont.Name, "draw numbers with this interpreter's `font`: "+strings.Join(cpu.FontNames(), ", "))
+	protectList := flag.String("protect", "", "catch programs writing where they shouldn't, as a comma-separated `list`: interpreter (0x000-0x1ff), program, all, and stop to stop the game when it happens rather than just saying so")
+	memory := flag.String("memory", "4k", "how much `memory` the Chip8 has: 4k, or 64k for XO-CHIP-sized programs")
+	var deviceFlags deviceFlags
+	flag.StringVar(&deviceFlags.serial, "serial", "", "give ROMs a serial port at 0xe98, and write what they send it to `file`, or stdout if it's -")
+	flag.BoolVar(&deviceFlags.clock, "clock", false, "give ROMs a real-time clock to read, at 0xe90 (see the devices package)")
+	keypad2 := flag.Bool("keypad2", false, "give the Chip8 a second keypad, on the numpad, for two-player games (CHIP-8X's SKP2 and SKNP2)")
+	pluginList := flag.String("plugin", "", "load Go plugins (built with -buildmode=plugin; see the plugins package) for more displays, inputs, speakers or debugger panels, as a comma-separated `list` of files")
+	minBeep := flag.Duration("min-beep", 0, "make every beep last at least this `long`, like 50ms, so the shortest ones can still be heard")
+	dataDir := flag.String("dir", "", "keep savestates, memory dumps and everything else the emulator writes under `folder` (default: the usual place on this system)")
+	var patches patchList
+	flag.Var(&patches, "patch", "load a file into memory after the ROM, as `file@address` (may be repeated)")
+	livesplitAddr := flag.String("livesplit", "", "auto-split speedruns in LiveSplit, talking to LiveSplit Server at `address`, like "+livesplit.DefaultAddr)
+	attractEach := flag.Duration("attract", 0, "attract mode, for kiosks and booths: show each ROM in the library (see the menu's load rom) for this `long`, like 30s, playing its demo script if it has one, until someone presses a key")
+	playlistPath := flag.String("playlist", "", "play the ROMs in the playlist `file` one after another, with pgdn and pgup for the next and previous (see the playlist package)")
+	watchROM := flag.Bool("watch", false, "start the ROM over whenever its file changes, for an edit-assemble-run loop without the run part")
+	lessonPath := flag.String("lesson", "", "play the lesson in `file` (see lessons/), explaining each instruction; press Enter for each step")
+	var splits splitList
+	flag.Var(&splits, "split", "with -livesplit, split when `condition` comes true: pc=0x2a4, mem[0x3f0]>=5 or screen=<hash> (may be repeated, in order)")
+	timingPath := flag.String("timing", "", "time every instruction, and write where the time went to `file` when you quit: a report, or a profile for go tool pprof if it ends in .pprof or .pb.gz")
+	pauseUnfocused := flag.Bool("pause-unfocused", false, "pause the game while the window doesn't have focus, and carry on when it does again (not in netplay or lessons)")
+	allocAudit := flag.Bool("allocs", false, "count heap allocations each frame, in the CPU loop and the renderer, and print what it found when you quit, to check the hot path still doesn't allocate (see the allocs package)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 [flags] [rom.ch8]\n")
+		flag.PrintDefaults()
+		printCommands()
+	}
+	flag.Parse()
+
+	romPath := "./roms/Pong (1 player).ch8"
+	var les *lesson.Lesson
+	if *lessonPath != "" {
+		var err error
+		if les, err = readLesson(*lessonPath); err != nil {
+			log.Fatal(err)
+		}
+		if les.ROM != "" {
+			romPath = lessonROM(*lessonPath, les)
+		}
+		// the lesson knows how fast it wants to go, unless you say otherwise.
+		speedGiven := false
+		flag.Visit(func(f *flag.Flag) { speedGiven = speedGiven || f.Name == "speed" })
+		if les.Speed > 0 && !speedGiven {
+			*speed = les.Speed
+		}
+		*explain = true
+	}
+	if flag.NArg() > 0 {
+		romPath = flag.Arg(0)
+	}
+	var list *playlist.Playlist
+	if *playlistPath != "" {
+		if flag.NArg() > 0 || les != nil || *attractEach > 0 {
+			log.Fatal("-playlist says which ROMs to play, so it can't be combined with a ROM, -lesson or -attract")
+		}
+		var err error
+		if list, err = playlist.Read(*playlistPath); err != nil {
+			log.Fatal(err)
+		}
+		romPath = list.Current().Path
+	}
+	rom, err := readROM(romPath)
 	if err != nil {
 		panic(err)
 	}
-	defer glfw.Terminate()
-	window, err := glfw.CreateWindow(640, 320, "Chip-8", nil, nil)
+	quirks, err := cpu.ParseQuirks(*quirkList)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	font, err := cpu.FontByName(*fontName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	memorySize, err := cpu.ParseMemorySize(*memory)
+	if err != nil {
+		log.Fatal(err)
+	}
+	protection, err := cpu.ParseWriteProtection(*protectList)
+	if err != nil {
+		log.Fatal(err)
 	}
-	glfw.WindowHint(glfw.Resizable, glfw.False)
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
-	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
-	window.MakeContextCurrent()
 
-	renderer := NewOpenGLRenderer(window)
-	input := NewGLFWKeyboardInput(window)
+	if *crowdWindow > 0 && *httpAddr == "" {
+		log.Fatal("-crowd needs -http, or there'd be no way to vote")
+	}
+	if *broadcast && *httpAddr == "" {
+		log.Fatal("-broadcast needs -http, or there'd be nowhere to watch")
+	}
+	if len(splits) > 0 && *livesplitAddr == "" {
+		log.Fatal("-split needs -livesplit, or there'd be nobody to tell")
+	}
+	if les != nil && (*headless || *livesplitAddr != "" || *hostAddr != "" || *joinAddr != "") {
+		log.Fatal("-lesson can't be combined with -headless, -livesplit or netplay")
+	}
+	if list != nil && (*headless || *hostAddr != "" || *joinAddr != "") {
+		log.Fatal("-playlist can't be combined with -headless or netplay")
+	}
+	if *attractEach > 0 && (les != nil || *headless || *hostAddr != "" || *joinAddr != "") {
+		log.Fatal("-attract can't be combined with -lesson, -headless or netplay")
+	}
+	if *watchROM && (les != nil || *headless || *hostAddr != "" || *joinAddr != "") {
+		log.Fatal("-watch can't be combined with -lesson, -headless or netplay")
+	}
+	// both players' games have to stay exactly the same, so nothing can change one of them behind the other's back.
+	if (len(patches) > 0 || deviceFlags.clock) && (*hostAddr != "" || *joinAddr != "") {
+		log.Fatal("-patch and -clock can't be combined with netplay")
+	}
+	if *allocAudit && (*headless || *livesplitAddr != "") {
+		log.Fatal("-allocs can't be combined with -headless or -livesplit")
+	}
+	if *timingPath != "" && *headless {
+		log.Fatal("-timing can't be combined with -headless")
+	}
+	if *displayAddr != "" && !*headless {
+		log.Fatal("-display only works with -headless")
+	}
+	if *headless {
+		if *httpAddr == "" && *displayAddr == "" && *grpcAddr == "" {
+			log.Fatal("-headless needs -http or -display, or there'd be no way to see anything")
+		}
+		config := headlessConfig{
+			romPath:        romPath,
+			rom:            rom,
+			store:          openStore(*dataDir),
+			speed:          *speed,
+			turbo:          *turbo,
+			quirks:         quirks,
+			minBeep:        *minBeep,
+			font:           font,
+			memorySize:     memorySize,
+			protection:     protection,
+			devices:        deviceFlags,
+			keypad2:        *keypad2,
+			patches:        patches,
+			httpAddr:       *httpAddr,
+			grpcAddr:       *grpcAddr,
+			crowdWindow:    *crowdWindow,
+			crowdAliases:   *crowdAliases,
+			displayAddr:    *displayAddr,
+			broadcast:      *broadcast,
+			broadcastDelay: *broadcastDelay,
+		}
 
-	romPath := "./roms/Pong (1 player).ch8"
-	rom, err := ioutil.ReadFile(romPath)
+		if *httpAddr != "" {
+			config.token = apiToken(*token)
+		}
+		runHeadless(context.Background(), config)
+		return
+	}
+
+	// both players have to be connected before anything happens, so get that over with first.
+	session, err := connectNetplay(*hostAddr, *joinAddr, rom, netplay.Settings{Speed: *speed, Quirks: quirks, Font: font, MemorySize: memorySize, Protection: protection})
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	if session != nil {
+		defer session.Close()
+		// the host decides how the game plays for both players.
+		settings := session.Settings()
+		quirks, font, memorySize, protection = settings.Quirks, settings.Font, settings.MemorySize, settings.Protection
 	}
 
-	c8 := new(cpu.Chip8)
-	c8.AttachDisplay(renderer)
-	c8.AttachInput(input)
-	c8.Run(rom)
+	window := openWindow(*fullscreen, *monitor)
+	defer glfw.Terminate()
 
+	renderer := NewOpenGLRenderer(window)
+	title := newWindowTitle(window, romPath)
+	title.netplay = session != nil
+	title.broadcasting = *broadcast
+	input := NewGLFWKeyboardInput(window)
+
+	// keys can be pressed over HTTP as well as on the keyboard -- except in netplay,
+	// where the Chip8 sees both players' keys, and nobody else's.
+	keypad := control.NewKeypad(input)
+	var keyboard cpu.Keyboard = keypad
+	var netplayKeys *netplay.Keyboard
+	if session != nil {
+		netplayKeys = netplay.NewKeyboard()
+		keyboard = netplayKeys
+		keypad = nil
+	}
+	c8 := cpu.NewChip8(keyboard, silentSpeaker{}, cpu.WithQuirks(quirks), cpu.MinimumBeep(*minBeep), cpu.WithFont(font), cpu.WithMemorySize(memorySize), cpu.ProtectMemory(protection))
 	defer c8.Log.WriteTo(os.Stdout)
+	watchProtectedWrites(c8, protection)
+	serial := attachDevices(c8, deviceFlags)
+	// the second keypad stays on this side in netplay, where there's no sending it to the other.
+	var secondKeypad *control.Keypad
+	if *keypad2 && session == nil {
+		secondKeypad = control.NewKeypad(input.SecondKeypad())
+		c8.ConnectSecondKeyboard(secondKeypad)
+	}
+	// plugins get the Chip8 once it's all wired up, before it starts.
+	extras := startPlugins(c8, romPath, *pluginList)
+	c8.ConnectSpeaker(extras.Speaker(silentSpeaker{}))
+	if *explain {
+		// explanations are for reading as they happen, not all at once at the end.
+		c8.SetLogLevel(cpu.LogExplanations)
+		c8.SetLogOutput(os.Stdout)
+	}
 
+	var audit *allocs.Audit
+	if *allocAudit {
+		audit = allocs.New()
+		c8.OnFrame(func(frame uint64) { audit.Frame() })
+	}
+	var timings *timing.Profile
+	if *timingPath != "" {
+		timings = timing.NewProfile()
+		c8.TimeInstructions(timings)
+	}
+
+	c8.SetSpeed(*speed)
+	if session != nil {
+		// the host decides the speed and the dice rolls for both players.
+		c8.SetSpeed(session.Speed())
+		c8.SetSeed(session.Seed())
+	}
+
+	// fast-forward while the turbo key is held down (or all the time, with --turbo).
+	c8.SetTurbo(*turbo)
+	input.Describe(keyName(turboKey), "fast-forward, while held")
+	input.OnHotkey(turboKey, func(pressed bool, mods glfw.ModifierKey) {
+		c8.SetTurbo(*turbo || pressed)
+	})
+
+	osd := new(onScreenDisplay)
+	// in netplay the session runs the Chip8 a frame per tick itself, so slow motion wouldn't
+	// do anything, and the host decides the speed.
+	if session == nil {
+		setSlowMotion(c8, osd, *slowMotion)
+		bindSlowMotionKeys(input, c8, osd)
+		bindSpeedKeys(input, c8, osd, *speed)
+	}
+	store := openStore(*dataDir)
+	slots := newSaveSlots(store, romPath)
+	// macros are for games played here, by whoever's at the keyboard: lessons press
+	// keys themselves, and netplay has no keypad to press them on.
+	var mac *macros
+	if les == nil && session == nil {
+		mac = newMacros(c8, keypad, input, osd)
+		bindMacroKeys(input, mac)
+	}
+	// loading a savestate on one side only would knock the players out of step.
+	if session == nil {
+		bindSaveSlotKeys(input, slots, c8, osd, mac)
+	}
+	// each player's RPL flags on disk are their own, so netplay starts without any.
+	if session == nil {
+		c8.ConnectRPLFlags(slots.rplFlags())
+	}
+	if *livesplitAddr != "" {
+		timer, err := startSplitter(c8, *livesplitAddr, splits)
+		if err != nil {
+			log.Fatalf("connecting to LiveSplit: %v", err)
+		}
+		defer timer.Close()
+	}
+
+	if err := c8.Load(rom); err != nil {
+		log.Fatal(err)
+	}
+	if err := patches.apply(c8); err != nil {
+		log.Fatal(err)
+	}
+	// lessons know how they want to be played, and in netplay both players have to
+	// play the same way, metadata or no metadata.
+	var setup *romSetup
+	if les == nil && session == nil {
+		setup = &romSetup{c8: c8, input: input, title: title, osd: osd, renderer: renderer, macros: mac, speed: *speed, quirks: quirks, font: font, given: givenFlags()}
+		setup.apply(romPath)
+	}
+	var server *control.Server
+	if *httpAddr != "" {
+		server = control.NewServer(c8, keypad, apiToken(*token))
+		if serial != nil {
+			server.HandleSerial(serial)
+		}
+		if secondKeypad != nil {
+			server.HandleSecondKeypad(secondKeypad)
+		}
+		if *crowdWindow > 0 && keypad != nil {
+			if err := startCrowd(server, keypad, *crowdWindow, *crowdAliases); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *broadcast {
+			server.Broadcast(*broadcastDelay)
+			log.Printf("spectators can watch at http://%s/watch", *httpAddr)
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddr, server))
+		}()
+	}
+	var publishGRPC func(cpu.Frame)
+	if *grpcAddr != "" {
+		publishGRPC = serveGRPC(*grpcAddr, c8, keypad)
+	}
+	// render draws a frame, and sends it along to anyone watching over HTTP or gRPC.
+	drawFrame := func() {
+		frame := c8.ColorFrame()
+		renderer.Render(frame)
+		extras.Show(frame)
+		if server != nil {
+			server.PublishFrame(frame[0])
+		}
+		if publishGRPC != nil {
+			publishGRPC(frame[0])
+		}
+	}
+	render := drawFrame
+	if audit != nil {
+		render = func() { audit.Measure("renderer", drawFrame) }
+	}
+	input.Describe(keyName(dumpKey), "dump memory to a file")
+	input.OnHotkey(dumpKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		path, err := dumpAll(c8, store, romPath)
+		if err != nil {
+			log.Printf("dumping memory: %v", err)
+			osd.showToast("memory dump failed")
+			return
+		}
+		osd.showToast("memory dumped to " + path)
+		// handy for -split screen=...
+		log.Printf("memory dumped to %s; the screen hash is %s", path, livesplit.ScreenHash(c8.Snapshot().VideoMemory))
+	})
+	// OpenGL has to stay on the main thread, so the CPU gets its own goroutine.
+	// It never waits on us: we just pick up the latest frame whenever one is ready.
+	// If we were playing this ROM last time, ask whether to resume first.
+	cpuStarted := false
+	stopNetplay := make(chan struct{})
+	defer close(stopNetplay)
+	netplayErr := make(chan error, 1)
+	var player *lesson.Player
+	// resetROM starts the ROM over, leaving the Chip8 stopped.
+	resetROM := func() {
+		c8.Halt()
+		c8.Wait()
+		if err := c8.Load(rom); err != nil {
+			log.Printf("resetting: %v", err)
+		}
+	}
+	var hold *resetHold
+	var attract *attractMode
+	var playlistPlay *playlistPlayer
+	downloads, _ := store.Path(archive.ROMFolder)
+	m := &menu{window: window, renderer: renderer, osd: osd, c8: c8, romPath: romPath, downloads: downloads, reset: resetROM, secondKeypad: secondKeypad != nil}
+	m.load = func(path string) error {
+		loaded, err := readROM(path)
+		if err != nil {
+			return err
+		}
+		c8.Halt()
+		c8.Wait()
+		if err := c8.Load(loaded); err != nil {
+			return err
+		}
+		// from here on it's as if we'd started with this ROM: it gets its own
+		// save slots and autosave, and its name in the title.
+		rom, romPath = loaded, path
+		slots.switchROM(c8, path, session == nil)
+		title.rom = romLabel(path)
+		if setup != nil {
+			setup.apply(path)
+		}
+		return nil
+	}
+	var reloader *romWatcher
+	if *watchROM {
+		reloader = &romWatcher{menu: m, osd: osd, c8: c8}
+		defer reloader.close()
+	}
+	if list != nil {
+		playlistPlay = &playlistPlayer{list: list, menu: m, osd: osd, c8: c8}
+		playlistPlay.apply(list.Current(), time.Now())
+	}
+	if session != nil {
+		// in netplay, the session runs the Chip8 a frame at a time, in step with the other player.
+		go func() {
+			netplayErr <- session.Run(c8, netplayKeys, input.Held, stopNetplay)
+		}()
+	} else if les != nil {
+		// lessons always start from the beginning, and run the Chip8 themselves.
+		player = startLesson(les, c8, input)
+	} else {
+		// play binds the keys for playing a ROM, which need it to have started.
+		play := func() {
+			cpuStarted = true
+			bindPauseKey(input, c8)
+			if *pauseUnfocused {
+				pauseWhenUnfocused(window, c8)
+			}
+			bindMenu(input, m)
+			hold = bindResetKey(input, osd, func() {
+				resetROM()
+				go resume(c8)
+			})
+			if playlistPlay != nil {
+				bindPlaylistKeys(input, playlistPlay)
+			}
+		}
+		if *attractEach > 0 {
+			// attract mode goes round the library from the top, not from where anyone left off.
+			play()
+			attract = startAttractMode(m, osd, c8, keypad, input, *attractEach)
+		} else {
+			offerResume(input, osd, store, c8, rom, func() {
+				play()
+				go resume(c8)
+			})
+		}
+	}
+
+	bindHelpKey(input, osd)
+
+	var view *registerView
+	if *registers || *debug {
+		view = openRegisterView(window, *debug, extras.DebugPanels)
+	}
+	// showRegisters updates the register view, if it's open.
+	showRegisters := func() {
+		if view == nil {
+			return
+		}
+		if view.closed() {
+			view.destroy()
+			view = nil
+			return
+		}
+		view.show(c8, window)
+	}
+
+	refresh := time.NewTicker(time.Second / 60)
+	defer refresh.Stop()
+	skipped := 0
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+		// plugins' keys go in through the keypad, like keys over HTTP -- so in
+		// netplay, where there isn't one, they don't go in at all.
+		if keypad != nil {
+			extras.PollInputs(keypad)
+		}
+		title.update(c8)
+		m.update()
+		if hold != nil {
+			hold.update(time.Now())
+		}
+		if attract != nil {
+			attract.update(time.Now())
+		}
+		if mac != nil && cpuStarted {
+			mac.update()
+		}
+		if playlistPlay != nil && cpuStarted {
+			playlistPlay.update(time.Now())
+		}
+		if reloader != nil && cpuStarted {
+			reloader.update()
+		}
+		// lessons and netplay stop and start the Chip8 all the time, and that's not pausing.
+		if cpuStarted && session == nil {
+			showPaused(osd, c8)
+		}
+		if player != nil {
+			osd.setIndicator("lesson", player.Status())
+		}
+		select {
+		case err := <-netplayErr:
+			if err != nil {
+				log.Printf("netplay: %v", err)
+				osd.showToast("the other player is gone")
+			}
+		default:
+		}
+		if c8.IsTurbo() {
+			// no frame pacing in turbo mode: take frames as fast as they come,
+			// but only bother drawing every few of them.
+			select {
+			case <-c8.FrameReady():
+				skipped++
+			case <-refresh.C:
+				continue
+			}
+			if skipped < turboFrameSkip {
+				continue
+			}
+			skipped = 0
+			renderer.SetOverlay(osd.lines(time.Now()))
+			render()
+			showRegisters()
+			continue
+		}
+
+		<-refresh.C
+		frameReady := false
+		select {
+		case <-c8.FrameReady():
+			frameReady = true
+		default:
+		}
+		overlayChanged := renderer.SetOverlay(osd.lines(time.Now()))
+		if frameReady || overlayChanged {
+			render()
+		}
+		// the registers change even when the screen doesn't, and flashes need to fade.
+		showRegisters()
+	}
+
+	// save where we were, so we can pick up from here next time. (If we never
+	// got going, leave the autosave from last time alone.)
+	if cpuStarted {
+		c8.Halt()
+		c8.Wait()
+		if err := autosave(store, c8, rom); err != nil {
+			log.Printf("autosave: %v", err)
+		}
+	}
+	if audit != nil {
+		fmt.Fprintf(os.Stderr, "\nHeap allocations:\n")
+		audit.WriteReport(os.Stderr)
+	}
+	if timings != nil {
+		speed := c8.Speed()
+		if *turbo {
+			speed = 0
+		}
+		if err := writeTimings(*timingPath, timings, speed, romPath); err != nil {
+			log.Printf("timing: %v", err)
+		}
+	}
+}
+
+// openWindow initializes GLFW and opens the emulator window, with its OpenGL
+// context current. Call glfw.Terminate when you're done with it.
+// If fullscreen is true, the window covers the monitor numbered monitor instead
+// (see fullscreenMonitor).
+func openWindow(fullscreen bool, monitor int) *glfw.Window {
+	err := glfw.Init()
+	if err != nil {
+		panic(err)
+	}
+	width, height := 640, 320
+	var covering *glfw.Monitor
+	if fullscreen {
+		covering = fullscreenMonitor(monitor)
+		// borderless fullscreen: an ordinary window, with no border, exactly covering
+		// the monitor. There's no switching video modes, so alt-tabbing away, other
+		// monitors and streaming software all carry on like nothing happened.
+		glfw.WindowHint(glfw.Decorated, glfw.False)
+		mode := covering.GetVideoMode()
+		width, height = mode.Width, mode.Height
+	}
+	window, err := glfw.CreateWindow(width, height, "Chip-8", nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	if covering != nil {
+		window.SetPos(covering.GetPos())
+	}
+	glfw.WindowHint(glfw.Resizable, glfw.False)
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	setWindowIcon(window)
+	window.MakeContextCurrent()
+	return window
 }
@@ -0,0 +1,326 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/sweep"
+)
+
+// menuKey opens and closes the menu. So does the right mouse button.
+const menuKey = glfw.KeyEscape
+
+// romsPerPage is how many ROMs the Load ROM page lists at once; any more than
+// that and they don't fit on the screen.
+const romsPerPage = 8
+
+// menu is a menu on top of the screen, for everyone who'd rather click on things
+// than learn which key does what. It pauses the Chip8 while it's open.
+// Everything about it happens on the main thread.
+type menu struct {
+	window   *glfw.Window
+	renderer *OpenGLRenderer
+	osd      *onScreenDisplay
+	c8       *cpu.Chip8
+
+	// romPath is the ROM that's loaded now; the Load ROM page lists the ROMs next to it.
+	romPath string
+	// downloads is where the games downloaded from the CHIP-8 Archive are (see chip8
+	// browse), which the Load ROM page lists too, or "" if there's nowhere.
+	downloads string
+	// load loads the ROM at path, and reset starts the one that's loaded over again.
+	// Neither has to resume the Chip8: the menu does that as it closes.
+	load  func(path string) error
+	reset func()
+
+	open    bool
+	title   string
+	items   []menuItem
+	current int
+	// wasRunning is true if the Chip8 was running when the menu opened, so it
+	// should run again when the menu closes.
+	wasRunning bool
+	// secondKeypad is true if the numpad is a second keypad (see -keypad2).
+	secondKeypad bool
+	// palette is the index of the palette in use.
+	palette int
+	// cursorX and cursorY are where the mouse was last time we looked, so the
+	// selection only follows the mouse when the mouse moves.
+	cursorX, cursorY float64
+}
+
+// A menuItem is a line of the menu. Choosing it calls choose; items without
+// a choose are just there to be read.
+type menuItem struct {
+	label  string
+	choose func()
+}
+
+// menuHeaderLines is how many lines of the menu come before its items: the title and a gap.
+const menuHeaderLines = 2
+
+// bindMenu binds the keys and mouse buttons that work the menu.
+func bindMenu(input *GLFWKeyboardInput, m *menu) {
+	input.Describe(keyName(menuKey)+" or right-click", "menu")
+	input.OnHotkey(menuKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		if m.open {
+			m.close()
+		} else {
+			m.show()
+		}
+	})
+	input.OnHotkey(glfw.KeyUp, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed && m.open {
+			m.move(-1)
+		}
+	})
+	input.OnHotkey(glfw.KeyDown, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed && m.open {
+			m.move(+1)
+		}
+	})
+	input.OnHotkey(glfw.KeyEnter, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed && m.open {
+			m.choose(m.current)
+		}
+	})
+	m.window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		if action != glfw.Press {
+			return
+		}
+		switch {
+		case button == glfw.MouseButtonRight && !m.open:
+			m.show()
+		case button == glfw.MouseButtonRight:
+			m.close()
+		case button == glfw.MouseButtonLeft && m.open:
+			if item := m.itemAt(w.GetCursorPos()); item >= 0 {
+				m.choose(item)
+			}
+		}
+	})
+}
+
+// show opens the menu at the top page, pausing the Chip8.
+func (m *menu) show() {
+	m.wasRunning = m.wasRunning || m.c8.IsRunning()
+	m.c8.Halt()
+	m.open = true
+	m.mainPage()
+}
+
+// close closes the menu, and lets the Chip8 carry on if it was running before.
+func (m *menu) close() {
+	m.open = false
+	m.osd.clearPrompt()
+	if m.wasRunning {
+		m.wasRunning = false
+		go resume(m.c8)
+	}
+}
+
+// update has the selection follow the mouse. Call it once a frame.
+func (m *menu) update() {
+	if !m.open {
+		return
+	}
+	x, y := m.window.GetCursorPos()
+	if x == m.cursorX && y == m.cursorY {
+		return
+	}
+	m.cursorX, m.cursorY = x, y
+	if item := m.itemAt(x, y); item >= 0 && m.items[item].choose != nil {
+		m.current = item
+		m.draw()
+	}
+}
+
+// itemAt returns which item is under the mouse at (x, y), or -1.
+func (m *menu) itemAt(x, y float64) int {
+	// the menu is the first thing on the overlay, so its lines come first.
+	item := m.renderer.OverlayLineAt(x, y) - menuHeaderLines
+	if item < 0 || item >= len(m.items) {
+		return -1
+	}
+	return item
+}
+
+// move moves the selection by one item up or down, skipping over items that are just text.
+func (m *menu) move(by int) {
+	for i := m.current + by; i >= 0 && i < len(m.items); i += by {
+		if m.items[i].choose != nil {
+			m.current = i
+			m.draw()
+			return
+		}
+	}
+}
+
+func (m *menu) choose(item int) {
+	if choose := m.items[item].choose; choose != nil {
+		choose()
+	}
+}
+
+// setPage puts a page of items on the menu, with the first one that does something selected.
+func (m *menu) setPage(title string, items []menuItem) {
+	m.title, m.items, m.current = title, items, 0
+	for i, item := range items {
+		if item.choose != nil {
+			m.current = i
+			break
+		}
+	}
+	m.draw()
+}
+
+func (m *menu) draw() {
+	lines := []string{m.title, ""}
+	for i, item := range m.items {
+		if i == m.current && item.choose != nil {
+			lines = append(lines, "> "+item.label+" <")
+		} else {
+			lines = append(lines, item.label)
+		}
+	}
+	m.osd.showPrompt(lines...)
+}
+
+func (m *menu) mainPage() {
+	m.setPage("menu", []menuItem{
+		{"resume", m.close},
+		{"load rom", func() { m.romPage(0) }},
+		{"reset", func() {
+			m.reset()
+			m.wasRunning = true
+			m.close()
+			m.osd.showToast("reset")
+		}},
+		{"quirks: " + m.quirkProfile(), func() {
+			// quirks that aren't one of the profiles start over from the first.
+			next := (quirkProfileIndex(m.c8.Quirks()) + 1) % len(sweep.Profiles)
+			m.c8.SetQuirks(sweep.Profiles[next].Quirks)
+			m.mainPage()
+			m.current = 3
+			m.draw()
+		}},
+		{"palette: " + palettes[m.palette].name, func() {
+			m.palette = (m.palette + 1) % len(palettes)
+			m.renderer.SetPalette(palettes[m.palette])
+			m.mainPage()
+			m.current = 4
+			m.draw()
+		}},
+		{"key mapping", m.keyMappingPage},
+		{"quit", func() { m.window.SetShouldClose(true) }},
+	})
+}
+
+// quirkProfile returns the name of the quirk profile (see sweep.Profiles) the
+// Chip8's quirks are, or the quirks themselves if they aren't any of them.
+func (m *menu) quirkProfile() string {
+	quirks := m.c8.Quirks()
+	if i := quirkProfileIndex(quirks); i >= 0 {
+		return sweep.Profiles[i].Name
+	}
+	return quirks.String()
+}
+
+// quirkProfileIndex returns which of sweep.Profiles has exactly quirks, or -1.
+func quirkProfileIndex(quirks cpu.Quirks) int {
+	for i, p := range sweep.Profiles {
+		if p.Quirks == quirks {
+			return i
+		}
+	}
+	return -1
+}
+
+// romPage lists the ROMs there are to load, romsPerPage at a time from the one
+// numbered first: the ROMs in the same folder as the one that's loaded, the ones
+// in ./roms, and the tutorials.
+func (m *menu) romPage(first int) {
+	roms := m.roms()
+	var items []menuItem
+	for i := first; i < len(roms) && i < first+romsPerPage; i++ {
+		path := roms[i]
+		items = append(items, menuItem{romLabel(path), func() {
+			if err := m.load(path); err != nil {
+				log.Printf("loading %s: %v", path, err)
+				m.osd.showToast("couldn't load that rom")
+				return
+			}
+			m.romPath = path
+			m.wasRunning = true
+			m.close()
+			m.osd.showToast("loaded " + romLabel(path))
+		}})
+	}
+	if len(items) == 0 {
+		items = append(items, menuItem{"no roms here", nil})
+	}
+	items = append(items, menuItem{"", nil})
+	if first+romsPerPage < len(roms) {
+		items = append(items, menuItem{"more", func() { m.romPage(first + romsPerPage) }})
+	}
+	items = append(items, menuItem{"back", m.mainPage})
+	m.setPage("load rom", items)
+}
+
+// roms returns the paths of the ROMs the Load ROM page lists.
+func (m *menu) roms() []string {
+	var roms []string
+	seen := make(map[string]bool)
+	for _, dir := range []string{filepath.Dir(m.romPath), "roms", m.downloads} {
+		if dir == "" {
+			continue
+		}
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.ch8"))
+		carts, _ := filepath.Glob(filepath.Join(dir, "*.gif"))
+		paths = append(paths, carts...)
+		sort.Strings(paths)
+		for _, path := range paths {
+			if abs, err := filepath.Abs(path); err == nil && !seen[abs] {
+				seen[abs] = true
+				roms = append(roms, path)
+			}
+		}
+	}
+	names, err := tutorials()
+	if err != nil {
+		return roms
+	}
+	for i := range names {
+		roms = append(roms, fmt.Sprintf("%s%d", tutorialPrefix, i+1))
+	}
+	return roms
+}
+
+// keyMappingPage shows which keys on the keyboard are which keys on the Chip8's keypad.
+func (m *menu) keyMappingPage() {
+	items := []menuItem{{"keyboard  =  chip8 keypad", nil}}
+	keys, codes := describeKeypad(keypadRows, keypadMapping, " ")
+	for i := range keys {
+		items = append(items, menuItem{keys[i] + "  =  " + strings.ToUpper(codes[i]), nil})
+	}
+	if m.secondKeypad {
+		items = append(items, menuItem{"", nil}, menuItem{"numpad  =  keypad 2", nil})
+		keys, codes := describeKeypad(secondKeypadRows, secondKeypadMapping, " ")
+		for i := range keys {
+			items = append(items, menuItem{keys[i] + "  =  " + strings.ToUpper(codes[i]), nil})
+		}
+	}
+	items = append(items, menuItem{"", nil}, menuItem{"back", m.mainPage})
+	m.setPage("key mapping", items)
+}
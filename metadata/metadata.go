@@ -0,0 +1,225 @@
+// Package metadata reads what there is to know about a ROM, from a small JSON file
+// that sits next to it: what the game's called and who wrote it, how fast it wants
+// to run and which quirks it expects, and how to play it. A ROM is just bytes, with
+// nowhere to say any of that, and a game from 1978 that runs at the wrong speed with
+// the wrong quirks and keys nobody can guess isn't much of a game.
+//
+// The file's named after the ROM (pong.ch8's is pong.json), and everything in it's
+// optional:
+//
+//	{
+//	    "title": "Pong",
+//	    "author": "Paul Vervalin",
+//	    "description": "The classic, for one player against the wall.",
+//	    "speed": 700,
+//	    "quirks": "shift-vy,increment-i",
+//	    "font": "vip",
+//	    "keys": {"up": "1", "down": "4"},
+//	    "keys2": {"i": "1", "k": "4"},
+//	    "controls": ["1 and 4 move your paddle up and down"],
+//	    "macros": {"f1": ["0 5 2", "30 5 2"]}
+//	}
+//
+// speed is instructions a second; quirks and font are as for -quirks and -font;
+// keys are more keys to play with, on top of the usual ones, each one a key on the
+// keyboard (a letter, a digit, or up, down, left, right, space or enter) and the
+// Chip8 key it presses; keys2 are the same for the second keypad, for two-player
+// games (see -keypad2); and controls are lines of help on how to play.
+//
+// macros are keys pressed for you, by the function key that plays them (with
+// Ctrl): getting through a game's menus, say. Each is the lines of a demo script
+// (see the demo package), with its frames counted from when it starts. They're
+// usually recorded rather than written, and Write keeps them.
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/demo"
+)
+
+// Metadata is what a ROM's metadata file says about it.
+type Metadata struct {
+	Title, Author, Description string
+	// Speed is how many instructions a second it runs at, or 0 if it doesn't say.
+	Speed int
+	// Quirks and Font are what it wants to be played with, or nil if it doesn't say.
+	Quirks *cpu.Quirks
+	Font   *cpu.Font
+	// Keys are more keys to play it with, by the name of the key on the keyboard,
+	// and Keys2 the same for the second keypad.
+	Keys, Keys2 map[string]cpu.KeyCode
+	// Controls says how to play it, a line at a time.
+	Controls []string
+	// Macros are keys to press for you, by the name of the key that plays them.
+	Macros map[string]*demo.Script
+}
+
+// file is the metadata file, the way it's written.
+type file struct {
+	Title       string              `json:"title,omitempty"`
+	Author      string              `json:"author,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Speed       int                 `json:"speed,omitempty"`
+	Quirks      *string             `json:"quirks,omitempty"`
+	Font        string              `json:"font,omitempty"`
+	Keys        map[string]string   `json:"keys,omitempty"`
+	Keys2       map[string]string   `json:"keys2,omitempty"`
+	Controls    []string            `json:"controls,omitempty"`
+	Macros      map[string][]string `json:"macros,omitempty"`
+}
+
+// Path returns where the metadata for the ROM at romPath goes.
+func Path(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".json"
+}
+
+// Read reads the metadata for the ROM at romPath. If it hasn't got any, Read
+// returns nil, and no error: most ROMs haven't.
+func Read(romPath string) (*Metadata, error) {
+	path := Path(romPath)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// Write writes m as the metadata for the ROM at romPath, over whatever was there.
+func Write(romPath string, m *Metadata) error {
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(Path(romPath), append(data, '\n'), 0644)
+}
+
+// Parse reads a metadata file.
+func Parse(r io.Reader) (*Metadata, error) {
+	var f file
+	decoder := json.NewDecoder(r)
+	// a typo'd field would otherwise be quietly ignored, and then nobody knows why
+	// the game's still running at the wrong speed.
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&f); err != nil {
+		return nil, err
+	}
+	m := &Metadata{Title: f.Title, Author: f.Author, Description: f.Description, Controls: f.Controls}
+	if f.Speed < 0 {
+		return nil, fmt.Errorf("the speed is a number of instructions a second, not %d", f.Speed)
+	}
+	m.Speed = f.Speed
+	if f.Quirks != nil {
+		quirks, err := cpu.ParseQuirks(*f.Quirks)
+		if err != nil {
+			return nil, err
+		}
+		m.Quirks = &quirks
+	}
+	if f.Font != "" {
+		font, err := cpu.FontByName(f.Font)
+		if err != nil {
+			return nil, err
+		}
+		m.Font = &font
+	}
+	var err error
+	if m.Keys, err = parseKeys(f.Keys); err != nil {
+		return nil, err
+	}
+	if m.Keys2, err = parseKeys(f.Keys2); err != nil {
+		return nil, fmt.Errorf("keypad 2: %v", err)
+	}
+	if len(f.Macros) > 0 {
+		m.Macros = make(map[string]*demo.Script, len(f.Macros))
+		for name, lines := range f.Macros {
+			script, err := demo.Parse(strings.NewReader(strings.Join(lines, "\n")))
+			if err != nil {
+				return nil, fmt.Errorf("the %s macro: %v", name, err)
+			}
+			m.Macros[strings.ToLower(name)] = script
+		}
+	}
+	return m, nil
+}
+
+// Marshal writes the metadata out the way Parse reads it, for the likes of
+// downloaders, which know about a ROM and want whoever plays it to know too.
+func (m *Metadata) Marshal() ([]byte, error) {
+	f := file{Title: m.Title, Author: m.Author, Description: m.Description, Speed: m.Speed, Controls: m.Controls}
+	if m.Quirks != nil {
+		// String says "none" for no quirks, which is nice to read and no good to parse.
+		quirks := ""
+		if *m.Quirks != (cpu.Quirks{}) {
+			quirks = m.Quirks.String()
+		}
+		f.Quirks = &quirks
+	}
+	if m.Font != nil {
+		f.Font = m.Font.Name
+	}
+	f.Keys, f.Keys2 = marshalKeys(m.Keys), marshalKeys(m.Keys2)
+	if len(m.Macros) > 0 {
+		f.Macros = make(map[string][]string, len(m.Macros))
+		for name, script := range m.Macros {
+			f.Macros[name] = script.Lines()
+		}
+	}
+	return json.MarshalIndent(f, "", "    ")
+}
+
+// parseKeys reads the keys to play with, from the names of keys on the keyboard to
+// the Chip8 keys they press, as hex digits. It returns nil if there aren't any.
+func parseKeys(keys map[string]string) (map[string]cpu.KeyCode, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	codes := make(map[string]cpu.KeyCode, len(keys))
+	for name, key := range keys {
+		code, err := strconv.ParseUint(key, 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("%s is for Chip8 key %q, which isn't a key from 0 to f", name, key)
+		}
+		codes[strings.ToLower(name)] = cpu.KeyCode(code)
+	}
+	return codes, nil
+}
+
+// marshalKeys writes keys out the way parseKeys reads them.
+func marshalKeys(codes map[string]cpu.KeyCode) map[string]string {
+	if len(codes) == 0 {
+		return nil
+	}
+	keys := make(map[string]string, len(codes))
+	for name, code := range codes {
+		keys[name] = fmt.Sprintf("%x", code)
+	}
+	return keys
+}
+
+// Name returns what to call the ROM: its title and its author, as far as anyone knows.
+func (m *Metadata) Name() string {
+	switch {
+	case m.Title == "":
+		return ""
+	case m.Author == "":
+		return m.Title
+	}
+	return m.Title + " by " + m.Author
+}
@@ -0,0 +1,93 @@
+package metadata_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/metadata"
+)
+
+// Parse / Read
+// should read everything the file says, and leave alone what it doesn't
+// should read back whatever Marshal writes
+// should say what's wrong with a file that's got something wrong with it
+// should find nothing, and no error, for a ROM with no metadata
+// should read back what Write writes
+func TestMetadata(t *testing.T) {
+	m, err := metadata.Parse(strings.NewReader(`{
+		"title": "Pong",
+		"author": "Paul Vervalin",
+		"speed": 700,
+		"quirks": "shift-vy",
+		"keys": {"Up": "1", "down": "c"},
+		"keys2": {"I": "1", "k": "c"},
+		"controls": ["1 and 4 move your paddle"],
+		"macros": {"F1": ["0 5 2", "30 5 2"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name() != "Pong by Paul Vervalin" || m.Speed != 700 || len(m.Controls) != 1 {
+		t.Errorf("got %+v", m)
+	}
+	if m.Quirks == nil || !m.Quirks.ShiftVy || m.Font != nil {
+		t.Errorf("got quirks %v and font %v, want shift-vy and no font", m.Quirks, m.Font)
+	}
+	if m.Keys["up"] != 0x1 || m.Keys["down"] != 0xc {
+		t.Errorf("got keys %v, want up on 1 and down on c", m.Keys)
+	}
+	if len(m.Keys2) != 2 || m.Keys2["i"] != 0x1 || m.Keys2["k"] != 0xc {
+		t.Errorf("got keys %v for keypad 2, want i on 1 and k on c", m.Keys2)
+	}
+	if f1 := m.Macros["f1"]; f1 == nil || f1.Held(31) != 5 || f1.End() != 32 {
+		t.Errorf("got macros %v, want 5 pressed twice on f1", m.Macros)
+	}
+
+	marshalled, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := metadata.Parse(bytes.NewReader(marshalled))
+	if err != nil {
+		t.Fatalf("%v, parsing:\n%s", err, marshalled)
+	}
+	if again.Name() != m.Name() || again.Speed != m.Speed || *again.Quirks != *m.Quirks || again.Keys["down"] != 0xc || again.Keys2["k"] != 0xc || again.Controls[0] != m.Controls[0] || again.Macros["f1"].End() != 32 {
+		t.Errorf("marshalled and parsed again, got %+v, want %+v", again, m)
+	}
+
+	none, err := metadata.Parse(strings.NewReader(`{"quirks": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none.Quirks == nil || *none.Quirks != (cpu.Quirks{}) {
+		t.Errorf("\"quirks\": \"\" should turn them all off, got %v", none.Quirks)
+	}
+
+	for _, bad := range []string{`{"sped": 700}`, `{"speed": -1}`, `{"quirks": "wobbly"}`, `{"font": "comic sans"}`, `{"keys": {"up": "g"}}`, `{"keys2": {"i": "10"}}`, `{"macros": {"f1": ["soon 5 2"]}}`, `{"title": `} {
+		if _, err := metadata.Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("%s parsed", bad)
+		}
+	}
+
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "pong.ch8")
+	if m, err := metadata.Read(rom); m != nil || err != nil {
+		t.Errorf("got %v, %v for a ROM without metadata", m, err)
+	}
+	if err := os.WriteFile(metadata.Path(rom), []byte(`{"title": "Pong"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := metadata.Read(rom); err != nil || m.Name() != "Pong" {
+		t.Errorf("got %v, %v, want Pong", m, err)
+	}
+	if err := metadata.Write(rom, &metadata.Metadata{Title: "Pong", Author: "me"}); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := metadata.Read(rom); err != nil || m.Name() != "Pong by me" {
+		t.Errorf("got %v, %v after writing it, want Pong by me", m, err)
+	}
+}
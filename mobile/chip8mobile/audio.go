@@ -0,0 +1,61 @@
+//go:build android || ios
+// +build android ios
+
+package main
+
+import (
+	"log"
+
+	"golang.org/x/mobile/exp/audio/al"
+)
+
+const (
+	// beepRate is the beep's sample rate, and beepPitch its pitch, in Hz.
+	beepRate  = 22050
+	beepPitch = 440
+	// beepSeconds is how long the beep lasts at most. Chip8 games beep for a few
+	// frames at a time, and OpenAL won't loop a buffer without being asked nicely.
+	beepSeconds = 2
+)
+
+// beeper is a speaker that plays a square wave through OpenAL.
+type beeper struct {
+	source al.Source
+	ok     bool
+}
+
+// newBeeper returns a beeper. If there's no sound to be had, it returns one that
+// doesn't make any: a game's still a game without it.
+func newBeeper() *beeper {
+	if err := al.OpenDevice(); err != nil {
+		log.Printf("no sound: %v", err)
+		return &beeper{}
+	}
+	samples := make([]byte, 2*beepRate*beepSeconds)
+	for i := 0; i < len(samples)/2; i++ {
+		// 16-bit little-endian samples, a quarter of the way up and down.
+		sample := int16(8192)
+		if (i*2*beepPitch/beepRate)%2 == 1 {
+			sample = -8192
+		}
+		samples[2*i], samples[2*i+1] = byte(sample), byte(uint16(sample)>>8)
+	}
+	buffer := al.GenBuffers(1)[0]
+	buffer.BufferData(al.FormatMono16, samples, beepRate)
+	source := al.GenSources(1)[0]
+	source.QueueBuffers(buffer)
+	return &beeper{source: source, ok: true}
+}
+
+func (b *beeper) StartSound() {
+	if b.ok {
+		al.RewindSources(b.source)
+		al.PlaySources(b.source)
+	}
+}
+
+func (b *beeper) StopSound() {
+	if b.ok {
+		al.StopSources(b.source)
+	}
+}
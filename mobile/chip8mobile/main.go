@@ -0,0 +1,181 @@
+//go:build android || ios
+// +build android ios
+
+// Chip8mobile is the emulator as a phone app, built with gomobile (see the mobile
+// package for how). It plays the ROM in assets/rom.ch8, with the screen and a touch
+// keypad laid out to fit however the phone's held, and saves the game whenever the
+// app goes into the background.
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mobile/app"
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/event/lifecycle"
+	"golang.org/x/mobile/event/paint"
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/event/touch"
+	"golang.org/x/mobile/exp/gl/glutil"
+	"golang.org/x/mobile/geom"
+	"golang.org/x/mobile/gl"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/mobile"
+	"github.com/mpingram/chip8/softrender"
+	"github.com/mpingram/chip8/storage"
+)
+
+// keypadResolution is how many pixels across the keypad's image is. It's drawn
+// scaled to fit, and doesn't need to be sharp, just quick to upload.
+const keypadResolution = 256
+
+func main() {
+	rom, err := readROM()
+	if err != nil {
+		log.Fatal(err)
+	}
+	keypad := control.NewKeypad(nil)
+	speaker := newBeeper()
+	c8 := cpu.NewChip8(keypad, speaker)
+	c8.SetLogLevel(cpu.LogNone)
+	session, err := mobile.NewSession(c8, openStore(), rom)
+	if err != nil {
+		log.Fatal(err)
+	}
+	touches := mobile.NewTouches(keypad, mobile.Layout{})
+
+	app.Main(func(a app.App) {
+		var (
+			glctx    gl.Context
+			sz       size.Event
+			d        *display
+			lastHeld = -1
+		)
+		for e := range a.Events() {
+			switch e := a.Filter(e).(type) {
+			case lifecycle.Event:
+				switch e.Crosses(lifecycle.StageVisible) {
+				case lifecycle.CrossOn:
+					glctx, _ = e.DrawContext.(gl.Context)
+					d = newDisplay(glctx)
+					lastHeld = -1
+					session.Foreground()
+					a.Send(paint.Event{})
+				case lifecycle.CrossOff:
+					if err := session.Background(); err != nil {
+						log.Printf("saving the game: %v", err)
+					}
+					d.release()
+					d, glctx = nil, nil
+				}
+			case size.Event:
+				sz = e
+				touches.SetLayout(mobile.NewLayout(float32(sz.WidthPx), float32(sz.HeightPx)))
+			case touch.Event:
+				switch e.Type {
+				case touch.TypeBegin, touch.TypeMove:
+					touches.Down(int64(e.Sequence), e.X, e.Y)
+				case touch.TypeEnd:
+					touches.Up(int64(e.Sequence))
+				}
+			case paint.Event:
+				if glctx == nil || e.External {
+					continue
+				}
+				select {
+				case <-c8.FrameReady():
+					d.screen(c8.ColorFrame())
+				default:
+				}
+				if held := int(touches.Held()); held != lastHeld {
+					d.keypad(uint16(held))
+					lastHeld = held
+				}
+				d.draw(glctx, sz, touches.Layout())
+				a.Publish()
+				// ask to be painted again right away: that's what drives the frames.
+				a.Send(paint.Event{})
+			}
+		}
+	})
+}
+
+// readROM reads the ROM the app plays, which gomobile packs into the app from the
+// assets folder.
+func readROM() ([]byte, error) {
+	f, err := asset.Open("rom.ch8")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// openStore returns where to keep the game. Android has no home directory, so
+// there's no usual place there, but gomobile points TMPDIR at the app's own cache
+// folder, which is the next best thing.
+func openStore() storage.Storage {
+	if root, err := storage.DefaultRoot("chip8"); err == nil {
+		return storage.NewDir(root)
+	}
+	return storage.NewDir(filepath.Join(os.TempDir(), "chip8"))
+}
+
+// display draws the screen and the keypad with OpenGL ES, each as an image that's
+// uploaded when it changes and stretched to where the layout says it goes.
+type display struct {
+	images        *glutil.Images
+	screenImage   *glutil.Image
+	keypadImage   *glutil.Image
+	renderer      *softrender.Renderer
+	keypadPicture *image.RGBA
+}
+
+func newDisplay(glctx gl.Context) *display {
+	images := glutil.NewImages(glctx)
+	return &display{
+		images:      images,
+		screenImage: images.NewImage(cpu.FrameWidth, cpu.FrameHeight),
+		keypadImage: images.NewImage(keypadResolution, keypadResolution),
+		renderer:    softrender.NewRenderer(softrender.DefaultPalette, 1),
+	}
+}
+
+// screen uploads a new frame of the Chip8's screen.
+func (d *display) screen(frame cpu.ColorFrame) {
+	copy(d.screenImage.RGBA.Pix, d.renderer.Render(frame).Pix)
+	d.screenImage.Upload()
+}
+
+// keypad uploads a new picture of the keypad, with the keys in held lit up.
+func (d *display) keypad(held uint16) {
+	mobile.DrawKeypad(d.keypadImage.RGBA, held, cpu.DefaultFont)
+	d.keypadImage.Upload()
+}
+
+func (d *display) draw(glctx gl.Context, sz size.Event, layout mobile.Layout) {
+	glctx.ClearColor(0, 0, 0, 1)
+	glctx.Clear(gl.COLOR_BUFFER_BIT)
+	drawAt(d.screenImage, sz, layout.Screen)
+	drawAt(d.keypadImage, sz, layout.Keypad)
+}
+
+func (d *display) release() {
+	d.screenImage.Release()
+	d.keypadImage.Release()
+	d.images.Release()
+}
+
+// drawAt draws img stretched over r, which is in pixels; glutil wants points.
+func drawAt(img *glutil.Image, sz size.Event, r mobile.Rect) {
+	pt := func(x, y float32) geom.Point {
+		return geom.Point{X: geom.Pt(x / sz.PixelsPerPt), Y: geom.Pt(y / sz.PixelsPerPt)}
+	}
+	img.Draw(sz, pt(r.X, r.Y), pt(r.X+r.W, r.Y), pt(r.X, r.Y+r.H), img.RGBA.Bounds())
+}
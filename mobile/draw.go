@@ -0,0 +1,69 @@
+package mobile
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// The keypad's colors: dark keys on black, lighting up when they're held, with their
+// digits written on them in the Chip8's own font.
+var (
+	keypadBackground = color.RGBA{0, 0, 0, 0xff}
+	keyUp            = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	keyDown          = color.RGBA{0x90, 0x20, 0x20, 0xff}
+	keyDigit         = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// DrawKeypad draws the keypad into img, filling it, with the keys in held (a bit for
+// each, as Touches.Held returns them) lit up and digits drawn in font. It draws in
+// the same places NewLayout puts the keys, scaled to fit img, so img can be drawn
+// over Layout.Keypad at any size.
+func DrawKeypad(img *image.RGBA, held uint16, font cpu.Font) {
+	bounds := img.Bounds()
+	fill(img, bounds, keypadBackground)
+	cell := bounds.Dx() / 4
+	if cell < 1 {
+		return
+	}
+	gap := int(float32(cell) * keyGap / 2)
+	for row, keys := range keypadRows {
+		for col, key := range keys {
+			r := image.Rect(col*cell+gap, row*cell+gap, (col+1)*cell-gap, (row+1)*cell-gap).Add(bounds.Min)
+			face := keyUp
+			if held&(1<<uint(key)) != 0 {
+				face = keyDown
+			}
+			fill(img, r, face)
+			drawGlyph(img, r, font.Glyph(byte(key)))
+		}
+	}
+}
+
+// drawGlyph draws a small font sprite (four pixels wide, five tall) in the middle of r,
+// as big as it fits with room to spare.
+func drawGlyph(img *image.RGBA, r image.Rectangle, glyph []byte) {
+	pixel := r.Dy() / 2 / len(glyph)
+	if pixel < 1 {
+		return
+	}
+	left := r.Min.X + (r.Dx()-4*pixel)/2
+	top := r.Min.Y + (r.Dy()-len(glyph)*pixel)/2
+	for y, row := range glyph {
+		for x := 0; x < 4; x++ {
+			if row&(0x80>>uint(x)) != 0 {
+				fill(img, image.Rect(left+x*pixel, top+y*pixel, left+(x+1)*pixel, top+(y+1)*pixel), keyDigit)
+			}
+		}
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package mobile_test
+
+import (
+	"fmt"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/mobile"
+	"github.com/mpingram/chip8/storage"
+)
+
+// keypad remembers what was pressed and released on it, in order.
+type keypad []string
+
+func (k *keypad) Press(key cpu.KeyCode)   { *k = append(*k, fmt.Sprintf("press %X", key)) }
+func (k *keypad) Release(key cpu.KeyCode) { *k = append(*k, fmt.Sprintf("release %X", key)) }
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// NewLayout / Touches
+// should put the keypad under the screen held upright, and beside it held sideways
+// should press a key for each finger, move keys with sliding fingers, and only let
+// go of a key once every finger on it has
+func TestTouches(t *testing.T) {
+	upright := mobile.NewLayout(400, 800)
+	if upright.Screen != (mobile.Rect{0, 0, 400, 200}) {
+		t.Errorf("upright, the screen is at %+v", upright.Screen)
+	}
+	if upright.Keypad.Y < upright.Screen.H || upright.Keypad.W != 400 {
+		t.Errorf("upright, the keypad is at %+v", upright.Keypad)
+	}
+	sideways := mobile.NewLayout(800, 400)
+	if sideways.Keypad.X < sideways.Screen.X+sideways.Screen.W {
+		t.Errorf("sideways, the keypad at %+v overlaps the screen at %+v", sideways.Keypad, sideways.Screen)
+	}
+
+	// upright, the keys are 100 pixels square, starting 300 down, in the middle of
+	// what the screen leaves: 1 2 3 C on top.
+	var keys keypad
+	touches := mobile.NewTouches(&keys, upright)
+	touches.Down(1, 50, 350)  // 1
+	touches.Down(2, 150, 350) // 2
+	touches.Down(2, 160, 360) // still 2
+	touches.Down(2, 150, 450) // slides down to 5
+	touches.Down(3, 140, 440) // 5 again
+	touches.Up(2)
+	if held := touches.Held(); held != 1<<1|1<<5 {
+		t.Errorf("held keys are %016b, want 1 and 5", held)
+	}
+	touches.Up(3)
+	touches.Down(1, 50, 100) // slides off the keypad
+	touches.Up(1)
+	want := []string{"press 1", "press 2", "release 2", "press 5", "release 5", "release 1"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("got %v on the keypad, want %v", keys, want)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	mobile.DrawKeypad(img, 1<<1, cpu.DefaultFont)
+	if img.RGBAAt(5, 5) == img.RGBAAt(30, 5) {
+		t.Errorf("key 1 is held, but looks the same as key 2")
+	}
+}
+
+// Session
+// should save the game when the app goes into the background
+// should pick up from the save next time
+func TestSession(t *testing.T) {
+	rom, err := asm.Assemble(`
+	loop:
+		ADD V0,1
+		JP loop
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemory()
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetTurbo(true)
+	session, err := mobile.NewSession(c8, store, rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// going into the background before ever starting is nothing to save.
+	if err := session.Background(); err != nil {
+		t.Fatal(err)
+	}
+	session.Foreground()
+	time.Sleep(10 * time.Millisecond)
+	if err := session.Background(); err != nil {
+		t.Fatal(err)
+	}
+	if c8.IsRunning() {
+		t.Errorf("the Chip8 is still running in the background")
+	}
+	saved := c8.Snapshot().V[0]
+	if saved == 0 {
+		t.Fatalf("the game didn't get anywhere")
+	}
+
+	again := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	if _, err := mobile.NewSession(again, store, rom); err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Snapshot().V[0]; got != saved {
+		t.Errorf("the new session's V0 is %d, want %d from the save", got, saved)
+	}
+}
@@ -0,0 +1,103 @@
+package mobile
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+// A Session is a game on a phone, which has to get out of the way whenever the
+// phone wants it to: a call comes in, the player switches apps, the screen turns
+// off. Phones don't ask before killing an app in the background either, so a
+// Session saves the game every time it goes there, and picks up from the save when
+// it starts again.
+type Session struct {
+	c8    *cpu.Chip8
+	store storage.Storage
+	rom   []byte
+
+	mu sync.Mutex
+	// stopped is closed when the Chip8 stops running after Foreground, or nil
+	// between Background and the next Foreground.
+	stopped chan struct{}
+}
+
+// NewSession loads rom into c8, and if there's a save for it in store from the last
+// time the app went into the background, restores that. The game doesn't start
+// until Foreground is called.
+func NewSession(c8 *cpu.Chip8, store storage.Storage, rom []byte) (*Session, error) {
+	if err := c8.Load(rom); err != nil {
+		return nil, err
+	}
+	s := &Session{c8: c8, store: store, rom: rom}
+	saved, err := store.Get(s.saveKey())
+	switch err {
+	case nil:
+		if err := c8.LoadState(bytes.NewReader(saved)); err != nil {
+			return nil, err
+		}
+	case storage.ErrNotFound:
+	default:
+		return nil, err
+	}
+	return s, nil
+}
+
+// saveKey is where the game is saved. It's where the windowed emulator keeps its
+// autosaves, keyed by a hash of the ROM, so the two could share them.
+func (s *Session) saveKey() string {
+	sum := sha1.Sum(s.rom)
+	return "saves/autosave/" + hex.EncodeToString(sum[:]) + ".state"
+}
+
+// Foreground starts the game, or carries on with it, now that the app can be seen.
+// It doesn't wait for the game to stop; the Chip8 runs in a goroutine of its own.
+func (s *Session) Foreground() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped != nil {
+		return
+	}
+	stopped := make(chan struct{})
+	s.stopped = stopped
+	go func() {
+		s.c8.Resume()
+		close(stopped)
+	}()
+}
+
+// Background stops the game and saves it, now that the app can't be seen, and might
+// be killed without warning. It returns once it's saved.
+func (s *Session) Background() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped == nil {
+		return nil
+	}
+	// the Chip8 mightn't have started running yet, in which case halting it does
+	// nothing, so keep at it until it's stopped.
+	for stopped := false; !stopped; {
+		s.c8.Halt()
+		select {
+		case <-s.stopped:
+			stopped = true
+		case <-time.After(time.Millisecond):
+		}
+	}
+	s.stopped = nil
+	var state bytes.Buffer
+	if err := s.c8.SaveState(&state); err != nil {
+		return err
+	}
+	return s.store.Put(s.saveKey(), state.Bytes())
+}
+
+// Chip8 returns the Chip8 the game is playing on.
+func (s *Session) Chip8() *cpu.Chip8 {
+	return s.c8
+}
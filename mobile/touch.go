@@ -0,0 +1,185 @@
+// Package mobile is the emulator on a phone: the touch keypad, the screen laid out
+// next to it, and a Session that pauses and saves the game when the app goes into the
+// background and picks it up again when it comes back. It's all plain Go, with no
+// mobile libraries in it, so it can be built and tested anywhere; the app that uses
+// it, in chip8mobile, is built with gomobile:
+//
+//	go get golang.org/x/mobile/cmd/gomobile
+//	gomobile init
+//	cp "roms/Pong (1 player).ch8" mobile/chip8mobile/assets/rom.ch8
+//	gomobile build -target=android ./mobile/chip8mobile
+//
+// (or -target=ios, on a Mac with Xcode). The ROM the app plays is the one in assets.
+package mobile
+
+import (
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Rect is a rectangle on the phone's screen, in pixels from the top-left.
+type Rect struct {
+	X, Y, W, H float32
+}
+
+// Contains returns true if x, y is inside the rectangle.
+func (r Rect) Contains(x, y float32) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// keypadRows are the keys of the keypad the way the COSMAC VIP laid them out, which
+// is the way games that show you the keys expect them to be.
+var keypadRows = [4][4]cpu.KeyCode{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// A Layout is where things go on the phone's screen: the Chip8's screen, the keypad
+// as a whole, and each of its keys.
+type Layout struct {
+	Screen Rect
+	Keypad Rect
+	Keys   [16]Rect
+}
+
+// keyGap is the space between keys, as a fraction of a key.
+const keyGap = 0.1
+
+// NewLayout lays out a phone screen width by height pixels. Held upright, the
+// Chip8's screen goes across the top and the keypad underneath it; held sideways,
+// the screen goes on the left and the keypad on the right. Either way the keypad is
+// as big as it can be, for thumbs.
+func NewLayout(width, height float32) Layout {
+	var l Layout
+	if height >= width {
+		l.Screen = Rect{0, 0, width, width / 2}
+		side := min32(width, height-l.Screen.H)
+		l.Keypad = Rect{(width - side) / 2, l.Screen.H + (height-l.Screen.H-side)/2, side, side}
+	} else {
+		side := min32(height, width/3)
+		screenWidth := min32(width-side, 2*height)
+		l.Screen = Rect{0, (height - screenWidth/2) / 2, screenWidth, screenWidth / 2}
+		l.Keypad = Rect{width - side, (height - side) / 2, side, side}
+	}
+	cell := l.Keypad.W / 4
+	gap := cell * keyGap
+	for row, keys := range keypadRows {
+		for col, key := range keys {
+			l.Keys[key] = Rect{l.Keypad.X + float32(col)*cell + gap/2, l.Keypad.Y + float32(row)*cell + gap/2, cell - gap, cell - gap}
+		}
+	}
+	return l
+}
+
+// KeyAt returns the key at x, y, if there's one there.
+func (l Layout) KeyAt(x, y float32) (cpu.KeyCode, bool) {
+	for key, r := range l.Keys {
+		if r.Contains(x, y) {
+			return cpu.KeyCode(key), true
+		}
+	}
+	return cpu.KeyNone, false
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// A Keypad is somewhere to press keys, like a control.Keypad.
+type Keypad interface {
+	Press(key cpu.KeyCode)
+	Release(key cpu.KeyCode)
+}
+
+// Touches turns fingers on the keypad into keys pressed on a Keypad. Every finger
+// is tracked on its own, so two can hold two keys at once, a finger sliding from one
+// key to the next lets go of the first and presses the second, and a key two fingers
+// are on stays down until they've both let go.
+type Touches struct {
+	keypad Keypad
+	mu     sync.Mutex
+	layout Layout
+	// fingers are the key each finger is on, by the finger's touch sequence.
+	fingers map[int64]cpu.KeyCode
+	// held is how many fingers are on each key.
+	held [16]int
+}
+
+// NewTouches returns Touches that press keys on keypad, laid out by layout.
+func NewTouches(keypad Keypad, layout Layout) *Touches {
+	return &Touches{keypad: keypad, layout: layout, fingers: make(map[int64]cpu.KeyCode)}
+}
+
+// SetLayout changes where the keys are, when the phone's turned around.
+func (t *Touches) SetLayout(layout Layout) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.layout = layout
+}
+
+// Layout returns where the keys are.
+func (t *Touches) Layout() Layout {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.layout
+}
+
+// Down is a finger, numbered finger, touching the screen at x, y, or moving to x, y.
+func (t *Touches) Down(finger int64, x, y float32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key, onKey := t.layout.KeyAt(x, y)
+	if old, ok := t.fingers[finger]; ok {
+		if onKey && old == key {
+			return
+		}
+		t.lift(finger)
+	}
+	if !onKey {
+		return
+	}
+	t.fingers[finger] = key
+	t.held[key]++
+	if t.held[key] == 1 {
+		t.keypad.Press(key)
+	}
+}
+
+// Up is a finger leaving the screen.
+func (t *Touches) Up(finger int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lift(finger)
+}
+
+// Held returns the keys held down, a bit for each, key 0 being bit 0.
+func (t *Touches) Held() uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var held uint16
+	for key, fingers := range t.held {
+		if fingers > 0 {
+			held |= 1 << uint(key)
+		}
+	}
+	return held
+}
+
+// lift takes finger off whatever key it was on. t.mu must be held.
+func (t *Touches) lift(finger int64) {
+	key, ok := t.fingers[finger]
+	if !ok {
+		return
+	}
+	delete(t.fingers, finger)
+	t.held[key]--
+	if t.held[key] == 0 {
+		t.keypad.Release(key)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"net"
+
+	"github.com/mpingram/chip8/netplay"
+)
+
+// connectNetplay hosts a netplay game at hostAddr or joins one at joinAddr,
+// whichever was given, and returns nil if neither was. The host plays by settings;
+// the other player plays by the host's.
+func connectNetplay(hostAddr, joinAddr string, rom []byte, settings netplay.Settings) (*netplay.Session, error) {
+	switch {
+	case hostAddr != "":
+		listener, err := net.Listen("tcp", hostAddr)
+		if err != nil {
+			return nil, err
+		}
+		// one game, one opponent: stop listening once they're here.
+		defer listener.Close()
+		log.Printf("waiting for the other player to join at %s...", listener.Addr())
+		return netplay.Host(listener, rom, settings, netplay.DefaultDelay)
+	case joinAddr != "":
+		log.Printf("joining the game at %s...", joinAddr)
+		return netplay.Join(joinAddr, rom)
+	}
+	return nil, nil
+}
@@ -0,0 +1,387 @@
+// Package netplay lets two people play the same Chip8 game over the network.
+//
+// It's lockstep netplay, the simplest kind there is: both players run the whole game,
+// and before each frame they swap the keys they're holding. Since a Chip8 given the
+// same program, the same random seed, the same settings and the same keys does
+// exactly the same thing, the two games never drift apart -- once the host has
+// told the other player how to set their Chip8 up, nothing but keys ever needs to
+// cross the network. Anything else that can change the game on one side only, like
+// a patch loaded over the ROM, a real-time clock or saved RPL flags, has to stay
+// out of netplay.
+//
+// To hide the round trip, each player's keys are sent a few frames before they're
+// used (the input delay), so the other side's keys have usually arrived by the time
+// they're needed. If they haven't, the game waits for them.
+package netplay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// DefaultDelay is the input delay, in frames. Three frames is 50ms, which covers
+// a round trip to most places you'd want to play with someone.
+const DefaultDelay = 3
+
+// handshakeMagic starts the handshake, so we don't try to play Pong with a web server.
+const handshakeMagic = "C8NP"
+
+// protocolVersion is bumped whenever the wire format changes.
+const protocolVersion = 2
+
+// ErrDifferentROM is returned when the two players aren't running the same ROM.
+var ErrDifferentROM = errors.New("netplay: the other player has a different ROM loaded")
+
+// ErrUnsupportedSettings is returned when the other player can't set their Chip8
+// up the way the host's is, because they don't know the font or a quirk.
+var ErrUnsupportedSettings = errors.New("netplay: the other player's Chip8 can't be set up like the host's")
+
+// Settings are everything about the Chip8 that changes how a game plays, which
+// the host decides for both players.
+type Settings struct {
+	Speed      int
+	Quirks     cpu.Quirks
+	Font       cpu.Font
+	MemorySize int
+	Protection cpu.WriteProtection
+}
+
+// Options returns the options that give a Chip8 these settings, for cpu.NewChip8.
+// The speed isn't one of them; use SetSpeed.
+func (s Settings) Options() []cpu.Option {
+	return []cpu.Option{cpu.WithQuirks(s.Quirks), cpu.WithFont(s.Font), cpu.WithMemorySize(s.MemorySize), cpu.ProtectMemory(s.Protection)}
+}
+
+// Session is a connection to the other player.
+type Session struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	seed     int64
+	settings Settings
+	delay    int
+
+	// frame is the next frame to run.
+	frame uint64
+	// sent is the keys we've sent for the frames we haven't run yet,
+	// in a ring indexed by frame number.
+	sent []uint16
+}
+
+// handshake is what the host sends when the other player connects:
+// the game's settings, which the other player adopts.
+type handshake struct {
+	Magic   [4]byte
+	Version uint16
+	ROMHash [sha1.Size]byte
+	Seed    int64
+	Speed   int32
+	Delay   uint16
+	// Quirks is Quirks.Bits.
+	Quirks uint32
+	// Font is the font's name, padded with zeroes.
+	Font       [16]byte
+	MemorySize uint32
+	// Protection is the write protection, as protectionInterpreter, protectionProgram
+	// and protectionStop bits.
+	Protection uint8
+}
+
+// The bits of handshake.Protection.
+const (
+	protectionInterpreter = 1 << iota
+	protectionProgram
+	protectionStop
+)
+
+// answer is what the other player sends back: their ROM hash, so we both know
+// whether it matches, and whether they could set their Chip8 up like ours.
+type answer struct {
+	ROMHash [sha1.Size]byte
+	OK      bool
+}
+
+// Host waits for the other player to connect on listener, and tells them the
+// random seed, settings and input delay to play with. Both players must be running rom.
+func Host(listener net.Listener, rom []byte, settings Settings, delay int) (*Session, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	h := handshake{
+		Version:    protocolVersion,
+		ROMHash:    sha1.Sum(rom),
+		Seed:       rand.Int63(),
+		Speed:      int32(settings.Speed),
+		Delay:      uint16(delay),
+		Quirks:     settings.Quirks.Bits(),
+		MemorySize: uint32(settings.MemorySize),
+	}
+	copy(h.Magic[:], handshakeMagic)
+	copy(h.Font[:], settings.Font.Name)
+	if settings.Protection.Interpreter {
+		h.Protection |= protectionInterpreter
+	}
+	if settings.Protection.Program {
+		h.Protection |= protectionProgram
+	}
+	if settings.Protection.Stop {
+		h.Protection |= protectionStop
+	}
+	if err := binary.Write(conn, binary.BigEndian, h); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var theirs answer
+	if err := binary.Read(conn, binary.BigEndian, &theirs); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("netplay: handshake: %v", err)
+	}
+	if theirs.ROMHash != h.ROMHash {
+		conn.Close()
+		return nil, ErrDifferentROM
+	}
+	if !theirs.OK {
+		conn.Close()
+		return nil, ErrUnsupportedSettings
+	}
+	return newSession(conn, h, settings), nil
+}
+
+// Join connects to a player hosting a game at addr. Both players must be running rom.
+func Join(addr string, rom []byte) (*Session, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var h handshake
+	if err := binary.Read(conn, binary.BigEndian, &h); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("netplay: handshake: %v", err)
+	}
+	if string(h.Magic[:]) != handshakeMagic {
+		conn.Close()
+		return nil, fmt.Errorf("netplay: %s isn't hosting a game", addr)
+	}
+	if h.Version != protocolVersion {
+		conn.Close()
+		return nil, fmt.Errorf("netplay: the host speaks version %d of the protocol; we speak %d", h.Version, protocolVersion)
+	}
+	// we play by the host's settings, or not at all.
+	settings, settingsErr := h.settings()
+	ours := answer{ROMHash: sha1.Sum(rom), OK: settingsErr == nil}
+	if err := binary.Write(conn, binary.BigEndian, ours); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ours.ROMHash != h.ROMHash {
+		conn.Close()
+		return nil, ErrDifferentROM
+	}
+	if settingsErr != nil {
+		conn.Close()
+		return nil, settingsErr
+	}
+	return newSession(conn, h, settings), nil
+}
+
+// settings returns the settings the host sent, or an error if we can't play by them.
+func (h handshake) settings() (Settings, error) {
+	quirks := cpu.QuirksFromBits(h.Quirks)
+	if quirks.Bits() != h.Quirks {
+		return Settings{}, fmt.Errorf("%v: the host uses quirks we don't know", ErrUnsupportedSettings)
+	}
+	font, err := cpu.FontByName(string(bytes.TrimRight(h.Font[:], "\x00")))
+	if err != nil {
+		return Settings{}, fmt.Errorf("%v: %v", ErrUnsupportedSettings, err)
+	}
+	size := int(h.MemorySize)
+	if size != cpu.MemorySize4K && size != cpu.MemorySize64K {
+		return Settings{}, fmt.Errorf("%v: the host's Chip8 has %d bytes of memory", ErrUnsupportedSettings, size)
+	}
+	return Settings{
+		Speed:      int(h.Speed),
+		Quirks:     quirks,
+		Font:       font,
+		MemorySize: size,
+		Protection: cpu.WriteProtection{
+			Interpreter: h.Protection&protectionInterpreter != 0,
+			Program:     h.Protection&protectionProgram != 0,
+			Stop:        h.Protection&protectionStop != 0,
+		},
+	}, nil
+}
+
+func newSession(conn net.Conn, h handshake, settings Settings) *Session {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// a frame's keys are ten bytes; don't let Nagle sit on them.
+		tcp.SetNoDelay(true)
+	}
+	return &Session{conn: conn, r: bufio.NewReader(conn), seed: h.Seed, settings: settings, delay: int(h.Delay)}
+}
+
+// Seed is the random seed both players use.
+func (s *Session) Seed() int64 { return s.seed }
+
+// Speed is the speed both players run at, in instructions per second.
+func (s *Session) Speed() int { return s.settings.Speed }
+
+// Settings are the settings both players' Chip8s have: the host's.
+func (s *Session) Settings() Settings { return s.settings }
+
+// Close hangs up on the other player.
+func (s *Session) Close() error { return s.conn.Close() }
+
+// sendKeys tells the other player which keys we'll be holding on frame.
+func (s *Session) sendKeys(frame uint64, keys uint16) error {
+	var msg [10]byte
+	binary.BigEndian.PutUint64(msg[:], frame)
+	binary.BigEndian.PutUint16(msg[8:], keys)
+	_, err := s.conn.Write(msg[:])
+	return err
+}
+
+// receiveKeys waits for the other player's keys for frame. Messages arrive in
+// order, one per frame, so the next one is always the one we want.
+func (s *Session) receiveKeys(frame uint64) (uint16, error) {
+	var msg [10]byte
+	if _, err := io.ReadFull(s.r, msg[:]); err != nil {
+		return 0, err
+	}
+	if got := binary.BigEndian.Uint64(msg[:]); got != frame {
+		return 0, fmt.Errorf("netplay: out of step: expected keys for frame %d, got frame %d", frame, got)
+	}
+	return binary.BigEndian.Uint16(msg[8:]), nil
+}
+
+// Keyboard is the keyboard the Chip8 sees during netplay: both players' keys at once.
+// It's a cpu.KeyEventSource, so the Chip8 keeps track of every key that's down
+// rather than polling for just one, and each player's SKPs see their own keys
+// whatever the other's holding. Give it to cpu.NewChip8.
+type Keyboard struct {
+	// held is a bitmask of the keys down this frame, bit n for key n.
+	// Only touch it through sync/atomic.
+	held uint32
+	// events gets a press or a release for every key that changes from one frame
+	// to the next. There can't be more than 16 of those, and Advance steps the
+	// Chip8, which takes them all, before it sets the next frame's keys, so there's
+	// always room; if nothing's reading them, though, the oldest make way rather
+	// than hold the game up.
+	events chan cpu.KeyEvent
+}
+
+// NewKeyboard returns a Keyboard with nothing held down.
+func NewKeyboard() *Keyboard {
+	return &Keyboard{events: make(chan cpu.KeyEvent, 16)}
+}
+
+// KeyEvents returns the keys pressed and released, by either player.
+func (k *Keyboard) KeyEvents() <-chan cpu.KeyEvent {
+	return k.events
+}
+
+// Poll returns the lowest key either player is holding down.
+func (k *Keyboard) Poll() cpu.KeyCode {
+	held := atomic.LoadUint32(&k.held)
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if held&(1<<key) != 0 {
+			return key
+		}
+	}
+	return cpu.KeyNone
+}
+
+// set makes keys the keys held down, pressing and releasing whichever have changed.
+func (k *Keyboard) set(keys uint16) {
+	old := atomic.SwapUint32(&k.held, uint32(keys))
+	changed := old ^ uint32(keys)
+	now := time.Now()
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if changed&(1<<key) != 0 {
+			k.send(cpu.KeyEvent{Code: key, Pressed: keys&(1<<key) != 0, Timestamp: now})
+		}
+	}
+}
+
+// send sends an event without waiting, throwing the oldest away if it has to.
+func (k *Keyboard) send(event cpu.KeyEvent) {
+	for {
+		select {
+		case k.events <- event:
+			return
+		default:
+		}
+		select {
+		case <-k.events:
+		default:
+		}
+	}
+}
+
+// Run plays the game in lockstep with the other player, a frame every sixtieth of a
+// second, until stop is closed or the connection drops. local returns the keys
+// the local player is holding, as a bitmask. See Advance for how to set up c8.
+//
+// Run runs the Chip8 itself, a frame at a time, so don't call Resume.
+func (s *Session) Run(c8 *cpu.Chip8, keyboard *Keyboard, local func() uint16, stop <-chan struct{}) error {
+	tick := time.NewTicker(time.Second / cpu.FramesPerSecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-tick.C:
+		}
+		if err := s.Advance(c8, keyboard, local()); err != nil {
+			return err
+		}
+	}
+}
+
+// Advance runs one frame of the game: it sends the other player the keys we're
+// holding (mine, as a bitmask), waits for theirs, and runs c8 for a frame with
+// both sets held down. c8 must have been created with keyboard and the session's
+// Settings, seeded with the session's seed and loaded with the ROM, and must not
+// be running.
+func (s *Session) Advance(c8 *cpu.Chip8, keyboard *Keyboard, mine uint16) error {
+	if s.sent == nil {
+		// nobody pressed anything during the first few frames; that's
+		// how the input delay gets started.
+		s.sent = make([]uint16, s.delay+1)
+		for frame := 0; frame < s.delay; frame++ {
+			if err := s.sendKeys(uint64(frame), 0); err != nil {
+				return err
+			}
+		}
+	}
+	// what we press now, the other player will see delay frames from now,
+	// so that's when we have to use it too.
+	later := s.frame + uint64(s.delay)
+	if err := s.sendKeys(later, mine); err != nil {
+		return err
+	}
+	s.sent[later%uint64(len(s.sent))] = mine
+	theirs, err := s.receiveKeys(s.frame)
+	if err != nil {
+		return err
+	}
+	keyboard.set(s.sent[s.frame%uint64(len(s.sent))] | theirs)
+
+	// FrameStep stops short if the program's finished or gone wrong, but the
+	// frame still has to end for the two players to stay in step.
+	for start := c8.FrameCount(); c8.FrameCount() == start; {
+		c8.FrameStep()
+	}
+	s.frame++
+	return nil
+}
@@ -0,0 +1,165 @@
+package netplay_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/netplay"
+)
+
+type player struct {
+	session  *netplay.Session
+	keyboard *netplay.Keyboard
+	c8       *cpu.Chip8
+	err      error
+}
+
+// connect hosts a game of rom, joins it, and returns the two players.
+func connect(t *testing.T, rom []byte) (host, guest *player) {
+	return connectWith(t, rom, netplay.Settings{Speed: 600, Font: cpu.DefaultFont, MemorySize: cpu.MemorySize4K})
+}
+
+// connectWith is connect, with the host playing by settings.
+func connectWith(t *testing.T, rom []byte, settings netplay.Settings) (host, guest *player) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	newPlayer := func(session *netplay.Session, err error) *player {
+		p := &player{session: session, keyboard: netplay.NewKeyboard(), err: err}
+		if err != nil {
+			return p
+		}
+		p.c8 = cpu.NewChip8(p.keyboard, nullSpeaker{}, session.Settings().Options()...)
+		p.c8.SetLogLevel(cpu.LogNone)
+		p.c8.SetSpeed(session.Speed())
+		p.c8.SetSeed(session.Seed())
+		p.err = p.c8.Load(rom)
+		return p
+	}
+	hosted := make(chan *player)
+	go func() {
+		hosted <- newPlayer(netplay.Host(listener, rom, settings, 2))
+	}()
+	guest = newPlayer(netplay.Join(listener.Addr().String(), rom))
+	host = <-hosted
+	if host.err != nil || guest.err != nil {
+		t.Fatalf("connecting: host: %v, guest: %v", host.err, guest.err)
+	}
+	t.Cleanup(func() {
+		host.session.Close()
+		guest.session.Close()
+	})
+	return host, guest
+}
+
+// play advances both players frames frames, with the keys hostKeys and guestKeys
+// say they're holding each frame.
+func play(t *testing.T, host, guest *player, frames int, hostKeys, guestKeys func(frame int) uint16) {
+	done := make(chan error)
+	go func() {
+		for frame := 0; frame < frames; frame++ {
+			if err := host.session.Advance(host.c8, host.keyboard, hostKeys(frame)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for frame := 0; frame < frames; frame++ {
+		if err := guest.session.Advance(guest.c8, guest.keyboard, guestKeys(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	hostState, guestState := host.c8.Snapshot(), guest.c8.Snapshot()
+	if hostState.PC != guestState.PC || hostState.V != guestState.V {
+		t.Errorf("the players fell out of step:\nhost  PC=%03x V=%x\nguest PC=%03x V=%x",
+			hostState.PC, hostState.V, guestState.PC, guestState.V)
+	}
+}
+
+// Host / Join / Session.Advance
+// should keep two Chip8s in lockstep, random numbers, keys and all
+func TestLockstep(t *testing.T) {
+	rom := []byte{
+		0x62, 0x05, // LD V2 05
+		0xc0, 0xff, // RND V0 ff
+		0x81, 0x04, // ADD V1 V0
+		0xe2, 0x9e, // SKP V2
+		0x12, 0x02, // JP 202
+		0x73, 0x01, // ADD V3 01
+		0x12, 0x02, // JP 202
+	}
+	host, guest := connect(t, rom)
+	// the host holds down key 5 every other frame; the guest never touches anything.
+	play(t, host, guest, 30, func(frame int) uint16 {
+		if frame%2 == 0 {
+			return 1 << 5
+		}
+		return 0
+	}, func(int) uint16 { return 0 })
+	if host.c8.Snapshot().V[3] == 0 {
+		t.Errorf("the host's key presses never reached the game")
+	}
+}
+
+// Keyboard
+// should let the game see both players' keys at once
+func TestBothPlayersKeys(t *testing.T) {
+	rom := []byte{
+		0x62, 0x03, // LD V2 03
+		0x64, 0x05, // LD V4 05
+		0xe2, 0x9e, // SKP V2
+		0x12, 0x0a, // JP 20a
+		0x73, 0x01, // ADD V3 01
+		0xe4, 0x9e, // SKP V4
+		0x12, 0x04, // JP 204
+		0x75, 0x01, // ADD V5 01
+		0x12, 0x04, // JP 204
+	}
+	host, guest := connect(t, rom)
+	// the host holds down key 3 and the guest key 5, the whole time.
+	play(t, host, guest, 10, func(int) uint16 { return 1 << 3 }, func(int) uint16 { return 1 << 5 })
+	if v := host.c8.Snapshot().V; v[3] == 0 || v[5] == 0 {
+		t.Errorf("with both players holding a key, the host's SKP passed %d times and the guest's %d", v[3], v[5])
+	}
+}
+
+// Host / Join
+// should give the guest the host's quirks, font, memory size and write protection
+func TestGuestAdoptsSettings(t *testing.T) {
+	font, err := cpu.FontByName("vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := netplay.Settings{
+		Speed:      900,
+		Quirks:     cpu.Quirks{ShiftVy: true, IncrementI: true},
+		Font:       font,
+		MemorySize: cpu.MemorySize64K,
+		Protection: cpu.WriteProtection{Interpreter: true, Stop: true},
+	}
+	host, guest := connectWith(t, []byte{0x12, 0x00}, settings)
+	for _, p := range []*player{host, guest} {
+		if got := p.session.Settings(); got != settings {
+			t.Errorf("the session's settings are %+v, want %+v", got, settings)
+		}
+	}
+	if q, size := guest.c8.Quirks(), guest.c8.MemorySize(); q != settings.Quirks || size != settings.MemorySize {
+		t.Errorf("the guest's Chip8 has quirks %v and %d bytes of memory, want %v and %d", q, size, settings.Quirks, settings.MemorySize)
+	}
+	if p := guest.c8.MemoryProtection(); p != settings.Protection {
+		t.Errorf("the guest's Chip8 protects %v, want %v", p, settings.Protection)
+	}
+}
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
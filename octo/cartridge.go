@@ -0,0 +1,138 @@
+package octo
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Cartridge is a game the way Octo shares them: a GIF, with a picture of a
+// cartridge on the front, and the game's source and the options it wants hidden in
+// the pixels. Each pixel's color is one of the picture's colors, and which copy of
+// it -- the palette has each color sixteen times over -- is four bits of the game:
+// the low four bits of each pixel's color index, two pixels to a byte (the first
+// one the high bits), frame after frame. The first four bytes are how many more
+// there are, high byte first, and those are JSON:
+//
+//	{"program": "...the source...", "options": {"tickrate": 20, ...}}
+type Cartridge struct {
+	Program string  `json:"program"`
+	Options Options `json:"options"`
+}
+
+// ReadCartridge reads a cartridge out of a GIF.
+func ReadCartridge(r io.Reader) (*Cartridge, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("that's not a cartridge: %v", err)
+	}
+	var data []byte
+	var high byte
+	first := true
+	for _, frame := range g.Image {
+		bounds := frame.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				nibble := frame.ColorIndexAt(x, y) & 0xf
+				if first {
+					high = nibble << 4
+				} else {
+					data = append(data, high|nibble)
+				}
+				first = !first
+			}
+		}
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("that's not a cartridge: there's nothing in it")
+	}
+	size := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if size < 0 || size > len(data)-4 {
+		return nil, fmt.Errorf("that's not a cartridge: it says it's got %d bytes in it, and it's only room for %d", size, len(data)-4)
+	}
+	payload := data[4 : 4+size]
+	if !utf8.Valid(payload) {
+		return nil, fmt.Errorf("that's not a cartridge: what's in it isn't text")
+	}
+	var cart Cartridge
+	if err := json.Unmarshal(payload, &cart); err != nil {
+		return nil, fmt.Errorf("that's not a cartridge: %v", err)
+	}
+	return &cart, nil
+}
+
+// ROM compiles the cartridge's program.
+func (c *Cartridge) ROM() ([]byte, error) {
+	return Compile(c.Program)
+}
+
+// Options are the Octo settings a program was written for, the ones that matter
+// here. They're Octo's quirks, which aren't quite this Chip8's: see Quirks.
+type Options struct {
+	// TickRate is how many instructions it runs each sixtieth of a second.
+	TickRate        int    `json:"tickrate"`
+	ShiftQuirks     bool   `json:"shiftQuirks"`
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"`
+	JumpQuirks      bool   `json:"jumpQuirks"`
+	ClipQuirks      bool   `json:"clipQuirks"`
+	LogicQuirks     bool   `json:"logicQuirks"`
+	VFOrderQuirks   bool   `json:"vfOrderQuirks"`
+	FontStyle       string `json:"fontStyle"`
+	// the colors, as #rrggbb: of pixels on in the first plane, the second, both,
+	// and neither.
+	FillColor       string `json:"fillColor"`
+	FillColor2      string `json:"fillColor2"`
+	BlendColor      string `json:"blendColor"`
+	BackgroundColor string `json:"backgroundColor"`
+}
+
+// Speed returns how many instructions a second the program wants to run at, or 0
+// if it doesn't say.
+func (o Options) Speed() int {
+	return o.TickRate * cpu.FramesPerSecond
+}
+
+// Quirks returns the quirks the program wants. Octo starts out behaving like the
+// COSMAC VIP did, and its quirks are the ways the SCHIP was different -- where this
+// Chip8 starts out like the SCHIP, and its quirks are the ways the VIP was different.
+// So a program with no Octo quirks at all turns some of ours on.
+func (o Options) Quirks() cpu.Quirks {
+	return cpu.Quirks{
+		ShiftVy:    !o.ShiftQuirks,
+		IncrementI: !o.LoadStoreQuirks,
+		JumpVx:     o.JumpQuirks,
+		Clip:       o.ClipQuirks,
+		ResetVF:    o.LogicQuirks,
+		FlagFirst:  o.VFOrderQuirks,
+	}
+}
+
+// Font returns the font the program wants, or nil if it's one this Chip8 hasn't got.
+func (o Options) Font() *cpu.Font {
+	font, err := cpu.FontByName(o.FontStyle)
+	if err != nil {
+		return nil
+	}
+	return &font
+}
+
+// Colors returns the colors the program wants to be drawn in: for pixels that are
+// on in neither plane, the first, the second, and both, the way the planes are
+// numbered in a cpu.ColorFrame. ok is false if it doesn't say, or not properly.
+func (o Options) Colors() (colors [4]color.RGBA, ok bool) {
+	for i, hex := range []string{o.BackgroundColor, o.FillColor, o.FillColor2, o.BlendColor} {
+		rgb, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 24)
+		if err != nil || len(hex) != 7 || hex[0] != '#' {
+			return colors, false
+		}
+		colors[i] = color.RGBA{byte(rgb >> 16), byte(rgb >> 8), byte(rgb), 0xff}
+	}
+	return colors, true
+}
@@ -0,0 +1,571 @@
+// Package octo compiles Octo (github.com/JohnEarnest/Octo), the high-level assembly
+// language most Chip8 games of the last ten years were written in, and reads its
+// cartridges (see cartridge.go), which is how they're passed around:
+//
+//	# comments start with a hash
+//	: main                      # a label; the program starts at main
+//	    i := smile              # labels can be used before they're defined
+//	    v0 := 10  v1 := 5
+//	    loop
+//	        sprite v0 v1 3
+//	        v0 += 1
+//	        if v0 == 50 then v0 := 10
+//	    again
+//	: smile
+//	    0b10100000 0b00000000 0b11100000    # numbers on their own are bytes
+//
+// It's the core of the language: labels, :const, :alias, :org, :byte, :unpack, the
+// instructions, and if ... then, if ... begin ... else ... end, and loop ... while
+// ... again, with every comparison Octo has. It isn't Octo's metaprogramming --
+// :macro, :calc, :stringmode and the like -- or the XO-CHIP instructions this Chip8
+// hasn't got; programs that use them get an error saying so, and have to be compiled
+// in Octo itself.
+package octo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProgramStart is where programs are loaded, and so the address of their first byte.
+const ProgramStart = 0x200
+
+// memoryEnd is the end of memory, as far as programs compiled here go.
+const memoryEnd = 0x1000
+
+// An Error is a mistake in the source, and where it was.
+type Error struct {
+	Line int
+	Err  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+type token struct {
+	text string
+	line int
+}
+
+// fixup is a place in the program that needs the address of a label that hadn't been
+// defined yet when it was compiled.
+type fixup struct {
+	at    int
+	label string
+	line  int
+	// part is which part of the address goes there.
+	part part
+}
+
+type part int
+
+const (
+	// low12 is an address in the last three nibbles of an instruction, like jump's.
+	low12 part = iota
+	// highNibble and lowByte are the halves of an address, for :unpack.
+	highNibble
+	lowByte
+)
+
+// block is a loop, or an if ... begin, that hasn't ended yet.
+type block struct {
+	loop bool
+	// at is where the loop starts, or where the jump past the if's body is.
+	at int
+	// breaks are the jumps out of a loop, by while.
+	breaks []int
+}
+
+type compiler struct {
+	tokens []token
+	next   int
+	// line is the line of the token being compiled, for errors.
+	line int
+
+	program []byte
+	here    int
+	labels  map[string]int
+	consts  map[string]int
+	aliases map[string]int
+	fixups  []fixup
+	blocks  []block
+	// jumpToMain is true while the program starts with a jump to main that hasn't
+	// turned out to be pointless yet.
+	jumpToMain bool
+}
+
+// Compile compiles a program, to be loaded at ProgramStart.
+func Compile(source string) (program []byte, err error) {
+	c := &compiler{
+		here:    ProgramStart,
+		labels:  make(map[string]int),
+		consts:  make(map[string]int),
+		aliases: make(map[string]int),
+	}
+	for n, line := range strings.Split(source, "\n") {
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = line[:hash]
+		}
+		for _, field := range strings.Fields(line) {
+			c.tokens = append(c.tokens, token{field, n + 1})
+		}
+	}
+	// mistakes are found deep down, and it's a long way back up with an error, so
+	// they come back up as a panic instead.
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			program, err = nil, e
+		}
+	}()
+
+	// Octo programs start at main, wherever it is, so they start with a jump there,
+	// unless main's first anyway.
+	c.jump(0x1000, "main")
+	c.jumpToMain = true
+	for c.next < len(c.tokens) {
+		c.statement()
+	}
+	if len(c.blocks) > 0 {
+		if c.blocks[len(c.blocks)-1].loop {
+			c.fail("there's a loop without an again")
+		}
+		c.fail("there's a begin without an end")
+	}
+	if _, ok := c.labels["main"]; !ok {
+		c.fail("there's no main, so there's nowhere to start")
+	}
+	for _, f := range c.fixups {
+		address, ok := c.labels[f.label]
+		if !ok {
+			return nil, &Error{f.line, fmt.Sprintf("there's no label called %s", f.label)}
+		}
+		switch at := f.at - ProgramStart; f.part {
+		case low12:
+			c.program[at] |= byte(address >> 8 & 0xf)
+			c.program[at+1] = byte(address)
+		case highNibble:
+			c.program[at] |= byte(address >> 8 & 0xf)
+		case lowByte:
+			c.program[at] = byte(address)
+		}
+	}
+	return c.program, nil
+}
+
+func (c *compiler) fail(format string, args ...interface{}) {
+	panic(&Error{c.line, fmt.Sprintf(format, args...)})
+}
+
+// take returns the next token.
+func (c *compiler) take() string {
+	if c.next >= len(c.tokens) {
+		c.fail("the program stops in the middle of something")
+	}
+	t := c.tokens[c.next]
+	c.next++
+	c.line = t.line
+	return t.text
+}
+
+// peek returns the next token without taking it, or "" at the end.
+func (c *compiler) peek() string {
+	if c.next >= len(c.tokens) {
+		return ""
+	}
+	return c.tokens[c.next].text
+}
+
+// expect takes the next token, which had better be want.
+func (c *compiler) expect(want string) {
+	if got := c.take(); got != want {
+		c.fail("expected %s, not %s", want, got)
+	}
+}
+
+// emit puts bytes into the program where we are.
+func (c *compiler) emit(bytes ...byte) {
+	for _, b := range bytes {
+		if c.here >= memoryEnd {
+			c.fail("the program doesn't fit in memory")
+		}
+		for len(c.program) <= c.here-ProgramStart {
+			c.program = append(c.program, 0)
+		}
+		c.program[c.here-ProgramStart] = b
+		c.here++
+	}
+}
+
+func (c *compiler) op(opcode int) {
+	c.emit(byte(opcode>>8), byte(opcode))
+}
+
+// jump puts an instruction with an address in it, like jump or call, in the
+// program: base is the instruction, and to the label it goes to.
+func (c *compiler) jump(base int, to string) {
+	if address, ok := c.labels[to]; ok {
+		c.op(base | address)
+		return
+	}
+	if _, ok := c.consts[to]; ok || isNumber(to) {
+		c.op(base | c.value(to, 0xfff))
+		return
+	}
+	if !isName(to) {
+		c.fail("%s isn't a label", to)
+	}
+	c.fixups = append(c.fixups, fixup{at: c.here, label: to, line: c.line})
+	c.op(base)
+}
+
+// patch points the jump at at to where we are.
+func (c *compiler) patch(at int) {
+	c.program[at-ProgramStart] = c.program[at-ProgramStart]&0xf0 | byte(c.here>>8)
+	c.program[at-ProgramStart+1] = byte(c.here)
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseInt(s, 0, 32)
+	return err == nil
+}
+
+func isName(s string) bool {
+	return s != "" && !isNumber(s) && !strings.ContainsAny(s, ":=+<>!&|^;")
+}
+
+// value returns what s is, a number or a :const, which has to be no more than max.
+// Bytes can be negative too, down to -128.
+func (c *compiler) value(s string, max int) int {
+	n, ok := c.consts[s]
+	if !ok {
+		parsed, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			c.fail("%s isn't a number", s)
+		}
+		n = int(parsed)
+	}
+	if max == 0xff && n < 0 && n >= -128 {
+		n &= 0xff
+	}
+	if n < 0 || n > max {
+		c.fail("%s isn't a number from 0 to %#x", s, max)
+	}
+	return n
+}
+
+// register returns the number of the register s names, if it does.
+func (c *compiler) register(s string) (int, bool) {
+	if n, ok := c.aliases[s]; ok {
+		return n, true
+	}
+	if len(s) != 2 || (s[0] != 'v' && s[0] != 'V') {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 4)
+	return int(n), err == nil
+}
+
+// takeRegister takes the next token, which had better be a register.
+func (c *compiler) takeRegister() int {
+	s := c.take()
+	x, ok := c.register(s)
+	if !ok {
+		c.fail("expected a register like v0, not %s", s)
+	}
+	return x
+}
+
+// define checks that name's a name, and hasn't been used for anything yet.
+func (c *compiler) define(name string) {
+	if !isName(name) {
+		c.fail("%q isn't a good name", name)
+	}
+	_, label := c.labels[name]
+	_, constant := c.consts[name]
+	_, alias := c.aliases[name]
+	if label || constant || alias {
+		c.fail("%s is already defined", name)
+	}
+}
+
+func (c *compiler) statement() {
+	t := c.take()
+	if x, ok := c.register(t); ok {
+		c.assign(x)
+		return
+	}
+	switch t {
+	case ":":
+		name := c.take()
+		c.define(name)
+		// if nothing's come before main, the program might as well start there.
+		if name == "main" && c.jumpToMain && c.here == ProgramStart+2 {
+			c.here, c.program, c.fixups = ProgramStart, c.program[:0], c.fixups[1:]
+		}
+		c.jumpToMain = false
+		c.labels[name] = c.here
+	case ":const":
+		name := c.take()
+		c.define(name)
+		c.consts[name] = c.value(c.take(), 0xffff)
+	case ":alias":
+		// aliases can be moved to another register, and often are.
+		name := c.take()
+		if _, ok := c.aliases[name]; !ok {
+			c.define(name)
+		}
+		c.aliases[name] = c.takeRegister()
+	case ":org":
+		c.here = c.value(c.take(), memoryEnd-1)
+		if c.here < ProgramStart {
+			c.fail(":org can't go before %#x, where the program starts", ProgramStart)
+		}
+		c.jumpToMain = false
+	case ":byte":
+		c.emit(byte(c.value(c.take(), 0xff)))
+	case ":unpack":
+		// v0 and v1 get a label's address, with a nibble of something else on top.
+		nibble := c.value(c.take(), 0xf)
+		label := c.take()
+		if address, ok := c.labels[label]; ok {
+			c.op(0x6000 | nibble<<4 | address>>8)
+			c.op(0x6100 | address&0xff)
+			return
+		}
+		c.fixups = append(c.fixups, fixup{at: c.here + 1, label: label, line: c.line, part: highNibble})
+		c.op(0x6000 | nibble<<4)
+		c.fixups = append(c.fixups, fixup{at: c.here + 1, label: label, line: c.line, part: lowByte})
+		c.op(0x6100)
+	case ":breakpoint":
+		// there's nothing here to break into; the name's just a name.
+		c.take()
+	case ":monitor":
+		c.take()
+		c.take()
+	case ":macro", ":calc", ":stringmode", ":call", ":proto", ":next", ":assert", ":pointer":
+		c.fail("%s is more Octo than this compiler knows; compile it in Octo", t)
+	case ";", "return":
+		c.op(0x00ee)
+	case "clear":
+		c.op(0x00e0)
+	case "bcd":
+		c.op(0xf033 | c.takeRegister()<<8)
+	case "save", "load":
+		x := c.takeRegister()
+		if c.peek() == "-" {
+			c.fail("%s with a range of registers is XO-CHIP, which this Chip8 isn't", t)
+		}
+		if t == "save" {
+			c.op(0xf055 | x<<8)
+		} else {
+			c.op(0xf065 | x<<8)
+		}
+	case "saveflags":
+		c.op(0xf075 | c.takeRegister()<<8)
+	case "loadflags":
+		c.op(0xf085 | c.takeRegister()<<8)
+	case "sprite":
+		x, y := c.takeRegister(), c.takeRegister()
+		c.op(0xd000 | x<<8 | y<<4 | c.value(c.take(), 0xf))
+	case "jump":
+		c.jump(0x1000, c.take())
+	case "jump0":
+		c.jump(0xb000, c.take())
+	case "scroll-down":
+		c.op(0x00c0 | c.value(c.take(), 0xf))
+	case "scroll-right":
+		c.op(0x00fb)
+	case "scroll-left":
+		c.op(0x00fc)
+	case "plane":
+		c.op(0xf001 | c.value(c.take(), 3)<<8)
+	case "scroll-up", "hires", "lores", "exit", "audio", "pitch", "native":
+		c.fail("this Chip8 hasn't got %s", t)
+	case "delay":
+		c.expect(":=")
+		c.op(0xf015 | c.takeRegister()<<8)
+	case "buzzer":
+		c.expect(":=")
+		c.op(0xf018 | c.takeRegister()<<8)
+	case "i":
+		c.assignI()
+	case "if":
+		c.conditional()
+	case "else":
+		b := c.innermost(false, "else")
+		end := c.here
+		c.op(0x1000)
+		c.patch(b.at)
+		b.at = end
+	case "end":
+		b := c.innermost(false, "end")
+		c.patch(b.at)
+		c.blocks = c.blocks[:len(c.blocks)-1]
+	case "loop":
+		c.blocks = append(c.blocks, block{loop: true, at: c.here})
+	case "while":
+		b := c.loop("while")
+		// while skips the jump out of the loop while the condition's true.
+		skipIfTrue, _ := c.condition()
+		c.op(skipIfTrue)
+		b.breaks = append(b.breaks, c.here)
+		c.op(0x1000)
+	case "again":
+		b := c.innermost(true, "again")
+		c.op(0x1000 | b.at)
+		for _, at := range b.breaks {
+			c.patch(at)
+		}
+		c.blocks = c.blocks[:len(c.blocks)-1]
+	default:
+		if _, ok := c.consts[t]; ok || isNumber(t) {
+			c.emit(byte(c.value(t, 0xff)))
+			return
+		}
+		// anything else is a label, and a label on its own is a call.
+		c.jump(0x2000, t)
+	}
+}
+
+// innermost returns the block that's open, which had better be a loop, or not.
+func (c *compiler) innermost(loop bool, word string) *block {
+	if len(c.blocks) == 0 || c.blocks[len(c.blocks)-1].loop != loop {
+		c.fail("%s doesn't go here", word)
+	}
+	return &c.blocks[len(c.blocks)-1]
+}
+
+// loop returns the loop we're in, however many ifs deep.
+func (c *compiler) loop(word string) *block {
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		if c.blocks[i].loop {
+			return &c.blocks[i]
+		}
+	}
+	c.fail("%s has to be in a loop", word)
+	return nil
+}
+
+func (c *compiler) assignI() {
+	switch op := c.take(); op {
+	case ":=":
+		switch to := c.take(); to {
+		case "hex":
+			c.op(0xf029 | c.takeRegister()<<8)
+		case "bighex":
+			c.op(0xf030 | c.takeRegister()<<8)
+		case "long":
+			c.fail("i := long is XO-CHIP, which this Chip8 isn't")
+		default:
+			c.jump(0xa000, to)
+		}
+	case "+=":
+		c.op(0xf01e | c.takeRegister()<<8)
+	default:
+		c.fail("i can't %s", op)
+	}
+}
+
+// assign compiles the likes of v1 += v2, for register x.
+func (c *compiler) assign(x int) {
+	op := c.take()
+	s := c.take()
+	if y, ok := c.register(s); ok {
+		ops := map[string]int{":=": 0x0, "|=": 0x1, "&=": 0x2, "^=": 0x3, "+=": 0x4, "-=": 0x5, ">>=": 0x6, "=-": 0x7, "<<=": 0xe}
+		n, ok := ops[op]
+		if !ok {
+			c.fail("%s isn't something a register can do", op)
+		}
+		c.op(0x8000 | x<<8 | y<<4 | n)
+		return
+	}
+	switch {
+	case op == ":=" && s == "delay":
+		c.op(0xf007 | x<<8)
+	case op == ":=" && s == "key":
+		c.op(0xf00a | x<<8)
+	case op == ":=" && s == "random":
+		c.op(0xc000 | x<<8 | c.value(c.take(), 0xff))
+	case op == ":=":
+		c.op(0x6000 | x<<8 | c.value(s, 0xff))
+	case op == "+=":
+		c.op(0x7000 | x<<8 | c.value(s, 0xff))
+	case op == "-=":
+		c.op(0x7000 | x<<8 | (0x100-c.value(s, 0xff))&0xff)
+	default:
+		c.fail("%s needs a register, not %s", op, s)
+	}
+}
+
+// conditional compiles if ... then, and the start of if ... begin.
+func (c *compiler) conditional() {
+	skipIfTrue, skipIfFalse := c.condition()
+	switch word := c.take(); word {
+	case "then":
+		// then skips the one instruction after it when the condition's false...
+		c.op(skipIfFalse)
+	case "begin":
+		// ...and begin skips a jump past its body when it's true.
+		c.op(skipIfTrue)
+		c.blocks = append(c.blocks, block{at: c.here})
+		c.op(0x1000)
+	default:
+		c.fail("expected then or begin, not %s", word)
+	}
+}
+
+// condition compiles a condition, like v0 == 5, and returns two instructions to
+// finish it off with: one that skips the next instruction if the condition's true,
+// and one that skips it if it's false. Most conditions are just the skip, but some
+// need instructions of their own first, which condition puts in the program.
+func (c *compiler) condition() (skipIfTrue, skipIfFalse int) {
+	x := c.takeRegister()
+	switch cmp := c.take(); cmp {
+	case "key", "-key":
+		skipIfTrue, skipIfFalse = 0xe09e|x<<8, 0xe0a1|x<<8
+		if cmp == "-key" {
+			skipIfTrue, skipIfFalse = skipIfFalse, skipIfTrue
+		}
+	case "==", "!=":
+		s := c.take()
+		if y, ok := c.register(s); ok {
+			skipIfTrue, skipIfFalse = 0x5000|x<<8|y<<4, 0x9000|x<<8|y<<4
+		} else {
+			n := c.value(s, 0xff)
+			skipIfTrue, skipIfFalse = 0x3000|x<<8|n, 0x4000|x<<8|n
+		}
+		if cmp == "!=" {
+			skipIfTrue, skipIfFalse = skipIfFalse, skipIfTrue
+		}
+	case "<", ">", "<=", ">=":
+		// the Chip8 can only compare for equality; the rest is subtraction, and vf's
+		// borrow flag, the way Octo does it. vf gets the right-hand side, then
+		// vf =- vx works out vx - it (with vf 1 if vx >= it), and vf -= vx it - vx
+		// (with vf 1 if vx <= it).
+		s := c.take()
+		if y, ok := c.register(s); ok {
+			c.op(0x8f00 | y<<4)
+		} else {
+			c.op(0x6f00 | c.value(s, 0xff))
+		}
+		if cmp == "<" || cmp == ">=" {
+			c.op(0x8f07 | x<<4)
+		} else {
+			c.op(0x8f05 | x<<4)
+		}
+		// vf is now 1 if it's >= or <=, and 0 if it's < or >.
+		skipIfTrue, skipIfFalse = 0x3f01, 0x4f01
+		if cmp == "<" || cmp == ">" {
+			skipIfTrue, skipIfFalse = skipIfFalse, skipIfTrue
+		}
+	default:
+		c.fail("%s isn't a comparison", cmp)
+	}
+	return skipIfTrue, skipIfFalse
+}
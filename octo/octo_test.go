@@ -0,0 +1,131 @@
+package octo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/mpingram/chip8/octo"
+)
+
+// Compile
+// should compile Octo's statements, ifs and loops into the instructions Octo would
+// should leave out the jump to main when main's first anyway
+// should fill in labels that are used before they're defined
+// should say what's wrong, and where, with programs it can't compile
+func TestCompile(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		want   string
+	}{
+		{": main  i := smile  v0 := 10  sprite v0 v0 1  : smile 0xff", "a2 06 60 0a d0 01 ff"},
+		{": sub ;  : main sub", "12 04 00 ee 22 02"},
+		{": main loop v0 += 1 while v0 != 3 again", "70 01 40 03 12 08 12 00"},
+		{": main if v1 key begin v2 := 1 else v2 := 2 end", "e1 9e 12 08 62 01 12 0a 62 02"},
+		{": main if v3 < 5 then v4 := -1", "6f 05 8f 37 3f 01 64 ff"},
+		{": main if v3 >= v5 then v4 -= 2", "8f 50 8f 37 4f 01 74 fe"},
+		{":const speed 3  :alias x v7  : main x += speed  :unpack 0xa data : data", "77 03 60 a2 61 06"},
+		{"# a comment\n: main jump0 far bcd ve : far", "b2 04 fe 33"},
+		{": main :org 0x206 clear", "00 00 00 00 00 00 00 e0"},
+	} {
+		got, err := octo.Compile(test.source)
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		if hex := fmt.Sprintf("% x", got); hex != test.want {
+			t.Errorf("%q compiled to %s, want %s", test.source, hex, test.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"v0 := 1",
+		": main jump nowhere",
+		": main loop v0 += 1",
+		": main end",
+		": main v0 := 256",
+		": main hires",
+		":macro thing { v0 := 1 } : main",
+		": main : main",
+	} {
+		if _, err := octo.Compile(bad); err == nil {
+			t.Errorf("%q compiled", bad)
+		} else if _, ok := err.(*octo.Error); !ok {
+			t.Errorf("%q: got %v, want an *octo.Error", bad, err)
+		}
+	}
+}
+
+// ReadCartridge
+// should read the program and options out of a cartridge
+// should turn Octo's options into this Chip8's speed, quirks and colors
+// should refuse a GIF that isn't a cartridge
+func TestReadCartridge(t *testing.T) {
+	want := octo.Cartridge{
+		Program: ": main\n  v0 := 1\n  jump main\n",
+		Options: octo.Options{
+			TickRate:        15,
+			ShiftQuirks:     true,
+			LoadStoreQuirks: true,
+			FillColor:       "#FFCC00",
+			FillColor2:      "#FF6600",
+			BlendColor:      "#662200",
+			BackgroundColor: "#996600",
+		},
+	}
+	var cart bytes.Buffer
+	if err := gif.EncodeAll(&cart, cartridge(t, want)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := octo.ReadCartridge(&cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Program != want.Program || got.Options != want.Options {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if rom, err := got.ROM(); err != nil || fmt.Sprintf("% x", rom) != "60 01 12 00" {
+		t.Errorf("got ROM % x, %v", rom, err)
+	}
+	if got.Options.Speed() != 900 || got.Options.Quirks().ShiftVy || got.Options.Quirks().IncrementI {
+		t.Errorf("got speed %d and quirks %v, want 900 and none", got.Options.Speed(), got.Options.Quirks())
+	}
+	colors, ok := got.Options.Colors()
+	if !ok || colors[0] != (color.RGBA{0x99, 0x66, 0x00, 0xff}) || colors[1] != (color.RGBA{0xff, 0xcc, 0x00, 0xff}) {
+		t.Errorf("got colors %v, %v", colors, ok)
+	}
+
+	var picture bytes.Buffer
+	plain := image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&picture, plain, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := octo.ReadCartridge(&picture); err == nil {
+		t.Error("read a cartridge out of a GIF with nothing in it")
+	}
+}
+
+// cartridge makes a cartridge the way Octo does, without the picture.
+func cartridge(t *testing.T, c octo.Cartridge) *gif.GIF {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(payload)
+	data := append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, payload...)
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.RGBA{byte(i), byte(i), byte(i), 0xff}
+	}
+	const width = 64
+	frame := image.NewPaletted(image.Rect(0, 0, width, (len(data)*2+width-1)/width), palette)
+	for i, b := range data {
+		frame.Pix[i*2], frame.Pix[i*2+1] = 0x30|b>>4, 0x30|b&0xf
+	}
+	return &gif.GIF{Image: []*image.Paletted{frame}, Delay: []int{0}}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// overlayFont is a tiny 3x5 pixel font for on-screen messages -- in the spirit of the
+// Chip8's own 4x5 font, but with letters. Each glyph is five rows, top to bottom;
+// the low three bits of each row are its pixels, left to right.
+// Lowercase letters are drawn as uppercase; anything else missing is drawn as '?'.
+var overlayFont = map[rune][5]byte{
+	'A':  {0b111, 0b101, 0b111, 0b101, 0b101},
+	'B':  {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C':  {0b111, 0b100, 0b100, 0b100, 0b111},
+	'D':  {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E':  {0b111, 0b100, 0b111, 0b100, 0b111},
+	'F':  {0b111, 0b100, 0b111, 0b100, 0b100},
+	'G':  {0b111, 0b100, 0b101, 0b101, 0b111},
+	'H':  {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I':  {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J':  {0b001, 0b001, 0b001, 0b101, 0b111},
+	'K':  {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L':  {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M':  {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N':  {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O':  {0b111, 0b101, 0b101, 0b101, 0b111},
+	'P':  {0b111, 0b101, 0b111, 0b100, 0b100},
+	'Q':  {0b111, 0b101, 0b101, 0b111, 0b001},
+	'R':  {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S':  {0b111, 0b100, 0b111, 0b001, 0b111},
+	'T':  {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U':  {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V':  {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W':  {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X':  {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y':  {0b101, 0b101, 0b111, 0b010, 0b010},
+	'Z':  {0b111, 0b001, 0b010, 0b100, 0b111},
+	'0':  {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1':  {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2':  {0b111, 0b001, 0b111, 0b100, 0b111},
+	'3':  {0b111, 0b001, 0b111, 0b001, 0b111},
+	'4':  {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5':  {0b111, 0b100, 0b111, 0b001, 0b111},
+	'6':  {0b111, 0b100, 0b111, 0b101, 0b111},
+	'7':  {0b111, 0b001, 0b010, 0b100, 0b100},
+	'8':  {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9':  {0b111, 0b101, 0b111, 0b001, 0b111},
+	' ':  {0b000, 0b000, 0b000, 0b000, 0b000},
+	'.':  {0b000, 0b000, 0b000, 0b000, 0b010},
+	',':  {0b000, 0b000, 0b000, 0b010, 0b100},
+	':':  {0b000, 0b010, 0b000, 0b010, 0b000},
+	'-':  {0b000, 0b000, 0b111, 0b000, 0b000},
+	'+':  {0b000, 0b010, 0b111, 0b010, 0b000},
+	'=':  {0b000, 0b111, 0b000, 0b111, 0b000},
+	'/':  {0b001, 0b001, 0b010, 0b100, 0b100},
+	'(':  {0b010, 0b100, 0b100, 0b100, 0b010},
+	')':  {0b010, 0b001, 0b001, 0b001, 0b010},
+	'[':  {0b110, 0b100, 0b100, 0b100, 0b110},
+	']':  {0b011, 0b001, 0b001, 0b001, 0b011},
+	'<':  {0b001, 0b010, 0b100, 0b010, 0b001},
+	'>':  {0b100, 0b010, 0b001, 0b010, 0b100},
+	'!':  {0b010, 0b010, 0b010, 0b000, 0b010},
+	'?':  {0b111, 0b001, 0b011, 0b000, 0b010},
+	'%':  {0b101, 0b001, 0b010, 0b100, 0b101},
+	'_':  {0b000, 0b000, 0b000, 0b000, 0b111},
+	'\'': {0b010, 0b010, 0b000, 0b000, 0b000},
+	'#':  {0b101, 0b111, 0b101, 0b111, 0b101},
+}
+
+const (
+	// the overlay is drawn at a higher resolution than the Chip8 screen so there's room for text.
+	overlayWidth  = 512
+	overlayHeight = 256
+	// each pixel of a glyph is drawn as a 2x2 block.
+	glyphScale   = 2
+	glyphWidth   = 3 * glyphScale
+	glyphHeight  = 5 * glyphScale
+	glyphSpacing = 1 * glyphScale
+	lineSpacing  = 4 * glyphScale
+	lineHeight   = glyphHeight + lineSpacing
+)
+
+// textOverlay rasterizes lines of text into an RGBA image the size of the overlay,
+// ready to be uploaded to a texture and drawn over the screen.
+type textOverlay struct {
+	// pixels is the RGBA image, stored bottom row first like OpenGL expects.
+	pixels []byte
+	lines  []string
+	dim    bool
+}
+
+func newTextOverlay() *textOverlay {
+	return &textOverlay{pixels: make([]byte, overlayWidth*overlayHeight*4)}
+}
+
+// set changes the overlay's text. If dim is true, the whole screen behind the
+// text is darkened. set returns false (and does nothing) if nothing changed.
+func (t *textOverlay) set(lines []string, dim bool) bool {
+	if dim == t.dim && equalLines(lines, t.lines) {
+		return false
+	}
+	t.lines = append(t.lines[:0], lines...)
+	t.dim = dim
+	t.rasterize()
+	return true
+}
+
+// empty returns true if there's nothing to draw.
+func (t *textOverlay) empty() bool {
+	return len(t.lines) == 0 && !t.dim
+}
+
+func (t *textOverlay) rasterize() {
+	var background byte
+	if t.dim {
+		background = 0xA0
+	}
+	for i := 0; i < len(t.pixels); i += 4 {
+		t.pixels[i], t.pixels[i+1], t.pixels[i+2], t.pixels[i+3] = 0, 0, 0, background
+	}
+
+	for n, line := range t.lines {
+		line = strings.ToUpper(line)
+		left, y, lineWidth := t.lineBox(n)
+		if line != "" {
+			// put a dark box behind each line, so it's readable on top of anything.
+			t.fillRect(left-glyphSpacing*2, y-glyphSpacing*2, lineWidth+glyphSpacing*4, glyphHeight+glyphSpacing*4, 0, 0xC0)
+		}
+		x := left
+		for _, r := range line {
+			glyph, ok := overlayFont[r]
+			if !ok {
+				glyph = overlayFont['?']
+			}
+			for row := 0; row < 5; row++ {
+				for col := 0; col < 3; col++ {
+					if glyph[row]&(0b100>>uint(col)) != 0 {
+						t.fillRect(x+col*glyphScale, y+row*glyphScale, glyphScale, glyphScale, 0xFF, 0xFF)
+					}
+				}
+			}
+			x += glyphWidth + glyphSpacing
+		}
+	}
+}
+
+// lineBox returns where line n goes: its left edge and top, and how wide it is. The
+// block of lines is centered vertically, and each line horizontally.
+func (t *textOverlay) lineBox(n int) (left, top, width int) {
+	top = (overlayHeight-len(t.lines)*lineHeight+lineSpacing)/2 + n*lineHeight
+	width = len([]rune(t.lines[n]))*(glyphWidth+glyphSpacing) - glyphSpacing
+	left = (overlayWidth - width) / 2
+	return left, top, width
+}
+
+// lineAt returns which line is at (x, y) on the overlay, counting the dark box
+// around it, or -1 if there's no line there.
+func (t *textOverlay) lineAt(x, y int) int {
+	margin := glyphSpacing * 2
+	for n, line := range t.lines {
+		if line == "" {
+			continue
+		}
+		left, top, width := t.lineBox(n)
+		if x >= left-margin && x < left+width+margin && y >= top-margin && y < top+glyphHeight+margin {
+			return n
+		}
+	}
+	return -1
+}
+
+// fillRect fills a rectangle (in top-left coordinates) with a gray value and alpha,
+// clipping it to the overlay.
+func (t *textOverlay) fillRect(x, y, w, h int, gray, alpha byte) {
+	for py := y; py < y+h; py++ {
+		if py < 0 || py >= overlayHeight {
+			continue
+		}
+		// OpenGL reads texture data from bottom to top
+		row := (overlayHeight - 1 - py) * overlayWidth * 4
+		for px := x; px < x+w; px++ {
+			if px < 0 || px >= overlayWidth {
+				continue
+			}
+			i := row + px*4
+			t.pixels[i], t.pixels[i+1], t.pixels[i+2], t.pixels[i+3] = gray, gray, gray, alpha
+		}
+	}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// onScreenDisplay keeps track of what the overlay should be showing right now.
+type onScreenDisplay struct {
+	toast      string
+	toastUntil time.Time
+	// prompt is a question waiting for an answer. While there is one,
+	// it's shown on a dimmed screen.
+	prompt []string
+	// help says what the keys do, on a dimmed screen, until it's hidden again.
+	help []string
+	// paused says the Chip8 is paused, on a dimmed screen, for as long as it is.
+	paused []string
+	// indicators are lines that stay on screen for as long as something's going on,
+	// like slow motion, in the order they first turned up.
+	indicators []indicator
+}
+
+type indicator struct {
+	name, text string
+}
+
+// toastDuration is how long a toast stays on screen.
+const toastDuration = 2 * time.Second
+
+// showToast pops up a short message for a couple of seconds.
+func (osd *onScreenDisplay) showToast(message string) {
+	osd.toast = message
+	osd.toastUntil = time.Now().Add(toastDuration)
+}
+
+// lines returns the lines of text that should be on screen at time now, and whether
+// the screen behind them should be dimmed.
+func (osd *onScreenDisplay) lines(now time.Time) ([]string, bool) {
+	var lines []string
+	dim := false
+	if len(osd.prompt) > 0 {
+		lines = append(lines, osd.prompt...)
+		dim = true
+	} else if len(osd.help) > 0 {
+		lines = append(lines, osd.help...)
+		dim = true
+	} else if len(osd.paused) > 0 {
+		lines = append(lines, osd.paused...)
+		dim = true
+	}
+	for _, ind := range osd.indicators {
+		lines = append(lines, ind.text)
+	}
+	if osd.toast != "" && now.Before(osd.toastUntil) {
+		lines = append(lines, osd.toast)
+	}
+	return lines, dim
+}
+
+// setIndicator keeps text on screen until it's changed, or cleared by setting it to "".
+// name says which indicator it is, so it can be changed later.
+func (osd *onScreenDisplay) setIndicator(name, text string) {
+	for i, ind := range osd.indicators {
+		if ind.name != name {
+			continue
+		}
+		if text == "" {
+			osd.indicators = append(osd.indicators[:i], osd.indicators[i+1:]...)
+		} else {
+			osd.indicators[i].text = text
+		}
+		return
+	}
+	if text != "" {
+		osd.indicators = append(osd.indicators, indicator{name, text})
+	}
+}
+
+// showPrompt shows a question on screen until it's cleared with clearPrompt.
+func (osd *onScreenDisplay) showPrompt(lines ...string) {
+	osd.prompt = lines
+}
+
+func (osd *onScreenDisplay) clearPrompt() {
+	osd.prompt = nil
+}
+
+// showHelp shows lines of help on a dimmed screen, or with no lines, hides it.
+func (osd *onScreenDisplay) showHelp(lines ...string) {
+	osd.help = lines
+}
+
+// setPaused shows lines on a dimmed screen to say the Chip8 is paused, or with no
+// lines, stops. A prompt or the help takes priority over it.
+func (osd *onScreenDisplay) setPaused(lines ...string) {
+	osd.paused = lines
+}
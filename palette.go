@@ -0,0 +1,71 @@
+package main
+
+import "github.com/mpingram/chip8/softrender"
+
+// A palette is the colors the Chip8's screen is drawn in, as red, green and blue from 0 to 1.
+// Programs that draw on the second bit plane (see cpu/planes.go) get two more: plane2
+// for pixels that are only on in plane 2, and both for pixels that are on in both.
+type palette struct {
+	name                   string
+	foreground, background [3]float32
+	plane2, both           [3]float32
+}
+
+// palettes are the palettes to choose from. The first one's the default: it's the
+// dark red the emulator has always been, for old times' sake.
+var palettes = []palette{
+	{"red", [3]float32{1, 0, 0}, [3]float32{0x0f / 255.0, 0, 0}, [3]float32{0.45, 0, 0.05}, [3]float32{1, 0.6, 0.5}},
+	{"green phosphor", [3]float32{0.2, 1, 0.3}, [3]float32{0, 0.08, 0.02}, [3]float32{0.05, 0.45, 0.12}, [3]float32{0.75, 1, 0.8}},
+	{"amber", [3]float32{1, 0.7, 0}, [3]float32{0.08, 0.04, 0}, [3]float32{0.5, 0.3, 0}, [3]float32{1, 0.95, 0.7}},
+	{"black and white", [3]float32{1, 1, 1}, [3]float32{0, 0, 0}, [3]float32{0.33, 0.33, 0.33}, [3]float32{0.67, 0.67, 0.67}},
+	// the four greens of the original Game Boy, which had four colors to begin with.
+	{"pocket", [3]float32{0.06, 0.22, 0.06}, [3]float32{0.61, 0.74, 0.06}, [3]float32{0.55, 0.67, 0.06}, [3]float32{0.19, 0.38, 0.19}},
+}
+
+// softPalette returns the palette for the software renderer, which wants bytes.
+func (p palette) softPalette() softrender.Palette {
+	var soft softrender.Palette
+	for i, c := range [...][3]float32{p.background, p.foreground, p.plane2, p.both} {
+		soft[i].R, soft[i].G, soft[i].B, soft[i].A = byte(c[0]*255+0.5), byte(c[1]*255+0.5), byte(c[2]*255+0.5), 0xff
+	}
+	return soft
+}
+
+// fromSoftPalette is softPalette the other way round: it returns the palette called
+// name, in soft's colors.
+func fromSoftPalette(name string, soft softrender.Palette) palette {
+	p := palette{name: name}
+	for i, c := range []*[3]float32{&p.background, &p.foreground, &p.plane2, &p.both} {
+		c[0], c[1], c[2] = float32(soft[i].R)/255, float32(soft[i].G)/255, float32(soft[i].B)/255
+	}
+	return p
+}
+
+// paletteNames returns the names of the palettes, in order.
+func paletteNames() []string {
+	names := make([]string, len(palettes))
+	for i, p := range palettes {
+		names[i] = p.name
+	}
+	return names
+}
+
+// paletteByName returns the palette called name, if there is one.
+func paletteByName(name string) (palette, bool) {
+	for _, p := range palettes {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return palette{}, false
+}
+
+// dimmed returns the palette turned down low, for a screen that's there to be seen
+// but isn't the one that's being played.
+func (p palette) dimmed() palette {
+	const dim = 0.35
+	for _, c := range []*[3]float32{&p.foreground, &p.background, &p.plane2, &p.both} {
+		c[0], c[1], c[2] = c[0]*dim, c[1]*dim, c[2]*dim
+	}
+	return p
+}
@@ -0,0 +1,78 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+)
+
+const (
+	// pauseKey pauses the Chip8, and resumes it again.
+	pauseKey = glfw.KeyP
+	// frameStepKey runs the Chip8 to the end of the frame, and pauses it there.
+	frameStepKey = glfw.KeyPeriod
+)
+
+// bindPauseKey binds the keys that pause and resume the Chip8, and step it a
+// frame at a time.
+func bindPauseKey(input *GLFWKeyboardInput, c8 *cpu.Chip8) {
+	input.Describe(keyName(pauseKey), "pause and resume")
+	input.OnHotkey(pauseKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		if c8.IsRunning() {
+			c8.Halt()
+		} else {
+			go resume(c8)
+		}
+	})
+	input.Describe(keyName(frameStepKey), "pause, and play one frame at a time")
+	input.OnHotkey(frameStepKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		// a frame's a few instructions, which is quick enough for the main thread.
+		if stats := c8.FrameStep(); stats.Reason != cpu.StopHalted {
+			logStop(stats.RunResult)
+		}
+	})
+}
+
+// pauseWhenUnfocused pauses the Chip8 when the window loses focus, for
+// -pause-unfocused, and resumes it when the window gets focus back -- unless it
+// was paused already, in which case it stays paused. The timers freeze along with
+// it (see cpu/timers.go), so tabbing away mid-game doesn't cost a life.
+func pauseWhenUnfocused(window *glfw.Window, c8 *cpu.Chip8) {
+	pausedIt := false
+	window.SetFocusCallback(func(w *glfw.Window, focused bool) {
+		switch {
+		case !focused && c8.IsRunning():
+			c8.Halt()
+			pausedIt = true
+		case focused && pausedIt:
+			pausedIt = false
+			go resume(c8)
+		}
+	})
+}
+
+// showPaused dims the screen and says so while the Chip8 is paused -- whether it was
+// the pause key, the HTTP API, or the program running off its end -- so a paused
+// emulator doesn't look like a hung one. It has to be called on the main thread.
+func showPaused(osd *onScreenDisplay, c8 *cpu.Chip8) {
+	if c8.IsRunning() {
+		osd.setPaused()
+		return
+	}
+	osd.setPaused(
+		"paused",
+		fmt.Sprintf("frame %d, pc %03x", c8.FrameCount(), c8.Snapshot().PC),
+		"",
+		"p: resume, .: next frame",
+	)
+}
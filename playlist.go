@@ -0,0 +1,84 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/playlist"
+)
+
+// nextROMKey and previousROMKey move through the playlist, with -playlist.
+const (
+	nextROMKey     = glfw.KeyPageDown
+	previousROMKey = glfw.KeyPageUp
+)
+
+// playlistPlayer plays the ROMs in a playlist, one after another.
+type playlistPlayer struct {
+	list *playlist.Playlist
+	menu *menu
+	osd  *onScreenDisplay
+	c8   *cpu.Chip8
+	// until is when the playlist moves on by itself, or zero if it doesn't.
+	until time.Time
+}
+
+// apply sets the Chip8 up the way entry says to play it, and starts its clock.
+// Whatever it doesn't say stays the way the ROM's metadata or the command line
+// set it when the ROM was loaded (see romSetup).
+func (p *playlistPlayer) apply(entry playlist.Entry, now time.Time) {
+	if entry.Speed > 0 {
+		p.c8.SetSpeed(entry.Speed)
+	}
+	if entry.Quirks != nil {
+		p.c8.SetQuirks(*entry.Quirks)
+	}
+	if entry.Font != nil {
+		p.c8.SetFont(*entry.Font)
+	}
+	p.until = time.Time{}
+	if entry.Time > 0 {
+		p.until = now.Add(entry.Time)
+	}
+}
+
+// play switches to entry and starts it.
+func (p *playlistPlayer) play(entry playlist.Entry) {
+	if err := p.menu.load(entry.Path); err != nil {
+		log.Printf("loading %s: %v", entry.Path, err)
+		p.osd.showToast("couldn't load " + romLabel(entry.Path))
+		return
+	}
+	p.menu.romPath = entry.Path
+	p.apply(entry, time.Now())
+	p.osd.showToast(fmt.Sprintf("%d/%d: %s", p.list.Position(), len(p.list.Entries), romLabel(entry.Path)))
+	go resume(p.c8)
+}
+
+// update moves on to the next ROM once this one's time is up. Call it once a frame.
+func (p *playlistPlayer) update(now time.Time) {
+	if !p.until.IsZero() && !now.Before(p.until) {
+		p.play(p.list.Next())
+	}
+}
+
+// bindPlaylistKeys binds the keys that move to the next and previous ROMs.
+func bindPlaylistKeys(input *GLFWKeyboardInput, p *playlistPlayer) {
+	input.Describe(keyName(nextROMKey)+"/"+keyName(previousROMKey), "next and previous rom in the playlist")
+	input.OnHotkey(nextROMKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			p.play(p.list.Next())
+		}
+	})
+	input.OnHotkey(previousROMKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			p.play(p.list.Previous())
+		}
+	})
+}
@@ -0,0 +1,168 @@
+// Package playlist reads playlists: lists of ROMs to play one after another, for
+// working through a folder of games without starting the emulator again for each.
+// Each ROM can have its own speed, quirks and font, since a folder of games from
+// different decades rarely agrees on any of them, and a time after which the
+// playlist moves on by itself.
+//
+// Playlists are plain text files, a ROM to a line:
+//
+//	# lines starting with # are comments
+//	pong.ch8
+//	invaders.ch8 speed=1000 quirks=shift-vy,increment-i
+//	"Brix (fixed).ch8" font=vip time=5m
+//
+// Paths are relative to the playlist's folder, and ones with spaces in go in quotes.
+// After the path come any of speed= (instructions a second), quirks= (as for
+// -quirks; quirks= on its own turns them all off), font= (as for -font) and time=
+// (like 90s or 5m). Whatever a ROM doesn't say is left the way its metadata (see
+// the metadata package) or the command line set it.
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// An Entry is one ROM in a playlist, and how to play it.
+type Entry struct {
+	Path string
+	// Speed is how many instructions a second to run it at, or 0 to leave the speed alone.
+	Speed int
+	// Quirks and Font are what to play it with, or nil to leave them alone.
+	Quirks *cpu.Quirks
+	Font   *cpu.Font
+	// Time is how long it plays before the playlist moves on by itself, or 0 for as
+	// long as you like.
+	Time time.Duration
+}
+
+// A Playlist is the entries in a playlist file, and which one's playing. It starts
+// at the first, and goes round: after the last comes the first again.
+type Playlist struct {
+	Entries []Entry
+	current int
+}
+
+// Read reads the playlist file at path.
+func Read(path string) (*Playlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Parse(f, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Parse reads a playlist whose paths are relative to dir. A playlist with nothing
+// in it is an error: there'd be nothing to play.
+func Parse(r io.Reader, dir string) (*Playlist, error) {
+	p := new(Playlist)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if !filepath.IsAbs(entry.Path) {
+			entry.Path = filepath.Join(dir, entry.Path)
+		}
+		p.Entries = append(p.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.Entries) == 0 {
+		return nil, fmt.Errorf("the playlist is empty")
+	}
+	return p, nil
+}
+
+// parseEntry parses a line of a playlist.
+func parseEntry(line string) (Entry, error) {
+	var entry Entry
+	rest := line
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return entry, fmt.Errorf("the path's quote isn't closed")
+		}
+		entry.Path, rest = line[1:end+1], line[end+2:]
+	} else {
+		fields := strings.Fields(line)
+		entry.Path, rest = fields[0], strings.TrimPrefix(line, fields[0])
+	}
+	for _, option := range strings.Fields(rest) {
+		eq := strings.Index(option, "=")
+		if eq < 0 {
+			return entry, fmt.Errorf("%q should be name=value", option)
+		}
+		name, value := option[:eq], option[eq+1:]
+		switch name {
+		case "speed":
+			speed, err := strconv.Atoi(value)
+			if err != nil || speed <= 0 {
+				return entry, fmt.Errorf("the speed is a number of instructions a second, not %q", value)
+			}
+			entry.Speed = speed
+		case "quirks":
+			quirks, err := cpu.ParseQuirks(value)
+			if err != nil {
+				return entry, err
+			}
+			entry.Quirks = &quirks
+		case "font":
+			font, err := cpu.FontByName(value)
+			if err != nil {
+				return entry, err
+			}
+			entry.Font = &font
+		case "time":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return entry, fmt.Errorf("the time is how long to play, like 90s or 5m, not %q", value)
+			}
+			entry.Time = d
+		default:
+			return entry, fmt.Errorf("there's no %s=; there's speed=, quirks=, font= and time=", name)
+		}
+	}
+	return entry, nil
+}
+
+// Current returns the entry that's playing.
+func (p *Playlist) Current() Entry {
+	return p.Entries[p.current]
+}
+
+// Position returns the number of the entry that's playing, counting from 1.
+func (p *Playlist) Position() int {
+	return p.current + 1
+}
+
+// Next moves on to the next entry, and returns it.
+func (p *Playlist) Next() Entry {
+	p.current = (p.current + 1) % len(p.Entries)
+	return p.Current()
+}
+
+// Previous goes back to the entry before, and returns it.
+func (p *Playlist) Previous() Entry {
+	p.current = (p.current + len(p.Entries) - 1) % len(p.Entries)
+	return p.Current()
+}
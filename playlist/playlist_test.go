@@ -0,0 +1,56 @@
+package playlist_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/playlist"
+)
+
+// Parse
+// should read each ROM's path, relative to the playlist, and its overrides
+// should go round from the last entry to the first, and back
+// should say which line's wrong in a playlist that is
+func TestPlaylist(t *testing.T) {
+	p, err := playlist.Parse(strings.NewReader(`
+		# three games
+		pong.ch8
+		invaders.ch8 speed=1000 quirks=shift-vy
+		"Brix (fixed).ch8" quirks= time=5m
+	`), "games")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(p.Entries))
+	}
+	pong, invaders, brix := p.Entries[0], p.Entries[1], p.Entries[2]
+	if pong.Path != filepath.Join("games", "pong.ch8") || pong.Speed != 0 || pong.Quirks != nil || pong.Time != 0 {
+		t.Errorf("pong is %+v, want just a path", pong)
+	}
+	if invaders.Speed != 1000 || invaders.Quirks == nil || !invaders.Quirks.ShiftVy {
+		t.Errorf("invaders is %+v, want it at 1000 with shift-vy", invaders)
+	}
+	if brix.Path != filepath.Join("games", "Brix (fixed).ch8") || brix.Quirks == nil || brix.Quirks.ShiftVy || brix.Time != 5*time.Minute {
+		t.Errorf("brix is %+v, want it quirkless for 5 minutes", brix)
+	}
+
+	if p.Current().Path != pong.Path || p.Previous().Path != brix.Path || p.Next().Path != pong.Path || p.Next().Path != invaders.Path {
+		t.Errorf("the playlist doesn't go round")
+	}
+	if p.Position() != 2 {
+		t.Errorf("the playlist is at %d, want 2", p.Position())
+	}
+
+	for _, bad := range []string{"pong.ch8 speed=fast", `"pong.ch8 speed=10`, "pong.ch8 colour=red", "pong.ch8 quirks=wobbly", "pong.ch8 10"} {
+		_, err := playlist.Parse(strings.NewReader("# fine\n"+bad), "")
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: got error %v, want one about line 2", bad, err)
+		}
+	}
+	if _, err := playlist.Parse(strings.NewReader("# nothing\n"), ""); err == nil {
+		t.Errorf("an empty playlist parsed")
+	}
+}
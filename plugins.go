@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/plugins"
+)
+
+// startPlugins loads the Go plugins in list, a comma-separated list of files, and
+// starts every plugin that's registered (see the plugins package) on c8. Plugins
+// compiled in don't need loading; they registered themselves before main began.
+func startPlugins(c8 *cpu.Chip8, romPath string, list string) *plugins.Set {
+	for _, path := range strings.Split(list, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := plugins.Load(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	set, err := plugins.Init(&plugins.Core{Chip8: c8, ROMPath: romPath})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range plugins.Registered() {
+		log.Printf("plugin %s: %v", p.Name(), p.Capabilities())
+	}
+	return set
+}
@@ -0,0 +1,38 @@
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// Load opens the Go plugin at path (see the standard library's plugin package) and
+// registers what's in it. Opening it runs its init functions, which can call Register
+// themselves; if they don't, it has to export a variable named Plugin, of type
+// Plugin, for Load to register instead.
+//
+// Go plugins are picky: they only load into a program built by the same version of
+// Go, with the same versions of every package the two have in common -- this one
+// included -- and only on some systems (Linux and macOS, at the time of writing).
+func Load(path string) error {
+	before := len(Registered())
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("loading plugin %s: %v", path, err)
+	}
+	if len(Registered()) > before {
+		return nil
+	}
+	symbol, err := p.Lookup("Plugin")
+	if err != nil {
+		return fmt.Errorf("loading plugin %s: it didn't register anything, and has no Plugin variable", path)
+	}
+	exported, ok := symbol.(*Plugin)
+	if !ok {
+		return fmt.Errorf("loading plugin %s: its Plugin is a %T, not a plugins.Plugin", path, symbol)
+	}
+	if *exported == nil {
+		return fmt.Errorf("loading plugin %s: its Plugin is nil", path)
+	}
+	Register(*exported)
+	return nil
+}
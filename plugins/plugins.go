@@ -0,0 +1,265 @@
+// Package plugins is how other people's code gets into the emulator without anyone
+// changing the emulator: a plugin can put the screen somewhere else (an LED matrix, a
+// braille display, a stream), press keys (a gamepad, a bot, a MIDI keyboard), make the
+// beeps, or add a panel of its own to the debugger.
+//
+// A plugin registers itself, usually from an init function, and then it's in for
+// every Chip8 the frontend starts. There are two ways to get one's init function run:
+// import its package into a build of the emulator, or build it as a Go plugin
+// (go build -buildmode=plugin) and load that with -plugin, which calls Load. A Go
+// plugin can also skip registering and just export a variable named Plugin, of type
+// plugins.Plugin, for Load to register for it.
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Capability is something a plugin can do for the frontend. A plugin's
+// Capabilities are some of them ORed together, and for each one it has, it has to
+// implement the interface that goes with it.
+type Capability uint
+
+const (
+	// Display plugins are DisplayPlugins, and get every frame the Chip8 draws.
+	Display Capability = 1 << iota
+	// Input plugins are InputPlugins, and press keys on the Chip8's keypad.
+	Input
+	// Speaker plugins are SpeakerPlugins, and hear when the Chip8 beeps.
+	Speaker
+	// DebugPanel plugins are DebugPanelPlugins, and get a panel in the debugger.
+	DebugPanel
+)
+
+// capabilityNames are what String calls the capabilities.
+var capabilityNames = []struct {
+	capability Capability
+	name       string
+}{
+	{Display, "display"},
+	{Input, "input"},
+	{Speaker, "speaker"},
+	{DebugPanel, "debug-panel"},
+}
+
+// String returns the capabilities as a comma-separated list, or "none".
+func (c Capability) String() string {
+	var on []string
+	for _, capability := range capabilityNames {
+		if c&capability.capability != 0 {
+			on = append(on, capability.name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, ",")
+}
+
+// Core is what a plugin gets to work with when it's initialized.
+type Core struct {
+	// Chip8 is the Chip8 the frontend is running. Plugins can call its methods
+	// like anyone else, hooks and all -- but not from inside the calls the frontend
+	// makes to them, which happen while the Chip8 is busy.
+	Chip8 *cpu.Chip8
+	// ROMPath is the file the ROM was loaded from, or "" if it didn't come from one.
+	ROMPath string
+}
+
+// A Plugin is anything that plugs into the frontend. Name is what it's called in
+// logs and in the debugger, and has to be different from every other plugin's.
+// Init is called once, before the Chip8 starts running; a plugin that returns an
+// error from it stops the frontend from starting, since it's presumably there for a
+// reason.
+type Plugin interface {
+	Name() string
+	Capabilities() Capability
+	Init(core *Core) error
+}
+
+// A DisplayPlugin shows the screen. Show is called with every frame the frontend
+// draws, on the frontend's main thread, so it needs to be quick about it.
+type DisplayPlugin interface {
+	Plugin
+	Show(frame cpu.ColorFrame)
+}
+
+// An InputPlugin presses keys on the Chip8's keypad. It's polled like any other
+// cpu.Keyboard, but by the frontend, once a frame (see PollInputs), and whatever key
+// it says is down is pressed as well as any on the real keyboard.
+type InputPlugin interface {
+	Plugin
+	cpu.Keyboard
+}
+
+// A SpeakerPlugin is told when the Chip8 starts and stops beeping, like any other
+// cpu.Speaker.
+type SpeakerPlugin interface {
+	Plugin
+	cpu.Speaker
+}
+
+// A DebugPanelPlugin adds a panel to the debugger, which shows the lines Panel
+// returns under the plugin's name. Panel is called every frame the debugger is
+// open, with a snapshot of the Chip8.
+type DebugPanelPlugin interface {
+	Plugin
+	Panel(state cpu.Chip8State) []string
+}
+
+var (
+	mu sync.Mutex
+	// registered are the plugins that have registered, by name.
+	registered = make(map[string]Plugin)
+)
+
+// Register registers a plugin, so the frontend will use it. Like database/sql's
+// Register, it panics if the plugin is nil or another one has its name: both are
+// mistakes best found the first time the program runs.
+func Register(p Plugin) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p == nil {
+		panic("plugins: Register plugin is nil")
+	}
+	if _, dup := registered[p.Name()]; dup {
+		panic("plugins: Register called twice for plugin " + p.Name())
+	}
+	registered[p.Name()] = p
+}
+
+// Registered returns the plugins that have registered, sorted by name.
+func Registered() []Plugin {
+	mu.Lock()
+	defer mu.Unlock()
+	plugins := make([]Plugin, 0, len(registered))
+	for _, p := range registered {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
+	return plugins
+}
+
+// A Set is the registered plugins, initialized and sorted out by what they can do,
+// for a frontend to wire in.
+type Set struct {
+	Displays    []DisplayPlugin
+	Inputs      []InputPlugin
+	Speakers    []SpeakerPlugin
+	DebugPanels []DebugPanelPlugin
+	// held is the key each input plugin had down when it was last polled.
+	held []cpu.KeyCode
+}
+
+// Init initializes every registered plugin with core, and returns them as a Set.
+// It stops at the first plugin that fails to initialize, or that says it can do
+// something without implementing the interface for it.
+func Init(core *Core) (*Set, error) {
+	set := new(Set)
+	for _, p := range Registered() {
+		capabilities := p.Capabilities()
+		if err := p.Init(core); err != nil {
+			return nil, fmt.Errorf("plugin %s: %v", p.Name(), err)
+		}
+		missing := func(what, plugin string) error {
+			return fmt.Errorf("plugin %s says it's a %s plugin, but it isn't a %s", p.Name(), what, plugin)
+		}
+		if capabilities&Display != 0 {
+			display, ok := p.(DisplayPlugin)
+			if !ok {
+				return nil, missing("display", "DisplayPlugin")
+			}
+			set.Displays = append(set.Displays, display)
+		}
+		if capabilities&Input != 0 {
+			input, ok := p.(InputPlugin)
+			if !ok {
+				return nil, missing("input", "InputPlugin")
+			}
+			set.Inputs = append(set.Inputs, input)
+			set.held = append(set.held, cpu.KeyNone)
+		}
+		if capabilities&Speaker != 0 {
+			speaker, ok := p.(SpeakerPlugin)
+			if !ok {
+				return nil, missing("speaker", "SpeakerPlugin")
+			}
+			set.Speakers = append(set.Speakers, speaker)
+		}
+		if capabilities&DebugPanel != 0 {
+			panel, ok := p.(DebugPanelPlugin)
+			if !ok {
+				return nil, missing("debug panel", "DebugPanelPlugin")
+			}
+			set.DebugPanels = append(set.DebugPanels, panel)
+		}
+	}
+	return set, nil
+}
+
+// Show shows the frame on every display plugin.
+func (s *Set) Show(frame cpu.ColorFrame) {
+	for _, display := range s.Displays {
+		display.Show(frame)
+	}
+}
+
+// A Keypad is somewhere to press keys, like a control.Keypad.
+type Keypad interface {
+	Press(key cpu.KeyCode)
+	Release(key cpu.KeyCode)
+}
+
+// PollInputs polls every input plugin and presses the keys they have down on keypad,
+// and releases the ones they've let go of since last time. Call it once a frame.
+//
+// It goes through a keypad, rather than the plugins being keyboards of their own,
+// so the Chip8 hears about their keys the same way it hears about everyone else's:
+// as presses and releases (see cpu.KeyEventSource).
+func (s *Set) PollInputs(keypad Keypad) {
+	for i, input := range s.Inputs {
+		key := input.Poll()
+		if key == s.held[i] {
+			continue
+		}
+		if s.held[i] != cpu.KeyNone {
+			keypad.Release(s.held[i])
+		}
+		if key != cpu.KeyNone {
+			keypad.Press(key)
+		}
+		s.held[i] = key
+	}
+}
+
+// Speaker returns a speaker that beeps on speaker and every speaker plugin at once.
+func (s *Set) Speaker(speaker cpu.Speaker) cpu.Speaker {
+	if len(s.Speakers) == 0 {
+		return speaker
+	}
+	speakers := multiSpeaker{speaker}
+	for _, plugin := range s.Speakers {
+		speakers = append(speakers, plugin)
+	}
+	return speakers
+}
+
+// multiSpeaker is some speakers that all beep together.
+type multiSpeaker []cpu.Speaker
+
+func (m multiSpeaker) StartSound() {
+	for _, speaker := range m {
+		speaker.StartSound()
+	}
+}
+
+func (m multiSpeaker) StopSound() {
+	for _, speaker := range m {
+		speaker.StopSound()
+	}
+}
@@ -0,0 +1,95 @@
+package plugins_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/plugins"
+)
+
+// gamepad is a display and input plugin: it counts the frames it's shown, and holds
+// down whichever key it's told to. It has a debug panel too, but doesn't say so, so
+// it shouldn't get one.
+type gamepad struct {
+	core   *plugins.Core
+	frames int
+	key    cpu.KeyCode
+}
+
+func (g *gamepad) Name() string                        { return "gamepad" }
+func (g *gamepad) Capabilities() plugins.Capability    { return plugins.Display | plugins.Input }
+func (g *gamepad) Init(core *plugins.Core) error       { g.core = core; return nil }
+func (g *gamepad) Show(frame cpu.ColorFrame)           { g.frames++ }
+func (g *gamepad) Poll() cpu.KeyCode                   { return g.key }
+func (g *gamepad) Panel(state cpu.Chip8State) []string { return nil }
+
+// liar says it's a speaker, and isn't.
+type liar struct{}
+
+func (liar) Name() string                     { return "liar" }
+func (liar) Capabilities() plugins.Capability { return plugins.Speaker }
+func (liar) Init(core *plugins.Core) error    { return nil }
+
+// keypad remembers what was pressed and released on it, in order.
+type keypad []string
+
+func (k *keypad) Press(key cpu.KeyCode)   { *k = append(*k, fmt.Sprintf("press %X", key)) }
+func (k *keypad) Release(key cpu.KeyCode) { *k = append(*k, fmt.Sprintf("release %X", key)) }
+
+// Register / Init
+// should initialize registered plugins and sort them out by what they say they can do
+// should only press and release keys on the keypad when an input plugin's key changes
+// should refuse a second plugin by the same name, and one that can't do what it says
+func TestPlugins(t *testing.T) {
+	pad := new(gamepad)
+	plugins.Register(pad)
+	core := &plugins.Core{ROMPath: "pong.ch8"}
+	set, err := plugins.Init(core)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pad.core != core {
+		t.Errorf("the plugin wasn't initialized with the core")
+	}
+	if len(set.Displays) != 1 || len(set.Inputs) != 1 || len(set.Speakers) != 0 || len(set.DebugPanels) != 0 {
+		t.Errorf("got %d displays, %d inputs, %d speakers and %d debug panels; want a display and an input, and nothing else",
+			len(set.Displays), len(set.Inputs), len(set.Speakers), len(set.DebugPanels))
+	}
+	set.Show(cpu.ColorFrame{})
+	if pad.frames != 1 {
+		t.Errorf("the display plugin was shown %d frames, want 1", pad.frames)
+	}
+
+	var keys keypad
+	for _, key := range []cpu.KeyCode{5, 5, 7, cpu.KeyNone} {
+		pad.key = key
+		set.PollInputs(&keys)
+	}
+	want := []string{"press 5", "release 5", "press 7", "release 7"}
+	if len(keys) != len(want) {
+		t.Fatalf("got %v on the keypad, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("got %v on the keypad, want %v", keys, want)
+			break
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("registering a second gamepad didn't panic")
+			}
+		}()
+		plugins.Register(new(gamepad))
+	}()
+	plugins.Register(liar{})
+	if _, err := plugins.Init(core); err == nil {
+		t.Errorf("Init took a speaker plugin that isn't a SpeakerPlugin")
+	}
+	if got := (plugins.Display | plugins.DebugPanel).String(); got != "display,debug-panel" {
+		t.Errorf("capabilities came out as %q", got)
+	}
+}
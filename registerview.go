@@ -0,0 +1,352 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-compatibility/gl"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/plugins"
+)
+
+const (
+	// the register view is drawn at a low resolution, like the Chip8 itself, and blown up.
+	registerViewWidth  = 256
+	registerViewHeight = 192
+	registerViewZoom   = 3
+	// flashFrames is how many frames something flashes for after it changes.
+	flashFrames = 20
+	// memoryColumns is how many cells wide the memory map is; each cell is 2x2 pixels.
+	memoryColumns = 64
+	// memoryCells is how many cells there are in the memory map: a byte to a cell in
+	// 4K of memory, and in 64K, sixteen.
+	memoryCells = 4096
+)
+
+// a color, in RGB.
+type color [3]byte
+
+var (
+	viewBackground = color{0x10, 0x10, 0x10}
+	viewText       = color{0xc0, 0xc0, 0xc0}
+	viewBar        = color{0x30, 0x90, 0x40}
+	viewFlash      = color{0xff, 0xd0, 0x00}
+	viewPC         = color{0x30, 0xff, 0x60}
+	viewI          = color{0x40, 0x80, 0xff}
+)
+
+// regionColors color the memory map, a color for each of cpu.MemoryMap's regions.
+var regionColors = map[string]color{
+	"font":        {0xa0, 0x60, 0xe0},
+	"interpreter": {0x60, 0x60, 0x60},
+	"program":     {0x40, 0xb0, 0xb0},
+	"data":        {0xe0, 0x90, 0x30},
+	"stack":       {0xe0, 0x50, 0x50},
+	"video":       {0xe0, 0xe0, 0xe0},
+}
+
+// registerView is a second window that shows what's going on inside the Chip8 as it
+// runs: the registers as bars, the stack, and all of memory as a map, colored by what
+// each part of it is for. Anything that changes flashes, so you can see at a glance what
+// each instruction is doing -- it's meant for putting up on a projector while someone
+// explains it all.
+type registerView struct {
+	window *glfw.Window
+	// debug is true if the window has the debugger's panels, for code and memory,
+	// down the right of the usual view.
+	debug bool
+	// panels are the debugger's panels from plugins, which go down the right of its own.
+	panels  []plugins.DebugPanelPlugin
+	canvas  *canvas
+	last    cpu.Chip8State
+	regions []cpu.MemoryRegion
+	// activity is how busy each region has been lately: how many of its bytes have
+	// changed, fading away over time.
+	activity []float64
+	// flash* count down the frames each thing has left to flash, since it last changed.
+	flashV      [16]int
+	flashI      int
+	flashDT     int
+	flashST     int
+	flashStack  [16]int
+	flashMemory []int
+}
+
+// openRegisterView opens the register view's window, with the debugger's panels as
+// well if debug is true, plugins' panels included. Call it on the main thread; it leaves the main window's
+// context current, like it found it.
+func openRegisterView(main *glfw.Window, debug bool, panels []plugins.DebugPanelPlugin) *registerView {
+	width, title := registerViewWidth, "Chip-8 registers"
+	if debug {
+		width, title = registerViewWidth+debugPanelsWidth+len(panels)*pluginPanelWidth, "Chip-8 debugger"
+	} else {
+		panels = nil
+	}
+	// openWindow sets its context hints after creating the main window, so they're
+	// still set for any window created after it. Drop them: a core profile context
+	// can't DrawPixels, and this window needs nothing more than the default.
+	glfw.DefaultWindowHints()
+	glfw.WindowHint(glfw.Resizable, glfw.False)
+	window, err := glfw.CreateWindow(width*registerViewZoom, registerViewHeight*registerViewZoom, title, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	setWindowIcon(window)
+	window.MakeContextCurrent()
+	// don't let this window's vsync hold up the main window's.
+	glfw.SwapInterval(0)
+	main.MakeContextCurrent()
+	return &registerView{window: window, debug: debug, panels: panels, canvas: newCanvas(width, registerViewHeight)}
+}
+
+// closed returns true once the window's been closed.
+func (v *registerView) closed() bool {
+	return v.window.ShouldClose()
+}
+
+// destroy closes the window for good.
+func (v *registerView) destroy() {
+	v.window.Destroy()
+}
+
+// show draws c8 into the window, leaving main's context current afterwards.
+func (v *registerView) show(c8 *cpu.Chip8, main *glfw.Window) {
+	state := c8.Snapshot()
+	// the map only changes when a ROM's loaded, but that's no reason not to keep up.
+	if regions := c8.MemoryMap(); !sameRegions(regions, v.regions) {
+		v.regions = regions
+		v.activity = make([]float64, len(regions))
+	}
+	v.notice(state)
+	v.draw(state)
+	v.window.MakeContextCurrent()
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.WindowPos2i(0, 0)
+	gl.PixelZoom(registerViewZoom, registerViewZoom)
+	gl.DrawPixels(int32(v.canvas.width), int32(v.canvas.height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(v.canvas.pixels))
+	v.window.SwapBuffers()
+	main.MakeContextCurrent()
+}
+
+// notice starts everything that's changed since last time flashing, and lets
+// everything else carry on fading.
+func (v *registerView) notice(state cpu.Chip8State) {
+	flash := func(counter *int, changed bool) {
+		if changed {
+			*counter = flashFrames
+		} else if *counter > 0 {
+			*counter--
+		}
+	}
+	for i := range state.V {
+		flash(&v.flashV[i], state.V[i] != v.last.V[i])
+	}
+	flash(&v.flashI, state.I != v.last.I)
+	flash(&v.flashDT, state.DT != v.last.DT)
+	flash(&v.flashST, state.ST != v.last.ST)
+	for i := range v.flashStack {
+		flash(&v.flashStack[i], stackEntry(state.Stack, i) != stackEntry(v.last.Stack, i))
+	}
+	// the first time through, there's nothing to compare memory with; pretend it was empty.
+	if len(v.last.Memory) != len(state.Memory) {
+		v.last.Memory = make([]byte, len(state.Memory))
+		v.flashMemory = make([]int, len(state.Memory))
+	}
+	for i := range state.Memory {
+		changed := state.Memory[i] != v.last.Memory[i]
+		flash(&v.flashMemory[i], changed)
+		if changed {
+			v.activity[v.regionOf(uint16(i))]++
+		}
+	}
+	for i := range v.activity {
+		v.activity[i] *= 0.9
+	}
+	v.last = state
+	// the snapshot's stack is a slice of its own memory; keep our own copy of that.
+	v.last.Stack = append([]byte(nil), state.Stack...)
+}
+
+func (v *registerView) draw(state cpu.Chip8State) {
+	c := v.canvas
+	c.fill(0, 0, c.width, c.height, viewBackground)
+
+	// the registers, each a bar as long as its value is big.
+	row := func(y int, label, value string, fraction float64, flashing int) {
+		c.text(4, y, label, viewText)
+		c.text(20, y, value, fade(viewText, flashing))
+		c.fill(44, y, 56, 5, color{0x20, 0x20, 0x20})
+		c.fill(44, y, int(56*fraction+0.5), 5, fade(viewBar, flashing))
+	}
+	for i, value := range state.V {
+		row(4+i*8, fmt.Sprintf("V%X", i), fmt.Sprintf("%02X", value), float64(value)/0xff, v.flashV[i])
+	}
+	highest := float64(len(state.Memory) - 1)
+	row(136, "I", fmt.Sprintf("%03X", state.I), float64(state.I)/highest, v.flashI)
+	row(144, "PC", fmt.Sprintf("%03X", state.PC), float64(state.PC)/highest, 0)
+	row(152, "DT", fmt.Sprintf("%02X", state.DT), float64(state.DT)/0xff, v.flashDT)
+	row(160, "ST", fmt.Sprintf("%02X", state.ST), float64(state.ST)/0xff, v.flashST)
+
+	// the stack, bottom first.
+	c.text(104, 4, "STACK", viewText)
+	for i := range v.flashStack {
+		if entry := stackEntry(state.Stack, i); entry >= 0 {
+			c.text(104, 12+i*8, fmt.Sprintf("%03X", entry), fade(viewText, v.flashStack[i]))
+		}
+	}
+
+	// all of memory, colored by region: the brighter the cell, the bigger the byte. The
+	// cell at PC is green, the one at I is blue, and anything just written flashes. With
+	// more than 4K, each cell stands for a few bytes, and shows the biggest of them.
+	const left, top = 124, 4
+	per := len(state.Memory) / memoryCells
+	for i := 0; i < memoryCells; i++ {
+		start := i * per
+		var value byte
+		flashing := 0
+		for addr := start; addr < start+per; addr++ {
+			if state.Memory[addr] > value {
+				value = state.Memory[addr]
+			}
+			if v.flashMemory[addr] > flashing {
+				flashing = v.flashMemory[addr]
+			}
+		}
+		cell := fade(shade(regionColors[v.regions[v.regionOf(uint16(start))].Name], value), flashing)
+		switch {
+		case int(state.PC) >= start-1 && int(state.PC) < start+per:
+			cell = viewPC
+		case int(state.I) >= start && int(state.I) < start+per:
+			cell = viewI
+		}
+		c.fill(left+i%memoryColumns*2, top+i/memoryColumns*2, 2, 2, cell)
+	}
+
+	// and under it, what the colors mean, and how busy each region is.
+	for i, region := range v.regions {
+		y := top + memoryCells/memoryColumns*2 + 4 + i*8
+		c.fill(left, y, 5, 5, regionColors[region.Name])
+		c.text(left+7, y, strings.ToUpper(region.Name), viewText)
+		// 64K's addresses are too long for both ends to fit.
+		addresses := fmt.Sprintf("%03X-%03X", region.Start, region.End-1)
+		if len(addresses) > 7 {
+			addresses = fmt.Sprintf("%04X", region.Start)
+		}
+		c.text(left+53, y, addresses, viewText)
+		c.fill(left+83, y, 24, 5, color{0x20, 0x20, 0x20})
+		busy := v.activity[i] / 32
+		if busy > 1 {
+			busy = 1
+		}
+		c.fill(left+83, y, int(24*busy+0.5), 5, viewBar)
+		marks := ""
+		if v.regionOf(state.PC) == i {
+			marks += "PC "
+		}
+		if v.regionOf(state.I) == i {
+			marks += "I"
+		}
+		c.text(left+109, y, marks, viewText)
+	}
+
+	if v.debug {
+		v.drawCode(state, registerViewWidth)
+		v.drawMemory(state, registerViewWidth+codePanelWidth)
+		for i, panel := range v.panels {
+			v.drawPluginPanel(panel, state, registerViewWidth+debugPanelsWidth+i*pluginPanelWidth)
+		}
+	}
+}
+
+// regionOf returns the index of the memory region addr is in.
+func (v *registerView) regionOf(addr uint16) int {
+	for i, region := range v.regions {
+		if int(addr) >= region.Start && int(addr) < region.End {
+			return i
+		}
+	}
+	return len(v.regions) - 1
+}
+
+// sameRegions reports whether two memory maps are the same.
+func sameRegions(a, b []cpu.MemoryRegion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// shade darkens a color for a small value, so empty memory shows which region it's in
+// and full memory stands out.
+func shade(base color, value byte) color {
+	var shaded color
+	for i := range shaded {
+		shaded[i] = byte(int(base[i]) * (64 + int(value)*3/4) / 256)
+	}
+	return shaded
+}
+
+// fade mixes in the flash color, more the more recently it changed.
+func fade(base color, flashing int) color {
+	var mixed color
+	for i := range mixed {
+		mixed[i] = byte((int(base[i])*(flashFrames-flashing) + int(viewFlash[i])*flashing) / flashFrames)
+	}
+	return mixed
+}
+
+// canvas is an RGBA image to draw into, stored bottom row first like OpenGL expects,
+// but drawn into with top-left coordinates like everything else.
+type canvas struct {
+	width, height int
+	pixels        []byte
+}
+
+func newCanvas(width, height int) *canvas {
+	return &canvas{width: width, height: height, pixels: make([]byte, width*height*4)}
+}
+
+// fill fills a rectangle with a color, clipping it to the canvas.
+func (c *canvas) fill(x, y, w, h int, col color) {
+	for py := y; py < y+h; py++ {
+		if py < 0 || py >= c.height {
+			continue
+		}
+		row := (c.height - 1 - py) * c.width * 4
+		for px := x; px < x+w; px++ {
+			if px < 0 || px >= c.width {
+				continue
+			}
+			i := row + px*4
+			c.pixels[i], c.pixels[i+1], c.pixels[i+2], c.pixels[i+3] = col[0], col[1], col[2], 0xff
+		}
+	}
+}
+
+// text draws a line of text in the overlay's font, a pixel to a pixel, with its top left at x, y.
+func (c *canvas) text(x, y int, text string, col color) {
+	for _, r := range text {
+		glyph, ok := overlayFont[r]
+		if !ok {
+			glyph = overlayFont['?']
+		}
+		for row := 0; row < 5; row++ {
+			for bit := 0; bit < 3; bit++ {
+				if glyph[row]&(0b100>>uint(bit)) != 0 {
+					c.fill(x+bit, y+row, 1, 1, col)
+				}
+			}
+		}
+		x += 4
+	}
+}
@@ -0,0 +1,73 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/watch"
+)
+
+// romWatcher starts the ROM over from the top whenever its file changes, with
+// -watch: save (or assemble) the ROM, and there it is, running. It follows the ROM
+// around, so loading another one from the menu or the playlist watches that one
+// instead.
+type romWatcher struct {
+	menu *menu
+	osd  *onScreenDisplay
+	c8   *cpu.Chip8
+	// watcher is watching path, if it's watching anything.
+	watcher *watch.Watcher
+	path    string
+}
+
+// update reloads the ROM if it's changed, and moves on to watching another one if
+// that's what's loaded now. Call it once a frame, on the main thread.
+func (r *romWatcher) update() {
+	if r.menu.romPath != r.path {
+		r.follow(r.menu.romPath)
+	}
+	if r.watcher == nil {
+		return
+	}
+	select {
+	case <-r.watcher.Changes():
+	default:
+		return
+	}
+	if err := r.menu.load(r.path); err != nil {
+		log.Printf("reloading %s: %v", r.path, err)
+		r.osd.showToast("couldn't reload " + romLabel(r.path))
+	} else {
+		r.osd.showToast("reloaded " + romLabel(r.path))
+	}
+	// if the new one didn't load, the old one carries on where it was.
+	go resume(r.c8)
+}
+
+// follow stops watching the old ROM, and starts watching the one at path.
+func (r *romWatcher) follow(path string) {
+	r.close()
+	r.path = path
+	if strings.HasPrefix(path, tutorialPrefix) {
+		// tutorials come from inside the emulator, and there's no changing them.
+		return
+	}
+	w, err := watch.New(path)
+	if err != nil {
+		log.Printf("can't watch %s for changes: %v", path, err)
+		return
+	}
+	r.watcher = w
+}
+
+// close stops watching.
+func (r *romWatcher) close() {
+	if r.watcher != nil {
+		r.watcher.Close()
+		r.watcher = nil
+	}
+}
@@ -0,0 +1,365 @@
+// Package remote splits the emulator in two over the network: the Chip8 runs in one
+// process (say, on a big headless box), and a thin display somewhere else shows its
+// screen, plays its beeps, and sends back its key presses.
+//
+// The protocol is tiny. The server starts by sending "C8RD" and a version byte; after
+// that, both sides send messages, each one a type byte followed by a fixed-size body:
+//
+//	server to display:
+//	  'F'  256 bytes   a frame: the video memory, 8 bytes to a row, highest bit leftmost
+//	  'S'  1 byte      the sound: 1 while the beep should play, 0 when it should stop
+//	display to server:
+//	  'K'  2 bytes     the keys held down, as a big-endian bitmask, bit n for key n
+//
+// Frames are only ever sent when there's a new one, and a display that can't keep up
+// skips frames rather than falling behind.
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+const (
+	magic   = "C8RD"
+	version = 1
+
+	msgFrame = 'F'
+	msgSound = 'S'
+	msgKeys  = 'K'
+)
+
+// ErrNotDisplayServer is returned by Dial when whatever's on the other end isn't a Server.
+var ErrNotDisplayServer = errors.New("remote: not a Chip8 display server")
+
+// Server sends the Chip8's screen and sound to remote displays, and collects their
+// key presses. It's a cpu.Keyboard and a cpu.Speaker, so it can be given to cpu.NewChip8
+// as both; and whoever reads frames from the Chip8 passes them on with PublishFrame.
+//
+// It's a cpu.KeyEventSource too, so the Chip8 sees every key that's held down, on
+// every display, rather than polling for just one: holding a direction and fire
+// at once works, and so does two people holding keys on two displays.
+type Server struct {
+	// keys is the keys held down on any display, bit n for key n: each display's
+	// keys, ORed together. It's only changed with mu held, but the CPU polls it
+	// from its own goroutine, so only touch it through sync/atomic.
+	keys uint32
+	// events gets a press or a release whenever keys changes. If nothing's reading
+	// them, the oldest make way rather than hold the displays up.
+	events chan cpu.KeyEvent
+
+	mu       sync.Mutex
+	frame    cpu.Frame
+	sound    bool
+	displays map[*display]struct{}
+}
+
+// display is one connected display, and what's waiting to be sent to it.
+type display struct {
+	// keys is the keys held down on this display. It belongs to the Server's mu.
+	keys uint16
+
+	mu    sync.Mutex
+	frame cpu.Frame
+	sound bool
+	// pending is a bitmask of the messages waiting to be sent.
+	pending byte
+	wake    chan struct{}
+}
+
+const (
+	pendingFrame = 1 << iota
+	pendingSound
+)
+
+// keyEventBuffer is how many key events can wait for the Chip8 to get to them.
+const keyEventBuffer = 64
+
+// NewServer returns a Server with nobody connected. Call Serve to let displays connect.
+func NewServer() *Server {
+	return &Server{displays: make(map[*display]struct{}), events: make(chan cpu.KeyEvent, keyEventBuffer)}
+}
+
+// Serve accepts displays on listener until it's closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveDisplay(conn)
+	}
+}
+
+func (s *Server) serveDisplay(conn net.Conn) {
+	defer conn.Close()
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+	d := &display{wake: make(chan struct{}, 1)}
+	s.mu.Lock()
+	// start the display off with what's on screen and whether it's beeping now.
+	d.post(pendingFrame|pendingSound, s.frame, s.sound)
+	s.displays[d] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.displays, d)
+		// whatever this display was holding down, it isn't anymore -- but
+		// whatever the others are, they still are.
+		s.updateKeys()
+	}()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		r := bufio.NewReader(conn)
+		for {
+			var msg [3]byte
+			if _, err := io.ReadFull(r, msg[:]); err != nil || msg[0] != msgKeys {
+				return
+			}
+			s.mu.Lock()
+			d.keys = binary.BigEndian.Uint16(msg[1:])
+			s.updateKeys()
+			s.mu.Unlock()
+		}
+	}()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString(magic)
+	w.WriteByte(version)
+	for {
+		d.mu.Lock()
+		pending, frame, sound := d.pending, d.frame, d.sound
+		d.pending = 0
+		d.mu.Unlock()
+		if pending&pendingSound != 0 {
+			var on byte
+			if sound {
+				on = 1
+			}
+			w.Write([]byte{msgSound, on})
+		}
+		if pending&pendingFrame != 0 {
+			w.WriteByte(msgFrame)
+			w.Write(frame[:])
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-d.wake:
+		case <-gone:
+			return
+		}
+	}
+}
+
+// post queues messages for the display, replacing anything of the same kind
+// that hasn't been sent yet.
+func (d *display) post(pending byte, frame cpu.Frame, sound bool) {
+	d.mu.Lock()
+	d.pending |= pending
+	d.frame = frame
+	d.sound = sound
+	d.mu.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) broadcast(pending byte) {
+	for d := range s.displays {
+		d.post(pending, s.frame, s.sound)
+	}
+}
+
+// PublishFrame sends a frame to every display.
+func (s *Server) PublishFrame(frame cpu.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frame = frame
+	s.broadcast(pendingFrame)
+}
+
+// StartSound starts the beep on every display.
+func (s *Server) StartSound() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sound = true
+	s.broadcast(pendingSound)
+}
+
+// StopSound stops the beep on every display.
+func (s *Server) StopSound() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sound = false
+	s.broadcast(pendingSound)
+}
+
+// updateKeys works out which keys are held down on any display, now that one's
+// changed, pressing and releasing whichever keys that changes. s.mu must be held.
+func (s *Server) updateKeys() {
+	var keys uint32
+	for d := range s.displays {
+		keys |= uint32(d.keys)
+	}
+	old := atomic.SwapUint32(&s.keys, keys)
+	changed := old ^ keys
+	now := time.Now()
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if changed&(1<<key) != 0 {
+			s.sendKeyEvent(cpu.KeyEvent{Code: key, Pressed: keys&(1<<key) != 0, Timestamp: now})
+		}
+	}
+}
+
+// sendKeyEvent sends an event without waiting, throwing the oldest away if it has to.
+func (s *Server) sendKeyEvent(event cpu.KeyEvent) {
+	for {
+		select {
+		case s.events <- event:
+			return
+		default:
+		}
+		select {
+		case <-s.events:
+		default:
+		}
+	}
+}
+
+// KeyEvents returns the keys pressed and released, on any display.
+func (s *Server) KeyEvents() <-chan cpu.KeyEvent {
+	return s.events
+}
+
+// Poll returns the lowest key held down on a display, for anyone who'd rather
+// poll than take KeyEvents.
+func (s *Server) Poll() cpu.KeyCode {
+	keys := atomic.LoadUint32(&s.keys)
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		if keys&(1<<key) != 0 {
+			return key
+		}
+	}
+	return cpu.KeyNone
+}
+
+// Client is a connection to a Server, from a display.
+type Client struct {
+	conn net.Conn
+	// frames gets the latest frame; a frame nobody picked up yet is replaced by the next.
+	frames chan cpu.Frame
+	// sound is 1 while the beep should be playing. Only touch it through sync/atomic.
+	sound uint32
+	// err is why the connection ended, once it has.
+	err  error
+	done chan struct{}
+}
+
+// Dial connects to the Server at addr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+	r := bufio.NewReader(conn)
+	var hello [len(magic) + 1]byte
+	if _, err := io.ReadFull(r, hello[:]); err != nil || string(hello[:len(magic)]) != magic {
+		conn.Close()
+		return nil, ErrNotDisplayServer
+	}
+	if hello[len(magic)] != version {
+		conn.Close()
+		return nil, fmt.Errorf("remote: the server speaks version %d of the protocol; we speak %d", hello[len(magic)], version)
+	}
+	c := &Client{conn: conn, frames: make(chan cpu.Frame, 1), done: make(chan struct{})}
+	go c.receive(r)
+	return c, nil
+}
+
+func (c *Client) receive(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		kind, err := r.ReadByte()
+		if err != nil {
+			c.err = err
+			return
+		}
+		switch kind {
+		case msgFrame:
+			var frame cpu.Frame
+			if _, err := io.ReadFull(r, frame[:]); err != nil {
+				c.err = err
+				return
+			}
+			// swap out a frame the display hasn't got to yet.
+			select {
+			case <-c.frames:
+			default:
+			}
+			c.frames <- frame
+		case msgSound:
+			on, err := r.ReadByte()
+			if err != nil {
+				c.err = err
+				return
+			}
+			atomic.StoreUint32(&c.sound, uint32(on))
+		default:
+			c.err = fmt.Errorf("remote: unknown message %q", kind)
+			return
+		}
+	}
+}
+
+// Frames returns a channel that gets each new frame from the server.
+func (c *Client) Frames() <-chan cpu.Frame {
+	return c.frames
+}
+
+// Beeping reports whether the beep should be playing right now.
+func (c *Client) Beeping() bool {
+	return atomic.LoadUint32(&c.sound) == 1
+}
+
+// SendKeys tells the server which keys are held down, as a bitmask with bit n for key n.
+func (c *Client) SendKeys(keys uint16) error {
+	msg := []byte{msgKeys, 0, 0}
+	binary.BigEndian.PutUint16(msg[1:], keys)
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// Done is closed when the connection ends; Err says why.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, once Done is closed.
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Close hangs up.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,114 @@
+package remote_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/remote"
+)
+
+// Server / Dial
+// should send the display the current frame, then new frames and the sound
+// should pass the display's keys back to the Chip8
+func TestRemoteDisplay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := remote.NewServer()
+	go server.Serve(listener)
+	defer listener.Close()
+
+	var first, second [256]byte
+	first[0] = 0x80
+	second[255] = 0x01
+	server.PublishFrame(first)
+
+	client, err := remote.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if got := receive(t, client); got != first {
+		t.Errorf("the display didn't get the frame that was already on screen")
+	}
+	server.StartSound()
+	server.PublishFrame(second)
+	if got := receive(t, client); got != second {
+		t.Errorf("the display didn't get the new frame")
+	}
+	if !client.Beeping() {
+		t.Errorf("the display isn't beeping")
+	}
+
+	client.SendKeys(1 << 0xA)
+	deadline := time.Now().Add(time.Second)
+	for server.Poll() != cpu.KeyA && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := server.Poll(); got != cpu.KeyA {
+		t.Errorf("the server sees key %x held down, want a", got)
+	}
+}
+
+// Server.KeyEvents
+// should press every key held down on any display, not just the lowest
+// should only release a display's own keys when it disconnects
+func TestRemoteKeysPerDisplay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := remote.NewServer()
+	go server.Serve(listener)
+	defer listener.Close()
+
+	first, err := remote.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := remote.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var held uint16
+	first.SendKeys(1<<0x5 | 1<<0xA)
+	waitForKeys(t, server, &held, 1<<0x5|1<<0xA)
+	second.SendKeys(1 << 0x1)
+	waitForKeys(t, server, &held, 1<<0x1|1<<0x5|1<<0xA)
+	second.Close()
+	waitForKeys(t, server, &held, 1<<0x5|1<<0xA)
+}
+
+// waitForKeys takes the server's key events, keeping track in held of which keys
+// they say are down, until the keys held are want.
+func waitForKeys(t *testing.T, server *remote.Server, held *uint16, want uint16) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for *held != want {
+		select {
+		case event := <-server.KeyEvents():
+			if event.Pressed {
+				*held |= 1 << event.Code
+			} else {
+				*held &^= 1 << event.Code
+			}
+		case <-timeout:
+			t.Fatalf("the keys held down are %016b, want %016b", *held, want)
+		}
+	}
+}
+
+func receive(t *testing.T, client *remote.Client) [256]byte {
+	select {
+	case frame := <-client.Frames():
+		return frame
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a frame")
+		return [256]byte{}
+	}
+}
@@ -1,9 +1,13 @@
+//go:build cgo
+// +build cgo
+
 package main
 
 import (
 	"fmt"
 	"github.com/go-gl/gl/v3.2-compatibility/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
 	"io/ioutil"
 	"log"
 	"strings"
@@ -16,6 +20,25 @@ type OpenGLRenderer struct {
 	vao           uint32
 	vertices      []float32
 	eboIndices    []uint32
+
+	// texData is the texture upload buffer; it's allocated once and refilled each frame.
+	texData []byte
+	// pixelBuffers are the two pixel buffer objects we stream texture data through,
+	// taking turns so we never write into a buffer the GPU might still be reading.
+	pixelBuffers [2]uint32
+	nextPBO      int
+	// lastScreen is the screen we last uploaded, so we can skip uploading it again.
+	lastScreen cpu.ColorFrame
+
+	// overlay is text (and maybe a dimmed background) drawn on top of the screen.
+	overlay        *textOverlay
+	overlayTexture uint32
+
+	// the locations of the shader's palette uniforms.
+	palettedUniform, foregroundUniform, backgroundUniform int32
+	plane2Uniform, bothUniform                            int32
+	// palette is the palette the screen's drawn in, so RenderTiles can dim it and put it back.
+	palette palette
 }
 
 func NewOpenGLRenderer(window *glfw.Window) *OpenGLRenderer {
@@ -46,7 +69,7 @@ func (o *OpenGLRenderer) init(w *glfw.Window) {
 	version := gl.GoStr(gl.GetString(gl.VERSION))
 	log.Printf("OpenGL version: %s\n", version)
 
-	gl.Viewport(0, 0, 640, 320)
+	o.fitViewport()
 
 	// FIXME DEBUG ONLY
 	// ------------
@@ -165,8 +188,8 @@ func (o *OpenGLRenderer) init(w *glfw.Window) {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
 
 	// create texture data from initial Chip8 screen
-	var emptyScreen [32][64]bool
-	texData := toTextureData(emptyScreen)
+	o.texData = make([]byte, 64*32)
+	toTextureData(o.texData, o.lastScreen)
 	texWidth := int32(64)
 	texHeight := int32(32)
 	gl.TexImage2D(
@@ -175,16 +198,49 @@ func (o *OpenGLRenderer) init(w *glfw.Window) {
 		gl.R8,         // internal texture format; a subtype of the texture format parameter above. gl.R8 is A single 'red' channel represented by one byte.
 		texWidth,
 		texHeight,
-		0,                // fun fact, this parameter apparently is 'border', and it must always be 0 or else!
-		gl.RED,           // texture format; gl.RED is a single red channel.
-		gl.UNSIGNED_BYTE, // type; gl.R8 requires an unsigned byte. Some other internal texture formats can have different types, that's why this is here.
-		gl.Ptr(texData),  // last but not least, the pixel data of the texture
+		0,                 // fun fact, this parameter apparently is 'border', and it must always be 0 or else!
+		gl.RED,            // texture format; gl.RED is a single red channel.
+		gl.UNSIGNED_BYTE,  // type; gl.R8 requires an unsigned byte. Some other internal texture formats can have different types, that's why this is here.
+		gl.Ptr(o.texData), // last but not least, the pixel data of the texture
 	)
 
+	// create the overlay texture. It's RGBA, so we can blend it on top of the screen.
+	o.overlay = newTextOverlay()
+	gl.GenTextures(1, &o.overlayTexture)
+	gl.BindTexture(gl.TEXTURE_2D, o.overlayTexture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, overlayWidth, overlayHeight, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.overlay.pixels))
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	// create the pixel buffer objects we'll upload new frames through. Uploading
+	// from a PBO lets the driver copy the pixels to the texture in the background,
+	// instead of stalling the CPU until the GPU is done with the old texture.
+	gl.GenBuffers(2, &o.pixelBuffers[0])
+	for _, pbo := range o.pixelBuffers {
+		gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, pbo)
+		gl.BufferData(gl.PIXEL_UNPACK_BUFFER, len(o.texData), nil, gl.STREAM_DRAW)
+	}
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+
 	// set our texture uniform in our shader to our texture (NOTE why 0 and not texture id?)
 	gl.UseProgram(o.shaderProgram)
 	texUniform := gl.GetUniformLocation(o.shaderProgram, gl.Str("texture1\000"))
 	gl.Uniform1i(texUniform, 0)
+	o.palettedUniform = gl.GetUniformLocation(o.shaderProgram, gl.Str("paletted\000"))
+	o.foregroundUniform = gl.GetUniformLocation(o.shaderProgram, gl.Str("foreground\000"))
+	o.backgroundUniform = gl.GetUniformLocation(o.shaderProgram, gl.Str("background\000"))
+	o.plane2Uniform = gl.GetUniformLocation(o.shaderProgram, gl.Str("plane2\000"))
+	o.bothUniform = gl.GetUniformLocation(o.shaderProgram, gl.Str("both\000"))
+	o.SetPalette(palettes[0])
+
+	// we only ever draw one thing, so bind everything we need to draw it once, here,
+	// and leave it bound instead of binding it all over again every frame.
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, o.screenTexture)
+	gl.BindVertexArray(o.vao)
 	// =====================================
 
 	// 'handle' errors
@@ -193,39 +249,40 @@ func (o *OpenGLRenderer) init(w *glfw.Window) {
 	}
 }
 
-func (o *OpenGLRenderer) Render(screen [32][64]bool) {
+// Render draws the screen, every bit plane of it, in the palette's colors. (A plain
+// black-and-white cpu.Frame is a ColorFrame with nothing in plane 2: cpu.ColorFrame{frame}.)
+func (o *OpenGLRenderer) Render(screen cpu.ColorFrame) {
 
-	gl.ClearColor(0.1, 0.2, 0.1, 1.0)
+	// if the window isn't the same shape as the screen, the rest of it is black bars.
+	o.fitViewport()
+	gl.ClearColor(0, 0, 0, 1.0)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
-	// get the next frame's texture data
-	texData := toTextureData(screen)
-
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, o.screenTexture)
-
-	// replace the current texture with new texture
-	gl.TexSubImage2D(
-		gl.TEXTURE_2D,
-		0,                // mipmap level 0
-		0,                // x offset
-		0,                // y offset
-		64,               // width (in BYTES??)
-		32,               // height (in BYTES??)
-		gl.RED,           // format
-		gl.UNSIGNED_BYTE, // type,
-		gl.Ptr(texData),  // data
-	)
+	// only upload the screen if it changed since the last frame;
+	// otherwise the texture already holds exactly this screen.
+	if screen != o.lastScreen {
+		o.lastScreen = screen
+		o.uploadScreen(screen)
+	}
 
-	// use our screen shader program
-	gl.UseProgram(o.shaderProgram)
 	// draw the vertices in our vertex array object as triangles
-	// containing our screen-sized rectangle
-	gl.BindVertexArray(o.vao)
+	// containing our screen-sized rectangle. (The shader program, texture
+	// and vertex array object are still bound from init.)
 	numVerticesToDraw := int32(6)
+	gl.Uniform1i(o.palettedUniform, 1)
 	gl.DrawElements(gl.TRIANGLES, numVerticesToDraw, gl.UNSIGNED_INT, gl.PtrOffset(0))
 	//gl.DrawArrays(gl.TRIANGLES, 0, numVerticesToDraw)
 
+	// draw the overlay on top, using the same rectangle with the overlay texture instead
+	if !o.overlay.empty() {
+		gl.Enable(gl.BLEND)
+		gl.Uniform1i(o.palettedUniform, 0)
+		gl.BindTexture(gl.TEXTURE_2D, o.overlayTexture)
+		gl.DrawElements(gl.TRIANGLES, numVerticesToDraw, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		gl.BindTexture(gl.TEXTURE_2D, o.screenTexture)
+		gl.Disable(gl.BLEND)
+	}
+
 	// render screen
 	o.window.SwapBuffers()
 
@@ -235,25 +292,174 @@ func (o *OpenGLRenderer) Render(screen [32][64]bool) {
 	}
 }
 
-func toTextureData(screen [32][64]bool) []byte {
-
-	FG_COLOR := byte(0xFF)
-	BG_COLOR := byte(0x0F)
-
-	texData := []byte{}
-	// OpenGL reads texture data from bottom to top
-	for i := len(screen) - 1; i > -1; i-- {
-		for _, px := range screen[i] {
-			var texel byte
-			if px {
-				texel = FG_COLOR
-			} else {
-				texel = BG_COLOR
-			}
-			texData = append(texData, texel)
+// RenderTiles draws several screens at once, tiled across the window in as many
+// columns and rows as makes them biggest -- side by side, for two. The one numbered
+// focus is drawn in the palette, and the rest in a dimmer version of it, so there's
+// no doubt which one's got the keyboard. (With focus -1, they're all in the palette.)
+// The overlay goes on top of everything, in the middle of the window.
+func (o *OpenGLRenderer) RenderTiles(screens []cpu.ColorFrame, focus int) {
+	fbWidth, fbHeight := o.window.GetFramebufferSize()
+	gl.Viewport(0, 0, int32(fbWidth), int32(fbHeight))
+	gl.ClearColor(0, 0, 0, 1.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// there's only the one texture, so every tile gets uploaded every time, and
+	// whatever's in the texture afterwards is no use to Render.
+	o.lastScreen = cpu.ColorFrame{}
+	numVerticesToDraw := int32(6)
+	gl.Uniform1i(o.palettedUniform, 1)
+	for i, screen := range screens {
+		left, top, width, height := tileRect(i, len(screens), fbWidth, fbHeight)
+		gl.Viewport(int32(left), int32(fbHeight-top-height), int32(width), int32(height))
+		if focus >= 0 && i != focus {
+			o.setPaletteUniforms(o.palette.dimmed())
+		}
+		o.uploadScreen(screen)
+		gl.DrawElements(gl.TRIANGLES, numVerticesToDraw, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		if focus >= 0 && i != focus {
+			o.setPaletteUniforms(o.palette)
+		}
+	}
+
+	if !o.overlay.empty() {
+		o.fitViewport()
+		gl.Enable(gl.BLEND)
+		gl.Uniform1i(o.palettedUniform, 0)
+		gl.BindTexture(gl.TEXTURE_2D, o.overlayTexture)
+		gl.DrawElements(gl.TRIANGLES, numVerticesToDraw, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		gl.BindTexture(gl.TEXTURE_2D, o.screenTexture)
+		gl.Disable(gl.BLEND)
+	}
+
+	o.window.SwapBuffers()
+
+	// 'handle' errors
+	if err := gl.GetError(); err != gl.NO_ERROR {
+		panic(err)
+	}
+}
+
+// SetOverlay sets the lines of text drawn on top of the screen, and whether the screen
+// behind them is dimmed. Pass no lines and dim=false to clear the overlay.
+// SetOverlay returns true if the overlay changed, meaning the screen needs to be redrawn.
+func (o *OpenGLRenderer) SetOverlay(lines []string, dim bool) bool {
+	if !o.overlay.set(lines, dim) {
+		return false
+	}
+	gl.BindTexture(gl.TEXTURE_2D, o.overlayTexture)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, overlayWidth, overlayHeight, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.overlay.pixels))
+	gl.BindTexture(gl.TEXTURE_2D, o.screenTexture)
+	return true
+}
+
+// SetPalette sets the colors the screen is drawn in, from the next Render on.
+func (o *OpenGLRenderer) SetPalette(p palette) {
+	o.palette = p
+	o.setPaletteUniforms(p)
+}
+
+// setPaletteUniforms hands the palette's colors to the shader.
+func (o *OpenGLRenderer) setPaletteUniforms(p palette) {
+	gl.Uniform3f(o.foregroundUniform, p.foreground[0], p.foreground[1], p.foreground[2])
+	gl.Uniform3f(o.backgroundUniform, p.background[0], p.background[1], p.background[2])
+	gl.Uniform3f(o.plane2Uniform, p.plane2[0], p.plane2[1], p.plane2[2])
+	gl.Uniform3f(o.bothUniform, p.both[0], p.both[1], p.both[2])
+}
+
+// OverlayLineAt returns which line of the overlay's text is at (x, y) in the window,
+// in screen coordinates like GLFW's cursor position, or -1 if there isn't one there.
+func (o *OpenGLRenderer) OverlayLineAt(x, y float64) int {
+	left, top, width, height := screenRect(o.window.GetSize())
+	if width == 0 || height == 0 {
+		return -1
+	}
+	return o.overlay.lineAt(int((x-float64(left))*overlayWidth/float64(width)), int((y-float64(top))*overlayHeight/float64(height)))
+}
+
+// fitViewport draws the screen as big as it'll go in the window, keeping its shape.
+func (o *OpenGLRenderer) fitViewport() {
+	// the framebuffer can have more pixels than the window has screen coordinates,
+	// on high-DPI displays.
+	fbWidth, fbHeight := o.window.GetFramebufferSize()
+	left, top, width, height := screenRect(fbWidth, fbHeight)
+	// OpenGL counts up from the bottom.
+	gl.Viewport(int32(left), int32(fbHeight-top-height), int32(width), int32(height))
+}
+
+// screenRect fits the 2:1 Chip8 screen into a window width by height, as big as
+// it'll go and centered, and returns where it goes.
+func screenRect(windowWidth, windowHeight int) (left, top, width, height int) {
+	width, height = windowWidth, windowWidth/2
+	if height > windowHeight {
+		width, height = windowHeight*2, windowHeight
+	}
+	return (windowWidth - width) / 2, (windowHeight - height) / 2, width, height
+}
+
+// tileGrid works out how many columns and rows to tile n screens in, in a window
+// width by height, to get them as big as they'll go. When it's a tie, more columns
+// wins: side by side is what people expect.
+func tileGrid(n, windowWidth, windowHeight int) (columns, rows int) {
+	best := -1
+	for c := 1; c <= n; c++ {
+		r := (n + c - 1) / c
+		_, _, width, _ := screenRect(windowWidth/c, windowHeight/r)
+		if width >= best {
+			best, columns, rows = width, c, r
+		}
+	}
+	return columns, rows
+}
+
+// tileRect is screenRect for the i'th of n screens tiled across the window, going
+// left to right and then top to bottom.
+func tileRect(i, n, windowWidth, windowHeight int) (left, top, width, height int) {
+	columns, rows := tileGrid(n, windowWidth, windowHeight)
+	cellWidth, cellHeight := windowWidth/columns, windowHeight/rows
+	left, top, width, height = screenRect(cellWidth, cellHeight)
+	return left + i%columns*cellWidth, top + i/columns*cellHeight, width, height
+}
+
+// uploadScreen streams the screen into the screen texture through the next pixel buffer object.
+func (o *OpenGLRenderer) uploadScreen(screen cpu.ColorFrame) {
+	toTextureData(o.texData, screen)
+
+	pbo := o.pixelBuffers[o.nextPBO]
+	o.nextPBO = (o.nextPBO + 1) % len(o.pixelBuffers)
+
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, pbo)
+	// 'orphan' the buffer's old storage first: if the GPU is still reading it,
+	// the driver hands us fresh storage instead of making us wait.
+	gl.BufferData(gl.PIXEL_UNPACK_BUFFER, len(o.texData), nil, gl.STREAM_DRAW)
+	gl.BufferSubData(gl.PIXEL_UNPACK_BUFFER, 0, len(o.texData), gl.Ptr(o.texData))
+
+	// replace the current texture with new texture. With a pixel unpack buffer bound,
+	// the data 'pointer' is an offset into the buffer instead of a pointer into our memory.
+	gl.TexSubImage2D(
+		gl.TEXTURE_2D,
+		0,                // mipmap level 0
+		0,                // x offset
+		0,                // y offset
+		64,               // width (in BYTES??)
+		32,               // height (in BYTES??)
+		gl.RED,           // format
+		gl.UNSIGNED_BYTE, // type,
+		gl.PtrOffset(0),  // data
+	)
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+}
+
+// toTextureData fills texData (which must be 64*32 bytes long) in place, without
+// allocating, with one texel per pixel of the screen: its color, 0 to 3, spread out over 0 to 255 so
+// that the shader sees it as 0, 1/3, 2/3 or 1. The palette decides what color they come out.
+func toTextureData(texData []byte, screen cpu.ColorFrame) {
+	for y := 0; y < cpu.FrameHeight; y++ {
+		// OpenGL reads texture data from bottom to top
+		row := texData[(cpu.FrameHeight-1-y)*cpu.FrameWidth:]
+		for x := 0; x < cpu.FrameWidth; x++ {
+			row[x] = byte(screen.Color(x, y)) * (0xff / 3)
 		}
 	}
-	return texData
 }
 
 func compileShader(sourceBytes []byte, shaderType uint32) (uint32, error) {
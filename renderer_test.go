@@ -0,0 +1,35 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// toTextureData
+// should turn each pixel's color into a texel, with the rows upside down for OpenGL
+// should do it without allocating, since it runs for every frame drawn
+func TestToTextureData(t *testing.T) {
+	var screen cpu.ColorFrame
+	screen[0].SetPixel(0, 0, true)
+	screen[1].SetPixel(5, 1, true)
+	screen[0].SetPixel(63, 31, true)
+	screen[1].SetPixel(63, 31, true)
+	texData := make([]byte, cpu.FrameWidth*cpu.FrameHeight)
+	toTextureData(texData, screen)
+
+	for _, c := range []struct {
+		x, y int
+		want byte
+	}{{0, 0, 0x55}, {5, 1, 0xaa}, {63, 31, 0xff}, {1, 0, 0}} {
+		if got := texData[(cpu.FrameHeight-1-c.y)*cpu.FrameWidth+c.x]; got != c.want {
+			t.Errorf("the texel for %d, %d is %#x, want %#x", c.x, c.y, got, c.want)
+		}
+	}
+	if allocs := testing.AllocsPerRun(10, func() { toTextureData(texData, screen) }); allocs != 0 {
+		t.Errorf("toTextureData allocated %v times a frame", allocs)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/report"
+)
+
+func init() {
+	commands["repl"] = command{
+		usage: "type instructions, as mnemonics or hex opcodes, and see what each one does to a Chip8",
+		run:   replCommand,
+	}
+}
+
+const replHelp = `Type an instruction, like LD V0,5 or 6005, and it's executed right away: it's
+written into memory at PC and run, so what you type builds up a program as you go.
+After each one you get the registers and the screen.
+
+  :step       run the instruction already at PC, instead of typing one
+  :run ADDR   run the program until it gets to ADDR (in hex), without explaining
+              every instruction on the way: run to cursor
+  :drw        walk through every DRW from now on a row at a time, to see how sprites
+              get drawn (:drw again to stop)
+  :key X      hold down key X (0 to F) until further notice; :key on its own lets go
+  :set R N    put N (in hex) in register R: V0 to VF, I, PC, SP, DT or ST
+  :reset      start again with a fresh Chip8 (and the ROM, if there was one)
+  :help       this
+  :quit       leave (so does Ctrl-D)
+`
+
+// replCommand runs the REPL: a Chip8 that runs one instruction at a time, as you type them.
+func replCommand(args []string) error {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 repl [rom.ch8]\n\n%s", replHelp)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var rom []byte
+	if flags.NArg() == 1 {
+		var err error
+		if rom, err = readROM(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	r := &repl{rom: rom, in: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	if err := r.reset(); err != nil {
+		return err
+	}
+	fmt.Fprint(r.out, replHelp)
+	r.show()
+	for {
+		fmt.Fprint(r.out, "\nchip8> ")
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return r.in.Err()
+		}
+		if quit := r.do(r.in.Text()); quit {
+			return nil
+		}
+	}
+}
+
+// repl is the state of a REPL session.
+type repl struct {
+	c8       *cpu.Chip8
+	keyboard *replKeyboard
+	rom      []byte
+	in       *bufio.Scanner
+	out      io.Writer
+	// drawDiagrams is set by :drw.
+	drawDiagrams bool
+}
+
+// reset starts over with a fresh Chip8, which explains each instruction as it runs it.
+func (r *repl) reset() error {
+	r.keyboard = &replKeyboard{key: cpu.KeyNone}
+	r.c8 = cpu.NewChip8(r.keyboard, silentSpeaker{})
+	r.c8.SetLogLevel(cpu.LogExplanations)
+	r.c8.SetLogOutput(&unprefixedWriter{w: r.out})
+	return r.c8.Load(r.rom)
+}
+
+// do does what one line of input says, and returns true if it says to quit.
+func (r *repl) do(line string) bool {
+	if semicolon := strings.Index(line, ";"); semicolon >= 0 {
+		line = line[:semicolon]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return false
+	}
+	if strings.HasPrefix(line, ":") {
+		return r.command(strings.Fields(line[1:]))
+	}
+
+	opcode, err := parseOpcode(line)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return false
+	}
+	pc := r.c8.Snapshot().PC
+	_, err = r.c8.LoadMemory(bytes.NewReader([]byte{byte(opcode >> 8), byte(opcode)}), pc, cpu.DumpBinary)
+	if err != nil {
+		fmt.Fprintf(r.out, "there's no room for an instruction at PC (%#03x): %v\n", pc, err)
+		return false
+	}
+	r.step()
+	return false
+}
+
+func (r *repl) command(fields []string) bool {
+	if len(fields) == 0 {
+		fields = []string{"help"}
+	}
+	switch fields[0] {
+	case "step", "s":
+		r.step()
+	case "run", "r":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :run ADDR, like :run 2a4")
+			break
+		}
+		addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(fields[1]), "0x"), 16, 16)
+		if err != nil {
+			fmt.Fprintf(r.out, "%q isn't a hex address\n", fields[1])
+			break
+		}
+		r.runUntil(uint16(addr))
+	case "drw", "d":
+		r.drawDiagrams = !r.drawDiagrams
+		if r.drawDiagrams {
+			fmt.Fprintln(r.out, "walking through DRWs")
+		} else {
+			fmt.Fprintln(r.out, "not walking through DRWs any more")
+		}
+	case "key", "k":
+		if len(fields) == 1 {
+			r.keyboard.key = cpu.KeyNone
+			fmt.Fprintln(r.out, "no keys held")
+			break
+		}
+		key, err := strconv.ParseUint(fields[1], 16, 4)
+		if err != nil {
+			fmt.Fprintf(r.out, "%q isn't a key; keys go from 0 to F\n", fields[1])
+			break
+		}
+		r.keyboard.key = cpu.KeyCode(key)
+		fmt.Fprintf(r.out, "holding key %X\n", key)
+	case "set":
+		if len(fields) != 3 {
+			fmt.Fprintln(r.out, "usage: :set R N, like :set V3 2a")
+			break
+		}
+		reg, err := cpu.ParseRegister(fields[1])
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			break
+		}
+		value, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(fields[2]), "0x"), 16, 16)
+		if err != nil {
+			fmt.Fprintf(r.out, "%q isn't a hex number\n", fields[2])
+			break
+		}
+		if err := r.c8.SetRegister(reg, uint16(value)); err != nil {
+			fmt.Fprintln(r.out, err)
+			break
+		}
+		r.show()
+	case "reset":
+		if err := r.reset(); err != nil {
+			fmt.Fprintln(r.out, err)
+			break
+		}
+		r.show()
+	case "help", "h", "?":
+		fmt.Fprint(r.out, replHelp)
+	case "quit", "q", "exit":
+		return true
+	default:
+		fmt.Fprintf(r.out, "don't know :%s; try :help\n", fields[0])
+	}
+	return false
+}
+
+// step runs the instruction at PC, which explains itself, and shows what it did.
+func (r *repl) step() {
+	state := r.c8.Snapshot()
+	opcode := uint16(state.Memory[state.PC])<<8 | uint16(state.Memory[(int(state.PC)+1)%len(state.Memory)])
+	if r.drawDiagrams && opcode&0xf000 == 0xd000 {
+		r.walkThroughDraw(cpu.TraceDraw(state, opcode))
+	}
+	r.c8.StepN(1)
+	r.show()
+}
+
+// runUntil runs the program until it gets to addr, for :run, and says how it went.
+// Explaining a few thousand instructions would scroll the interesting bit away, so
+// they go by quietly.
+func (r *repl) runUntil(addr uint16) {
+	r.c8.SetLogLevel(cpu.LogNone)
+	stats := r.c8.RunUntil(addr)
+	r.c8.SetLogLevel(cpu.LogExplanations)
+	switch stats.Reason {
+	case cpu.StopBreakpoint:
+		fmt.Fprintf(r.out, "got to %03x after %d instructions\n", addr, stats.Instructions)
+	case cpu.StopHalted:
+		fmt.Fprintf(r.out, "gave up after %d instructions without getting to %03x; it's at %03x\n", stats.Instructions, addr, stats.PC)
+	case cpu.StopError:
+		fmt.Fprintf(r.out, "stopped at %03x after %d instructions: %v\n", stats.PC, stats.Instructions, stats.Err)
+	default:
+		fmt.Fprintf(r.out, "stopped at %03x after %d instructions: %v\n", stats.PC, stats.Instructions, stats.Reason)
+	}
+	r.show()
+}
+
+// walkThroughDraw shows what a DRW does to each row of the screen, waiting for
+// Enter between rows. It's the comment in drawSprite, animated.
+func (r *repl) walkThroughDraw(rows []cpu.DrawRow) {
+	fmt.Fprintf(r.out, "DRW, a row at a time: Enter for the next row, or q and Enter to skip to the end.\n")
+	for i, row := range rows {
+		fmt.Fprintf(r.out, "\nrow %d of %d: sprite byte %08b goes at x=%d, y=%d\n", i+1, len(rows), row.Sprite, row.X, row.Y)
+		if row.Right < 0 {
+			fmt.Fprintf(r.out, "x is a multiple of 8, so it lines up with byte %d of the screen row all by itself.\n", row.Left)
+		} else {
+			fmt.Fprintf(r.out, "x%%8 is %d, so it's split across bytes %d and %d of the screen row:\n", row.X%8, row.Left, row.Right)
+			fmt.Fprintf(r.out, "  spriteLeftByte  = %08b >> %d = %08b\n", row.Sprite, row.X%8, row.SpriteLeft)
+			fmt.Fprintf(r.out, "  spriteRightByte = %08b << %d = %08b\n", row.Sprite, 8-row.X%8, row.SpriteRight)
+		}
+		fmt.Fprint(r.out, drawRowDiagram(row))
+		if row.Collided {
+			fmt.Fprintln(r.out, "A pixel that was on got turned off: that's a collision.")
+		}
+		if i == len(rows)-1 {
+			break
+		}
+		if !r.in.Scan() || strings.TrimSpace(r.in.Text()) == "q" {
+			break
+		}
+	}
+	fmt.Fprintln(r.out)
+}
+
+// drawRowDiagram lays out a DrawRow like the diagram in drawSprite's comment: the
+// sprite's halves above the bytes of the screen row they're XORed into, and the result.
+func drawRowDiagram(row cpu.DrawRow) string {
+	var b strings.Builder
+	line := func(label string, bytes func(i int) string) {
+		fmt.Fprintf(&b, "  %-11s|", label)
+		for i := 0; i < 8; i++ {
+			b.WriteString(bytes(i) + "|")
+		}
+		b.WriteString("\n")
+	}
+	line("pixels", func(i int) string { return fmt.Sprintf("%-4d%4d", i*8, i*8+7) })
+	line("byte", func(i int) string { return fmt.Sprintf("   %d    ", i) })
+	line("sprite", func(i int) string {
+		switch i {
+		case row.Left:
+			return fmt.Sprintf("%08b", row.SpriteLeft)
+		case row.Right:
+			return fmt.Sprintf("%08b", row.SpriteRight)
+		}
+		return "        "
+	})
+	line("screen row", func(i int) string { return fmt.Sprintf("%08b", row.Before[i]) })
+	line("XOR", func(i int) string { return fmt.Sprintf("%08b", row.After[i]) })
+	return b.String()
+}
+
+// parseOpcode reads an instruction typed as a hex opcode, like 6005 or 0x6005,
+// or in assembly language, like LD V0,5.
+func parseOpcode(s string) (uint16, error) {
+	hex := strings.TrimPrefix(strings.ToLower(s), "0x")
+	if len(hex) == 4 {
+		if opcode, err := strconv.ParseUint(hex, 16, 16); err == nil {
+			return uint16(opcode), nil
+		}
+	}
+	return asm.Instruction(s)
+}
+
+// stackEntry returns the nth address on the stack, or -1 if it isn't that deep.
+func stackEntry(stack []byte, n int) int {
+	if 2*n+1 >= len(stack) {
+		return -1
+	}
+	return int(stack[2*n])<<8 | int(stack[2*n+1])
+}
+
+// show prints the registers and the screen.
+func (r *repl) show() {
+	state := r.c8.Snapshot()
+	var b strings.Builder
+	for i, v := range state.V {
+		fmt.Fprintf(&b, "V%X=%02x ", i, v)
+		if i == 7 {
+			b.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&b, "\nPC=%03x  I=%03x  DT=%02x  ST=%02x  stack:", state.PC, state.I, state.DT, state.ST)
+	for i := 0; stackEntry(state.Stack, i) >= 0; i++ {
+		fmt.Fprintf(&b, " %03x", stackEntry(state.Stack, i))
+	}
+	if r.keyboard.key != cpu.KeyNone {
+		fmt.Fprintf(&b, "  holding key %X", r.keyboard.key)
+	}
+	b.WriteString("\n")
+	if state.Plane2 == [256]byte{} {
+		b.WriteString(report.TextScreen(state.VideoMemory))
+	} else {
+		// something's been drawn on the second plane, so it'll take colors to show it.
+		screen := cpu.ColorFrame{1: state.Plane2}
+		copy(screen[0][:], state.VideoMemory)
+		b.WriteString(report.ColorTextScreen(screen))
+	}
+	fmt.Fprint(r.out, b.String())
+}
+
+// replKeyboard is a keypad you hold keys down on with :key.
+type replKeyboard struct {
+	key cpu.KeyCode
+}
+
+func (k *replKeyboard) Poll() cpu.KeyCode {
+	return k.key
+}
+
+// unprefixedWriter cuts the Chip8 log's "chip8:" and timestamp off each line, since
+// they don't mean much when the instruction was run the moment you pressed Enter.
+type unprefixedWriter struct {
+	w io.Writer
+}
+
+func (u *unprefixedWriter) Write(p []byte) (int, error) {
+	line := p
+	if space := bytes.IndexByte(line, ' '); space >= 0 {
+		line = line[space+1:]
+	}
+	if _, err := u.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
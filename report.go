@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/report"
+)
+
+func init() {
+	commands["report"] = command{
+		usage: "run a ROM for a while and write up what it did, in Markdown or HTML",
+		run:   reportCommand,
+	}
+}
+
+func reportCommand(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	cycles := flags.Int("cycles", 2000, "number of instructions to run")
+	screenshots := flags.Int("screenshots", 0, "instructions between screenshots (default a quarter of -cycles)")
+	registers := flags.Int("registers", 0, "instructions between samples of the registers (default a twentieth of -cycles)")
+	speed := flags.Int("speed", cpu.DefaultSpeed, "instructions per second, for saying when things happened")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 report [flags] rom.ch8 output.md|output.html\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	opts := report.Options{Cycles: *cycles, ScreenshotEvery: *screenshots, RegistersEvery: *registers}
+	if opts.ScreenshotEvery == 0 {
+		opts.ScreenshotEvery = *cycles / 4
+	}
+	if opts.RegistersEvery == 0 {
+		opts.RegistersEvery = *cycles / 20
+	}
+
+	rom, err := readROM(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	c8 := cpu.NewChip8(noKeyboard{}, silentSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(*speed)
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+	r := report.Run(c8, filepath.Base(flags.Arg(0)), opts)
+
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(flags.Arg(1))) {
+	case ".html", ".htm":
+		err = r.WriteHTML(out)
+	default:
+		err = r.WriteMarkdown(out)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,215 @@
+// Package report runs a ROM for a while and writes up what it did, in Markdown or HTML:
+// the code it ran and how often, the key moments (the first time it drew, the first
+// time it read the keypad, ...), what the screen looked like along the way, and what
+// the registers did. It's for blog posts and documentation about how games work inside.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// Options says how much to record.
+type Options struct {
+	// Cycles is how many instructions to run.
+	Cycles int
+	// ScreenshotEvery is how many cycles go between screenshots. There's always one at the end.
+	ScreenshotEvery int
+	// RegistersEvery is how many cycles go between samples of the registers.
+	RegistersEvery int
+}
+
+// Report is what a ROM did.
+type Report struct {
+	// Title is what to call it, usually the ROM's file name.
+	Title  string
+	Speed  int
+	Cycles int
+	// Lines are the instructions that ran, in address order.
+	Lines []Line
+	// Moments are the first times the program did each interesting thing, in order.
+	Moments     []Moment
+	Screenshots []Screenshot
+	Registers   []Registers
+}
+
+// A Line is an instruction that ran, and how many times it ran.
+type Line struct {
+	Address uint16
+	Opcode  uint16
+	Count   int
+}
+
+// A Moment is the first time the program did something worth knowing about.
+type Moment struct {
+	Cycle   int
+	Address uint16
+	Opcode  uint16
+	What    string
+}
+
+// A Screenshot is the screen as it was after Cycle cycles.
+type Screenshot struct {
+	Cycle  int
+	Screen cpu.Frame
+}
+
+// Registers are the registers as they were after Cycle cycles.
+type Registers struct {
+	Cycle  int
+	PC, I  uint16
+	V      [16]byte
+	DT, ST byte
+}
+
+// moments are the things worth noticing the first time a program does them, by what
+// the opcode looks like once masked.
+var moments = []struct {
+	mask, match uint16
+	what        string
+}{
+	{0xffff, 0x00e0, "clears the screen"},
+	{0xf000, 0xd000, "draws a sprite"},
+	{0xf000, 0x2000, "calls a subroutine"},
+	{0xf0ff, 0xe09e, "reads the keypad"},
+	{0xf0ff, 0xe0a1, "reads the keypad"},
+	{0xf0ff, 0xf00a, "waits for a key"},
+	{0xf0ff, 0xf015, "sets the delay timer"},
+	{0xf0ff, 0xf018, "makes a sound"},
+	{0xf000, 0xc000, "rolls a random number"},
+	{0xf0ff, 0xf029, "uses the font"},
+	{0xf0ff, 0xf033, "writes a number in decimal"},
+	{0xf0ff, 0xf055, "saves registers to memory"},
+	{0xf0ff, 0xf065, "loads registers from memory"},
+}
+
+// Run runs c8, which should have a ROM loaded and be stopped, and reports on it.
+// Nobody presses any keys.
+func Run(c8 *cpu.Chip8, title string, opts Options) *Report {
+	r := &Report{Title: title, Speed: c8.Speed()}
+	counts := make(map[uint16]*Line)
+	seen := make(map[string]bool)
+	state := c8.Snapshot()
+	for r.Cycles < opts.Cycles {
+		pc := state.PC
+		opcode := uint16(state.Memory[int(pc)%len(state.Memory)])<<8 | uint16(state.Memory[(int(pc)+1)%len(state.Memory)])
+		if opcode == 0x0000 {
+			// the end of the program.
+			break
+		}
+		// StepN rather than Step, which would take two more snapshots and diff them.
+		c8.StepN(1)
+		r.Cycles++
+		state = c8.Snapshot()
+
+		line, ok := counts[pc]
+		if !ok {
+			line = &Line{Address: pc}
+			counts[pc] = line
+		}
+		// the last opcode seen wins, in case the program rewrites itself.
+		line.Opcode = opcode
+		line.Count++
+		for _, m := range moments {
+			if opcode&m.mask == m.match && !seen[m.what] {
+				seen[m.what] = true
+				r.Moments = append(r.Moments, Moment{r.Cycles, pc, opcode, m.what})
+			}
+		}
+		if opts.ScreenshotEvery > 0 && r.Cycles%opts.ScreenshotEvery == 0 {
+			r.screenshot(state)
+		}
+		if opts.RegistersEvery > 0 && r.Cycles%opts.RegistersEvery == 0 {
+			r.sampleRegisters(state)
+		}
+	}
+	// there's always a last look at where it got to.
+	if len(r.Screenshots) == 0 || r.Screenshots[len(r.Screenshots)-1].Cycle != r.Cycles {
+		r.screenshot(state)
+	}
+	if len(r.Registers) == 0 || r.Registers[len(r.Registers)-1].Cycle != r.Cycles {
+		r.sampleRegisters(state)
+	}
+
+	for _, line := range counts {
+		r.Lines = append(r.Lines, *line)
+	}
+	sort.Slice(r.Lines, func(i, j int) bool { return r.Lines[i].Address < r.Lines[j].Address })
+	return r
+}
+
+func (r *Report) screenshot(state cpu.Chip8State) {
+	shot := Screenshot{Cycle: r.Cycles}
+	copy(shot.Screen[:], state.VideoMemory)
+	r.Screenshots = append(r.Screenshots, shot)
+}
+
+func (r *Report) sampleRegisters(state cpu.Chip8State) {
+	r.Registers = append(r.Registers, Registers{r.Cycles, state.PC, state.I, state.V, state.DT, state.ST})
+}
+
+// Seconds converts cycles to seconds, at the speed the ROM ran at.
+func (r *Report) Seconds(cycles int) float64 {
+	return float64(cycles) / float64(r.Speed)
+}
+
+// TextScreen draws a screen with text, two rows of pixels to a line of half-block
+// characters, in a box -- for terminals, and for screenshots in plain text.
+func TextScreen(videoMemory []byte) string {
+	pixel := func(x, y int) bool {
+		return videoMemory[y*8+x/8]&(0x80>>uint(x%8)) != 0
+	}
+	var b strings.Builder
+	b.WriteString("┌" + strings.Repeat("─", 64) + "┐\n")
+	for y := 0; y < 32; y += 2 {
+		b.WriteString("│")
+		for x := 0; x < 64; x++ {
+			switch top, bottom := pixel(x, y), pixel(x, y+1); {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("│\n")
+	}
+	b.WriteString("└" + strings.Repeat("─", 64) + "┘\n")
+	return b.String()
+}
+
+// textColors are the ANSI color codes ColorTextScreen draws colors 1 to 3 in, as
+// foreground colors; add 10 for the background. Color 0 is the terminal's own.
+var textColors = [...]int{1: 97, 2: 31, 3: 33}
+
+// ColorTextScreen is TextScreen for screens with more than one bit plane (see
+// cpu.ColorFrame): the same box of half blocks, colored with ANSI escape codes --
+// white for plane 1, red for plane 2, and yellow where they're both on. The upper
+// half of each block is the top pixel's color, and the rest is the bottom's.
+func ColorTextScreen(screen cpu.ColorFrame) string {
+	var b strings.Builder
+	b.WriteString("┌" + strings.Repeat("─", 64) + "┐\n")
+	for y := 0; y < 32; y += 2 {
+		b.WriteString("│")
+		for x := 0; x < 64; x++ {
+			top, bottom := screen.Color(x, y), screen.Color(x, y+1)
+			fg, bg := 39, 49
+			if top != 0 {
+				fg = textColors[top]
+			}
+			if bottom != 0 {
+				bg = textColors[bottom] + 10
+			}
+			fmt.Fprintf(&b, "\x1b[%d;%dm▀", fg, bg)
+		}
+		b.WriteString("\x1b[0m│\n")
+	}
+	b.WriteString("└" + strings.Repeat("─", 64) + "┘\n")
+	return b.String()
+}
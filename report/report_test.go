@@ -0,0 +1,72 @@
+package report_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/report"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// Run
+// should count how often each instruction runs, and notice the first draw
+// should take screenshots and sample registers as often as asked, and at the end
+func TestRun(t *testing.T) {
+	rom, err := asm.Assemble(`
+		CLS
+	loop:
+		ADD V0,1
+		SE V0,3
+		JP loop
+		LD F,V0
+		DRW V1,V1,5
+	end:
+		JP end
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	if err := c8.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	r := report.Run(c8, "test", report.Options{Cycles: 15, ScreenshotEvery: 10, RegistersEvery: 5})
+
+	counts := make(map[uint16]int)
+	for _, line := range r.Lines {
+		counts[line.Address] = line.Count
+	}
+	if counts[0x202] != 3 || counts[0x206] != 2 || counts[0x20c] != 4 {
+		t.Errorf("got counts %v", counts)
+	}
+	var moments []string
+	for _, m := range r.Moments {
+		moments = append(moments, m.What)
+	}
+	if got := strings.Join(moments, ", "); got != "clears the screen, uses the font, draws a sprite" {
+		t.Errorf("got moments %q", got)
+	}
+	if len(r.Screenshots) != 2 || r.Screenshots[1].Cycle != 15 || len(r.Registers) != 3 {
+		t.Errorf("got %d screenshots and %d register samples, want 2 and 3", len(r.Screenshots), len(r.Registers))
+	}
+
+	var md, html bytes.Buffer
+	if err := r.WriteMarkdown(&md); err != nil || !strings.Contains(md.String(), "| `208` | `f029` | `LD F,V0` | 1 |") {
+		t.Errorf("Markdown came out wrong (%v):\n%s", err, md.String())
+	}
+	if err := r.WriteHTML(&html); err != nil || !strings.Contains(html.String(), "data:image/png;base64,") {
+		t.Errorf("HTML came out wrong (%v)", err)
+	}
+}
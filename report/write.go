@@ -0,0 +1,148 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// WriteMarkdown writes the report as Markdown. Screenshots are drawn with text, so
+// the report's one file that works anywhere Markdown does.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", r.Title)
+	fmt.Fprintf(bw, "%d instructions at %d a second: %.2f seconds of play, with nobody pressing any keys.\n\n", r.Cycles, r.Speed, r.Seconds(r.Cycles))
+
+	fmt.Fprintf(bw, "## Key moments\n\n")
+	if len(r.Moments) == 0 {
+		fmt.Fprintf(bw, "Nothing much happened.\n\n")
+	}
+	for _, m := range r.Moments {
+		fmt.Fprintf(bw, "- **Cycle %d** (%.2fs): first %s, at `%03x: %s`\n", m.Cycle, r.Seconds(m.Cycle), m.What, m.Address, cpu.Disassemble(m.Opcode))
+	}
+
+	fmt.Fprintf(bw, "\n## Screenshots\n\n")
+	for _, shot := range r.Screenshots {
+		fmt.Fprintf(bw, "After %d cycles (%.2fs):\n\n```\n%s```\n\n", shot.Cycle, r.Seconds(shot.Cycle), TextScreen(shot.Screen[:]))
+	}
+
+	fmt.Fprintf(bw, "## Code\n\nEvery instruction that ran, and how many times it ran.\n\n")
+	fmt.Fprintf(bw, "| Address | Opcode | Instruction | Runs |\n|---|---|---|---:|\n")
+	for i, line := range r.Lines {
+		// a gap in the addresses is code that never ran, or data.
+		if i > 0 && line.Address != r.Lines[i-1].Address+2 {
+			fmt.Fprintf(bw, "| … | | | |\n")
+		}
+		fmt.Fprintf(bw, "| `%03x` | `%04x` | `%s` | %d |\n", line.Address, line.Opcode, cpu.Disassemble(line.Opcode), line.Count)
+	}
+
+	fmt.Fprintf(bw, "\n## Registers\n\n| Cycle | PC | I |")
+	for v := 0; v < 16; v++ {
+		fmt.Fprintf(bw, " V%X |", v)
+	}
+	fmt.Fprintf(bw, " DT | ST |\n|---:|---|---|%s---|---|\n", strings.Repeat("---|", 16))
+	for _, regs := range r.Registers {
+		fmt.Fprintf(bw, "| %d | `%03x` | `%03x` |", regs.Cycle, regs.PC, regs.I)
+		for _, v := range regs.V {
+			fmt.Fprintf(bw, " %02x |", v)
+		}
+		fmt.Fprintf(bw, " %d | %d |\n", regs.DT, regs.ST)
+	}
+	return bw.Flush()
+}
+
+// WriteHTML writes the report as a web page, with the screenshots as pictures.
+// Everything's in the one file.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds":     func(r *Report, cycles int) string { return fmt.Sprintf("%.2fs", r.Seconds(cycles)) },
+	"disassemble": cpu.Disassemble,
+	"hex3":        func(n uint16) string { return fmt.Sprintf("%03x", n) },
+	"hex4":        func(n uint16) string { return fmt.Sprintf("%04x", n) },
+	"byte":        func(n byte) string { return fmt.Sprintf("%02x", n) },
+	"png":         screenshotURL,
+	"gap": func(lines []Line, i int) bool {
+		return i > 0 && lines[i].Address != lines[i-1].Address+2
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+code, td { font-family: monospace; }
+table { border-collapse: collapse; }
+td, th { padding: 0 0.5em; border-bottom: 1px solid #eee; text-align: left; }
+td.n { text-align: right; }
+figure { display: inline-block; margin: 0 1em 1em 0; }
+figure img { width: 256px; image-rendering: pixelated; border: 1px solid #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Cycles}} instructions at {{.Speed}} a second: {{seconds . .Cycles}} of play, with nobody pressing any keys.</p>
+
+<h2>Key moments</h2>
+<ul>
+{{- range .Moments}}
+<li><b>Cycle {{.Cycle}}</b> ({{seconds $ .Cycle}}): first {{.What}}, at <code>{{hex3 .Address}}: {{disassemble .Opcode}}</code></li>
+{{- else}}
+<li>Nothing much happened.</li>
+{{- end}}
+</ul>
+
+<h2>Screenshots</h2>
+{{range .Screenshots -}}
+<figure><img src="{{png .Screen}}" alt="the screen after {{.Cycle}} cycles"><figcaption>after {{.Cycle}} cycles ({{seconds $ .Cycle}})</figcaption></figure>
+{{end}}
+
+<h2>Code</h2>
+<p>Every instruction that ran, and how many times it ran.</p>
+<table>
+<tr><th>Address</th><th>Opcode</th><th>Instruction</th><th>Runs</th></tr>
+{{- range $i, $line := .Lines}}
+{{- if gap $.Lines $i}}
+<tr><td>…</td><td></td><td></td><td></td></tr>
+{{- end}}
+<tr><td>{{hex3 .Address}}</td><td>{{hex4 .Opcode}}</td><td>{{disassemble .Opcode}}</td><td class="n">{{.Count}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Registers</h2>
+<table>
+<tr><th>Cycle</th><th>PC</th><th>I</th>{{range $v, $_ := (index .Registers 0).V}}<th>V{{printf "%X" $v}}</th>{{end}}<th>DT</th><th>ST</th></tr>
+{{- range .Registers}}
+<tr><td class="n">{{.Cycle}}</td><td>{{hex3 .PC}}</td><td>{{hex3 .I}}</td>{{range .V}}<td>{{byte .}}</td>{{end}}<td class="n">{{.DT}}</td><td class="n">{{.ST}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// screenshotURL turns a screen into a PNG, a pixel to a pixel, as a data: URL.
+func screenshotURL(screen cpu.Frame) template.URL {
+	img := image.NewPaletted(image.Rect(0, 0, cpu.FrameWidth, cpu.FrameHeight), color.Palette{color.Black, color.White})
+	for y := 0; y < cpu.FrameHeight; y++ {
+		for x := 0; x < cpu.FrameWidth; x++ {
+			if screen.Pixel(x, y) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
@@ -0,0 +1,62 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// resetKey starts the ROM over, once it's been held down for resetHoldTime: long
+// enough that nobody does it by accident in the middle of a game.
+const resetKey = glfw.KeyBackspace
+
+const resetHoldTime = time.Second
+
+// resetHold keeps track of the reset key being held down, and shows how much
+// longer it has to be. It all happens on the main thread.
+type resetHold struct {
+	osd   *onScreenDisplay
+	reset func()
+	// heldSince is when the reset key went down, or zero while it's up.
+	heldSince time.Time
+	// done is true once this press has reset, so holding on doesn't reset again.
+	done bool
+}
+
+// bindResetKey binds the reset key, which calls reset once it's been held long enough.
+func bindResetKey(input *GLFWKeyboardInput, osd *onScreenDisplay, reset func()) *resetHold {
+	r := &resetHold{osd: osd, reset: reset}
+	input.Describe(keyName(resetKey), "reset, if you hold it")
+	input.OnHotkey(resetKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			r.heldSince, r.done = time.Now(), false
+			return
+		}
+		r.heldSince = time.Time{}
+		r.osd.setIndicator("reset", "")
+	})
+	return r
+}
+
+// update shows how far along the hold is, and resets once it's long enough. Call it
+// once a frame.
+func (r *resetHold) update(now time.Time) {
+	if r.heldSince.IsZero() || r.done {
+		return
+	}
+	held := now.Sub(r.heldSince)
+	if held >= resetHoldTime {
+		r.done = true
+		r.osd.setIndicator("reset", "")
+		r.reset()
+		r.osd.showToast("reset")
+		return
+	}
+	const width = 10
+	filled := int(width * held / resetHoldTime)
+	r.osd.setIndicator("reset", "hold to reset ["+strings.Repeat("#", filled)+strings.Repeat("-", width-filled)+"]")
+}
@@ -0,0 +1,75 @@
+package romid
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DatabaseURL is where the CHIP-8 database is (github.com/chip-8/chip-8-database):
+// the games people have collected over the years, and the ROMs of each, by SHA-1.
+const DatabaseURL = "https://raw.githubusercontent.com/chip-8/chip-8-database/master/database/programs.json"
+
+// A Game is a game in the database.
+type Game struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Release     string   `json:"release"`
+	Authors     []string `json:"authors"`
+	// ROMs are the known versions of it, by SHA-1, in hex.
+	ROMs map[string]ROM `json:"roms"`
+}
+
+// A ROM is one version of a Game.
+type ROM struct {
+	File string `json:"file"`
+	// Platforms are the Chip8s it runs on, best first, in the database's own names
+	// for them, like originalChip8 or superchip.
+	Platforms []string `json:"platforms"`
+	// TickRate is how many instructions it wants to run every sixtieth of a second,
+	// if it says.
+	TickRate int `json:"tickrate"`
+}
+
+// A Database is the CHIP-8 database, or any other file like it.
+type Database struct {
+	games  []Game
+	bySHA1 map[string]int
+}
+
+// ParseDatabase reads the database's programs.json.
+func ParseDatabase(r io.Reader) (*Database, error) {
+	d := &Database{bySHA1: make(map[string]int)}
+	if err := json.NewDecoder(r).Decode(&d.games); err != nil {
+		return nil, fmt.Errorf("the database doesn't make sense: %v", err)
+	}
+	for i, game := range d.games {
+		for sum := range game.ROMs {
+			d.bySHA1[strings.ToLower(sum)] = i
+		}
+	}
+	return d, nil
+}
+
+// Len returns how many games the database knows about.
+func (d *Database) Len() int {
+	return len(d.games)
+}
+
+// Lookup returns the game the ROM with this SHA-1 is, and which version of it.
+func (d *Database) Lookup(sum [sha1.Size]byte) (Game, ROM, bool) {
+	key := hex.EncodeToString(sum[:])
+	i, ok := d.bySHA1[key]
+	if !ok {
+		return Game{}, ROM{}, false
+	}
+	for k, rom := range d.games[i].ROMs {
+		if strings.ToLower(k) == key {
+			return d.games[i], rom, true
+		}
+	}
+	return Game{}, ROM{}, false
+}
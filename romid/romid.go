@@ -0,0 +1,115 @@
+// Package romid works out what a ROM is, from nothing but its bytes: its checksums,
+// for looking it up (see Database), and which Chip8 it was written for -- the
+// original, the SCHIP, or the XO-CHIP -- from the instructions in it.
+//
+// Working that out is guesswork, since a ROM's code and data are all mixed up together
+// and nothing says which is which: Identify reads it two bytes at a time, as if it
+// were all code, and a sprite that happens to look like an SCHIP instruction looks
+// like one. That's why it says what it saw, and where, so you can judge for yourself.
+package romid
+
+import (
+	"crypto/sha1"
+	"hash/crc32"
+)
+
+// programStart is where ROMs are loaded, for saying where things are in them.
+const programStart = 0x200
+
+// maxEvidence is how many of the instructions that gave a ROM away Identify keeps.
+const maxEvidence = 8
+
+// A Platform is which Chip8 a ROM was written for. Each can run everything the
+// ones before it could.
+type Platform int
+
+const (
+	Chip8 Platform = iota
+	SCHIP
+	XOCHIP
+)
+
+func (p Platform) String() string {
+	switch p {
+	case SCHIP:
+		return "schip"
+	case XOCHIP:
+		return "xochip"
+	}
+	return "chip8"
+}
+
+// Evidence is an instruction in a ROM that only a later Platform has.
+type Evidence struct {
+	Address, Opcode uint16
+	Platform        Platform
+}
+
+// An ID is what a ROM is.
+type ID struct {
+	Size  int
+	SHA1  [sha1.Size]byte
+	CRC32 uint32
+	// Platform is the Chip8 the ROM looks like it was written for, and Evidence
+	// (the first few of) the instructions that say so.
+	Platform Platform
+	Evidence []Evidence
+}
+
+// Identify works out what rom is.
+func Identify(rom []byte) ID {
+	id := ID{Size: len(rom), SHA1: sha1.Sum(rom), CRC32: crc32.ChecksumIEEE(rom)}
+	seen := make(map[uint16]bool)
+	for i := 0; i+1 < len(rom); i += 2 {
+		opcode := uint16(rom[i])<<8 | uint16(rom[i+1])
+		p := needs(opcode)
+		if p == Chip8 {
+			continue
+		}
+		if p > id.Platform {
+			id.Platform = p
+		}
+		// the same sprite row a hundred times over doesn't say any more than once.
+		if !seen[opcode] && len(id.Evidence) < maxEvidence {
+			seen[opcode] = true
+			id.Evidence = append(id.Evidence, Evidence{uint16(programStart + i), opcode, p})
+		}
+	}
+	return id
+}
+
+// needs returns the Platform that first had the instruction opcode.
+func needs(opcode uint16) Platform {
+	n, nn := opcode&0xf, opcode&0xff
+	switch opcode >> 12 {
+	case 0x0:
+		switch {
+		case opcode >= 0x00fb && opcode <= 0x00ff, opcode&0xfff0 == 0x00c0 && n != 0:
+			// scroll right and left, exit, low and high resolution, and scroll down.
+			return SCHIP
+		case opcode&0xfff0 == 0x00d0 && n != 0:
+			// scroll up.
+			return XOCHIP
+		}
+	case 0x5:
+		// save and load a range of registers.
+		if n == 2 || n == 3 {
+			return XOCHIP
+		}
+	case 0xd:
+		// 16x16 sprites.
+		if n == 0 {
+			return SCHIP
+		}
+	case 0xf:
+		switch {
+		case nn == 0x30, nn == 0x75, nn == 0x85:
+			// the big font, and the RPL flags.
+			return SCHIP
+		case opcode == 0xf000, nn == 0x01, opcode == 0xf002, nn == 0x3a:
+			// long I, planes, audio, and pitch.
+			return XOCHIP
+		}
+	}
+	return Chip8
+}
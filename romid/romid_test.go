@@ -0,0 +1,65 @@
+package romid_test
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/romid"
+)
+
+// Identify
+// should work out the checksums
+// should say a ROM's for the plainest Chip8 it could run on
+// should say which instructions gave it away, once each
+func TestIdentify(t *testing.T) {
+	plain := []byte{0x60, 0x05, 0xd0, 0x15, 0x12, 0x00}
+	id := romid.Identify(plain)
+	if id.Size != 6 || id.SHA1 != sha1.Sum(plain) || id.CRC32 != crc32.ChecksumIEEE(plain) {
+		t.Errorf("got %+v", id)
+	}
+	if id.Platform != romid.Chip8 || len(id.Evidence) != 0 {
+		t.Errorf("a plain ROM came out %v, because of %v", id.Platform, id.Evidence)
+	}
+
+	// high resolution, then a 16x16 sprite, twice, then a plane.
+	schip := []byte{0x00, 0xff, 0xd0, 0x10, 0xd0, 0x10, 0x12, 0x00}
+	if id := romid.Identify(schip); id.Platform != romid.SCHIP || len(id.Evidence) != 2 || id.Evidence[1].Address != 0x202 {
+		t.Errorf("an SCHIP ROM came out %v, because of %+v", id.Platform, id.Evidence)
+	}
+	xo := append(schip, 0xf2, 0x01)
+	if id := romid.Identify(xo); id.Platform != romid.XOCHIP || id.Evidence[2] != (romid.Evidence{Address: 0x208, Opcode: 0xf201, Platform: romid.XOCHIP}) {
+		t.Errorf("an XO-CHIP ROM came out %v, because of %+v", id.Platform, id.Evidence)
+	}
+}
+
+// Database
+// should find a ROM by its SHA-1, in either case
+// should find nothing for a ROM it hasn't heard of
+func TestDatabase(t *testing.T) {
+	rom := []byte{0x12, 0x00}
+	sum := sha1.Sum(rom)
+	db, err := romid.ParseDatabase(strings.NewReader(`[
+		{"title": "Spin", "authors": ["Somebody"], "release": "2020",
+		 "roms": {"` + strings.ToUpper(fmt.Sprintf("%x", sum)) + `": {"file": "spin.ch8", "platforms": ["originalChip8"], "tickrate": 15}}},
+		{"title": "Other", "roms": {"0000000000000000000000000000000000000000": {}}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 2 {
+		t.Errorf("got %d games, want 2", db.Len())
+	}
+	game, found, ok := db.Lookup(sum)
+	if !ok || game.Title != "Spin" || found.File != "spin.ch8" || found.TickRate != 15 {
+		t.Errorf("got %+v, %+v, %v", game, found, ok)
+	}
+	if _, _, ok := db.Lookup(sha1.Sum([]byte{0x13, 0x00})); ok {
+		t.Error("found a ROM that isn't in the database")
+	}
+	if _, err := romid.ParseDatabase(strings.NewReader(`{"not": "a list"}`)); err == nil {
+		t.Error("parsed a database that isn't a list of games")
+	}
+}
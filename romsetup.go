@@ -0,0 +1,141 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/metadata"
+	"github.com/mpingram/chip8/octo"
+	"github.com/mpingram/chip8/softrender"
+)
+
+// romSetup sets the Chip8 up for each ROM that's loaded, the way its metadata (see
+// the metadata package) says to: its speed, quirks and font, unless they were given
+// on the command line, which beats anything a file says; its keys, macros and how
+// to play it; and its name, in the title. Octo cartridges have their options instead, if
+// they haven't got a metadata file, and their colors.
+type romSetup struct {
+	c8       *cpu.Chip8
+	input    *GLFWKeyboardInput
+	title    *windowTitle
+	osd      *onScreenDisplay
+	renderer *OpenGLRenderer
+	// macros, if there are any, get each ROM's macros.
+	macros *macros
+	// uncolored is the palette from before a cartridge's colors took over, to go
+	// back to for the next ROM that hasn't got colors of its own.
+	uncolored *palette
+	// speed, quirks and font are what the command line said, or the defaults.
+	speed  int
+	quirks cpu.Quirks
+	font   cpu.Font
+	// given is which flags were given on the command line.
+	given map[string]bool
+}
+
+// givenFlags returns which flags were given on the command line, by name.
+func givenFlags() map[string]bool {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	return given
+}
+
+// apply sets the Chip8 up for the ROM at path, which has just been loaded. A ROM
+// without metadata gets what the command line said.
+func (s *romSetup) apply(path string) {
+	var m *metadata.Metadata
+	if !strings.HasPrefix(path, tutorialPrefix) {
+		var err error
+		if m, err = metadata.Read(path); err != nil {
+			log.Print(err)
+		}
+	}
+	var cart *octo.Cartridge
+	if isCartridge(path) {
+		var err error
+		if cart, err = readCartridge(path); err != nil {
+			log.Print(err)
+		}
+	}
+	if m == nil && cart != nil {
+		m = cartridgeMetadata(cart)
+	}
+	if m == nil {
+		m = new(metadata.Metadata)
+	}
+	s.color(cart)
+
+	speed, quirks, font := s.speed, s.quirks, s.font
+	if m.Speed > 0 && !s.given["speed"] {
+		speed = m.Speed
+	}
+	if m.Quirks != nil && !s.given["quirks"] {
+		quirks = *m.Quirks
+	}
+	if m.Font != nil && !s.given["font"] {
+		font = *m.Font
+	}
+	s.c8.SetSpeed(speed)
+	s.c8.SetQuirks(quirks)
+	s.c8.SetFont(font)
+
+	var controls []string
+	if name := m.Name(); name != "" {
+		controls = append(controls, name)
+	}
+	if m.Description != "" {
+		controls = append(controls, m.Description)
+	}
+	s.input.SetGame(gameKeys(path, m.Keys), gameKeys(path, m.Keys2), append(controls, m.Controls...))
+	if s.macros != nil {
+		s.macros.load(path, m.Macros)
+	}
+
+	s.title.rom = romLabel(path)
+	if m.Title != "" {
+		s.title.rom = m.Title
+	}
+	if name := m.Name(); name != "" {
+		s.osd.showToast(name)
+	}
+}
+
+// gameKeys finds the keys named in the metadata for the ROM at path, leaving out
+// (and complaining about) any it can't find.
+func gameKeys(path string, names map[string]cpu.KeyCode) map[glfw.Key]cpu.KeyCode {
+	keys := make(map[glfw.Key]cpu.KeyCode, len(names))
+	for name, code := range names {
+		key, ok := keyByName(name)
+		if !ok {
+			log.Printf("%s: there's no %q key to play with", metadata.Path(path), name)
+			continue
+		}
+		keys[key] = code
+	}
+	return keys
+}
+
+// color draws the screen in the cartridge's colors, if it's a cartridge with colors,
+// and in the colors from before if it isn't.
+func (s *romSetup) color(cart *octo.Cartridge) {
+	if cart != nil {
+		if colors, ok := cart.Options.Colors(); ok {
+			if s.uncolored == nil {
+				before := s.renderer.palette
+				s.uncolored = &before
+			}
+			s.renderer.SetPalette(fromSoftPalette("cartridge", softrender.Palette(colors)))
+			return
+		}
+	}
+	if s.uncolored != nil {
+		s.renderer.SetPalette(*s.uncolored)
+		s.uncolored = nil
+	}
+}
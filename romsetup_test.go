@@ -0,0 +1,31 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/metadata"
+)
+
+// gameKeys
+// should find the keys a metadata file's "keys2" names for the second keypad,
+// the same way as its "keys", and leave out keys there aren't
+func TestGameKeys2(t *testing.T) {
+	m, err := metadata.Parse(strings.NewReader(`{"keys": {"up": "2"}, "keys2": {"i": "2", "k": "8", "nope": "5"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, keys2 := gameKeys("pong.ch8", m.Keys), gameKeys("pong.ch8", m.Keys2)
+	if len(keys) != 1 || keys[glfw.KeyUp] != 0x2 {
+		t.Errorf("got keys %v for keypad 1, want up on 2", keys)
+	}
+	want := map[glfw.Key]cpu.KeyCode{glfw.KeyI: 0x2, glfw.KeyK: 0x8}
+	if len(keys2) != len(want) || keys2[glfw.KeyI] != want[glfw.KeyI] || keys2[glfw.KeyK] != want[glfw.KeyK] {
+		t.Errorf("got keys %v for keypad 2, want %v", keys2, want)
+	}
+}
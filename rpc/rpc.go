@@ -0,0 +1,19 @@
+// Package rpc serves the Chip8 as a gRPC service, defined in chip8.proto: load
+// a ROM, run it, pause it, step it, stream its frames and events, and send it input.
+// It's the strict, typed sibling of the HTTP control API in package control.
+//
+// gRPC is a big dependency for something most people won't use, so the server is
+// only built with the grpc build tag, after generating the protobuf code:
+//
+//	go generate ./rpc
+//	go get google.golang.org/grpc google.golang.org/protobuf
+//	go build -tags grpc
+//
+// and then -grpc serves it, next to (or instead of) the HTTP API.
+//
+// Generating needs protoc, protoc-gen-go and protoc-gen-go-grpc on your PATH.
+package rpc
+
+// the generated code goes where chip8.proto's go_package says, rpc/chip8pb: the
+// module option has protoc write it relative to the top of the module, one up.
+//go:generate protoc --go_out=.. --go_opt=module=github.com/mpingram/chip8 --go-grpc_out=.. --go-grpc_opt=module=github.com/mpingram/chip8 chip8.proto
@@ -0,0 +1,207 @@
+//go:build grpc
+// +build grpc
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/rpc/chip8pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// eventPollInterval is how often StreamEvents checks whether the Chip8 has
+// started or stopped -- once a frame, which is as often as anyone could care.
+const eventPollInterval = time.Second / cpu.FramesPerSecond
+
+// maxStepCount is the most instructions one Step executes, so that a client can't
+// tie the Chip8 up for as long as it likes. Stepping is for looking closely; for
+// getting somewhere, there's Run.
+const maxStepCount = 1000
+
+// Server implements the Emulator service for one Chip8.
+// Register it with chip8pb.RegisterEmulatorServer.
+type Server struct {
+	chip8pb.UnimplementedEmulatorServer
+
+	c8     *cpu.Chip8
+	keypad *control.Keypad
+
+	// romLoads counts the ROMs loaded through the service, so event streams can
+	// tell when there's a new one. Only touch it through sync/atomic.
+	romLoads uint64
+
+	mu       sync.Mutex
+	watchers map[chan cpu.Frame]struct{}
+}
+
+// NewServer returns a Server for c8. Input goes to keypad, which should be
+// c8's keyboard; if it's nil, SendInput is refused.
+func NewServer(c8 *cpu.Chip8, keypad *control.Keypad) *Server {
+	return &Server{c8: c8, keypad: keypad, watchers: make(map[chan cpu.Frame]struct{})}
+}
+
+// PublishFrame sends a frame to every StreamFrames client. Like the HTTP server's
+// PublishFrame, it's up to whoever displays the Chip8 to pass its frames on.
+func (s *Server) PublishFrame(frame cpu.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		// each watcher holds at most one frame; swap a stale one for this one.
+		select {
+		case <-w:
+		default:
+		}
+		w <- frame
+	}
+}
+
+func (s *Server) status() *chip8pb.Status {
+	return &chip8pb.Status{
+		Running: s.c8.IsRunning(),
+		Speed:   int32(s.c8.Speed()),
+		Frame:   s.c8.FrameCount(),
+	}
+}
+
+func (s *Server) LoadROM(ctx context.Context, req *chip8pb.LoadROMRequest) (*chip8pb.Status, error) {
+	if most := s.c8.MemorySize() - 0x200; len(req.Rom) > most {
+		return nil, status.Errorf(codes.InvalidArgument, "ROM is %d bytes; the most that fits is %d", len(req.Rom), most)
+	}
+	wasRunning := s.c8.IsRunning()
+	s.c8.Halt()
+	s.c8.Wait()
+	if err := s.c8.Load(req.Rom); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	atomic.AddUint64(&s.romLoads, 1)
+	if wasRunning {
+		go s.c8.Resume()
+	}
+	return s.status(), nil
+}
+
+func (s *Server) Run(ctx context.Context, req *chip8pb.RunRequest) (*chip8pb.Status, error) {
+	if req.Speed < 0 {
+		return nil, status.Error(codes.InvalidArgument, "speed can't be negative")
+	}
+	if req.Speed > 0 {
+		s.c8.SetSpeed(int(req.Speed))
+	}
+	go s.c8.Resume()
+	return s.status(), nil
+}
+
+func (s *Server) Pause(ctx context.Context, req *chip8pb.PauseRequest) (*chip8pb.Status, error) {
+	s.c8.Halt()
+	s.c8.Wait()
+	return s.status(), nil
+}
+
+func (s *Server) Step(ctx context.Context, req *chip8pb.StepRequest) (*chip8pb.State, error) {
+	count := int(req.Count)
+	if count < 1 {
+		count = 1
+	}
+	if count > maxStepCount {
+		count = maxStepCount
+	}
+	s.c8.StepN(count)
+	state := s.c8.Snapshot()
+	return &chip8pb.State{
+		Pc:     uint32(state.PC),
+		I:      uint32(state.I),
+		V:      state.V[:],
+		Dt:     uint32(state.DT),
+		St:     uint32(state.ST),
+		Stack:  state.Stack,
+		Memory: state.Memory[:],
+	}, nil
+}
+
+func (s *Server) StreamFrames(req *chip8pb.StreamFramesRequest, stream chip8pb.Emulator_StreamFramesServer) error {
+	frames := make(chan cpu.Frame, 1)
+	s.mu.Lock()
+	s.watchers[frames] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, frames)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case frame := <-frames:
+			err := stream.Send(&chip8pb.Frame{Number: s.c8.FrameCount(), Screen: frame[:]})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *Server) StreamEvents(req *chip8pb.StreamEventsRequest, stream chip8pb.Emulator_StreamEventsServer) error {
+	send := func(kind chip8pb.Event_Kind) error {
+		return stream.Send(&chip8pb.Event{Kind: kind, Frame: s.c8.FrameCount()})
+	}
+	running := s.c8.IsRunning()
+	romLoads := atomic.LoadUint64(&s.romLoads)
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+		if loads := atomic.LoadUint64(&s.romLoads); loads != romLoads {
+			romLoads = loads
+			if err := send(chip8pb.Event_ROM_LOADED); err != nil {
+				return err
+			}
+		}
+		if now := s.c8.IsRunning(); now != running {
+			running = now
+			kind := chip8pb.Event_STOPPED
+			if running {
+				kind = chip8pb.Event_STARTED
+			}
+			if err := send(kind); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) SendInput(stream chip8pb.Emulator_SendInputServer) error {
+	if s.keypad == nil {
+		return status.Error(codes.Unimplemented, "this Chip8 doesn't take remote input")
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&chip8pb.SendInputResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		if event.Key > 0xF {
+			return status.Errorf(codes.InvalidArgument, "key %d isn't on the keypad (0 through 15)", event.Key)
+		}
+		if event.Pressed {
+			s.keypad.Press(cpu.KeyCode(event.Key))
+		} else {
+			s.keypad.Release(cpu.KeyCode(event.Key))
+		}
+	}
+}
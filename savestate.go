@@ -0,0 +1,109 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+// slotKeys are the function keys for each slot: F1 is slot 1 and so on.
+var slotKeys = [numSaveSlots]glfw.Key{
+	glfw.KeyF1, glfw.KeyF2, glfw.KeyF3, glfw.KeyF4, glfw.KeyF5,
+	glfw.KeyF6, glfw.KeyF7, glfw.KeyF8, glfw.KeyF9, glfw.KeyF10,
+}
+
+// bindSaveSlotKeys binds Shift+F1..F10 to saving in slots 1..10 and F1..F10 to
+// loading them, confirming each save and load on screen. Ctrl+F1..F10 are for
+// macros (see macros.go), if there's mac to hand them to.
+func bindSaveSlotKeys(input *GLFWKeyboardInput, slots *saveSlots, c8 *cpu.Chip8, osd *onScreenDisplay, mac *macros) {
+	slotRange := keyName(slotKeys[0]) + "-" + keyName(slotKeys[len(slotKeys)-1])
+	input.Describe(slotRange, "load a savestate")
+	input.Describe("shift+"+slotRange, "save a savestate")
+	for i, key := range slotKeys {
+		slot := i + 1
+		input.OnHotkey(key, func(pressed bool, mods glfw.ModifierKey) {
+			if !pressed {
+				return
+			}
+			if mods&glfw.ModControl != 0 {
+				if mac != nil {
+					mac.use(key)
+				}
+				return
+			}
+			if mods&glfw.ModShift != 0 {
+				if err := slots.save(c8, slot); err != nil {
+					log.Printf("saving slot %d: %v", slot, err)
+					osd.showToast(fmt.Sprintf("slot %d: save failed", slot))
+					return
+				}
+				osd.showToast(fmt.Sprintf("saved slot %d", slot))
+				return
+			}
+
+			savedAt, ok := slots.timestamp(slot)
+			if !ok {
+				osd.showToast(fmt.Sprintf("slot %d is empty", slot))
+				return
+			}
+			if err := slots.load(c8, slot); err != nil {
+				log.Printf("loading slot %d: %v", slot, err)
+				osd.showToast(fmt.Sprintf("slot %d: load failed", slot))
+				return
+			}
+			osd.showToast(fmt.Sprintf("loaded slot %d (%s)", slot, savedAt.Format("2006-01-02 15:04")))
+		})
+	}
+}
+
+// offerResume asks the player whether to pick up where they left off last time
+// they played rom, which c8 has already loaded. Y resumes from the autosave and N starts
+// the ROM from the beginning; either way, start is called once they've answered.
+// If there's no autosave for rom, start is called right away.
+func offerResume(input *GLFWKeyboardInput, osd *onScreenDisplay, store storage.Storage, c8 *cpu.Chip8, rom []byte, start func()) {
+	saved, err := loadAutosave(store, rom)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			log.Printf("reading autosave: %v", err)
+		}
+		start()
+		return
+	}
+
+	answered := false
+	answer := func(resume bool) {
+		if answered {
+			return
+		}
+		answered = true
+		osd.clearPrompt()
+		if resume {
+			warnQuirks(c8, saved)
+			if err := c8.LoadState(bytes.NewReader(saved)); err != nil {
+				log.Printf("resuming autosave: %v", err)
+				osd.showToast("couldn't resume, starting over")
+			} else {
+				osd.showToast("resumed")
+			}
+		}
+		start()
+	}
+	osd.showPrompt("resume where you left off?", "", "Y: resume    N: start over")
+	input.OnHotkey(glfw.KeyY, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			answer(true)
+		}
+	})
+	input.OnHotkey(glfw.KeyN, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			answer(false)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+func init() {
+	commands["serve"] = command{
+		usage: "run a ROM as a service, with no window: the HTTP control API and remote displays, saving the game when it's stopped",
+		run:   serveCommand,
+	}
+}
+
+// serveCommand is -headless made for running unattended, under systemd or in a
+// container: it listens on all interfaces by default, takes its token from the
+// environment so it needn't be on the command line for everyone to see, and when
+// it's told to stop (SIGTERM, which is what systemd and docker stop send, or
+// Ctrl-C) it saves the game, closes its connections and exits cleanly -- and picks
+// up where it left off when it's started again. A systemd unit for it looks like:
+//
+//	[Service]
+//	ExecStart=/usr/local/bin/chip8 serve -dir /var/lib/chip8 /var/lib/chip8/game.ch8
+//	Environment=CHIP8_TOKEN=something-secret
+//	Restart=on-failure
+//	DynamicUser=yes
+//	StateDirectory=chip8
+//
+// and since it doesn't need a window, it can be built with CGO_ENABLED=0 into a
+// single static binary for a container with nothing else in it.
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	httpAddr := flags.String("http", ":8080", "serve the HTTP control API on this `address`; \"\" for none")
+	token := flags.String("token", os.Getenv("CHIP8_TOKEN"), "the `token` HTTP clients need (default: $CHIP8_TOKEN, or a random one, printed at startup)")
+	displayAddr := flags.String("display", "", "let remote displays (chip8 view) connect on this `address`, like :7800")
+	speed := flags.Int("speed", cpu.DefaultSpeed, "number of instructions to execute per second")
+	turbo := flags.Bool("turbo", false, "run as fast as possible, without a speed limit")
+	quirkList := flags.String("quirks", "", "make instructions behave like older interpreters did, as a comma-separated `list`: "+strings.Join(cpu.QuirkNames(), ", "))
+	fontName := flags.String("font", cpu.DefaultFont.Name, "draw numbers with this interpreter's `font`: "+strings.Join(cpu.FontNames(), ", "))
+	memory := flags.String("memory", "4k", "how much `memory` the Chip8 has: 4k, or 64k")
+	protectList := flags.String("protect", "", "catch programs writing where they shouldn't, as a comma-separated `list`: interpreter, program, all, stop")
+	dataDir := flags.String("dir", "", "keep the autosave and everything else under `folder` (default: the usual place on this system)")
+	resume := flags.Bool("resume", true, "pick up from the last time the game was stopped, and save it when it's stopped again")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 serve [flags] rom.ch8\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *httpAddr == "" && *displayAddr == "" {
+		return fmt.Errorf("with neither -http nor -display, there'd be no way to see anything")
+	}
+	// journald puts its own timestamps on everything.
+	if os.Getenv("JOURNAL_STREAM") != "" {
+		log.SetFlags(0)
+	}
+
+	romPath := flags.Arg(0)
+	rom, err := readROM(romPath)
+	if err != nil {
+		return err
+	}
+	quirks, err := cpu.ParseQuirks(*quirkList)
+	if err != nil {
+		return err
+	}
+	font, err := cpu.FontByName(*fontName)
+	if err != nil {
+		return err
+	}
+	memorySize, err := cpu.ParseMemorySize(*memory)
+	if err != nil {
+		return err
+	}
+	protection, err := cpu.ParseWriteProtection(*protectList)
+	if err != nil {
+		return err
+	}
+	config := headlessConfig{
+		romPath:     romPath,
+		rom:         rom,
+		store:       openStore(*dataDir),
+		speed:       *speed,
+		turbo:       *turbo,
+		quirks:      quirks,
+		font:        font,
+		memorySize:  memorySize,
+		protection:  protection,
+		httpAddr:    *httpAddr,
+		displayAddr: *displayAddr,
+		autosave:    *resume,
+	}
+	if *httpAddr != "" {
+		config.token = apiToken(*token)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	runHeadless(ctx, config)
+	log.Printf("stopped")
+	return nil
+}
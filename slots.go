@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+// numSaveSlots is the number of savestate slots each ROM gets.
+const numSaveSlots = 10
+
+// saveSlots stores savestates for one ROM in numbered slots, along with the time
+// each slot was saved. Slots are numbered from 1.
+type saveSlots struct {
+	store storage.Storage
+	// prefix is the start of the key of everything stored for this ROM.
+	prefix string
+}
+
+// newSaveSlots returns the save slots for the ROM at romPath.
+// The slots are stored under saves/<ROM name>/.
+func newSaveSlots(store storage.Storage, romPath string) *saveSlots {
+	return &saveSlots{store: store, prefix: "saves/" + romFolder(romPath) + "/"}
+}
+
+// switchROM points s at the save slots for the ROM at romPath, for when that ROM's
+// been loaded into c8 in place of the one s was for. If c8 keeps its RPL flags
+// alongside s (connectRPL), it's connected to the new ROM's flags too, so Fx75
+// and Fx85 don't read and overwrite the old ROM's.
+func (s *saveSlots) switchROM(c8 *cpu.Chip8, romPath string, connectRPL bool) {
+	*s = *newSaveSlots(s.store, romPath)
+	if connectRPL {
+		c8.ConnectRPLFlags(s.rplFlags())
+	}
+}
+
+func (s *saveSlots) key(slot int) string {
+	return fmt.Sprintf("%sslot%d.state", s.prefix, slot)
+}
+
+func (s *saveSlots) timeKey(slot int) string {
+	return fmt.Sprintf("%sslot%d.time", s.prefix, slot)
+}
+
+// save saves the state of c8 in the given slot, replacing whatever was there.
+func (s *saveSlots) save(c8 *cpu.Chip8, slot int) error {
+	var state bytes.Buffer
+	if err := c8.SaveState(&state); err != nil {
+		return err
+	}
+	if err := s.store.Put(s.key(slot), state.Bytes()); err != nil {
+		return err
+	}
+	return s.store.Put(s.timeKey(slot), []byte(time.Now().Format(time.RFC3339)))
+}
+
+// load restores c8 to the state saved in the given slot.
+func (s *saveSlots) load(c8 *cpu.Chip8, slot int) error {
+	state, err := s.store.Get(s.key(slot))
+	if err != nil {
+		return err
+	}
+	warnQuirks(c8, state)
+	return c8.LoadState(bytes.NewReader(state))
+}
+
+// warnQuirks logs it if state was saved with different quirks from the ones c8 has
+// now. It'll still load, but the game may not carry on quite the way it would have.
+func warnQuirks(c8 *cpu.Chip8, state []byte) {
+	header, err := cpu.ReadSavestateHeader(bytes.NewReader(state))
+	if err != nil {
+		return
+	}
+	if now := c8.Quirks(); header.Quirks != now {
+		log.Printf("the savestate was saved with quirks %v, and they're %v now", header.Quirks, now)
+	}
+}
+
+// timestamp returns the time the given slot was last saved,
+// or false if nothing has been saved in it.
+func (s *saveSlots) timestamp(slot int) (time.Time, bool) {
+	data, err := s.store.Get(s.timeKey(slot))
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, string(data))
+	return t, err == nil
+}
+
+// autosaveKey returns where the state is saved when the emulator closes
+// while running rom. Autosaves are keyed by a hash of the ROM's contents,
+// so renaming or moving the ROM file doesn't lose them.
+func autosaveKey(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return "saves/autosave/" + hex.EncodeToString(sum[:]) + ".state"
+}
+
+// autosave saves the state of c8, which is running rom, so it can be resumed next time.
+func autosave(store storage.Storage, c8 *cpu.Chip8, rom []byte) error {
+	var state bytes.Buffer
+	if err := c8.SaveState(&state); err != nil {
+		return err
+	}
+	return store.Put(autosaveKey(rom), state.Bytes())
+}
+
+// loadAutosave returns the state autosaved for rom, or ErrNotFound if there isn't one.
+func loadAutosave(store storage.Storage, rom []byte) ([]byte, error) {
+	return store.Get(autosaveKey(rom))
+}
+
+// storedRPLFlags keeps a ROM's RPL user flags in storage, as the 8 flag bytes.
+type storedRPLFlags struct {
+	store storage.Storage
+	key   string
+}
+
+// rplFlags returns the RPL user flags for the ROM, which are stored alongside its save slots.
+func (s *saveSlots) rplFlags() *storedRPLFlags {
+	return &storedRPLFlags{store: s.store, key: s.prefix + "rpl.flags"}
+}
+
+func (f *storedRPLFlags) Load() [8]byte {
+	var flags [8]byte
+	data, err := f.store.Get(f.key)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			log.Printf("reading RPL flags: %v", err)
+		}
+		return flags
+	}
+	copy(flags[:], data)
+	return flags
+}
+
+func (f *storedRPLFlags) Save(flags [8]byte) {
+	if err := f.store.Put(f.key, flags[:]); err != nil {
+		log.Printf("saving RPL flags: %v", err)
+	}
+}
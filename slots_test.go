@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/storage"
+)
+
+// saveSlots.switchROM
+// should connect the new ROM's RPL flags, so two ROMs loaded one after the other
+// don't read or overwrite each other's
+func TestSwitchROMKeepsRPLFlagsApart(t *testing.T) {
+	c8 := cpu.NewChip8(noKeyboard{}, silentSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	slots := newSaveSlots(storage.NewMemory(), "roms/first.ch8")
+	c8.ConnectRPLFlags(slots.rplFlags())
+
+	// play loads romPath's ROM, which reads flag 0, adds n to it, and saves it again,
+	// and returns the flag.
+	play := func(romPath string, n byte) byte {
+		t.Helper()
+		if err := c8.Load([]byte{
+			0xf0, 0x85, // LD V0 R
+			0x70, n, // ADD V0 n
+			0xf0, 0x75, // LD R V0
+		}); err != nil {
+			t.Fatal(err)
+		}
+		slots.switchROM(c8, romPath, true)
+		c8.StepN(3)
+		return c8.Snapshot().V[0]
+	}
+	if got := play("roms/first.ch8", 1); got != 1 {
+		t.Fatalf("the first ROM's flag is %d, want 1", got)
+	}
+	if got := play("roms/second.ch8", 2); got != 2 {
+		t.Errorf("the second ROM's flag is %d, want 2: it started from the first ROM's", got)
+	}
+	if got := play("roms/first.ch8", 1); got != 2 {
+		t.Errorf("the first ROM's flag is %d when it's loaded again, want 2", got)
+	}
+}
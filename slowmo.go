@@ -0,0 +1,61 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+)
+
+const (
+	// slowerKey and fasterKey step through the slow motion rates.
+	slowerKey = glfw.KeyLeftBracket
+	fasterKey = glfw.KeyRightBracket
+)
+
+// slowMotionRates are the rates the slow motion keys step through, slowest first.
+var slowMotionRates = []float64{0.1, 0.25, 0.5, 0.75, 1}
+
+// bindSlowMotionKeys binds the keys that slow the Chip8 down and speed it back up,
+// and keeps an indicator on screen for as long as it's in slow motion.
+func bindSlowMotionKeys(input *GLFWKeyboardInput, c8 *cpu.Chip8, osd *onScreenDisplay) {
+	step := func(by int) {
+		// find where we are now, then take a step from there.
+		current := len(slowMotionRates) - 1
+		for i, rate := range slowMotionRates {
+			if rate >= c8.SlowMotion() {
+				current = i
+				break
+			}
+		}
+		next := current + by
+		if next < 0 || next >= len(slowMotionRates) {
+			return
+		}
+		setSlowMotion(c8, osd, slowMotionRates[next])
+	}
+	input.Describe(keyName(slowerKey)+" "+keyName(fasterKey), "slow motion, slower and faster")
+	input.OnHotkey(slowerKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			step(-1)
+		}
+	})
+	input.OnHotkey(fasterKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			step(+1)
+		}
+	})
+}
+
+// setSlowMotion sets the slow motion rate, and says so on screen.
+func setSlowMotion(c8 *cpu.Chip8, osd *onScreenDisplay, rate float64) {
+	c8.SetSlowMotion(rate)
+	if c8.SlowMotion() < 1 {
+		osd.setIndicator("slow motion", fmt.Sprintf("slow motion %gx", c8.SlowMotion()))
+	} else {
+		osd.setIndicator("slow motion", "")
+	}
+}
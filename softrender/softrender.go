@@ -0,0 +1,93 @@
+// Package softrender draws the Chip8's screen without a graphics card: into an
+// image.RGBA, a pixel at a time, and from there onto a terminal with 24-bit color
+// escape codes. It's all plain Go, so a build that uses it instead of OpenGL can be
+// built with CGO_ENABLED=0, for any system Go can cross-compile to -- and run over
+// SSH, or in a container, or anywhere else there's no OpenGL to be had.
+package softrender
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Palette is the colors to draw each of the screen's colors in (see
+// cpu.ColorFrame): [0] is the background, [1] plane 1, [2] plane 2, and [3] where
+// they're both on.
+type Palette [4]color.RGBA
+
+// DefaultPalette is white on black, with red and yellow for the second bit plane.
+var DefaultPalette = Palette{
+	{0, 0, 0, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+	{0xcc, 0x22, 0x22, 0xff},
+	{0xff, 0xdd, 0x33, 0xff},
+}
+
+// A Renderer draws frames into an image it keeps, so it isn't making a new one
+// sixty times a second.
+type Renderer struct {
+	palette Palette
+	scale   int
+	image   *image.RGBA
+}
+
+// NewRenderer returns a renderer that draws each of the Chip8's pixels as a square
+// scale pixels wide, in palette's colors. A scale less than 1 is 1.
+func NewRenderer(palette Palette, scale int) *Renderer {
+	if scale < 1 {
+		scale = 1
+	}
+	return &Renderer{
+		palette: palette,
+		scale:   scale,
+		image:   image.NewRGBA(image.Rect(0, 0, cpu.FrameWidth*scale, cpu.FrameHeight*scale)),
+	}
+}
+
+// SetPalette changes the colors the next frame is drawn in.
+func (r *Renderer) SetPalette(palette Palette) {
+	r.palette = palette
+}
+
+// Render draws frame and returns the image it's drawn on. The image belongs to the
+// renderer, and the next Render draws over it, so copy it to keep it.
+func (r *Renderer) Render(frame cpu.ColorFrame) *image.RGBA {
+	for y := 0; y < cpu.FrameHeight; y++ {
+		for x := 0; x < cpu.FrameWidth; x++ {
+			c := r.palette[frame.Color(x, y)]
+			for dy := 0; dy < r.scale; dy++ {
+				for dx := 0; dx < r.scale; dx++ {
+					r.image.SetRGBA(x*r.scale+dx, y*r.scale+dy, c)
+				}
+			}
+		}
+	}
+	return r.image
+}
+
+// WriteTerminal writes img to a terminal that understands 24-bit color, two rows of
+// pixels to a line of upper-half blocks: the top pixel is the block's color and the
+// bottom one the background's. It starts by moving the cursor to the top-left, so
+// writing one frame after another animates in place rather than scrolling.
+func WriteTerminal(w io.Writer, img image.Image) error {
+	bw := bufio.NewWriter(w)
+	bounds := img.Bounds()
+	bw.WriteString("\x1b[H")
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			bottom := top
+			if y+1 < bounds.Max.Y {
+				bottom = color.RGBAModel.Convert(img.At(x, y+1)).(color.RGBA)
+			}
+			fmt.Fprintf(bw, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		bw.WriteString("\x1b[0m\r\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,53 @@
+package softrender_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/softrender"
+)
+
+// Renderer / WriteTerminal
+// should draw each Chip8 pixel as a scale-sized square in its plane's color
+// should write two rows of pixels to a line, starting from the top-left
+func TestRender(t *testing.T) {
+	var frame cpu.ColorFrame
+	frame[0][0] = 0x80 // 0, 0 is on in plane 1
+	frame[1][0] = 0xc0 // 0, 0 and 1, 0 are on in plane 2
+	renderer := softrender.NewRenderer(softrender.DefaultPalette, 3)
+	img := renderer.Render(frame)
+	if got, want := img.Bounds(), image.Rect(0, 0, 64*3, 32*3); got != want {
+		t.Fatalf("the image is %v, want %v", got, want)
+	}
+	for _, pixel := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, softrender.DefaultPalette[3]},
+		{2, 2, softrender.DefaultPalette[3]},
+		{3, 0, softrender.DefaultPalette[2]},
+		{5, 2, softrender.DefaultPalette[2]},
+		{6, 0, softrender.DefaultPalette[0]},
+		{0, 3, softrender.DefaultPalette[0]},
+	} {
+		if got := img.RGBAAt(pixel.x, pixel.y); got != pixel.want {
+			t.Errorf("pixel %d, %d is %v, want %v", pixel.x, pixel.y, got, pixel.want)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := softrender.WriteTerminal(&b, softrender.NewRenderer(softrender.DefaultPalette, 1).Render(frame)); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "\x1b[H\x1b[38;2;255;221;51m\x1b[48;2;0;0;0m▀") {
+		t.Errorf("the terminal output starts %q", out[:40])
+	}
+	if lines := strings.Count(out, "\r\n"); lines != 16 {
+		t.Errorf("the terminal got %d lines, want 16", lines)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+)
+
+const (
+	// speedDownKey and speedUpKey step through the speeds, and speedResetKey goes back
+	// to the speed we started at. (The keypad's + and - work too.)
+	speedDownKey  = glfw.KeyMinus
+	speedUpKey    = glfw.KeyEqual
+	speedResetKey = glfw.Key0
+)
+
+// speeds are the speeds, in instructions per second, the speed keys step through.
+// Anything from the early interpreters' few hundred up to silly.
+var speeds = []int{30, 60, 120, 250, 400, 500, 700, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// bindSpeedKeys binds the keys that change how many instructions a second the Chip8
+// runs, saying the new speed on screen each time. startSpeed is the speed to go back to.
+func bindSpeedKeys(input *GLFWKeyboardInput, c8 *cpu.Chip8, osd *onScreenDisplay, startSpeed int) {
+	setSpeed := func(speed int) {
+		c8.SetSpeed(speed)
+		osd.showToast(fmt.Sprintf("speed %d ips", c8.Speed()))
+	}
+	faster := func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		for _, speed := range speeds {
+			if speed > c8.Speed() {
+				setSpeed(speed)
+				return
+			}
+		}
+	}
+	slower := func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		for i := len(speeds) - 1; i >= 0; i-- {
+			if speeds[i] < c8.Speed() {
+				setSpeed(speeds[i])
+				return
+			}
+		}
+	}
+	input.Describe("- + "+keyName(speedResetKey), "slower, faster, and back to normal speed")
+	input.OnHotkey(speedDownKey, slower)
+	input.OnHotkey(glfw.KeyKPSubtract, slower)
+	input.OnHotkey(speedUpKey, faster)
+	input.OnHotkey(glfw.KeyKPAdd, faster)
+	input.OnHotkey(speedResetKey, func(pressed bool, mods glfw.ModifierKey) {
+		if pressed {
+			setSpeed(startSpeed)
+		}
+	})
+}
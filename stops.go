@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// resume runs the Chip8 until it stops, and if it stopped by itself, says why in
+// the log. Run it in its own goroutine.
+func resume(c8 *cpu.Chip8) {
+	logStop(c8.Resume())
+}
+
+// logStop logs why the Chip8 stopped, unless it was just somebody pausing it.
+func logStop(result cpu.RunResult) {
+	switch result.Reason {
+	case cpu.StopHalted, cpu.StopAlreadyRunning:
+		// nothing worth saying.
+	case cpu.StopFinished:
+		log.Printf("the program finished at %03x", result.PC)
+	case cpu.StopError:
+		log.Printf("the program crashed at %03x: %v", result.PC, result.Err)
+	default:
+		log.Printf("the chip8 stopped at %03x: %v", result.PC, result.Reason)
+	}
+}
+
+// watchProtectedWrites logs the program's writes to write-protected memory, unless
+// they stop it -- in which case logStop says so already.
+func watchProtectedWrites(c8 *cpu.Chip8, protection cpu.WriteProtection) {
+	if protection.Stop {
+		return
+	}
+	c8.OnProtectedWrite(func(w cpu.ProtectedWrite) {
+		log.Print(w)
+	})
+}
@@ -0,0 +1,164 @@
+// Package storage is where the emulator keeps things that should outlive it:
+// savestates, autosaves, the SCHIP's RPL user flags, high scores and so on.
+//
+// Everything is stored as a blob of bytes under a key, which looks like a
+// slash-separated path ("saves/pong/slot1.state"). The emulator only ever talks
+// to the Storage interface, so if you're embedding it somewhere without a
+// filesystem (a browser, a server with a database) you can plug in your own.
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when nothing is stored under the key.
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrInvalidKey is returned for keys that aren't clean, relative, slash-separated paths.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Storage stores blobs of bytes under keys.
+// Implementations must be safe to use from multiple goroutines.
+type Storage interface {
+	// Get returns the blob stored under key, or ErrNotFound.
+	Get(key string) ([]byte, error)
+	// Put stores data under key, replacing anything stored there before.
+	Put(key string, data []byte) error
+}
+
+// validKey returns true for keys like "saves/pong/slot1.state": relative,
+// slash-separated, with no empty, "." or ".." elements.
+func validKey(key string) bool {
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "/") || strings.HasPrefix(key, "../") || key == ".." {
+		return false
+	}
+	return !strings.Contains(key, "\\")
+}
+
+// Dir stores each blob as a file in a directory on disk. A key's slashes become
+// subdirectories, so "saves/pong/slot1.state" is stored in <root>/saves/pong/slot1.state.
+type Dir struct {
+	root string
+}
+
+// NewDir returns a Storage that keeps its blobs in the directory root.
+// The directory is created when the first blob is stored.
+func NewDir(root string) *Dir {
+	return &Dir{root: root}
+}
+
+// DefaultRoot returns the usual place on this system for an application to keep its
+// files, in a folder called app: %AppData%\app on Windows, ~/Library/Application
+// Support/app on a Mac, and $XDG_DATA_HOME/app (which is ~/.local/share/app unless
+// you've said otherwise) everywhere else.
+func DefaultRoot(app string) (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		// these are where the config goes too; on these systems there's no difference.
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, app), nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, app), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", app), nil
+}
+
+// Path returns the name of the file the blob for key is kept in, for telling people
+// where to find it.
+func (d *Dir) Path(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(d.root, filepath.FromSlash(key)), nil
+}
+
+// Get returns the contents of the file for key.
+func (d *Dir) Get(key string) ([]byte, error) {
+	p, err := d.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put writes data to the file for key. It writes to a temporary file first and
+// then renames it into place, so a crash halfway through never leaves half a savestate.
+func (d *Dir) Put(key string, data []byte) error {
+	p, err := d.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".tmp-"+filepath.Base(p))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Memory keeps blobs in memory, where they last until the program exits.
+// It's handy for tests, and for places where there's nowhere else to put them.
+type Memory struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+// NewMemory returns an empty in-memory Storage.
+func NewMemory() *Memory {
+	return &Memory{blobs: make(map[string][]byte)}
+}
+
+// Get returns a copy of the blob stored under key.
+func (m *Memory) Get(key string) ([]byte, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Put stores a copy of data under key.
+func (m *Memory) Put(key string, data []byte) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,63 @@
+package storage_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/mpingram/chip8/storage"
+)
+
+// Storage implementations
+// should return what was Put under the same key
+// should return ErrNotFound for keys that were never Put
+// should reject keys that escape the storage
+func testStorage(t *testing.T, s storage.Storage) {
+	if _, err := s.Get("saves/pong/slot1.state"); err != storage.ErrNotFound {
+		t.Errorf("Get before Put: got error %v, want ErrNotFound", err)
+	}
+	want := []byte{0xc8, 0x55}
+	if err := s.Put("saves/pong/slot1.state", want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("saves/pong/slot1.state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Get after Put: got %x, want %x", got, want)
+	}
+	for _, key := range []string{"", "/etc/passwd", "../outside", "saves/../../outside", "saves//slot1"} {
+		if err := s.Put(key, want); err != storage.ErrInvalidKey {
+			t.Errorf("Put(%q): got error %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, storage.NewMemory())
+}
+
+func TestDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "chip8-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	testStorage(t, storage.NewDir(root))
+}
+
+// DefaultRoot
+// should follow XDG_DATA_HOME, on systems that have it
+func TestDefaultRoot(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("no XDG here")
+	}
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("XDG_DATA_HOME", "/data")
+	if root, err := storage.DefaultRoot("chip8"); err != nil || root != "/data/chip8" {
+		t.Errorf("got %q, %v; want /data/chip8", root, err)
+	}
+}
@@ -0,0 +1,276 @@
+// Package supervisor runs lots of Chip8s at once, each with its own keypad, speed
+// and lifecycle, and serves an HTTP API for starting, listing and stopping them. It's
+// what lets a server host a game for every visitor, instead of one game for everybody.
+//
+//	GET    /instances              list the instances, as JSON
+//	POST   /instances?speed=700    start an instance running the ROM in the body; returns its ID
+//	DELETE /instances/{id}         stop an instance and throw it away
+//	       /instances/{id}/...     that instance's own control API (see package control),
+//	                               like /instances/3/ to watch it or /instances/3/key to play it
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// maxROMSize is the largest ROM that fits in memory.
+const maxROMSize = 4096 - 0x200
+
+// ErrFull is returned by Start when the supervisor is already running as many instances as it's allowed.
+var ErrFull = errors.New("supervisor: too many instances running")
+
+// ErrNoInstance is returned when there's no instance with the ID asked for.
+var ErrNoInstance = errors.New("supervisor: no such instance")
+
+// Supervisor hosts Chip8 instances.
+type Supervisor struct {
+	token string
+	max   int
+
+	mu        sync.Mutex
+	instances map[string]*Instance
+	nextID    int
+}
+
+// Instance is one Chip8 the supervisor is running, along with its peripherals.
+type Instance struct {
+	ID      string
+	Created time.Time
+
+	c8     *cpu.Chip8
+	keypad *control.Keypad
+	server *control.Server
+	// stop cancels the context the Chip8 runs with, which stops it even if it
+	// hasn't got round to starting yet.
+	stop context.CancelFunc
+}
+
+// InstanceInfo is what GET /instances says about each instance.
+type InstanceInfo struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Running bool      `json:"running"`
+	Speed   int       `json:"speed"`
+	Frame   uint64    `json:"frame"`
+}
+
+// New returns a Supervisor that runs at most max instances at once (0 for no limit),
+// and only does as it's told by clients that know token (see control.NewServer).
+func New(token string, max int) *Supervisor {
+	return &Supervisor{token: token, max: max, instances: make(map[string]*Instance)}
+}
+
+// Start starts a new instance running rom at speed instructions per second.
+func (s *Supervisor) Start(rom []byte, speed int) (*Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max > 0 && len(s.instances) >= s.max {
+		return nil, ErrFull
+	}
+
+	// every instance gets its own keypad; the only keys it sees are the ones
+	// pressed over its own API.
+	keypad := control.NewKeypad(nil)
+	c8 := cpu.NewChip8(keypad, silentSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(speed)
+	if err := c8.Load(rom); err != nil {
+		return nil, err
+	}
+	s.nextID++
+	ctx, stop := context.WithCancel(context.Background())
+	inst := &Instance{
+		ID:      strconv.Itoa(s.nextID),
+		Created: time.Now(),
+		c8:      c8,
+		keypad:  keypad,
+		server:  control.NewServer(c8, keypad, s.token),
+		stop:    stop,
+	}
+	s.instances[inst.ID] = inst
+
+	go c8.ResumeContext(ctx)
+	// nobody else is reading this Chip8's frames, so pass them all on to its viewers.
+	go func() {
+		for {
+			select {
+			case <-c8.FrameReady():
+				inst.server.PublishFrame(c8.Frame())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return inst, nil
+}
+
+// Get returns the instance with the given ID.
+func (s *Supervisor) Get(id string) (*Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, ok := s.instances[id]
+	if !ok {
+		return nil, ErrNoInstance
+	}
+	return inst, nil
+}
+
+// Stop stops an instance and forgets about it.
+func (s *Supervisor) Stop(id string) error {
+	s.mu.Lock()
+	inst, ok := s.instances[id]
+	delete(s.instances, id)
+	s.mu.Unlock()
+	if !ok {
+		return ErrNoInstance
+	}
+	inst.stop()
+	inst.c8.Wait()
+	return nil
+}
+
+// StopAll stops every instance.
+func (s *Supervisor) StopAll() {
+	for _, info := range s.List() {
+		s.Stop(info.ID)
+	}
+}
+
+// List describes every instance, oldest first.
+func (s *Supervisor) List() []InstanceInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]InstanceInfo, 0, len(s.instances))
+	for _, inst := range s.instances {
+		list = append(list, inst.Info())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	return list
+}
+
+// Info describes the instance.
+func (inst *Instance) Info() InstanceInfo {
+	return InstanceInfo{
+		ID:      inst.ID,
+		Created: inst.Created,
+		Running: inst.c8.IsRunning(),
+		Speed:   inst.c8.Speed(),
+		Frame:   inst.c8.FrameCount(),
+	}
+}
+
+// Chip8 returns the instance's Chip8.
+func (inst *Instance) Chip8() *cpu.Chip8 {
+	return inst.c8
+}
+
+// Keypad returns the instance's keypad.
+func (inst *Instance) Keypad() *control.Keypad {
+	return inst.keypad
+}
+
+// ServeHTTP serves the supervisor's API.
+func (s *Supervisor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/instances")
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if path == "" || path == "/" {
+		if !control.HasToken(r, s.token) {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		s.handleInstances(w, r)
+		return
+	}
+
+	// /instances/{id}, or /instances/{id}/... for the instance's own API.
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	inst, err := s.Get(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodDelete {
+			// the instance's pages expect to be under a directory, so their links work.
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		if !control.HasToken(r, s.token) {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		s.Stop(inst.ID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// the instance's server checks the token itself.
+	http.StripPrefix("/instances/"+inst.ID, inst.server).ServeHTTP(w, r)
+}
+
+func (s *Supervisor) handleInstances(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.List())
+
+	case http.MethodPost:
+		speed := cpu.DefaultSpeed
+		if q := r.URL.Query().Get("speed"); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n < 1 {
+				http.Error(w, fmt.Sprintf("speed %q isn't a positive number", q), http.StatusBadRequest)
+				return
+			}
+			speed = n
+		}
+		rom, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxROMSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("ROMs can be at most %d bytes", maxROMSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		inst, err := s.Start(rom, speed)
+		if err == ErrFull {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/instances/"+inst.ID+"/")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(inst.Info())
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// silentSpeaker is a Speaker that doesn't make any noise, since there's
+// nobody sitting at a server to hear it.
+type silentSpeaker struct{}
+
+func (silentSpeaker) StartSound() {}
+func (silentSpeaker) StopSound()  {}
@@ -0,0 +1,116 @@
+package supervisor_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/supervisor"
+)
+
+// Supervisor
+// should run instances side by side, and stop them when told to
+func TestSupervisor(t *testing.T) {
+	s := supervisor.New("sesame", 2)
+	defer s.StopAll()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// JP 200, forever
+	loop := "\x12\x00"
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp := request(t, http.MethodPost, ts.URL+"/instances?speed=600", "sesame", loop)
+		var info supervisor.InstanceInfo
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST /instances: got status %s", resp.Status)
+		}
+		ids = append(ids, info.ID)
+	}
+	if ids[0] == ids[1] {
+		t.Fatalf("two instances got the same ID, %s", ids[0])
+	}
+
+	resp := request(t, http.MethodPost, ts.URL+"/instances", "sesame", loop)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST /instances with the supervisor full: got status %s, want 503", resp.Status)
+	}
+
+	// each instance's own API is under its ID.
+	resp = request(t, http.MethodPost, ts.URL+"/instances/"+ids[1]+"/key", "sesame", `{"key": "5", "action": "press"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("POST /instances/%s/key: got status %s", ids[1], resp.Status)
+	}
+	first, _ := s.Get(ids[0])
+	second, _ := s.Get(ids[1])
+	if first.Keypad().Poll() == second.Keypad().Poll() {
+		t.Errorf("pressing a key on instance %s pressed it on instance %s too", ids[1], ids[0])
+	}
+
+	resp = request(t, http.MethodDelete, ts.URL+"/instances/"+ids[0], "sesame", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /instances/%s: got status %s", ids[0], resp.Status)
+	}
+	if first.Chip8().IsRunning() {
+		t.Errorf("instance %s is still running after being stopped", ids[0])
+	}
+
+	resp = request(t, http.MethodGet, ts.URL+"/instances", "sesame", "")
+	var list []supervisor.InstanceInfo
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(list) != 1 || list[0].ID != ids[1] || !list[0].Running {
+		t.Errorf("GET /instances = %+v, want just instance %s, running", list, ids[1])
+	}
+
+	resp = request(t, http.MethodGet, ts.URL+"/instances", "open sesame", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /instances with the wrong token: got status %s, want 401", resp.Status)
+	}
+}
+
+// Supervisor.Stop
+// should stop an instance for good, even one stopped before its Chip8 got going
+func TestStopBeforeStarted(t *testing.T) {
+	s := supervisor.New("", 0)
+	for i := 0; i < 20; i++ {
+		// JP 200, forever
+		inst, err := s.Start([]byte{0x12, 0x00}, 600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Stop(inst.ID); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+		if inst.Chip8().IsRunning() {
+			t.Fatalf("instance %s started running after it was stopped", inst.ID)
+		}
+	}
+}
+
+func request(t *testing.T, method, url, token, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/sweep"
+)
+
+func init() {
+	commands["sweep"] = command{
+		usage: "run a folder of ROMs under each set of quirks, and report which crash, break or draw nothing",
+		run:   sweepCommand,
+	}
+}
+
+func sweepCommand(args []string) error {
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	frames := flags.Int("frames", 600, "run each ROM for this many frames (60 a second)")
+	speed := flags.Int("speed", cpu.DefaultSpeed, "instructions per second")
+	var names []string
+	for _, p := range sweep.Profiles {
+		names = append(names, p.Name)
+	}
+	profileList := flags.String("profiles", strings.Join(names, ","), "run each ROM under these `quirk profiles`")
+	output := flags.String("o", "", "write the report to `file`: .json to keep and compare, anything else for Markdown (default: print it)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 sweep [flags] folder-or-rom...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 || *frames <= 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var profiles []sweep.Profile
+	for _, name := range strings.Split(*profileList, ",") {
+		p, err := sweep.ProfileByName(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, p)
+	}
+
+	var paths []string
+	for _, arg := range flags.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		found, _ := filepath.Glob(filepath.Join(arg, "*.ch8"))
+		carts, _ := filepath.Glob(filepath.Join(arg, "*.gif"))
+		found = append(found, carts...)
+		sort.Strings(found)
+		paths = append(paths, found...)
+	}
+	var roms []sweep.ROM
+	for _, path := range paths {
+		rom, err := readROM(path)
+		if err != nil {
+			// a cartridge that doesn't compile shouldn't stop the sweep.
+			fmt.Fprintf(os.Stderr, "skipping %v\n", err)
+			continue
+		}
+		roms = append(roms, sweep.ROM{Name: path, Data: rom})
+	}
+	if len(roms) == 0 {
+		return fmt.Errorf("there are no ROMs there")
+	}
+
+	r := sweep.Sweep(roms, profiles, *frames, *speed)
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if strings.EqualFold(filepath.Ext(*output), ".json") {
+		return r.WriteJSON(out)
+	}
+	return r.WriteMarkdown(out)
+}
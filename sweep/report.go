@@ -0,0 +1,127 @@
+package sweep
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// A ROM is one of the ROMs to sweep, and what to call it in the report.
+type ROM struct {
+	Name string
+	Data []byte
+}
+
+// A Report is a whole sweep: how every ROM went under every profile.
+type Report struct {
+	Frames   int      `json:"frames"`
+	Speed    int      `json:"speed"`
+	Profiles []string `json:"profiles"`
+	// Results are by ROM, in the order they were given, and then by profile.
+	Results []Result `json:"results"`
+}
+
+// Sweep runs every ROM under every profile, for frames frames at speed instructions
+// a second, as many at once as there are CPUs to run them on.
+func Sweep(roms []ROM, profiles []Profile, frames, speed int) *Report {
+	r := &Report{Frames: frames, Speed: speed, Results: make([]Result, len(roms)*len(profiles))}
+	for _, p := range profiles {
+		r.Profiles = append(r.Profiles, p.Name)
+	}
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < runtime.NumCPU(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range runs {
+				rom, profile := roms[i/len(profiles)], profiles[i%len(profiles)]
+				r.Results[i] = Run(rom.Name, rom.Data, profile, frames, speed)
+			}
+		}()
+	}
+	for i := range r.Results {
+		runs <- i
+	}
+	close(runs)
+	wg.Wait()
+	return r
+}
+
+// Totals returns how many runs under each profile had each outcome.
+func (r *Report) Totals() map[string]map[Outcome]int {
+	totals := make(map[string]map[Outcome]int)
+	for _, p := range r.Profiles {
+		totals[p] = make(map[Outcome]int)
+	}
+	for _, result := range r.Results {
+		totals[result.Profile][result.Outcome]++
+	}
+	return totals
+}
+
+// WriteJSON writes the report as JSON, for keeping and comparing with the next one.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteMarkdown writes the report as Markdown, for reading: a table of every ROM
+// under every profile, the totals, and what went wrong, where.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Compatibility sweep\n\n")
+	fmt.Fprintf(&b, "Each ROM ran for %d frames at %d instructions a second, with nobody at the keyboard.\n\n", r.Frames, r.Speed)
+
+	fmt.Fprintf(&b, "| ROM | %s |\n", strings.Join(r.Profiles, " | "))
+	fmt.Fprintf(&b, "|---%s|\n", strings.Repeat("|---", len(r.Profiles)))
+	for i := 0; i < len(r.Results); i += len(r.Profiles) {
+		row := r.Results[i : i+len(r.Profiles)]
+		outcomes := make([]string, len(row))
+		for j, result := range row {
+			outcomes[j] = result.Outcome.String()
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", escapeCell(row[0].ROM), strings.Join(outcomes, " | "))
+	}
+
+	fmt.Fprintf(&b, "\n## Totals\n\n")
+	fmt.Fprintf(&b, "| | %s |\n", strings.Join(r.Profiles, " | "))
+	fmt.Fprintf(&b, "|---%s|\n", strings.Repeat("|---", len(r.Profiles)))
+	totals := r.Totals()
+	for o := OK; o <= Crashed; o++ {
+		counts := make([]string, len(r.Profiles))
+		for j, p := range r.Profiles {
+			counts[j] = fmt.Sprint(totals[p][o])
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", o, strings.Join(counts, " | "))
+	}
+
+	var wrong []string
+	for _, result := range r.Results {
+		switch result.Outcome {
+		case UnknownOpcode, Crashed:
+			wrong = append(wrong, fmt.Sprintf("- **%s** (%s): %s after %d frames, at %#03x (%04x): %s",
+				result.ROM, result.Profile, result.Outcome, result.Frames, result.PC, result.Opcode, result.Err))
+		case Finished:
+			wrong = append(wrong, fmt.Sprintf("- **%s** (%s): finished after %d frames, at %#03x",
+				result.ROM, result.Profile, result.Frames, result.PC))
+		}
+	}
+	if len(wrong) > 0 {
+		fmt.Fprintf(&b, "\n## What went wrong\n\n%s\n", strings.Join(wrong, "\n"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeCell makes text safe to put in a Markdown table.
+func escapeCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
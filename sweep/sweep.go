@@ -0,0 +1,159 @@
+// Package sweep runs a pile of ROMs, each under each of a few quirk profiles, for a
+// while, with nobody at the keyboard, and writes down how each run went: whether it
+// crashed, ran into an instruction this Chip8 doesn't know, or finished early, or
+// whether it ran and still had nothing on the screen at the end. Run it over every
+// ROM you've got before and after changing the emulator, and you'll know what the
+// change broke, and what it fixed.
+//
+// "Nothing on the screen" is the flakiest of them: plenty of games sit at a blank
+// screen waiting for a key, and nobody presses one here. It's still worth knowing
+// when a ROM that drew under one profile doesn't under another.
+package sweep
+
+import (
+	"fmt"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Profile is a set of quirks to run ROMs with, named after the Chip8 that had them.
+type Profile struct {
+	Name   string
+	Quirks cpu.Quirks
+}
+
+// Profiles are the profiles ROMs are run under, unless you say otherwise: the
+// Chip8s most games were written for.
+var Profiles = []Profile{
+	// this Chip8, the way it's always been, which is the CHIP-48's way, near enough.
+	{"default", cpu.Quirks{}},
+	{"vip", cpu.Quirks{ShiftVy: true, IncrementI: true, ResetVF: true, Clip: true}},
+	{"schip", cpu.Quirks{JumpVx: true, Clip: true, HalfScroll: true}},
+	{"xochip", cpu.Quirks{ShiftVy: true, IncrementI: true}},
+}
+
+// ProfileByName returns the profile in Profiles called name.
+func ProfileByName(name string) (Profile, error) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("there's no profile called %q", name)
+}
+
+// An Outcome is how a run went.
+type Outcome int
+
+const (
+	// OK means it ran the whole time, and there's something on the screen.
+	OK Outcome = iota
+	// Blank means it ran the whole time, and there's nothing on the screen.
+	Blank
+	// Finished means it ran into a 0x0000, where programs end, before the time was up.
+	Finished
+	// UnknownOpcode means it ran into an instruction this Chip8 doesn't know.
+	UnknownOpcode
+	// Crashed means anything else went wrong: the stack overflowed, say, or the
+	// emulator itself fell over.
+	Crashed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OK:
+		return "ok"
+	case Blank:
+		return "blank screen"
+	case Finished:
+		return "finished"
+	case UnknownOpcode:
+		return "unknown opcode"
+	case Crashed:
+		return "crashed"
+	}
+	return "unknown"
+}
+
+// MarshalText writes an Outcome as its name, for JSON reports.
+func (o Outcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// A Result is how one ROM went under one profile.
+type Result struct {
+	ROM     string  `json:"rom"`
+	Profile string  `json:"profile"`
+	Outcome Outcome `json:"outcome"`
+	// Frames is how many frames it ran before it stopped, or all of them.
+	Frames int `json:"frames"`
+	// PC and Opcode are where it stopped, and the instruction there, for the
+	// outcomes that stop early.
+	PC     uint16 `json:"pc,omitempty"`
+	Opcode uint16 `json:"opcode,omitempty"`
+	// Err is what went wrong, for UnknownOpcode and Crashed.
+	Err string `json:"error,omitempty"`
+}
+
+// Run runs rom, which is called name, under profile, for frames frames at speed
+// instructions a second, and says how it went.
+func Run(name string, rom []byte, profile Profile, frames, speed int) (result Result) {
+	result = Result{ROM: name, Profile: profile.Name}
+	c8 := cpu.NewChip8(noKeyboard{}, silentSpeaker{}, cpu.WithQuirks(profile.Quirks))
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(speed)
+	// the same random numbers every time, so a sweep today and a sweep tomorrow
+	// only differ where the emulator does.
+	c8.SetSeed(1)
+	if err := c8.Load(rom); err != nil {
+		result.Outcome, result.Err = Crashed, err.Error()
+		return result
+	}
+	// a bug in the emulator is just the kind of thing a sweep is for finding, and
+	// it shouldn't take the rest of the sweep down with it.
+	defer func() {
+		if r := recover(); r != nil {
+			result.Outcome, result.Err = Crashed, fmt.Sprintf("the emulator panicked: %v", r)
+		}
+	}()
+	for result.Frames < frames {
+		for start := c8.FrameCount(); c8.FrameCount() == start; {
+			run := c8.FrameStep()
+			if run.Reason == cpu.StopFinished || run.Reason == cpu.StopError {
+				// it stopped on the instruction at PC: the end, or the one it couldn't do.
+				result.PC = run.PC
+				if opcode, err := c8.ReadMemory(run.PC, 2); err == nil {
+					result.Opcode = uint16(opcode[0])<<8 | uint16(opcode[1])
+				}
+			}
+			switch {
+			case run.Reason == cpu.StopFinished:
+				result.Outcome = Finished
+				return result
+			case run.Err != nil && cpu.Decode(result.Opcode).Op == cpu.OpInvalid:
+				result.Outcome, result.Err = UnknownOpcode, run.Err.Error()
+				return result
+			case run.Err != nil:
+				result.Outcome, result.Err = Crashed, run.Err.Error()
+				return result
+			}
+		}
+		result.Frames++
+	}
+	result.PC, result.Opcode = 0, 0
+	if c8.ColorFrame() == (cpu.ColorFrame{}) {
+		result.Outcome = Blank
+	}
+	return result
+}
+
+// noKeyboard is a keyboard nobody's pressing.
+type noKeyboard struct{}
+
+func (noKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+// silentSpeaker is a Speaker that doesn't make any noise.
+type silentSpeaker struct{}
+
+func (silentSpeaker) StartSound() {}
+func (silentSpeaker) StopSound()  {}
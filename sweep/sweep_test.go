@@ -0,0 +1,76 @@
+package sweep_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/sweep"
+)
+
+// Run
+// should say a ROM that draws and keeps going is ok
+// should say a ROM that never draws has a blank screen
+// should catch ROMs that finish early, don't make sense, or crash the emulator
+func TestRun(t *testing.T) {
+	profile, err := sweep.ProfileByName("vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		rom  []byte
+		want sweep.Outcome
+	}{
+		// draw the font's 0, then go round and round.
+		{"draws", []byte{0xf0, 0x29, 0xd0, 0x05, 0x12, 0x04}, sweep.OK},
+		{"loops", []byte{0x12, 0x00}, sweep.Blank},
+		{"ends", []byte{0x60, 0x01}, sweep.Finished},
+		{"hires", []byte{0x00, 0xff}, sweep.UnknownOpcode},
+		// a subroutine that calls itself, until there's no more stack, or memory.
+		{"recurses", []byte{0x22, 0x00}, sweep.Crashed},
+	} {
+		result := sweep.Run(test.name, test.rom, profile, 600, 6000)
+		if result.Outcome != test.want {
+			t.Errorf("%s came out %v (%+v), want %v", test.name, result.Outcome, result, test.want)
+		}
+	}
+	if _, err := sweep.ProfileByName("commodore"); err == nil {
+		t.Error("found a profile that doesn't exist")
+	}
+}
+
+// Sweep
+// should run every ROM under every profile, and keep them in order
+// should write a report to read, and one to keep
+func TestSweep(t *testing.T) {
+	roms := []sweep.ROM{{"loops | forever", []byte{0x12, 0x00}}, {"hires", []byte{0x00, 0xff}}}
+	r := sweep.Sweep(roms, sweep.Profiles, 10, 700)
+	if len(r.Results) != 2*len(sweep.Profiles) || r.Results[len(sweep.Profiles)].ROM != "hires" || r.Results[1].Profile != sweep.Profiles[1].Name {
+		t.Fatalf("got %+v", r.Results)
+	}
+	if totals := r.Totals(); totals["default"][sweep.Blank] != 1 || totals["default"][sweep.UnknownOpcode] != 1 {
+		t.Errorf("got totals %v", totals)
+	}
+
+	var md bytes.Buffer
+	if err := r.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`| loops \| forever | blank screen |`, "**hires** (default): unknown opcode"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("report doesn't say %q:\n%s", want, md.String())
+		}
+	}
+	var js bytes.Buffer
+	if err := r.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var kept struct {
+		Results []struct{ Outcome string }
+	}
+	if err := json.Unmarshal(js.Bytes(), &kept); err != nil || kept.Results[0].Outcome != "blank screen" {
+		t.Errorf("got %v, %v from\n%s", kept, err, js.String())
+	}
+}
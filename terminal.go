@@ -0,0 +1,296 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+// This is the emulator without cgo, which means without GLFW and OpenGL: build it
+// with CGO_ENABLED=0 and it cross-compiles to anything Go does, with no C compiler
+// or graphics drivers needed. Instead of opening a window, it draws the screen right
+// in the terminal it was started from, with the software renderer (see softrender),
+// and reads the keys from there too. It's a smaller emulator than the windowed one --
+// no menus, debugger, savestates or netplay -- but everything that doesn't need a
+// window is still here: the commands, -headless, and the HTTP control API.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/softrender"
+)
+
+// terminalKeys maps keys on a QWERTY keyboard to the Chip8's keypad, the same way
+// the windowed emulator does (see keypadMapping).
+var terminalKeys = map[byte]cpu.KeyCode{
+	'1': 0xA, '2': 0x0, '3': 0xB, '4': 0xF,
+	'q': 0x1, 'w': 0x2, 'e': 0x3, 'r': 0xC,
+	'a': 0x4, 's': 0x5, 'd': 0x6, 'f': 0xD,
+	'z': 0x7, 'x': 0x8, 'c': 0x9, 'v': 0xE,
+}
+
+// terminalKeyHold is how long a key stays down after the terminal says it was
+// pressed. Terminals only say when keys are pressed, never when they're let go, but
+// they keep saying it while a key is held, so every repeat keeps it down a bit longer.
+// It has to outlast the pause before the repeats start, or a held key stutters.
+const terminalKeyHold = 600 * time.Millisecond
+
+func main() {
+	if runCommand() {
+		return
+	}
+	turbo := flag.Bool("turbo", false, "run as fast as possible, without a speed limit")
+	speed := flag.Int("speed", cpu.DefaultSpeed, "number of instructions to execute per second")
+	httpAddr := flag.String("http", "", "serve the HTTP control API on this `address`, like localhost:8080")
+	token := flag.String("token", "", "the `token` HTTP clients need to control the emulator (default: a random one, printed at startup)")
+	headless := flag.Bool("headless", false, "run without drawing anything; watch the screen in a browser with -http, or with chip8 view and -display")
+	displayAddr := flag.String("display", "", "with -headless, let remote displays (chip8 view) connect on this `address`, like :7800")
+	quirkList := flag.String("quirks", "", "make instructions behave like older interpreters did, for games that expect them to, as a comma-separated `list`: "+strings.Join(cpu.QuirkNames(), ", "))
+	fontName := flag.String("font", cpu.DefaultFont.Name, "draw numbers with this interpreter's `font`: "+strings.Join(cpu.FontNames(), ", "))
+	protectList := flag.String("protect", "", "catch programs writing where they shouldn't, as a comma-separated `list`: interpreter (0x000-0x1ff), program, all, and stop to stop the game when it happens rather than just saying so")
+	memory := flag.String("memory", "4k", "how much `memory` the Chip8 has: 4k, or 64k for XO-CHIP-sized programs")
+	paletteName := flag.String("palette", palettes[0].name, "draw the screen in this `palette`: "+strings.Join(paletteNames(), ", "))
+	var deviceFlags deviceFlags
+	flag.StringVar(&deviceFlags.serial, "serial", "", "give ROMs a serial port at 0xe98, and write what they send it to `file`, or stdout if it's -")
+	flag.BoolVar(&deviceFlags.clock, "clock", false, "give ROMs a real-time clock to read, at 0xe90 (see the devices package)")
+	dataDir := flag.String("dir", "", "keep what the emulator writes under `folder` (default: the usual place on this system)")
+	var patches patchList
+	flag.Var(&patches, "patch", "load a file into memory after the ROM, as `file@address` (may be repeated)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 [flags] [rom.ch8]\n\nThis chip8 was built without cgo, so it plays in the terminal. Keys are 1-4, q-r, a-f and z-v; Esc or Ctrl-C quits.\n\n")
+		flag.PrintDefaults()
+		printCommands()
+	}
+	flag.Parse()
+
+	romPath := "./roms/Pong (1 player).ch8"
+	if flag.NArg() > 0 {
+		romPath = flag.Arg(0)
+	}
+	rom, err := readROM(romPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	quirks, err := cpu.ParseQuirks(*quirkList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	font, err := cpu.FontByName(*fontName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	memorySize, err := cpu.ParseMemorySize(*memory)
+	if err != nil {
+		log.Fatal(err)
+	}
+	protection, err := cpu.ParseWriteProtection(*protectList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pal, ok := paletteByName(*paletteName)
+	if !ok {
+		log.Fatalf("there's no palette called %q", *paletteName)
+	}
+	if *displayAddr != "" && !*headless {
+		log.Fatal("-display only works with -headless")
+	}
+
+	config := headlessConfig{
+		romPath:     romPath,
+		rom:         rom,
+		store:       openStore(*dataDir),
+		speed:       *speed,
+		turbo:       *turbo,
+		quirks:      quirks,
+		font:        font,
+		memorySize:  memorySize,
+		protection:  protection,
+		devices:     deviceFlags,
+		patches:     patches,
+		httpAddr:    *httpAddr,
+		displayAddr: *displayAddr,
+	}
+	if *httpAddr != "" {
+		config.token = apiToken(*token)
+	}
+	if *headless {
+		if *httpAddr == "" && *displayAddr == "" {
+			log.Fatal("-headless needs -http or -display, or there'd be no way to see anything")
+		}
+		runHeadless(context.Background(), config)
+		return
+	}
+	runTerminal(config, pal)
+}
+
+// runTerminal plays a ROM in the terminal: the screen is drawn in it, half a
+// character to a pixel, and keys typed in it go to the Chip8. It takes the same
+// config as runHeadless, of which it uses everything but the remote displays and
+// the crowd. It returns when the player presses Esc or Ctrl-C.
+func runTerminal(config headlessConfig, pal palette) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		log.Fatalf("the terminal frontend needs a terminal: %v (try -headless)", err)
+	}
+	defer tty.Close()
+
+	keypad := control.NewKeypad(nil)
+	c8 := cpu.NewChip8(keypad, bellSpeaker{tty}, cpu.WithQuirks(config.quirks), cpu.WithFont(config.font), cpu.WithMemorySize(config.memorySize), cpu.ProtectMemory(config.protection))
+	// the log would scribble all over the screen.
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(config.speed)
+	c8.SetTurbo(config.turbo)
+	serial := attachDevices(c8, config.devices)
+	c8.ConnectRPLFlags(newSaveSlots(config.store, config.romPath).rplFlags())
+	if err := c8.Load(config.rom); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.patches.apply(c8); err != nil {
+		log.Fatal(err)
+	}
+
+	var server *control.Server
+	if config.httpAddr != "" {
+		server = control.NewServer(c8, keypad, config.token)
+		if serial != nil {
+			server.HandleSerial(serial)
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(config.httpAddr, server))
+		}()
+		log.Printf("control it at http://%s/?token=%s", config.httpAddr, config.token)
+	}
+
+	restore, err := rawTerminal(tty)
+	if err != nil {
+		log.Fatalf("setting up the terminal: %v", err)
+	}
+	// clear the screen and hide the cursor, and put both back on the way out.
+	fmt.Fprint(tty, "\x1b[2J\x1b[?25l")
+	defer func() {
+		fmt.Fprint(tty, "\x1b[0m\x1b[2J\x1b[H\x1b[?25h")
+		restore()
+	}()
+
+	quit := make(chan struct{})
+	go readTerminalKeys(tty, keypad, quit)
+	go func() {
+		logStop(c8.Resume())
+	}()
+
+	renderer := softrender.NewRenderer(pal.softPalette(), 1)
+	refresh := time.NewTicker(time.Second / 60)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-quit:
+			c8.Halt()
+			return
+		case <-refresh.C:
+		}
+		select {
+		case <-c8.FrameReady():
+		default:
+			continue
+		}
+		frame := c8.ColorFrame()
+		if err := softrender.WriteTerminal(tty, renderer.Render(frame)); err != nil {
+			log.Printf("drawing the screen: %v", err)
+		}
+		if server != nil {
+			server.PublishFrame(frame[0])
+		}
+	}
+}
+
+// rawTerminal puts tty into raw mode, so keys come through as they're typed rather
+// than a line at a time, and don't echo. It returns a function that puts it back
+// the way it was. stty does the work, since doing it ourselves takes a different
+// system call on every system, and stty is on all the ones with a /dev/tty.
+func rawTerminal(tty *os.File) (restore func(), err error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
+
+// readTerminalKeys reads keys from tty and presses them on keypad, holding each one
+// down until terminalKeyHold after the last time the terminal said it was pressed. It
+// closes quit when Esc or Ctrl-C is pressed, or the terminal goes away.
+func readTerminalKeys(tty *os.File, keypad *control.Keypad, quit chan struct{}) {
+	var mu sync.Mutex
+	// until is when each key that's down gets let go of.
+	until := make(map[cpu.KeyCode]time.Time)
+	go func() {
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case now := <-tick.C:
+				mu.Lock()
+				for key, t := range until {
+					if now.After(t) {
+						keypad.Release(key)
+						delete(until, key)
+					}
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	defer close(quit)
+	buf := make([]byte, 64)
+	for {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			if b == 0x1b || b == 0x03 {
+				// an escape sequence (an arrow key, say) starts with Esc too, but
+				// none of them mean anything here, so quitting on them is no loss.
+				return
+			}
+			key, ok := terminalKeys[b]
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			if _, held := until[key]; !held {
+				keypad.Press(key)
+			}
+			until[key] = time.Now().Add(terminalKeyHold)
+			mu.Unlock()
+		}
+	}
+}
+
+// bellSpeaker rings the terminal's bell when the Chip8 beeps. That's as close as a
+// terminal gets to a speaker, and it can't be told to stop.
+type bellSpeaker struct {
+	tty *os.File
+}
+
+func (s bellSpeaker) StartSound() { fmt.Fprint(s.tty, "\a") }
+func (s bellSpeaker) StopSound()  {}
@@ -0,0 +1,156 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/control"
+	"github.com/mpingram/chip8/cpu"
+)
+
+func init() {
+	commands["tile"] = command{
+		usage: "play two or more ROMs side by side in one window, with tab to pass the keyboard from one to the next",
+		run:   tileCommand,
+	}
+}
+
+// focusKey passes the keyboard on to the next ROM in chip8 tile (or, with shift,
+// back to the one before).
+const focusKey = glfw.KeyTab
+
+// A tile is one of the Chip8s in chip8 tile.
+type tile struct {
+	romPath string
+	c8      *cpu.Chip8
+	// keypad is how the keyboard gets to the Chip8, when this tile has the focus.
+	keypad *control.Keypad
+	// held is the keys the keypad has down, bit n for key n.
+	held uint16
+}
+
+// setKeys presses and lets go of the tile's keys so that the ones in keys, and
+// only those, are held down.
+func (t *tile) setKeys(keys uint16) {
+	for key := cpu.KeyCode(0); key <= 0xF; key++ {
+		bit := uint16(1) << key
+		switch {
+		case keys&bit != 0 && t.held&bit == 0:
+			t.keypad.Press(key)
+		case keys&bit == 0 && t.held&bit != 0:
+			t.keypad.Release(key)
+		}
+	}
+	t.held = keys
+}
+
+// tileCommand runs a Chip8 for each ROM on the command line, all at once, tiled
+// across one window: for seeing two versions of a game side by side, or two games,
+// or putting on a show. They all run all the time, but only one of them has the
+// keyboard -- the one that isn't dimmed -- and tab passes it on.
+func tileCommand(args []string) error {
+	flags := flag.NewFlagSet("tile", flag.ExitOnError)
+	speed := flags.Int("speed", cpu.DefaultSpeed, "number of instructions each Chip8 executes per second")
+	quirkList := flags.String("quirks", "", "make instructions behave like older interpreters did, as a comma-separated `list`: "+strings.Join(cpu.QuirkNames(), ", "))
+	fontName := flags.String("font", cpu.DefaultFont.Name, "draw numbers with this interpreter's `font`: "+strings.Join(cpu.FontNames(), ", "))
+	paletteName := flags.String("palette", palettes[0].name, "draw the screens in this `palette`: "+strings.Join(paletteNames(), ", "))
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 tile [flags] first.ch8 second.ch8 [more.ch8...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	quirks, err := cpu.ParseQuirks(*quirkList)
+	if err != nil {
+		return err
+	}
+	font, err := cpu.FontByName(*fontName)
+	if err != nil {
+		return err
+	}
+	p, ok := paletteByName(*paletteName)
+	if !ok {
+		return fmt.Errorf("there's no palette called %q", *paletteName)
+	}
+
+	tiles := make([]*tile, flags.NArg())
+	labels := make([]string, len(tiles))
+	for i, romPath := range flags.Args() {
+		rom, err := readROM(romPath)
+		if err != nil {
+			return err
+		}
+		keypad := control.NewKeypad(nil)
+		c8 := cpu.NewChip8(keypad, silentSpeaker{}, cpu.WithQuirks(quirks), cpu.WithFont(font))
+		// nobody's going to read the log, and it would grow forever.
+		c8.SetLogLevel(cpu.LogNone)
+		c8.SetSpeed(*speed)
+		if err := c8.Load(rom); err != nil {
+			return fmt.Errorf("%s: %v", romPath, err)
+		}
+		tiles[i] = &tile{romPath: romPath, c8: c8, keypad: keypad}
+		labels[i] = romLabel(romPath)
+	}
+
+	window := openWindow(false, 0)
+	defer glfw.Terminate()
+	// two screens to a row, each the size the window usually is.
+	window.SetSize(1280, 320*((len(tiles)+1)/2))
+	window.SetTitle(strings.Join(labels, " | "))
+	renderer := NewOpenGLRenderer(window)
+	renderer.SetPalette(p)
+	input := NewGLFWKeyboardInput(window)
+	osd := new(onScreenDisplay)
+
+	focus := 0
+	input.Describe(keyName(focusKey)+"/shift+"+keyName(focusKey), "give the keyboard to the next or previous rom")
+	input.OnHotkey(focusKey, func(pressed bool, mods glfw.ModifierKey) {
+		if !pressed {
+			return
+		}
+		tiles[focus].setKeys(0)
+		if mods&glfw.ModShift != 0 {
+			focus = (focus + len(tiles) - 1) % len(tiles)
+		} else {
+			focus = (focus + 1) % len(tiles)
+		}
+		osd.showToast("playing " + labels[focus])
+	})
+	bindHelpKey(input, osd)
+
+	for _, t := range tiles {
+		go resume(t.c8)
+	}
+	osd.showToast("playing " + labels[focus] + " - tab for the next one")
+
+	frames := make([]cpu.ColorFrame, len(tiles))
+	refresh := time.NewTicker(time.Second / 60)
+	defer refresh.Stop()
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+		tiles[focus].setKeys(input.Held())
+
+		<-refresh.C
+		for i, t := range tiles {
+			frames[i] = t.c8.ColorFrame()
+		}
+		renderer.SetOverlay(osd.lines(time.Now()))
+		renderer.RenderTiles(frames, focus)
+	}
+
+	for _, t := range tiles {
+		t.c8.Halt()
+		t.c8.Wait()
+	}
+	return nil
+}
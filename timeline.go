@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/timeline"
+)
+
+func init() {
+	commands["timeline"] = command{
+		usage: "run a ROM for a while and draw a timing diagram of it, as Chrome trace JSON or SVG",
+		run:   timelineCommand,
+	}
+}
+
+func timelineCommand(args []string) error {
+	flags := flag.NewFlagSet("timeline", flag.ExitOnError)
+	cycles := flags.Int("cycles", 1000, "number of instructions to record")
+	speed := flags.Int("speed", cpu.DefaultSpeed, "instructions per second, which is what the timeline's clock runs at")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 timeline [flags] rom.ch8 output.json|output.svg\n")
+		fmt.Fprintf(os.Stderr, "Open the JSON in chrome://tracing or https://ui.perfetto.dev.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	rom, err := readROM(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	recorder := timeline.NewRecorder()
+	c8 := cpu.NewChip8(noKeyboard{}, recorder.Speaker(nil))
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(*speed)
+	if err := c8.Load(rom); err != nil {
+		return err
+	}
+	tl := recorder.Record(c8, *cycles)
+
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(flags.Arg(1)), ".svg") {
+		err = tl.WriteSVG(out)
+	} else {
+		err = tl.WriteChromeTrace(out)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
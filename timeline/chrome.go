@@ -0,0 +1,62 @@
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// chromeEvent is an event in the Chrome trace event format, which is documented at
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU.
+type chromeEvent struct {
+	Name     string                 `json:"name"`
+	Phase    string                 `json:"ph"`
+	Time     float64                `json:"ts"`
+	Duration float64                `json:"dur,omitempty"`
+	Process  int                    `json:"pid"`
+	Thread   int                    `json:"tid"`
+	Scope    string                 `json:"s,omitempty"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// each part of the timeline gets a track, which Chrome calls a thread.
+const (
+	instructionTrack = iota + 1
+	frameTrack
+	soundTrack
+)
+
+// WriteChromeTrace writes the timeline in Chrome's trace event format. Instructions,
+// frames and sound each get a track, and the timers are drawn as counters.
+func (t *Timeline) WriteChromeTrace(w io.Writer) error {
+	// times are in microseconds.
+	us := func(cycles int) float64 {
+		return t.Seconds(cycles) * 1e6
+	}
+	var events []chromeEvent
+	for i, name := range []string{"instructions", "frames", "sound"} {
+		events = append(events, chromeEvent{Name: "thread_name", Phase: "M", Process: 1, Thread: instructionTrack + i, Args: map[string]interface{}{"name": name}})
+	}
+	for _, in := range t.Instructions {
+		events = append(events, chromeEvent{
+			Name: cpu.Disassemble(in.Opcode), Phase: "X", Time: us(in.Cycle), Duration: us(1), Process: 1, Thread: instructionTrack,
+			Args: map[string]interface{}{"pc": fmt.Sprintf("%#03x", in.PC), "opcode": fmt.Sprintf("%04x", in.Opcode), "cycle": in.Cycle},
+		})
+	}
+	timer := func(name string, changes []Change) {
+		for _, change := range changes {
+			events = append(events, chromeEvent{Name: name, Phase: "C", Time: us(change.Cycle), Process: 1, Args: map[string]interface{}{name: change.Value}})
+		}
+	}
+	timer("DT", t.DT)
+	timer("ST", t.ST)
+	for i, cycle := range t.Frames {
+		events = append(events, chromeEvent{Name: fmt.Sprintf("frame %d", i+1), Phase: "i", Time: us(cycle + 1), Process: 1, Thread: frameTrack, Scope: "t"})
+	}
+	for _, sound := range t.Sound {
+		events = append(events, chromeEvent{Name: "beep", Phase: "X", Time: us(sound.Start), Duration: us(sound.End - sound.Start), Process: 1, Thread: soundTrack})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{"traceEvents": events, "displayTimeUnit": "ms"})
+}
@@ -0,0 +1,82 @@
+package timeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// the SVG's layout, in pixels.
+const (
+	svgMaxWidth   = 2000
+	svgLabelWidth = 90
+	svgLaneHeight = 40
+	svgLaneGap    = 10
+)
+
+// opcodeColors color instructions by their first hex digit, so you can see the
+// shape of a program at a glance: jumps and calls, arithmetic, drawing, and so on.
+var opcodeColors = [16]string{
+	"#888888", "#e6194b", "#f58231", "#ffe119", "#bfef45", "#3cb44b", "#42d4f4", "#4363d8",
+	"#911eb4", "#f032e6", "#a9a9a9", "#9a6324", "#800000", "#000075", "#469990", "#dcbeff",
+}
+
+// WriteSVG draws the timeline as an SVG, with a lane each for instructions (colored by
+// their first hex digit), the delay and sound timers, frames, and sound. Long timelines
+// are squeezed to fit in 2000 pixels.
+func (t *Timeline) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	scale := 4.0
+	if t.Cycles > 0 && float64(t.Cycles)*scale > svgMaxWidth {
+		scale = svgMaxWidth / float64(t.Cycles)
+	}
+	x := func(cycle int) float64 {
+		return svgLabelWidth + float64(cycle)*scale
+	}
+	lanes := []string{"instructions", "DT", "ST", "frames", "sound"}
+	width := x(t.Cycles) + 10
+	height := len(lanes)*(svgLaneHeight+svgLaneGap) + 30
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%d" font-family="monospace" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	top := func(lane int) int {
+		return 10 + lane*(svgLaneHeight+svgLaneGap)
+	}
+	for i, name := range lanes {
+		fmt.Fprintf(bw, `<text x="4" y="%d">%s</text>`+"\n", top(i)+svgLaneHeight/2+4, name)
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="#f4f4f4"/>`+"\n", svgLabelWidth, top(i), x(t.Cycles)-svgLabelWidth, svgLaneHeight)
+	}
+
+	for _, in := range t.Instructions {
+		fmt.Fprintf(bw, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s"><title>%03x: %04x</title></rect>`+"\n",
+			x(in.Cycle), top(0), scale, svgLaneHeight, opcodeColors[in.Opcode>>12], in.PC, in.Opcode)
+	}
+	// the timers are drawn as steps, full height being 255.
+	timer := func(lane int, changes []Change) {
+		fmt.Fprintf(bw, `<polyline fill="none" stroke="#4363d8" points="`)
+		y := func(value byte) float64 {
+			return float64(top(lane)+svgLaneHeight) - float64(value)*svgLaneHeight/255
+		}
+		for i, change := range changes {
+			if i > 0 {
+				fmt.Fprintf(bw, "%.2f,%.2f ", x(change.Cycle), y(changes[i-1].Value))
+			}
+			fmt.Fprintf(bw, "%.2f,%.2f ", x(change.Cycle), y(change.Value))
+		}
+		if len(changes) > 0 {
+			fmt.Fprintf(bw, "%.2f,%.2f", x(t.Cycles), y(changes[len(changes)-1].Value))
+		}
+		fmt.Fprintf(bw, `"/>`+"\n")
+	}
+	timer(1, t.DT)
+	timer(2, t.ST)
+	for _, cycle := range t.Frames {
+		fmt.Fprintf(bw, `<line x1="%.2f" y1="%d" x2="%.2f" y2="%d" stroke="black"/>`+"\n", x(cycle+1), top(3), x(cycle+1), top(3)+svgLaneHeight)
+	}
+	for _, sound := range t.Sound {
+		fmt.Fprintf(bw, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="#e6194b"/>`+"\n", x(sound.Start), top(4), float64(sound.End-sound.Start)*scale, svgLaneHeight)
+	}
+	fmt.Fprintf(bw, `<text x="%d" y="%d">0s</text>`+"\n", svgLabelWidth, height-6)
+	fmt.Fprintf(bw, `<text x="%.2f" y="%d" text-anchor="end">%.3fs (%d cycles)</text>`+"\n", x(t.Cycles), height-6, t.Seconds(t.Cycles), t.Cycles)
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
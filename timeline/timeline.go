@@ -0,0 +1,137 @@
+// Package timeline records what a Chip8 does over time -- every instruction, every tick
+// of the timers, every frame, every beep -- and draws it as a timing diagram, for
+// working out timing problems and quirks by looking at them instead of guessing.
+//
+// Timelines can be written as Chrome trace JSON, to open in chrome://tracing or
+// https://ui.perfetto.dev, or as a simple SVG to open in anything.
+package timeline
+
+import (
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Timeline is a recording of a Chip8 running. Times are counted in cycles -- one
+// instruction is one cycle -- since that's what the Chip8 counts in; Speed converts
+// them to seconds.
+type Timeline struct {
+	// Speed is how many cycles the Chip8 ran per second.
+	Speed int
+	// Cycles is how many cycles the timeline covers.
+	Cycles       int
+	Instructions []Instruction
+	// DT and ST list each value the timers took, with the cycle they took it on.
+	DT, ST []Change
+	// Frames lists the cycle each frame ended on.
+	Frames []int
+	// Sound lists the intervals the sound was on for.
+	Sound []Interval
+}
+
+// An Instruction is an instruction the Chip8 executed, on cycle Cycle.
+type Instruction struct {
+	Cycle  int
+	PC     uint16
+	Opcode uint16
+}
+
+// A Change is a timer getting a new value.
+type Change struct {
+	Cycle int
+	Value byte
+}
+
+// An Interval is a stretch of cycles from Start up to (but not including) End.
+type Interval struct {
+	Start, End int
+}
+
+// Seconds converts a number of cycles to seconds.
+func (t *Timeline) Seconds(cycles int) float64 {
+	return float64(cycles) / float64(t.Speed)
+}
+
+// Recorder records a Timeline. Make one before the Chip8, so it can listen to the speaker.
+type Recorder struct {
+	timeline Timeline
+	// cycle is the cycle being executed right now.
+	cycle int
+	// soundOn is true if the sound started and hasn't stopped yet.
+	soundOn bool
+}
+
+// NewRecorder returns a Recorder.
+func NewRecorder() *Recorder {
+	return new(Recorder)
+}
+
+// Speaker returns a Speaker for the Chip8 that tells the Recorder when the sound goes
+// on and off, and passes it on to next (which can be nil).
+func (r *Recorder) Speaker(next cpu.Speaker) cpu.Speaker {
+	return &recordingSpeaker{r, next}
+}
+
+type recordingSpeaker struct {
+	r    *Recorder
+	next cpu.Speaker
+}
+
+func (s *recordingSpeaker) StartSound() {
+	if !s.r.soundOn {
+		s.r.soundOn = true
+		s.r.timeline.Sound = append(s.r.timeline.Sound, Interval{Start: s.r.cycle, End: -1})
+	}
+	if s.next != nil {
+		s.next.StartSound()
+	}
+}
+
+func (s *recordingSpeaker) StopSound() {
+	if s.r.soundOn {
+		s.r.soundOn = false
+		s.r.timeline.Sound[len(s.r.timeline.Sound)-1].End = s.r.cycle + 1
+	}
+	if s.next != nil {
+		s.next.StopSound()
+	}
+}
+
+// Record steps c8 through cycles instructions (or until its program ends), recording
+// everything it does. c8 has to be stopped, with its Speaker from r.Speaker.
+func (r *Recorder) Record(c8 *cpu.Chip8, cycles int) *Timeline {
+	r.timeline = Timeline{Speed: c8.Speed()}
+	r.soundOn = false
+	state := c8.Snapshot()
+	r.timeline.DT = []Change{{0, state.DT}}
+	r.timeline.ST = []Change{{0, state.ST}}
+	frame := c8.FrameCount()
+	for r.cycle = 0; r.cycle < cycles; r.cycle++ {
+		pc := state.PC
+		opcode := uint16(state.Memory[int(pc)%len(state.Memory)])<<8 | uint16(state.Memory[(int(pc)+1)%len(state.Memory)])
+		if opcode == 0x0000 {
+			// that's the end of the program.
+			break
+		}
+		// StepN rather than Step, which would take two more snapshots and diff them.
+		c8.StepN(1)
+		r.timeline.Instructions = append(r.timeline.Instructions, Instruction{r.cycle, pc, opcode})
+
+		state = c8.Snapshot()
+		if last := r.timeline.DT[len(r.timeline.DT)-1]; state.DT != last.Value {
+			r.timeline.DT = append(r.timeline.DT, Change{r.cycle, state.DT})
+		}
+		if last := r.timeline.ST[len(r.timeline.ST)-1]; state.ST != last.Value {
+			r.timeline.ST = append(r.timeline.ST, Change{r.cycle, state.ST})
+		}
+		if f := c8.FrameCount(); f != frame {
+			frame = f
+			r.timeline.Frames = append(r.timeline.Frames, r.cycle)
+		}
+	}
+	r.timeline.Cycles = r.cycle
+	// a sound still going at the end lasts until the end, as far as we know.
+	if r.soundOn {
+		r.timeline.Sound[len(r.timeline.Sound)-1].End = r.cycle
+	}
+	t := r.timeline
+	return &t
+}
@@ -0,0 +1,71 @@
+package timeline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mpingram/chip8/asm"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/timeline"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+// Recorder.Record
+// should record each instruction, the timers counting down, frames and the sound
+// should stop at the end of the program
+func TestRecord(t *testing.T) {
+	rom, err := asm.Assemble(`
+		LD V0,3
+		LD ST,V0
+		LD DT,V0
+		ADD V1,1
+		ADD V1,1
+		ADD V1,1
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := timeline.NewRecorder()
+	c8 := cpu.NewChip8(nullKeyboard{}, r.Speaker(nil))
+	c8.SetLogLevel(cpu.LogNone)
+	c8.SetSpeed(120)
+	if err := c8.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	tl := r.Record(c8, 100)
+
+	if tl.Cycles != 6 || len(tl.Instructions) != 6 {
+		t.Fatalf("recorded %d cycles and %d instructions, want 6 of each", tl.Cycles, len(tl.Instructions))
+	}
+	if in := tl.Instructions[1]; in.Cycle != 1 || in.PC != 0x202 || in.Opcode != 0xf018 {
+		t.Errorf("second instruction is %+v", in)
+	}
+	// at 120 instructions a second, a frame is two cycles.
+	if len(tl.Frames) != 3 || tl.Frames[0] != 1 {
+		t.Errorf("frames ended on cycles %v, want 1, 3 and 5", tl.Frames)
+	}
+	if len(tl.Sound) != 1 || tl.Sound[0].Start != 1 {
+		t.Errorf("sound was on for %v, want once, from cycle 1", tl.Sound)
+	}
+	if len(tl.DT) < 2 || tl.DT[1] != (timeline.Change{Cycle: 2, Value: 3}) {
+		t.Errorf("DT went %v, want it set to 3 on cycle 2", tl.DT)
+	}
+
+	var trace bytes.Buffer
+	if err := tl.WriteChromeTrace(&trace); err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct{ TraceEvents []map[string]interface{} }
+	if err := json.Unmarshal(trace.Bytes(), &parsed); err != nil || len(parsed.TraceEvents) == 0 {
+		t.Errorf("the Chrome trace isn't JSON with events in it: %v", err)
+	}
+	var svg bytes.Buffer
+	if err := tl.WriteSVG(&svg); err != nil || !strings.HasPrefix(svg.String(), "<svg") {
+		t.Errorf("the SVG isn't an SVG: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package timing adds up how long a Chip8 spends on each instruction, by kind of
+// instruction and by where in the ROM it is, so when a game runs slow you can tell
+// whose fault it is: the emulator's, taking too long over the instructions, or the
+// game's, asking for more instructions than the speed it's running at allows.
+//
+// Hand a Profile to (*cpu.Chip8).TimeInstructions, play for a while, and write the
+// report, or a profile for go tool pprof, where the instructions are the functions
+// and the addresses are the lines.
+package timing
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+)
+
+// A Profile adds up instruction timings. It's safe to read while the Chip8 is
+// still writing to it.
+type Profile struct {
+	mu      sync.Mutex
+	started time.Time
+	ops     map[cpu.Op]*Total
+	addrs   map[uint16]*Total
+}
+
+// A Total is how many times something ran, and how long it took altogether.
+type Total struct {
+	// Name is the instruction, like "DRW Vx,Vy,n", for totals by instruction; and
+	// for totals by address, the instruction that was last at the address (a game
+	// that rewrites itself gets lumped together).
+	Name string
+	Op   cpu.Op
+	// Address is where the instruction was, for totals by address.
+	Address uint16
+	Count   int64
+	Time    time.Duration
+}
+
+// Average returns how long it took each time.
+func (t Total) Average() time.Duration {
+	if t.Count == 0 {
+		return 0
+	}
+	return t.Time / time.Duration(t.Count)
+}
+
+// NewProfile returns an empty profile, which starts the clock for the wall time.
+func NewProfile() *Profile {
+	return &Profile{
+		started: time.Now(),
+		ops:     make(map[cpu.Op]*Total),
+		addrs:   make(map[uint16]*Total),
+	}
+}
+
+// TimeInstruction writes down that the instruction at pc took took, for the Chip8.
+func (p *Profile) TimeInstruction(pc, opcode uint16, took time.Duration) {
+	op := cpu.Decode(opcode).Op
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.ops[op]
+	if t == nil {
+		t = &Total{Name: op.Syntax(), Op: op}
+		p.ops[op] = t
+	}
+	t.Count++
+	t.Time += took
+	a := p.addrs[pc]
+	if a == nil {
+		a = &Total{Address: pc}
+		p.addrs[pc] = a
+	}
+	a.Name, a.Op = op.Syntax(), op
+	a.Count++
+	a.Time += took
+}
+
+// Ops returns the totals by instruction, the slowest first.
+func (p *Profile) Ops() []Total {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []Total
+	for _, t := range p.ops {
+		out = append(out, *t)
+	}
+	return sorted(out)
+}
+
+// Addresses returns the totals by address, the slowest first.
+func (p *Profile) Addresses() []Total {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []Total
+	for _, t := range p.addrs {
+		out = append(out, *t)
+	}
+	return sorted(out)
+}
+
+// Elapsed returns how long it's been since the profile started: the wall time the
+// instructions had to fit in.
+func (p *Profile) Elapsed() time.Duration {
+	return time.Since(p.started)
+}
+
+// sorted sorts totals the most time first, and by name or address where that's
+// equal, so the same run makes the same report.
+func sorted(out []Total) []Total {
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Time != out[j].Time {
+			return out[i].Time > out[j].Time
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
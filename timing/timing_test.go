@@ -0,0 +1,113 @@
+package timing_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/timing"
+)
+
+type nullKeyboard struct{}
+
+func (nullKeyboard) Poll() cpu.KeyCode { return cpu.KeyNone }
+
+type nullSpeaker struct{}
+
+func (nullSpeaker) StartSound() {}
+func (nullSpeaker) StopSound()  {}
+
+// Profile
+// should add up every instruction the Chip8 runs, by instruction and by address
+// should put the slowest first
+func TestProfile(t *testing.T) {
+	p := timing.NewProfile()
+	c8 := cpu.NewChip8(nullKeyboard{}, nullSpeaker{})
+	c8.SetLogLevel(cpu.LogNone)
+	// draw the font's 0, then go round and round.
+	if err := c8.Load([]byte{0xf0, 0x29, 0xd0, 0x05, 0x12, 0x04}); err != nil {
+		t.Fatal(err)
+	}
+	c8.TimeInstructions(p)
+	for i := 0; i < 10; i++ {
+		c8.Step()
+	}
+	c8.TimeInstructions(nil)
+	c8.Step()
+
+	counts := map[string]int64{}
+	for _, op := range p.Ops() {
+		counts[op.Name] = op.Count
+	}
+	if counts["LD F,Vx"] != 1 || counts["DRW Vx,Vy,n"] != 1 || counts["JP nnn"] != 8 {
+		t.Errorf("got %+v", p.Ops())
+	}
+	addrs := p.Addresses()
+	if len(addrs) != 3 {
+		t.Fatalf("got %+v", addrs)
+	}
+	for _, a := range addrs {
+		if a.Address == 0x204 && (a.Count != 8 || a.Name != "JP nnn") {
+			t.Errorf("got %+v for the jump", a)
+		}
+	}
+
+	p = timing.NewProfile()
+	p.TimeInstruction(0x200, 0x00e0, time.Millisecond)
+	p.TimeInstruction(0x202, 0xd015, 3*time.Millisecond)
+	p.TimeInstruction(0x200, 0x00e0, time.Millisecond)
+	if ops := p.Ops(); ops[0].Name != "DRW Vx,Vy,n" || ops[1].Average() != time.Millisecond {
+		t.Errorf("got %+v", ops)
+	}
+}
+
+// WriteReport
+// should say whether the emulator's keeping up
+// should list the slowest instructions and addresses
+func TestWriteReport(t *testing.T) {
+	p := timing.NewProfile()
+	p.TimeInstruction(0x2a4, 0xd015, 5*time.Millisecond)
+	p.TimeInstruction(0x200, 0x6001, time.Millisecond)
+	var b bytes.Buffer
+	if err := p.WriteReport(&b, 700, 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"2 instructions ran", "the emulator can't keep up", "DRW Vx,Vy,n", "0x2a4"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report doesn't say %q:\n%s", want, b.String())
+		}
+	}
+	b.Reset()
+	p.WriteReport(&b, 1, 1)
+	if !strings.Contains(b.String(), "keeping up easily") || strings.Contains(b.String(), "LD Vx,nn") {
+		t.Errorf("got\n%s", b.String())
+	}
+}
+
+// WriteProfile
+// should write a gzipped profile, with the instruction names in it
+func TestWriteProfile(t *testing.T) {
+	p := timing.NewProfile()
+	p.TimeInstruction(0x2a4, 0xd015, 5*time.Millisecond)
+	var b bytes.Buffer
+	if err := p.WriteProfile(&b, "pong.ch8"); err != nil {
+		t.Fatal(err)
+	}
+	z, err := gzip.NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DRW Vx,Vy,n", "pong.ch8", "nanoseconds"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("profile doesn't have %q in it: %q", want, data)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package timing
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteReport writes the profile out for reading: how much of the time went on
+// executing instructions, whether that's more than the speed allows for, and the
+// top instructions and addresses that it went on. speed is the instructions a
+// second the Chip8 ran at, or 0 if it ran as fast as it could.
+func (p *Profile) WriteReport(w io.Writer, speed, top int) error {
+	ops, addrs := p.Ops(), p.Addresses()
+	elapsed := p.Elapsed()
+	var count int64
+	var busy time.Duration
+	for _, t := range ops {
+		count += t.Count
+		busy += t.Time
+	}
+	if count == 0 {
+		_, err := fmt.Fprintf(w, "No instructions ran in %v.\n", elapsed.Round(time.Millisecond))
+		return err
+	}
+	average := busy / time.Duration(count)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d instructions ran in %v, and executing them took %v of it (%.1f%%).\n",
+		count, elapsed.Round(time.Millisecond), busy.Round(time.Microsecond), percent(busy, elapsed))
+	if speed > 0 {
+		// each instruction gets a 1/speed slice of the second; if executing one
+		// takes longer than that on average, no amount of waiting less catches up.
+		budget := time.Second / time.Duration(speed)
+		fmt.Fprintf(&b, "That's %v an instruction, against the %v each one has at %d instructions a second: ", average, budget, speed)
+		switch {
+		case average > budget:
+			fmt.Fprintf(&b, "the emulator can't keep up, so if the game's slow, it's the emulator.\n")
+		case average > budget/2:
+			fmt.Fprintf(&b, "the emulator's keeping up, but only just.\n")
+		default:
+			fmt.Fprintf(&b, "the emulator's keeping up easily, so if the game's slow, it's the game (try a higher -speed).\n")
+		}
+	} else {
+		fmt.Fprintf(&b, "That's %v an instruction, running as fast as it can.\n", average)
+	}
+
+	fmt.Fprintf(&b, "\nBy instruction:\n")
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "instruction\tcount\ttime\taverage\tshare\t\n")
+	for i, t := range ops {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%.1f%%\t\n", t.Name, t.Count, t.Time, t.Average(), percent(t.Time, busy))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(&b, "\nBy address:\n")
+	tw = tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "address\tinstruction\tcount\ttime\taverage\tshare\t\n")
+	for i, t := range addrs {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%#03x\t%s\t%d\t%v\t%v\t%.1f%%\t\n", t.Address, t.Name, t.Count, t.Time, t.Average(), percent(t.Time, busy))
+	}
+	tw.Flush()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func percent(part, whole time.Duration) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
+
+// WriteProfile writes the profile in pprof's format, for go tool pprof: each kind
+// of instruction is a function, and each address a line in it, so -top shows which
+// instructions took the time and -lines which addresses did (in decimal, since
+// they're line numbers: 0x2a4 is line 676). rom is the file name to give them.
+//
+// The format's a gzipped protocol buffer (github.com/google/pprof's profile.proto);
+// we only need a few of its fields, which is little enough to write by hand rather
+// than pull in protobuf for.
+func (p *Profile) WriteProfile(w io.Writer, rom string) error {
+	addrs := p.Addresses()
+	var pb protoBuffer
+	strs := map[string]int64{}
+	str := func(s string) int64 {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = int64(len(strs))
+		return strs[s]
+	}
+	str("") // string_table[0] has to be empty.
+
+	// sample_type: a count of instructions, and the time they took.
+	for _, t := range [][2]string{{"instructions", "count"}, {"time", "nanoseconds"}} {
+		var vt protoBuffer
+		vt.varint(1, uint64(str(t[0])))
+		vt.varint(2, uint64(str(t[1])))
+		pb.bytes(1, vt)
+	}
+	functions := map[string]uint64{}
+	for i, t := range addrs {
+		id := uint64(i + 1)
+		var sample protoBuffer
+		sample.packed(1, id)
+		sample.packed(2, uint64(t.Count), uint64(t.Time.Nanoseconds()))
+		pb.bytes(2, sample)
+
+		fn, ok := functions[t.Name]
+		if !ok {
+			fn = uint64(len(functions) + 1)
+			functions[t.Name] = fn
+		}
+		var line protoBuffer
+		line.varint(1, fn)
+		line.varint(2, uint64(t.Address))
+		var loc protoBuffer
+		loc.varint(1, id)
+		loc.varint(3, uint64(t.Address))
+		loc.bytes(4, line)
+		pb.bytes(4, loc)
+	}
+	for name, id := range functions {
+		var fn protoBuffer
+		fn.varint(1, id)
+		fn.varint(2, uint64(str(name)))
+		fn.varint(3, uint64(str(name)))
+		fn.varint(4, uint64(str(rom)))
+		pb.bytes(5, fn)
+	}
+	// the string table has to go in the order the strings were numbered.
+	table := make([]string, len(strs))
+	for s, i := range strs {
+		table[i] = s
+	}
+	for _, s := range table {
+		pb.bytes(6, protoBuffer(s))
+	}
+	pb.varint(9, uint64(p.started.UnixNano()))
+	pb.varint(10, uint64(p.Elapsed().Nanoseconds()))
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(pb); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// A protoBuffer is a protocol buffer message being written, field by field.
+type protoBuffer []byte
+
+func (pb *protoBuffer) uvarint(v uint64) {
+	for v >= 0x80 {
+		*pb = append(*pb, byte(v)|0x80)
+		v >>= 7
+	}
+	*pb = append(*pb, byte(v))
+}
+
+// varint writes a number field.
+func (pb *protoBuffer) varint(field int, v uint64) {
+	pb.uvarint(uint64(field)<<3 | 0)
+	pb.uvarint(v)
+}
+
+// bytes writes a string, or a message, field.
+func (pb *protoBuffer) bytes(field int, data []byte) {
+	pb.uvarint(uint64(field)<<3 | 2)
+	pb.uvarint(uint64(len(data)))
+	*pb = append(*pb, data...)
+}
+
+// packed writes a repeated number field, all at once.
+func (pb *protoBuffer) packed(field int, vs ...uint64) {
+	var data protoBuffer
+	for _, v := range vs {
+		data.uvarint(v)
+	}
+	pb.bytes(field, data)
+}
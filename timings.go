@@ -0,0 +1,31 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpingram/chip8/timing"
+)
+
+// writeTimings writes where the time went to path, for -timing: a profile for go
+// tool pprof if path looks like one, and a report to read if it doesn't.
+func writeTimings(path string, p *timing.Profile, speed int, romPath string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".pprof") || strings.HasSuffix(path, ".pb.gz") {
+		err = p.WriteProfile(f, filepath.Base(romPath))
+	} else {
+		err = p.WriteReport(f, speed, 20)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,60 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+)
+
+// windowTitle keeps the window's title up to date with what's going on: which ROM
+// it is, whether it's running, how fast, and whether anyone's watching. It beats
+// "Chip-8" when you've got three of them open.
+type windowTitle struct {
+	window *glfw.Window
+	// rom is the ROM's name, without the folders or the .ch8.
+	rom string
+	// netplay is true if a netplay session runs the Chip8, a frame at a time.
+	netplay bool
+	// broadcasting is true if spectators can watch over HTTP.
+	broadcasting bool
+	// current is the title the window has now, so we only set it when it changes.
+	current string
+}
+
+func newWindowTitle(window *glfw.Window, romPath string) *windowTitle {
+	return &windowTitle{window: window, rom: romLabel(romPath)}
+}
+
+// update sets the title from the Chip8's state. It has to be called on the main thread.
+func (t *windowTitle) update(c8 *cpu.Chip8) {
+	parts := []string{t.rom}
+	switch {
+	case t.netplay:
+		// the session halts and resumes the Chip8 every frame, so don't believe IsRunning.
+		parts = append(parts, "netplay")
+	case c8.IsRunning():
+		parts = append(parts, "running")
+	default:
+		parts = append(parts, "paused")
+	}
+	speed := fmt.Sprintf("%d Hz", c8.Speed())
+	if c8.IsTurbo() {
+		speed += ", turbo"
+	} else if rate := c8.SlowMotion(); rate < 1 {
+		speed += fmt.Sprintf(" at %gx", rate)
+	}
+	parts = append(parts, speed)
+	if t.broadcasting {
+		parts = append(parts, "● broadcasting")
+	}
+	title := strings.Join(parts, " · ") + " - Chip-8"
+	if title != t.current {
+		t.current = title
+		t.window.SetTitle(title)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mpingram/chip8/asm"
+)
+
+// tutorialSpeed is how fast tutorials run unless you say otherwise: slow enough to
+// read the explanation of each instruction as it goes by.
+const tutorialSpeed = 10
+
+// tutorialPrefix marks a ROM path as one of the built-in tutorials, like tutorial:2.
+const tutorialPrefix = "tutorial:"
+
+//go:embed tutorials/*.asm
+var tutorialFiles embed.FS
+
+func init() {
+	commands["tutorial"] = command{
+		usage: "take a guided tour of the instruction set: list the tutorials, or play tutorial N with each instruction explained",
+		play:  tutorialCommand,
+	}
+}
+
+// tutorialCommand prints tutorial N's source, so you can read along, and then has it
+// played like any other ROM with -explain turned on. Any flags after N are passed
+// on, so `chip8 tutorial 2 -speed 100` speeds it up.
+func tutorialCommand(args []string) ([]string, error) {
+	names, err := tutorials()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("usage: chip8 tutorial N [flags]\n\nTutorials:")
+		for i, name := range names {
+			fmt.Printf("  %d  %s\n", i+1, tutorialTitle(name))
+		}
+		return nil, nil
+	}
+	source, err := tutorialSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println(source)
+	return append([]string{"-explain", "-speed", strconv.Itoa(tutorialSpeed)}, append(args[1:], tutorialPrefix+args[0])...), nil
+}
+
+// tutorials returns the tutorials' file names, in order.
+func tutorials() ([]string, error) {
+	entries, err := tutorialFiles.ReadDir("tutorials")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names, nil
+}
+
+// tutorialTitle turns a file name like "2-count-with-bcd.asm" into "count with BCD".
+func tutorialTitle(name string) string {
+	title := strings.TrimSuffix(name, path.Ext(name))
+	title = title[strings.Index(title, "-")+1:]
+	return strings.Replace(strings.Replace(title, "-", " ", -1), "bcd", "BCD", -1)
+}
+
+// tutorialSource returns the source of tutorial n, counting from 1.
+func tutorialSource(n string) (string, error) {
+	names, err := tutorials()
+	if err != nil {
+		return "", err
+	}
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(names) {
+		return "", fmt.Errorf("there's no tutorial %q; there are %d, numbered from 1", n, len(names))
+	}
+	source, err := tutorialFiles.ReadFile("tutorials/" + names[i-1])
+	return string(source), err
+}
+
+// readROM reads the ROM at romPath, which can also be a built-in tutorial, like
+// tutorial:1, which gets assembled on the spot, or an Octo cartridge, which gets
+// compiled.
+func readROM(romPath string) ([]byte, error) {
+	if isCartridge(romPath) {
+		return cartridgeROM(romPath)
+	}
+	if !strings.HasPrefix(romPath, tutorialPrefix) {
+		return ioutil.ReadFile(romPath)
+	}
+	source, err := tutorialSource(strings.TrimPrefix(romPath, tutorialPrefix))
+	if err != nil {
+		return nil, err
+	}
+	rom, err := asm.Assemble(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", romPath, err)
+	}
+	return rom, nil
+}
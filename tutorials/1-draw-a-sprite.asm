@@ -0,0 +1,42 @@
+; Tutorial 1: draw a sprite
+;
+; Everything on the Chip8's screen is drawn with sprites: little pictures eight
+; pixels wide and up to fifteen rows tall. Each row is one byte, and each bit
+; of the byte is one pixel -- 1 for on, 0 for off. The screen is 64 pixels
+; across and 32 down, with 0,0 in the top left corner.
+;
+; Drawing is done with DRW, which needs three things: I pointing at the sprite's
+; bytes in memory, two registers holding the x and y to draw it at, and how
+; many rows tall it is.
+
+    CLS                 ; start with a blank screen
+    LD V0,28            ; V0 is x: 28 pixels from the left, about the middle
+    LD V1,12            ; V1 is y: 12 pixels from the top
+    LD I,smiley         ; point I at the sprite (smiley is a label -- see below)
+    DRW V0,V1,8         ; draw all 8 rows of it. There it is!
+
+; Sprites aren't painted on, they're XORed: drawing a pixel that's already on
+; turns it off again. That's how games erase things -- they draw them again.
+; When that happens, DRW sets VF to 1, so the game can tell two sprites have
+; bumped into each other. Let's draw the smiley again, a bit to the right,
+; and watch VF.
+
+    ADD V0,4            ; move 4 pixels right
+    DRW V0,V1,8         ; the overlap disappears, and VF says there was a collision
+
+; A program mustn't run off the end into whatever's next in memory, so it
+; finishes by jumping to itself forever.
+end:
+    JP end
+
+; The sprite itself. DB puts bytes straight into the program, and writing
+; them in binary lets you see the picture.
+smiley:
+    DB 0b00111100
+    DB 0b01000010
+    DB 0b10100101
+    DB 0b10000001
+    DB 0b10100101
+    DB 0b10011001
+    DB 0b01000010
+    DB 0b00111100
@@ -0,0 +1,39 @@
+; Tutorial 2: count with BCD
+;
+; Registers hold numbers from 0 to 255, but the screen only shows pictures, so
+; to show a score a game has to turn it into digits and draw each one. The
+; Chip8 has two instructions that do nearly all the work:
+;
+;   LD B,Vx   writes Vx in decimal, one digit per byte, at I, I+1 and I+2 --
+;             "binary coded decimal", or BCD. 137 becomes 1, 3, 7.
+;   LD F,Vx   points I at the built-in sprite for the digit in Vx. Every
+;             Chip8 has a font of 5-row sprites for 0 to F in its memory.
+;
+; This program counts from 0 to 255 (and around again), drawing the count.
+
+    LD V3,0             ; V3 is the count
+
+loop:
+    LD I,digits         ; point I at three spare bytes
+    LD B,V3             ; write the count's hundreds, tens and ones there
+    LD V2,[I]           ; and read them back into V0, V1 and V2 all at once
+
+    CLS
+    LD V4,24            ; V4 is x, starting left of the middle
+    LD V5,13            ; V5 is y
+    LD F,V0             ; the hundreds digit's sprite...
+    DRW V4,V5,5         ; ...drawn
+    ADD V4,5            ; step right a digit's width, plus a gap
+    LD F,V1             ; the tens
+    DRW V4,V5,5
+    ADD V4,5
+    LD F,V2             ; the ones
+    DRW V4,V5,5
+
+    ADD V3,1            ; count one more (after 255 comes 0 again)
+    JP loop
+
+; Room for the three digits. Programs can keep their variables right next to
+; their code -- it's all just memory.
+digits:
+    DB 0,0,0
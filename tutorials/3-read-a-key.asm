@@ -0,0 +1,39 @@
+; Tutorial 3: read a key
+;
+; The Chip8's keypad has sixteen keys, 0 to F. On your keyboard they're the
+; block from 1 to V, with the bottom row of the keypad along the top:
+;
+;   1 2 3 C      Q W E R
+;   4 5 6 D      A S D F
+;   7 8 9 E  =>  Z X C V
+;   A 0 B F      1 2 3 4
+;
+; There are two ways to read them. LD Vx,K stops everything until a key is
+; pressed, and puts it in Vx -- good for "press a key to start". SKP Vx and
+; SKNP Vx skip the next instruction if the key in Vx is (or isn't) held down
+; right now, without waiting -- good for moving a paddle.
+;
+; This program waits for a key, shows which one it was, and then shows a bar
+; for as long as the key is held.
+
+    LD V1,28            ; x and y of the digit
+    LD V2,13
+
+loop:
+    LD V0,K             ; wait for a key. Press one!
+    CLS
+    LD F,V0             ; point I at the font's sprite for the key's digit
+    DRW V1,V2,5         ; and draw it
+
+    LD I,bar
+    LD V3,26            ; x and y of the bar, under the digit
+    LD V4,20
+    DRW V3,V4,1         ; draw the bar...
+held:
+    SKNP V0             ; ...and if the key isn't held any more, skip the next line
+    JP held             ; it's still held, so keep checking
+    DRW V3,V4,1         ; let go: draw the bar again, which rubs it out
+    JP loop             ; and wait for the next key
+
+bar:
+    DB 0b11111111
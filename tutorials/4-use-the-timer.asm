@@ -0,0 +1,33 @@
+; Tutorial 4: use the timer
+;
+; The Chip8 runs as fast as it likes, so games can't count instructions to
+; keep time. Instead there are two timers, which count down by one sixty times
+; a second until they get to 0, whatever the Chip8 is doing:
+;
+;   the delay timer, DT, which programs set with LD DT,Vx and read with LD Vx,DT
+;   the sound timer, ST, which beeps for as long as it isn't 0
+;
+; This program blinks a dot twice a second, beeping as it appears.
+
+    LD I,dot
+    LD V0,31            ; x and y of the dot, in the middle of the screen
+    LD V1,15
+
+loop:
+    DRW V0,V1,1         ; XOR the dot on (or off, every other time)
+    SE VF,0             ; VF is 1 if it just rubbed a dot out...
+    JP wait             ; ...so no beep
+    LD V2,3
+    LD ST,V2            ; beep for 3 sixtieths of a second
+
+wait:
+    LD V2,30
+    LD DT,V2            ; half a second is 30 sixtieths
+tick:
+    LD V2,DT            ; see how much is left
+    SE V2,0             ; once it's run out, skip the jump and carry on
+    JP tick             ; otherwise keep waiting
+    JP loop
+
+dot:
+    DB 0b11000000
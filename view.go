@@ -0,0 +1,82 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/mpingram/chip8/cpu"
+	"github.com/mpingram/chip8/remote"
+)
+
+func init() {
+	commands["view"] = command{
+		usage: "show the screen of a Chip8 running somewhere else (chip8 -headless -display), and play it from here",
+		run:   viewCommand,
+	}
+}
+
+// viewCommand is the thin end of the remote display protocol: it draws the frames the
+// server sends and sends back the keys pressed here. There's no Chip8 in this process at all.
+func viewCommand(args []string) error {
+	flags := flag.NewFlagSet("view", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: chip8 view host:port\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	client, err := remote.Dial(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	window := openWindow(false, 0)
+	defer glfw.Terminate()
+	renderer := NewOpenGLRenderer(window)
+	input := NewGLFWKeyboardInput(window)
+	osd := new(onScreenDisplay)
+
+	var frame cpu.Frame
+	var sentKeys uint16
+	connected := true
+	refresh := time.NewTicker(time.Second / 60)
+	defer refresh.Stop()
+	for !window.ShouldClose() {
+		glfw.PollEvents()
+		if keys := input.Held(); connected && keys != sentKeys {
+			if client.SendKeys(keys) == nil {
+				sentKeys = keys
+			}
+		}
+
+		<-refresh.C
+		frameReady := false
+		select {
+		case frame = <-client.Frames():
+			frameReady = true
+		case <-client.Done():
+			if connected {
+				connected = false
+				log.Printf("view: lost the connection: %v", client.Err())
+				osd.showPrompt("connection lost")
+			}
+		default:
+		}
+		overlayChanged := renderer.SetOverlay(osd.lines(time.Now()))
+		if frameReady || overlayChanged {
+			renderer.Render(cpu.ColorFrame{frame})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build fsnotify
+// +build fsnotify
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// start has the operating system tell us about the file, and sends on raw whenever
+// it's written, or a new one's put in its place.
+func (w *Watcher) start(path string) (<-chan struct{}, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// watch the folder rather than the file: plenty of editors and assemblers write
+	// a new file and rename it over the old one, and a watch on the old file goes
+	// wherever the old file goes.
+	if err := notify.Add(filepath.Dir(path)); err != nil {
+		notify.Close()
+		return nil, err
+	}
+	name := filepath.Clean(path)
+	raw := make(chan struct{})
+	go func() {
+		defer notify.Close()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case event, ok := <-notify.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != name || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				select {
+				case raw <- struct{}{}:
+				case <-w.stop:
+					return
+				}
+			case _, ok := <-notify.Errors:
+				// the worst that happens is a change we don't hear about, and then
+				// there'll be another one along when the file's saved again.
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return raw, nil
+}
@@ -0,0 +1,48 @@
+//go:build !fsnotify
+// +build !fsnotify
+
+package watch
+
+import (
+	"os"
+	"time"
+)
+
+// pollInterval is how often the file gets looked at.
+const pollInterval = 250 * time.Millisecond
+
+// start looks at the file every pollInterval, and sends on raw whenever its size
+// or modification time is different from last time.
+func (w *Watcher) start(path string) (<-chan struct{}, error) {
+	last, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				// it's most likely in the middle of being saved; look again next time.
+				continue
+			}
+			if info.Size() == last.Size() && info.ModTime().Equal(last.ModTime()) {
+				continue
+			}
+			last = info
+			select {
+			case raw <- struct{}{}:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return raw, nil
+}
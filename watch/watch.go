@@ -0,0 +1,81 @@
+// Package watch watches a file for changes -- a ROM, say, that you're editing and
+// assembling over and over, so the emulator can load each new version as soon as
+// it's there, without anyone having to go and ask it to.
+//
+// Out of the box it looks at the file's size and modification time a few times a
+// second, which needs nothing but the standard library and works everywhere,
+// network drives and all. Built with the fsnotify tag, it asks the operating
+// system to say when the file changes instead, which is quicker off the mark and
+// doesn't keep waking up to look:
+//
+//	go get github.com/fsnotify/fsnotify
+//	go build -tags fsnotify
+//
+// Either way, a save that takes several writes (or a write and a rename) is one change.
+package watch
+
+import "time"
+
+// settle is how long a file has to be left alone after it changes before it counts
+// as changed: assemblers and editors rarely write a file in one go, and a ROM
+// that's half written is no use to anyone.
+const settle = 100 * time.Millisecond
+
+// A Watcher watches a file.
+type Watcher struct {
+	changes chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New starts watching the file at path, which has to be there to begin with.
+func New(path string) (*Watcher, error) {
+	w := &Watcher{
+		// a change nobody's picked up yet is as good as any number of them.
+		changes: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	raw, err := w.start(path)
+	if err != nil {
+		return nil, err
+	}
+	go w.debounce(raw)
+	return w, nil
+}
+
+// Changes returns a channel that receives a value when the file's changed.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Close stops watching the file.
+func (w *Watcher) Close() error {
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+// debounce passes the changes that come in on raw along to Changes, once they've
+// stopped coming for a while.
+func (w *Watcher) debounce(raw <-chan struct{}) {
+	defer close(w.done)
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case _, ok := <-raw:
+			if !ok {
+				return
+			}
+			settled = time.After(settle)
+		case <-settled:
+			settled = nil
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package watch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpingram/chip8/watch"
+)
+
+// New / Changes
+// should say the file's changed, once, when it's been saved in a few goes
+// should say nothing while the file's left alone
+// should refuse to watch a file that isn't there
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.ch8")
+	if err := os.WriteFile(path, []byte{0x12, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := watch.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	select {
+	case <-w.Changes():
+		t.Fatalf("the file changed without anyone touching it")
+	case <-time.After(600 * time.Millisecond):
+	}
+
+	for _, rom := range [][]byte{{0x00}, {0x00, 0xe0}, {0x00, 0xe0, 0x12, 0x00}} {
+		if err := os.WriteFile(path, rom, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-w.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the file changed, and nobody said")
+	}
+	select {
+	case <-w.Changes():
+		t.Errorf("one save was two changes")
+	case <-time.After(600 * time.Millisecond):
+	}
+
+	if _, err := watch.New(filepath.Join(t.TempDir(), "missing.ch8")); err == nil {
+		t.Errorf("watching a file that isn't there worked")
+	}
+}